@@ -21,10 +21,12 @@ type Config struct {
 	AppKey      string
 	PartnQuery  string
 	BrokerQuery string
-	BrokerIDTag string
-	Span        int
-	ZKAddr      string
-	ZKPrefix    string
+	// BrokerTotalQuery is optional.
+	BrokerTotalQuery string
+	BrokerIDTag      string
+	Span             int
+	ZKAddr           string
+	ZKPrefix         string
 }
 
 var config = &Config{} // :(
@@ -33,6 +35,7 @@ func init() {
 	flag.StringVar(&config.APIKey, "api-key", "", "Datadog API key")
 	flag.StringVar(&config.AppKey, "app-key", "", "Datadog app key")
 	bq := flag.String("broker-storage-query", "avg:system.disk.free{service:kafka,device:/data}", "Datadog metric query to get broker storage free")
+	btq := flag.String("broker-storage-total-query", "avg:system.disk.total{service:kafka,device:/data}", "Datadog metric query to get broker storage total (an empty value skips storage total)")
 	flag.StringVar(&config.BrokerIDTag, "broker-id-tag", "broker_id", "Datadog host tag for broker ID")
 	pq := flag.String("partition-size-query", "max:kafka.log.partition.size{service:kafka} by {topic,partition}", "Datadog metric query to get partition size by topic, partition")
 	flag.IntVar(&config.Span, "span", 3600, "Query range in seconds (now - span)")
@@ -45,6 +48,10 @@ func init() {
 	// Complete query string.
 	config.BrokerQuery = fmt.Sprintf("%s by {%s}.rollup(avg, %d)", *bq, config.BrokerIDTag, config.Span)
 	config.PartnQuery = fmt.Sprintf("%s.rollup(avg, %d)", *pq, config.Span)
+
+	if *btq != "" {
+		config.BrokerTotalQuery = fmt.Sprintf("%s by {%s}.rollup(avg, %d)", *btq, config.BrokerIDTag, config.Span)
+	}
 }
 
 func main() {
@@ -37,14 +37,32 @@ func partitionMetrics(c *Config) (map[string]map[string]map[string]float64, erro
 }
 
 func brokerMetrics(c *Config) (map[string]map[string]float64, error) {
-	start := time.Now().Add(-time.Duration(c.Span) * time.Second).Unix()
-	o, err := c.Client.QueryMetrics(start, time.Now().Unix(), c.BrokerQuery)
-	if err != nil {
+	// Populate.
+	d := map[string]map[string]float64{}
+
+	if err := brokerMetric(c, c.BrokerQuery, "StorageFree", d); err != nil {
 		return nil, err
 	}
 
-	// Populate.
-	d := map[string]map[string]float64{}
+	// Storage total is optional.
+	if c.BrokerTotalQuery != "" {
+		if err := brokerMetric(c, c.BrokerTotalQuery, "StorageTotal", d); err != nil {
+			return nil, err
+		}
+	}
+
+	return d, nil
+}
+
+// brokerMetric runs the query and populates the
+// value of each series under the key for the
+// respective broker in d.
+func brokerMetric(c *Config, query, key string, d map[string]map[string]float64) error {
+	start := time.Now().Add(-time.Duration(c.Span) * time.Second).Unix()
+	o, err := c.Client.QueryMetrics(start, time.Now().Unix(), query)
+	if err != nil {
+		return err
+	}
 
 	for _, ts := range o {
 		broker := tagValFromScope(ts.GetScope(), c.BrokerIDTag)
@@ -62,10 +80,10 @@ func brokerMetrics(c *Config) (map[string]map[string]float64, error) {
 			d[broker] = map[string]float64{}
 		}
 
-		d[broker]["StorageFree"] = *ts.Points[0][1]
+		d[broker][key] = *ts.Points[0][1]
 	}
 
-	return d, nil
+	return nil
 }
 
 // tagValFromScope takes a metric scope string
@@ -7,6 +7,7 @@ import (
 	"os"
 	"os/signal"
 	"sync"
+	"time"
 
 	"github.com/DataDog/kafka-kit/kafkazk"
 	"github.com/DataDog/kafka-kit/registry/server"
@@ -20,15 +21,34 @@ func main() {
 
 	flag.StringVar(&serverConfig.HTTPListen, "http-listen", "localhost:8080", "Server HTTP listen address")
 	flag.StringVar(&serverConfig.GRPCListen, "grpc-listen", "localhost:8090", "Server gRPC listen address")
+	flag.StringVar(&serverConfig.MetricsListen, "metrics-listen", "", "Server Prometheus metrics listen address, e.g. localhost:8070 (disabled if empty)")
 	flag.IntVar(&serverConfig.ReadReqRate, "read-rate-limit", 5, "Read request rate limit (reqs/s)")
 	flag.IntVar(&serverConfig.WriteReqRate, "write-rate-limit", 1, "Write request rate limit (reqs/s)")
 	flag.StringVar(&serverConfig.ZKTagsPrefix, "zk-tags-prefix", "registry", "Tags storage ZooKeeper prefix")
 	flag.StringVar(&zkConfig.Connect, "zk-addr", "localhost:2181", "ZooKeeper connect string")
 	flag.StringVar(&zkConfig.Prefix, "zk-prefix", "", "ZooKeeper prefix (if Kafka is configured with a chroot path prefix)")
+	flag.StringVar(&zkConfig.MetricsPrefix, "zk-metrics-prefix", "topicmappr", "ZooKeeper namespace prefix for Kafka metrics")
+	flag.IntVar(&serverConfig.ZKRetryAttempts, "zk-retry-attempts", 3, "Maximum attempts for ZooKeeper reads failing with connection errors")
+	flag.DurationVar(&serverConfig.ZKRetryBackoff, "zk-retry-backoff", 100*time.Millisecond, "Initial ZooKeeper retry backoff (doubled on each retry)")
+	flag.BoolVar(&serverConfig.Reflection, "grpc-reflection", false, "Enable the gRPC server reflection service")
+	logLevel := flag.String("log-level", "info", "Minimum log level: [debug, info, warn, error]")
+	concurrencyLimits := flag.String("concurrency-limits", "", "Maximum concurrent requests per RPC method, as comma delimited method=limit pairs (e.g. GetTopics=4)")
 
 	envy.Parse("REGISTRY")
 	flag.Parse()
 
+	limits, err := server.ParseConcurrencyLimits(*concurrencyLimits)
+	if err != nil {
+		log.Fatal(err)
+	}
+	serverConfig.ConcurrencyLimits = limits
+
+	level, err := server.ParseLogLevel(*logLevel)
+	if err != nil {
+		log.Fatalf("%s: %s", err, *logLevel)
+	}
+	serverConfig.Logger = server.NewStdLogger(log.New(os.Stderr, "", log.LstdFlags), level)
+
 	log.Println("Registry running")
 
 	ctx, cancel := context.WithCancel(context.Background())
@@ -55,6 +75,11 @@ func main() {
 		log.Fatal(err)
 	}
 
+	// Start the metrics listener.
+	if err := srvr.RunMetrics(ctx, wg); err != nil {
+		log.Fatal(err)
+	}
+
 	// Graceful shutdown on SIGINT.
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt)
@@ -0,0 +1,163 @@
+package commands
+
+import (
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strings"
+
+	"github.com/DataDog/kafka-kit/kafkazk"
+
+	"gopkg.in/yaml.v2"
+)
+
+// affinityRules holds topic placement rules
+// loaded from an --affinity-rules file.
+type affinityRules struct {
+	// Groups of topics whose replicas
+	// shouldn't share brokers.
+	AntiAffinity [][]string `yaml:"anti-affinity"`
+	// Either "strict" (the default) or "soft".
+	Mode string `yaml:"mode"`
+}
+
+// affinityRulesFromFile reads a YAML or JSON affinity rules file at path p
+// and returns the equivalent *kafkazk.TopicAntiAffinity.
+func affinityRulesFromFile(p string) (*kafkazk.TopicAntiAffinity, error) {
+	data, err := ioutil.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+
+	return affinityRulesFromBytes(data)
+}
+
+// affinityRulesFromBytes unmarshals and validates YAML or JSON affinity
+// rules, returning the equivalent *kafkazk.TopicAntiAffinity. Unknown
+// fields are returned as errors.
+func affinityRulesFromBytes(data []byte) (*kafkazk.TopicAntiAffinity, error) {
+	rules := affinityRules{}
+	if err := yaml.UnmarshalStrict(data, &rules); err != nil {
+		return nil, fmt.Errorf("invalid affinity rules file: %s", err)
+	}
+
+	a := &kafkazk.TopicAntiAffinity{Groups: rules.AntiAffinity}
+
+	switch rules.Mode {
+	case "", "strict":
+	case "soft":
+		a.Soft = true
+	default:
+		return nil, fmt.Errorf("invalid affinity rules file: mode must be either 'strict' or 'soft'")
+	}
+
+	if len(a.Groups) == 0 {
+		return nil, fmt.Errorf("invalid affinity rules file: no anti-affinity groups defined")
+	}
+
+	if err := a.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid affinity rules file: %s", err)
+	}
+
+	return a, nil
+}
+
+// setExistingTopics fetches the partition maps of all topics in the anti-
+// affinity groups that aren't in the PartitionMap being rebuilt, setting
+// them as the Existing assignments. Topics that don't exist are skipped.
+func setExistingTopics(zk kafkazk.Handler, a *kafkazk.TopicAntiAffinity, pm *kafkazk.PartitionMap) error {
+	rebuilt := map[string]bool{}
+	for _, p := range pm.Partitions {
+		rebuilt[p.Topic] = true
+	}
+
+	var res []*regexp.Regexp
+	for _, g := range a.Groups {
+		for _, t := range g {
+			if !rebuilt[t] {
+				res = append(res, regexp.MustCompile(fmt.Sprintf("^%s$", regexp.QuoteMeta(t))))
+			}
+		}
+	}
+
+	a.Existing = kafkazk.NewPartitionMap()
+
+	if len(res) == 0 {
+		return nil
+	}
+
+	topics, err := zk.GetTopics(res)
+	if err != nil {
+		return err
+	}
+
+	for _, t := range topics {
+		tm, err := zk.GetPartitionMap(t)
+		if err != nil {
+			return err
+		}
+
+		a.Existing.Partitions = append(a.Existing.Partitions, tm.Partitions...)
+	}
+
+	return nil
+}
+
+// checkTopicAntiAffinity prints any brokers in the output PartitionMap pm2
+// holding replicas of topics that share an anti-affinity group, including
+// existing replicas retained by the rebuild. Violations already present in
+// the input PartitionMap pm1 are noted as pre-existing. With strict rules,
+// each violation introduced by the rebuild is returned as an error.
+func checkTopicAntiAffinity(a *kafkazk.TopicAntiAffinity, pm1, pm2 *kafkazk.PartitionMap) errors {
+	if a == nil {
+		return nil
+	}
+
+	mode := "strict"
+	if a.Soft {
+		mode = "soft"
+	}
+
+	fmt.Fprintf(textOut, "\nTopic anti-affinity (%s):\n", mode)
+
+	violations := a.Violations(pm2)
+	if len(violations) == 0 {
+		fmt.Fprintf(textOut, "%s[none]\n", indent)
+		return nil
+	}
+
+	// Conflicting topics held by
+	// each broker in the input map.
+	held := map[int]map[string]bool{}
+	for _, v := range a.Violations(pm1) {
+		held[v.Broker] = map[string]bool{}
+		for _, t := range v.Topics {
+			held[v.Broker][t] = true
+		}
+	}
+
+	var errs errors
+	for _, v := range violations {
+		msg := fmt.Sprintf("broker %d holds replicas of anti-affinity topics %s", v.Broker, strings.Join(v.Topics, ", "))
+
+		var introduced bool
+		for _, t := range v.Topics {
+			if !held[v.Broker][t] {
+				introduced = true
+			}
+		}
+
+		if !introduced {
+			fmt.Fprintf(textOut, "%s%s (pre-existing)\n", indent, msg)
+			continue
+		}
+
+		fmt.Fprintf(textOut, "%s%s\n", indent, msg)
+
+		if !a.Soft {
+			errs = append(errs, fmt.Errorf("%s", msg))
+		}
+	}
+
+	return errs
+}
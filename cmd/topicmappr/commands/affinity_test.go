@@ -0,0 +1,126 @@
+package commands
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/DataDog/kafka-kit/kafkazk"
+)
+
+func TestAffinityRulesFromBytes(t *testing.T) {
+	tests := map[int]struct {
+		data   string
+		groups [][]string
+		soft   bool
+		valid  bool
+	}{
+		0: {
+			data:   "anti-affinity:\n  - [orders, payments]\n  - [payments, ledger]\n",
+			groups: [][]string{{"orders", "payments"}, {"payments", "ledger"}},
+			valid:  true,
+		},
+		1: {
+			data:   `{"mode": "soft", "anti-affinity": [["orders", "payments"]]}`,
+			groups: [][]string{{"orders", "payments"}},
+			soft:   true,
+			valid:  true,
+		},
+		2: {data: "mode: strict\nanti-affinity: [[orders, payments]]\n", groups: [][]string{{"orders", "payments"}}, valid: true},
+		// Invalid mode.
+		3: {data: "mode: loose\nanti-affinity: [[orders, payments]]\n"},
+		// Unknown field.
+		4: {data: "affinity: [[orders, payments]]\n"},
+		// No groups.
+		5: {data: "mode: soft\n"},
+		// Too few topics.
+		6: {data: "anti-affinity: [[orders]]\n"},
+		7: {data: "anti-affinity: [[orders, orders]]\n"},
+	}
+
+	for i, test := range tests {
+		a, err := affinityRulesFromBytes([]byte(test.data))
+		if !test.valid {
+			if err == nil {
+				t.Errorf("[test %d] Expected error", i)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("[test %d] Unexpected error: %s", i, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(a.Groups, test.groups) {
+			t.Errorf("[test %d] Expected groups %v, got %v", i, test.groups, a.Groups)
+		}
+
+		if a.Soft != test.soft {
+			t.Errorf("[test %d] Expected soft=%v, got %v", i, test.soft, a.Soft)
+		}
+	}
+}
+
+func TestSetExistingTopics(t *testing.T) {
+	zk := &kafkazk.Mock{}
+
+	pm, _ := kafkazk.PartitionMapFromString(`{"version":1,"partitions":[
+		{"topic":"test_topic","partition":0,"replicas":[1001,1002]}]}`)
+
+	a := &kafkazk.TopicAntiAffinity{
+		Groups: [][]string{{"test_topic", "test_topic2", "no_such_topic"}},
+	}
+
+	if err := setExistingTopics(zk, a, pm); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only other topics that exist are fetched.
+	expected, _ := zk.GetPartitionMap("test_topic2")
+	if !reflect.DeepEqual(a.Existing.Partitions, expected.Partitions) {
+		t.Errorf("Expected existing partitions %v, got %v", expected.Partitions, a.Existing.Partitions)
+	}
+}
+
+func TestCheckTopicAntiAffinity(t *testing.T) {
+	existing, _ := kafkazk.PartitionMapFromString(`{"version":1,"partitions":[
+		{"topic":"orders","partition":0,"replicas":[1001,1002]}]}`)
+
+	pm1, _ := kafkazk.PartitionMapFromString(`{"version":1,"partitions":[
+		{"topic":"payments","partition":0,"replicas":[1003,1004]}]}`)
+
+	pm2, _ := kafkazk.PartitionMapFromString(`{"version":1,"partitions":[
+		{"topic":"payments","partition":0,"replicas":[1002,1003]}]}`)
+
+	a := &kafkazk.TopicAntiAffinity{
+		Groups:   [][]string{{"orders", "payments"}},
+		Existing: existing,
+	}
+
+	// Strict violations introduced by the rebuild are errors.
+	errs := checkTopicAntiAffinity(a, pm1, pm2)
+	if len(errs) != 1 {
+		t.Fatalf("Expected 1 error, got %v", errs)
+	}
+
+	expected := "broker 1002 holds replicas of anti-affinity topics orders, payments"
+	if errs[0].Error() != expected {
+		t.Errorf("Expected error '%s', got '%s'", expected, errs[0])
+	}
+
+	// Violations present in the input map aren't.
+	if errs := checkTopicAntiAffinity(a, pm2, pm2); len(errs) != 0 {
+		t.Errorf("Expected no errors, got %v", errs)
+	}
+
+	// Soft violations are only reported.
+	a.Soft = true
+	if errs := checkTopicAntiAffinity(a, pm1, pm2); len(errs) != 0 {
+		t.Errorf("Expected no errors, got %v", errs)
+	}
+
+	// No rules configured.
+	if errs := checkTopicAntiAffinity(nil, pm1, pm2); len(errs) != 0 {
+		t.Errorf("Expected no errors, got %v", errs)
+	}
+}
@@ -0,0 +1,369 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/DataDog/kafka-kit/kafkazk"
+
+	"github.com/spf13/cobra"
+)
+
+var autobalanceCmd = &cobra.Command{
+	Use:   "autobalance",
+	Short: "Continuously rebalance storage and leadership within safety limits",
+	Long: `autobalance runs as a daemon that evaluates the storage free range spread
+and preferred leader count range spread of brokers holding --topics every --interval.
+If either exceeds its threshold, a rebalance of at most --max-moves partition changes
+is planned. Plans are printed and, if --apply is set, written as a partition
+reassignment. Only one autobalance instance acts at a time via a lock held in
+ZooKeeper, and no plan is made while a reassignment is in progress. autobalance may
+be stopped at any time with SIGINT or SIGTERM; the lock is released on exit.`,
+	Run: autobalance,
+}
+
+func init() {
+	rootCmd.AddCommand(autobalanceCmd)
+
+	autobalanceCmd.Flags().String("topics", "", "Balance topics (comma delim. list) by lookup in ZooKeeper")
+	autobalanceCmd.Flags().Duration("interval", 10*time.Minute, "Interval between balance evaluations")
+	autobalanceCmd.Flags().Float64("storage-threshold", 20.00, "Broker storage free range spread (percent) above which storage is rebalanced")
+	autobalanceCmd.Flags().Float64("leader-threshold", 20.00, "Broker preferred leader count range spread (percent) above which leadership is rebalanced")
+	autobalanceCmd.Flags().Int("max-moves", 5, "Maximum number of partition changes per interval")
+	autobalanceCmd.Flags().Bool("apply", false, "Write planned changes as a partition reassignment (otherwise plans are only printed)")
+	autobalanceCmd.Flags().String("zk-metrics-prefix", "topicmappr", "ZooKeeper namespace prefix for Kafka metrics and the autobalance lock")
+	autobalanceCmd.Flags().Int("metrics-age", 60, "Kafka metrics age tolerance (in minutes)")
+
+	// Required.
+	autobalanceCmd.MarkFlagRequired("topics")
+}
+
+// Autobalance step outcomes.
+const (
+	autobalanceNoLock      = "lock held by another instance"
+	autobalanceReassigning = "reassignment in progress"
+	autobalanceBalanced    = "within thresholds"
+	autobalanceNoMoves     = "no moves available"
+	autobalancePlanned     = "planned"
+	autobalanceApplied     = "applied"
+)
+
+// autobalanceLockName is the lock znode name
+// under the --zk-metrics-prefix.
+const autobalanceLockName = "autobalance_lock"
+
+// autobalanceCluster provides cluster state to, and
+// applies reassignments for, the autobalancer.
+type autobalanceCluster interface {
+	// state returns the current PartitionMap, a BrokerMap
+	// of brokers holding it and a PartitionMetaMap.
+	state() (*kafkazk.PartitionMap, kafkazk.BrokerMap, kafkazk.PartitionMetaMap, error)
+	// reassigning returns whether a
+	// reassignment is in progress.
+	reassigning() (bool, error)
+	// reassign writes a partition reassignment.
+	reassign(*kafkazk.PartitionMap) error
+}
+
+// autobalanceLock is a distributed lock ensuring only
+// one autobalancer acts on a cluster at a time.
+type autobalanceLock interface {
+	// acquire acquires or refreshes the lock,
+	// returning whether it's held.
+	acquire() (bool, error)
+	release() error
+}
+
+// autobalanceConfig holds autobalancer settings.
+type autobalanceConfig struct {
+	interval         time.Duration
+	storageThreshold float64
+	leaderThreshold  float64
+	maxMoves         int
+	apply            bool
+}
+
+// autobalanceResult describes the outcome of an autobalance step.
+type autobalanceResult struct {
+	outcome       string
+	storageSpread float64
+	leaderSpread  float64
+	// The partitions changed and
+	// their original assignments.
+	orig    *kafkazk.PartitionMap
+	changes *kafkazk.PartitionMap
+}
+
+// autobalancer runs the autobalance decision loop.
+type autobalancer struct {
+	cluster autobalanceCluster
+	lock    autobalanceLock
+	config  autobalanceConfig
+	log     *log.Logger
+}
+
+// run calls step every interval until the context is done, at which point
+// the lock is released. Steps are never interrupted; stopping takes effect
+// between steps, leaving at most a single completed reassignment write.
+func (a *autobalancer) run(ctx context.Context) {
+	defer func() {
+		if err := a.lock.release(); err != nil {
+			a.log.Printf("error releasing lock: %s\n", err)
+		}
+	}()
+
+	ticker := time.NewTicker(a.config.interval)
+	defer ticker.Stop()
+
+	for {
+		// Stopping takes precedence over
+		// a concurrently elapsed interval.
+		if ctx.Err() != nil {
+			return
+		}
+
+		r, err := a.step()
+		if err != nil {
+			a.log.Printf("error: %s\n", err)
+		} else {
+			a.report(r)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// step performs a single autobalance evaluation. If the lock is held, no
+// reassignment is in progress and either spread is above its threshold, a
+// bounded rebalance is planned and optionally applied.
+func (a *autobalancer) step() (*autobalanceResult, error) {
+	held, err := a.lock.acquire()
+	if err != nil {
+		return nil, err
+	}
+
+	if !held {
+		return &autobalanceResult{outcome: autobalanceNoLock}, nil
+	}
+
+	busy, err := a.cluster.reassigning()
+	if err != nil {
+		return nil, err
+	}
+
+	if busy {
+		return &autobalanceResult{outcome: autobalanceReassigning}, nil
+	}
+
+	pm, bm, pmm, err := a.cluster.state()
+	if err != nil {
+		return nil, err
+	}
+
+	r := &autobalanceResult{
+		storageSpread: bm.StorageRangeSpread(),
+		leaderSpread:  leaderRangeSpread(pm, bm),
+	}
+
+	storage := r.storageSpread > a.config.storageThreshold
+	leaders := r.leaderSpread > a.config.leaderThreshold
+
+	if !storage && !leaders {
+		r.outcome = autobalanceBalanced
+		return r, nil
+	}
+
+	p := newBalancePlan(pm, bm, pmm)
+
+	if storage {
+		if err := p.planStorage(a.config.maxMoves, a.config.storageThreshold); err != nil {
+			return nil, err
+		}
+	}
+
+	if leaders {
+		p.planLeaders(a.config.maxMoves, a.config.leaderThreshold)
+	}
+
+	r.orig, r.changes = skipReassignmentNoOps(pm, p.pm)
+
+	if len(r.changes.Partitions) == 0 {
+		r.outcome = autobalanceNoMoves
+		return r, nil
+	}
+
+	r.outcome = autobalancePlanned
+
+	if a.config.apply {
+		if err := a.cluster.reassign(r.changes); err != nil {
+			return nil, err
+		}
+		r.outcome = autobalanceApplied
+	}
+
+	return r, nil
+}
+
+// report logs an autobalanceResult.
+func (a *autobalancer) report(r *autobalanceResult) {
+	switch r.outcome {
+	case autobalanceNoLock, autobalanceReassigning:
+		a.log.Printf("skipped: %s\n", r.outcome)
+		return
+	}
+
+	a.log.Printf("storage range spread: %.2f%%, leader range spread: %.2f%%: %s\n",
+		r.storageSpread, r.leaderSpread, r.outcome)
+
+	if r.changes == nil {
+		return
+	}
+
+	for i, p := range r.changes.Partitions {
+		o := r.orig.Partitions[i]
+		a.log.Printf("%s%s p%d: %v -> %v %s\n", indent, p.Topic, p.Partition,
+			o.Replicas, p.Replicas, whatChanged(o.Replicas, p.Replicas))
+	}
+}
+
+func autobalance(cmd *cobra.Command, _ []string) {
+	var c autobalanceConfig
+	c.interval, _ = cmd.Flags().GetDuration("interval")
+	c.storageThreshold, _ = cmd.Flags().GetFloat64("storage-threshold")
+	c.leaderThreshold, _ = cmd.Flags().GetFloat64("leader-threshold")
+	c.maxMoves, _ = cmd.Flags().GetInt("max-moves")
+	c.apply, _ = cmd.Flags().GetBool("apply")
+
+	switch {
+	case c.interval <= 0:
+		fmt.Fprintln(textOut, "\n[ERROR] --interval must be greater than 0")
+		defaultsAndExit()
+	case c.storageThreshold < 0 || c.leaderThreshold < 0:
+		fmt.Fprintln(textOut, "\n[ERROR] --storage-threshold and --leader-threshold must be 0 or greater")
+		defaultsAndExit()
+	case c.maxMoves < 1:
+		fmt.Fprintln(textOut, "\n[ERROR] --max-moves must be greater than 0")
+		defaultsAndExit()
+	}
+
+	bootstrap(cmd)
+
+	// ZooKeeper init.
+	zk, err := initZooKeeper(cmd)
+	if err != nil {
+		fmt.Fprintln(textOut, err)
+		exit(1)
+	}
+
+	defer zk.Close()
+
+	// initZooKeeper discards the default
+	// logger output; use a dedicated logger.
+	logger := log.New(os.Stdout, "", log.LstdFlags)
+
+	// Already validated by initZooKeeper.
+	prefix, _ := zkPrefix(cmd)
+	mp := cmd.Flag("zk-metrics-prefix").Value.String()
+	age, _ := cmd.Flags().GetInt("metrics-age")
+
+	a := &autobalancer{
+		cluster: &zkAutobalanceCluster{
+			zk:         zk,
+			prefix:     prefix,
+			metricsAge: time.Duration(age) * time.Minute,
+		},
+		lock: &zkLock{
+			zk:    zk,
+			path:  fmt.Sprintf("/%s/%s", mp, autobalanceLockName),
+			owner: lockOwner(),
+			// A lease unrefreshed for two intervals
+			// indicates a stalled instance.
+			ttl: 2 * c.interval,
+		},
+		config: c,
+		log:    logger,
+	}
+
+	// Stop on signal.
+	ctx, cancel := context.WithCancel(context.Background())
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		<-sigs
+		logger.Println("stopping")
+		cancel()
+	}()
+
+	logger.Printf("autobalance running (interval: %s, max moves: %d, apply: %t)\n",
+		c.interval, c.maxMoves, c.apply)
+
+	a.run(ctx)
+}
+
+// zkAutobalanceCluster is an autobalanceCluster backed by ZooKeeper.
+type zkAutobalanceCluster struct {
+	zk         kafkazk.Handler
+	prefix     string
+	metricsAge time.Duration
+}
+
+func (z *zkAutobalanceCluster) state() (*kafkazk.PartitionMap, kafkazk.BrokerMap, kafkazk.PartitionMetaMap, error) {
+	age, err := z.zk.MaxMetaAge()
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("Error fetching metrics metadata: %s", err)
+	}
+
+	if age > z.metricsAge {
+		return nil, nil, nil, fmt.Errorf("Metrics metadata is older than allowed: %s", age)
+	}
+
+	brokerMeta, errs := z.zk.GetAllBrokerMeta(true)
+	if errs != nil && brokerMeta == nil {
+		return nil, nil, nil, errs[0]
+	}
+
+	partitionMeta, err := z.zk.GetAllPartitionMeta()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	pm, err := kafkazk.PartitionMapFromZK(Config.topics, z.zk)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	bm := kafkazk.BrokerMapFromPartitionMap(pm, brokerMeta, false)
+
+	// Brokers without metrics can't be balanced by storage.
+	for id, b := range bm {
+		if id != 0 && b.StorageFree == 0 {
+			return nil, nil, nil, fmt.Errorf("Broker %d is missing metrics metadata", id)
+		}
+	}
+
+	return pm, bm, partitionMeta, nil
+}
+
+func (z *zkAutobalanceCluster) reassigning() (bool, error) {
+	return len(z.zk.GetReassignments()) > 0, nil
+}
+
+func (z *zkAutobalanceCluster) reassign(pm *kafkazk.PartitionMap) error {
+	data, err := json.Marshal(pm)
+	if err != nil {
+		return err
+	}
+
+	return z.zk.Create(kafkazk.ZKPath(z.prefix, "/admin/reassign_partitions"), string(data))
+}
@@ -0,0 +1,271 @@
+package commands
+
+import (
+	"sort"
+
+	"github.com/DataDog/kafka-kit/kafkazk"
+)
+
+// balancePlan is a working copy of a PartitionMap and BrokerMap
+// to which bounded storage and leadership moves are applied.
+type balancePlan struct {
+	pm  *kafkazk.PartitionMap
+	bm  kafkazk.BrokerMap
+	pmm kafkazk.PartitionMetaMap
+	// Number of moves planned.
+	moves int
+	// Partitions already changed, indexed by
+	// topic and partition; each partition is
+	// changed at most once per plan.
+	moved map[string]map[int]bool
+}
+
+// newBalancePlan returns a *balancePlan operating on
+// copies of the provided PartitionMap and BrokerMap.
+func newBalancePlan(pm *kafkazk.PartitionMap, bm kafkazk.BrokerMap, pmm kafkazk.PartitionMetaMap) *balancePlan {
+	return &balancePlan{
+		pm:    pm.Copy(),
+		bm:    bm.Copy(),
+		pmm:   pmm,
+		moved: map[string]map[int]bool{},
+	}
+}
+
+func (p *balancePlan) isMoved(partn kafkazk.Partition) bool {
+	return p.moved[partn.Topic][partn.Partition]
+}
+
+func (p *balancePlan) setMoved(partn kafkazk.Partition) {
+	if p.moved[partn.Topic] == nil {
+		p.moved[partn.Topic] = map[int]bool{}
+	}
+	p.moved[partn.Topic][partn.Partition] = true
+	p.moves++
+}
+
+// brokerIDs returns the BrokerMap IDs, excluding
+// the stub broker, sorted by the less func with
+// ties broken by ID.
+func (p *balancePlan) brokerIDs(less func(a, b *kafkazk.Broker) bool) []int {
+	var ids []int
+	for id := range p.bm {
+		if id != 0 {
+			ids = append(ids, id)
+		}
+	}
+
+	sort.Slice(ids, func(i, j int) bool {
+		a, b := p.bm[ids[i]], p.bm[ids[j]]
+		if less(a, b) {
+			return true
+		}
+		if less(b, a) {
+			return false
+		}
+		return a.ID < b.ID
+	})
+
+	return ids
+}
+
+// planStorage relocates replicas from the broker with the least storage
+// free to those with the most until the storage free range spread is at or
+// below the threshold, the plan holds limit moves or no relocation is
+// possible. A relocation must not reduce rack diversity and must not leave
+// the destination with less storage free than the source.
+func (p *balancePlan) planStorage(limit int, threshold float64) error {
+	for p.moves < limit && p.bm.StorageRangeSpread() > threshold {
+		byFree := p.brokerIDs(func(a, b *kafkazk.Broker) bool {
+			return a.StorageFree < b.StorageFree
+		})
+
+		if len(byFree) < 2 {
+			return nil
+		}
+
+		src := p.bm[byFree[0]]
+
+		var planned bool
+		// Destinations in descending storage free.
+		for i := len(byFree) - 1; i > 0 && !planned; i-- {
+			dst := p.bm[byFree[i]]
+			// Limit moves to those that don't leave the
+			// destination with less free than the source.
+			maxSize := (dst.StorageFree - src.StorageFree) / 2
+
+			idx, pos, size, err := p.largestRelocatable(src, dst, maxSize)
+			if err != nil {
+				return err
+			}
+
+			if idx < 0 {
+				continue
+			}
+
+			partn := p.pm.Partitions[idx]
+			partn.Replicas[pos] = dst.ID
+			src.StorageFree += size
+			dst.StorageFree -= size
+			p.setMoved(partn)
+			planned = true
+		}
+
+		if !planned {
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// largestRelocatable returns the index of the largest partition held by src
+// that's no larger than limit and can be relocated to dst, along with the
+// replica set position of src and the partition size. An index of -1 is
+// returned if no partition is relocatable.
+func (p *balancePlan) largestRelocatable(src, dst *kafkazk.Broker, limit float64) (int, int, float64, error) {
+	idx, pos, best := -1, -1, 0.00
+
+	for i, partn := range p.pm.Partitions {
+		if p.isMoved(partn) {
+			continue
+		}
+
+		srcPos := -1
+		eligible := true
+
+		for n, id := range partn.Replicas {
+			switch {
+			case id == src.ID:
+				srcPos = n
+			case id == dst.ID:
+				eligible = false
+			case dst.Locality != "" && p.bm[id] != nil && p.bm[id].Locality == dst.Locality:
+				eligible = false
+			}
+		}
+
+		if srcPos < 0 || !eligible {
+			continue
+		}
+
+		size, err := p.pmm.Size(partn)
+		if err != nil {
+			return -1, -1, 0, err
+		}
+
+		if size <= limit && size > best {
+			idx, pos, best = i, srcPos, size
+		}
+	}
+
+	return idx, pos, best, nil
+}
+
+// planLeaders moves preferred leadership from the broker leading the most
+// partitions to followers leading the fewest until the leader range spread is
+// at or below the threshold, the plan holds limit moves or no move is possible.
+func (p *balancePlan) planLeaders(limit int, threshold float64) {
+	for p.moves < limit && leaderRangeSpread(p.pm, p.bm) > threshold {
+		counts := leaderCounts(p.pm, p.bm)
+
+		byCount := p.brokerIDs(func(a, b *kafkazk.Broker) bool {
+			return counts[a.ID] < counts[b.ID]
+		})
+
+		if len(byCount) < 2 {
+			return
+		}
+
+		src := byCount[len(byCount)-1]
+
+		var planned bool
+		// Destinations in ascending leader count.
+		for _, dst := range byCount[:len(byCount)-1] {
+			// A move must reduce the range.
+			if counts[src]-counts[dst] < 2 {
+				break
+			}
+
+			for _, partn := range p.pm.Partitions {
+				if p.isMoved(partn) || len(partn.Replicas) == 0 || partn.Replicas[0] != src {
+					continue
+				}
+
+				if n := replicaPosition(partn.Replicas, dst); n > 0 {
+					partn.Replicas[0], partn.Replicas[n] = partn.Replicas[n], partn.Replicas[0]
+					p.setMoved(partn)
+					planned = true
+					break
+				}
+			}
+
+			if planned {
+				break
+			}
+		}
+
+		if !planned {
+			return
+		}
+	}
+}
+
+// replicaPosition returns the position of
+// id in replicas or -1 if not present.
+func replicaPosition(replicas []int, id int) int {
+	for n, r := range replicas {
+		if r == id {
+			return n
+		}
+	}
+
+	return -1
+}
+
+// leaderCounts returns the number of partitions in the PartitionMap
+// led by each broker in the BrokerMap, excluding the stub broker.
+func leaderCounts(pm *kafkazk.PartitionMap, bm kafkazk.BrokerMap) map[int]int {
+	counts := map[int]int{}
+	for id := range bm {
+		if id != 0 {
+			counts[id] = 0
+		}
+	}
+
+	for _, partn := range pm.Partitions {
+		if len(partn.Replicas) == 0 {
+			continue
+		}
+		if _, exists := counts[partn.Replicas[0]]; exists {
+			counts[partn.Replicas[0]]++
+		}
+	}
+
+	return counts
+}
+
+// leaderRangeSpread returns the range spread of preferred leader
+// counts among brokers in the BrokerMap as a percentage of the
+// highest count.
+func leaderRangeSpread(pm *kafkazk.PartitionMap, bm kafkazk.BrokerMap) float64 {
+	counts := leaderCounts(pm, bm)
+	if len(counts) == 0 {
+		return 0
+	}
+
+	min, max := -1, 0
+	for _, c := range counts {
+		if c > max {
+			max = c
+		}
+		if min < 0 || c < min {
+			min = c
+		}
+	}
+
+	if max == 0 {
+		return 0
+	}
+
+	return float64(max-min) / float64(max) * 100
+}
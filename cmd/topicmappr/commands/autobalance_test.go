@@ -0,0 +1,330 @@
+package commands
+
+import (
+	"context"
+	"io/ioutil"
+	"log"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/DataDog/kafka-kit/kafkazk"
+)
+
+// testAutobalanceCluster is an autobalanceCluster.
+type testAutobalanceCluster struct {
+	pm    *kafkazk.PartitionMap
+	bm    kafkazk.BrokerMap
+	pmm   kafkazk.PartitionMetaMap
+	busy  bool
+	calls int
+	// Reassignments written.
+	reassigned []*kafkazk.PartitionMap
+	// Called on each state call.
+	onState func()
+}
+
+func (c *testAutobalanceCluster) state() (*kafkazk.PartitionMap, kafkazk.BrokerMap, kafkazk.PartitionMetaMap, error) {
+	c.calls++
+	if c.onState != nil {
+		c.onState()
+	}
+	return c.pm.Copy(), c.bm.Copy(), c.pmm, nil
+}
+
+func (c *testAutobalanceCluster) reassigning() (bool, error) { return c.busy, nil }
+
+func (c *testAutobalanceCluster) reassign(pm *kafkazk.PartitionMap) error {
+	c.reassigned = append(c.reassigned, pm)
+	return nil
+}
+
+// testAutobalanceLock is an autobalanceLock.
+type testAutobalanceLock struct {
+	unavailable bool
+	held        bool
+	released    bool
+}
+
+func (l *testAutobalanceLock) acquire() (bool, error) {
+	l.held = !l.unavailable
+	return l.held, nil
+}
+
+func (l *testAutobalanceLock) release() error {
+	l.released = true
+	l.held = false
+	return nil
+}
+
+// testGetAutobalanceCluster returns a cluster where broker 1001 has the
+// least storage free and leads the most partitions.
+func testGetAutobalanceCluster() *testAutobalanceCluster {
+	pm, _ := kafkazk.PartitionMapFromString(`{"version":1,"partitions":[
+    {"topic":"test_topic","partition":0,"replicas":[1001,1002]},
+    {"topic":"test_topic","partition":1,"replicas":[1001,1003]},
+    {"topic":"test_topic","partition":2,"replicas":[1001,1004]},
+    {"topic":"test_topic","partition":3,"replicas":[1001,1002]},
+    {"topic":"test_topic","partition":4,"replicas":[1002,1001]},
+    {"topic":"test_topic","partition":5,"replicas":[1003,1001]},
+    {"topic":"test_topic","partition":6,"replicas":[1004,1001]},
+    {"topic":"test_topic","partition":7,"replicas":[1002,1003]}]}`)
+
+	bm := kafkazk.BrokerMapFromPartitionMap(pm, nil, false)
+	bm[1001].StorageFree = 100.00
+	bm[1002].StorageFree = 400.00
+	bm[1003].StorageFree = 400.00
+	bm[1004].StorageFree = 400.00
+
+	pmm := kafkazk.NewPartitionMetaMap()
+	pmm["test_topic"] = map[int]*kafkazk.PartitionMeta{}
+	for i := 0; i < 8; i++ {
+		pmm["test_topic"][i] = &kafkazk.PartitionMeta{Size: 20.00}
+	}
+
+	return &testAutobalanceCluster{pm: pm, bm: bm, pmm: pmm}
+}
+
+func testGetAutobalancer(c autobalanceCluster, l autobalanceLock, conf autobalanceConfig) *autobalancer {
+	return &autobalancer{
+		cluster: c,
+		lock:    l,
+		config:  conf,
+		log:     log.New(ioutil.Discard, "", 0),
+	}
+}
+
+// sameBrokers returns whether two replica
+// sets contain the same brokers.
+func sameBrokers(a, b []int) bool {
+	a2, b2 := append([]int{}, a...), append([]int{}, b...)
+	sort.Ints(a2)
+	sort.Ints(b2)
+
+	if len(a2) != len(b2) {
+		return false
+	}
+
+	for i := range a2 {
+		if a2[i] != b2[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func TestAutobalanceStepCaps(t *testing.T) {
+	for _, max := range []int{1, 3, 5} {
+		c := testGetAutobalanceCluster()
+		a := testGetAutobalancer(c, &testAutobalanceLock{}, autobalanceConfig{
+			storageThreshold: 20.00,
+			leaderThreshold:  20.00,
+			maxMoves:         max,
+		})
+
+		r, err := a.step()
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		if r.outcome != autobalancePlanned {
+			t.Errorf("Expected outcome '%s', got '%s'", autobalancePlanned, r.outcome)
+		}
+
+		if n := len(r.changes.Partitions); n == 0 || n > max {
+			t.Errorf("Expected between 1 and %d changes, got %d", max, n)
+		}
+	}
+}
+
+func TestAutobalanceStepStorage(t *testing.T) {
+	c := testGetAutobalanceCluster()
+	a := testGetAutobalancer(c, &testAutobalanceLock{}, autobalanceConfig{
+		storageThreshold: 20.00,
+		// Disable leadership balancing.
+		leaderThreshold: 100.00,
+		maxMoves:        3,
+	})
+
+	r, _ := a.step()
+
+	if len(r.changes.Partitions) != 3 {
+		t.Fatalf("Expected 3 changes, got %d", len(r.changes.Partitions))
+	}
+
+	// Each change relocates a replica
+	// off of 1001.
+	for _, p := range r.changes.Partitions {
+		if replicaPosition(p.Replicas, 1001) >= 0 {
+			t.Errorf("Expected 1001 removed from %s p%d, got %v", p.Topic, p.Partition, p.Replicas)
+		}
+	}
+
+	// No reassignment without --apply.
+	if len(c.reassigned) != 0 {
+		t.Errorf("Expected no reassignments, got %d", len(c.reassigned))
+	}
+}
+
+func TestAutobalanceStepLeaders(t *testing.T) {
+	c := testGetAutobalanceCluster()
+	a := testGetAutobalancer(c, &testAutobalanceLock{}, autobalanceConfig{
+		// Disable storage balancing.
+		storageThreshold: 1000.00,
+		leaderThreshold:  20.00,
+		maxMoves:         5,
+	})
+
+	r, _ := a.step()
+
+	if len(r.changes.Partitions) == 0 {
+		t.Fatal("Expected leadership changes")
+	}
+
+	// Leadership moves don't change replica sets.
+	for i, p := range r.changes.Partitions {
+		if !sameBrokers(p.Replicas, r.orig.Partitions[i].Replicas) {
+			t.Errorf("Expected same brokers for %s p%d, got %v -> %v",
+				p.Topic, p.Partition, r.orig.Partitions[i].Replicas, p.Replicas)
+		}
+	}
+
+	// Leader counts go from 4/2/1/1 to 2/2/2/2.
+	pm := c.pm.Copy()
+	for _, p := range r.changes.Partitions {
+		pm.Partitions[p.Partition] = p
+	}
+
+	if s := leaderRangeSpread(pm, c.bm); s != 0 {
+		t.Errorf("Expected leader range spread 0.00, got %.2f", s)
+	}
+}
+
+func TestAutobalanceStepThresholds(t *testing.T) {
+	c := testGetAutobalanceCluster()
+	a := testGetAutobalancer(c, &testAutobalanceLock{}, autobalanceConfig{
+		storageThreshold: 1000.00,
+		leaderThreshold:  100.00,
+		maxMoves:         5,
+		apply:            true,
+	})
+
+	r, _ := a.step()
+
+	if r.outcome != autobalanceBalanced {
+		t.Errorf("Expected outcome '%s', got '%s'", autobalanceBalanced, r.outcome)
+	}
+
+	if r.changes != nil || len(c.reassigned) != 0 {
+		t.Error("Expected no changes")
+	}
+
+	// Storage range spread is 300%.
+	if r.storageSpread != 300.00 {
+		t.Errorf("Expected storage range spread 300.00, got %.2f", r.storageSpread)
+	}
+
+	// Leader range spread is 75%.
+	if r.leaderSpread != 75.00 {
+		t.Errorf("Expected leader range spread 75.00, got %.2f", r.leaderSpread)
+	}
+}
+
+func TestAutobalanceStepSkips(t *testing.T) {
+	conf := autobalanceConfig{storageThreshold: 20.00, leaderThreshold: 20.00, maxMoves: 5, apply: true}
+
+	// Lock held elsewhere.
+	c := testGetAutobalanceCluster()
+	a := testGetAutobalancer(c, &testAutobalanceLock{unavailable: true}, conf)
+
+	r, _ := a.step()
+	if r.outcome != autobalanceNoLock {
+		t.Errorf("Expected outcome '%s', got '%s'", autobalanceNoLock, r.outcome)
+	}
+
+	if c.calls != 0 || len(c.reassigned) != 0 {
+		t.Error("Expected no state fetch or reassignment without the lock")
+	}
+
+	// Reassignment in progress.
+	c = testGetAutobalanceCluster()
+	c.busy = true
+	a = testGetAutobalancer(c, &testAutobalanceLock{}, conf)
+
+	r, _ = a.step()
+	if r.outcome != autobalanceReassigning {
+		t.Errorf("Expected outcome '%s', got '%s'", autobalanceReassigning, r.outcome)
+	}
+
+	if len(c.reassigned) != 0 {
+		t.Error("Expected no reassignment while reassigning")
+	}
+}
+
+func TestAutobalanceStepApply(t *testing.T) {
+	c := testGetAutobalanceCluster()
+	a := testGetAutobalancer(c, &testAutobalanceLock{}, autobalanceConfig{
+		storageThreshold: 20.00,
+		leaderThreshold:  20.00,
+		maxMoves:         2,
+		apply:            true,
+	})
+
+	r, _ := a.step()
+
+	if r.outcome != autobalanceApplied {
+		t.Errorf("Expected outcome '%s', got '%s'", autobalanceApplied, r.outcome)
+	}
+
+	if len(c.reassigned) != 1 {
+		t.Fatalf("Expected 1 reassignment, got %d", len(c.reassigned))
+	}
+
+	if n := len(c.reassigned[0].Partitions); n != 2 {
+		t.Errorf("Expected 2 partitions reassigned, got %d", n)
+	}
+}
+
+func TestAutobalanceRun(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	c := testGetAutobalanceCluster()
+	l := &testAutobalanceLock{}
+
+	// Stop after the third step.
+	c.onState = func() {
+		if c.calls == 3 {
+			cancel()
+		}
+	}
+
+	a := testGetAutobalancer(c, l, autobalanceConfig{
+		interval:         time.Millisecond,
+		storageThreshold: 20.00,
+		leaderThreshold:  20.00,
+		maxMoves:         1,
+		apply:            true,
+	})
+
+	a.run(ctx)
+
+	if c.calls != 3 {
+		t.Errorf("Expected 3 steps, got %d", c.calls)
+	}
+
+	// The test cluster state never changes, so
+	// each step writes a single move.
+	if len(c.reassigned) != 3 {
+		t.Errorf("Expected 3 reassignments, got %d", len(c.reassigned))
+	}
+
+	for _, pm := range c.reassigned {
+		if len(pm.Partitions) != 1 {
+			t.Errorf("Expected 1 partition reassigned, got %d", len(pm.Partitions))
+		}
+	}
+
+	if !l.released || l.held {
+		t.Error("Expected lock released")
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"log"
 	"os"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -32,13 +33,24 @@ var (
 )
 
 func bootstrap(cmd *cobra.Command) {
-	b, _ := cmd.Flags().GetString("brokers")
-	Config.brokers = brokerStringToSlice(b)
+	if b, _ := cmd.Flags().GetString("brokers"); b != "" {
+		Config.brokers = brokerStringToSlice(b)
+	}
 
 	// Append trailing slash if not included.
-	op := cmd.Flag("out-path").Value.String()
-	if op != "" && !strings.HasSuffix(op, "/") {
-		cmd.Flags().Set("out-path", op+"/")
+	// Not all commands write output.
+	if f := cmd.Flag("out-path"); f != nil {
+		if op := f.Value.String(); op != "" && !strings.HasSuffix(op, "/") {
+			cmd.Flags().Set("out-path", op+"/")
+		}
+	}
+
+	// Validate the output map format.
+	if f := cmd.Flag("output-format"); f != nil {
+		if of := f.Value.String(); of != "json" && of != "yaml" {
+			fmt.Fprintln(textOut, "\n[ERROR] --output-format must be either 'json' or 'yaml'")
+			defaultsAndExit()
+		}
 	}
 
 	// Determine if regexp was provided in the topic
@@ -55,8 +67,8 @@ func bootstrap(cmd *cobra.Command) {
 		for _, t := range topicNames {
 			r, err := regexp.Compile(t)
 			if err != nil {
-				fmt.Printf("Invalid topic regex: %s\n", t)
-				os.Exit(1)
+				fmt.Fprintf(textOut, "Invalid topic regex: %s\n", t)
+				exit(1)
 			}
 
 			Config.topics = append(Config.topics, r)
@@ -79,10 +91,21 @@ func initZooKeeper(cmd *cobra.Command) (kafkazk.Handler, error) {
 	zkAddr := cmd.Parent().Flag("zk-addr").Value.String()
 	timeout := 250 * time.Millisecond
 
+	prefix, err := zkPrefix(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	// Not all commands reference metrics.
+	var metricsPrefix string
+	if f := cmd.Flag("zk-metrics-prefix"); f != nil {
+		metricsPrefix = f.Value.String()
+	}
+
 	zk, err := kafkazk.NewHandler(&kafkazk.Config{
 		Connect:       zkAddr,
-		Prefix:        cmd.Parent().Flag("zk-prefix").Value.String(),
-		MetricsPrefix: cmd.Flag("zk-metrics-prefix").Value.String(),
+		Prefix:        prefix,
+		MetricsPrefix: metricsPrefix,
 	})
 
 	if err != nil {
@@ -93,12 +116,72 @@ func initZooKeeper(cmd *cobra.Command) (kafkazk.Handler, error) {
 
 	if !zk.Ready() {
 		return nil, fmt.Errorf("Failed to connect to ZooKeeper %s within %s", zkAddr, timeout)
-		os.Exit(1)
 	}
 
 	return zk, nil
 }
 
+// zkPrefix returns the ZooKeeper prefix resolved
+// from the --zk-prefix and --cluster flags.
+func zkPrefix(cmd *cobra.Command) (string, error) {
+	return resolveZKPrefix(
+		cmd.Parent().Flag("zk-prefix").Value.String(),
+		cmd.Parent().Flag("cluster").Value.String(),
+	)
+}
+
+// resolveZKPrefix takes a --zk-prefix value and --cluster name and returns
+// the ZooKeeper prefix. The --zk-prefix value is either a single prefix or
+// a comma delimited list of cluster=prefix mappings (e.g.
+// "east=kafka-east,west=kafka-west"), in which case the cluster name must
+// be specified and mapped. An empty mapped prefix refers to the root.
+func resolveZKPrefix(v, cluster string) (string, error) {
+	if !strings.Contains(v, "=") {
+		if cluster != "" {
+			return "", fmt.Errorf("--cluster requires --zk-prefix cluster=prefix mappings")
+		}
+		return v, nil
+	}
+
+	prefixes := map[string]string{}
+	for _, m := range strings.Split(v, ",") {
+		kv := strings.SplitN(strings.TrimSpace(m), "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return "", fmt.Errorf("Invalid --zk-prefix mapping '%s'", m)
+		}
+
+		if _, exists := prefixes[kv[0]]; exists {
+			return "", fmt.Errorf("Duplicate --zk-prefix cluster '%s'", kv[0])
+		}
+
+		prefixes[kv[0]] = kv[1]
+	}
+
+	if cluster == "" {
+		return "", fmt.Errorf("--cluster must be specified with --zk-prefix cluster=prefix mappings")
+	}
+
+	p, exists := prefixes[cluster]
+	if !exists {
+		var known []string
+		for c := range prefixes {
+			known = append(known, c)
+		}
+		sort.Strings(known)
+
+		return "", fmt.Errorf("Unknown cluster '%s'; --zk-prefix maps: %s", cluster, strings.Join(known, ", "))
+	}
+
+	return p, nil
+}
+
+// isPositiveFloat returns whether
+// s parses as a float greater than 0.
+func isPositiveFloat(s string) bool {
+	f, err := strconv.ParseFloat(s, 64)
+	return err == nil && f > 0
+}
+
 // containsRegex takes a topic name
 // reference and returns whether or not
 // it should be interpreted as regex.
@@ -129,12 +212,12 @@ func brokerStringToSlice(s string) []int {
 		i, err := strconv.Atoi(strings.TrimSpace(p))
 		// Err and exit on bad input.
 		if err != nil {
-			fmt.Println(err)
-			os.Exit(1)
+			fmt.Fprintln(textOut, err)
+			exit(1)
 		}
 
 		if ids[i] {
-			fmt.Printf("ID %d supplied as duplicate, excluding\n", i)
+			fmt.Fprintf(textOut, "ID %d supplied as duplicate, excluding\n", i)
 			info++
 			continue
 		}
@@ -145,13 +228,36 @@ func brokerStringToSlice(s string) []int {
 
 	// Formatting purposes.
 	if info > 0 {
-		fmt.Println()
+		fmt.Fprintln(textOut)
 	}
 
 	return is
 }
 
 func defaultsAndExit() {
-	fmt.Println()
-	os.Exit(1)
+	fmt.Fprintln(textOut)
+	exit(1)
+}
+
+// exitHooks are run by exit ahead of the process exiting.
+var exitHooks []func()
+
+// onExit registers f to be run by exit. Hooks
+// are run in the reverse order of registration.
+func onExit(f func()) {
+	exitHooks = append(exitHooks, f)
+}
+
+// exit runs any registered exit hooks, such as lock releases, and writes
+// the JSON output document if it's enabled and unwritten, then exits with
+// the provided status code. Commands exit through exit rather than
+// os.Exit, which doesn't run deferred calls.
+func exit(code int) {
+	for i := len(exitHooks) - 1; i >= 0; i-- {
+		exitHooks[i]()
+	}
+
+	writeJSONOnExit(code)
+
+	os.Exit(code)
 }
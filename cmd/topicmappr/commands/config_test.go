@@ -0,0 +1,55 @@
+package commands
+
+import (
+	"testing"
+)
+
+func TestResolveZKPrefix(t *testing.T) {
+	mappings := "east=kafka-east, west=clusters/west,root="
+
+	type testCase struct {
+		prefix  string
+		cluster string
+	}
+
+	tests := map[testCase]string{
+		// Single prefixes.
+		{"", ""}:      "",
+		{"kafka", ""}: "kafka",
+		// Mapped prefixes.
+		{mappings, "east"}: "kafka-east",
+		{mappings, "west"}: "clusters/west",
+		{mappings, "root"}: "",
+	}
+
+	for test, expected := range tests {
+		p, err := resolveZKPrefix(test.prefix, test.cluster)
+		if err != nil {
+			t.Errorf("[%v] Unexpected error: %s", test, err)
+			continue
+		}
+
+		if p != expected {
+			t.Errorf("[%v] Expected prefix '%s', got '%s'", test, expected, p)
+		}
+	}
+
+	errTests := []testCase{
+		// Unknown cluster.
+		{mappings, "north"},
+		// Cluster not specified.
+		{mappings, ""},
+		// Cluster without mappings.
+		{"kafka", "east"},
+		// Invalid mappings.
+		{"east=kafka-east,west", "east"},
+		{"=kafka", "east"},
+		{"east=a,east=b", "east"},
+	}
+
+	for _, test := range errTests {
+		if _, err := resolveZKPrefix(test.prefix, test.cluster); err == nil {
+			t.Errorf("[%v] Expected error", test)
+		}
+	}
+}
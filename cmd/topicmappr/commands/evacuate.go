@@ -0,0 +1,245 @@
+package commands
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/DataDog/kafka-kit/kafkazk"
+
+	"github.com/spf13/cobra"
+)
+
+var evacuateCmd = &cobra.Command{
+	Use:   "evacuate",
+	Short: "Move all partition replicas off of the specified brokers",
+	Long: `evacuate drains the brokers provided via --brokers, moving every partition
+replica they hold to the remaining brokers registered in ZooKeeper. Replicas are
+placed using the same constraints as rebuild, including rack diversity and, with
+--placement=storage, storage free. Only partitions holding replicas on a drained
+broker are included in the output maps. All topics are considered unless scoped
+with --topics. If the brokers can't be fully drained, the partitions that couldn't
+be placed are reported and no maps are written.`,
+	Run: evacuate,
+}
+
+func init() {
+	rootCmd.AddCommand(evacuateCmd)
+
+	evacuateCmd.Flags().String("brokers", "", "Brokers (comma delim. list) to drain of all replicas")
+	evacuateCmd.Flags().String("exclude-brokers", "", "Brokers (comma delim. list) ineligible for placements; existing replicas in evacuated partitions are moved off of them")
+	evacuateCmd.Flags().String("topics", "", "Scope the evacuation to topics (comma delim. list) by lookup in ZooKeeper (default all topics)")
+	evacuateCmd.Flags().String("placement", "count", "Partition placement strategy: [count, storage]")
+	evacuateCmd.Flags().Float64("min-storage-free-gb", 0.00, "Reject placements that would leave a broker with less than this many gigabytes of storage free (when using storage placement)")
+	evacuateCmd.Flags().String("partition-size-source", "zk", "Partition size source: [zk, <path>]; a path is a JSON file mapping topic:partition to bytes")
+	evacuateCmd.Flags().String("zk-metrics-prefix", "topicmappr", "ZooKeeper namespace prefix for Kafka metrics (when using storage placement)")
+	evacuateCmd.Flags().Int("metrics-age", 60, "Kafka metrics age tolerance (in minutes) (when using storage placement)")
+	evacuateCmd.Flags().Duration("lock-ttl", 0, "Hold a lock under --zk-metrics-prefix while building maps, failing if another instance holds it; a lock held past this duration is reported as stalled (0 disables locking)")
+	evacuateCmd.Flags().String("out-path", "", "Path to write output map files to")
+	evacuateCmd.Flags().String("out-file", "", "If defined, write a combined map of all topics to a file")
+	evacuateCmd.Flags().String("output-format", "json", "Output map format: [json, yaml]")
+
+	// Required.
+	evacuateCmd.MarkFlagRequired("brokers")
+}
+
+func evacuate(cmd *cobra.Command, _ []string) {
+	p := cmd.Flag("placement").Value.String()
+	mf, _ := cmd.Flags().GetFloat64("min-storage-free-gb")
+
+	switch {
+	case p != "count" && p != "storage":
+		fmt.Fprintln(textOut, "\n[ERROR] --placement must be either 'count' or 'storage'")
+		defaultsAndExit()
+	case mf < 0:
+		fmt.Fprintln(textOut, "\n[ERROR] --min-storage-free-gb must be 0 or greater")
+		defaultsAndExit()
+	}
+
+	bootstrap(cmd)
+
+	drain := Config.brokers
+	var exclude []int
+	if eb, _ := cmd.Flags().GetString("exclude-brokers"); eb != "" {
+		exclude = brokerStringToSlice(eb)
+	}
+	if len(Config.topics) == 0 {
+		Config.topics = []*regexp.Regexp{regexp.MustCompile(".*")}
+	}
+
+	// ZooKeeper init.
+	zk, err := initZooKeeper(cmd)
+	if err != nil {
+		fmt.Fprintln(textOut, err)
+		exit(1)
+	}
+
+	defer zk.Close()
+
+	// Fail fast if another instance is writing maps.
+	unlock, err := lockReassignments(cmd, zk)
+	if err != nil {
+		fmt.Fprintf(textOut, "\n[ERROR] %s\n", err)
+		exit(1)
+	}
+	defer unlock()
+
+	// Fetch broker and partition metadata.
+	if p == "storage" {
+		checkMetaAge(cmd, zk)
+	}
+
+	brokerMeta := getBrokerMeta(cmd, zk, p == "storage")
+
+	var partitionMeta kafkazk.PartitionMetaMap
+	if p == "storage" {
+		partitionMeta = getPartitionMeta(cmd, zk)
+	}
+
+	partitionMap, err := kafkazk.PartitionMapFromZK(Config.topics, zk)
+	if err != nil {
+		fmt.Fprintln(textOut, err)
+		exit(1)
+	}
+
+	// Scope the map to partitions held by drained brokers.
+	partitionMap = drainedPartitions(partitionMap, drain)
+	partitionMapOrig := partitionMap.Copy()
+
+	printTopics(partitionMap)
+
+	// Assume a zero size for any partitions
+	// missing from a partition size file.
+	sizeWarns := fillMissingSourcedPartitionMeta(cmd, partitionMap, partitionMeta)
+
+	if len(exclude) > 0 {
+		fmt.Fprintf(textOut, "\nBrokers excluded from placements:\n%s%v\n", indent, exclude)
+	}
+
+	params := kafkazk.NewRebuildParams()
+	params.PMM = partitionMeta
+	params.Strategy = p
+	params.Optimization = "distribution"
+	params.MinStorageFree = mf * div
+
+	partitionMapOut, errs := evacuationMap(partitionMap, brokerMeta, drain, exclude, params)
+	if len(errs) > 0 {
+		fmt.Fprintf(textOut, "\n[ERROR] unable to drain brokers %v:\n", drain)
+		for _, e := range errs {
+			fmt.Fprintf(textOut, "%s%s\n", indent, e)
+		}
+		exit(1)
+	}
+
+	printMapChanges(partitionMapOrig, partitionMapOut)
+
+	// Print assumed partition sizes. These
+	// don't prevent map creation.
+	printSizeWarns(sizeWarns)
+
+	writeMaps(cmd, partitionMapOut)
+}
+
+// drainedPartitions returns a PartitionMap of the partitions in
+// pm holding a replica on any of the brokers in drain.
+func drainedPartitions(pm *kafkazk.PartitionMap, drain []int) *kafkazk.PartitionMap {
+	d := map[int]bool{}
+	for _, id := range drain {
+		d[id] = true
+	}
+
+	out := kafkazk.NewPartitionMap()
+
+	for _, p := range pm.Partitions {
+		for _, id := range p.Replicas {
+			if d[id] {
+				out.Partitions = append(out.Partitions, p)
+				break
+			}
+		}
+	}
+
+	return out
+}
+
+// evacuationMap returns a PartitionMap of pm with every replica held by the
+// brokers in drain moved to one of the remaining brokers in bmm. Placements
+// are performed by a rebuild with the drained brokers marked for replacement,
+// so all placement constraints apply. Brokers in exclude are ineligible for
+// placements and any replicas they hold in pm are moved off as well. If the
+// brokers can't be fully drained, the errors describe each partition that
+// couldn't be placed. The BM field of params is populated by evacuationMap.
+func evacuationMap(pm *kafkazk.PartitionMap, bmm kafkazk.BrokerMetaMap, drain, exclude []int, params kafkazk.RebuildParams) (*kafkazk.PartitionMap, errors) {
+	held := map[int]bool{}
+	for _, p := range pm.Partitions {
+		for _, id := range p.Replicas {
+			held[id] = true
+		}
+	}
+
+	d := map[int]bool{}
+	var errs errors
+
+	// Brokers missing from ZooKeeper can
+	// be drained if they hold replicas.
+	for _, id := range drain {
+		d[id] = true
+		if _, exists := bmm[id]; !exists && !held[id] {
+			errs = append(errs, fmt.Errorf("broker %d not found in ZooKeeper and holds no replicas", id))
+		}
+	}
+
+	if errs != nil {
+		return nil, errs
+	}
+
+	// Brokers eligible for placements.
+	var remaining []int
+	for id := range bmm {
+		if !d[id] {
+			remaining = append(remaining, id)
+		}
+	}
+
+	// Brokers not in the remaining list are marked for
+	// replacement by the update, excluded brokers as excluded.
+	brokers := kafkazk.BrokerMapFromPartitionMap(pm, bmm, false)
+	brokers.Exclude(exclude)
+	brokers.UpdateSorted(excludeBrokers(remaining, exclude), bmm)
+
+	// The largest replication factor must be
+	// satisfiable by the remaining brokers.
+	var r int
+	for _, p := range pm.Partitions {
+		if len(p.Replicas) > r {
+			r = len(p.Replicas)
+		}
+	}
+
+	if err := checkReplicationFactor(r, brokers); err != nil {
+		if len(exclude) > 0 {
+			return nil, errors{fmt.Errorf("too few brokers remain after draining and --exclude-brokers: %s", err)}
+		}
+		return nil, errors{fmt.Errorf("too few brokers remain after draining: %s", err)}
+	}
+
+	// Return storage held by the drained brokers.
+	if params.Strategy == "storage" {
+		replaced := func(b *kafkazk.Broker) bool { return b.Replace }
+		if err := brokers.SubStorage(pm, params.PMM, replaced); err != nil {
+			return nil, errors{err}
+		}
+	}
+
+	params.BM = brokers
+
+	out, rebuildErrs := pm.Rebuild(params)
+	for _, e := range rebuildErrs {
+		errs = append(errs, e)
+	}
+
+	if errs != nil {
+		return nil, errs
+	}
+
+	return out, nil
+}
@@ -0,0 +1,189 @@
+package commands
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/DataDog/kafka-kit/kafkazk"
+)
+
+// testEvacuateMeta is a six broker cluster spanning three racks.
+func testEvacuateMeta() kafkazk.BrokerMetaMap {
+	return kafkazk.BrokerMetaMap{
+		1001: &kafkazk.BrokerMeta{Rack: "a", StorageFree: 6000},
+		1002: &kafkazk.BrokerMeta{Rack: "a", StorageFree: 6000},
+		1003: &kafkazk.BrokerMeta{Rack: "b", StorageFree: 6000},
+		1004: &kafkazk.BrokerMeta{Rack: "b", StorageFree: 6000},
+		1005: &kafkazk.BrokerMeta{Rack: "c", StorageFree: 6000},
+		1006: &kafkazk.BrokerMeta{Rack: "c", StorageFree: 6000},
+	}
+}
+
+func testEvacuateMap(t *testing.T) *kafkazk.PartitionMap {
+	pm, err := kafkazk.PartitionMapFromString(`{"version":1,"partitions":[
+		{"topic":"test_topic","partition":0,"replicas":[1001,1003,1005]},
+		{"topic":"test_topic","partition":1,"replicas":[1002,1004,1006]},
+		{"topic":"test_topic","partition":2,"replicas":[1003,1001,1006]},
+		{"topic":"test_topic","partition":3,"replicas":[1004,1005,1002]},
+		{"topic":"test_topic2","partition":0,"replicas":[1005,1001]},
+		{"topic":"test_topic2","partition":1,"replicas":[1006,1003]}]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return pm
+}
+
+func TestDrainedPartitions(t *testing.T) {
+	pm := drainedPartitions(testEvacuateMap(t), []int{1001})
+
+	expected := []string{"test_topic p0", "test_topic p2", "test_topic2 p0"}
+
+	var got []string
+	for _, p := range pm.Partitions {
+		got = append(got, fmt.Sprintf("%s p%d", p.Topic, p.Partition))
+	}
+
+	if !reflect.DeepEqual(expected, got) {
+		t.Errorf("Expected partitions %v, got %v", expected, got)
+	}
+}
+
+func TestEvacuationMap(t *testing.T) {
+	bmm := testEvacuateMeta()
+
+	for _, placement := range []string{"count", "storage"} {
+		pm := drainedPartitions(testEvacuateMap(t), []int{1001})
+
+		pmm := kafkazk.NewPartitionMetaMap()
+		pmm.FillMissing(pm, 100)
+
+		params := kafkazk.NewRebuildParams()
+		params.PMM = pmm
+		params.Strategy = placement
+		params.Optimization = "distribution"
+
+		// Replication factors by partition.
+		rf := map[string]int{}
+		for _, p := range pm.Partitions {
+			rf[fmt.Sprintf("%s p%d", p.Topic, p.Partition)] = len(p.Replicas)
+		}
+
+		out, errs := evacuationMap(pm, bmm, []int{1001}, nil, params)
+		if errs != nil {
+			t.Fatalf("[%s] Unexpected error(s): %s", placement, errs)
+		}
+
+		if len(out.Partitions) != len(rf) {
+			t.Fatalf("[%s] Expected %d partitions, got %d", placement, len(rf), len(out.Partitions))
+		}
+
+		for _, p := range out.Partitions {
+			name := fmt.Sprintf("%s p%d", p.Topic, p.Partition)
+			if len(p.Replicas) != rf[name] {
+				t.Errorf("[%s] %s: expected %d replicas, got %v", placement, name, rf[name], p.Replicas)
+			}
+
+			racks := map[string]bool{}
+			for _, id := range p.Replicas {
+				// Zero replicas remain on the drained broker.
+				if id == 1001 {
+					t.Errorf("[%s] %s p%d: replicas %v include drained broker 1001", placement, p.Topic, p.Partition, p.Replicas)
+				}
+
+				if racks[bmm[id].Rack] {
+					t.Errorf("[%s] %s p%d: replicas %v share rack %s", placement, p.Topic, p.Partition, p.Replicas, bmm[id].Rack)
+				}
+				racks[bmm[id].Rack] = true
+			}
+		}
+
+		// 1002 is the only remaining rack-a
+		// broker for the RF3 partitions.
+		for _, p := range out.Partitions {
+			if p.Topic == "test_topic" && p.Replicas[0] != 1002 && p.Replicas[1] != 1002 {
+				t.Errorf("[%s] %s p%d: expected 1001 replaced by 1002, got %v", placement, p.Topic, p.Partition, p.Replicas)
+			}
+		}
+	}
+}
+
+func TestEvacuationMapExclude(t *testing.T) {
+	bmm := testEvacuateMeta()
+
+	params := kafkazk.NewRebuildParams()
+	params.Strategy = "count"
+	params.Optimization = "distribution"
+
+	drain, exclude := []int{1001}, []int{1005}
+	pm := drainedPartitions(testEvacuateMap(t), drain)
+
+	out, errs := evacuationMap(pm, bmm, drain, exclude, params)
+	if errs != nil {
+		t.Fatalf("Unexpected error(s): %s", errs)
+	}
+
+	// Replicas on the excluded broker in evacuated
+	// partitions are moved off, and it receives none.
+	for _, p := range out.Partitions {
+		for _, id := range p.Replicas {
+			if id == 1001 || id == 1005 {
+				t.Errorf("%s p%d: replicas %v include drained or excluded broker %d", p.Topic, p.Partition, p.Replicas, id)
+			}
+		}
+	}
+
+	// Excluding the remaining rack c
+	// broker leaves too few racks.
+	exclude = []int{1005, 1006}
+	if _, errs := evacuationMap(pm, bmm, drain, exclude, params); len(errs) == 0 {
+		t.Error("Expected error")
+	}
+}
+
+func TestEvacuationMapInfeasible(t *testing.T) {
+	bmm := testEvacuateMeta()
+
+	params := kafkazk.NewRebuildParams()
+	params.Strategy = "count"
+	params.Optimization = "distribution"
+
+	// Draining rack a leaves no rack diverse
+	// placements for the RF3 partitions.
+	drain := []int{1001, 1002}
+	pm := drainedPartitions(testEvacuateMap(t), drain)
+
+	_, errs := evacuationMap(pm, bmm, drain, nil, params)
+
+	expected := []string{"test_topic p0", "test_topic p1", "test_topic p2", "test_topic p3"}
+	for _, name := range expected {
+		var found bool
+		for _, e := range errs {
+			if strings.HasPrefix(e.Error(), name+":") {
+				found = true
+			}
+		}
+
+		if !found {
+			t.Errorf("Expected an error for %s, got %v", name, errs)
+		}
+	}
+
+	// Too few brokers remain.
+	drain = []int{1001, 1002, 1003, 1004}
+	pm = drainedPartitions(testEvacuateMap(t), drain)
+
+	if _, errs := evacuationMap(pm, bmm, drain, nil, params); len(errs) != 1 || !strings.Contains(errs[0].Error(), "too few brokers") {
+		t.Errorf("Expected a broker count error, got %v", errs)
+	}
+
+	// Unknown brokers.
+	drain = []int{2001}
+	pm = drainedPartitions(testEvacuateMap(t), drain)
+
+	if _, errs := evacuationMap(pm, bmm, drain, nil, params); len(errs) != 1 {
+		t.Errorf("Expected an unknown broker error, got %v", errs)
+	}
+}
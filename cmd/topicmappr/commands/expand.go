@@ -0,0 +1,192 @@
+package commands
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+
+	"github.com/DataDog/kafka-kit/kafkazk"
+
+	"github.com/spf13/cobra"
+)
+
+var expandTopicCmd = &cobra.Command{
+	Use:   "expand-topic",
+	Short: "Increase the partition count of a topic",
+	Long: `expand-topic adds partitions to the --topic, up to the --partitions count,
+placing the replicas of each new partition using the same constraints as rebuild,
+including rack diversity. New partitions take the replication factor of the
+topic. The assignments of existing partitions are left untouched. The output map
+holds the complete assignment for the expanded topic, suitable for altering the
+topic's partition count. Partition counts can't be reduced.`,
+	Run: expandTopic,
+}
+
+func init() {
+	rootCmd.AddCommand(expandTopicCmd)
+
+	expandTopicCmd.Flags().String("topic", "", "Topic to expand")
+	expandTopicCmd.Flags().Int("partitions", 0, "Target partition count; must be greater than the current count")
+	expandTopicCmd.Flags().String("brokers", "", "Broker list to scope new partition placements to (default brokers currently holding the topic)")
+	expandTopicCmd.Flags().String("exclude-brokers", "", "Brokers (comma delim. list) ineligible for new partition placements")
+	expandTopicCmd.Flags().String("zk-metrics-prefix", "topicmappr", "ZooKeeper namespace prefix for Kafka metrics")
+	expandTopicCmd.Flags().Duration("lock-ttl", 0, "Hold a lock under --zk-metrics-prefix while building maps, failing if another instance holds it; a lock held past this duration is reported as stalled (0 disables locking)")
+	expandTopicCmd.Flags().String("out-path", "", "Path to write output map files to")
+	expandTopicCmd.Flags().String("out-file", "", "If defined, write a combined map of all topics to a file")
+	expandTopicCmd.Flags().String("output-format", "json", "Output map format: [json, yaml]")
+
+	// Required.
+	expandTopicCmd.MarkFlagRequired("topic")
+	expandTopicCmd.MarkFlagRequired("partitions")
+}
+
+func expandTopic(cmd *cobra.Command, _ []string) {
+	t, _ := cmd.Flags().GetString("topic")
+	n, _ := cmd.Flags().GetInt("partitions")
+
+	switch {
+	case t == "":
+		fmt.Fprintln(textOut, "\n[ERROR] --topic must be specified")
+		defaultsAndExit()
+	case n < 1:
+		fmt.Fprintln(textOut, "\n[ERROR] --partitions must be greater than 0")
+		defaultsAndExit()
+	}
+
+	bootstrap(cmd)
+
+	// ZooKeeper init.
+	zk, err := initZooKeeper(cmd)
+	if err != nil {
+		fmt.Fprintln(textOut, err)
+		exit(1)
+	}
+
+	defer zk.Close()
+
+	// Fail fast if another instance is writing maps.
+	unlock, err := lockReassignments(cmd, zk)
+	if err != nil {
+		fmt.Fprintf(textOut, "\n[ERROR] %s\n", err)
+		exit(1)
+	}
+	defer unlock()
+
+	re := regexp.MustCompile(fmt.Sprintf("^%s$", regexp.QuoteMeta(t)))
+	partitionMap, err := kafkazk.PartitionMapFromZK([]*regexp.Regexp{re}, zk)
+	if err != nil {
+		fmt.Fprintln(textOut, err)
+		exit(1)
+	}
+
+	brokerMeta := getBrokerMeta(cmd, zk, false)
+
+	var exclude []int
+	if eb, _ := cmd.Flags().GetString("exclude-brokers"); eb != "" {
+		exclude = brokerStringToSlice(eb)
+	}
+
+	partitionMapOut, errs := expansionMap(partitionMap, brokerMeta, Config.brokers, exclude, n)
+	if len(errs) > 0 {
+		fmt.Fprintf(textOut, "\n[ERROR] unable to expand topic %s:\n", t)
+		for _, e := range errs {
+			fmt.Fprintf(textOut, "%s%s\n", indent, e)
+		}
+		exit(1)
+	}
+
+	fmt.Fprintf(textOut, "\nTopic %s: %d -> %d partitions\n", t, len(partitionMap.Partitions), len(partitionMapOut.Partitions))
+
+	fmt.Fprintln(textOut, "\nNew partitions:")
+	for _, p := range partitionMapOut.Partitions[len(partitionMap.Partitions):] {
+		fmt.Fprintf(textOut, "%s%s p%d: %v\n", indent, p.Topic, p.Partition, p.Replicas)
+	}
+
+	writeMaps(cmd, partitionMapOut)
+}
+
+// expansionMap takes a PartitionMap of a single topic and returns a copy
+// extended to n partitions. The replicas of each new partition are placed by
+// a rebuild of stub replica sets among the brokers in bl, or the brokers
+// holding the topic if bl is empty, less any brokers in exclude; existing
+// assignments, including those on excluded brokers, are unmodified. The
+// topic's current partition usage is accounted for so that the new partitions
+// are spread across the least used brokers. New partitions take the largest
+// replication factor of the existing partitions. An error is returned if n
+// doesn't exceed the current partition count.
+func expansionMap(pm *kafkazk.PartitionMap, bmm kafkazk.BrokerMetaMap, bl, exclude []int, n int) (*kafkazk.PartitionMap, errors) {
+	if len(pm.Partitions) == 0 {
+		return nil, errors{fmt.Errorf("partition map is empty")}
+	}
+
+	topic := pm.Partitions[0].Topic
+
+	var r, current int
+	for _, p := range pm.Partitions {
+		if p.Topic != topic {
+			return nil, errors{fmt.Errorf("expected partitions of one topic, got %s and %s", topic, p.Topic)}
+		}
+
+		if len(p.Replicas) > r {
+			r = len(p.Replicas)
+		}
+
+		if p.Partition+1 > current {
+			current = p.Partition + 1
+		}
+	}
+
+	if n <= current {
+		return nil, errors{fmt.Errorf("topic %s has %d partitions; partition counts can only be increased", topic, current)}
+	}
+
+	// Placements default to the brokers
+	// currently holding the topic.
+	brokers := kafkazk.BrokerMapFromPartitionMap(pm, bmm, false)
+
+	if len(bl) == 0 {
+		for id := range brokers {
+			if id != 0 {
+				bl = append(bl, id)
+			}
+		}
+		sort.Ints(bl)
+	}
+
+	// Brokers not in bl and excluded brokers are marked
+	// for replacement and therefore ineligible for placements.
+	// Only the new partitions are rebuilt, so existing replicas
+	// on these brokers aren't moved.
+	brokers.Exclude(exclude)
+	brokers.UpdateSorted(excludeBrokers(bl, exclude), bmm)
+
+	if err := checkReplicationFactor(r, brokers); err != nil {
+		if len(exclude) > 0 {
+			return nil, errors{fmt.Errorf("too few brokers remain after --exclude-brokers: %s", err)}
+		}
+		return nil, errors{err}
+	}
+
+	// Stub replica sets for the new partitions.
+	stubs := kafkazk.NewTopicPartitionMap(topic, n, r)
+	stubs.Partitions = stubs.Partitions[current:]
+
+	params := kafkazk.NewRebuildParams()
+	params.BM = brokers
+	params.Strategy = "count"
+
+	placed, rebuildErrs := stubs.Rebuild(params)
+	if len(rebuildErrs) > 0 {
+		var errs errors
+		for _, e := range rebuildErrs {
+			errs = append(errs, e)
+		}
+		return nil, errs
+	}
+
+	out := pm.Copy()
+	out.Partitions = append(out.Partitions, placed.Partitions...)
+	sort.Sort(out.Partitions)
+
+	return out, nil
+}
@@ -0,0 +1,160 @@
+package commands
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/DataDog/kafka-kit/kafkazk"
+)
+
+func TestExpansionMap(t *testing.T) {
+	bmm := testEvacuateMeta()
+
+	pm, err := kafkazk.PartitionMapFromString(`{"version":1,"partitions":[
+		{"topic":"test_topic","partition":0,"replicas":[1001,1003,1005]},
+		{"topic":"test_topic","partition":1,"replicas":[1002,1004,1006]},
+		{"topic":"test_topic","partition":2,"replicas":[1003,1005,1001]},
+		{"topic":"test_topic","partition":3,"replicas":[1004,1006,1002]},
+		{"topic":"test_topic","partition":4,"replicas":[1005,1001,1003]},
+		{"topic":"test_topic","partition":5,"replicas":[1006,1002,1004]}]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	orig := pm.Copy()
+
+	out, errs := expansionMap(pm, bmm, nil, nil, 12)
+	if errs != nil {
+		t.Fatalf("Unexpected error(s): %s", errs)
+	}
+
+	if len(out.Partitions) != 12 {
+		t.Fatalf("Expected 12 partitions, got %d", len(out.Partitions))
+	}
+
+	// The input map isn't modified.
+	if !reflect.DeepEqual(pm, orig) {
+		t.Errorf("Expected input map %v to be unmodified, got %v", orig, pm)
+	}
+
+	// Existing assignments are untouched.
+	for i, p := range orig.Partitions {
+		if !reflect.DeepEqual(out.Partitions[i], p) {
+			t.Errorf("Expected partition %v unchanged, got %v", p, out.Partitions[i])
+		}
+	}
+
+	// Only the new partitions are assigned.
+	counts := map[int]int{}
+	for i, p := range out.Partitions[6:] {
+		if p.Topic != "test_topic" || p.Partition != i+6 {
+			t.Errorf("Expected test_topic p%d, got %s p%d", i+6, p.Topic, p.Partition)
+		}
+
+		if len(p.Replicas) != 3 {
+			t.Errorf("p%d: expected 3 replicas, got %v", p.Partition, p.Replicas)
+		}
+
+		racks := map[string]bool{}
+		for _, id := range p.Replicas {
+			if _, exists := bmm[id]; !exists {
+				t.Errorf("p%d: unexpected broker %d in %v", p.Partition, id, p.Replicas)
+				continue
+			}
+
+			if racks[bmm[id].Rack] {
+				t.Errorf("p%d: replicas %v share rack %s", p.Partition, p.Replicas, bmm[id].Rack)
+			}
+			racks[bmm[id].Rack] = true
+			counts[id]++
+		}
+	}
+
+	// The new replicas are spread evenly.
+	for id := range bmm {
+		if counts[id] != 3 {
+			t.Errorf("Expected 3 new replicas on broker %d, got %d", id, counts[id])
+		}
+	}
+}
+
+func TestExpansionMapBrokers(t *testing.T) {
+	bmm := testEvacuateMeta()
+
+	pm, _ := kafkazk.PartitionMapFromString(`{"version":1,"partitions":[
+		{"topic":"test_topic","partition":0,"replicas":[1001,1003]},
+		{"topic":"test_topic","partition":1,"replicas":[1002,1004]}]}`)
+
+	// New partitions are scoped to the broker list.
+	out, errs := expansionMap(pm, bmm, []int{1002, 1005}, nil, 4)
+	if errs != nil {
+		t.Fatalf("Unexpected error(s): %s", errs)
+	}
+
+	for _, p := range out.Partitions[2:] {
+		for _, id := range p.Replicas {
+			if id != 1002 && id != 1005 {
+				t.Errorf("p%d: expected replicas on 1002 and 1005, got %v", p.Partition, p.Replicas)
+			}
+		}
+	}
+
+	// Too few brokers for the replication factor.
+	if _, errs := expansionMap(pm, bmm, []int{1005}, nil, 4); len(errs) != 1 {
+		t.Errorf("Expected a replication factor error, got %v", errs)
+	}
+}
+
+func TestExpansionMapExclude(t *testing.T) {
+	bmm := testEvacuateMeta()
+
+	pm, _ := kafkazk.PartitionMapFromString(`{"version":1,"partitions":[
+		{"topic":"test_topic","partition":0,"replicas":[1001,1003]},
+		{"topic":"test_topic","partition":1,"replicas":[1002,1004]}]}`)
+
+	// Excluded brokers receive no new partitions and
+	// existing replicas on them aren't moved.
+	out, errs := expansionMap(pm, bmm, nil, []int{1001, 1003}, 4)
+	if errs != nil {
+		t.Fatalf("Unexpected error(s): %s", errs)
+	}
+
+	if r := out.Partitions[0].Replicas; !reflect.DeepEqual(r, []int{1001, 1003}) {
+		t.Errorf("p0: expected replicas [1001 1003], got %v", r)
+	}
+
+	for _, p := range out.Partitions[2:] {
+		for _, id := range p.Replicas {
+			if id == 1001 || id == 1003 {
+				t.Errorf("p%d: replicas %v include excluded broker %d", p.Partition, p.Replicas, id)
+			}
+		}
+	}
+
+	// Too few brokers remain.
+	_, errs = expansionMap(pm, bmm, nil, []int{1001, 1002, 1003}, 4)
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "--exclude-brokers") {
+		t.Errorf("Expected an --exclude-brokers error, got %v", errs)
+	}
+}
+
+func TestExpansionMapReduce(t *testing.T) {
+	bmm := testEvacuateMeta()
+	pm := testEvacuateMap(t)
+
+	// test_topic has 4 partitions; reductions and
+	// no-ops are rejected.
+	pm.Partitions = pm.Partitions[:4]
+
+	for _, n := range []int{2, 4} {
+		if _, errs := expansionMap(pm, bmm, nil, nil, n); len(errs) != 1 {
+			t.Errorf("[%d partitions] Expected error, got %v", n, errs)
+		}
+	}
+
+	// Multiple topics are rejected.
+	if _, errs := expansionMap(testEvacuateMap(t), bmm, nil, nil, 8); len(errs) != 1 {
+		t.Errorf("Expected error, got %v", errs)
+	}
+}
@@ -0,0 +1,103 @@
+package commands
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/DataDog/kafka-kit/kafkazk"
+
+	"github.com/spf13/cobra"
+)
+
+var fairnessCmd = &cobra.Command{
+	Use:   "fairness",
+	Short: "Audit replica distribution fairness using the Gini coefficient",
+	Long: `fairness reports the Gini coefficient of partition replica counts across
+all brokers holding the topics matching --topics, along with any additional
+brokers provided via --brokers. The score ranges from 0 (perfectly even) to 1
+(a single broker holds everything). Optionally, the Gini coefficient of broker
+free storage is reported via --storage, which requires broker metrics.`,
+	Run: fairness,
+}
+
+func init() {
+	rootCmd.AddCommand(fairnessCmd)
+
+	fairnessCmd.Flags().String("topics", "", "Audit topics (comma delim. list) by lookup in ZooKeeper")
+	fairnessCmd.Flags().String("brokers", "", "Additional brokers to include (e.g. those holding no partitions)")
+	fairnessCmd.Flags().Bool("storage", false, "Include the Gini coefficient of broker free storage")
+	fairnessCmd.Flags().String("zk-metrics-prefix", "topicmappr", "ZooKeeper namespace prefix for Kafka metrics (when using --storage)")
+	fairnessCmd.Flags().Int("metrics-age", 60, "Kafka metrics age tolerance (in minutes) (when using --storage)")
+
+	// Required.
+	fairnessCmd.MarkFlagRequired("topics")
+}
+
+func fairness(cmd *cobra.Command, _ []string) {
+	bootstrap(cmd)
+
+	// ZooKeeper init.
+	zk, err := initZooKeeper(cmd)
+	if err != nil {
+		fmt.Fprintln(textOut, err)
+		exit(1)
+	}
+
+	defer zk.Close()
+
+	partitionMap, err := kafkazk.PartitionMapFromZK(Config.topics, zk)
+	if err != nil {
+		fmt.Fprintln(textOut, err)
+		exit(1)
+	}
+
+	printTopics(partitionMap)
+
+	ids := fairnessBrokers(partitionMap, Config.brokers)
+
+	fmt.Fprintln(textOut, "\nFairness (Gini coefficient; 0 is even, 1 is maximally skewed):")
+	fmt.Fprintf(textOut, "%sbrokers: %d\n", indent, len(ids))
+	fmt.Fprintf(textOut, "%spartition count: %.3f\n", indent, partitionMap.CountGini(ids))
+
+	if s, _ := cmd.Flags().GetBool("storage"); s {
+		checkMetaAge(cmd, zk)
+		brokerMeta := getBrokerMeta(cmd, zk, true)
+
+		brokers := kafkazk.BrokerMap{}
+		for _, id := range ids {
+			meta, exists := brokerMeta[id]
+			if !exists || meta.MetricsIncomplete {
+				fmt.Fprintf(textOut, "Metrics not found for broker %d\n", id)
+				exit(1)
+			}
+
+			brokers[id] = &kafkazk.Broker{ID: id, StorageFree: meta.StorageFree}
+		}
+
+		fmt.Fprintf(textOut, "%sstorage free: %.3f\n", indent, brokers.StorageGini())
+	}
+}
+
+// fairnessBrokers returns a sorted []int of all broker IDs
+// referenced in the PartitionMap along with the additional IDs.
+func fairnessBrokers(pm *kafkazk.PartitionMap, additional []int) []int {
+	seen := map[int]bool{}
+	for _, p := range pm.Partitions {
+		for _, id := range p.Replicas {
+			seen[id] = true
+		}
+	}
+
+	for _, id := range additional {
+		seen[id] = true
+	}
+
+	var ids []int
+	for id := range seen {
+		ids = append(ids, id)
+	}
+
+	sort.Ints(ids)
+
+	return ids
+}
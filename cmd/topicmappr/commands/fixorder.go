@@ -0,0 +1,151 @@
+package commands
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/DataDog/kafka-kit/kafkazk"
+
+	"github.com/spf13/cobra"
+)
+
+var fixOrderCmd = &cobra.Command{
+	Use:   "fix-order",
+	Short: "Reorder replica sets to set preferred leaders without changing membership",
+	Long: `fix-order reorders the replica sets of the target topics so that the
+desired broker is in the first (preferred leader) position. Replica set
+membership is never changed. The desired leaders are either selected by a
+policy via --leader-policy or provided explicitly via --leaders. The bytes
+policy balances leader bytes, using partition sizes as a proxy for throughput,
+rather than leader counts. Target topics are provided via --topics (discovered
+in ZooKeeper) or a JSON map via --map-string.`,
+	Run: fixOrder,
+}
+
+func init() {
+	rootCmd.AddCommand(fixOrderCmd)
+
+	fixOrderCmd.Flags().String("topics", "", "Reorder topics (comma delim. list) by lookup in ZooKeeper")
+	fixOrderCmd.Flags().String("map-string", "", "Reorder a partition map provided as a string literal")
+	fixOrderCmd.Flags().String("leader-policy", "", "Preferred leader selection policy: [count, storage, bytes, rack:<id>]")
+	fixOrderCmd.Flags().String("leaders", "", "Explicit preferred leaders (comma delim. list of topic:partition:broker)")
+	fixOrderCmd.Flags().String("out-path", "", "Path to write output map files to")
+	fixOrderCmd.Flags().String("out-file", "", "If defined, write a combined map of all topics to a file")
+	fixOrderCmd.Flags().String("output-format", "json", "Output map format: [json, yaml]")
+	fixOrderCmd.Flags().String("zk-metrics-prefix", "topicmappr", "ZooKeeper namespace prefix for Kafka metrics (when using the storage or bytes leader policy)")
+}
+
+func fixOrder(cmd *cobra.Command, _ []string) {
+	t, _ := cmd.Flags().GetString("topics")
+	ms, _ := cmd.Flags().GetString("map-string")
+	lp, _ := cmd.Flags().GetString("leader-policy")
+	l, _ := cmd.Flags().GetString("leaders")
+
+	switch {
+	case ms == "" && t == "":
+		fmt.Fprintln(textOut, "\n[ERROR] must specify either --topics or --map-string")
+		defaultsAndExit()
+	case lp == "" && l == "":
+		fmt.Fprintln(textOut, "\n[ERROR] must specify either --leader-policy or --leaders")
+		defaultsAndExit()
+	case lp != "" && l != "":
+		fmt.Fprintln(textOut, "\n[ERROR] --leader-policy and --leaders are mutually exclusive")
+		defaultsAndExit()
+	case lp != "" && lp != "count" && lp != "storage" && lp != "bytes" && !strings.HasPrefix(lp, "rack:"):
+		fmt.Fprintln(textOut, "\n[ERROR] --leader-policy must be either 'count', 'storage', 'bytes' or 'rack:<id>'")
+		defaultsAndExit()
+	case lp == "rack:":
+		fmt.Fprintln(textOut, "\n[ERROR] --leader-policy rack:<id> requires a rack ID")
+		defaultsAndExit()
+	}
+
+	leaders, err := parseLeaders(l)
+	if err != nil {
+		fmt.Fprintf(textOut, "\n[ERROR] %s\n", err)
+		defaultsAndExit()
+	}
+
+	bootstrap(cmd)
+
+	// ZooKeeper is required for topic discovery
+	// and broker metadata used by policies.
+	var zk kafkazk.Handler
+	if t != "" || lp != "" {
+		zk, err = initZooKeeper(cmd)
+		if err != nil {
+			fmt.Fprintln(textOut, err)
+			exit(1)
+		}
+		defer zk.Close()
+	}
+
+	partitionMap := getPartitionMap(cmd, zk)
+	partitionMapOrig := partitionMap.Copy()
+
+	printTopics(partitionMap)
+
+	var partitionMeta kafkazk.PartitionMetaMap
+
+	switch {
+	case lp == "bytes":
+		partitionMeta = getPartitionMeta(cmd, zk)
+		err = partitionMap.SetLeadersBySize(partitionMeta)
+	case lp != "":
+		brokerMeta := getBrokerMeta(cmd, zk, lp == "storage")
+		brokers := kafkazk.BrokerMapFromPartitionMap(partitionMap, brokerMeta, false)
+		err = partitionMap.SetLeaders(brokers, lp)
+	default:
+		err = partitionMap.SetExplicitLeaders(leaders)
+	}
+
+	if err != nil {
+		fmt.Fprintln(textOut, err)
+		exit(1)
+	}
+
+	printMapChanges(partitionMapOrig, partitionMap)
+
+	if partitionMeta != nil {
+		printLeaderBytesStats(partitionMapOrig, partitionMap, partitionMeta)
+	}
+
+	// Only emit changed replica sets.
+	_, partitionMap = skipReassignmentNoOps(partitionMapOrig, partitionMap)
+
+	writeMaps(cmd, partitionMap)
+}
+
+// parseLeaders takes a comma delimited list of topic:partition:broker
+// strings and returns a map of topic, partition to leader broker ID.
+func parseLeaders(s string) (map[string]map[int]int, error) {
+	leaders := map[string]map[int]int{}
+	if s == "" {
+		return leaders, nil
+	}
+
+	for _, l := range strings.Split(s, ",") {
+		parts := strings.Split(strings.TrimSpace(l), ":")
+		if len(parts) != 3 || parts[0] == "" {
+			return nil, fmt.Errorf("invalid leader '%s': must be formatted as topic:partition:broker", l)
+		}
+
+		p, err := strconv.Atoi(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid leader '%s': %s", l, err)
+		}
+
+		id, err := strconv.Atoi(parts[2])
+		if err != nil {
+			return nil, fmt.Errorf("invalid leader '%s': %s", l, err)
+		}
+
+		if _, exists := leaders[parts[0]]; !exists {
+			leaders[parts[0]] = map[int]int{}
+		}
+
+		leaders[parts[0]][p] = id
+	}
+
+	return leaders, nil
+}
@@ -0,0 +1,246 @@
+package commands
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/DataDog/kafka-kit/kafkazk"
+
+	"github.com/spf13/cobra"
+)
+
+var isrHealthCmd = &cobra.Command{
+	Use:   "isr-health",
+	Short: "Report ISR health and recommend reassignment throttle limits",
+	Long: `isr-health reads the replica and ISR state of all topics matching --topics
+and reports under-replicated and offline partitions. Brokers hosting lagging
+replicas or leading under-replicated partitions are already spending replication
+capacity on recovery; a reassignment throttle is recommended for each broker
+from the capacity remaining. The lowest per-broker value is recommended as the
+cluster wide throttle. New reassignments are warned against while the share of
+under-replicated partitions exceeds --urp-threshold.`,
+	Run: isrHealth,
+}
+
+func init() {
+	rootCmd.AddCommand(isrHealthCmd)
+
+	isrHealthCmd.Flags().String("topics", ".*", "Topics (comma delim. list) to check by lookup in ZooKeeper")
+	isrHealthCmd.Flags().Float64("capacity", 125.00, "Per-broker replication capacity in MB/s")
+	isrHealthCmd.Flags().Float64("max-rate", 90.00, "Maximum percent of remaining capacity to recommend for reassignments")
+	isrHealthCmd.Flags().Float64("min-rate", 10.00, "Minimum recommended throttle rate in MB/s")
+	isrHealthCmd.Flags().Float64("urp-threshold", 5.00, "Percent of under-replicated partitions above which new reassignments are warned against")
+}
+
+// throttleParams holds the
+// throttle recommendation settings.
+type throttleParams struct {
+	capacity float64
+	max      float64
+	min      float64
+}
+
+// isrBroker holds replica and recovery
+// counts for a broker along with its
+// recommended throttle rate.
+type isrBroker struct {
+	id       int
+	replicas int
+	// Replicas not in the ISR.
+	lagging int
+	// Under-replicated partitions
+	// led by the broker.
+	sourcing int
+	rate     float64
+}
+
+// isrHealthReport holds ISR statistics for
+// a set of topics along with the recommended
+// cluster wide throttle rate.
+type isrHealthReport struct {
+	partitions      int
+	underReplicated int
+	offline         int
+	brokers         []isrBroker
+	rate            float64
+}
+
+// urpPercent returns the percent of
+// partitions that are under-replicated.
+func (r isrHealthReport) urpPercent() float64 {
+	if r.partitions == 0 {
+		return 0
+	}
+
+	return float64(r.underReplicated) / float64(r.partitions) * 100
+}
+
+// holdReassignments returns whether new reassignments should be avoided
+// given the percent threshold of under-replicated partitions.
+func (r isrHealthReport) holdReassignments(threshold float64) bool {
+	return r.offline > 0 || r.urpPercent() > threshold
+}
+
+func isrHealth(cmd *cobra.Command, _ []string) {
+	bootstrap(cmd)
+
+	// ZooKeeper init.
+	zk, err := initZooKeeper(cmd)
+	if err != nil {
+		fmt.Fprintln(textOut, err)
+		exit(1)
+	}
+
+	defer zk.Close()
+
+	topics, err := zk.GetTopics(Config.topics)
+	if err != nil {
+		fmt.Fprintln(textOut, err)
+		exit(1)
+	}
+
+	if len(topics) == 0 {
+		fmt.Fprintln(textOut, "No topics found matching --topics")
+		exit(1)
+	}
+
+	states := map[string]*kafkazk.TopicState{}
+	isrs := map[string]kafkazk.TopicStateISR{}
+
+	for _, t := range topics {
+		if states[t], err = zk.GetTopicState(t); err != nil {
+			fmt.Fprintln(textOut, err)
+			exit(1)
+		}
+
+		if isrs[t], err = zk.GetTopicStateISR(t); err != nil {
+			fmt.Fprintln(textOut, err)
+			exit(1)
+		}
+	}
+
+	c, _ := cmd.Flags().GetFloat64("capacity")
+	max, _ := cmd.Flags().GetFloat64("max-rate")
+	min, _ := cmd.Flags().GetFloat64("min-rate")
+	thresh, _ := cmd.Flags().GetFloat64("urp-threshold")
+
+	report := buildISRHealth(states, isrs, throttleParams{capacity: c, max: max, min: min})
+
+	printISRHealth(report, thresh)
+}
+
+// buildISRHealth takes the replica state and ISR state of each topic
+// and returns an isrHealthReport. A broker's recovery load is the share of
+// its replicas that are either lagging or leading an under-replicated
+// partition; the recommended throttle is the max percent of the capacity
+// not consumed by recovery, floored at the min rate.
+func buildISRHealth(states map[string]*kafkazk.TopicState, isrs map[string]kafkazk.TopicStateISR, p throttleParams) isrHealthReport {
+	report := isrHealthReport{}
+	brokers := map[int]*isrBroker{}
+
+	get := func(id int) *isrBroker {
+		if _, exists := brokers[id]; !exists {
+			brokers[id] = &isrBroker{id: id}
+		}
+		return brokers[id]
+	}
+
+	for t, state := range states {
+		for pn, replicas := range state.Partitions {
+			report.partitions++
+
+			ps, exists := isrs[t][pn]
+			inISR := map[int]bool{}
+			for _, id := range ps.ISR {
+				inISR[id] = true
+			}
+
+			var lagging int
+			for _, id := range replicas {
+				b := get(id)
+				b.replicas++
+				if !inISR[id] {
+					b.lagging++
+					lagging++
+				}
+			}
+
+			if lagging == 0 {
+				continue
+			}
+
+			report.underReplicated++
+
+			// No leader to recover from.
+			if !exists || len(ps.ISR) == 0 || ps.Leader < 0 {
+				report.offline++
+				continue
+			}
+
+			get(ps.Leader).sourcing++
+		}
+	}
+
+	var ids []int
+	for id := range brokers {
+		ids = append(ids, id)
+	}
+
+	sort.Ints(ids)
+
+	report.rate = math.MaxFloat64
+
+	for _, id := range ids {
+		b := brokers[id]
+
+		// A broker may lead partitions without holding any
+		// listed replicas, e.g. mid-reassignment.
+		var load float64
+		switch {
+		case b.replicas > 0:
+			load = math.Min(float64(b.lagging+b.sourcing)/float64(b.replicas), 1.00)
+		case b.sourcing > 0:
+			load = 1.00
+		}
+
+		b.rate = math.Max(p.capacity*(1-load)*(p.max/100), p.min)
+
+		report.rate = math.Min(report.rate, b.rate)
+		report.brokers = append(report.brokers, *b)
+	}
+
+	if len(ids) == 0 {
+		report.rate = 0
+	}
+
+	return report
+}
+
+// printISRHealth prints an isrHealthReport. A warning is printed if any
+// partitions are offline or the percent of under-replicated partitions
+// exceeds the threshold.
+func printISRHealth(r isrHealthReport, threshold float64) {
+	fmt.Fprintln(textOut, "\nISR health:")
+	fmt.Fprintf(textOut, "%sPartitions: %d\n", indent, r.partitions)
+	fmt.Fprintf(textOut, "%sUnder-replicated: %d (%.2f%%)\n", indent, r.underReplicated, r.urpPercent())
+	fmt.Fprintf(textOut, "%sOffline: %d\n", indent, r.offline)
+
+	fmt.Fprintln(textOut, "\nBroker recovery:")
+	for _, b := range r.brokers {
+		fmt.Fprintf(textOut, "%sBroker %d - replicas: %d, lagging: %d, leading under-replicated: %d, throttle: %.2fMB/s\n",
+			indent, b.id, b.replicas, b.lagging, b.sourcing, b.rate)
+	}
+
+	fmt.Fprintln(textOut, "\nRecommended reassignment throttle:")
+	fmt.Fprintf(textOut, "%s%.2fMB/s\n", indent, r.rate)
+
+	if r.offline > 0 {
+		fmt.Fprintf(textOut, "\n[WARNING] %d partition(s) offline\n", r.offline)
+	}
+
+	if r.holdReassignments(threshold) {
+		fmt.Fprintf(textOut, "\n[WARNING] %.2f%% of partitions under-replicated (threshold %.2f%%); new reassignments not recommended until recovery completes\n",
+			r.urpPercent(), threshold)
+	}
+}
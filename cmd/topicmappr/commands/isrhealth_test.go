@@ -0,0 +1,187 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/DataDog/kafka-kit/kafkazk"
+)
+
+func testThrottleParams() throttleParams {
+	return throttleParams{capacity: 125.00, max: 90.00, min: 10.00}
+}
+
+func TestBuildISRHealthHealthy(t *testing.T) {
+	states := map[string]*kafkazk.TopicState{
+		"test_topic": &kafkazk.TopicState{
+			Partitions: map[string][]int{
+				"0": []int{1001, 1002},
+				"1": []int{1002, 1003},
+				"2": []int{1003, 1004},
+				"3": []int{1004, 1001},
+			},
+		},
+	}
+
+	isrs := map[string]kafkazk.TopicStateISR{
+		"test_topic": kafkazk.TopicStateISR{
+			"0": kafkazk.PartitionState{Leader: 1001, ISR: []int{1001, 1002}},
+			"1": kafkazk.PartitionState{Leader: 1002, ISR: []int{1002, 1003}},
+			"2": kafkazk.PartitionState{Leader: 1003, ISR: []int{1003, 1004}},
+			"3": kafkazk.PartitionState{Leader: 1004, ISR: []int{1004, 1001}},
+		},
+	}
+
+	report := buildISRHealth(states, isrs, testThrottleParams())
+
+	if report.partitions != 4 {
+		t.Errorf("Expected 4 partitions, got %d", report.partitions)
+	}
+
+	if report.underReplicated != 0 || report.offline != 0 {
+		t.Errorf("Expected 0 under-replicated and offline partitions, got %d, %d",
+			report.underReplicated, report.offline)
+	}
+
+	if len(report.brokers) != 4 {
+		t.Fatalf("Expected 4 brokers, got %d", len(report.brokers))
+	}
+
+	for _, b := range report.brokers {
+		if b.rate != 112.50 {
+			t.Errorf("Expected broker %d rate 112.50, got %.2f", b.id, b.rate)
+		}
+	}
+
+	if report.rate != 112.50 {
+		t.Errorf("Expected rate 112.50, got %.2f", report.rate)
+	}
+
+	if report.holdReassignments(5.00) {
+		t.Error("Expected reassignments to be allowed")
+	}
+}
+
+func TestBuildISRHealthUnderReplicated(t *testing.T) {
+	states := map[string]*kafkazk.TopicState{
+		"test_topic": &kafkazk.TopicState{
+			Partitions: map[string][]int{
+				"0": []int{1001, 1002},
+				"1": []int{1002, 1003},
+				"2": []int{1003, 1001},
+				"3": []int{1004, 1001},
+			},
+		},
+	}
+
+	isrs := map[string]kafkazk.TopicStateISR{
+		"test_topic": kafkazk.TopicStateISR{
+			// 1002 lagging.
+			"0": kafkazk.PartitionState{Leader: 1001, ISR: []int{1001}},
+			"1": kafkazk.PartitionState{Leader: 1002, ISR: []int{1002, 1003}},
+			// 1001 lagging.
+			"2": kafkazk.PartitionState{Leader: 1003, ISR: []int{1003}},
+			// Offline.
+			"3": kafkazk.PartitionState{Leader: -1, ISR: []int{}},
+		},
+	}
+
+	report := buildISRHealth(states, isrs, testThrottleParams())
+
+	if report.underReplicated != 3 {
+		t.Errorf("Expected 3 under-replicated partitions, got %d", report.underReplicated)
+	}
+
+	if report.offline != 1 {
+		t.Errorf("Expected 1 offline partition, got %d", report.offline)
+	}
+
+	if p := report.urpPercent(); p != 75.00 {
+		t.Errorf("Expected under-replicated percent 75.00, got %.2f", p)
+	}
+
+	// Broker ID -> [replicas, lagging, sourcing], rate.
+	expected := map[int][3]int{
+		1001: [3]int{3, 2, 1},
+		1002: [3]int{2, 1, 0},
+		1003: [3]int{2, 0, 1},
+		1004: [3]int{1, 1, 0},
+	}
+
+	expectedRate := map[int]float64{
+		1001: 10.00,
+		1002: 56.25,
+		1003: 56.25,
+		1004: 10.00,
+	}
+
+	if len(report.brokers) != len(expected) {
+		t.Fatalf("Expected %d brokers, got %d", len(expected), len(report.brokers))
+	}
+
+	for _, b := range report.brokers {
+		got := [3]int{b.replicas, b.lagging, b.sourcing}
+		if got != expected[b.id] {
+			t.Errorf("Expected broker %d counts %v, got %v", b.id, expected[b.id], got)
+		}
+
+		if b.rate != expectedRate[b.id] {
+			t.Errorf("Expected broker %d rate %.2f, got %.2f", b.id, expectedRate[b.id], b.rate)
+		}
+	}
+
+	if report.rate != 10.00 {
+		t.Errorf("Expected rate 10.00, got %.2f", report.rate)
+	}
+
+	if !report.holdReassignments(5.00) {
+		t.Error("Expected reassignments to be held")
+	}
+
+	// Without the offline partition, the
+	// under-replicated threshold applies.
+	report.offline = 0
+	if report.holdReassignments(80.00) {
+		t.Error("Expected reassignments to be allowed below the threshold")
+	}
+}
+
+func TestBuildISRHealthNoReplicas(t *testing.T) {
+	states := map[string]*kafkazk.TopicState{
+		"empty_topic": &kafkazk.TopicState{Partitions: map[string][]int{}},
+		"test_topic": &kafkazk.TopicState{
+			Partitions: map[string][]int{
+				"0": []int{1001, 1002},
+			},
+		},
+	}
+
+	isrs := map[string]kafkazk.TopicStateISR{
+		"empty_topic": kafkazk.TopicStateISR{},
+		// 1003 still leads the partition being moved off of it.
+		"test_topic": kafkazk.TopicStateISR{
+			"0": kafkazk.PartitionState{Leader: 1003, ISR: []int{1003}},
+		},
+	}
+
+	report := buildISRHealth(states, isrs, testThrottleParams())
+
+	if report.partitions != 1 {
+		t.Errorf("Expected 1 partition, got %d", report.partitions)
+	}
+
+	for _, b := range report.brokers {
+		if b.rate != 10.00 {
+			t.Errorf("Expected broker %d rate 10.00, got %.2f", b.id, b.rate)
+		}
+	}
+
+	if report.rate != 10.00 {
+		t.Errorf("Expected rate 10.00, got %.2f", report.rate)
+	}
+
+	// No partitions.
+	report = buildISRHealth(map[string]*kafkazk.TopicState{"empty_topic": states["empty_topic"]}, isrs, testThrottleParams())
+	if report.rate != 0 || report.urpPercent() != 0 {
+		t.Errorf("Expected rate and under-replicated percent 0, got %.2f, %.2f", report.rate, report.urpPercent())
+	}
+}
@@ -0,0 +1,210 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/DataDog/kafka-kit/kafkazk"
+
+	"github.com/spf13/cobra"
+)
+
+// reassignmentLockName is the lock znode name under the
+// --zk-metrics-prefix held while writing reassignment maps.
+const reassignmentLockName = "reassignment_lock"
+
+// zkLock is a distributed lock implemented as an ephemeral znode. Creating
+// the znode fails if it exists, so only one owner can hold the lock, and
+// ZooKeeper removes it should the holder's session end without releasing
+// it. The holder and a lease expiry are stored as data; the lease is
+// refreshed by the holder and used to identify a holder that has stalled.
+type zkLock struct {
+	zk    kafkazk.Handler
+	path  string
+	owner string
+	ttl   time.Duration
+}
+
+// lockLease is the data stored at the lock znode.
+type lockLease struct {
+	Owner   string `json:"owner"`
+	Expires int64  `json:"expires"`
+}
+
+// lockOwner returns the lock owner
+// name for this topicmappr instance.
+func lockOwner() string {
+	host, _ := os.Hostname()
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
+
+// acquire acquires the lock if it's free, or refreshes the
+// lease if it's already held by the caller. Whether the lock
+// is held is returned.
+func (l *zkLock) acquire() (bool, error) {
+	lease := lockLease{
+		Owner:   l.owner,
+		Expires: time.Now().Add(l.ttl).Unix(),
+	}
+
+	data, _ := json.Marshal(lease)
+
+	err := l.zk.CreateEphemeral(l.path, string(data))
+	if _, ok := err.(kafkazk.ErrNoNode); ok {
+		// The parent znode doesn't exist yet;
+		// create it and try again.
+		if err = l.createParent(); err == nil {
+			err = l.zk.CreateEphemeral(l.path, string(data))
+		}
+	}
+
+	switch err.(type) {
+	case nil:
+		return true, nil
+	case kafkazk.ErrNodeExists:
+	default:
+		return false, err
+	}
+
+	// The lock exists; check if it's ours.
+	current, err := l.lease()
+	switch err.(type) {
+	case nil:
+	case kafkazk.ErrNoNode:
+		// Released since the create was attempted.
+		return false, nil
+	default:
+		return false, err
+	}
+
+	if current.Owner != l.owner {
+		return false, nil
+	}
+
+	// Only the holder's session can hold the
+	// znode, so refreshing the lease is safe.
+	if err := l.zk.Set(l.path, string(data)); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// createParent creates the parent znode of the lock if it
+// doesn't exist. Only the immediate parent is created.
+func (l *zkLock) createParent() error {
+	parent := path.Dir(l.path)
+
+	switch err := l.zk.Create(parent, ""); err.(type) {
+	case nil, kafkazk.ErrNodeExists:
+		return nil
+	default:
+		return fmt.Errorf("parent znode %s must exist: %s", parent, err)
+	}
+}
+
+func (l *zkLock) release() error {
+	current, err := l.lease()
+	switch err.(type) {
+	case nil:
+	case kafkazk.ErrNoNode:
+		return nil
+	default:
+		return err
+	}
+
+	if current.Owner != l.owner {
+		return nil
+	}
+
+	return l.zk.Delete(l.path)
+}
+
+// lease returns the lease stored at the lock znode.
+func (l *zkLock) lease() (*lockLease, error) {
+	data, err := l.zk.Get(l.path)
+	if err != nil {
+		return nil, err
+	}
+
+	lease := &lockLease{}
+	if err := json.Unmarshal(data, lease); err != nil {
+		return nil, fmt.Errorf("Error parsing lock data: %s", err)
+	}
+
+	return lease, nil
+}
+
+// acquireExclusive acquires the lock, returning a func that releases it. If
+// the lock is held by another owner, an error identifying the holder is
+// returned.
+func (l *zkLock) acquireExclusive() (func(), error) {
+	held, err := l.acquire()
+	if err != nil {
+		return nil, fmt.Errorf("Error acquiring lock %s: %s", l.path, err)
+	}
+
+	if !held {
+		holder, err := l.lease()
+		if err != nil {
+			return nil, fmt.Errorf("Lock %s is held by another instance", l.path)
+		}
+
+		expires := time.Unix(holder.Expires, 0)
+		if time.Now().After(expires) {
+			return nil, fmt.Errorf("Lock %s is held by %s; its lease expired at %s and the holder may be stalled",
+				l.path, holder.Owner, expires.Format(time.RFC3339))
+		}
+
+		return nil, fmt.Errorf("Lock %s is held by %s until %s", l.path,
+			holder.Owner, expires.Format(time.RFC3339))
+	}
+
+	release := func() {
+		if err := l.release(); err != nil {
+			fmt.Fprintf(textOut, "\n[WARN] error releasing lock %s: %s\n", l.path, err)
+		}
+	}
+
+	return release, nil
+}
+
+// lockReassignments acquires the reassignment lock, if --lock-ttl is set,
+// returning a func that releases it. This prevents concurrent topicmappr
+// runs from producing conflicting reassignments. The release is also
+// registered as an exit hook so that the lock is released on every exit
+// path; it's otherwise removed by ZooKeeper once the session ends.
+func lockReassignments(cmd *cobra.Command, zk kafkazk.Handler) (func(), error) {
+	ttl, _ := cmd.Flags().GetDuration("lock-ttl")
+
+	switch {
+	case ttl < 0:
+		return nil, fmt.Errorf("--lock-ttl must be 0 or greater")
+	case ttl == 0:
+		return func() {}, nil
+	}
+
+	mp := cmd.Flag("zk-metrics-prefix").Value.String()
+
+	l := &zkLock{
+		zk:    zk,
+		path:  fmt.Sprintf("/%s/%s", mp, reassignmentLockName),
+		owner: lockOwner(),
+		ttl:   ttl,
+	}
+
+	release, err := l.acquireExclusive()
+	if err != nil {
+		return nil, err
+	}
+
+	var once sync.Once
+	unlock := func() { once.Do(release) }
+	onExit(unlock)
+
+	return unlock, nil
+}
@@ -0,0 +1,289 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DataDog/kafka-kit/kafkazk"
+
+	"github.com/spf13/cobra"
+)
+
+// testLockZK is a kafkazk.Handler storing znode data in memory.
+// As with ZooKeeper, creating an existing znode fails. If
+// parents is set, creating a znode also requires that its
+// parent exists.
+type testLockZK struct {
+	kafkazk.Mock
+	sync.Mutex
+	data    map[string]string
+	parents bool
+}
+
+func (z *testLockZK) Create(p, d string) error {
+	return z.CreateEphemeral(p, d)
+}
+
+func (z *testLockZK) CreateEphemeral(p, d string) error {
+	z.Lock()
+	defer z.Unlock()
+
+	if _, exists := z.data[p]; exists {
+		return kafkazk.ErrNodeExists{}
+	}
+
+	if _, exists := z.data[path.Dir(p)]; z.parents && !exists && path.Dir(p) != "/" {
+		return kafkazk.ErrNoNode{}
+	}
+
+	z.data[p] = d
+	return nil
+}
+
+func (z *testLockZK) Get(p string) ([]byte, error) {
+	z.Lock()
+	defer z.Unlock()
+
+	d, exists := z.data[p]
+	if !exists {
+		return nil, kafkazk.ErrNoNode{}
+	}
+	return []byte(d), nil
+}
+
+func (z *testLockZK) Set(p, d string) error {
+	z.Lock()
+	defer z.Unlock()
+
+	z.data[p] = d
+	return nil
+}
+
+func (z *testLockZK) Delete(p string) error {
+	z.Lock()
+	defer z.Unlock()
+
+	delete(z.data, p)
+	return nil
+}
+
+func TestZKLock(t *testing.T) {
+	zk := &testLockZK{data: map[string]string{}}
+	path := "/topicmappr/autobalance_lock"
+
+	l1 := &zkLock{zk: zk, path: path, owner: "a", ttl: time.Minute}
+	l2 := &zkLock{zk: zk, path: path, owner: "b", ttl: time.Minute}
+
+	// Free lock.
+	if held, err := l1.acquire(); !held || err != nil {
+		t.Errorf("Expected lock acquired, got %t, %v", held, err)
+	}
+
+	// Refresh.
+	if held, _ := l1.acquire(); !held {
+		t.Error("Expected lock refreshed")
+	}
+
+	// Held by another.
+	if held, _ := l2.acquire(); held {
+		t.Error("Expected lock unavailable")
+	}
+
+	// Release by non-owner is a no-op.
+	l2.release()
+	if _, exists := zk.data[path]; !exists {
+		t.Error("Expected lock retained")
+	}
+
+	// An expired lease is held until
+	// the holder's session ends.
+	expired, _ := json.Marshal(lockLease{Owner: "a", Expires: time.Now().Add(-time.Second).Unix()})
+	zk.data[path] = string(expired)
+
+	if held, _ := l2.acquire(); held {
+		t.Error("Expected expired lock unavailable")
+	}
+
+	if _, err := l2.acquireExclusive(); err == nil || !strings.Contains(err.Error(), "expired") {
+		t.Errorf("Expected error reporting an expired lease, got '%v'", err)
+	}
+
+	// The holder's session ended.
+	delete(zk.data, path)
+
+	if held, _ := l2.acquire(); !held {
+		t.Error("Expected lock acquired")
+	}
+
+	l2.release()
+	if _, exists := zk.data[path]; exists {
+		t.Error("Expected lock released")
+	}
+}
+
+func TestZKLockContention(t *testing.T) {
+	zk := &testLockZK{data: map[string]string{}}
+	path := "/topicmappr/reassignment_lock"
+
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	errs := make([]error, 8)
+	releases := make([]func(), 8)
+
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			l := &zkLock{zk: zk, path: path, owner: fmt.Sprintf("owner-%d", i), ttl: time.Minute}
+			<-start
+			releases[i], errs[i] = l.acquireExclusive()
+		}(i)
+	}
+
+	close(start)
+	wg.Wait()
+
+	// Exactly one instance proceeds.
+	var holder int
+	var held int
+	for i, err := range errs {
+		if err == nil {
+			holder = i
+			held++
+		}
+	}
+
+	if held != 1 {
+		t.Fatalf("Expected 1 lock holder, got %d", held)
+	}
+
+	// Failures identify the holder.
+	owner := fmt.Sprintf("owner-%d", holder)
+	for i, err := range errs {
+		if i != holder && !strings.Contains(err.Error(), owner) {
+			t.Errorf("Expected error naming holder %s, got '%s'", owner, err)
+		}
+	}
+
+	// Released locks are available.
+	releases[holder]()
+
+	l := &zkLock{zk: zk, path: path, owner: "next", ttl: time.Minute}
+	release, err := l.acquireExclusive()
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	release()
+	if _, exists := zk.data[path]; exists {
+		t.Error("Expected lock released")
+	}
+}
+
+func TestZKLockParent(t *testing.T) {
+	zk := &testLockZK{data: map[string]string{}, parents: true}
+
+	// A missing parent is created.
+	l := &zkLock{zk: zk, path: "/topicmappr/reassignment_lock", owner: "a", ttl: time.Minute}
+
+	held, err := l.acquire()
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if !held {
+		t.Error("Expected lock to be acquired")
+	}
+
+	if _, exists := zk.data["/topicmappr"]; !exists {
+		t.Error("Expected parent znode /topicmappr to be created")
+	}
+
+	// Only the immediate parent is created.
+	l = &zkLock{zk: zk, path: "/missing/topicmappr/reassignment_lock", owner: "a", ttl: time.Minute}
+
+	_, err = l.acquire()
+	if err == nil || !strings.Contains(err.Error(), "/missing/topicmappr must exist") {
+		t.Errorf("Expected parent znode error, got '%v'", err)
+	}
+}
+
+func TestLockReassignments(t *testing.T) {
+	zk := &testLockZK{data: map[string]string{}}
+	path := "/topicmappr/reassignment_lock"
+
+	cmd := &cobra.Command{}
+	cmd.Flags().Duration("lock-ttl", 0, "")
+	cmd.Flags().String("zk-metrics-prefix", "topicmappr", "")
+
+	// Disabled by default.
+	release, err := lockReassignments(cmd, zk)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	release()
+	if len(zk.data) != 0 {
+		t.Errorf("Expected no lock, got %v", zk.data)
+	}
+
+	// Held by another instance.
+	held, _ := json.Marshal(lockLease{Owner: "other", Expires: time.Now().Add(time.Minute).Unix()})
+	zk.data[path] = string(held)
+
+	cmd.Flags().Set("lock-ttl", "5m")
+
+	if _, err := lockReassignments(cmd, zk); err == nil || !strings.Contains(err.Error(), "other") {
+		t.Errorf("Expected error naming holder 'other', got '%v'", err)
+	}
+
+	// Free lock.
+	delete(zk.data, path)
+
+	defer func(h []func()) { exitHooks = h }(exitHooks)
+
+	release, err = lockReassignments(cmd, zk)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	lease := &lockLease{}
+	json.Unmarshal([]byte(zk.data[path]), lease)
+	if lease.Owner != lockOwner() {
+		t.Errorf("Expected owner %s, got %s", lockOwner(), lease.Owner)
+	}
+
+	// The release is registered as an exit hook.
+	if len(exitHooks) == 0 {
+		t.Fatal("Expected an exit hook")
+	}
+
+	exitHooks[len(exitHooks)-1]()
+	if _, exists := zk.data[path]; exists {
+		t.Error("Expected lock released")
+	}
+
+	// Releasing again is a no-op, leaving
+	// a subsequent acquisition in place.
+	reacquired, _ := json.Marshal(lease)
+	zk.data[path] = string(reacquired)
+
+	release()
+	if _, exists := zk.data[path]; !exists {
+		t.Error("Expected lock retained")
+	}
+
+	delete(zk.data, path)
+
+	// Negative TTL.
+	cmd.Flags().Set("lock-ttl", "-1s")
+
+	if _, err := lockReassignments(cmd, zk); err == nil {
+		t.Error("Expected error")
+	}
+}
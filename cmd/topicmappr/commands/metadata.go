@@ -1,8 +1,12 @@
 package commands
 
 import (
+	"encoding/json"
 	"fmt"
-	"os"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/DataDog/kafka-kit/kafkazk"
@@ -13,15 +17,15 @@ import (
 func checkMetaAge(cmd *cobra.Command, zk kafkazk.Handler) {
 	age, err := zk.MaxMetaAge()
 	if err != nil {
-		fmt.Printf("Error fetching metrics metadata: %s\n", err)
-		os.Exit(1)
+		fmt.Fprintf(textOut, "Error fetching metrics metadata: %s\n", err)
+		exit(1)
 	}
 
 	tol, _ := cmd.Flags().GetInt("metrics-age")
 
 	if age > time.Duration(tol)*time.Minute {
-		fmt.Printf("Metrics metadata is older than allowed: %s\n", age)
-		os.Exit(1)
+		fmt.Fprintf(textOut, "Metrics metadata is older than allowed: %s\n", age)
+		exit(1)
 	}
 }
 
@@ -30,7 +34,9 @@ func checkMetaAge(cmd *cobra.Command, zk kafkazk.Handler) {
 // persisted in ZooKeeper (via an external mechanism*) can be merged
 // into the metadata.
 func getBrokerMeta(cmd *cobra.Command, zk kafkazk.Handler, m bool) kafkazk.BrokerMetaMap {
-	brokerMeta, errs := zk.GetAllBrokerMeta(m)
+	timeout, _ := cmd.Flags().GetDuration("metrics-fetch-timeout")
+
+	brokerMeta, errs := fetchBrokerMeta(zk, m, timeout, Config.brokers)
 	// If no data is returned, report and exit.
 	// Otherwise, it's possible that complete
 	// data for a few brokers wasn't returned.
@@ -38,14 +44,104 @@ func getBrokerMeta(cmd *cobra.Command, zk kafkazk.Handler, m bool) kafkazk.Broke
 	// brokers that matter are missing metrics.
 	if errs != nil && brokerMeta == nil {
 		for _, e := range errs {
-			fmt.Println(e)
+			fmt.Fprintln(textOut, e)
 		}
-		os.Exit(1)
+		exit(1)
 	}
 
 	return brokerMeta
 }
 
+// fetchBrokerMeta calls GetAllBrokerMeta on the provided kafkazk.Handler,
+// returning an error if the call doesn't complete within the timeout. The
+// error names the expected brokers, whose metadata was never received.
+// A timeout of 0 waits indefinitely.
+func fetchBrokerMeta(zk kafkazk.Handler, m bool, timeout time.Duration, expected []int) (kafkazk.BrokerMetaMap, []error) {
+	if timeout <= 0 {
+		return zk.GetAllBrokerMeta(m)
+	}
+
+	type result struct {
+		brokerMeta kafkazk.BrokerMetaMap
+		errs       []error
+	}
+
+	// Buffered so that a call completing after
+	// the timeout doesn't block the sender.
+	done := make(chan result, 1)
+
+	go func() {
+		bm, errs := zk.GetAllBrokerMeta(m)
+		done <- result{brokerMeta: bm, errs: errs}
+	}()
+
+	select {
+	case r := <-done:
+		return r.brokerMeta, r.errs
+	case <-time.After(timeout):
+		var err error
+		if len(expected) > 0 {
+			ids := make([]int, len(expected))
+			copy(ids, expected)
+			sort.Ints(ids)
+			err = fmt.Errorf("Timed out after %s fetching broker metadata; no metadata received for brokers %v", timeout, ids)
+		} else {
+			err = fmt.Errorf("Timed out after %s fetching broker metadata; no metadata received for any broker", timeout)
+		}
+
+		return nil, []error{err}
+	}
+}
+
+// setAntiAffinityGroups assigns brokers to anti-affinity groups according
+// to the registry tag keys specified in --anti-affinity-tags. Tags are read
+// from the registry tag storage in ZooKeeper under --zk-tags-prefix.
+func setAntiAffinityGroups(cmd *cobra.Command, zk kafkazk.Handler, bm kafkazk.BrokerMetaMap) {
+	at, _ := cmd.Flags().GetString("anti-affinity-tags")
+	if at == "" {
+		return
+	}
+
+	var keys []string
+	for _, k := range strings.Split(at, ",") {
+		if k = strings.TrimSpace(k); k != "" {
+			keys = append(keys, k)
+		}
+	}
+
+	prefix, _ := cmd.Flags().GetString("zk-tags-prefix")
+	tags := map[int]map[string]string{}
+
+	for id := range bm {
+		data, err := zk.Get(fmt.Sprintf("/%s/broker/%d", prefix, id))
+		if err != nil {
+			switch err.(type) {
+			// The broker has no tags.
+			case kafkazk.ErrNoNode:
+				continue
+			default:
+				fmt.Fprintf(textOut, "Error fetching tags for broker %d: %s\n", id, err)
+				exit(1)
+			}
+		}
+
+		if len(data) == 0 {
+			continue
+		}
+
+		t := map[string]string{}
+		if err := json.Unmarshal(data, &t); err != nil {
+			fmt.Fprintf(textOut, "Error parsing tags for broker %d: %s\n", id, err)
+			exit(1)
+		}
+
+		tags[id] = t
+	}
+
+	bm.SetTags(tags)
+	bm.SetAntiAffinityGroups(tags, keys)
+}
+
 // ensureBrokerMetrics takes a map of reference brokers and
 // a map of discovered broker metadata. Any non-missing brokers
 // in the broker map must be present in the broker metadata map
@@ -55,8 +151,8 @@ func ensureBrokerMetrics(cmd *cobra.Command, bm kafkazk.BrokerMap, bmm kafkazk.B
 		// Missing brokers won't even
 		// be found in the brokerMeta.
 		if !b.Missing && id != 0 && bmm[id].MetricsIncomplete {
-			fmt.Printf("Metrics not found for broker %d\n", id)
-			os.Exit(1)
+			fmt.Fprintf(textOut, "Metrics not found for broker %d\n", id)
+			exit(1)
 		}
 	}
 }
@@ -64,13 +160,105 @@ func ensureBrokerMetrics(cmd *cobra.Command, bm kafkazk.BrokerMap, bmm kafkazk.B
 // getPartitionMeta returns a map of topic, partition metadata
 // persisted in ZooKeeper (via an external mechanism*). This is
 // primarily partition size metrics data used for the storage
-// placement strategy.
+// placement strategy. If a file is specified as the
+// --partition-size-source, sizes are read from the file instead.
 func getPartitionMeta(cmd *cobra.Command, zk kafkazk.Handler) kafkazk.PartitionMetaMap {
-	partitionMeta, err := zk.GetAllPartitionMeta()
+	var partitionMeta kafkazk.PartitionMetaMap
+	var err error
+
+	switch src := partitionSizeSource(cmd); src {
+	case "zk":
+		partitionMeta, err = zk.GetAllPartitionMeta()
+	default:
+		partitionMeta, err = partitionMetaFromFile(src)
+	}
+
 	if err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+		fmt.Fprintln(textOut, err)
+		exit(1)
 	}
 
 	return partitionMeta
 }
+
+// partitionSizeSource returns the --partition-size-source
+// value, defaulting to "zk" for commands without the flag.
+func partitionSizeSource(cmd *cobra.Command) string {
+	if f := cmd.Flag("partition-size-source"); f != nil && f.Value.String() != "" {
+		return f.Value.String()
+	}
+
+	return "zk"
+}
+
+// partitionMetaFromFile reads a JSON file at path p mapping
+// "topic:partition" keys to partition sizes in bytes and
+// returns a PartitionMetaMap.
+func partitionMetaFromFile(p string) (kafkazk.PartitionMetaMap, error) {
+	data, err := ioutil.ReadFile(p)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading partition sizes: %s", err)
+	}
+
+	pmm, err := partitionMetaFromJSON(data)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading partition sizes from %s: %s", p, err)
+	}
+
+	return pmm, nil
+}
+
+// partitionMetaFromJSON unmarshals a JSON object mapping "topic:partition"
+// keys to partition sizes in bytes into a PartitionMetaMap.
+func partitionMetaFromJSON(data []byte) (kafkazk.PartitionMetaMap, error) {
+	sizes := map[string]float64{}
+	if err := json.Unmarshal(data, &sizes); err != nil {
+		return nil, err
+	}
+
+	pmm := kafkazk.NewPartitionMetaMap()
+
+	for k, size := range sizes {
+		i := strings.LastIndex(k, ":")
+		if i < 1 {
+			return nil, fmt.Errorf("invalid key '%s'; expected topic:partition", k)
+		}
+
+		p, err := strconv.Atoi(k[i+1:])
+		if err != nil || p < 0 {
+			return nil, fmt.Errorf("invalid partition in key '%s'", k)
+		}
+
+		if size < 0 {
+			return nil, fmt.Errorf("invalid size for '%s': %.0f", k, size)
+		}
+
+		topic := k[:i]
+		if _, exists := pmm[topic]; !exists {
+			pmm[topic] = map[int]*kafkazk.PartitionMeta{}
+		}
+
+		pmm[topic][p] = &kafkazk.PartitionMeta{Size: size}
+	}
+
+	return pmm, nil
+}
+
+// fillMissingSourcedPartitionMeta, if partition sizes are read from a file
+// via --partition-size-source, adds a zero size to the PartitionMetaMap for
+// any partitions in the PartitionMap missing from the file. A warning is
+// returned for each filled partition.
+func fillMissingSourcedPartitionMeta(cmd *cobra.Command, pm *kafkazk.PartitionMap, pmm kafkazk.PartitionMetaMap) errors {
+	src := partitionSizeSource(cmd)
+	if src == "zk" || pmm == nil {
+		return nil
+	}
+
+	var warns errors
+	for _, p := range pmm.FillMissing(pm, 0) {
+		warns = append(warns, fmt.Errorf("%s p%d: partition size not found in %s, assuming 0",
+			p.Topic, p.Partition, src))
+	}
+
+	return warns
+}
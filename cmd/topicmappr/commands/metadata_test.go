@@ -0,0 +1,218 @@
+package commands
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DataDog/kafka-kit/kafkazk"
+
+	"github.com/spf13/cobra"
+)
+
+// slowZK is a kafkazk.Mock that delays
+// GetAllBrokerMeta calls by delay.
+type slowZK struct {
+	kafkazk.Mock
+	delay time.Duration
+}
+
+func (zk *slowZK) GetAllBrokerMeta(withMetrics bool) (kafkazk.BrokerMetaMap, []error) {
+	time.Sleep(zk.delay)
+	return zk.Mock.GetAllBrokerMeta(withMetrics)
+}
+
+func TestFetchBrokerMeta(t *testing.T) {
+	zk := &slowZK{delay: 10 * time.Millisecond}
+
+	tests := map[int]time.Duration{
+		// Completes within the timeout.
+		0: time.Second,
+		// No timeout.
+		1: 0,
+	}
+
+	for i, timeout := range tests {
+		bm, errs := fetchBrokerMeta(zk, true, timeout, []int{1001, 1002})
+		if errs != nil {
+			t.Errorf("[test %d] Unexpected errors: %v", i, errs)
+			continue
+		}
+
+		if len(bm) != 5 {
+			t.Errorf("[test %d] Expected 5 brokers, got %d", i, len(bm))
+		}
+
+		if bm[1001].StorageFree != 2000.00 {
+			t.Errorf("[test %d] Expected storage free 2000.00, got %.2f", i, bm[1001].StorageFree)
+		}
+	}
+}
+
+func TestFetchBrokerMetaTimeout(t *testing.T) {
+	zk := &slowZK{delay: time.Second}
+
+	start := time.Now()
+	bm, errs := fetchBrokerMeta(zk, true, 20*time.Millisecond, []int{1003, 1001})
+
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Expected fetch to time out, took %s", elapsed)
+	}
+
+	if bm != nil {
+		t.Errorf("Expected nil BrokerMetaMap, got %v", bm)
+	}
+
+	if len(errs) != 1 {
+		t.Fatalf("Expected 1 error, got %d", len(errs))
+	}
+
+	if !strings.Contains(errs[0].Error(), "[1001 1003]") {
+		t.Errorf("Expected error naming brokers [1001 1003], got '%s'", errs[0])
+	}
+
+	// Without expected brokers.
+	_, errs = fetchBrokerMeta(zk, true, 20*time.Millisecond, nil)
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "any broker") {
+		t.Errorf("Expected a timeout error, got %v", errs)
+	}
+}
+
+func TestGetPartitionMetaSource(t *testing.T) {
+	dir, err := ioutil.TempDir("", "topicmappr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "sizes.json")
+	data := `{"test_topic:0": 2048, "test_topic:1": 4096, "other_topic:0": 10}`
+	if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	zk := &kafkazk.Mock{}
+	zkMeta, _ := zk.GetAllPartitionMeta()
+
+	// Source -> expected test_topic p0 and p1 sizes.
+	tests := map[string][2]float64{
+		"zk": {zkMeta["test_topic"][0].Size, zkMeta["test_topic"][1].Size},
+		path: {2048, 4096},
+	}
+
+	for src, expected := range tests {
+		cmd := &cobra.Command{}
+		cmd.Flags().String("partition-size-source", "zk", "")
+		cmd.Flags().Set("partition-size-source", src)
+
+		pmm := getPartitionMeta(cmd, zk)
+
+		for i, size := range expected {
+			s, err := pmm.Size(kafkazk.Partition{Topic: "test_topic", Partition: i})
+			if err != nil {
+				t.Errorf("[%s] Unexpected error: %s", src, err)
+				continue
+			}
+
+			if s != size {
+				t.Errorf("[%s] Expected p%d size %.2f, got %.2f", src, i, size, s)
+			}
+		}
+	}
+}
+
+// testPartition is a topic, partition
+// pair usable as a map key.
+type testPartition struct {
+	topic     string
+	partition int
+}
+
+func TestPartitionMetaFromJSON(t *testing.T) {
+	pmm, err := partitionMetaFromJSON([]byte(`{"a:0": 100, "a:1": 200, "b.c-d:12": 0}`))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	expected := map[testPartition]float64{
+		{"a", 0}:      100,
+		{"a", 1}:      200,
+		{"b.c-d", 12}: 0,
+	}
+
+	for p, size := range expected {
+		s, err := pmm.Size(kafkazk.Partition{Topic: p.topic, Partition: p.partition})
+		if err != nil || s != size {
+			t.Errorf("Expected %s p%d size %.2f, got %.2f (%v)", p.topic, p.partition, size, s, err)
+		}
+	}
+
+	invalid := []string{
+		`[1, 2]`,
+		`{"a": 100}`,
+		`{":0": 100}`,
+		`{"a:x": 100}`,
+		`{"a:-1": 100}`,
+		`{"a:0": -100}`,
+	}
+
+	for _, data := range invalid {
+		if _, err := partitionMetaFromJSON([]byte(data)); err == nil {
+			t.Errorf("[%s] Expected error", data)
+		}
+	}
+}
+
+func TestFillMissingSourcedPartitionMeta(t *testing.T) {
+	pm, _ := kafkazk.PartitionMapFromString(`{"version":1,"partitions":[
+    {"topic":"a","partition":0,"replicas":[1001,1002]},
+    {"topic":"a","partition":1,"replicas":[1002,1003]},
+    {"topic":"b","partition":0,"replicas":[1001,1003]}]}`)
+
+	newPMM := func() kafkazk.PartitionMetaMap {
+		pmm, _ := partitionMetaFromJSON([]byte(`{"a:0": 100, "c:0": 300}`))
+		return pmm
+	}
+
+	cmd := &cobra.Command{}
+	cmd.Flags().String("partition-size-source", "zk", "")
+
+	// Not applied to ZooKeeper sourced sizes.
+	pmm := newPMM()
+	if warns := fillMissingSourcedPartitionMeta(cmd, pm, pmm); warns != nil {
+		t.Errorf("Unexpected warnings: %v", warns)
+	}
+
+	if _, err := pmm.Size(kafkazk.Partition{Topic: "a", Partition: 1}); err == nil {
+		t.Error("Expected a p1 to remain missing")
+	}
+
+	// Missing partitions are zero filled with a warning.
+	cmd.Flags().Set("partition-size-source", "sizes.json")
+
+	pmm = newPMM()
+	warns := fillMissingSourcedPartitionMeta(cmd, pm, pmm)
+	if len(warns) != 2 {
+		t.Fatalf("Expected 2 warnings, got %v", warns)
+	}
+
+	if !strings.Contains(warns[0].Error(), "a p1: partition size not found in sizes.json") {
+		t.Errorf("Unexpected warning: %s", warns[0])
+	}
+
+	expected := map[testPartition]float64{
+		{"a", 0}: 100,
+		{"a", 1}: 0,
+		{"b", 0}: 0,
+	}
+
+	for p, size := range expected {
+		s, err := pmm.Size(kafkazk.Partition{Topic: p.topic, Partition: p.partition})
+		if err != nil || s != size {
+			t.Errorf("Expected %s p%d size %.2f, got %.2f (%v)", p.topic, p.partition, size, s, err)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package commands
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/DataDog/kafka-kit/kafkazk"
+
+	"github.com/spf13/cobra"
+)
+
+var orphansCmd = &cobra.Command{
+	Use:   "orphans",
+	Short: "Report partitions with replicas assigned to unregistered brokers",
+	Long: `orphans scans the live partition assignments for all topics matching
+--topics and reports replicas assigned to broker IDs that aren't registered
+in ZooKeeper. Optionally, a repair plan can be generated with --repair, which
+replaces unregistered brokers with the least utilized registered brokers
+(scoped to --brokers, if provided).`,
+	Run: orphans,
+}
+
+func init() {
+	rootCmd.AddCommand(orphansCmd)
+
+	orphansCmd.Flags().String("topics", "", "Scan topics (comma delim. list) by lookup in ZooKeeper")
+	orphansCmd.Flags().Bool("repair", false, "Generate a repair plan for orphaned partitions")
+	orphansCmd.Flags().String("brokers", "", "Broker list to scope repair placements to (defaults to all registered brokers)")
+	orphansCmd.Flags().String("out-path", "", "Path to write output map files to")
+	orphansCmd.Flags().String("out-file", "", "If defined, write a combined map of all topics to a file")
+	orphansCmd.Flags().String("output-format", "json", "Output map format: [json, yaml]")
+
+	// Required.
+	orphansCmd.MarkFlagRequired("topics")
+}
+
+func orphans(cmd *cobra.Command, _ []string) {
+	bootstrap(cmd)
+
+	// ZooKeeper init.
+	zk, err := initZooKeeper(cmd)
+	if err != nil {
+		fmt.Fprintln(textOut, err)
+		exit(1)
+	}
+
+	defer zk.Close()
+
+	brokerMeta := getBrokerMeta(cmd, zk, false)
+
+	partitionMap, err := kafkazk.PartitionMapFromZK(Config.topics, zk)
+	if err != nil {
+		fmt.Fprintln(textOut, err)
+		exit(1)
+	}
+
+	printTopics(partitionMap)
+
+	orphaned := partitionMap.Orphans(brokerMeta)
+	printOrphans(orphaned, brokerMeta)
+
+	if len(orphaned.Partitions) == 0 {
+		return
+	}
+
+	if r, _ := cmd.Flags().GetBool("repair"); !r {
+		return
+	}
+
+	// Default the repair broker list
+	// to all registered brokers.
+	if len(Config.brokers) == 0 {
+		for id := range brokerMeta {
+			Config.brokers = append(Config.brokers, id)
+		}
+		sort.Ints(Config.brokers)
+	}
+
+	// The BrokerMap is built from the complete partition map
+	// so that use counts reflect all partitions of the matched
+	// topics, while only orphaned partitions are rebuilt.
+	fmt.Fprintf(textOut, "\nBroker change summary:\n")
+	brokers := kafkazk.BrokerMapFromPartitionMap(partitionMap, brokerMeta, false)
+	_, msgs := brokers.UpdateSorted(Config.brokers, brokerMeta)
+	for _, m := range msgs {
+		fmt.Fprintf(textOut, "%s%s\n", indent, m)
+	}
+
+	repaired, errs := orphaned.Rebuild(kafkazk.RebuildParams{
+		BM:       brokers,
+		Strategy: "count",
+	})
+
+	printMapChanges(orphaned, repaired)
+
+	handleOverridableErrs(cmd, errs)
+
+	writeMaps(cmd, repaired)
+}
+
+// printOrphans takes a PartitionMap of orphaned partitions and
+// a BrokerMetaMap and prints the unregistered broker IDs referenced
+// by each partition.
+func printOrphans(pm *kafkazk.PartitionMap, bm kafkazk.BrokerMetaMap) {
+	fmt.Fprintln(textOut, "\nOrphaned partitions:")
+
+	if len(pm.Partitions) == 0 {
+		fmt.Fprintf(textOut, "%s[none]\n", indent)
+		return
+	}
+
+	for _, p := range pm.Partitions {
+		var unknown []int
+		for _, id := range p.Replicas {
+			if _, exists := bm[id]; !exists {
+				unknown = append(unknown, id)
+			}
+		}
+
+		fmt.Fprintf(textOut, "%s%s p%d: %v (unregistered: %v)\n",
+			indent, p.Topic, p.Partition, p.Replicas, unknown)
+	}
+}
@@ -2,18 +2,131 @@ package commands
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
+	"io"
 	"math"
 	"os"
+	"reflect"
 	"sort"
+	"strings"
 
 	"github.com/DataDog/kafka-kit/kafkazk"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/spf13/cobra"
 )
 
 type errors []error
 
+// textOut receives human-readable output. It's stdout in text mode
+// and a *stderrText with --stdout-format json.
+var textOut io.Writer = os.Stdout
+
+// jsonOutput is the structured document written to stdout
+// when --stdout-format is json. It's nil in text mode.
+var jsonOutput *stdoutJSON
+
+// stdoutJSON holds the output fields of a run.
+type stdoutJSON struct {
+	PartitionMap *kafkazk.PartitionMap `json:"partition_map"`
+	BrokerStatus *kafkazk.BrokerStatus `json:"broker_status,omitempty"`
+	// Messages holds broker change messages.
+	Messages []string `json:"messages"`
+	// Warnings holds overridable errors, such as
+	// constraint violations, and assumed partition sizes.
+	Warnings []string `json:"warnings"`
+	// DeferredMoves is the number of replica
+	// moves deferred by --max-moves.
+	DeferredMoves int `json:"deferred_moves,omitempty"`
+	// Error describes the failure of a run
+	// that exited with a non-zero status.
+	Error string `json:"error,omitempty"`
+
+	out     io.Writer
+	written bool
+}
+
+// stderrText is the text output writer with JSON output enabled. Text is
+// written to stderr and retained so that the error of a failed run, the
+// last paragraph of text output, can be included in the JSON document.
+type stderrText struct {
+	w   io.Writer
+	buf bytes.Buffer
+}
+
+func (s *stderrText) Write(p []byte) (int, error) {
+	s.buf.Write(p)
+	return s.w.Write(p)
+}
+
+// lastParagraph returns the text output following the
+// last blank line, less any "[ERROR]" prefix.
+func (s *stderrText) lastParagraph() string {
+	text := strings.TrimSpace(s.buf.String())
+	if i := strings.LastIndex(text, "\n\n"); i >= 0 {
+		text = strings.TrimSpace(text[i:])
+	}
+
+	return strings.TrimPrefix(text, "[ERROR] ")
+}
+
+// initJSONOutput enables JSON output, written to w. Text
+// output is written to stderr so that w holds only the
+// JSON document.
+func initJSONOutput(w io.Writer) {
+	jsonOutput = &stdoutJSON{
+		Messages: []string{},
+		Warnings: []string{},
+		out:      w,
+	}
+
+	textOut = &stderrText{w: os.Stderr}
+}
+
+// writeJSONOutput writes the JSON output document with the provided
+// PartitionMap and BrokerStatus, if JSON output is enabled. This is
+// called once all maps have been written.
+func writeJSONOutput(pm *kafkazk.PartitionMap, bs *kafkazk.BrokerStatus) {
+	if jsonOutput == nil {
+		return
+	}
+
+	jsonOutput.PartitionMap = pm
+	jsonOutput.BrokerStatus = bs
+
+	out, err := json.MarshalIndent(jsonOutput, "", "  ")
+	if err != nil {
+		jsonOutput.written = true
+		fmt.Fprintln(textOut, err)
+		exit(1)
+	}
+
+	fmt.Fprintf(jsonOutput.out, "%s\n", out)
+	jsonOutput.written = true
+}
+
+// writeJSONOnExit writes the JSON output document for a run exiting with the
+// status code, if JSON output is enabled and the document wasn't already
+// written. For non-zero codes, the document holds the error that was
+// printed ahead of exiting and no PartitionMap.
+func writeJSONOnExit(code int) {
+	if jsonOutput == nil || jsonOutput.written {
+		return
+	}
+
+	if code != 0 {
+		jsonOutput.Error = fmt.Sprintf("exited with status %d", code)
+		if t, ok := textOut.(*stderrText); ok {
+			if p := t.lastParagraph(); p != "" {
+				jsonOutput.Error = p
+			}
+		}
+	}
+
+	writeJSONOutput(nil, nil)
+}
+
 func (e errors) Len() int           { return len(e) }
 func (e errors) Less(i, j int) bool { return e[i].Error() < e[j].Error() }
 func (e errors) Swap(i, j int)      { e[i], e[j] = e[j], e[i] }
@@ -26,9 +139,9 @@ func printTopics(pm *kafkazk.PartitionMap) {
 		topics[p.Topic] = struct{}{}
 	}
 
-	fmt.Printf("\nTopics:\n")
+	fmt.Fprintf(textOut, "\nTopics:\n")
 	for t := range topics {
-		fmt.Printf("%s%s\n", indent, t)
+		fmt.Fprintf(textOut, "%s%s\n", indent, t)
 	}
 }
 
@@ -41,18 +154,18 @@ func printMapChanges(pm1, pm2 *kafkazk.PartitionMap) {
 		t1, t2 := pm1.Partitions[i].Topic, pm2.Partitions[i].Topic
 		p1, p2 := pm1.Partitions[i].Partition, pm2.Partitions[i].Partition
 		if t1 != t2 || p1 != p2 {
-			fmt.Println("Unexpected partition map order")
-			os.Exit(1)
+			fmt.Fprintln(textOut, "Unexpected partition map order")
+			exit(1)
 		}
 	}
 
 	// Get a status string of what's changed.
-	fmt.Println("\nPartition map changes:")
+	fmt.Fprintln(textOut, "\nPartition map changes:")
 	for i := range pm1.Partitions {
 		change := whatChanged(pm1.Partitions[i].Replicas,
 			pm2.Partitions[i].Replicas)
 
-		fmt.Printf("%s%s p%d: %v -> %v %s\n",
+		fmt.Fprintf(textOut, "%s%s p%d: %v -> %v %s\n",
 			indent,
 			pm1.Partitions[i].Topic,
 			pm1.Partitions[i].Partition,
@@ -62,25 +175,84 @@ func printMapChanges(pm1, pm2 *kafkazk.PartitionMap) {
 	}
 }
 
+// partitionMoves takes the original input PartitionMap and the final output
+// PartitionMap and returns a "<topic>:<partition> [before] -> [after]" string
+// for each partition where the replica list changed, in input map order.
+func partitionMoves(pm1, pm2 *kafkazk.PartitionMap) []string {
+	after := map[string]map[int][]int{}
+	for _, p := range pm2.Partitions {
+		if _, exists := after[p.Topic]; !exists {
+			after[p.Topic] = map[int][]int{}
+		}
+		after[p.Topic][p.Partition] = p.Replicas
+	}
+
+	moves := []string{}
+	for _, p := range pm1.Partitions {
+		replicas, exists := after[p.Topic][p.Partition]
+		if !exists || reflect.DeepEqual(p.Replicas, replicas) {
+			continue
+		}
+
+		moves = append(moves, fmt.Sprintf("%s:%d %v -> %v", p.Topic, p.Partition, p.Replicas, replicas))
+	}
+
+	return moves
+}
+
+// printPartitionMoves, if enabled via --show-moves, prints
+// the partitions where the replica list changed.
+func printPartitionMoves(cmd *cobra.Command, pm1, pm2 *kafkazk.PartitionMap) {
+	if sm, _ := cmd.Flags().GetBool("show-moves"); !sm {
+		return
+	}
+
+	moves := partitionMoves(pm1, pm2)
+
+	fmt.Fprintf(textOut, "\nPartition moves (%d):\n", len(moves))
+	for _, m := range moves {
+		fmt.Fprintf(textOut, "%s%s\n", indent, m)
+	}
+}
+
 // printBrokerAssignmentStats prints before and after broker usage stats,
 // such as leadership counts, total partitions owned, degree distribution,
 // and changes in storage usage.
 func printBrokerAssignmentStats(cmd *cobra.Command, pm1, pm2 *kafkazk.PartitionMap, bm1, bm2 kafkazk.BrokerMap) errors {
 	var errs errors
 
-	fmt.Println("\nBroker distribution:")
+	fmt.Fprintln(textOut, "\nBroker distribution:")
 
 	// Get general info.
 	dd1, dd2 := pm1.DegreeDistribution().Stats(), pm2.DegreeDistribution().Stats()
-	fmt.Printf("%sdegree [min/max/avg]: %.0f/%.0f/%.2f -> %.0f/%.0f/%.2f\n",
+	fmt.Fprintf(textOut, "%sdegree [min/max/avg]: %.0f/%.0f/%.2f -> %.0f/%.0f/%.2f\n",
 		indent, dd1.Min, dd1.Max, dd1.Avg, dd2.Min, dd2.Max, dd2.Avg)
 
-	fmt.Printf("%s-\n", indent)
+	// Partition count Gini coefficient before/after. The output
+	// includes all brokers not marked for replacement, such as
+	// newly provided brokers that received no partitions.
+	var before, after []int
+	for _, id := range fairnessBrokers(pm1, nil) {
+		if id != 0 {
+			before = append(before, id)
+		}
+	}
+
+	for id, b := range bm2 {
+		if id != 0 && !b.Replace {
+			after = append(after, id)
+		}
+	}
+
+	fmt.Fprintf(textOut, "%sgini [partition count]: %.3f -> %.3f\n",
+		indent, pm1.CountGini(before), pm2.CountGini(after))
+
+	fmt.Fprintf(textOut, "%s-\n", indent)
 
 	// Per-broker info.
 	UseStats := pm2.UseStats()
 	for _, use := range UseStats {
-		fmt.Printf("%sBroker %d - leader: %d, follower: %d, total: %d\n",
+		fmt.Fprintf(textOut, "%sBroker %d - leader: %d, follower: %d, total: %d\n",
 			indent, use.ID, use.Leader, use.Follower, use.Leader+use.Follower)
 	}
 
@@ -89,9 +261,9 @@ func printBrokerAssignmentStats(cmd *cobra.Command, pm1, pm2 *kafkazk.PartitionM
 	psf, _ := cmd.Flags().GetFloat64("partition-size-factor")
 
 	if cmd.Use == "rebalance" || cmd.Flag("placement").Value.String() == "storage" {
-		fmt.Println("\nStorage free change estimations:")
+		fmt.Fprintln(textOut, "\nStorage free change estimations:")
 		if psf != 1.0 && cmd.Use != "rebalance" {
-			fmt.Printf("%sPartition size factor of %.2f applied\n", indent, psf)
+			fmt.Fprintf(textOut, "%sPartition size factor of %.2f applied\n", indent, psf)
 		}
 
 		// Get filtered BrokerMaps. For the 'before' broker statistics, we want
@@ -134,20 +306,24 @@ func printBrokerAssignmentStats(cmd *cobra.Command, pm1, pm2 *kafkazk.PartitionM
 
 		// Range before/after.
 		r1, r2 := mb1.StorageRange(), mb2.StorageRange()
-		fmt.Printf("%srange: %.2fGB -> %.2fGB\n", indent, r1/div, r2/div)
+		fmt.Fprintf(textOut, "%srange: %.2fGB -> %.2fGB\n", indent, r1/div, r2/div)
 		if r2 > r1 {
 			errs = append(errs, fmt.Errorf("broker free storage range increased"))
 		}
 
 		// Range spread before/after.
 		rs1, rs2 := mb1.StorageRangeSpread(), mb2.StorageRangeSpread()
-		fmt.Printf("%srange spread: %.2f%% -> %.2f%%\n", indent, rs1, rs2)
+		fmt.Fprintf(textOut, "%srange spread: %.2f%% -> %.2f%%\n", indent, rs1, rs2)
 
 		// Std dev before/after.
 		sd1, sd2 := mb1.StorageStdDev(), mb2.StorageStdDev()
-		fmt.Printf("%sstd. deviation: %.2fGB -> %.2fGB\n", indent, sd1/div, sd2/div)
+		fmt.Fprintf(textOut, "%sstd. deviation: %.2fGB -> %.2fGB\n", indent, sd1/div, sd2/div)
+
+		// Gini coefficient before/after.
+		g1, g2 := mb1.StorageGini(), mb2.StorageGini()
+		fmt.Fprintf(textOut, "%sgini: %.3f -> %.3f\n", indent, g1, g2)
 
-		fmt.Printf("%s-\n", indent)
+		fmt.Fprintf(textOut, "%s-\n", indent)
 
 		// Get changes in storage utilization.
 		storageDiffs := bm1.StorageDiff(bm2)
@@ -163,10 +339,13 @@ func printBrokerAssignmentStats(cmd *cobra.Command, pm1, pm2 *kafkazk.PartitionM
 		for _, id := range ids {
 			diff := storageDiffs[id]
 
-			// Indicate if the broker
-			// is a replacement.
+			// Indicate if the broker is
+			// a replacement or excluded.
 			var replace string
-			if bm2[id].Replace {
+			switch {
+			case bm2[id].Excluded:
+				replace = "*excluded"
+			case bm2[id].Replace:
 				replace = "*marked for replacement"
 			}
 
@@ -178,7 +357,7 @@ func printBrokerAssignmentStats(cmd *cobra.Command, pm1, pm2 *kafkazk.PartitionM
 			// 	continue
 			// }
 
-			fmt.Printf("%sBroker %d: %.2f -> %.2f (%+.2fGB, %.2f%%) %s\n",
+			fmt.Fprintf(textOut, "%sBroker %d: %.2f -> %.2f (%+.2fGB, %.2f%%) %s\n",
 				indent, id, originalStorage, newStorage, diff[0]/div, diff[1], replace)
 		}
 	}
@@ -186,6 +365,132 @@ func printBrokerAssignmentStats(cmd *cobra.Command, pm1, pm2 *kafkazk.PartitionM
 	return errs
 }
 
+// printPlacementStats prints candidate selection
+// statistics recorded during placement.
+func printPlacementStats(ps *kafkazk.PlacementStats) {
+	if ps.Placements == 0 {
+		return
+	}
+
+	fmt.Fprintln(textOut, "\nPlacement stats:")
+	fmt.Fprintf(textOut, "%splacements: %d, failed: %d\n", indent, ps.Placements, ps.Failures)
+	fmt.Fprintf(textOut, "%scandidates rejected by constraints: %d\n", indent, ps.Rejections)
+	fmt.Fprintf(textOut, "%sbacktracks to lower ranked candidates: %d\n", indent, ps.Backtracks)
+	fmt.Fprintf(textOut, "%savg. candidate set size: %.2f\n", indent, ps.AvgCandidates())
+}
+
+// placementMetrics returns a *prometheus.Registry holding counters and
+// a candidate set size histogram populated from the *PlacementStats.
+func placementMetrics(ps *kafkazk.PlacementStats) *prometheus.Registry {
+	reg := prometheus.NewRegistry()
+
+	counters := []struct {
+		name, help string
+		value      int
+	}{
+		{"selections_total", "Candidate selections performed.", ps.Placements},
+		{"failures_total", "Selections where no candidate satisfied the constraints.", ps.Failures},
+		{"rejections_total", "Candidates that failed to satisfy the constraints.", ps.Rejections},
+		{"backtracks_total", "Selections that fell back to a lower ranked candidate.", ps.Backtracks},
+	}
+
+	for _, c := range counters {
+		counter := prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: "topicmappr",
+			Subsystem: "placement",
+			Name:      c.name,
+			Help:      c.help,
+		})
+		counter.Add(float64(c.value))
+		reg.MustRegister(counter)
+	}
+
+	candidates := prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: "topicmappr",
+		Subsystem: "placement",
+		Name:      "candidates",
+		Help:      "Candidate set size per selection.",
+		Buckets:   prometheus.ExponentialBuckets(1, 2, 10),
+	})
+
+	for size, n := range ps.CandidateSizes {
+		for i := 0; i < n; i++ {
+			candidates.Observe(float64(size))
+		}
+	}
+
+	reg.MustRegister(candidates)
+
+	return reg
+}
+
+// writePlacementMetrics, if enabled via --placement-metrics-file, writes
+// the placement stats in the Prometheus text format, such as for the
+// node_exporter textfile collector.
+func writePlacementMetrics(cmd *cobra.Command, ps *kafkazk.PlacementStats) {
+	pf := cmd.Flag("placement-metrics-file").Value.String()
+	if pf == "" {
+		return
+	}
+
+	fmt.Fprintln(textOut, "\nPlacement metrics:")
+	if err := prometheus.WriteToTextfile(pf, placementMetrics(ps)); err != nil {
+		fmt.Fprintf(textOut, "%s%s\n", indent, err)
+	} else {
+		fmt.Fprintf(textOut, "%s%s\n", indent, pf)
+	}
+}
+
+// printLeaderBytesStats prints the before/after leader bytes
+// per broker along with the standard deviation.
+func printLeaderBytesStats(pm1, pm2 *kafkazk.PartitionMap, pmm kafkazk.PartitionMetaMap) {
+	lb1, err := pm1.LeaderBytes(pmm)
+	if err != nil {
+		fmt.Fprintln(textOut, err)
+		return
+	}
+
+	lb2, _ := pm2.LeaderBytes(pmm)
+	sd1, _ := pm1.LeaderBytesStdDev(pmm)
+	sd2, _ := pm2.LeaderBytesStdDev(pmm)
+
+	fmt.Fprintln(textOut, "\nLeader bytes:")
+	fmt.Fprintf(textOut, "%sstd. deviation: %.2fGB -> %.2fGB\n", indent, sd1/div, sd2/div)
+	fmt.Fprintf(textOut, "%s-\n", indent)
+
+	ids := []int{}
+	for id := range lb2 {
+		ids = append(ids, id)
+	}
+
+	sort.Ints(ids)
+
+	for _, id := range ids {
+		fmt.Fprintf(textOut, "%sBroker %d: %.2fGB -> %.2fGB\n", indent, id, lb1[id]/div, lb2[id]/div)
+	}
+}
+
+// printLeadershipStats prints the before/after leader
+// counts per broker along with the leadership skew.
+func printLeadershipStats(pm1, pm2 *kafkazk.PartitionMap) {
+	s1 := kafkazk.BrokerUseStatsList(pm1.UseStats())
+	s2 := kafkazk.BrokerUseStatsList(pm2.UseStats())
+
+	min1, max1, skew1 := s1.LeadershipSkew()
+	min2, max2, skew2 := s2.LeadershipSkew()
+
+	fmt.Fprintln(textOut, "\nLeadership:")
+	fmt.Fprintf(textOut, "%smin/max: %d/%d -> %d/%d\n", indent, min1, max1, min2, max2)
+	fmt.Fprintf(textOut, "%sskew: %.2f -> %.2f\n", indent, skew1, skew2)
+	fmt.Fprintf(textOut, "%s-\n", indent)
+
+	// Replica set membership is unchanged; the
+	// stats lists hold the same sorted broker IDs.
+	for i := range s2 {
+		fmt.Fprintf(textOut, "%sBroker %d: %d -> %d\n", indent, s2[i].ID, s1[i].Leader, s2[i].Leader)
+	}
+}
+
 // skipReassignmentNoOps removes no-op partition map changes
 // from the input and final output PartitionMap
 func skipReassignmentNoOps(pm1, pm2 *kafkazk.PartitionMap) (*kafkazk.PartitionMap, *kafkazk.PartitionMap) {
@@ -203,10 +508,11 @@ func skipReassignmentNoOps(pm1, pm2 *kafkazk.PartitionMap) (*kafkazk.PartitionMa
 }
 
 // writeMaps takes a PartitionMap and writes out
-// files.
+// files. If any files fail to be written, writeMaps
+// exits once all have been attempted.
 func writeMaps(cmd *cobra.Command, pm *kafkazk.PartitionMap) {
 	if len(pm.Partitions) == 0 {
-		fmt.Println("\nNo partition reassignments, skipping map generation")
+		fmt.Fprintln(textOut, "\nNo partition reassignments, skipping map generation")
 		return
 	}
 
@@ -222,24 +528,150 @@ func writeMaps(cmd *cobra.Command, pm *kafkazk.PartitionMap) {
 		tm[p.Topic].Partitions = append(tm[p.Topic].Partitions, p)
 	}
 
-	fmt.Println("\nNew partition maps:")
+	var failed bool
+
+	fmt.Fprintln(textOut, "\nNew partition maps:")
 	// Global map if set.
 	if of != "" {
-		err := kafkazk.WriteMap(pm, op+of)
+		name, err := writeMap(cmd, pm, op+of)
 		if err != nil {
-			fmt.Printf("%s%s", indent, err)
+			fmt.Fprintf(textOut, "%s%s\n", indent, err)
+			failed = true
 		} else {
-			fmt.Printf("%s%s%s.json [combined map]\n", indent, op, of)
+			fmt.Fprintf(textOut, "%s%s [combined map]\n", indent, name)
 		}
 	}
 
 	for t := range tm {
-		err := kafkazk.WriteMap(tm[t], op+t)
+		name, err := writeMap(cmd, tm[t], op+t)
+		if err != nil {
+			fmt.Fprintf(textOut, "%s%s\n", indent, err)
+			failed = true
+		} else {
+			fmt.Fprintf(textOut, "%s%s\n", indent, name)
+		}
+	}
+
+	if failed {
+		exit(1)
+	}
+}
+
+// writeMap writes a PartitionMap to the provided path in the
+// format specified by --output-format, defaulting to JSON. The
+// name of the file written is returned.
+func writeMap(cmd *cobra.Command, pm *kafkazk.PartitionMap, path string) (string, error) {
+	var format string
+	if f := cmd.Flag("output-format"); f != nil {
+		format = f.Value.String()
+	}
+
+	if format == "yaml" {
+		return path + ".yaml", kafkazk.WriteMapYAML(pm, path)
+	}
+
+	return path + ".json", kafkazk.WriteMap(pm, path)
+}
+
+// writeBatchedMaps takes the original and output PartitionMaps along with
+// a PartitionMetaMap and writes the output map as ordered batches, where no
+// broker transfers more than --transfer-limit-gb in any single batch. As with
+// writeMaps, failures to write any batch exit once all have been attempted.
+func writeBatchedMaps(cmd *cobra.Command, pm1, pm2 *kafkazk.PartitionMap, pmm kafkazk.PartitionMetaMap) {
+	tl, _ := cmd.Flags().GetFloat64("transfer-limit-gb")
+
+	batches, err := pm2.TransferBatches(pm1, pmm, tl*div)
+	if err != nil {
+		fmt.Fprintln(textOut, err)
+		exit(1)
+	}
+
+	if len(batches) == 0 {
+		fmt.Fprintln(textOut, "\nNo partition reassignments, skipping map generation")
+		return
+	}
+
+	op := cmd.Flag("out-path").Value.String()
+	of := cmd.Flag("out-file").Value.String()
+	if of == "" {
+		of = "batch"
+	}
+
+	var failed bool
+
+	fmt.Fprintf(textOut, "\nNew partition maps (%d batches, %.2fGB transfer limit per broker):\n", len(batches), tl)
+	for i, b := range batches {
+		name, err := writeMap(cmd, b, fmt.Sprintf("%s%s-%d", op, of, i+1))
 		if err != nil {
-			fmt.Printf("%s%s", indent, err)
+			fmt.Fprintf(textOut, "%s%s\n", indent, err)
+			failed = true
 		} else {
-			fmt.Printf("%s%s%s.json\n", indent, op, t)
+			fmt.Fprintf(textOut, "%s%s [%d partitions]\n", indent, name, len(b.Partitions))
+		}
+	}
+
+	if failed {
+		exit(1)
+	}
+}
+
+// limitMoves, if --max-moves is set, returns the output PartitionMap with
+// partitions reverted to their original assignment where needed to cap the
+// number of replica moves, prioritizing moves off of the fullest brokers in
+// the original BrokerMap. The number of deferred moves is reported. The
+// PartitionMap is returned as is if --max-moves isn't set.
+func limitMoves(cmd *cobra.Command, pm1, pm2 *kafkazk.PartitionMap, bm kafkazk.BrokerMap) *kafkazk.PartitionMap {
+	mm, _ := cmd.Flags().GetInt("max-moves")
+	if mm == 0 {
+		return pm2
+	}
+
+	out, deferred, err := pm2.LimitMoves(pm1, bm, mm)
+	if err != nil {
+		fmt.Fprintln(textOut, err)
+		exit(1)
+	}
+
+	fmt.Fprintln(textOut, "\nMove limit:")
+	if deferred == 0 {
+		fmt.Fprintf(textOut, "%s[none deferred]\n", indent)
+	} else {
+		fmt.Fprintf(textOut, "%s%d replica moves deferred to a subsequent run (--max-moves %d)\n", indent, deferred, mm)
+	}
+
+	if jsonOutput != nil {
+		jsonOutput.DeferredMoves = deferred
+	}
+
+	return out
+}
+
+// writeDiff writes a JSON diff of the changes between the original and
+// output PartitionMaps to --out-path + --diff-file, if set. Bytes moved are
+// computed and reported only if a PartitionMetaMap is provided.
+func writeDiff(cmd *cobra.Command, pm1, pm2 *kafkazk.PartitionMap, pmm kafkazk.PartitionMetaMap) {
+	df := cmd.Flag("diff-file").Value.String()
+	if df == "" {
+		return
+	}
+
+	d, err := pm2.Diff(pm1, pmm)
+	if err != nil {
+		fmt.Fprintln(textOut, err)
+		exit(1)
+	}
+
+	op := cmd.Flag("out-path").Value.String()
+
+	fmt.Fprintln(textOut, "\nPartition map diff:")
+	if err := kafkazk.WriteMapDiff(d, op+df); err != nil {
+		fmt.Fprintf(textOut, "%s%s\n", indent, err)
+	} else {
+		var moved string
+		if pmm != nil {
+			moved = fmt.Sprintf(", %.2fGB moved", d.BytesMoved/div)
 		}
+		fmt.Fprintf(textOut, "%s%s.json [%d partitions%s]\n", indent, op+df, len(d.Partitions), moved)
 	}
 }
 
@@ -248,20 +680,42 @@ func writeMaps(cmd *cobra.Command, pm *kafkazk.PartitionMap) {
 // CLI). If --ignore-warns is false (default), any errors passed
 // here will cause an exit(1).
 func handleOverridableErrs(cmd *cobra.Command, e errors) {
-	fmt.Println("\nWARN:")
+	fmt.Fprintln(textOut, "\nWARN:")
 	if len(e) > 0 {
 		sort.Sort(e)
 		for _, err := range e {
-			fmt.Printf("%s%s\n", indent, err)
+			fmt.Fprintf(textOut, "%s%s\n", indent, err)
+			if jsonOutput != nil {
+				jsonOutput.Warnings = append(jsonOutput.Warnings, err.Error())
+			}
 		}
 	} else {
-		fmt.Printf("%s[none]\n", indent)
+		fmt.Fprintf(textOut, "%s[none]\n", indent)
 	}
 
 	iw, _ := cmd.Flags().GetBool("ignore-warns")
 	if !iw && len(e) > 0 {
-		fmt.Printf("\n%sWarnings encountered, partition map not created. Override with --ignore-warns.\n", indent)
-		os.Exit(1)
+		fmt.Fprintf(textOut, "\n%sWarnings encountered, partition map not created. Override with --ignore-warns.\n", indent)
+		exit(1)
+	}
+}
+
+// printSizeWarns prints warnings for partitions with assumed sizes. Unlike
+// overridable errors, these don't prevent map creation; storage estimates
+// involving the affected partitions are approximate. The warnings are
+// included in the JSON output.
+func printSizeWarns(e errors) {
+	if len(e) == 0 {
+		return
+	}
+
+	fmt.Fprintln(textOut, "\nPartition sizes assumed:")
+	sort.Sort(e)
+	for _, err := range e {
+		fmt.Fprintf(textOut, "%s%s\n", indent, err)
+		if jsonOutput != nil {
+			jsonOutput.Warnings = append(jsonOutput.Warnings, err.Error())
+		}
 	}
 }
 
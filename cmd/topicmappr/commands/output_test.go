@@ -1,7 +1,17 @@
 package commands
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
 	"testing"
+
+	"github.com/DataDog/kafka-kit/kafkazk"
+
+	"github.com/spf13/cobra"
 )
 
 func TestWhatChanged(t *testing.T) {
@@ -32,3 +42,224 @@ func TestWhatChanged(t *testing.T) {
 		}
 	}
 }
+
+func TestWriteJSONOutput(t *testing.T) {
+	buf := &bytes.Buffer{}
+	jsonOutput = &stdoutJSON{Messages: []string{}, Warnings: []string{}, out: buf}
+	defer func() { jsonOutput = nil }()
+
+	// Replace 1004 with 1005.
+	Config.brokers = []int{1001, 1002, 1003, 1005}
+	defer func() { Config.brokers = nil }()
+
+	cmd := &cobra.Command{}
+	cmd.Flags().Bool("force-rebuild", false, "")
+	cmd.Flags().Float64("warm-new-brokers", 0.0, "")
+	cmd.Flags().Bool("ignore-warns", true, "")
+
+	zk := &kafkazk.Mock{}
+	pm, _ := zk.GetPartitionMap("test_topic")
+	bmm, _ := zk.GetAllBrokerMeta(false)
+
+	_, bs := getBrokers(cmd, pm, bmm)
+	handleOverridableErrs(cmd, errors{fmt.Errorf("test warning")})
+	writeJSONOutput(pm, bs)
+
+	out := &struct {
+		PartitionMap *kafkazk.PartitionMap `json:"partition_map"`
+		BrokerStatus *kafkazk.BrokerStatus `json:"broker_status"`
+		Messages     []string              `json:"messages"`
+		Warnings     []string              `json:"warnings"`
+	}{}
+
+	if err := json.Unmarshal(buf.Bytes(), out); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	expected := kafkazk.BrokerStatus{New: 1, Replace: 1}
+	if out.BrokerStatus == nil || *out.BrokerStatus != expected {
+		t.Errorf("Expected broker status %+v, got %+v", expected, out.BrokerStatus)
+	}
+
+	if len(out.Messages) != 2 {
+		t.Errorf("Expected 2 messages, got %v", out.Messages)
+	}
+
+	if len(out.Warnings) != 1 || out.Warnings[0] != "test warning" {
+		t.Errorf("Expected warnings [test warning], got %v", out.Warnings)
+	}
+
+	if out.PartitionMap == nil || !reflect.DeepEqual(out.PartitionMap, pm) {
+		t.Errorf("Expected partition map %v, got %v", pm, out.PartitionMap)
+	}
+}
+
+func TestWriteJSONOnExit(t *testing.T) {
+	buf := &bytes.Buffer{}
+	jsonOutput = &stdoutJSON{Messages: []string{}, Warnings: []string{}, out: buf}
+	textOut = &stderrText{w: ioutil.Discard}
+	defer func() { jsonOutput, textOut = nil, os.Stdout }()
+
+	fmt.Fprintf(textOut, "\nTopics:\n%stest_topic\n", indent)
+	fmt.Fprintf(textOut, "\n[ERROR] %s\n", "test error")
+
+	writeJSONOnExit(1)
+
+	out := &struct {
+		PartitionMap *kafkazk.PartitionMap `json:"partition_map"`
+		Error        string                `json:"error"`
+	}{}
+
+	if err := json.Unmarshal(buf.Bytes(), out); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if out.Error != "test error" {
+		t.Errorf("Expected error 'test error', got '%s'", out.Error)
+	}
+
+	if out.PartitionMap != nil {
+		t.Errorf("Expected nil partition map, got %v", out.PartitionMap)
+	}
+
+	// The document is only written once.
+	writeJSONOnExit(1)
+
+	if n := bytes.Count(buf.Bytes(), []byte("partition_map")); n != 1 {
+		t.Errorf("Expected 1 JSON document, got %d", n)
+	}
+}
+
+func TestPrintSizeWarns(t *testing.T) {
+	jsonOutput = &stdoutJSON{Messages: []string{}, Warnings: []string{}}
+	defer func() { jsonOutput = nil }()
+
+	printSizeWarns(errors{
+		fmt.Errorf("test_topic p1: partition size not found, assuming 1.00GB"),
+		fmt.Errorf("test_topic p0: partition size not found, assuming 1.00GB"),
+	})
+
+	expected := []string{
+		"test_topic p0: partition size not found, assuming 1.00GB",
+		"test_topic p1: partition size not found, assuming 1.00GB",
+	}
+
+	if !reflect.DeepEqual(jsonOutput.Warnings, expected) {
+		t.Errorf("Expected warnings %v, got %v", expected, jsonOutput.Warnings)
+	}
+}
+
+func TestPlacementMetrics(t *testing.T) {
+	ps := &kafkazk.PlacementStats{
+		Placements: 3,
+		Failures:   1,
+		Rejections: 6,
+		Backtracks: 1,
+		Candidates: 10,
+		CandidateSizes: map[int]int{
+			2: 1,
+			4: 2,
+		},
+	}
+
+	mfs, err := placementMetrics(ps).Gather()
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	expected := map[string]float64{
+		"topicmappr_placement_selections_total": 3,
+		"topicmappr_placement_failures_total":   1,
+		"topicmappr_placement_rejections_total": 6,
+		"topicmappr_placement_backtracks_total": 1,
+	}
+
+	for _, mf := range mfs {
+		m := mf.GetMetric()[0]
+
+		if mf.GetName() == "topicmappr_placement_candidates" {
+			h := m.GetHistogram()
+			if h.GetSampleCount() != 3 || h.GetSampleSum() != 10 {
+				t.Errorf("Expected 3 candidate samples summing to 10, got %d and %.0f", h.GetSampleCount(), h.GetSampleSum())
+			}
+			continue
+		}
+
+		v, exists := expected[mf.GetName()]
+		if !exists {
+			t.Errorf("Unexpected metric %s", mf.GetName())
+			continue
+		}
+
+		if c := m.GetCounter().GetValue(); c != v {
+			t.Errorf("Expected %s %.0f, got %.0f", mf.GetName(), v, c)
+		}
+
+		delete(expected, mf.GetName())
+	}
+
+	if len(expected) > 0 {
+		t.Errorf("Expected metrics %v", expected)
+	}
+}
+
+func TestPartitionMoves(t *testing.T) {
+	in, _ := kafkazk.PartitionMapFromString(`{"version":1,"partitions":[
+    {"topic":"a","partition":0,"replicas":[1001,1002]},
+    {"topic":"a","partition":1,"replicas":[1002,1003]},
+    {"topic":"a","partition":2,"replicas":[1003,1001]},
+    {"topic":"b","partition":0,"replicas":[1001,1002]}]}`)
+
+	out, _ := kafkazk.PartitionMapFromString(`{"version":1,"partitions":[
+    {"topic":"a","partition":0,"replicas":[1001,1002]},
+    {"topic":"a","partition":1,"replicas":[1002,1004]},
+    {"topic":"a","partition":2,"replicas":[1001,1003]},
+    {"topic":"b","partition":0,"replicas":[1001,1002,1004]}]}`)
+
+	expected := []string{
+		"a:1 [1002 1003] -> [1002 1004]",
+		"a:2 [1003 1001] -> [1001 1003]",
+		"b:0 [1001 1002] -> [1001 1002 1004]",
+	}
+
+	if moves := partitionMoves(in, out); !reflect.DeepEqual(moves, expected) {
+		t.Errorf("Expected moves %v, got %v", expected, moves)
+	}
+
+	if moves := partitionMoves(in, in.Copy()); len(moves) != 0 {
+		t.Errorf("Expected no moves, got %v", moves)
+	}
+}
+
+func TestLimitMoves(t *testing.T) {
+	pm1, _ := kafkazk.PartitionMapFromString(`{"version":1,"partitions":[
+		{"topic":"test_topic","partition":0,"replicas":[1001,1002]},
+		{"topic":"test_topic","partition":1,"replicas":[1002,1003]}]}`)
+	pm2, _ := kafkazk.PartitionMapFromString(`{"version":1,"partitions":[
+		{"topic":"test_topic","partition":0,"replicas":[1003,1002]},
+		{"topic":"test_topic","partition":1,"replicas":[1001,1003]}]}`)
+
+	bm := kafkazk.BrokerMapFromPartitionMap(pm1, nil, false)
+
+	cmd := &cobra.Command{}
+	cmd.Flags().Int("max-moves", 0, "")
+
+	// Unlimited by default.
+	if out := limitMoves(cmd, pm1, pm2, bm); out != pm2 {
+		t.Errorf("Expected the output map unmodified, got %v", out)
+	}
+
+	// 1002 holds the most replicas; the
+	// move off of it is prioritized.
+	cmd.Flags().Set("max-moves", "1")
+
+	out := limitMoves(cmd, pm1, pm2, bm)
+
+	if !out.Partitions[0].Equal(pm1.Partitions[0]) {
+		t.Errorf("Expected p0 deferred, got %v", out.Partitions[0].Replicas)
+	}
+
+	if !out.Partitions[1].Equal(pm2.Partitions[1]) {
+		t.Errorf("Expected p1 moved, got %v", out.Partitions[1].Replicas)
+	}
+}
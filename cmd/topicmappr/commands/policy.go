@@ -0,0 +1,272 @@
+package commands
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+)
+
+// policy holds placement constraints loaded from a
+// policy file. Keys match the equivalent flag names.
+// Unset fields are nil and don't alter flag values.
+type policy struct {
+	Brokers             []int
+	Placement           *string
+	Optimize            *string
+	PartitionSizeFactor *float64
+	Replication         *int
+	SubAffinity         *bool
+	UseMeta             *bool
+	LeaderPolicy        *string
+	WarmNewBrokers      *float64
+	TransferLimitGB     *float64
+	SkipNoOps           *bool
+	AntiAffinityTags    []string
+	RackWeighted        *bool
+	MinStorageFreeGB    *float64
+	ControllerPlacement *string
+	TopicSpread         *bool
+	MaxPartitions       *int
+}
+
+// fields returns a map of policy file keys to
+// the corresponding policy field references.
+func (p *policy) fields() map[string]interface{} {
+	return map[string]interface{}{
+		"brokers":                   &p.Brokers,
+		"placement":                 &p.Placement,
+		"optimize":                  &p.Optimize,
+		"partition-size-factor":     &p.PartitionSizeFactor,
+		"replication":               &p.Replication,
+		"sub-affinity":              &p.SubAffinity,
+		"use-meta":                  &p.UseMeta,
+		"leader-policy":             &p.LeaderPolicy,
+		"warm-new-brokers":          &p.WarmNewBrokers,
+		"transfer-limit-gb":         &p.TransferLimitGB,
+		"skip-no-ops":               &p.SkipNoOps,
+		"anti-affinity-tags":        &p.AntiAffinityTags,
+		"rack-weighted":             &p.RackWeighted,
+		"min-storage-free-gb":       &p.MinStorageFreeGB,
+		"controller-placement":      &p.ControllerPlacement,
+		"topic-spread":              &p.TopicSpread,
+		"max-partitions-per-broker": &p.MaxPartitions,
+	}
+}
+
+// policyFromFile reads and validates a YAML or JSON policy file at path p.
+func policyFromFile(p string) (*policy, error) {
+	data, err := ioutil.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+
+	return policyFromBytes(data)
+}
+
+// policyFromBytes unmarshals and validates a YAML or JSON policy. Unknown
+// fields and invalid values are returned as errors referencing the field name.
+func policyFromBytes(data []byte) (*policy, error) {
+	// YAML is a superset of JSON; both are
+	// handled by the YAML decoder.
+	raw := map[string]interface{}{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("invalid policy file: %s", err)
+	}
+
+	var keys []string
+	for k := range raw {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pol := &policy{}
+	fields := pol.fields()
+	var errs []string
+
+	// Decode each field individually so that
+	// type errors reference the field name.
+	for _, k := range keys {
+		f, exists := fields[k]
+		if !exists {
+			errs = append(errs, fmt.Sprintf("%s: unknown field", k))
+			continue
+		}
+
+		b, _ := yaml.Marshal(raw[k])
+		if err := yaml.Unmarshal(b, f); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: invalid value '%v'", k, raw[k]))
+		}
+	}
+
+	errs = append(errs, pol.validate()...)
+
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("invalid policy file:\n%s%s", indent, strings.Join(errs, "\n"+indent))
+	}
+
+	return pol, nil
+}
+
+// validate returns a []string of field-level errors.
+func (p *policy) validate() []string {
+	var errs []string
+
+	for _, id := range p.Brokers {
+		if id <= 0 {
+			errs = append(errs, fmt.Sprintf("brokers: invalid broker ID %d", id))
+		}
+	}
+
+	if p.Placement != nil && *p.Placement != "count" && *p.Placement != "storage" {
+		errs = append(errs, "placement: must be either 'count' or 'storage'")
+	}
+
+	if p.Optimize != nil && *p.Optimize != "distribution" && *p.Optimize != "storage" {
+		errs = append(errs, "optimize: must be either 'distribution' or 'storage'")
+	}
+
+	if p.PartitionSizeFactor != nil && *p.PartitionSizeFactor <= 0 {
+		errs = append(errs, "partition-size-factor: must be greater than 0")
+	}
+
+	if p.Replication != nil && *p.Replication < 0 {
+		errs = append(errs, "replication: must be 0 or greater")
+	}
+
+	if lp := p.LeaderPolicy; lp != nil && *lp != "" && *lp != "count" && *lp != "storage" && *lp != "bytes" && !strings.HasPrefix(*lp, "rack:") {
+		errs = append(errs, "leader-policy: must be either 'count', 'storage', 'bytes' or 'rack:<id>'")
+	}
+
+	if lp := p.LeaderPolicy; lp != nil && *lp == "rack:" {
+		errs = append(errs, "leader-policy: rack:<id> requires a rack ID")
+	}
+
+	if p.WarmNewBrokers != nil && (*p.WarmNewBrokers < 0 || *p.WarmNewBrokers > 1) {
+		errs = append(errs, "warm-new-brokers: must be between 0.00 and 1.00")
+	}
+
+	if p.TransferLimitGB != nil && *p.TransferLimitGB < 0 {
+		errs = append(errs, "transfer-limit-gb: must be 0 or greater")
+	}
+
+	if p.MinStorageFreeGB != nil && *p.MinStorageFreeGB < 0 {
+		errs = append(errs, "min-storage-free-gb: must be 0 or greater")
+	}
+
+	if p.MaxPartitions != nil && *p.MaxPartitions < 0 {
+		errs = append(errs, "max-partitions-per-broker: must be 0 or greater")
+	}
+
+	if cp := p.ControllerPlacement; cp != nil && *cp != "" && *cp != "deprioritize" && *cp != "exclude" {
+		errs = append(errs, "controller-placement: must be either 'deprioritize' or 'exclude'")
+	}
+
+	for _, k := range p.AntiAffinityTags {
+		if strings.TrimSpace(k) == "" || strings.Contains(k, ",") {
+			errs = append(errs, fmt.Sprintf("anti-affinity-tags: invalid tag key '%s'", k))
+		}
+	}
+
+	return errs
+}
+
+// flags returns a map of flag names to values for all set policy fields.
+func (p *policy) flags() map[string]string {
+	f := map[string]string{}
+
+	if len(p.Brokers) > 0 {
+		var ids []string
+		for _, id := range p.Brokers {
+			ids = append(ids, strconv.Itoa(id))
+		}
+		f["brokers"] = strings.Join(ids, ",")
+	}
+
+	if p.Placement != nil {
+		f["placement"] = *p.Placement
+	}
+	if p.Optimize != nil {
+		f["optimize"] = *p.Optimize
+	}
+	if p.PartitionSizeFactor != nil {
+		f["partition-size-factor"] = strconv.FormatFloat(*p.PartitionSizeFactor, 'f', -1, 64)
+	}
+	if p.Replication != nil {
+		f["replication"] = strconv.Itoa(*p.Replication)
+	}
+	if p.SubAffinity != nil {
+		f["sub-affinity"] = strconv.FormatBool(*p.SubAffinity)
+	}
+	if p.UseMeta != nil {
+		f["use-meta"] = strconv.FormatBool(*p.UseMeta)
+	}
+	if p.LeaderPolicy != nil {
+		f["leader-policy"] = *p.LeaderPolicy
+	}
+	if p.WarmNewBrokers != nil {
+		f["warm-new-brokers"] = strconv.FormatFloat(*p.WarmNewBrokers, 'f', -1, 64)
+	}
+	if p.TransferLimitGB != nil {
+		f["transfer-limit-gb"] = strconv.FormatFloat(*p.TransferLimitGB, 'f', -1, 64)
+	}
+	if p.SkipNoOps != nil {
+		f["skip-no-ops"] = strconv.FormatBool(*p.SkipNoOps)
+	}
+	if p.RackWeighted != nil {
+		f["rack-weighted"] = strconv.FormatBool(*p.RackWeighted)
+	}
+	if p.MinStorageFreeGB != nil {
+		f["min-storage-free-gb"] = strconv.FormatFloat(*p.MinStorageFreeGB, 'f', -1, 64)
+	}
+	if p.ControllerPlacement != nil {
+		f["controller-placement"] = *p.ControllerPlacement
+	}
+	if p.TopicSpread != nil {
+		f["topic-spread"] = strconv.FormatBool(*p.TopicSpread)
+	}
+	if p.MaxPartitions != nil {
+		f["max-partitions-per-broker"] = strconv.Itoa(*p.MaxPartitions)
+	}
+	if len(p.AntiAffinityTags) > 0 {
+		f["anti-affinity-tags"] = strings.Join(p.AntiAffinityTags, ",")
+	}
+
+	return f
+}
+
+// applyPolicy sets flag values from the policy. Flags explicitly
+// set on the command line take precedence and are left unmodified.
+func applyPolicy(cmd *cobra.Command, p *policy) error {
+	for name, value := range p.flags() {
+		if cmd.Flags().Changed(name) {
+			continue
+		}
+
+		if err := cmd.Flags().Set(name, value); err != nil {
+			return fmt.Errorf("invalid policy file: %s: %s", name, err)
+		}
+	}
+
+	return nil
+}
+
+// loadPolicyFile, if a policy file is specified via --policy-file,
+// loads and applies the policy to the command flags.
+func loadPolicyFile(cmd *cobra.Command) error {
+	pf, _ := cmd.Flags().GetString("policy-file")
+	if pf == "" {
+		return nil
+	}
+
+	p, err := policyFromFile(pf)
+	if err != nil {
+		return err
+	}
+
+	return applyPolicy(cmd, p)
+}
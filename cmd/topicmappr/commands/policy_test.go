@@ -0,0 +1,169 @@
+package commands
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+func testPolicyCmd() *cobra.Command {
+	cmd := &cobra.Command{}
+	cmd.Flags().String("brokers", "", "")
+	cmd.Flags().String("placement", "count", "")
+	cmd.Flags().String("optimize", "distribution", "")
+	cmd.Flags().Float64("partition-size-factor", 1.0, "")
+	cmd.Flags().Int("replication", 0, "")
+	cmd.Flags().Bool("sub-affinity", false, "")
+	cmd.Flags().Bool("use-meta", true, "")
+	cmd.Flags().String("leader-policy", "", "")
+	cmd.Flags().Float64("warm-new-brokers", 0.0, "")
+	cmd.Flags().Float64("transfer-limit-gb", 0.0, "")
+	cmd.Flags().Bool("skip-no-ops", false, "")
+	cmd.Flags().String("anti-affinity-tags", "", "")
+	cmd.Flags().String("policy-file", "", "")
+
+	return cmd
+}
+
+func testWritePolicyFile(t *testing.T, name, content string) string {
+	dir, err := ioutil.TempDir("", "topicmappr")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestLoadPolicyFile(t *testing.T) {
+	files := map[string]string{
+		"policy.yaml": `
+brokers: [1001, 1002, 1003]
+placement: storage
+optimize: storage
+partition-size-factor: 1.5
+replication: 3
+sub-affinity: true
+leader-policy: rack:a
+warm-new-brokers: 0.5
+anti-affinity-tags: [power-domain, switch]
+`,
+		"policy.json": `{
+  "brokers": [1001, 1002, 1003],
+  "placement": "storage",
+  "optimize": "storage",
+  "partition-size-factor": 1.5,
+  "replication": 3,
+  "sub-affinity": true,
+  "leader-policy": "rack:a",
+  "warm-new-brokers": 0.5,
+  "anti-affinity-tags": ["power-domain", "switch"]
+}`,
+	}
+
+	expected := map[string]string{
+		"brokers":               "1001,1002,1003",
+		"placement":             "storage",
+		"optimize":              "storage",
+		"partition-size-factor": "1.5",
+		"replication":           "3",
+		"sub-affinity":          "true",
+		"use-meta":              "true",
+		"leader-policy":         "rack:a",
+		"warm-new-brokers":      "0.5",
+		"skip-no-ops":           "false",
+		"anti-affinity-tags":    "power-domain,switch",
+	}
+
+	for name, content := range files {
+		path := testWritePolicyFile(t, name, content)
+		defer os.RemoveAll(filepath.Dir(path))
+
+		cmd := testPolicyCmd()
+		cmd.Flags().Set("policy-file", path)
+
+		if err := loadPolicyFile(cmd); err != nil {
+			t.Fatalf("[%s] Unexpected error: %s", name, err)
+		}
+
+		for flag, v := range expected {
+			if got := cmd.Flag(flag).Value.String(); got != v {
+				t.Errorf("[%s] Expected %s value '%s', got '%s'", name, flag, v, got)
+			}
+		}
+	}
+}
+
+func TestLoadPolicyFileFlagOverride(t *testing.T) {
+	path := testWritePolicyFile(t, "policy.yaml", "placement: storage\nbrokers: [1001, 1002]\n")
+	defer os.RemoveAll(filepath.Dir(path))
+
+	cmd := testPolicyCmd()
+	cmd.Flags().Set("policy-file", path)
+	// Explicitly set flags take precedence.
+	cmd.Flags().Set("placement", "count")
+
+	if err := loadPolicyFile(cmd); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if p := cmd.Flag("placement").Value.String(); p != "count" {
+		t.Errorf("Expected placement value 'count', got '%s'", p)
+	}
+
+	if b := cmd.Flag("brokers").Value.String(); b != "1001,1002" {
+		t.Errorf("Expected brokers value '1001,1002', got '%s'", b)
+	}
+}
+
+func TestLoadPolicyFileInvalid(t *testing.T) {
+	// Map of policy file content to
+	// expected field names in the error.
+	tests := map[string][]string{
+		"placement: random\n":                           []string{"placement"},
+		"optimize: none\nwarm-new-brokers: 2\n":         []string{"optimize", "warm-new-brokers"},
+		"partition-size-factor: 0\n":                    []string{"partition-size-factor"},
+		"brokers: [1001, -1]\nleader-policy: fastest\n": []string{"brokers", "leader-policy"},
+		"replication: three\n":                          []string{"replication"},
+		"unknown-field: true\n":                         []string{"unknown-field"},
+		"transfer-limit-gb: -10\n":                      []string{"transfer-limit-gb"},
+		"min-storage-free-gb: -1\n":                     []string{"min-storage-free-gb"},
+		"controller-placement: avoid\n":                 []string{"controller-placement"},
+		"leader-policy: 'rack:'\n":                      []string{"leader-policy"},
+	}
+
+	for content, fields := range tests {
+		path := testWritePolicyFile(t, "policy.yaml", content)
+		defer os.RemoveAll(filepath.Dir(path))
+
+		cmd := testPolicyCmd()
+		cmd.Flags().Set("policy-file", path)
+
+		err := loadPolicyFile(cmd)
+		if err == nil {
+			t.Errorf("Expected error for policy '%s'", content)
+			continue
+		}
+
+		for _, f := range fields {
+			if !strings.Contains(err.Error(), f) {
+				t.Errorf("Expected error referencing field '%s', got '%s'", f, err)
+			}
+		}
+	}
+
+	// Missing file.
+	cmd := testPolicyCmd()
+	cmd.Flags().Set("policy-file", "/nonexistent/policy.yaml")
+	if err := loadPolicyFile(cmd); err == nil {
+		t.Error("Expected error for missing policy file")
+	}
+}
@@ -2,7 +2,6 @@ package commands
 
 import (
 	"fmt"
-	"os"
 	"sort"
 
 	"github.com/DataDog/kafka-kit/kafkazk"
@@ -23,16 +22,23 @@ func init() {
 	rebalanceCmd.Flags().String("topics", "", "Rebuild topics (comma delim. list) by lookup in ZooKeeper")
 	rebalanceCmd.Flags().String("out-path", "", "Path to write output map files to")
 	rebalanceCmd.Flags().String("out-file", "", "If defined, write a combined map of all topics to a file")
+	rebalanceCmd.Flags().String("output-format", "json", "Output map format: [json, yaml]")
+	rebalanceCmd.Flags().String("diff-file", "", "If defined, write a JSON diff of all partition map changes to a file")
 	rebalanceCmd.Flags().String("brokers", "", "Broker list to scope all partition placements to")
+	rebalanceCmd.Flags().String("partition-size-source", "zk", "Partition size source: [zk, <path>]; a path is a JSON file mapping topic:partition to bytes")
 	rebalanceCmd.Flags().Float64("storage-threshold", 0.20, "Percent below the harmonic mean storage free to target for partition offload")
 	rebalanceCmd.Flags().Float64("storage-threshold-gb", 0.00, "Storage free in gigabytes to target for partition offload (those below the specified value); 0 [default] defers target selection to --storage-threshold")
 	rebalanceCmd.Flags().Float64("tolerance", 0.10, "Percent distance from the mean storage free to limit storage scheduling (0 targets a brokers)")
 	rebalanceCmd.Flags().Int("partition-limit", 30, "Limit the number of top partitions by size eligible for relocation per broker")
+	rebalanceCmd.Flags().Float64("min-storage-free-gb", 0.00, "Reject relocations that would leave a destination broker with less than this many gigabytes of storage free")
 	rebalanceCmd.Flags().Bool("locality-scoped", false, "Disallow a relocation to traverse rack.id values among brokers")
 	rebalanceCmd.Flags().Bool("verbose", false, "Verbose output")
 	rebalanceCmd.Flags().String("zk-metrics-prefix", "topicmappr", "ZooKeeper namespace prefix for Kafka metrics")
 	rebalanceCmd.Flags().Int("metrics-age", 60, "Kafka metrics age tolerance (in minutes)")
 	rebalanceCmd.Flags().Bool("optimize-leaders", false, "Perform a naive leadership optimization")
+	rebalanceCmd.Flags().Int("max-moves", 0, "Cap the number of replica moves in the output maps, prioritizing moves off of the fullest brokers; remaining moves are deferred to a subsequent run (0 is unlimited)")
+	rebalanceCmd.Flags().Float64("transfer-limit-gb", 0.00, "If set, write maps as ordered batches where no broker transfers more than this many gigabytes per batch")
+	rebalanceCmd.Flags().Duration("lock-ttl", 0, "Hold a lock under --zk-metrics-prefix while building maps, failing if another instance holds it; a lock held past this duration is reported as stalled (0 disables locking)")
 
 	// Required.
 	rebalanceCmd.MarkFlagRequired("brokers")
@@ -40,17 +46,30 @@ func init() {
 }
 
 func rebalance(cmd *cobra.Command, _ []string) {
+	if mm, _ := cmd.Flags().GetInt("max-moves"); mm < 0 {
+		fmt.Fprintln(textOut, "\n[ERROR] --max-moves must be 0 or greater")
+		defaultsAndExit()
+	}
+
 	bootstrap(cmd)
 
 	// ZooKeeper init.
 	zk, err := initZooKeeper(cmd)
 	if err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+		fmt.Fprintln(textOut, err)
+		exit(1)
 	}
 
 	defer zk.Close()
 
+	// Fail fast if another instance is writing maps.
+	unlock, err := lockReassignments(cmd, zk)
+	if err != nil {
+		fmt.Fprintf(textOut, "\n[ERROR] %s\n", err)
+		exit(1)
+	}
+	defer unlock()
+
 	// Get broker and partition metadata.
 	checkMetaAge(cmd, zk)
 	brokerMeta := getBrokerMeta(cmd, zk, true)
@@ -59,8 +78,8 @@ func rebalance(cmd *cobra.Command, _ []string) {
 	// Get the current partition map.
 	partitionMap, err := kafkazk.PartitionMapFromZK(Config.topics, zk)
 	if err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+		fmt.Fprintln(textOut, err)
+		exit(1)
 	}
 
 	partitionMapOrig := partitionMap.Copy()
@@ -68,6 +87,10 @@ func rebalance(cmd *cobra.Command, _ []string) {
 	// Print topics matched to input params.
 	printTopics(partitionMap)
 
+	// Assume a zero size for any partitions
+	// missing from a partition size file.
+	sizeWarns := fillMissingSourcedPartitionMeta(cmd, partitionMap, partitionMeta)
+
 	// Get a mapping of broker IDs to topics, partitions.
 	mappings := partitionMap.Mappings()
 
@@ -129,12 +152,29 @@ func rebalance(cmd *cobra.Command, _ []string) {
 	// Update the partition map with the relocation plan.
 	applyRelocationPlan(cmd, partitionMap, params.plan)
 
+	// Defer any moves beyond --max-moves ahead of reporting so that
+	// statistics and warnings describe the capped plan. Storage free
+	// estimates are projected from the original broker map.
+	if capped := limitMoves(cmd, partitionMapOrig, partitionMap, brokersOrig); capped != partitionMap {
+		partitionMap = capped
+
+		brokers, err = brokersOrig.ProjectStorage(partitionMapOrig, partitionMap, partitionMeta)
+		if err != nil {
+			fmt.Fprintln(textOut, err)
+			exit(1)
+		}
+	}
+
 	// Print map change results.
 	printMapChanges(partitionMapOrig, partitionMap)
 
 	// Print broker assignment statistics.
 	errs := printBrokerAssignmentStats(cmd, partitionMapOrig, partitionMap, brokersOrig, brokers)
 
+	// Print assumed partition sizes. These
+	// don't prevent map creation.
+	printSizeWarns(sizeWarns)
+
 	// Handle errors that are possible
 	// to be overridden by the user (aka
 	// 'WARN' in topicmappr console output).
@@ -144,6 +184,21 @@ func rebalance(cmd *cobra.Command, _ []string) {
 	// a high percentage of these.
 	partitionMapOrig, partitionMap = skipReassignmentNoOps(partitionMapOrig, partitionMap)
 
+	// Write a diff of all changes if configured.
+	writeDiff(cmd, partitionMapOrig, partitionMap, partitionMeta)
+
+	// Suggest log dirs for new placements
+	// if log dir metadata is available.
+	partitionMap.SetLogDirs(partitionMapOrig, brokersOrig, partitionMeta)
+
 	// Write maps.
-	writeMaps(cmd, partitionMap)
+	if tl, _ := cmd.Flags().GetFloat64("transfer-limit-gb"); tl > 0 {
+		writeBatchedMaps(cmd, partitionMapOrig, partitionMap, partitionMeta)
+	} else {
+		writeMaps(cmd, partitionMap)
+	}
+
+	// Write JSON output if configured. Failures
+	// writing maps exit with a JSON error instead.
+	writeJSONOutput(partitionMap, nil)
 }
@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"fmt"
 	"math"
-	"os"
 	"sort"
 
 	"github.com/DataDog/kafka-kit/kafkazk"
@@ -91,17 +90,17 @@ func (r relocationPlan) isPlanned(p kafkazk.Partition) ([][2]int, bool) {
 func validateBrokersForRebalance(cmd *cobra.Command, brokers kafkazk.BrokerMap, bm kafkazk.BrokerMetaMap) []int {
 	// No broker changes are permitted in rebalance
 	// other than new broker additions.
-	fmt.Println("\nValidating broker list:")
+	fmt.Fprintln(textOut, "\nValidating broker list:")
 
 	// Update the current BrokerList with
 	// the provided broker list.
-	c, msgs := brokers.Update(Config.brokers, bm)
-	for m := range msgs {
-		fmt.Printf("%s%s\n", indent, m)
+	c, msgs := brokers.UpdateSorted(Config.brokers, bm)
+	for _, m := range msgs {
+		fmt.Fprintf(textOut, "%s%s\n", indent, m)
 	}
 
 	if c.Changes() {
-		fmt.Printf("%s-\n", indent)
+		fmt.Fprintf(textOut, "%s-\n", indent)
 	}
 
 	// Check if any referenced brokers are marked as having
@@ -110,14 +109,14 @@ func validateBrokersForRebalance(cmd *cobra.Command, brokers kafkazk.BrokerMap,
 
 	switch {
 	case c.Missing > 0, c.OldMissing > 0, c.Replace > 0:
-		fmt.Printf("%s[ERROR] rebalance only allows broker additions\n", indent)
-		os.Exit(1)
+		fmt.Fprintf(textOut, "%s[ERROR] rebalance only allows broker additions (%s)\n", indent, c)
+		exit(1)
 	case c.New > 0:
-		fmt.Printf("%s%d additional brokers added\n", indent, c.New)
-		fmt.Printf("%s-\n", indent)
+		fmt.Fprintf(textOut, "%s%d additional brokers added\n", indent, c.New)
+		fmt.Fprintf(textOut, "%s-\n", indent)
 		fallthrough
 	default:
-		fmt.Printf("%sOK\n", indent)
+		fmt.Fprintf(textOut, "%sOK\n", indent)
 	}
 
 	st, _ := cmd.Flags().GetFloat64("storage-threshold")
@@ -175,29 +174,29 @@ func validateBrokersForRebalance(cmd *cobra.Command, brokers kafkazk.BrokerMap,
 	// Print rebalance parameters as a result of
 	// input configurations and brokers found
 	// to be beyond the storage threshold.
-	fmt.Println("\nRebalance parameters:")
+	fmt.Fprintln(textOut, "\nRebalance parameters:")
 
 	tol, _ := cmd.Flags().GetFloat64("tolerance")
 	mean, hMean := brokers.Mean(), brokers.HMean()
 
-	fmt.Printf("%sFree storage mean, harmonic mean: %.2fGB, %.2fGB\n",
+	fmt.Fprintf(textOut, "%sFree storage mean, harmonic mean: %.2fGB, %.2fGB\n",
 		indent, mean/div, hMean/div)
 
-	fmt.Printf("%sBroker free storage limits (with a %.2f%% tolerance from mean):\n",
+	fmt.Fprintf(textOut, "%sBroker free storage limits (with a %.2f%% tolerance from mean):\n",
 		indent, tol*100)
 
-	fmt.Printf("%s%sSources limited to <= %.2fGB\n", indent, indent, mean*(1+tol)/div)
-	fmt.Printf("%s%sDestinations limited to >= %.2fGB\n", indent, indent, mean*(1-tol)/div)
+	fmt.Fprintf(textOut, "%s%sSources limited to <= %.2fGB\n", indent, indent, mean*(1+tol)/div)
+	fmt.Fprintf(textOut, "%s%sDestinations limited to >= %.2fGB\n", indent, indent, mean*(1-tol)/div)
 
-	fmt.Printf("\n%s:\n", selectorMethod.String())
+	fmt.Fprintf(textOut, "\n%s:\n", selectorMethod.String())
 
 	// Exit if no target brokers were found.
 	if len(offloadTargets) == 0 {
-		fmt.Printf("%s[none]\n", indent)
-		os.Exit(0)
+		fmt.Fprintf(textOut, "%s[none]\n", indent)
+		exit(0)
 	} else {
 		for _, id := range offloadTargets {
-			fmt.Printf("%s%d\n", indent, id)
+			fmt.Fprintf(textOut, "%s%d\n", indent, id)
 		}
 	}
 
@@ -208,6 +207,7 @@ func planRelocationsForBroker(cmd *cobra.Command, params planRelocationsForBroke
 	verbose, _ := cmd.Flags().GetBool("verbose")
 	tolerance, _ := cmd.Flags().GetFloat64("tolerance")
 	localityScoped, _ := cmd.Flags().GetBool("locality-scoped")
+	minFree, _ := cmd.Flags().GetFloat64("min-storage-free-gb")
 
 	relos := params.relos
 	mappings := params.mappings
@@ -226,12 +226,12 @@ func planRelocationsForBroker(cmd *cobra.Command, params planRelocationsForBroke
 	topPartn, _ := mappings.LargestPartitions(sourceID, topPartitionsLimit, partitionMeta)
 
 	if verbose {
-		fmt.Printf("\n[pass %d] Broker %d has a storage free of %.2fGB. Top partitions:\n",
+		fmt.Fprintf(textOut, "\n[pass %d] Broker %d has a storage free of %.2fGB. Top partitions:\n",
 			params.pass, sourceID, brokers[sourceID].StorageFree/div)
 
 		for _, p := range topPartn {
 			pSize, _ := partitionMeta.Size(p)
-			fmt.Printf("%s%s p%d: %.2fGB\n",
+			fmt.Fprintf(textOut, "%s%s p%d: %.2fGB\n",
 				indent, p.Topic, p.Partition, pSize/div)
 		}
 	}
@@ -308,9 +308,9 @@ func planRelocationsForBroker(cmd *cobra.Command, params planRelocationsForBroke
 		}
 
 		if verbose {
-			fmt.Printf("%s-\n", indent)
-			fmt.Printf("%sAttempting migration plan for %s p%d\n", indent, partn.Topic, partn.Partition)
-			fmt.Printf("%sCandidate destination broker %d has a storage free of %.2fGB\n",
+			fmt.Fprintf(textOut, "%s-\n", indent)
+			fmt.Fprintf(textOut, "%sAttempting migration plan for %s p%d\n", indent, partn.Topic, partn.Partition)
+			fmt.Fprintf(textOut, "%sCandidate destination broker %d has a storage free of %.2fGB\n",
 				indent, dest.ID, dest.StorageFree/div)
 		}
 
@@ -324,7 +324,7 @@ func planRelocationsForBroker(cmd *cobra.Command, params planRelocationsForBroke
 		sLim := meanStorageFree * (1 + tolerance)
 		if sourceFree > sLim {
 			if verbose {
-				fmt.Printf("%sCannot move partition from target: "+
+				fmt.Fprintf(textOut, "%sCannot move partition from target: "+
 					"expected storage free %.2fGB above tolerated threshold of %.2fGB\n",
 					indent, sourceFree/div, sLim/div)
 			}
@@ -335,7 +335,7 @@ func planRelocationsForBroker(cmd *cobra.Command, params planRelocationsForBroke
 		dLim := meanStorageFree * (1 - tolerance)
 		if destFree < dLim {
 			if verbose {
-				fmt.Printf("%sCannot move partition to candidate: "+
+				fmt.Fprintf(textOut, "%sCannot move partition to candidate: "+
 					"expected storage free %.2fGB below tolerated threshold of %.2fGB\n",
 					indent, destFree/div, dLim/div)
 			}
@@ -343,6 +343,18 @@ func planRelocationsForBroker(cmd *cobra.Command, params planRelocationsForBroke
 			continue
 		}
 
+		// The destination must retain the
+		// minimum storage free.
+		if destFree < minFree*div {
+			if verbose {
+				fmt.Fprintf(textOut, "%sCannot move partition to candidate: "+
+					"expected storage free %.2fGB below minimum of %.2fGB\n",
+					indent, destFree/div, minFree)
+			}
+
+			continue
+		}
+
 		// Otherwise, schedule the relocation.
 
 		relos[sourceID] = append(relos[sourceID], relocation{partition: partn, destination: dest.ID})
@@ -360,7 +372,7 @@ func planRelocationsForBroker(cmd *cobra.Command, params planRelocationsForBroke
 		mappings.Remove(sourceID, partn)
 
 		if verbose {
-			fmt.Printf("%sPlanning relocation to candidate\n", indent)
+			fmt.Fprintf(textOut, "%sPlanning relocation to candidate\n", indent)
 		}
 
 		// Break at the first placement.
@@ -397,22 +409,22 @@ func printPlannedRelocations(targets []int, relos map[int][]relocation, pmm kafk
 	var total float64
 
 	for _, id := range targets {
-		fmt.Printf("\nBroker %d relocations planned:\n", id)
+		fmt.Fprintf(textOut, "\nBroker %d relocations planned:\n", id)
 
 		if _, exist := relos[id]; !exist {
-			fmt.Printf("%s[none]\n", indent)
+			fmt.Fprintf(textOut, "%s[none]\n", indent)
 			continue
 		}
 
 		for _, r := range relos[id] {
 			pSize, _ := pmm.Size(r.partition)
 			total += pSize / div
-			fmt.Printf("%s[%.2fGB] %s p%d -> %d\n",
+			fmt.Fprintf(textOut, "%s[%.2fGB] %s p%d -> %d\n",
 				indent, pSize/div, r.partition.Topic, r.partition.Partition, r.destination)
 		}
 	}
-	fmt.Printf("%s-\n", indent)
-	fmt.Printf("%sTotal relocation volume: %.2fGB\n", indent, total)
+	fmt.Fprintf(textOut, "%s-\n", indent)
+	fmt.Fprintf(textOut, "%sTotal relocation volume: %.2fGB\n", indent, total)
 }
 
 func absDistance(x, t float64) float64 {
@@ -0,0 +1,68 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/DataDog/kafka-kit/kafkazk"
+
+	"github.com/spf13/cobra"
+)
+
+var rebalanceLeadershipCmd = &cobra.Command{
+	Use:   "rebalance-leadership",
+	Short: "Even out leader counts by reordering replica sets without moving data",
+	Long: `rebalance-leadership reorders the replica sets of the target topics so that
+preferred leadership (the first replica of each set) is distributed as evenly as
+possible among brokers. Replica set membership is never changed, so applying the
+resulting map moves no data and only changes preferred leaders. Starting from the
+current partition map, only the replica sets required to flatten leader counts are
+reordered, making this safe to run frequently. Target topics are provided via
+--topics and discovered in ZooKeeper.`,
+	Run: rebalanceLeadership,
+}
+
+func init() {
+	rootCmd.AddCommand(rebalanceLeadershipCmd)
+
+	rebalanceLeadershipCmd.Flags().String("topics", "", "Rebalance leadership for topics (comma delim. list) by lookup in ZooKeeper")
+	rebalanceLeadershipCmd.Flags().String("out-path", "", "Path to write output map files to")
+	rebalanceLeadershipCmd.Flags().String("out-file", "", "If defined, write a combined map of all topics to a file")
+	rebalanceLeadershipCmd.Flags().String("output-format", "json", "Output map format: [json, yaml]")
+
+	// Required.
+	rebalanceLeadershipCmd.MarkFlagRequired("topics")
+}
+
+func rebalanceLeadership(cmd *cobra.Command, _ []string) {
+	bootstrap(cmd)
+
+	// ZooKeeper init.
+	zk, err := initZooKeeper(cmd)
+	if err != nil {
+		fmt.Fprintln(textOut, err)
+		exit(1)
+	}
+
+	defer zk.Close()
+
+	// Get the current partition map.
+	partitionMap, err := kafkazk.PartitionMapFromZK(Config.topics, zk)
+	if err != nil {
+		fmt.Fprintln(textOut, err)
+		exit(1)
+	}
+
+	partitionMapOrig := partitionMap.Copy()
+
+	printTopics(partitionMap)
+
+	partitionMap.BalanceLeaders()
+
+	printMapChanges(partitionMapOrig, partitionMap)
+	printLeadershipStats(partitionMapOrig, partitionMap)
+
+	// Only emit changed replica sets.
+	_, partitionMap = skipReassignmentNoOps(partitionMapOrig, partitionMap)
+
+	writeMaps(cmd, partitionMap)
+}
@@ -2,11 +2,12 @@ package commands
 
 import (
 	"fmt"
-	"os"
+	"strings"
 
 	"github.com/DataDog/kafka-kit/kafkazk"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
 var rebuildCmd = &cobra.Command{
@@ -17,7 +18,9 @@ target topics and a list of broker IDs to which those topics should be mapped.
 Target topics are provided as a comma delimited list of topic names and/or regex patterns
 via the --topics parameter, which discovers matching topics in ZooKeeper (additionally,
 the --zk-addr and --zk-prefix global flags should be set). Alternatively, a JSON map can be
-provided via the --map-string flag. Target broker IDs are provided via the --broker flag.`,
+provided via the --map-string flag. Target broker IDs are provided via the --broker flag.
+A topic that doesn't yet exist can be placed from scratch by providing its name via
+--new-topic along with --partitions and --replication.`,
 	Run: rebuild,
 }
 
@@ -26,69 +29,209 @@ func init() {
 
 	rebuildCmd.Flags().String("topics", "", "Rebuild topics (comma delim. list) by lookup in ZooKeeper")
 	rebuildCmd.Flags().String("map-string", "", "Rebuild a partition map provided as a string literal")
+	rebuildCmd.Flags().String("new-topic", "", "Build an initial partition map for a topic that doesn't yet exist (requires --partitions and --replication)")
+	rebuildCmd.Flags().Int("partitions", 0, "Partition count for --new-topic")
 	rebuildCmd.Flags().Bool("use-meta", true, "Use broker metadata in placement constraints")
 	rebuildCmd.Flags().String("out-path", "", "Path to write output map files to")
 	rebuildCmd.Flags().String("out-file", "", "If defined, write a combined map of all topics to a file")
+	rebuildCmd.Flags().String("output-format", "json", "Output map format: [json, yaml]")
+	rebuildCmd.Flags().String("diff-file", "", "If defined, write a JSON diff of all partition map changes to a file")
 	rebuildCmd.Flags().Bool("force-rebuild", false, "Forces a complete map rebuild")
-	rebuildCmd.Flags().Int("replication", 0, "Normalize the topic replication factor across all replica sets (0 results in a no-op)")
+	rebuildCmd.Flags().Int("replication", 0, "Normalize the topic replication factor across all replica sets; also accepted as --replication-factor (0 results in a no-op)")
 	rebuildCmd.Flags().Bool("sub-affinity", false, "Replacement broker substitution affinity")
 	rebuildCmd.Flags().String("placement", "count", "Partition placement strategy: [count, storage]")
+	rebuildCmd.Flags().String("strategy", "balanced", "Replica spread strategy: [balanced, compact]; compact packs replicas onto the fewest brokers satisfying all constraints")
 	rebuildCmd.Flags().String("optimize", "distribution", "Optimization priority for the storage placement strategy: [distribution, storage]")
 	rebuildCmd.Flags().Float64("partition-size-factor", 1.0, "Factor by which to multiply partition sizes when using storage placement")
+	rebuildCmd.Flags().String("partition-size-source", "zk", "Partition size source: [zk, <path>]; a path is a JSON file mapping topic:partition to bytes")
+	rebuildCmd.Flags().String("missing-partition-size", "", "Size to assume for partitions missing from partition metadata rather than failing: [mean, <GB>] (default none)")
+	rebuildCmd.Flags().Float64("min-storage-free-gb", 0.00, "Reject placements that would leave a broker with less than this many gigabytes of storage free (when using storage placement)")
 	rebuildCmd.Flags().String("brokers", "", "Broker list to scope all partition placements to")
+	rebuildCmd.Flags().String("exclude-brokers", "", "Brokers (comma delim. list) ineligible for placements; existing replicas are moved off of them")
 	rebuildCmd.Flags().String("zk-metrics-prefix", "topicmappr", "ZooKeeper namespace prefix for Kafka metrics (when using storage placement)")
 	rebuildCmd.Flags().Int("metrics-age", 60, "Kafka metrics age tolerance (in minutes) (when using storage placement)")
 	rebuildCmd.Flags().Bool("skip-no-ops", false, "Skip no-op partition assigments")
+	rebuildCmd.Flags().Bool("show-moves", false, "Print the before and after replica lists of each partition with a changed assignment")
+	rebuildCmd.Flags().Int("max-moves", 0, "Cap the number of replica moves in the output maps, prioritizing moves off of the fullest brokers; remaining moves are deferred to a subsequent run (0 is unlimited)")
+	rebuildCmd.Flags().Float64("transfer-limit-gb", 0.00, "If set, write maps as ordered batches where no broker transfers more than this many gigabytes per batch")
+	rebuildCmd.Flags().String("leader-policy", "", "Preferred leader selection policy applied to all output replica sets: [count, storage, bytes, rack:<id>] (default none)")
+	rebuildCmd.Flags().Float64("warm-new-brokers", 0.0, "Seed new brokers with this fraction of the mean broker partition count to ramp up placements (when using count placement)")
+	rebuildCmd.Flags().String("seed", "", "Seed for ordering equally used brokers in count placement: [auto, <int>]; auto derives a seed from the eligible broker IDs and topic name for reproducible maps (default none)")
+	rebuildCmd.Flags().String("controller-placement", "", "Controller broker policy for new replica placements: [deprioritize, exclude] (default none)")
+	rebuildCmd.Flags().Bool("topic-spread", false, "Among otherwise equal candidates, prefer brokers holding the fewest partitions of the topic being placed")
+	rebuildCmd.Flags().Int("observers-per-partition", 0, "Number of replicas per partition to designate as observers, preferring racks remote to the synchronous replicas (0 results in a no-op)")
+	rebuildCmd.Flags().Int("max-partitions-per-broker", 0, "Maximum number of partition replicas a broker may hold to be selected for new placements (0 is unlimited)")
+	rebuildCmd.Flags().Bool("rack-weighted", false, "Prefer racks in proportion to the storage free of each rack (requires broker metrics)")
+	rebuildCmd.Flags().String("anti-affinity-tags", "", "Registry broker tag keys (comma delim. list) that define anti-affinity groups; brokers sharing a tag value won't share replica sets")
+	rebuildCmd.Flags().String("zk-tags-prefix", "registry", "ZooKeeper namespace prefix for registry tags (when using --anti-affinity-tags)")
+	rebuildCmd.Flags().String("affinity-rules", "", "Path to a YAML or JSON file of topic anti-affinity groups; new replicas avoid brokers holding replicas of other topics in the same group")
+	rebuildCmd.Flags().Duration("lock-ttl", 0, "Hold a lock under --zk-metrics-prefix while building maps, failing if another instance holds it; a lock held past this duration is reported as stalled (0 disables locking)")
+	rebuildCmd.Flags().String("placement-metrics-file", "", "If set, write placement stats to this path in the Prometheus text format, such as for the node_exporter textfile collector")
+	rebuildCmd.Flags().String("policy-file", "", "Path to a YAML or JSON policy file of placement settings (explicitly set flags take precedence)")
 
-	// Required.
-	rebuildCmd.MarkFlagRequired("brokers")
+	// Accept --replication-factor as an alias of --replication.
+	rebuildCmd.Flags().SetNormalizeFunc(func(_ *pflag.FlagSet, name string) pflag.NormalizedName {
+		if name == "replication-factor" {
+			name = "replication"
+		}
+		return pflag.NormalizedName(name)
+	})
 }
 
 func rebuild(cmd *cobra.Command, _ []string) {
+	// Apply any policy file settings.
+	if err := loadPolicyFile(cmd); err != nil {
+		fmt.Fprintf(textOut, "\n[ERROR] %s\n", err)
+		defaultsAndExit()
+	}
+
 	// Sanity check params.
 	t, _ := cmd.Flags().GetString("topics")
 	ms, _ := cmd.Flags().GetString("map-string")
+	nt, _ := cmd.Flags().GetString("new-topic")
+	np, _ := cmd.Flags().GetInt("partitions")
 	p := cmd.Flag("placement").Value.String()
 	o := cmd.Flag("optimize").Value.String()
+	st := cmd.Flag("strategy").Value.String()
 	fr, _ := cmd.Flags().GetBool("force-rebuild")
 	sa, _ := cmd.Flags().GetBool("sub-affinity")
 	m, _ := cmd.Flags().GetBool("use-meta")
+	wn, _ := cmd.Flags().GetFloat64("warm-new-brokers")
+	lp, _ := cmd.Flags().GetString("leader-policy")
+	tl, _ := cmd.Flags().GetFloat64("transfer-limit-gb")
+	mm, _ := cmd.Flags().GetInt("max-moves")
+	mf, _ := cmd.Flags().GetFloat64("min-storage-free-gb")
+	mps, _ := cmd.Flags().GetString("missing-partition-size")
+	cp, _ := cmd.Flags().GetString("controller-placement")
+	b, _ := cmd.Flags().GetString("brokers")
+	at, _ := cmd.Flags().GetString("anti-affinity-tags")
+	rw, _ := cmd.Flags().GetBool("rack-weighted")
+	rf, _ := cmd.Flags().GetInt("replication")
+	mp, _ := cmd.Flags().GetInt("max-partitions-per-broker")
+	eb, _ := cmd.Flags().GetString("exclude-brokers")
+	ob, _ := cmd.Flags().GetInt("observers-per-partition")
+	lt, _ := cmd.Flags().GetDuration("lock-ttl")
+	ar, _ := cmd.Flags().GetString("affinity-rules")
+	sd, _ := cmd.Flags().GetString("seed")
+	_, _, seedErr := parseSeed(sd)
 
 	switch {
-	case ms == "" && t == "":
-		fmt.Println("\n[ERROR] must specify either --topics or --map-string")
+	case b == "":
+		fmt.Fprintln(textOut, "\n[ERROR] must specify --brokers (or brokers in a --policy-file)")
+		defaultsAndExit()
+	case ms == "" && t == "" && nt == "":
+		fmt.Fprintln(textOut, "\n[ERROR] must specify either --topics, --map-string or --new-topic")
+		defaultsAndExit()
+	case nt != "" && (ms != "" || t != ""):
+		fmt.Fprintln(textOut, "\n[ERROR] --new-topic can't be combined with --topics or --map-string")
+		defaultsAndExit()
+	case nt != "" && (np <= 0 || rf <= 0):
+		fmt.Fprintln(textOut, "\n[ERROR] --new-topic requires --partitions and --replication")
+		defaultsAndExit()
+	case nt != "" && p == "storage" && mps == "":
+		fmt.Fprintln(textOut, "\n[ERROR] --new-topic with --placement=storage requires --missing-partition-size")
+		defaultsAndExit()
+	case np != 0 && nt == "":
+		fmt.Fprintln(textOut, "\n[ERROR] --partitions requires --new-topic")
 		defaultsAndExit()
 	case p != "count" && p != "storage":
-		fmt.Println("\n[ERROR] --placement must be either 'count' or 'storage'")
+		fmt.Fprintln(textOut, "\n[ERROR] --placement must be either 'count' or 'storage'")
 		defaultsAndExit()
 	case o != "distribution" && o != "storage":
-		fmt.Println("\n[ERROR] --optimize must be either 'distribution' or 'storage'")
+		fmt.Fprintln(textOut, "\n[ERROR] --optimize must be either 'distribution' or 'storage'")
+		defaultsAndExit()
+	case st != "balanced" && st != "compact":
+		fmt.Fprintln(textOut, "\n[ERROR] --strategy must be either 'balanced' or 'compact'")
 		defaultsAndExit()
 	case !m && p == "storage":
-		fmt.Println("\n[ERROR] --placement=storage requires --use-meta=true")
+		fmt.Fprintln(textOut, "\n[ERROR] --placement=storage requires --use-meta=true")
+		defaultsAndExit()
+	case !m && rw:
+		fmt.Fprintln(textOut, "\n[ERROR] --rack-weighted requires --use-meta=true")
+		defaultsAndExit()
+	case !m && at != "":
+		fmt.Fprintln(textOut, "\n[ERROR] --anti-affinity-tags requires --use-meta=true")
+		defaultsAndExit()
+	case wn < 0 || wn > 1:
+		fmt.Fprintln(textOut, "\n[ERROR] --warm-new-brokers must be between 0.00 and 1.00")
+		defaultsAndExit()
+	case lp != "" && lp != "count" && lp != "storage" && lp != "bytes" && !strings.HasPrefix(lp, "rack:"):
+		fmt.Fprintln(textOut, "\n[ERROR] --leader-policy must be either 'count', 'storage', 'bytes' or 'rack:<id>'")
+		defaultsAndExit()
+	case lp == "rack:":
+		fmt.Fprintln(textOut, "\n[ERROR] --leader-policy rack:<id> requires a rack ID")
+		defaultsAndExit()
+	case tl < 0:
+		fmt.Fprintln(textOut, "\n[ERROR] --transfer-limit-gb must be greater than 0")
+		defaultsAndExit()
+	case mf < 0:
+		fmt.Fprintln(textOut, "\n[ERROR] --min-storage-free-gb must be 0 or greater")
+		defaultsAndExit()
+	case mm < 0:
+		fmt.Fprintln(textOut, "\n[ERROR] --max-moves must be 0 or greater")
+		defaultsAndExit()
+	case rf < 0:
+		fmt.Fprintln(textOut, "\n[ERROR] --replication must be 0 or greater")
+		defaultsAndExit()
+	case ob < 0:
+		fmt.Fprintln(textOut, "\n[ERROR] --observers-per-partition must be 0 or greater")
+		defaultsAndExit()
+	case rf > 0 && ob >= rf:
+		fmt.Fprintln(textOut, "\n[ERROR] --observers-per-partition must be less than --replication")
+		defaultsAndExit()
+	case mp < 0:
+		fmt.Fprintln(textOut, "\n[ERROR] --max-partitions-per-broker must be 0 or greater")
+		defaultsAndExit()
+	case cp != "" && cp != "deprioritize" && cp != "exclude":
+		fmt.Fprintln(textOut, "\n[ERROR] --controller-placement must be either 'deprioritize' or 'exclude'")
+		defaultsAndExit()
+	case mps != "" && mps != "mean" && !isPositiveFloat(mps):
+		fmt.Fprintln(textOut, "\n[ERROR] --missing-partition-size must be either 'mean' or a size in gigabytes")
+		defaultsAndExit()
+	case seedErr != nil:
+		fmt.Fprintf(textOut, "\n[ERROR] %s\n", seedErr)
 		defaultsAndExit()
 	case fr && sa:
-		fmt.Println("\n[INFO] --force-rebuild disables --sub-affinity")
+		fmt.Fprintln(textOut, "\n[INFO] --force-rebuild disables --sub-affinity")
+	}
+
+	// Load any topic affinity rules.
+	var topicAntiAffinity *kafkazk.TopicAntiAffinity
+	if ar != "" {
+		var err error
+		if topicAntiAffinity, err = affinityRulesFromFile(ar); err != nil {
+			fmt.Fprintf(textOut, "\n[ERROR] %s\n", err)
+			defaultsAndExit()
+		}
 	}
 
 	bootstrap(cmd)
 
 	// ZooKeeper init.
 	var zk kafkazk.Handler
-	if m || len(Config.topics) > 0 || p == "storage" {
+	if m || len(Config.topics) > 0 || nt != "" || p == "storage" || tl > 0 || cp != "" || lp == "bytes" || lt != 0 || ar != "" {
 		var err error
 		zk, err = initZooKeeper(cmd)
 		if err != nil {
-			fmt.Println(err)
-			os.Exit(1)
+			fmt.Fprintln(textOut, err)
+			exit(1)
 		}
 		defer zk.Close()
 	}
 
+	// Fail fast if another instance is writing maps.
+	unlock, err := lockReassignments(cmd, zk)
+	if err != nil {
+		fmt.Fprintf(textOut, "\n[ERROR] %s\n", err)
+		exit(1)
+	}
+	defer unlock()
+
 	// General flow:
 	// 1) A PartitionMap is formed (either unmarshaled from the literal
-	//   map input via --rebuild-map or generated from ZooKeeper Metadata
-	//   for topics matching --topics).
+	//   map input via --rebuild-map, generated from ZooKeeper Metadata
+	//   for topics matching --topics, or populated with stub brokers
+	//   for a --new-topic).
 	// 2) A BrokerMap is formed from brokers found in the PartitionMap
 	//   along with any new brokers provided via the --brokers param.
 	// 3) The PartitionMap and BrokerMap are fed to a rebuild
@@ -101,7 +244,7 @@ func rebuild(cmd *cobra.Command, _ []string) {
 
 	// Fetch broker metadata.
 	var withMetrics bool
-	if cmd.Flag("placement").Value.String() == "storage" {
+	if cmd.Flag("placement").Value.String() == "storage" || rw {
 		checkMetaAge(cmd, zk)
 		withMetrics = true
 	}
@@ -109,11 +252,12 @@ func rebuild(cmd *cobra.Command, _ []string) {
 	var brokerMeta kafkazk.BrokerMetaMap
 	if m, _ := cmd.Flags().GetBool("use-meta"); m {
 		brokerMeta = getBrokerMeta(cmd, zk, withMetrics)
+		setAntiAffinityGroups(cmd, zk, brokerMeta)
 	}
 
 	// Fetch partition metadata.
 	var partitionMeta kafkazk.PartitionMetaMap
-	if cmd.Flag("placement").Value.String() == "storage" {
+	if cmd.Flag("placement").Value.String() == "storage" || tl > 0 || lp == "bytes" {
 		partitionMeta = getPartitionMeta(cmd, zk)
 	}
 
@@ -125,11 +269,24 @@ func rebuild(cmd *cobra.Command, _ []string) {
 	// Get a list of affected topics.
 	printTopics(partitionMapIn)
 
+	// Fetch the assignments of any other
+	// topics in anti-affinity groups.
+	if topicAntiAffinity != nil {
+		if err := setExistingTopics(zk, topicAntiAffinity, partitionMapIn); err != nil {
+			fmt.Fprintf(textOut, "Error fetching anti-affinity topics: %s\n", err)
+			exit(1)
+		}
+	}
+
+	// Fill in any missing partition sizes if configured.
+	sizeWarns := fillMissingPartitionMeta(cmd, partitionMapIn, partitionMeta)
+	sizeWarns = append(sizeWarns, fillMissingSourcedPartitionMeta(cmd, partitionMapIn, partitionMeta)...)
+
 	brokers, bs := getBrokers(cmd, partitionMapIn, brokerMeta)
 	brokersOrig := brokers.Copy()
 
 	if bs.Changes() {
-		fmt.Printf("%s-\n", indent)
+		fmt.Fprintf(textOut, "%s-\n", indent)
 	}
 
 	// Check if any referenced brokers are marked as having
@@ -142,18 +299,61 @@ func rebuild(cmd *cobra.Command, _ []string) {
 	affinities := getSubAffinities(cmd, brokers, brokersOrig, partitionMapIn)
 
 	if affinities != nil {
-		fmt.Printf("%s-\n", indent)
+		fmt.Fprintf(textOut, "%s-\n", indent)
 	}
 
 	// Print changes, actions.
 	printChangesActions(cmd, bs)
 
+	// Ensure that enough brokers remain
+	// eligible for placements.
+	if eb != "" {
+		if err := checkExcludedBrokers(cmd, partitionMapIn, brokers); err != nil {
+			fmt.Fprintf(textOut, "\n[ERROR] %s\n", err)
+			exit(1)
+		}
+	}
+
 	// Apply any replication factor settings.
-	updateReplicationFactor(cmd, partitionMapIn)
+	updateReplicationFactor(cmd, partitionMapIn, brokers)
 
 	// Build a new map using the provided list of brokers.
 	// This is OK to run even when a no-op is intended.
-	partitionMapOut, errs := buildMap(cmd, partitionMapIn, partitionMeta, brokers, affinities)
+	placementStats := kafkazk.NewPlacementStats()
+	controller := getController(cmd, zk)
+	partitionMapOut, errs := buildMap(cmd, partitionMapIn, partitionMeta, brokers, affinities, topicAntiAffinity, controller, placementStats)
+
+	// Fail if any replicas couldn't be placed
+	// under the partition count cap.
+	if mp > 0 {
+		if unplaced := unplacedPartitions(partitionMapIn, partitionMapOut); len(unplaced) > 0 {
+			fmt.Fprintf(textOut, "\n[ERROR] unable to place all replicas with --max-partitions-per-broker %d; partitions not placed:\n", mp)
+			for _, p := range unplaced {
+				fmt.Fprintf(textOut, "%s%s\n", indent, p)
+			}
+			exit(1)
+		}
+	}
+
+	// Defer any moves beyond --max-moves ahead of reporting so that
+	// statistics and warnings describe the capped plan. Storage free
+	// estimates are projected from the original broker map.
+	if capped := limitMoves(cmd, originalMap, partitionMapOut, brokersOrig); capped != partitionMapOut {
+		partitionMapOut = capped
+		if p == "storage" {
+			if brokers, err = projectBrokers(cmd, originalMap, partitionMapOut, partitionMeta, brokersOrig); err != nil {
+				fmt.Fprintln(textOut, err)
+				exit(1)
+			}
+		}
+	}
+
+	// Apply any leader policy.
+	applyLeaderPolicy(cmd, partitionMapOut, brokers, partitionMeta)
+
+	// Designate any observers. This follows the leader
+	// policy since the leader is never an observer.
+	errs = append(errs, applyObservers(cmd, partitionMapOut, brokers)...)
 
 	// Count missing brokers as a warning.
 	if bs.Missing > 0 {
@@ -162,10 +362,36 @@ func rebuild(cmd *cobra.Command, _ []string) {
 
 	// Print map change results.
 	printMapChanges(originalMap, partitionMapOut)
+	printPartitionMoves(cmd, originalMap, partitionMapOut)
 
 	// Print broker assignment statistics.
 	printBrokerAssignmentStats(cmd, originalMap, partitionMapOut, brokersOrig, brokers)
 
+	// Print placement algorithm statistics and
+	// write them as metrics if configured.
+	printPlacementStats(placementStats)
+	writePlacementMetrics(cmd, placementStats)
+
+	// Print leader bytes statistics if balancing by bytes.
+	if lp == "bytes" {
+		printLeaderBytesStats(originalMap, partitionMapOut, partitionMeta)
+	}
+
+	// Check topic anti-affinity, including any retained replicas.
+	// Strict violations introduced by the rebuild are fatal
+	// regardless of --ignore-warns.
+	if violations := checkTopicAntiAffinity(topicAntiAffinity, originalMap, partitionMapOut); len(violations) > 0 {
+		fmt.Fprintf(textOut, "\n[ERROR] strict topic anti-affinity violated, partition map not created:\n")
+		for _, v := range violations {
+			fmt.Fprintf(textOut, "%s%s\n", indent, v)
+		}
+		exit(1)
+	}
+
+	// Print assumed partition sizes. These
+	// don't prevent map creation.
+	printSizeWarns(sizeWarns)
+
 	// Print error/warnings.
 	handleOverridableErrs(cmd, errs)
 
@@ -174,5 +400,20 @@ func rebuild(cmd *cobra.Command, _ []string) {
 		originalMap, partitionMapOut = skipReassignmentNoOps(originalMap, partitionMapOut)
 	}
 
-	writeMaps(cmd, partitionMapOut)
+	// Write a diff of all changes if configured.
+	writeDiff(cmd, originalMap, partitionMapOut, partitionMeta)
+
+	// Suggest log dirs for new placements
+	// if log dir metadata is available.
+	partitionMapOut.SetLogDirs(originalMap, brokers, partitionMeta)
+
+	if tl > 0 {
+		writeBatchedMaps(cmd, originalMap, partitionMapOut, partitionMeta)
+	} else {
+		writeMaps(cmd, partitionMapOut)
+	}
+
+	// Write JSON output if configured. Failures
+	// writing maps exit with a JSON error instead.
+	writeJSONOutput(partitionMapOut, bs)
 }
@@ -2,7 +2,8 @@ package commands
 
 import (
 	"fmt"
-	"os"
+	"regexp"
+	"strconv"
 
 	"github.com/DataDog/kafka-kit/kafkazk"
 
@@ -18,16 +19,19 @@ import (
 // literal input (json from off-the-shelf Kafka tools output) provided
 // via the ---map-string flag, or, by building a map based on topic
 // config found in ZooKeeper for all topics matching input provided
-// via the --topics flag.
+// via the --topics flag, or, for a topic that doesn't yet exist, by
+// populating a map of stub brokers via the --new-topic flag.
 func getPartitionMap(cmd *cobra.Command, zk kafkazk.Handler) *kafkazk.PartitionMap {
 	ms := cmd.Flag("map-string").Value.String()
+	nt, _ := cmd.Flags().GetString("new-topic")
+
 	switch {
 	// The map was provided as text.
 	case ms != "":
 		pm, err := kafkazk.PartitionMapFromString(ms)
 		if err != nil {
-			fmt.Println(err)
-			os.Exit(1)
+			fmt.Fprintln(textOut, err)
+			exit(1)
 		}
 
 		return pm
@@ -36,8 +40,17 @@ func getPartitionMap(cmd *cobra.Command, zk kafkazk.Handler) *kafkazk.PartitionM
 	case len(Config.topics) > 0:
 		pm, err := kafkazk.PartitionMapFromZK(Config.topics, zk)
 		if err != nil {
-			fmt.Println(err)
-			os.Exit(1)
+			fmt.Fprintln(textOut, err)
+			exit(1)
+		}
+		return pm
+	// Build a map of stub brokers for a new topic;
+	// all replicas are placed in the rebuild.
+	case nt != "":
+		pm, err := newTopicMap(cmd, zk)
+		if err != nil {
+			fmt.Fprintf(textOut, "\n[ERROR] %s\n", err)
+			exit(1)
 		}
 		return pm
 	}
@@ -45,6 +58,62 @@ func getPartitionMap(cmd *cobra.Command, zk kafkazk.Handler) *kafkazk.PartitionM
 	return nil
 }
 
+// newTopicMap returns a PartitionMap for the --new-topic with the configured
+// partition count and replication factor. All replicas reference the stub
+// broker ID 0. An error is returned if the topic already exists.
+func newTopicMap(cmd *cobra.Command, zk kafkazk.Handler) (*kafkazk.PartitionMap, error) {
+	name, _ := cmd.Flags().GetString("new-topic")
+	partitions, _ := cmd.Flags().GetInt("partitions")
+	replication, _ := cmd.Flags().GetInt("replication")
+
+	if zk != nil {
+		re := regexp.MustCompile(fmt.Sprintf("^%s$", regexp.QuoteMeta(name)))
+		existing, err := zk.GetTopics([]*regexp.Regexp{re})
+		if err != nil {
+			return nil, err
+		}
+
+		if len(existing) > 0 {
+			return nil, fmt.Errorf("topic %s already exists; use --topics to rebuild it", name)
+		}
+	}
+
+	return kafkazk.NewTopicPartitionMap(name, partitions, replication), nil
+}
+
+// fillMissingPartitionMeta, if a size is configured via --missing-partition-size,
+// adds that size to the PartitionMetaMap for any partitions in the PartitionMap
+// missing from it. The size is either the mean of all known partition sizes or
+// a value in gigabytes. A warning is returned for each filled partition, other
+// than those of a --new-topic, which are never sized.
+func fillMissingPartitionMeta(cmd *cobra.Command, pm *kafkazk.PartitionMap, pmm kafkazk.PartitionMetaMap) errors {
+	mps, _ := cmd.Flags().GetString("missing-partition-size")
+	if mps == "" || pmm == nil {
+		return nil
+	}
+
+	var size float64
+	if mps == "mean" {
+		size = pmm.MeanSize()
+	} else {
+		gb, _ := strconv.ParseFloat(mps, 64)
+		size = gb * div
+	}
+
+	filled := pmm.FillMissing(pm, size)
+	if nt, _ := cmd.Flags().GetString("new-topic"); nt != "" {
+		return nil
+	}
+
+	var warns errors
+	for _, p := range filled {
+		warns = append(warns, fmt.Errorf("%s p%d: partition size not found, assuming %.2fGB",
+			p.Topic, p.Partition, size/div))
+	}
+
+	return warns
+}
+
 // getSubAffinities, if enabled via --sub-affinity, takes reference broker maps
 // and a partition map and attempts to return a complete SubstitutionAffinities.
 func getSubAffinities(cmd *cobra.Command, bm kafkazk.BrokerMap, bmo kafkazk.BrokerMap, pm *kafkazk.PartitionMap) kafkazk.SubstitutionAffinities {
@@ -56,8 +125,8 @@ func getSubAffinities(cmd *cobra.Command, bm kafkazk.BrokerMap, bmo kafkazk.Brok
 		var err error
 		affinities, err = bm.SubstitutionAffinities(pm)
 		if err != nil {
-			fmt.Printf("Substitution affinity error: %s\n", err.Error())
-			os.Exit(1)
+			fmt.Fprintf(textOut, "Substitution affinity error: %s\n", err.Error())
+			exit(1)
 		}
 	}
 
@@ -68,7 +137,7 @@ func getSubAffinities(cmd *cobra.Command, bm kafkazk.BrokerMap, bmo kafkazk.Brok
 		if bmo[a].Missing {
 			inferred = "(inferred)"
 		}
-		fmt.Printf("%sSubstitution affinity: %d -> %d %s\n", indent, a, b.ID, inferred)
+		fmt.Fprintf(textOut, "%sSubstitution affinity: %d -> %d %s\n", indent, a, b.ID, inferred)
 	}
 
 	return affinities
@@ -86,18 +155,37 @@ func getSubAffinities(cmd *cobra.Command, bm kafkazk.BrokerMap, bmo kafkazk.Brok
 //   not previously holding any partitions for any partitions of the referenced topics
 //   being rebuilt by topicmappr)
 func getBrokers(cmd *cobra.Command, pm *kafkazk.PartitionMap, bm kafkazk.BrokerMetaMap) (kafkazk.BrokerMap, *kafkazk.BrokerStatus) {
-	fmt.Printf("\nBroker change summary:\n")
+	fmt.Fprintf(textOut, "\nBroker change summary:\n")
 
 	// Get a broker map of the brokers in the current partition map.
 	// If meta data isn't being looked up, brokerMeta will be empty.
 	fr, _ := cmd.Flags().GetBool("force-rebuild")
 	brokers := kafkazk.BrokerMapFromPartitionMap(pm, bm, fr)
 
+	// Excluded brokers are dropped from the provided broker list
+	// so that none are added as new; any holding replicas are
+	// marked as excluded and have their replicas moved off.
+	bl := Config.brokers
+	if eb, _ := cmd.Flags().GetString("exclude-brokers"); eb != "" {
+		ex := brokerStringToSlice(eb)
+		bl = excludeBrokers(bl, ex)
+		brokers.Exclude(ex)
+	}
+
 	// Update the currentBrokers list with
 	// the provided broker list.
-	bs, msgs := brokers.Update(Config.brokers, bm)
-	for m := range msgs {
-		fmt.Printf("%s%s\n", indent, m)
+	bs, msgs := brokers.UpdateSorted(bl, bm)
+	for _, m := range msgs {
+		fmt.Fprintf(textOut, "%s%s\n", indent, m)
+		if jsonOutput != nil {
+			jsonOutput.Messages = append(jsonOutput.Messages, m)
+		}
+	}
+
+	// Seed new brokers with a partial use
+	// count, if configured.
+	if wn, _ := cmd.Flags().GetFloat64("warm-new-brokers"); wn > 0 && bs.New > 0 {
+		brokers.WarmNewBrokers(wn)
 	}
 
 	return brokers, bs
@@ -107,64 +195,194 @@ func getBrokers(cmd *cobra.Command, pm *kafkazk.PartitionMap, bm kafkazk.BrokerM
 // information output describing changes in broker counts
 // and liveness.
 func printChangesActions(cmd *cobra.Command, bs *kafkazk.BrokerStatus) {
-	change := bs.New - bs.Replace
+	change := bs.New - bs.Replace - bs.Excluded
 	r, _ := cmd.Flags().GetInt("replication")
 	fr, _ := cmd.Flags().GetBool("force-rebuild")
 
 	// Print change summary.
-	fmt.Printf("%sReplacing %d, added %d, missing %d, total count changed by %d\n",
-		indent, bs.Replace, bs.New, bs.Missing+bs.OldMissing, change)
+	fmt.Fprintf(textOut, "%sReplacing %d, excluding %d, added %d, missing %d, total count changed by %d\n",
+		indent, bs.Replace, bs.Excluded, bs.New, bs.Missing+bs.OldMissing, change)
 
 	// Print action.
-	fmt.Printf("\nAction:\n")
+	fmt.Fprintf(textOut, "\nAction:\n")
 
 	switch {
-	case change >= 0 && bs.Replace > 0:
-		fmt.Printf("%sRebuild topic with %d broker(s) marked for replacement\n",
-			indent, bs.Replace)
+	case change >= 0 && bs.Replace+bs.Excluded > 0:
+		if bs.Replace > 0 {
+			fmt.Fprintf(textOut, "%sRebuild topic with %d broker(s) marked for replacement\n",
+				indent, bs.Replace)
+		}
+		if bs.Excluded > 0 {
+			fmt.Fprintf(textOut, "%sRebuild topic with %d broker(s) excluded from placements\n",
+				indent, bs.Excluded)
+		}
 	case change > 0 && bs.Replace == 0:
-		fmt.Printf("%sExpanding/rebalancing topic with %d additional broker(s) (this is a no-op unless --force-rebuild is specified)\n",
+		fmt.Fprintf(textOut, "%sExpanding/rebalancing topic with %d additional broker(s) (this is a no-op unless --force-rebuild is specified)\n",
 			indent, bs.New)
 	case change < 0:
-		fmt.Printf("%sShrinking topic by %d broker(s)\n", indent, -change)
+		fmt.Fprintf(textOut, "%sShrinking topic by %d broker(s)\n", indent, -change)
 	case fr, r > 0:
 		if fr {
-			fmt.Printf("%sForce rebuilding map\n", indent)
+			fmt.Fprintf(textOut, "%sForce rebuilding map\n", indent)
 		}
 		if r > 0 {
-			fmt.Printf("%sSetting replication factor to %d\n", indent, r)
+			fmt.Fprintf(textOut, "%sSetting replication factor to %d\n", indent, r)
 		}
 	default:
-		fmt.Printf("%sno-op\n", indent)
+		fmt.Fprintf(textOut, "%sno-op\n", indent)
 	}
 }
 
 // updateReplicationFactor takes a PartitionMap and normalizes
-// the replica set length to an optionally provided value.
-func updateReplicationFactor(cmd *cobra.Command, pm *kafkazk.PartitionMap) {
+// the replica set length to an optionally provided value. When
+// decreasing the replication factor, the least-preferred replicas
+// according to the BrokerMap are removed.
+func updateReplicationFactor(cmd *cobra.Command, pm *kafkazk.PartitionMap, bm kafkazk.BrokerMap) {
 	r, _ := cmd.Flags().GetInt("replication")
 	// If the replication factor is changed,
 	// the partition map input needs to have stub
 	// brokers appended (r factor increase) or
 	// existing brokers removed (r factor decrease).
 	if r > 0 {
-		pm.SetReplication(r)
+		if err := checkReplicationFactor(r, bm); err != nil {
+			fmt.Fprintf(textOut, "\n[ERROR] %s\n", err)
+			exit(1)
+		}
+
+		pm.SetReplicationRackAware(r, bm)
+	}
+}
+
+// checkReplicationFactor returns an error if the replication
+// factor r exceeds the number of brokers in the BrokerMap
+// available for placement.
+func checkReplicationFactor(r int, bm kafkazk.BrokerMap) error {
+	var available int
+	for id, b := range bm {
+		if id != 0 && !b.Missing && !b.Replace {
+			available++
+		}
+	}
+
+	if r > available {
+		return fmt.Errorf("replication factor %d exceeds the available broker count of %d", r, available)
+	}
+
+	return nil
+}
+
+// excludeBrokers returns the broker list bl
+// without the broker IDs in exclude.
+func excludeBrokers(bl, exclude []int) []int {
+	ex := map[int]bool{}
+	for _, id := range exclude {
+		ex[id] = true
 	}
+
+	var out []int
+	for _, id := range bl {
+		if !ex[id] {
+			out = append(out, id)
+		}
+	}
+
+	return out
+}
+
+// checkExcludedBrokers returns an error if the brokers remaining after
+// --exclude-brokers are applied can't satisfy the replication factor; either
+// the one provided via --replication or the largest replica set in the
+// PartitionMap.
+func checkExcludedBrokers(cmd *cobra.Command, pm *kafkazk.PartitionMap, bm kafkazk.BrokerMap) error {
+	r, _ := cmd.Flags().GetInt("replication")
+
+	if r == 0 {
+		for _, p := range pm.Partitions {
+			if len(p.Replicas) > r {
+				r = len(p.Replicas)
+			}
+		}
+	}
+
+	if err := checkReplicationFactor(r, bm); err != nil {
+		return fmt.Errorf("too few brokers remain after --exclude-brokers: %s", err)
+	}
+
+	return nil
+}
+
+// getController, if a policy is set via --controller-placement, returns
+// the broker ID of the active controller. Otherwise, 0 is returned.
+func getController(cmd *cobra.Command, zk kafkazk.Handler) int {
+	cp, _ := cmd.Flags().GetString("controller-placement")
+	if cp == "" {
+		return 0
+	}
+
+	id, err := zk.GetController()
+	if err != nil {
+		fmt.Fprintf(textOut, "Error fetching controller: %s\n", err)
+		exit(1)
+	}
+
+	// E.g. "deprioritized", "excluded".
+	fmt.Fprintf(textOut, "%sController %d %sd for new placements\n", indent, id, cp)
+
+	return id
+}
+
+// parseSeed takes a --seed value and returns the seed, and whether
+// the seed should instead be derived from the brokers and topic.
+func parseSeed(s string) (int64, bool, error) {
+	switch s {
+	case "":
+		return 0, false, nil
+	case "auto":
+		return 0, true, nil
+	}
+
+	seed, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("--seed must be either 'auto' or an integer")
+	}
+
+	return seed, false, nil
 }
 
 // buildMap takes an input PartitionMap, rebuild parameters, and all partition/broker
-// metadata structures required to generate the output PartitionMap. A []string of
-// warnings / advisories is returned if any are encountered.
-func buildMap(cmd *cobra.Command, pm *kafkazk.PartitionMap, pmm kafkazk.PartitionMetaMap, bm kafkazk.BrokerMap, af kafkazk.SubstitutionAffinities) (*kafkazk.PartitionMap, errors) {
+// metadata structures required to generate the output PartitionMap. Any topic
+// anti-affinity rules are applied to placements. Candidate selections are recorded
+// in the provided *PlacementStats. A []string of warnings / advisories is returned
+// if any are encountered.
+func buildMap(cmd *cobra.Command, pm *kafkazk.PartitionMap, pmm kafkazk.PartitionMetaMap, bm kafkazk.BrokerMap, af kafkazk.SubstitutionAffinities, ta *kafkazk.TopicAntiAffinity, controller int, ps *kafkazk.PlacementStats) (*kafkazk.PartitionMap, errors) {
 	placement := cmd.Flag("placement").Value.String()
 	psf, _ := cmd.Flags().GetFloat64("partition-size-factor")
+	rw, _ := cmd.Flags().GetBool("rack-weighted")
+	mf, _ := cmd.Flags().GetFloat64("min-storage-free-gb")
+	ts, _ := cmd.Flags().GetBool("topic-spread")
+	mp, _ := cmd.Flags().GetInt("max-partitions-per-broker")
+	// Already validated.
+	strategy, _ := kafkazk.ParseStrategy(cmd.Flag("strategy").Value.String())
+	seed, autoSeed, _ := parseSeed(cmd.Flag("seed").Value.String())
 
 	rebuildParams := kafkazk.RebuildParams{
-		PMM:           pmm,
-		BM:            bm,
-		Strategy:      placement,
-		Optimization:  cmd.Flag("optimize").Value.String(),
-		PartnSzFactor: psf,
+		PMM:              pmm,
+		BM:               bm,
+		Strategy:         placement,
+		Optimization:     cmd.Flag("optimize").Value.String(),
+		PartnSzFactor:    psf,
+		RackWeighted:     rw,
+		MinStorageFree:   mf * div,
+		Controller:       controller,
+		ControllerPolicy: cmd.Flag("controller-placement").Value.String(),
+		TopicSpread:      ts,
+		ScoreFunc:        strategy.ScoreFunc(),
+		Seed:             seed,
+		AutoSeed:         autoSeed,
+		Stats:            ps,
+
+		MaxPartitionsPerBroker: mp,
+		TopicAntiAffinity:      ta,
 	}
 
 	if af != nil {
@@ -194,8 +412,8 @@ func buildMap(cmd *cobra.Command, pm *kafkazk.PartitionMap, pmm kafkazk.Partitio
 			allBrokers := func(b *kafkazk.Broker) bool { return true }
 			err := rebuildParams.BM.SubStorage(pm, pmm, allBrokers)
 			if err != nil {
-				fmt.Println(err)
-				os.Exit(1)
+				fmt.Fprintln(textOut, err)
+				exit(1)
 			}
 		}
 
@@ -209,11 +427,104 @@ func buildMap(cmd *cobra.Command, pm *kafkazk.PartitionMap, pmm kafkazk.Partitio
 		replacedBrokers := func(b *kafkazk.Broker) bool { return b.Replace }
 		err := rebuildParams.BM.SubStorage(pm, pmm, replacedBrokers)
 		if err != nil {
-			fmt.Println(err)
-			os.Exit(1)
+			fmt.Fprintln(textOut, err)
+			exit(1)
 		}
 	}
 
 	// Rebuild directly on the input map.
 	return pm.Rebuild(rebuildParams)
 }
+
+// unplacedPartitions takes an input and output PartitionMap and returns
+// the partitions, formatted as "<topic> p<partition>", where the output
+// replica set holds fewer replicas than the input or any stub brokers.
+func unplacedPartitions(in, out *kafkazk.PartitionMap) []string {
+	want := map[string]int{}
+	for _, p := range in.Partitions {
+		want[fmt.Sprintf("%s p%d", p.Topic, p.Partition)] = len(p.Replicas)
+	}
+
+	var unplaced []string
+	for _, p := range out.Partitions {
+		name := fmt.Sprintf("%s p%d", p.Topic, p.Partition)
+
+		placed := len(p.Replicas) >= want[name]
+		for _, id := range p.Replicas {
+			if id == 0 {
+				placed = false
+			}
+		}
+
+		if !placed {
+			unplaced = append(unplaced, name)
+		}
+	}
+
+	return unplaced
+}
+
+// projectBrokers returns a copy of the original BrokerMap with storage free
+// values projected for the changes between the original and output
+// PartitionMaps. Partition sizes are scaled by --partition-size-factor to
+// match the estimates made by storage placements.
+func projectBrokers(cmd *cobra.Command, pm1, pm2 *kafkazk.PartitionMap, pmm kafkazk.PartitionMetaMap, bm kafkazk.BrokerMap) (kafkazk.BrokerMap, error) {
+	psf, _ := cmd.Flags().GetFloat64("partition-size-factor")
+
+	scaled := kafkazk.NewPartitionMetaMap()
+	for t, partns := range pmm {
+		scaled[t] = map[int]*kafkazk.PartitionMeta{}
+		for n, meta := range partns {
+			scaled[t][n] = &kafkazk.PartitionMeta{Size: meta.Size * psf}
+		}
+	}
+
+	return bm.ProjectStorage(pm1, pm2, scaled)
+}
+
+// applyLeaderPolicy, if a policy is set via --leader-policy, reorders
+// the replica sets of the PartitionMap so that the broker selected by the
+// policy is the preferred leader. The bytes policy balances leadership
+// by partition size and requires the PartitionMetaMap.
+func applyLeaderPolicy(cmd *cobra.Command, pm *kafkazk.PartitionMap, bm kafkazk.BrokerMap, pmm kafkazk.PartitionMetaMap) {
+	lp, _ := cmd.Flags().GetString("leader-policy")
+	if lp == "" {
+		return
+	}
+
+	var err error
+	if lp == "bytes" {
+		err = pm.SetLeadersBySize(pmm)
+	} else {
+		err = pm.SetLeaders(bm, lp)
+	}
+
+	if err != nil {
+		fmt.Fprintln(textOut, err)
+		exit(1)
+	}
+}
+
+// applyObservers, if set via --observers-per-partition, designates the
+// configured number of replicas of each replica set in the PartitionMap
+// as observers, preferring those in racks remote to the other replicas.
+// Observers left sharing a rack with a synchronous replica are returned
+// as warnings.
+func applyObservers(cmd *cobra.Command, pm *kafkazk.PartitionMap, bm kafkazk.BrokerMap) errors {
+	n, _ := cmd.Flags().GetInt("observers-per-partition")
+	if n == 0 {
+		return nil
+	}
+
+	if err := pm.SetObservers(n, bm); err != nil {
+		fmt.Fprintf(textOut, "\n[ERROR] %s\n", err)
+		exit(1)
+	}
+
+	var errs errors
+	for _, c := range pm.ObserverRackConflicts(bm) {
+		errs = append(errs, fmt.Errorf("%s", c))
+	}
+
+	return errs
+}
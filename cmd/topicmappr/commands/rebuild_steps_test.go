@@ -0,0 +1,191 @@
+package commands
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/DataDog/kafka-kit/kafkazk"
+
+	"github.com/spf13/cobra"
+)
+
+func TestCheckReplicationFactor(t *testing.T) {
+	bm := kafkazk.BrokerMap{
+		0:    &kafkazk.Broker{ID: 0, Replace: true},
+		1001: &kafkazk.Broker{ID: 1001},
+		1002: &kafkazk.Broker{ID: 1002},
+		1003: &kafkazk.Broker{ID: 1003, Replace: true},
+		1004: &kafkazk.Broker{ID: 1004, Missing: true},
+		1005: &kafkazk.Broker{ID: 1005, New: true},
+	}
+
+	tests := map[int]bool{
+		1: false,
+		3: false,
+		// Exceeds the 3 available brokers.
+		4: true,
+	}
+
+	for r, expectErr := range tests {
+		err := checkReplicationFactor(r, bm)
+		if (err != nil) != expectErr {
+			t.Errorf("[r %d] Expected error: %t, got %v", r, expectErr, err)
+		}
+	}
+}
+
+func TestReplicationFactorFlagAlias(t *testing.T) {
+	f := rebuildCmd.Flags().Lookup("replication-factor")
+	if f == nil || f.Name != "replication" {
+		t.Error("Expected --replication-factor to alias --replication")
+	}
+}
+
+func TestParseSeed(t *testing.T) {
+	tests := map[string]struct {
+		seed  int64
+		auto  bool
+		valid bool
+	}{
+		"":     {valid: true},
+		"auto": {auto: true, valid: true},
+		"42":   {seed: 42, valid: true},
+		"-7":   {seed: -7, valid: true},
+		"1.5":  {},
+		"AUTO": {},
+	}
+
+	for s, test := range tests {
+		seed, auto, err := parseSeed(s)
+		if (err == nil) != test.valid {
+			t.Errorf("[seed '%s'] Expected valid=%v, got error: %v", s, test.valid, err)
+			continue
+		}
+
+		if seed != test.seed || auto != test.auto {
+			t.Errorf("[seed '%s'] Expected %d (auto=%v), got %d (auto=%v)", s, test.seed, test.auto, seed, auto)
+		}
+	}
+}
+
+func TestUnplacedPartitions(t *testing.T) {
+	in, _ := kafkazk.PartitionMapFromString(`{"version":1,"partitions":[
+    {"topic":"a","partition":0,"replicas":[1001,1002]},
+    {"topic":"a","partition":1,"replicas":[1001,0]},
+    {"topic":"b","partition":0,"replicas":[1001,1002,1003]}]}`)
+
+	out, _ := kafkazk.PartitionMapFromString(`{"version":1,"partitions":[
+    {"topic":"a","partition":0,"replicas":[1001,1004]},
+    {"topic":"a","partition":1,"replicas":[1001,0]},
+    {"topic":"b","partition":0,"replicas":[1001,1003]}]}`)
+
+	unplaced := unplacedPartitions(in, out)
+	expected := []string{"a p1", "b p0"}
+
+	if !reflect.DeepEqual(unplaced, expected) {
+		t.Errorf("Expected %v, got %v", expected, unplaced)
+	}
+
+	if u := unplacedPartitions(in, in.Copy()); len(u) != 1 {
+		t.Errorf("Expected 1 unplaced partition, got %v", u)
+	}
+}
+
+func TestExcludeBrokers(t *testing.T) {
+	pm, _ := kafkazk.PartitionMapFromString(`{"version":1,"partitions":[
+    {"topic":"a","partition":0,"replicas":[1001,1002]},
+    {"topic":"a","partition":1,"replicas":[1002,1003]},
+    {"topic":"a","partition":2,"replicas":[1003,1004]},
+    {"topic":"a","partition":3,"replicas":[1004,1001]}]}`)
+
+	exclude := []int{1002, 1005}
+	bl := excludeBrokers([]int{1001, 1002, 1003, 1004, 1005}, exclude)
+
+	if expected := []int{1001, 1003, 1004}; !reflect.DeepEqual(bl, expected) {
+		t.Errorf("Expected broker list %v, got %v", expected, bl)
+	}
+
+	brokers := kafkazk.BrokerMapFromPartitionMap(pm, nil, false)
+	brokers.Exclude(exclude)
+	bs, _ := brokers.Update(bl, nil)
+
+	// Excluded brokers aren't counted as replacements.
+	if bs.Excluded != 1 || bs.Replace != 0 {
+		t.Errorf("Expected 1 excluded and 0 replaced brokers, got %d and %d", bs.Excluded, bs.Replace)
+	}
+
+	params := kafkazk.NewRebuildParams()
+	params.BM = brokers
+	params.Strategy = "count"
+
+	out, errs := pm.Rebuild(params)
+	if len(errs) > 0 {
+		t.Fatalf("Unexpected errors: %v", errs)
+	}
+
+	for _, p := range out.Partitions {
+		if len(p.Replicas) != 2 {
+			t.Errorf("Expected 2 replicas for p%d, got %v", p.Partition, p.Replicas)
+		}
+
+		for _, id := range p.Replicas {
+			if id == 1002 || id == 1005 {
+				t.Errorf("Unexpected excluded broker %d in p%d replicas %v", id, p.Partition, p.Replicas)
+			}
+		}
+	}
+}
+
+func TestCheckExcludedBrokers(t *testing.T) {
+	pm, _ := kafkazk.PartitionMapFromString(`{"version":1,"partitions":[
+    {"topic":"a","partition":0,"replicas":[1001,1002,1003]},
+    {"topic":"a","partition":1,"replicas":[1002,1003]}]}`)
+
+	bm := kafkazk.BrokerMap{
+		1001: &kafkazk.Broker{ID: 1001},
+		1002: &kafkazk.Broker{ID: 1002, Replace: true},
+		1003: &kafkazk.Broker{ID: 1003},
+	}
+
+	// Map replication of 3 exceeds 2 eligible brokers.
+	cmd := &cobra.Command{}
+	cmd.Flags().Int("replication", 0, "")
+
+	if err := checkExcludedBrokers(cmd, pm, bm); err == nil {
+		t.Error("Expected error")
+	}
+
+	// A lowered replication factor is satisfiable.
+	cmd.Flags().Set("replication", "2")
+
+	if err := checkExcludedBrokers(cmd, pm, bm); err != nil {
+		t.Errorf("Unexpected error: %s", err)
+	}
+}
+
+func TestNewTopicMap(t *testing.T) {
+	cmd := &cobra.Command{}
+	cmd.Flags().String("new-topic", "", "")
+	cmd.Flags().Int("partitions", 0, "")
+	cmd.Flags().Int("replication", 0, "")
+
+	cmd.Flags().Set("new-topic", "new_topic")
+	cmd.Flags().Set("partitions", "12")
+	cmd.Flags().Set("replication", "3")
+
+	pm, err := newTopicMap(cmd, &kafkazk.Mock{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if expected := kafkazk.NewTopicPartitionMap("new_topic", 12, 3); !reflect.DeepEqual(pm, expected) {
+		t.Errorf("Expected map %v, got %v", expected, pm)
+	}
+
+	// Existing topics must be rebuilt with --topics.
+	cmd.Flags().Set("new-topic", "test_topic")
+
+	if _, err := newTopicMap(cmd, &kafkazk.Mock{}); err == nil {
+		t.Error("Expected error")
+	}
+}
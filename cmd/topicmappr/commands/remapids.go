@@ -0,0 +1,158 @@
+package commands
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/DataDog/kafka-kit/kafkazk"
+
+	"github.com/spf13/cobra"
+)
+
+var remapIDsCmd = &cobra.Command{
+	Use:   "remap-ids",
+	Short: "Rewrite broker IDs in a partition map according to an old:new mapping",
+	Long: `remap-ids rewrites all replica IDs of the target topics according to the
+old:new broker ID pairs provided via --mapping. This is useful for translating maps
+that reference stale broker IDs following a cluster migration. Target topics are
+provided via --topics (discovered in ZooKeeper) or a JSON map via --map-string.
+Remapped replica sets must not reference a broker more than once and, unless
+--use-meta=false, all referenced brokers must be registered in ZooKeeper. Broker
+IDs referenced by the map that aren't included in the mapping are left unchanged
+and reported.`,
+	Run: remapIDs,
+}
+
+func init() {
+	rootCmd.AddCommand(remapIDsCmd)
+
+	remapIDsCmd.Flags().String("mapping", "", "Broker ID mapping (comma delim. list of old:new)")
+	remapIDsCmd.Flags().String("topics", "", "Remap topics (comma delim. list) by lookup in ZooKeeper")
+	remapIDsCmd.Flags().String("map-string", "", "Remap a partition map provided as a string literal")
+	remapIDsCmd.Flags().Bool("use-meta", true, "Validate that remapped brokers are registered in ZooKeeper")
+	remapIDsCmd.Flags().String("out-path", "", "Path to write output map files to")
+	remapIDsCmd.Flags().String("out-file", "", "If defined, write a combined map of all topics to a file")
+	remapIDsCmd.Flags().String("output-format", "json", "Output map format: [json, yaml]")
+
+	// Required.
+	remapIDsCmd.MarkFlagRequired("mapping")
+}
+
+func remapIDs(cmd *cobra.Command, _ []string) {
+	t, _ := cmd.Flags().GetString("topics")
+	ms, _ := cmd.Flags().GetString("map-string")
+	m, _ := cmd.Flags().GetBool("use-meta")
+
+	if ms == "" && t == "" {
+		fmt.Fprintln(textOut, "\n[ERROR] must specify either --topics or --map-string")
+		defaultsAndExit()
+	}
+
+	mapping, err := parseIDMapping(cmd.Flag("mapping").Value.String())
+	if err != nil {
+		fmt.Fprintf(textOut, "\n[ERROR] %s\n", err)
+		defaultsAndExit()
+	}
+
+	bootstrap(cmd)
+
+	// ZooKeeper is required for topic discovery
+	// and validating broker registrations.
+	var zk kafkazk.Handler
+	if t != "" || m {
+		zk, err = initZooKeeper(cmd)
+		if err != nil {
+			fmt.Fprintln(textOut, err)
+			exit(1)
+		}
+		defer zk.Close()
+	}
+
+	partitionMap := getPartitionMap(cmd, zk)
+	partitionMapOrig := partitionMap.Copy()
+
+	printTopics(partitionMap)
+
+	unmapped, err := partitionMap.RemapBrokers(mapping)
+	if err != nil {
+		fmt.Fprintln(textOut, err)
+		exit(1)
+	}
+
+	if len(unmapped) > 0 {
+		fmt.Fprintf(textOut, "\n[WARNING] broker IDs referenced by the map with no mapping: %v\n", unmapped)
+	}
+
+	if m {
+		brokerMeta := getBrokerMeta(cmd, zk, false)
+		if missing := unregisteredBrokers(partitionMap, brokerMeta); len(missing) > 0 {
+			fmt.Fprintf(textOut, "\n[ERROR] remapped brokers not registered in ZooKeeper: %v\n", missing)
+			exit(1)
+		}
+	}
+
+	printMapChanges(partitionMapOrig, partitionMap)
+
+	writeMaps(cmd, partitionMap)
+}
+
+// parseIDMapping takes a comma delimited list of old:new broker ID
+// pairs and returns a map of old to new IDs. Each old and new ID may
+// only be referenced once.
+func parseIDMapping(s string) (map[int]int, error) {
+	mapping := map[int]int{}
+	targets := map[int]bool{}
+
+	for _, pair := range strings.Split(s, ",") {
+		parts := strings.Split(strings.TrimSpace(pair), ":")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid mapping '%s': must be formatted as old:new", pair)
+		}
+
+		var ids [2]int
+		for i, p := range parts {
+			id, err := strconv.Atoi(p)
+			if err != nil || id <= 0 {
+				return nil, fmt.Errorf("invalid mapping '%s': broker IDs must be positive integers", pair)
+			}
+			ids[i] = id
+		}
+
+		if _, exists := mapping[ids[0]]; exists {
+			return nil, fmt.Errorf("invalid mapping '%s': broker %d mapped more than once", pair, ids[0])
+		}
+
+		if targets[ids[1]] {
+			return nil, fmt.Errorf("invalid mapping '%s': broker %d is the target of more than one mapping", pair, ids[1])
+		}
+
+		mapping[ids[0]] = ids[1]
+		targets[ids[1]] = true
+	}
+
+	return mapping, nil
+}
+
+// unregisteredBrokers returns a sorted []int of all broker IDs
+// referenced in the PartitionMap that aren't in the BrokerMetaMap.
+func unregisteredBrokers(pm *kafkazk.PartitionMap, bm kafkazk.BrokerMetaMap) []int {
+	missing := map[int]bool{}
+	for _, p := range pm.Partitions {
+		for _, id := range p.Replicas {
+			if _, exists := bm[id]; !exists {
+				missing[id] = true
+			}
+		}
+	}
+
+	var ids []int
+	for id := range missing {
+		ids = append(ids, id)
+	}
+
+	sort.Ints(ids)
+
+	return ids
+}
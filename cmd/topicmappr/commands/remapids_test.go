@@ -0,0 +1,65 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/DataDog/kafka-kit/kafkazk"
+)
+
+func TestParseIDMapping(t *testing.T) {
+	mapping, err := parseIDMapping("1001:2001, 1002:2002")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	expected := map[int]int{1001: 2001, 1002: 2002}
+
+	if len(mapping) != len(expected) {
+		t.Fatalf("Expected %d mappings, got %d", len(expected), len(mapping))
+	}
+
+	for old, id := range expected {
+		if mapping[old] != id {
+			t.Errorf("Expected %d mapped to %d, got %d", old, id, mapping[old])
+		}
+	}
+
+	invalid := []string{
+		"",
+		"1001",
+		"1001:2001:3001",
+		"1001:abc",
+		"1001:-1",
+		"1001:2001,1001:2002",
+		"1001:2001,1002:2001",
+	}
+
+	for _, s := range invalid {
+		if _, err := parseIDMapping(s); err == nil {
+			t.Errorf("Expected error for mapping '%s'", s)
+		}
+	}
+}
+
+func TestUnregisteredBrokers(t *testing.T) {
+	zk := &kafkazk.Mock{}
+	bm, _ := zk.GetAllBrokerMeta(false)
+
+	pm, _ := kafkazk.PartitionMapFromString(`{"version":1,"partitions":[
+    {"topic":"test_topic","partition":0,"replicas":[1001,2002]},
+    {"topic":"test_topic","partition":1,"replicas":[2001,1002]},
+    {"topic":"test_topic","partition":2,"replicas":[2002,1003]}]}`)
+
+	missing := unregisteredBrokers(pm, bm)
+	expected := []int{2001, 2002}
+
+	if len(missing) != len(expected) {
+		t.Fatalf("Expected unregistered brokers %v, got %v", expected, missing)
+	}
+
+	for i := range expected {
+		if missing[i] != expected[i] {
+			t.Errorf("Expected unregistered brokers %v, got %v", expected, missing)
+		}
+	}
+}
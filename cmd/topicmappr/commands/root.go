@@ -3,13 +3,15 @@ package commands
 import (
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/jamiealquiza/envy"
 	"github.com/spf13/cobra"
 )
 
 var rootCmd = &cobra.Command{
-	Use: "topicmappr",
+	Use:              "topicmappr",
+	PersistentPreRun: setStdoutFormat,
 }
 
 // Execute rootCmd.
@@ -17,13 +19,34 @@ func Execute() {
 	envy.ParseCobra(rootCmd, envy.CobraConfig{Prefix: "TOPICMAPPR", Persistent: true, Recursive: false})
 
 	if err := rootCmd.Execute(); err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+		fmt.Fprintln(textOut, err)
+		exit(1)
 	}
 }
 
 func init() {
 	rootCmd.PersistentFlags().String("zk-addr", "localhost:2181", "ZooKeeper connect string")
-	rootCmd.PersistentFlags().String("zk-prefix", "", "ZooKeeper prefix (if Kafka is configured with a chroot path prefix)")
+	rootCmd.PersistentFlags().String("zk-prefix", "", "ZooKeeper prefix (if Kafka is configured with a chroot path prefix), or comma delimited cluster=prefix mappings selected with --cluster")
+	rootCmd.PersistentFlags().String("cluster", "", "Cluster name selecting the --zk-prefix mapping to use")
 	rootCmd.PersistentFlags().Bool("ignore-warns", false, "Produce a map even if warnings are encountered")
+	rootCmd.PersistentFlags().String("stdout-format", "text", "Stdout format: [text, json]; json emits a single structured document and writes text output to stderr (rebuild and rebalance only)")
+	rootCmd.PersistentFlags().Duration("metrics-fetch-timeout", 30*time.Second, "Timeout for fetching broker metadata and metrics from ZooKeeper (0 disables the timeout)")
+}
+
+// setStdoutFormat validates the --stdout-format flag and
+// enables JSON output if configured. This is run ahead of
+// any command output.
+func setStdoutFormat(cmd *cobra.Command, _ []string) {
+	switch sf, _ := cmd.Flags().GetString("stdout-format"); sf {
+	case "", "text":
+	case "json":
+		if n := cmd.Name(); n != "rebuild" && n != "rebalance" {
+			fmt.Fprintf(textOut, "\n[ERROR] --stdout-format json is not supported by %s\n", n)
+			defaultsAndExit()
+		}
+		initJSONOutput(os.Stdout)
+	default:
+		fmt.Fprintln(textOut, "\n[ERROR] --stdout-format must be either 'text' or 'json'")
+		defaultsAndExit()
+	}
 }
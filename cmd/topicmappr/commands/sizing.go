@@ -0,0 +1,233 @@
+package commands
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/spf13/cobra"
+)
+
+var sizingCmd = &cobra.Command{
+	Use:   "sizing",
+	Short: "Compute the minimum broker count required to host a workload",
+	Long: `sizing computes the minimum number of brokers required to host --partitions
+partitions at --replication replicas each, where each replica is --partition-size-gb
+gigabytes and each broker provides --broker-storage-gb gigabytes of storage, of which
+at most --max-storage-utilization percent may be used. If --racks is set, replicas of
+a partition must be placed in distinct racks and the required brokers per rack are
+reported. The number of replicas per broker may be additionally capped with
+--max-replicas-per-broker. No ZooKeeper access is required.`,
+	Run: sizing,
+}
+
+func init() {
+	rootCmd.AddCommand(sizingCmd)
+
+	sizingCmd.Flags().Int("partitions", 0, "Number of partitions in the workload")
+	sizingCmd.Flags().Int("replication", 3, "Replication factor of the workload")
+	sizingCmd.Flags().Float64("partition-size-gb", 0.00, "Size of each partition replica in gigabytes")
+	sizingCmd.Flags().Float64("broker-storage-gb", 0.00, "Storage capacity of each broker in gigabytes")
+	sizingCmd.Flags().Float64("max-storage-utilization", 80.00, "Maximum percent of broker storage that may be used")
+	sizingCmd.Flags().Int("racks", 0, "Number of racks replicas must be distributed across (0 disables rack constraints)")
+	sizingCmd.Flags().Int("max-replicas-per-broker", 0, "Maximum number of replicas per broker (0 results in no limit)")
+
+	// Required.
+	sizingCmd.MarkFlagRequired("partitions")
+	sizingCmd.MarkFlagRequired("partition-size-gb")
+	sizingCmd.MarkFlagRequired("broker-storage-gb")
+}
+
+var (
+	// errSizingRacks error.
+	errSizingRacks = fmt.Errorf("--racks must be 0 or at least the replication factor")
+	// errSizingFit error.
+	errSizingFit = fmt.Errorf("a single partition replica exceeds the usable broker storage")
+)
+
+// sizingParams describes a workload and
+// the constraints it must be placed within.
+type sizingParams struct {
+	partitions  int
+	replication int
+	// Partition size and broker
+	// storage in gigabytes.
+	partitionSize  float64
+	brokerStorage  float64
+	maxUtilization float64
+	racks          int
+	maxReplicas    int
+}
+
+// sizingResult holds the computed broker requirements.
+type sizingResult struct {
+	brokers int
+	// Replicas each broker can hold.
+	perBroker int
+	// Replicas and brokers per rack,
+	// if rack constraints are set.
+	rackReplicas []int
+	rackBrokers  []int
+	// The storage utilization of the
+	// cluster at the minimum size.
+	utilization float64
+}
+
+// computeSizing returns the minimum broker requirements for the
+// workload described by the sizingParams. Each replica of a partition
+// requires a distinct broker; when rack constraints are set, no rack
+// may hold more than one replica of a given partition.
+func computeSizing(p sizingParams) (sizingResult, error) {
+	var r sizingResult
+
+	if p.racks != 0 && p.racks < p.replication {
+		return r, errSizingRacks
+	}
+
+	usable := p.brokerStorage * p.maxUtilization / 100
+	r.perBroker = int(math.Floor(usable / p.partitionSize))
+
+	if p.maxReplicas > 0 && p.maxReplicas < r.perBroker {
+		r.perBroker = p.maxReplicas
+	}
+
+	if r.perBroker < 1 {
+		return r, errSizingFit
+	}
+
+	replicas := p.partitions * p.replication
+
+	// The lower bound is enough brokers to hold all
+	// replicas, and no fewer than the replication factor.
+	r.brokers = int(math.Ceil(float64(replicas) / float64(r.perBroker)))
+	if r.brokers < p.replication {
+		r.brokers = p.replication
+	}
+
+	if p.racks > 0 {
+		// A rack holds at most one replica of each partition, so
+		// a rack's brokers beyond the partition count are unusable.
+		// Add brokers until the racks can hold all replicas.
+		for {
+			r.rackBrokers, r.rackReplicas = rackSizing(p, r.brokers, r.perBroker)
+			if sumInts(r.rackReplicas) == replicas {
+				break
+			}
+			r.brokers++
+		}
+	}
+
+	if r.brokers > 0 {
+		total := float64(r.brokers) * p.brokerStorage
+		r.utilization = float64(replicas) * p.partitionSize / total * 100
+	}
+
+	return r, nil
+}
+
+// rackSizing distributes the provided number of brokers as evenly as
+// possible across the sizingParams racks, which maximizes the replicas the
+// racks can hold. The brokers per rack and the workload replicas per rack,
+// spread evenly within each rack's capacity, are returned. The replicas
+// total less than the workload if the brokers can't hold all replicas.
+func rackSizing(p sizingParams, brokers, perBroker int) ([]int, []int) {
+	rackBrokers := make([]int, p.racks)
+	capacity := make([]int, p.racks)
+
+	for i := range rackBrokers {
+		rackBrokers[i] = brokers / p.racks
+		if i < brokers%p.racks {
+			rackBrokers[i]++
+		}
+
+		capacity[i] = rackBrokers[i] * perBroker
+		if capacity[i] > p.partitions {
+			capacity[i] = p.partitions
+		}
+	}
+
+	rackReplicas := make([]int, p.racks)
+
+	for remaining := p.partitions * p.replication; remaining > 0; {
+		var placed bool
+		for i := range rackReplicas {
+			if remaining > 0 && rackReplicas[i] < capacity[i] {
+				rackReplicas[i]++
+				remaining--
+				placed = true
+			}
+		}
+
+		if !placed {
+			break
+		}
+	}
+
+	return rackBrokers, rackReplicas
+}
+
+// sumInts returns the sum of a []int.
+func sumInts(s []int) int {
+	var n int
+	for _, v := range s {
+		n += v
+	}
+
+	return n
+}
+
+func sizing(cmd *cobra.Command, _ []string) {
+	var p sizingParams
+	p.partitions, _ = cmd.Flags().GetInt("partitions")
+	p.replication, _ = cmd.Flags().GetInt("replication")
+	p.partitionSize, _ = cmd.Flags().GetFloat64("partition-size-gb")
+	p.brokerStorage, _ = cmd.Flags().GetFloat64("broker-storage-gb")
+	p.maxUtilization, _ = cmd.Flags().GetFloat64("max-storage-utilization")
+	p.racks, _ = cmd.Flags().GetInt("racks")
+	p.maxReplicas, _ = cmd.Flags().GetInt("max-replicas-per-broker")
+
+	switch {
+	case p.partitions < 1:
+		fmt.Fprintln(textOut, "\n[ERROR] --partitions must be greater than 0")
+		defaultsAndExit()
+	case p.replication < 1:
+		fmt.Fprintln(textOut, "\n[ERROR] --replication must be greater than 0")
+		defaultsAndExit()
+	case p.partitionSize <= 0 || p.brokerStorage <= 0:
+		fmt.Fprintln(textOut, "\n[ERROR] --partition-size-gb and --broker-storage-gb must be greater than 0")
+		defaultsAndExit()
+	case p.maxUtilization <= 0 || p.maxUtilization > 100:
+		fmt.Fprintln(textOut, "\n[ERROR] --max-storage-utilization must be between 0 and 100")
+		defaultsAndExit()
+	case p.racks < 0 || p.maxReplicas < 0:
+		fmt.Fprintln(textOut, "\n[ERROR] --racks and --max-replicas-per-broker must be 0 or greater")
+		defaultsAndExit()
+	}
+
+	r, err := computeSizing(p)
+	if err != nil {
+		fmt.Fprintf(textOut, "\n[ERROR] %s\n", err)
+		exit(1)
+	}
+
+	printSizing(p, r)
+}
+
+// printSizing prints the sizing workload and result.
+func printSizing(p sizingParams, r sizingResult) {
+	fmt.Fprintln(textOut, "\nWorkload:")
+	fmt.Fprintf(textOut, "%spartitions: %d, replication: %d\n", indent, p.partitions, p.replication)
+	fmt.Fprintf(textOut, "%sreplicas: %d, total size: %.2fGB\n", indent,
+		p.partitions*p.replication, float64(p.partitions*p.replication)*p.partitionSize)
+
+	fmt.Fprintln(textOut, "\nRequired brokers:")
+	fmt.Fprintf(textOut, "%sminimum brokers: %d\n", indent, r.brokers)
+	fmt.Fprintf(textOut, "%smax replicas per broker: %d\n", indent, r.perBroker)
+	fmt.Fprintf(textOut, "%sstorage utilization: %.2f%%\n", indent, r.utilization)
+
+	if len(r.rackBrokers) > 0 {
+		fmt.Fprintf(textOut, "%s-\n", indent)
+		for i := range r.rackBrokers {
+			fmt.Fprintf(textOut, "%srack %d: %d brokers (%d replicas)\n", indent, i+1, r.rackBrokers[i], r.rackReplicas[i])
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package commands
+
+import (
+	"testing"
+)
+
+func TestComputeSizing(t *testing.T) {
+	tests := map[string]struct {
+		params      sizingParams
+		brokers     int
+		perBroker   int
+		rackBrokers []int
+	}{
+		// 300 replicas at 40 per broker (400GB usable / 10GB).
+		"storage bound": {
+			params:    sizingParams{partitions: 100, replication: 3, partitionSize: 10, brokerStorage: 500, maxUtilization: 80},
+			brokers:   8,
+			perBroker: 40,
+		},
+		// Capacity for all replicas on one broker, but
+		// each replica of a partition needs its own.
+		"replication bound": {
+			params:    sizingParams{partitions: 2, replication: 3, partitionSize: 1, brokerStorage: 1000, maxUtilization: 100},
+			brokers:   3,
+			perBroker: 1000,
+		},
+		"replica cap": {
+			params:    sizingParams{partitions: 100, replication: 2, partitionSize: 1, brokerStorage: 1000, maxUtilization: 100, maxReplicas: 25},
+			brokers:   8,
+			perBroker: 25,
+		},
+		// 300 replicas over 3 racks; 100 per rack at 40 per broker.
+		"racks": {
+			params:      sizingParams{partitions: 100, replication: 3, partitionSize: 10, brokerStorage: 500, maxUtilization: 80, racks: 3},
+			brokers:     9,
+			perBroker:   40,
+			rackBrokers: []int{3, 3, 3},
+		},
+		// 200 replicas over 3 racks at 40 per broker; 80, 80, 40.
+		"uneven racks": {
+			params:      sizingParams{partitions: 100, replication: 2, partitionSize: 10, brokerStorage: 400, maxUtilization: 100, racks: 3},
+			brokers:     5,
+			perBroker:   40,
+			rackBrokers: []int{2, 2, 1},
+		},
+		// 20 replicas at 10 per broker; a broker in each of
+		// 2 racks satisfies rack diversity.
+		"rack minimum": {
+			params:      sizingParams{partitions: 10, replication: 2, partitionSize: 1, brokerStorage: 10, maxUtilization: 100, racks: 3},
+			brokers:     2,
+			perBroker:   10,
+			rackBrokers: []int{1, 1, 0},
+		},
+		// 30 replicas at 7 per broker would fit 5 brokers, but
+		// each of the 3 racks must hold 10 replicas.
+		"rack capped": {
+			params:      sizingParams{partitions: 10, replication: 3, partitionSize: 1, brokerStorage: 7, maxUtilization: 100, racks: 3},
+			brokers:     6,
+			perBroker:   7,
+			rackBrokers: []int{2, 2, 2},
+		},
+		// With a fourth rack, 5 brokers hold 9, 7, 7 and 7 replicas.
+		"rack capped, spare rack": {
+			params:      sizingParams{partitions: 10, replication: 3, partitionSize: 1, brokerStorage: 7, maxUtilization: 100, racks: 4},
+			brokers:     5,
+			perBroker:   7,
+			rackBrokers: []int{2, 1, 1, 1},
+		},
+	}
+
+	for name, test := range tests {
+		r, err := computeSizing(test.params)
+		if err != nil {
+			t.Errorf("[%s] Unexpected error: %s", name, err)
+			continue
+		}
+
+		if r.brokers != test.brokers {
+			t.Errorf("[%s] Expected %d brokers, got %d", name, test.brokers, r.brokers)
+		}
+
+		if r.perBroker != test.perBroker {
+			t.Errorf("[%s] Expected %d replicas per broker, got %d", name, test.perBroker, r.perBroker)
+		}
+
+		if len(r.rackBrokers) != len(test.rackBrokers) {
+			t.Errorf("[%s] Expected rack brokers %v, got %v", name, test.rackBrokers, r.rackBrokers)
+			continue
+		}
+
+		for i := range r.rackBrokers {
+			if r.rackBrokers[i] != test.rackBrokers[i] {
+				t.Errorf("[%s] Expected rack brokers %v, got %v", name, test.rackBrokers, r.rackBrokers)
+				break
+			}
+		}
+	}
+}
+
+func TestComputeSizingUtilization(t *testing.T) {
+	p := sizingParams{partitions: 100, replication: 3, partitionSize: 10, brokerStorage: 500, maxUtilization: 80}
+
+	r, _ := computeSizing(p)
+
+	// 3000GB over 8 brokers of 500GB.
+	if r.utilization != 75 {
+		t.Errorf("Expected utilization 75.00%%, got %.2f%%", r.utilization)
+	}
+}
+
+func TestRackSizing(t *testing.T) {
+	p := sizingParams{partitions: 10, replication: 3, racks: 4}
+
+	brokers, replicas := rackSizing(p, 5, 7)
+
+	expectedBrokers, expectedReplicas := []int{2, 1, 1, 1}, []int{9, 7, 7, 7}
+	for i := range expectedBrokers {
+		if brokers[i] != expectedBrokers[i] || replicas[i] != expectedReplicas[i] {
+			t.Fatalf("Expected brokers %v and replicas %v, got %v and %v",
+				expectedBrokers, expectedReplicas, brokers, replicas)
+		}
+	}
+
+	// 4 brokers over 3 racks can't hold
+	// 30 replicas at 7 per broker.
+	p.racks = 3
+	if _, replicas := rackSizing(p, 4, 7); sumInts(replicas) != 24 {
+		t.Errorf("Expected 24 replicas placed, got %v", replicas)
+	}
+}
+
+func TestComputeSizingErrors(t *testing.T) {
+	tests := map[string]sizingParams{
+		"racks": sizingParams{partitions: 10, replication: 3, partitionSize: 1, brokerStorage: 100, maxUtilization: 80, racks: 2},
+		"fit":   sizingParams{partitions: 10, replication: 3, partitionSize: 90, brokerStorage: 100, maxUtilization: 80},
+	}
+
+	expected := map[string]error{
+		"racks": errSizingRacks,
+		"fit":   errSizingFit,
+	}
+
+	for name, p := range tests {
+		if _, err := computeSizing(p); err != expected[name] {
+			t.Errorf("[%s] Expected error '%v', got '%v'", name, expected[name], err)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package commands
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+
+	"github.com/DataDog/kafka-kit/kafkazk"
+
+	"github.com/spf13/cobra"
+)
+
+var storageReportCmd = &cobra.Command{
+	Use:   "storage-report",
+	Short: "Report projected broker storage changes for a proposed partition map",
+	Long: `storage-report takes a proposed partition map via --map-string and
+compares it against the current assignments of the referenced topics in ZooKeeper.
+The current and projected free storage range spread is reported along with the
+projected free storage for each broker. Brokers projected to have less free storage
+than --min-free-gb are flagged.`,
+	Run: storageReport,
+}
+
+func init() {
+	rootCmd.AddCommand(storageReportCmd)
+
+	storageReportCmd.Flags().String("map-string", "", "Proposed partition map provided as a string literal")
+	storageReportCmd.Flags().Float64("min-free-gb", 0.00, "Flag brokers projected to have less than this many gigabytes of free storage")
+	storageReportCmd.Flags().String("zk-metrics-prefix", "topicmappr", "ZooKeeper namespace prefix for Kafka metrics")
+	storageReportCmd.Flags().Int("metrics-age", 60, "Kafka metrics age tolerance (in minutes)")
+
+	// Required.
+	storageReportCmd.MarkFlagRequired("map-string")
+}
+
+// brokerProjection holds the current and
+// projected free storage for a broker.
+type brokerProjection struct {
+	id        int
+	current   float64
+	projected float64
+	flagged   bool
+}
+
+// storageProjection holds current and projected
+// storage statistics for a proposed map.
+type storageProjection struct {
+	currentSpread   float64
+	projectedSpread float64
+	brokers         []brokerProjection
+	flagged         []int
+}
+
+func storageReport(cmd *cobra.Command, _ []string) {
+	proposed, err := kafkazk.PartitionMapFromString(cmd.Flag("map-string").Value.String())
+	if err != nil {
+		fmt.Fprintln(textOut, err)
+		exit(1)
+	}
+
+	// ZooKeeper init.
+	zk, err := initZooKeeper(cmd)
+	if err != nil {
+		fmt.Fprintln(textOut, err)
+		exit(1)
+	}
+
+	defer zk.Close()
+
+	// Get broker and partition metadata.
+	checkMetaAge(cmd, zk)
+	brokerMeta := getBrokerMeta(cmd, zk, true)
+	partitionMeta := getPartitionMeta(cmd, zk)
+
+	// Get the current map for all topics
+	// referenced in the proposed map.
+	var topics []*regexp.Regexp
+	seen := map[string]bool{}
+	for _, p := range proposed.Partitions {
+		if !seen[p.Topic] {
+			topics = append(topics, regexp.MustCompile(fmt.Sprintf("^%s$", regexp.QuoteMeta(p.Topic))))
+			seen[p.Topic] = true
+		}
+	}
+
+	current, err := kafkazk.PartitionMapFromZK(topics, zk)
+	if err != nil {
+		fmt.Fprintln(textOut, err)
+		exit(1)
+	}
+
+	brokers, err := brokerMapForPlan(current, proposed, brokerMeta)
+	if err != nil {
+		fmt.Fprintln(textOut, err)
+		exit(1)
+	}
+
+	min, _ := cmd.Flags().GetFloat64("min-free-gb")
+
+	report, err := buildStorageReport(brokers, current, proposed, partitionMeta, min*div)
+	if err != nil {
+		fmt.Fprintln(textOut, err)
+		exit(1)
+	}
+
+	printStorageReport(report, min)
+}
+
+// brokerMapForPlan returns a BrokerMap of all brokers referenced in
+// either the current or proposed PartitionMap. An error is returned if
+// any referenced broker is missing from the BrokerMetaMap.
+func brokerMapForPlan(current, proposed *kafkazk.PartitionMap, bm kafkazk.BrokerMetaMap) (kafkazk.BrokerMap, error) {
+	brokers := kafkazk.BrokerMap{}
+
+	for _, pm := range []*kafkazk.PartitionMap{current, proposed} {
+		for _, p := range pm.Partitions {
+			for _, id := range p.Replicas {
+				meta, exists := bm[id]
+				if !exists {
+					return nil, fmt.Errorf("Broker %d not found in ZooKeeper", id)
+				}
+
+				if meta.MetricsIncomplete {
+					return nil, fmt.Errorf("Metrics not found for broker %d", id)
+				}
+
+				brokers[id] = &kafkazk.Broker{
+					ID:          id,
+					Locality:    meta.Rack,
+					StorageFree: meta.StorageFree,
+				}
+			}
+		}
+	}
+
+	return brokers, nil
+}
+
+// buildStorageReport takes a BrokerMap, the current and proposed PartitionMaps,
+// a PartitionMetaMap and a minimum free storage threshold in bytes and returns a
+// storageProjection. Brokers projected below the threshold are flagged.
+func buildStorageReport(bm kafkazk.BrokerMap, current, proposed *kafkazk.PartitionMap, pmm kafkazk.PartitionMetaMap, min float64) (storageProjection, error) {
+	report := storageProjection{}
+
+	projected, err := bm.ProjectStorage(current, proposed, pmm)
+	if err != nil {
+		return report, err
+	}
+
+	report.currentSpread = bm.StorageRangeSpread()
+	report.projectedSpread = projected.StorageRangeSpread()
+
+	var ids []int
+	for id := range bm {
+		ids = append(ids, id)
+	}
+
+	sort.Ints(ids)
+
+	for _, id := range ids {
+		bp := brokerProjection{
+			id:        id,
+			current:   bm[id].StorageFree,
+			projected: projected[id].StorageFree,
+		}
+
+		if bp.projected < min {
+			bp.flagged = true
+			report.flagged = append(report.flagged, id)
+		}
+
+		report.brokers = append(report.brokers, bp)
+	}
+
+	return report, nil
+}
+
+// printStorageReport prints a storageProjection. The
+// min threshold is specified in gigabytes.
+func printStorageReport(r storageProjection, min float64) {
+	fmt.Fprintln(textOut, "\nStorage free range spread:")
+	fmt.Fprintf(textOut, "%s%.2f%% -> %.2f%%\n", indent, r.currentSpread, r.projectedSpread)
+
+	fmt.Fprintln(textOut, "\nProjected broker storage free:")
+	for _, b := range r.brokers {
+		var flag string
+		if b.flagged {
+			flag = fmt.Sprintf(" *below %.2fGB", min)
+		}
+
+		fmt.Fprintf(textOut, "%sBroker %d: %.2f -> %.2f (%+.2fGB)%s\n",
+			indent, b.id, b.current/div, b.projected/div, (b.projected-b.current)/div, flag)
+	}
+
+	if len(r.flagged) > 0 {
+		fmt.Fprintf(textOut, "\n[WARNING] brokers projected below %.2fGB free: %v\n", min, r.flagged)
+	}
+}
@@ -0,0 +1,77 @@
+package commands
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/DataDog/kafka-kit/kafkazk"
+)
+
+func TestBuildStorageReport(t *testing.T) {
+	zk := &kafkazk.Mock{}
+	bmm, _ := zk.GetAllBrokerMeta(true)
+	pmm, _ := zk.GetAllPartitionMeta()
+
+	current, _ := kafkazk.PartitionMapFromString(`{"version":1,"partitions":[
+    {"topic":"test_topic","partition":0,"replicas":[1001,1002]},
+    {"topic":"test_topic","partition":1,"replicas":[1002,1001]},
+    {"topic":"test_topic","partition":2,"replicas":[1003,1004]},
+    {"topic":"test_topic","partition":3,"replicas":[1004,1003]}]}`)
+
+	// Move p0 from 1001 to 1005 and p2 from 1004 to 1005.
+	proposed, _ := kafkazk.PartitionMapFromString(`{"version":1,"partitions":[
+    {"topic":"test_topic","partition":0,"replicas":[1005,1002]},
+    {"topic":"test_topic","partition":2,"replicas":[1003,1005]}]}`)
+
+	bm, err := brokerMapForPlan(current, proposed, bmm)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	report, err := buildStorageReport(bm, current, proposed, pmm, 3500.00)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	// 2000 -> 10000.
+	if s := fmt.Sprintf("%.2f", report.currentSpread); s != "400.00" {
+		t.Errorf("Expected current spread 400.00, got %s", s)
+	}
+
+	// 3000 -> 10000.
+	if s := fmt.Sprintf("%.2f", report.projectedSpread); s != "233.33" {
+		t.Errorf("Expected projected spread 233.33, got %s", s)
+	}
+
+	expected := map[int]float64{
+		1001: 3000.00,
+		1002: 4000.00,
+		1003: 6000.00,
+		1004: 10000.00,
+		1005: 7000.00,
+	}
+
+	if len(report.brokers) != len(expected) {
+		t.Fatalf("Expected %d brokers, got %d", len(expected), len(report.brokers))
+	}
+
+	for _, b := range report.brokers {
+		if b.projected != expected[b.id] {
+			t.Errorf("Expected broker %d projected storage %.2f, got %.2f", b.id, expected[b.id], b.projected)
+		}
+
+		if b.flagged != (b.id == 1001) {
+			t.Errorf("Unexpected flagged value %v for broker %d", b.flagged, b.id)
+		}
+	}
+
+	if len(report.flagged) != 1 || report.flagged[0] != 1001 {
+		t.Errorf("Expected flagged brokers [1001], got %v", report.flagged)
+	}
+
+	// A broker not found in the metadata.
+	proposed.Partitions[0].Replicas = []int{1010, 1002}
+	if _, err := brokerMapForPlan(current, proposed, bmm); err == nil {
+		t.Error("Expected error for unknown broker")
+	}
+}
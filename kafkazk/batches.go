@@ -0,0 +1,150 @@
+package kafkazk
+
+import (
+	"fmt"
+	"sort"
+)
+
+// partitionMove describes a partition reassignment along with
+// the bytes each broker is expected to transfer to complete it.
+type partitionMove struct {
+	partition Partition
+	size      float64
+	transfer  map[int]float64
+}
+
+// batch is a set of partition moves and the
+// sum of transfer bytes per broker.
+type batch struct {
+	moves    []partitionMove
+	transfer map[int]float64
+}
+
+// fits returns whether the move m can be added to
+// the batch without any broker exceeding the limit l.
+func (b batch) fits(m partitionMove, l float64) bool {
+	for id, t := range m.transfer {
+		if b.transfer[id]+t > l {
+			return false
+		}
+	}
+
+	return true
+}
+
+// TransferBatches takes the original PartitionMap, a PartitionMetaMap and
+// a per-broker transfer limit l in bytes. All partitions that differ between
+// the original and the calling PartitionMap are sequenced into a []*PartitionMap
+// of batches to be applied in order. Within each batch, the bytes transferred by
+// any single broker are bounded by l. A broker transfers the size of a partition
+// for each new replica it receives (as a target) and for each new replica sourced
+// from it as the existing partition leader. Moves are packed largest first; a
+// move that alone exceeds the limit is placed in a dedicated batch. An error is
+// returned if a partition is missing from the original map or the PartitionMetaMap.
+func (pm *PartitionMap) TransferBatches(orig *PartitionMap, pmm PartitionMetaMap, l float64) ([]*PartitionMap, error) {
+	// Index the original partitions.
+	prev := map[string]map[int]Partition{}
+	for _, p := range orig.Partitions {
+		if _, exists := prev[p.Topic]; !exists {
+			prev[p.Topic] = map[int]Partition{}
+		}
+		prev[p.Topic][p.Partition] = p
+	}
+
+	var moves []partitionMove
+
+	for _, p := range pm.Partitions {
+		op, exists := prev[p.Topic][p.Partition]
+		if !exists {
+			return nil, fmt.Errorf("%s p%d not found in original map", p.Topic, p.Partition)
+		}
+
+		// Skip no-ops.
+		if p.Equal(op) {
+			continue
+		}
+
+		size, err := pmm.Size(p)
+		if err != nil {
+			return nil, err
+		}
+
+		m := partitionMove{
+			partition: p,
+			size:      size,
+			transfer:  map[int]float64{},
+		}
+
+		// Find new replicas.
+		existing := map[int]bool{}
+		for _, id := range op.Replicas {
+			existing[id] = true
+		}
+
+		for _, id := range p.Replicas {
+			if existing[id] {
+				continue
+			}
+
+			m.transfer[id] += size
+			if len(op.Replicas) > 0 {
+				m.transfer[op.Replicas[0]] += size
+			}
+		}
+
+		moves = append(moves, m)
+	}
+
+	// Sort by size descending, then by
+	// topic and partition for determinism.
+	sort.Slice(moves, func(i, j int) bool {
+		mi, mj := moves[i], moves[j]
+		switch {
+		case mi.size != mj.size:
+			return mi.size > mj.size
+		case mi.partition.Topic != mj.partition.Topic:
+			return mi.partition.Topic < mj.partition.Topic
+		}
+		return mi.partition.Partition < mj.partition.Partition
+	})
+
+	// First fit.
+	var batches []batch
+
+	for _, m := range moves {
+		var placed bool
+
+		for i := range batches {
+			if batches[i].fits(m, l) {
+				batches[i].moves = append(batches[i].moves, m)
+				for id, t := range m.transfer {
+					batches[i].transfer[id] += t
+				}
+				placed = true
+				break
+			}
+		}
+
+		if !placed {
+			b := batch{moves: []partitionMove{m}, transfer: map[int]float64{}}
+			for id, t := range m.transfer {
+				b.transfer[id] += t
+			}
+			batches = append(batches, b)
+		}
+	}
+
+	// Build a PartitionMap per batch.
+	var out []*PartitionMap
+	for _, b := range batches {
+		bpm := NewPartitionMap()
+		for _, m := range b.moves {
+			bpm.Partitions = append(bpm.Partitions, m.partition)
+		}
+
+		sort.Sort(bpm.Partitions)
+		out = append(out, bpm)
+	}
+
+	return out, nil
+}
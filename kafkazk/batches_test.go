@@ -0,0 +1,117 @@
+package kafkazk
+
+import (
+	"testing"
+)
+
+func testGetBatchMaps() (*PartitionMap, *PartitionMap) {
+	orig, _ := PartitionMapFromString(`{"version":1,"partitions":[
+    {"topic":"test_topic","partition":0,"replicas":[1001,1002]},
+    {"topic":"test_topic","partition":1,"replicas":[1002,1001]},
+    {"topic":"test_topic","partition":2,"replicas":[1003,1004]},
+    {"topic":"test_topic","partition":3,"replicas":[1004,1003]},
+    {"topic":"test_topic","partition":4,"replicas":[1001,1003]},
+    {"topic":"test_topic","partition":5,"replicas":[1002,1004]}]}`)
+
+	pm, _ := PartitionMapFromString(`{"version":1,"partitions":[
+    {"topic":"test_topic","partition":0,"replicas":[1001,1005]},
+    {"topic":"test_topic","partition":1,"replicas":[1005,1001]},
+    {"topic":"test_topic","partition":2,"replicas":[1003,1004]},
+    {"topic":"test_topic","partition":3,"replicas":[1004,1005]},
+    {"topic":"test_topic","partition":4,"replicas":[1001,1005]},
+    {"topic":"test_topic","partition":5,"replicas":[1005,1004]}]}`)
+
+	return orig, pm
+}
+
+func TestTransferBatches(t *testing.T) {
+	zk := &Mock{}
+	pmm, _ := zk.GetAllPartitionMeta()
+	orig, pm := testGetBatchMaps()
+
+	limit := 5000.00
+
+	batches, err := pm.TransferBatches(orig, pmm, limit)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	seen := map[int]int{}
+
+	for i, b := range batches {
+		transfer := map[int]float64{}
+
+		for _, p := range b.Partitions {
+			seen[p.Partition]++
+
+			size, _ := pmm.Size(p)
+			op := orig.Partitions[p.Partition]
+
+			existing := map[int]bool{}
+			for _, id := range op.Replicas {
+				existing[id] = true
+			}
+
+			for _, id := range p.Replicas {
+				if !existing[id] {
+					transfer[id] += size
+					transfer[op.Replicas[0]] += size
+				}
+			}
+		}
+
+		for id, bytes := range transfer {
+			if bytes > limit {
+				t.Errorf("[batch %d] Broker %d transfer of %.2f exceeds limit %.2f", i, id, bytes, limit)
+			}
+		}
+	}
+
+	// p2 is a no-op; all other partitions
+	// should be scheduled exactly once.
+	for _, p := range []int{0, 1, 3, 4, 5} {
+		if seen[p] != 1 {
+			t.Errorf("Expected partition %d scheduled once, got %d", p, seen[p])
+		}
+	}
+
+	if seen[2] != 0 {
+		t.Error("Unexpected no-op partition 2 scheduled")
+	}
+
+	// 1005 receives 11200 bytes total.
+	if len(batches) < 3 {
+		t.Errorf("Expected at least 3 batches, got %d", len(batches))
+	}
+}
+
+func TestTransferBatchesOversized(t *testing.T) {
+	zk := &Mock{}
+	pmm, _ := zk.GetAllPartitionMeta()
+	orig, pm := testGetBatchMaps()
+
+	// Every move exceeds the limit and
+	// should be placed in a dedicated batch.
+	batches, err := pm.TransferBatches(orig, pmm, 500.00)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if len(batches) != 5 {
+		t.Errorf("Expected 5 batches, got %d", len(batches))
+	}
+
+	// Largest first.
+	if p := batches[0].Partitions[0].Partition; p != 5 {
+		t.Errorf("Expected partition 5 in the first batch, got %d", p)
+	}
+}
+
+func TestTransferBatchesMissingMeta(t *testing.T) {
+	orig, pm := testGetBatchMaps()
+
+	_, err := pm.TransferBatches(orig, NewPartitionMetaMap(), 5000.00)
+	if err == nil {
+		t.Error("Expected missing partition metadata error")
+	}
+}
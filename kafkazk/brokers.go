@@ -1,9 +1,15 @@
 package kafkazk
 
 import (
+	"encoding/json"
 	"fmt"
+	"math"
 	"math/rand"
+	"net"
 	"sort"
+	"strconv"
+	"strings"
+	"time"
 )
 
 // BrokerMetaMap is a map of broker IDs to BrokerMeta
@@ -15,7 +21,14 @@ type BrokerMetaMap map[int]*BrokerMeta
 // used in satisfying constraints.
 type BrokerMeta struct {
 	StorageFree       float64 // In bytes.
+	StorageTotal      float64 // In bytes; 0 if unknown.
 	MetricsIncomplete bool
+	// Storage free in bytes by log dir path.
+	LogDirs map[string]float64
+	// Anti-affinity group names.
+	AntiAffinityGroups []string
+	// Registry tags.
+	Tags map[string]string
 	// Metadata from ZooKeeper.
 	ListenerSecurityProtocolMap map[string]string `json:"listener_security_protocol_map"`
 	Endpoints                   []string          `json:"endpoints"`
@@ -24,7 +37,137 @@ type BrokerMeta struct {
 	Host                        string            `json:"host"`
 	Timestamp                   string            `json:"timestamp"`
 	Port                        int               `json:"port"`
-	Version                     int               `json:"version"`
+	// Version is the broker znode schema version. If the
+	// znode omits the version, it's inferred from the
+	// fields present; see UnmarshalJSON.
+	Version int `json:"version"`
+}
+
+// brokerMetaJSON is used to unmarshal a BrokerMeta
+// without recursing into BrokerMeta.UnmarshalJSON.
+type brokerMetaJSON BrokerMeta
+
+// UnmarshalJSON unmarshals broker znode data written by any Kafka version
+// (broker znode schema v1 through v5). Fields absent from older schemas are
+// left zero valued. If the host or port fields are null or unset, as is the
+// case for brokers without a PLAINTEXT listener since schema v4, they're
+// populated from the first parseable endpoint. If the version field is
+// absent, the schema version is inferred from the fields present.
+func (b *BrokerMeta) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, (*brokerMetaJSON)(b)); err != nil {
+		return err
+	}
+
+	if b.Host == "" || b.Port <= 0 {
+		for _, e := range b.Endpoints {
+			host, port, err := parseEndpoint(e)
+			if err != nil {
+				continue
+			}
+
+			if b.Host == "" {
+				b.Host = host
+			}
+			if b.Port <= 0 {
+				b.Port = port
+			}
+
+			break
+		}
+	}
+
+	if b.Version == 0 {
+		fields := map[string]json.RawMessage{}
+		if err := json.Unmarshal(data, &fields); err != nil {
+			return err
+		}
+
+		b.Version = brokerSchemaVersion(fields)
+	}
+
+	return nil
+}
+
+// brokerSchemaVersion returns the broker znode schema
+// version according to the fields present.
+func brokerSchemaVersion(fields map[string]json.RawMessage) int {
+	// Fields in order of the schema
+	// version that introduced them.
+	introduced := []struct {
+		field   string
+		version int
+	}{
+		{"features", 5},
+		{"listener_security_protocol_map", 4},
+		{"rack", 3},
+		{"endpoints", 2},
+	}
+
+	for _, f := range introduced {
+		if _, exists := fields[f.field]; exists {
+			return f.version
+		}
+	}
+
+	return 1
+}
+
+// EndpointFor returns the host and port of the broker endpoint for the
+// named listener (e.g. "PLAINTEXT", "INTERNAL"). If no endpoint has that
+// listener name, the listener is treated as a security protocol and the
+// first endpoint whose listener maps to it in the ListenerSecurityProtocolMap
+// is used. IPv6 hosts are returned without brackets.
+func (b *BrokerMeta) EndpointFor(listener string) (host string, port int, err error) {
+	// Exact listener name match.
+	for _, e := range b.Endpoints {
+		if l, _ := endpointListener(e); strings.EqualFold(l, listener) {
+			return parseEndpoint(e)
+		}
+	}
+
+	// Security protocol match.
+	for _, e := range b.Endpoints {
+		l, _ := endpointListener(e)
+		for name, protocol := range b.ListenerSecurityProtocolMap {
+			if strings.EqualFold(name, l) && strings.EqualFold(protocol, listener) {
+				return parseEndpoint(e)
+			}
+		}
+	}
+
+	return "", 0, fmt.Errorf("No endpoint found for listener '%s'", listener)
+}
+
+// endpointListener splits a broker endpoint string in the form
+// listener://host:port into the listener name and host:port.
+func endpointListener(e string) (string, string) {
+	parts := strings.SplitN(e, "://", 2)
+	if len(parts) != 2 {
+		return "", ""
+	}
+
+	return parts[0], parts[1]
+}
+
+// parseEndpoint takes a broker endpoint string in the form
+// listener://host:port and returns the host and port.
+func parseEndpoint(e string) (string, int, error) {
+	l, hostport := endpointListener(e)
+	if l == "" {
+		return "", 0, fmt.Errorf("Invalid endpoint '%s'", e)
+	}
+
+	host, p, err := net.SplitHostPort(hostport)
+	if err != nil {
+		return "", 0, err
+	}
+
+	port, err := strconv.Atoi(p)
+	if err != nil {
+		return "", 0, err
+	}
+
+	return host, port, nil
 }
 
 // BrokerMetricsMap holds a mapping of broker
@@ -34,7 +177,96 @@ type BrokerMetricsMap map[int]*BrokerMetrics
 // BrokerMetrics holds broker metric
 // data fetched from ZK.
 type BrokerMetrics struct {
-	StorageFree float64
+	StorageFree  float64
+	StorageTotal float64
+	LogDirs      map[string]float64
+}
+
+// Aggregate returns the sum of StorageFree across all brokers in the
+// BrokerMetricsMap and the number of brokers. The reserved broker ID 0
+// is excluded.
+func (m BrokerMetricsMap) Aggregate() (totalFree float64, count int) {
+	for id, b := range m {
+		if id == 0 {
+			continue
+		}
+
+		totalFree += b.StorageFree
+		count++
+	}
+
+	return totalFree, count
+}
+
+// Utilization returns the fraction of total broker storage used, from 0.00
+// (empty) to 1.00 (full). If the storage total is unknown, 0.00 is returned.
+func (b *BrokerMeta) Utilization() float64 {
+	if b.StorageTotal <= 0 {
+		return 0.00
+	}
+
+	return (b.StorageTotal - b.StorageFree) / b.StorageTotal
+}
+
+// Equal returns whether two BrokerMeta hold the same broker registration
+// metadata: rack, endpoints, listener security protocol map, host, port,
+// JMX port and version. Transient fields such as storage metrics, the
+// registration timestamp and registry derived tags are ignored. Endpoint
+// order is significant. Two nil BrokerMeta are equal.
+func (b *BrokerMeta) Equal(other *BrokerMeta) bool {
+	if b == nil || other == nil {
+		return b == other
+	}
+
+	switch {
+	case b.Rack != other.Rack,
+		b.Host != other.Host,
+		b.Port != other.Port,
+		b.JMXPort != other.JMXPort,
+		b.Version != other.Version,
+		len(b.Endpoints) != len(other.Endpoints),
+		len(b.ListenerSecurityProtocolMap) != len(other.ListenerSecurityProtocolMap):
+		return false
+	}
+
+	for i := range b.Endpoints {
+		if b.Endpoints[i] != other.Endpoints[i] {
+			return false
+		}
+	}
+
+	for k, v := range b.ListenerSecurityProtocolMap {
+		if ov, exists := other.ListenerSecurityProtocolMap[k]; !exists || ov != v {
+			return false
+		}
+	}
+
+	return true
+}
+
+// RegisteredAt returns the broker registration time parsed from the
+// Timestamp field, which Kafka writes as milliseconds since the epoch. An
+// error is returned if the Timestamp is empty or malformed.
+func (b *BrokerMeta) RegisteredAt() (time.Time, error) {
+	ms, err := strconv.ParseInt(b.Timestamp, 10, 64)
+	if err != nil || ms < 0 {
+		return time.Time{}, fmt.Errorf("Invalid broker timestamp '%s'", b.Timestamp)
+	}
+
+	return time.Unix(0, ms*int64(time.Millisecond)), nil
+}
+
+// StaleSince returns whether the broker registered more than d ago. Broker
+// znodes are ephemeral and rewritten on each registration, so a stale
+// registration may indicate clock skew. False is returned if the
+// registration time can't be determined; see RegisteredAt.
+func (b *BrokerMeta) StaleSince(d time.Duration) bool {
+	t, err := b.RegisteredAt()
+	if err != nil {
+		return false
+	}
+
+	return time.Since(t) > d
 }
 
 // BrokerUseStats holds counts
@@ -55,32 +287,71 @@ func (b BrokerUseStatsList) Less(i, j int) bool { return b[i].ID < b[j].ID }
 // BrokerStatus summarizes change counts
 // from an input and output broker list.
 type BrokerStatus struct {
-	New        int
-	Missing    int
-	OldMissing int
-	Replace    int
+	New        int `json:"new"`
+	Missing    int `json:"missing"`
+	OldMissing int `json:"old_missing"`
+	Replace    int `json:"replace"`
+	Excluded   int `json:"excluded"`
 }
 
 // Changes returns a bool that indicates whether a
 // BrokerStatus values represent a change in brokers.
 func (bs BrokerStatus) Changes() bool {
 	switch {
-	case bs.New != 0, bs.Missing != 0, bs.OldMissing != 0, bs.Replace != 0:
+	case bs.New != 0, bs.Missing != 0, bs.OldMissing != 0, bs.Replace != 0, bs.Excluded != 0:
 		return true
 	}
 
 	return false
 }
 
+// String returns a summary of the non-zero BrokerStatus
+// counts, such as "brokers: 3 new, 1 missing, 2 replace".
+func (bs BrokerStatus) String() string {
+	var counts []string
+
+	for _, c := range []struct {
+		n    int
+		desc string
+	}{
+		{bs.New, "new"},
+		{bs.Missing, "missing"},
+		{bs.OldMissing, "old missing"},
+		{bs.Replace, "replace"},
+		{bs.Excluded, "excluded"},
+	} {
+		if c.n != 0 {
+			counts = append(counts, fmt.Sprintf("%d %s", c.n, c.desc))
+		}
+	}
+
+	if len(counts) == 0 {
+		return "brokers: no changes"
+	}
+
+	return "brokers: " + strings.Join(counts, ", ")
+}
+
 // Broker associates metadata with a real broker by ID.
 type Broker struct {
 	ID          int
 	Locality    string
 	Used        int
 	StorageFree float64
-	Replace     bool
-	Missing     bool
-	New         bool
+	// Total storage in bytes; 0 if unknown.
+	StorageTotal float64
+	LogDirs      map[string]float64
+	// Brokers sharing any anti-affinity group are
+	// never placed in the same replica set.
+	AntiAffinityGroups []string
+	Replace            bool
+	Missing            bool
+	New                bool
+	// Excluded brokers are ineligible for placements
+	// and have any existing replicas moved off of them.
+	// They're also marked Replace, but aren't counted
+	// or reported as replacements.
+	Excluded bool
 }
 
 // BrokerMap holds a mapping of broker IDs to *Broker.
@@ -92,7 +363,9 @@ type BrokerList []*Broker
 // Wrapper types for sort by methods.
 type brokersByCount BrokerList
 type brokersByStorage BrokerList
+type brokersByStorageAsc BrokerList
 type brokersByID BrokerList
+type brokersByUtilization BrokerList
 
 // Satisfy the sort interface for BrokerList types.
 
@@ -124,11 +397,70 @@ func (b brokersByStorage) Less(i, j int) bool {
 	return b[i].ID < b[j].ID
 }
 
+// By StorageFree value ascending. Ties are
+// broken by ID ascending.
+func (b brokersByStorageAsc) Len() int      { return len(b) }
+func (b brokersByStorageAsc) Swap(i, j int) { b[i], b[j] = b[j], b[i] }
+func (b brokersByStorageAsc) Less(i, j int) bool {
+	if b[i].StorageFree == b[j].StorageFree {
+		return b[i].ID < b[j].ID
+	}
+
+	return brokersByStorage(b).Less(j, i)
+}
+
 // By ID value ascending.
 func (b brokersByID) Len() int           { return len(b) }
 func (b brokersByID) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
 func (b brokersByID) Less(i, j int) bool { return b[i].ID < b[j].ID }
 
+// By storage utilization ascending. Brokers with an unknown
+// StorageTotal are ordered last. Ties are broken by ID ascending.
+func (b brokersByUtilization) Len() int      { return len(b) }
+func (b brokersByUtilization) Swap(i, j int) { b[i], b[j] = b[j], b[i] }
+func (b brokersByUtilization) Less(i, j int) bool {
+	ki, kj := b[i].StorageTotal > 0, b[j].StorageTotal > 0
+	if ki != kj {
+		return ki
+	}
+
+	ui, uj := b[i].Utilization(), b[j].Utilization()
+	if ui != uj {
+		return ui < uj
+	}
+
+	return b[i].ID < b[j].ID
+}
+
+// IDs returns the BrokerList broker IDs sorted in ascending order.
+// The BrokerList itself is not modified.
+func (b BrokerList) IDs() []int {
+	ids := []int{}
+
+	for _, br := range b {
+		ids = append(ids, br.ID)
+	}
+
+	sort.Ints(ids)
+
+	return ids
+}
+
+// Partition splits the BrokerList in a single pass into the brokers that
+// return true as an input to function f and those that don't. The input
+// order is preserved in both lists.
+func (b BrokerList) Partition(f func(*Broker) bool) (matched, rest BrokerList) {
+	for _, br := range b {
+		if f(br) {
+			matched = append(matched, br)
+		} else {
+			rest = append(rest, br)
+		}
+	}
+
+	return matched, rest
+}
+
 // Sort methods.
 
 // SortByCount sorts the BrokerList by Used values.
@@ -141,11 +473,87 @@ func (b BrokerList) SortByStorage() {
 	sort.Sort(brokersByStorage(b))
 }
 
+// SortByStorageAscending sorts the BrokerList by StorageFree
+// values, least free first.
+func (b BrokerList) SortByStorageAscending() {
+	sort.Sort(brokersByStorageAsc(b))
+}
+
+// SortByUtilization sorts the BrokerList by storage utilization,
+// least utilized first. Brokers with an unknown StorageTotal are
+// ordered last.
+func (b BrokerList) SortByUtilization() {
+	sort.Sort(brokersByUtilization(b))
+}
+
 // SortByID sorts the BrokerList by ID values.
 func (b BrokerList) SortByID() {
 	sort.Sort(brokersByID(b))
 }
 
+// SortByRackWeight performs a stable sort of the BrokerList by rack load,
+// where the load of a rack is the sum of broker Used values divided by the
+// rack capacity. Rack capacity is the sum of broker StorageFree values, or
+// the number of brokers in the rack if any broker lacks StorageFree data.
+// Racks with more capacity are therefore preferred in proportion to their
+// capacity. The existing order of brokers with equal rack load is preserved.
+func (b BrokerList) SortByRackWeight() {
+	used := map[string]float64{}
+	storage := map[string]float64{}
+	count := map[string]float64{}
+	byStorage := true
+
+	for _, br := range b {
+		if br.ID == 0 {
+			continue
+		}
+
+		used[br.Locality] += float64(br.Used)
+		storage[br.Locality] += br.StorageFree
+		count[br.Locality]++
+
+		if br.StorageFree <= 0 {
+			byStorage = false
+		}
+	}
+
+	capacity := count
+	if byStorage {
+		capacity = storage
+	}
+
+	load := func(br *Broker) float64 {
+		return used[br.Locality] / capacity[br.Locality]
+	}
+
+	sort.SliceStable(b, func(i, j int) bool {
+		return load(b[i]) < load(b[j])
+	})
+}
+
+// SortByScore performs a stable sort of the BrokerList by the scores
+// returned by f for the partition p, lowest first. The existing order
+// of brokers with equal scores is preserved.
+func (b BrokerList) SortByScore(f ScoreFunc, p *Partition) {
+	scores := make(map[int]float64, len(b))
+	for _, br := range b {
+		scores[br.ID] = f(br, p)
+	}
+
+	sort.SliceStable(b, func(i, j int) bool {
+		return scores[b[i].ID] < scores[b[j].ID]
+	})
+}
+
+// SortByTopicCount performs a stable sort of the BrokerList by the
+// number of a topic's replicas held by each broker, as provided in
+// counts. The existing order of brokers with equal counts is preserved.
+func (b BrokerList) SortByTopicCount(counts map[int]int) {
+	sort.SliceStable(b, func(i, j int) bool {
+		return counts[b[i].ID] < counts[b[j].ID]
+	})
+}
+
 // SortPseudoShuffle takes a BrokerList and performs a sort by count.
 // For each sequence of brokers with equal counts, the sub-slice is
 // pseudo random shuffled using the provided seed value s.
@@ -181,13 +589,129 @@ func (b BrokerList) SortPseudoShuffle(seed int64) {
 	}
 }
 
+// SortPseudoShuffleWeighted takes a BrokerList and performs a sort by count.
+// For each sequence of brokers with equal counts, the sub-slice is shuffled
+// such that brokers with a greater weight, as returned by the weight func,
+// are more likely to be ordered earlier. Brokers with a weight of zero or
+// less are ordered after all others in the sequence. The shuffle uses a
+// pseudo random source seeded with the provided seed value; identical seeds
+// and inputs always produce identical orderings.
+func (b BrokerList) SortPseudoShuffleWeighted(seed int64, weight func(*Broker) float64) {
+	sort.Sort(brokersByCount(b))
+
+	r := rand.New(rand.NewSource(seed))
+
+	// For each continuous run of
+	// a given Used value, shuffle
+	// that range of the slice.
+	for s := 0; s < len(b); {
+		k := s + 1
+		for k < len(b) && b[k].Used == b[s].Used {
+			k++
+		}
+
+		weightedShuffle(b[s:k], r, weight)
+		s = k
+	}
+}
+
+// weightedShuffle orders the BrokerList by a random key per broker
+// drawn in proportion to its weight (Efraimidis-Spirakis). Keys are
+// computed as log(u)/weight, which orders identically to the typical
+// u^(1/weight) while avoiding precision loss for large weights.
+func weightedShuffle(b BrokerList, r *rand.Rand, weight func(*Broker) float64) {
+	if len(b) < 2 {
+		return
+	}
+
+	keys := map[int]float64{}
+	for _, br := range b {
+		w := weight(br)
+		if w <= 0 {
+			keys[br.ID] = math.Inf(-1)
+			continue
+		}
+
+		keys[br.ID] = math.Log(r.Float64()) / w
+	}
+
+	sort.SliceStable(b, func(i, j int) bool {
+		return keys[b[i].ID] > keys[b[j].ID]
+	})
+}
+
+// Exclude marks the brokers in the BrokerMap with an ID in ids as Excluded.
+// A subsequent Update marks excluded brokers for replacement whether or not
+// they're in the provided broker list, counting them as excluded rather than
+// replaced. IDs not in the BrokerMap are ignored.
+func (b BrokerMap) Exclude(ids []int) {
+	for _, id := range ids {
+		if broker, exists := b[id]; exists && id != 0 {
+			broker.Excluded = true
+		}
+	}
+}
+
 // Update takes a []int of broker IDs and BrokerMap then adds them to the
 // BrokerMap, returning the count of marked for replacement, newly included,
 // and brokers that weren't found in ZooKeeper. Additionally, a channel
 // of msgs describing changes is returned.
 func (b BrokerMap) Update(bl []int, bm BrokerMetaMap) (*BrokerStatus, <-chan string) {
+	bs, changes := b.update(bl, bm)
+
+	msgs := make(chan string, len(changes))
+	for _, c := range changes {
+		msgs <- c.msg
+	}
+
+	close(msgs)
+
+	return bs, msgs
+}
+
+// UpdateSorted is a variant of Update that returns the msgs describing
+// changes as a []string in a deterministic order: by broker ID, then by
+// category (previously mapped brokers missing, brokers marked for removal,
+// excluded brokers, provided brokers not found, new brokers).
+func (b BrokerMap) UpdateSorted(bl []int, bm BrokerMetaMap) (*BrokerStatus, []string) {
+	bs, changes := b.update(bl, bm)
+
+	sort.SliceStable(changes, func(i, j int) bool {
+		if changes[i].id != changes[j].id {
+			return changes[i].id < changes[j].id
+		}
+		return changes[i].category < changes[j].category
+	})
+
+	msgs := make([]string, len(changes))
+	for i, c := range changes {
+		msgs[i] = c.msg
+	}
+
+	return bs, msgs
+}
+
+// brokerChange describes a change made by update.
+type brokerChange struct {
+	id       int
+	category int
+	msg      string
+}
+
+// brokerChange categories, in sort order.
+const (
+	changeOldMissing = iota
+	changeRemoved
+	changeExcluded
+	changeNotFound
+	changeNew
+)
+
+// update performs Update, returning the BrokerStatus and
+// the changes made in the order they were observed.
+func (b BrokerMap) update(bl []int, bm BrokerMetaMap) (*BrokerStatus, []brokerChange) {
 	bs := &BrokerStatus{}
-	msgs := make(chan string, len(b)+(len(bl)*3))
+	var changes []brokerChange
 
 	// Build a map from the new broker list.
 	newBrokers := map[int]bool{}
@@ -205,7 +729,8 @@ func (b BrokerMap) Update(bl []int, bm BrokerMetaMap) (*BrokerStatus, <-chan str
 			}
 
 			if _, exist := bm[id]; !exist {
-				msgs <- fmt.Sprintf("Previous broker %d missing", id)
+				changes = append(changes, brokerChange{id, changeOldMissing,
+					fmt.Sprintf("Previous broker %d missing", id)})
 				b[id].Replace = true
 				b[id].Missing = true
 				// If this broker is missing and was provided in
@@ -229,10 +754,17 @@ func (b BrokerMap) Update(bl []int, bm BrokerMetaMap) (*BrokerStatus, <-chan str
 			continue
 		}
 
-		if _, ok := newBrokers[broker.ID]; !ok {
-			bs.Replace++
+		if _, ok := newBrokers[broker.ID]; !ok || broker.Excluded {
 			b[broker.ID].Replace = true
-			msgs <- fmt.Sprintf("Broker %d marked for removal", broker.ID)
+			if broker.Excluded {
+				bs.Excluded++
+				changes = append(changes, brokerChange{broker.ID, changeExcluded,
+					fmt.Sprintf("Broker %d excluded from placements", broker.ID)})
+				continue
+			}
+			bs.Replace++
+			changes = append(changes, brokerChange{broker.ID, changeRemoved,
+				fmt.Sprintf("Broker %d marked for removal", broker.ID)})
 		}
 	}
 
@@ -257,17 +789,22 @@ func (b BrokerMap) Update(bl []int, bm BrokerMetaMap) (*BrokerStatus, <-chan str
 			// the broker metadata map.
 			if meta, exists := bm[id]; exists {
 				b[id] = &Broker{
-					Used:        0,
-					ID:          id,
-					Replace:     false,
-					Locality:    meta.Rack,
-					StorageFree: meta.StorageFree,
-					New:         true,
+					Used:         0,
+					ID:           id,
+					Replace:      false,
+					Locality:     meta.Rack,
+					StorageFree:  meta.StorageFree,
+					StorageTotal: meta.StorageTotal,
+					LogDirs:      copyLogDirs(meta.LogDirs),
+					New:          true,
+
+					AntiAffinityGroups: copyGroups(meta.AntiAffinityGroups),
 				}
 				bs.New++
 			} else {
 				bs.Missing++
-				msgs <- fmt.Sprintf("Broker %d not found in ZooKeeper", id)
+				changes = append(changes, brokerChange{id, changeNotFound,
+					fmt.Sprintf("Broker %d not found in ZooKeeper", id)})
 			}
 		}
 	}
@@ -275,19 +812,92 @@ func (b BrokerMap) Update(bl []int, bm BrokerMetaMap) (*BrokerStatus, <-chan str
 	// Log new brokers.
 	for _, broker := range b {
 		if broker.New {
-			msgs <- fmt.Sprintf("New broker %d", broker.ID)
+			changes = append(changes, brokerChange{broker.ID, changeNew,
+				fmt.Sprintf("New broker %d", broker.ID)})
 		}
 	}
 
-	close(msgs)
+	return bs, changes
+}
 
-	return bs, msgs
+// UpdateChanged is a variant of Update that returns, along with the
+// BrokerStatus, a BrokerMap of only the brokers whose New, Replace or
+// Missing state changed as a result of the call. Brokers newly added to
+// the BrokerMap are included. The returned BrokerMap references the same
+// *Broker values held by the updated BrokerMap.
+func (b BrokerMap) UpdateChanged(bl []int, bm BrokerMetaMap) (*BrokerStatus, BrokerMap) {
+	type state struct{ new, replace, missing bool }
+
+	before := map[int]state{}
+	for id, broker := range b {
+		before[id] = state{broker.New, broker.Replace, broker.Missing}
+	}
+
+	// The msgs channel is closed
+	// and buffered; discard it.
+	bs, _ := b.Update(bl, bm)
+
+	changed := BrokerMap{}
+	for id, broker := range b {
+		prev, exists := before[id]
+		if !exists || prev != (state{broker.New, broker.Replace, broker.Missing}) {
+			changed[id] = broker
+		}
+	}
+
+	return bs, changed
+}
+
+// WarmNewBrokers seeds the Used value of all brokers marked as New to a fraction
+// f of the mean Used value of existing, non-replaced brokers. Since the count
+// placement strategy favors the least used brokers, new brokers otherwise start
+// at 0 and receive a disproportionate share of placements in a single pass.
+// A value of 0 leaves new brokers untouched; a value of 1.00 treats them as
+// equally used as the existing brokers.
+func (b BrokerMap) WarmNewBrokers(f float64) {
+	if f <= 0 {
+		return
+	}
+
+	var total, n int
+	for _, broker := range b {
+		if broker.ID == 0 || broker.New || broker.Replace {
+			continue
+		}
+		total += broker.Used
+		n++
+	}
+
+	if n == 0 {
+		return
+	}
+
+	warm := int(math.Round(float64(total) / float64(n) * f))
+
+	for _, broker := range b {
+		if broker.New {
+			broker.Used = warm
+		}
+	}
 }
 
 // SubStorageAll takes a PartitionMap, PartitionMetaMap, and a function. For all
 // brokers that return true as an input to function f, the size of all partitions
 // held is added back to the broker StorageFree value.
 func (b BrokerMap) SubStorage(pm *PartitionMap, pmm PartitionMetaMap, f func(*Broker) bool) error {
+	return b.subStorage(pm, pmm, f, false)
+}
+
+// SubStorageLeadersOnly is the same as SubStorage, but partition sizes are only
+// added back to the StorageFree value of the broker holding the leader (first)
+// replica. This is used to model leadership changes in terms of storage.
+// As with SubStorage, an error is returned if any replica references a broker
+// not in the BrokerMap.
+func (b BrokerMap) SubStorageLeadersOnly(pm *PartitionMap, pmm PartitionMetaMap, f func(*Broker) bool) error {
+	return b.subStorage(pm, pmm, f, true)
+}
+
+func (b BrokerMap) subStorage(pm *PartitionMap, pmm PartitionMetaMap, f func(*Broker) bool, leadersOnly bool) error {
 	// Get the size of each partition.
 	for _, partn := range pm.Partitions {
 		size, err := pmm.Size(partn)
@@ -297,9 +907,9 @@ func (b BrokerMap) SubStorage(pm *PartitionMap, pmm PartitionMetaMap, f func(*Br
 
 		// Add this size back to the
 		// StorageFree for all mapped brokers.
-		for _, bid := range partn.Replicas {
+		for i, bid := range partn.Replicas {
 			if broker, exists := b[bid]; exists {
-				if f(broker) {
+				if f(broker) && (i == 0 || !leadersOnly) {
 					broker.StorageFree += size
 				}
 			} else {
@@ -311,6 +921,100 @@ func (b BrokerMap) SubStorage(pm *PartitionMap, pmm PartitionMetaMap, f func(*Br
 	return nil
 }
 
+// ProjectStorage takes the original and proposed PartitionMaps and a
+// PartitionMetaMap. A copy of the BrokerMap is returned with StorageFree
+// values projected as if the proposed PartitionMap were applied: brokers
+// removed from a replica set regain the partition size while brokers added
+// to a replica set lose it. An error is returned if a proposed partition
+// isn't in the original map, is missing metadata, or references a broker
+// not in the BrokerMap.
+func (b BrokerMap) ProjectStorage(orig, proposed *PartitionMap, pmm PartitionMetaMap) (BrokerMap, error) {
+	projected := b.Copy()
+
+	// Index the original replica sets.
+	prev := map[string]map[int][]int{}
+	for _, p := range orig.Partitions {
+		if _, exists := prev[p.Topic]; !exists {
+			prev[p.Topic] = map[int][]int{}
+		}
+		prev[p.Topic][p.Partition] = p.Replicas
+	}
+
+	for _, p := range proposed.Partitions {
+		replicas, exists := prev[p.Topic][p.Partition]
+		if !exists {
+			return nil, fmt.Errorf("%s p%d not found in original map", p.Topic, p.Partition)
+		}
+
+		if err := projected.ApplyPartitionMove(p, replicas, p.Replicas, pmm); err != nil {
+			return nil, err
+		}
+	}
+
+	return projected, nil
+}
+
+// AdjustStorage adds delta, which may be negative, to the StorageFree value
+// of the broker ID. An error is returned if the broker isn't in the BrokerMap.
+func (b BrokerMap) AdjustStorage(id int, delta float64) error {
+	broker, exists := b[id]
+	if !exists {
+		return fmt.Errorf("Broker %d not found in broker map", id)
+	}
+
+	broker.StorageFree += delta
+
+	return nil
+}
+
+// ApplyPartitionMove updates broker StorageFree values for a change of the
+// partition p replica set from the src to the dst broker IDs. The partition
+// size is referenced from the PartitionMetaMap. Brokers removed from the
+// replica set regain the partition size while brokers added to it lose it;
+// brokers in both are unchanged. This allows storage to be maintained as
+// placements are made, rather than with a full SubStorage pass over all
+// partitions. If an error is returned, no StorageFree values are changed.
+func (b BrokerMap) ApplyPartitionMove(p Partition, src, dst []int, pmm PartitionMetaMap) error {
+	size, err := pmm.Size(p)
+	if err != nil {
+		return err
+	}
+
+	before, after := map[int]bool{}, map[int]bool{}
+	for _, id := range src {
+		before[id] = true
+	}
+	for _, id := range dst {
+		after[id] = true
+	}
+
+	// Ensure all brokers exist before
+	// making any adjustments.
+	for _, ids := range []map[int]bool{before, after} {
+		for id := range ids {
+			if _, exists := b[id]; !exists {
+				return fmt.Errorf("Broker %d not found in broker map", id)
+			}
+		}
+	}
+
+	// Removed replicas.
+	for id := range before {
+		if !after[id] {
+			b.AdjustStorage(id, size)
+		}
+	}
+
+	// Added replicas.
+	for id := range after {
+		if !before[id] {
+			b.AdjustStorage(id, -size)
+		}
+	}
+
+	return nil
+}
+
 // Filter returns a BrokerMap of brokers that return
 // true as an input to function f.
 func (b BrokerMap) Filter(f func(*Broker) bool) BrokerMap {
@@ -329,6 +1033,83 @@ func (b BrokerMap) Filter(f func(*Broker) bool) BrokerMap {
 	return bmap
 }
 
+// TagResolver resolves a broker ID to its tags,
+// such as those held in the registry tag storage.
+type TagResolver interface {
+	BrokerTags(int) (map[string]string, error)
+}
+
+// TagResolverFunc is a function that satisfies TagResolver.
+type TagResolverFunc func(int) (map[string]string, error)
+
+// BrokerTags calls f(id).
+func (f TagResolverFunc) BrokerTags(id int) (map[string]string, error) {
+	return f(id)
+}
+
+// FilterByTag returns a BrokerMap of brokers with tags, as resolved by r,
+// matching all selectors. A "key:value" selector matches brokers holding
+// the key with the specified value; a bare "key" or "key:" selector matches
+// brokers holding the key with any value. Selectors are applied with
+// BrokerMetaMap.FilterByTag. Brokers are included if no selectors are
+// provided. An error is returned for empty selector keys or if any tags
+// can't be resolved.
+func (b BrokerMap) FilterByTag(selectors []string, r TagResolver) (BrokerMap, error) {
+	type selector struct{ key, value string }
+
+	var sels []selector
+	for _, s := range selectors {
+		key, value, err := parseTagSelector(s)
+		if err != nil {
+			return nil, err
+		}
+		sels = append(sels, selector{key, value})
+	}
+
+	// Resolve tags into a
+	// BrokerMetaMap to filter.
+	bm := BrokerMetaMap{}
+	for id := range b {
+		if id == 0 {
+			continue
+		}
+
+		tags, err := r.BrokerTags(id)
+		if err != nil {
+			return nil, fmt.Errorf("Error resolving tags for broker %d: %s", id, err)
+		}
+
+		bm[id] = &BrokerMeta{Tags: tags}
+	}
+
+	for _, s := range sels {
+		bm = bm.FilterByTag(s.key, s.value)
+	}
+
+	bmap := BrokerMap{}
+	for id := range bm {
+		bmap[id] = b[id]
+	}
+
+	return bmap, nil
+}
+
+// parseTagSelector splits a "key:value" or bare "key" tag selector into
+// the key and value arguments of BrokerMetaMap.FilterByTag. An error is
+// returned if the key is empty.
+func parseTagSelector(s string) (string, string, error) {
+	kv := strings.SplitN(s, ":", 2)
+	if kv[0] == "" {
+		return "", "", fmt.Errorf("Invalid tag selector '%s'", s)
+	}
+
+	if len(kv) == 1 {
+		return kv[0], "", nil
+	}
+
+	return kv[0], kv[1], nil
+}
+
 // List take a BrokerMap and returns a BrokerList.
 func (b BrokerMap) List() BrokerList {
 	bl := BrokerList{}
@@ -340,6 +1121,22 @@ func (b BrokerMap) List() BrokerList {
 	return bl
 }
 
+// IDs returns the BrokerMap broker IDs sorted in ascending
+// order. The reserved stub broker ID 0 is excluded.
+func (b BrokerMap) IDs() []int {
+	ids := []int{}
+
+	for id := range b {
+		if id != 0 {
+			ids = append(ids, id)
+		}
+	}
+
+	sort.Ints(ids)
+
+	return ids
+}
+
 // BrokerMapFromPartitionMap creates a BrokerMap from a partitionMap.
 func BrokerMapFromPartitionMap(pm *PartitionMap, bm BrokerMetaMap, force bool) BrokerMap {
 	bmap := BrokerMap{}
@@ -368,6 +1165,9 @@ func BrokerMapFromPartitionMap(pm *PartitionMap, bm BrokerMetaMap, force bool) B
 			if meta, exists := bm[id]; exists {
 				bmap[id].Locality = meta.Rack
 				bmap[id].StorageFree = meta.StorageFree
+				bmap[id].StorageTotal = meta.StorageTotal
+				bmap[id].LogDirs = copyLogDirs(meta.LogDirs)
+				bmap[id].AntiAffinityGroups = copyGroups(meta.AntiAffinityGroups)
 			}
 		}
 	}
@@ -383,31 +1183,279 @@ func BrokerMapFromPartitionMap(pm *PartitionMap, bm BrokerMetaMap, force bool) B
 
 // Copy returns a copy of a BrokerMap.
 func (b BrokerMap) Copy() BrokerMap {
-	c := BrokerMap{}
-	for id, br := range b {
-		c[id] = &Broker{
-			ID:          br.ID,
-			Locality:    br.Locality,
-			Used:        br.Used,
-			StorageFree: br.StorageFree,
-			Replace:     br.Replace,
-			Missing:     br.Missing,
-			New:         br.New,
+	c := make(BrokerMap, len(b))
+	b.CopyInto(c)
+
+	return c
+}
+
+// WithMetrics returns a copy of the BrokerMap with broker StorageFree values
+// set from the BrokerMetricsMap. Brokers without metrics have StorageFree set
+// to 0. The BrokerMap is unmodified.
+func (b BrokerMap) WithMetrics(m BrokerMetricsMap) BrokerMap {
+	c := b.Copy()
+
+	for id, br := range c {
+		br.StorageFree = 0
+		if metrics, exists := m[id]; exists {
+			br.StorageFree = metrics.StorageFree
 		}
 	}
 
 	return c
 }
 
+// CopyInto copies the BrokerMap into dst, leaving dst equal to the BrokerMap.
+// Brokers in dst are updated in place and reuse any existing log dir and
+// anti-affinity group allocations, avoiding allocations when repeatedly copying
+// into the same BrokerMap. Brokers in dst not in the BrokerMap are removed.
+func (b BrokerMap) CopyInto(dst BrokerMap) {
+	for id := range dst {
+		if _, exists := b[id]; !exists {
+			delete(dst, id)
+		}
+	}
+
+	for id, br := range b {
+		existing, exists := dst[id]
+		switch {
+		case !exists:
+			c := br.Copy()
+			dst[id] = &c
+		case existing != br:
+			br.copyInto(existing)
+		}
+	}
+}
+
+// Snapshot returns a point-in-time copy of the BrokerMap
+// that can later be passed to Restore.
+func (b BrokerMap) Snapshot() BrokerMap {
+	return b.Copy()
+}
+
+// Restore takes a BrokerMap snapshot s and reverts the BrokerMap to the
+// snapshot state. Existing brokers are updated in place so that references
+// held elsewhere (such as in a BrokerList) reflect the restored state. Brokers
+// not present in the snapshot are removed. The snapshot remains unmodified and
+// can be restored multiple times.
+func (b BrokerMap) Restore(s BrokerMap) {
+	s.CopyInto(b)
+}
+
+// Merge copies non-zero field values from brokers in other into the brokers
+// with matching IDs in the BrokerMap, such as when combining broker localities
+// and storage metrics fetched from separate sources. Existing non-zero values
+// are only replaced if overwrite is true. Brokers only present in other are
+// added as copies. The reserved ID 0 is skipped.
+func (b BrokerMap) Merge(other BrokerMap, overwrite bool) {
+	for id, o := range other {
+		if id == 0 {
+			continue
+		}
+
+		br, exists := b[id]
+		if !exists {
+			b[id] = BrokerMap{id: o}.Copy()[id]
+			continue
+		}
+
+		if o.Locality != "" && (overwrite || br.Locality == "") {
+			br.Locality = o.Locality
+		}
+
+		if o.Used != 0 && (overwrite || br.Used == 0) {
+			br.Used = o.Used
+		}
+
+		if o.StorageFree != 0 && (overwrite || br.StorageFree == 0) {
+			br.StorageFree = o.StorageFree
+		}
+
+		if o.StorageTotal != 0 && (overwrite || br.StorageTotal == 0) {
+			br.StorageTotal = o.StorageTotal
+		}
+
+		if len(o.LogDirs) > 0 && (overwrite || len(br.LogDirs) == 0) {
+			br.LogDirs = copyLogDirs(o.LogDirs)
+		}
+
+		if len(o.AntiAffinityGroups) > 0 && (overwrite || len(br.AntiAffinityGroups) == 0) {
+			br.AntiAffinityGroups = copyGroups(o.AntiAffinityGroups)
+		}
+
+		// A true value is the only
+		// non-zero value of a bool.
+		br.Replace = br.Replace || o.Replace
+		br.Missing = br.Missing || o.Missing
+		br.New = br.New || o.New
+		br.Excluded = br.Excluded || o.Excluded
+	}
+}
+
+// Diff takes another BrokerMap and returns the IDs of brokers present only in
+// the other BrokerMap (added) and only in the calling BrokerMap (removed),
+// both sorted ascending. For brokers present in both with differing
+// StorageFree values, a map of broker ID to [before, after] StorageFree is
+// returned. The reserved broker ID 0 is excluded.
+func (b BrokerMap) Diff(other BrokerMap) (added, removed []int, storageChanged map[int][2]float64) {
+	storageChanged = map[int][2]float64{}
+
+	for id, br := range b {
+		if id == 0 {
+			continue
+		}
+
+		o, exists := other[id]
+		if !exists {
+			removed = append(removed, id)
+			continue
+		}
+
+		if br.StorageFree != o.StorageFree {
+			storageChanged[id] = [2]float64{br.StorageFree, o.StorageFree}
+		}
+	}
+
+	for id := range other {
+		if _, exists := b[id]; !exists && id != 0 {
+			added = append(added, id)
+		}
+	}
+
+	sort.Ints(added)
+	sort.Ints(removed)
+
+	return added, removed, storageChanged
+}
+
+// Utilization returns the fraction of total broker storage used, from 0.00
+// (empty) to 1.00 (full). If the storage total is unknown, 0.00 is returned.
+func (b *Broker) Utilization() float64 {
+	if b.StorageTotal <= 0 {
+		return 0.00
+	}
+
+	return (b.StorageTotal - b.StorageFree) / b.StorageTotal
+}
+
 // Copy returns a copy of a Broker.
 func (b Broker) Copy() Broker {
-	return Broker{
-		ID:          b.ID,
-		Locality:    b.Locality,
-		Used:        b.Used,
-		StorageFree: b.StorageFree,
-		Replace:     b.Replace,
-		Missing:     b.Missing,
-		New:         b.New,
+	c := b
+	c.LogDirs = copyLogDirs(b.LogDirs)
+	c.AntiAffinityGroups = copyGroups(b.AntiAffinityGroups)
+
+	return c
+}
+
+// copyInto copies the Broker into dst,
+// reusing the dst log dir map and anti-affinity
+// group slice allocations.
+func (b Broker) copyInto(dst *Broker) {
+	logDirs, groups := dst.LogDirs, dst.AntiAffinityGroups
+	*dst = b
+
+	dst.LogDirs = nil
+	if b.LogDirs != nil {
+		if logDirs == nil {
+			logDirs = make(map[string]float64, len(b.LogDirs))
+		}
+		for k := range logDirs {
+			delete(logDirs, k)
+		}
+		for k, v := range b.LogDirs {
+			logDirs[k] = v
+		}
+		dst.LogDirs = logDirs
+	}
+
+	dst.AntiAffinityGroups = nil
+	if b.AntiAffinityGroups != nil {
+		if groups == nil {
+			groups = make([]string, 0, len(b.AntiAffinityGroups))
+		}
+		dst.AntiAffinityGroups = append(groups[:0], b.AntiAffinityGroups...)
+	}
+}
+
+// BestLogDir returns the log dir path with the most storage free. An empty
+// string is returned if the broker has no log dir metadata.
+func (b *Broker) BestLogDir() string {
+	var best string
+	var free float64
+
+	for dir, f := range b.LogDirs {
+		// Break ties by path for deterministic output.
+		if best == "" || f > free || (f == free && dir < best) {
+			best, free = dir, f
+		}
+	}
+
+	return best
+}
+
+func copyLogDirs(m map[string]float64) map[string]float64 {
+	if m == nil {
+		return nil
+	}
+
+	c := make(map[string]float64, len(m))
+	for k, v := range m {
+		c[k] = v
 	}
+
+	return c
+}
+
+func copyGroups(g []string) []string {
+	if g == nil {
+		return nil
+	}
+
+	c := make([]string, len(g))
+	copy(c, g)
+
+	return c
+}
+
+// SetAntiAffinityGroups takes a map of broker ID to tag key-values and a
+// list of tag keys. For each tag key held by a broker, the broker is assigned
+// to the anti-affinity group named "key:value". Brokers in the same group
+// (e.g. "power-domain:pd1") won't be placed in the same replica set.
+func (bm BrokerMetaMap) SetAntiAffinityGroups(tags map[int]map[string]string, keys []string) {
+	for id, meta := range bm {
+		meta.AntiAffinityGroups = nil
+
+		for _, k := range keys {
+			if v, exists := tags[id][k]; exists && v != "" {
+				meta.AntiAffinityGroups = append(meta.AntiAffinityGroups, fmt.Sprintf("%s:%s", k, v))
+			}
+		}
+	}
+}
+
+// SetTags takes a map of broker ID to tag key-values
+// and sets the Tags field of each referenced broker.
+func (bm BrokerMetaMap) SetTags(tags map[int]map[string]string) {
+	for id, t := range tags {
+		if meta, exists := bm[id]; exists {
+			meta.Tags = t
+		}
+	}
+}
+
+// FilterByTag returns a BrokerMetaMap of all brokers holding the tag
+// key with the specified value. An empty value matches brokers holding
+// the key with any value. The BrokerMeta values are not copied.
+func (bm BrokerMetaMap) FilterByTag(key, value string) BrokerMetaMap {
+	filtered := BrokerMetaMap{}
+
+	for id, meta := range bm {
+		v, exists := meta.Tags[key]
+		if exists && (value == "" || v == value) {
+			filtered[id] = meta
+		}
+	}
+
+	return filtered
 }
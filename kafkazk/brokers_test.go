@@ -1,7 +1,13 @@
 package kafkazk
 
 import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
 	"testing"
+	"time"
 )
 
 func TestChanges(t *testing.T) {
@@ -35,6 +41,59 @@ func TestChanges(t *testing.T) {
 	}
 }
 
+func TestBrokerStatusString(t *testing.T) {
+	tests := map[int]BrokerStatus{
+		0: BrokerStatus{},
+		1: BrokerStatus{New: 3},
+		2: BrokerStatus{New: 3, Missing: 1, Replace: 2},
+		3: BrokerStatus{Missing: 1, OldMissing: 2},
+		4: BrokerStatus{New: 1, Missing: 1, OldMissing: 1, Replace: 1},
+		5: BrokerStatus{Replace: 2},
+		6: BrokerStatus{Replace: 1, Excluded: 2},
+	}
+
+	expected := map[int]string{
+		0: "brokers: no changes",
+		1: "brokers: 3 new",
+		2: "brokers: 3 new, 1 missing, 2 replace",
+		3: "brokers: 1 missing, 2 old missing",
+		4: "brokers: 1 new, 1 missing, 1 old missing, 1 replace",
+		5: "brokers: 2 replace",
+		6: "brokers: 1 replace, 2 excluded",
+	}
+
+	for i, bs := range tests {
+		if s := bs.String(); s != expected[i] {
+			t.Errorf("[test %d] Expected '%s', got '%s'", i, expected[i], s)
+		}
+	}
+}
+
+func TestUtilization(t *testing.T) {
+	tests := map[int]*BrokerMeta{
+		// Total unknown.
+		0: &BrokerMeta{StorageFree: 1000.00},
+		// Full disk.
+		1: &BrokerMeta{StorageFree: 0.00, StorageTotal: 1000.00},
+		// Empty disk.
+		2: &BrokerMeta{StorageFree: 1000.00, StorageTotal: 1000.00},
+		3: &BrokerMeta{StorageFree: 250.00, StorageTotal: 1000.00},
+	}
+
+	expected := map[int]float64{
+		0: 0.00,
+		1: 1.00,
+		2: 0.00,
+		3: 0.75,
+	}
+
+	for i, bm := range tests {
+		if u := bm.Utilization(); u != expected[i] {
+			t.Errorf("[test %d] Expected utilization %.2f, got %.2f", i, expected[i], u)
+		}
+	}
+}
+
 func TestSortBrokerListByCount(t *testing.T) {
 	b := newMockBrokerMap2()
 	bl := b.Filter(func(b *Broker) bool { return true }).List()
@@ -75,6 +134,66 @@ func TestSortBrokerListByStorage(t *testing.T) {
 	}
 }
 
+func TestSortBrokerListByStorageAscending(t *testing.T) {
+	b := newMockBrokerMap2()
+	bl := b.Filter(func(b *Broker) bool { return true }).List()
+
+	// Duplicate storage values.
+	b[1001].StorageFree = 500.00
+	b[1003].StorageFree = 500.00
+	b[1006].StorageFree = 500.00
+
+	// Reverse the input order.
+	sort.Sort(sort.Reverse(brokersByID(bl)))
+
+	bl.SortByStorageAscending()
+
+	var blIDs []int
+	for _, br := range bl {
+		blIDs = append(blIDs, br.ID)
+	}
+
+	expected := []int{1002, 1004, 1005, 1007, 1001, 1003, 1006}
+
+	for i, br := range bl {
+		if br.ID != expected[i] {
+			t.Fatalf("Expected %v, got %v", expected, blIDs)
+		}
+	}
+}
+
+func TestSortBrokerListByUtilization(t *testing.T) {
+	bl := BrokerList{
+		// Unknown totals.
+		&Broker{ID: 1006, StorageFree: 100.00},
+		&Broker{ID: 1005, StorageFree: 900.00},
+		// Large broker, 50% used.
+		&Broker{ID: 1001, StorageFree: 5000.00, StorageTotal: 10000.00},
+		// Small broker, 10% used.
+		&Broker{ID: 1002, StorageFree: 900.00, StorageTotal: 1000.00},
+		// Ties at 50% used, broken by ID.
+		&Broker{ID: 1004, StorageFree: 250.00, StorageTotal: 500.00},
+		&Broker{ID: 1003, StorageFree: 2000.00, StorageTotal: 4000.00},
+		// Empty.
+		&Broker{ID: 1007, StorageFree: 2000.00, StorageTotal: 2000.00},
+	}
+
+	bl.SortByUtilization()
+
+	var blIDs []int
+	for _, br := range bl {
+		blIDs = append(blIDs, br.ID)
+	}
+
+	expected := []int{1007, 1002, 1001, 1003, 1004, 1005, 1006}
+
+	for i, br := range bl {
+		if br.ID != expected[i] {
+			t.Fatalf("Expected %v, got %v", expected, blIDs)
+		}
+	}
+}
+
 func TestSortBrokerListByID(t *testing.T) {
 	b := newMockBrokerMap2()
 	bl := b.Filter(func(b *Broker) bool { return true }).List()
@@ -95,6 +214,67 @@ func TestSortBrokerListByID(t *testing.T) {
 	}
 }
 
+func TestBrokerListIDs(t *testing.T) {
+	bl := BrokerList{
+		&Broker{ID: 1003},
+		&Broker{ID: 1001},
+		&Broker{ID: 1002},
+	}
+
+	expected := []int{1001, 1002, 1003}
+	if ids := bl.IDs(); !reflect.DeepEqual(ids, expected) {
+		t.Errorf("Expected %v, got %v", expected, ids)
+	}
+
+	// The BrokerList order is preserved.
+	if bl[0].ID != 1003 {
+		t.Errorf("Expected BrokerList to be unmodified, got first ID %d", bl[0].ID)
+	}
+}
+
+func TestBrokerListPartition(t *testing.T) {
+	bl := BrokerList{
+		&Broker{ID: 1004},
+		&Broker{ID: 1001, Replace: true},
+		&Broker{ID: 1003},
+		&Broker{ID: 1005, Replace: true},
+		&Broker{ID: 1002},
+	}
+
+	matched, rest := bl.Partition(func(b *Broker) bool { return b.Replace })
+
+	ids := func(l BrokerList) []int {
+		var out []int
+		for _, b := range l {
+			out = append(out, b.ID)
+		}
+		return out
+	}
+
+	// Input order is preserved.
+	if expected := []int{1001, 1005}; !reflect.DeepEqual(ids(matched), expected) {
+		t.Errorf("Expected matched %v, got %v", expected, ids(matched))
+	}
+
+	if expected := []int{1004, 1003, 1002}; !reflect.DeepEqual(ids(rest), expected) {
+		t.Errorf("Expected rest %v, got %v", expected, ids(rest))
+	}
+
+	// Together, the outputs are a permutation of the input.
+	all := append(BrokerList{}, matched...)
+	all = append(all, rest...)
+
+	if !reflect.DeepEqual(all.IDs(), bl.IDs()) {
+		t.Errorf("Expected brokers %v, got %v", bl.IDs(), all.IDs())
+	}
+
+	// Empty input.
+	matched, rest = BrokerList{}.Partition(func(b *Broker) bool { return true })
+	if len(matched) != 0 || len(rest) != 0 {
+		t.Errorf("Expected empty outputs, got %v, %v", matched, rest)
+	}
+}
+
 func TestSortPseudoShuffle(t *testing.T) {
 	b := newMockBrokerMap2()
 	bl := b.Filter(func(b *Broker) bool { return true }).List()
@@ -120,6 +300,139 @@ func TestSortPseudoShuffle(t *testing.T) {
 	}
 }
 
+func TestSortPseudoShuffleWeighted(t *testing.T) {
+	b := newMockBrokerMap2()
+	all := func(b *Broker) bool { return true }
+	weight := func(b *Broker) float64 { return b.StorageFree }
+
+	// Identical seeds and inputs produce identical orderings.
+	for _, seed := range []int64{1, 3, 7} {
+		bl1 := b.Filter(all).List()
+		bl2 := b.Filter(all).List()
+
+		bl1.SortPseudoShuffleWeighted(seed, weight)
+		bl2.SortPseudoShuffleWeighted(seed, weight)
+
+		for i := range bl1 {
+			if bl1[i].ID != bl2[i].ID {
+				t.Errorf("[seed %d] Expected identical orderings, got %v and %v",
+					seed, brokerListIDs(bl1), brokerListIDs(bl2))
+				break
+			}
+		}
+
+		// Runs of equal Used counts are preserved.
+		for i := 1; i < len(bl1); i++ {
+			if bl1[i].Used < bl1[i-1].Used {
+				t.Errorf("[seed %d] Expected ascending Used values, got %v", seed, brokerListIDs(bl1))
+				break
+			}
+		}
+	}
+
+	// Brokers with a weight of zero
+	// are ordered last in each run.
+	zeroWeight := map[int]bool{1001: true, 1002: true, 1003: true}
+	weight = func(b *Broker) float64 {
+		if zeroWeight[b.ID] {
+			return 0
+		}
+		return b.StorageFree
+	}
+
+	for _, seed := range []int64{1, 3, 7} {
+		bl := b.Filter(all).List()
+		bl.SortPseudoShuffleWeighted(seed, weight)
+
+		// Used 2: 1004, 1005 then 1001, 1002.
+		// Used 3: 1006, 1007 then 1003.
+		for _, i := range []int{2, 3, 6} {
+			if !zeroWeight[bl[i].ID] {
+				t.Errorf("[seed %d] Expected a zero weight broker at position %d, got %v",
+					seed, i, brokerListIDs(bl))
+			}
+		}
+	}
+
+	// A heavier broker is ordered first more often.
+	heavy := 0
+	for seed := int64(0); seed < 200; seed++ {
+		bl := BrokerList{
+			&Broker{ID: 1001, StorageFree: 100.00},
+			&Broker{ID: 1002, StorageFree: 900.00},
+		}
+
+		bl.SortPseudoShuffleWeighted(seed, func(b *Broker) float64 { return b.StorageFree })
+
+		if bl[0].ID == 1002 {
+			heavy++
+		}
+	}
+
+	if heavy < 150 {
+		t.Errorf("Expected broker 1002 first in at least 150 of 200 shuffles, got %d", heavy)
+	}
+}
+
+func brokerListIDs(bl BrokerList) []int {
+	var ids []int
+	for _, b := range bl {
+		ids = append(ids, b.ID)
+	}
+
+	return ids
+}
+
+func TestSortByRackWeight(t *testing.T) {
+	bl := BrokerList{
+		&Broker{ID: 1001, Locality: "a", Used: 2, StorageFree: 100},
+		&Broker{ID: 1002, Locality: "a", Used: 2, StorageFree: 100},
+		&Broker{ID: 1003, Locality: "b", Used: 1, StorageFree: 100},
+		&Broker{ID: 1004, Locality: "c", Used: 3, StorageFree: 600},
+	}
+
+	// By storage, rack loads are a: 4/200, b: 1/100, c: 3/600.
+	bl.SortByRackWeight()
+
+	expected := []int{1004, 1003, 1001, 1002}
+	for i, b := range bl {
+		if b.ID != expected[i] {
+			t.Errorf("Expected ID %d at position %d, got %d", expected[i], i, b.ID)
+		}
+	}
+
+	// Without complete storage data, rack
+	// loads by count are a: 4/2, b: 1/1, c: 3/1.
+	bl[0].StorageFree = 0
+	bl.SortByRackWeight()
+
+	expected = []int{1003, 1001, 1002, 1004}
+	for i, b := range bl {
+		if b.ID != expected[i] {
+			t.Errorf("Expected ID %d at position %d, got %d", expected[i], i, b.ID)
+		}
+	}
+}
+
+func TestSortByTopicCount(t *testing.T) {
+	bl := BrokerList{
+		&Broker{ID: 1001},
+		&Broker{ID: 1002},
+		&Broker{ID: 1003},
+		&Broker{ID: 1004},
+	}
+
+	// 1003 holds none.
+	bl.SortByTopicCount(map[int]int{1001: 2, 1002: 1, 1004: 1})
+
+	expected := []int{1003, 1002, 1004, 1001}
+	for i, b := range bl {
+		if b.ID != expected[i] {
+			t.Errorf("Expected ID %d at position %d, got %d", expected[i], i, b.ID)
+		}
+	}
+}
+
 func TestUpdate(t *testing.T) {
 	zk := &Mock{}
 	bmm, _ := zk.GetAllBrokerMeta(false)
@@ -196,6 +509,199 @@ func TestUpdate(t *testing.T) {
 	}
 }
 
+func TestExclude(t *testing.T) {
+	zk := &Mock{}
+	bmm, _ := zk.GetAllBrokerMeta(false)
+	bm := newMockBrokerMap()
+
+	// 1002 is excluded while still in the broker
+	// list, 1003 is excluded and omitted from it.
+	// 1006 isn't in the BrokerMap and is ignored.
+	bm.Exclude([]int{1002, 1003, 1006})
+
+	stat, msgs := bm.UpdateSorted([]int{1001, 1002, 1005}, bmm)
+
+	if stat.Excluded != 2 {
+		t.Errorf("Expected Excluded count of 2, got %d", stat.Excluded)
+	}
+	if stat.Replace != 1 {
+		t.Errorf("Expected Replace count of 1, got %d", stat.Replace)
+	}
+
+	for _, id := range []int{1002, 1003} {
+		if !bm[id].Excluded || !bm[id].Replace {
+			t.Errorf("Expected ID %d Excluded and Replace == true", id)
+		}
+	}
+
+	if bm[1004].Excluded || !bm[1004].Replace {
+		t.Error("Expected ID 1004 Replace == true and Excluded == false")
+	}
+
+	if _, exists := bm[1006]; exists {
+		t.Error("ID 1006 unexpectedly exists in BrokerMap")
+	}
+
+	expected := []string{
+		"Broker 1002 excluded from placements",
+		"Broker 1003 excluded from placements",
+		"Broker 1004 marked for removal",
+		"New broker 1005",
+	}
+
+	if !reflect.DeepEqual(msgs, expected) {
+		t.Errorf("Expected msgs %v, got %v", expected, msgs)
+	}
+}
+
+func TestUpdateSorted(t *testing.T) {
+	zk := &Mock{}
+
+	expected := []string{
+		"Previous broker 1001 missing",
+		"Broker 1001 marked for removal",
+		"Previous broker 1002 missing",
+		"Broker 1004 marked for removal",
+		"New broker 1005",
+		"Broker 1006 not found in ZooKeeper",
+	}
+
+	// Ordering must be stable across
+	// map iteration orders.
+	for i := 0; i < 20; i++ {
+		bmm, _ := zk.GetAllBrokerMeta(false)
+		delete(bmm, 1001)
+		delete(bmm, 1002)
+		bm := newMockBrokerMap()
+
+		stat, msgs := bm.UpdateSorted([]int{1002, 1003, 1005, 1006}, bmm)
+
+		if stat.New != 1 || stat.Missing != 2 || stat.OldMissing != 1 || stat.Replace != 2 {
+			t.Fatalf("Unexpected BrokerStatus %+v", *stat)
+		}
+
+		if !reflect.DeepEqual(msgs, expected) {
+			t.Fatalf("Expected messages %v, got %v", expected, msgs)
+		}
+	}
+}
+
+func TestUpdateChanged(t *testing.T) {
+	zk := &Mock{}
+	bmm, _ := zk.GetAllBrokerMeta(false)
+	bm := newMockBrokerMap()
+
+	// 1001 and 1004 are removed, 1005 is added.
+	_, changed := bm.UpdateChanged([]int{1002, 1003, 1005}, bmm)
+
+	expected := []int{1001, 1004, 1005}
+
+	if len(changed) != len(expected) {
+		t.Errorf("Expected %d changed brokers, got %d", len(expected), len(changed))
+	}
+
+	for _, id := range expected {
+		if changed[id] != bm[id] {
+			t.Errorf("Expected changed broker %d", id)
+		}
+	}
+
+	// Repeating the update results in no changes.
+	stat, changed := bm.UpdateChanged([]int{1002, 1003, 1005}, bmm)
+	if len(changed) != 0 {
+		t.Errorf("Expected no changed brokers, got %d", len(changed))
+	}
+
+	// The BrokerStatus is still returned.
+	if stat.Replace != 2 {
+		t.Errorf("Expected Replace count of 2, got %d", stat.Replace)
+	}
+
+	// 1003 goes missing.
+	delete(bmm, 1003)
+	_, changed = bm.UpdateChanged([]int{1002, 1003, 1005}, bmm)
+
+	if len(changed) != 1 || changed[1003] == nil {
+		t.Errorf("Expected changed broker 1003, got %v", changed)
+	}
+}
+
+func TestWarmNewBrokers(t *testing.T) {
+	bm := newMockBrokerMap()
+	bm[1005] = &Broker{ID: 1005, Locality: "b", New: true}
+	bm[1004].Replace = true
+
+	// A 0 value is a no-op.
+	bm.WarmNewBrokers(0)
+	if bm[1005].Used != 0 {
+		t.Errorf("Expected Used value of 0, got %d", bm[1005].Used)
+	}
+
+	// Mean Used of 1001, 1002, 1003 is 2.67;
+	// 1004 is marked for replacement and excluded.
+	tests := map[float64]int{
+		0.50: 1,
+		1.00: 3,
+	}
+
+	for f, expected := range tests {
+		bm.WarmNewBrokers(f)
+		if bm[1005].Used != expected {
+			t.Errorf("Expected Used value of %d, got %d", expected, bm[1005].Used)
+		}
+	}
+
+	// Existing brokers should be untouched.
+	for _, id := range []int{1001, 1002} {
+		if bm[id].Used != 3 {
+			t.Errorf("Expected Used value of 3 for ID %d, got %d", id, bm[id].Used)
+		}
+	}
+}
+
+func TestWarmNewBrokersRebuild(t *testing.T) {
+	// Build a map with 1001, 1002, and 1003
+	// each holding 8 replicas.
+	pm := NewPartitionMap()
+	sets := [][]int{{1001, 1002}, {1002, 1003}, {1003, 1001}}
+	for i := 0; i < 12; i++ {
+		p := Partition{Topic: "test_topic", Partition: i, Replicas: sets[i%3]}
+		pm.Partitions = append(pm.Partitions, p)
+	}
+
+	// Replace 1003 with the new broker 1004, returning
+	// the number of replicas placed on 1004.
+	placed := func(f float64) int {
+		bm := BrokerMapFromPartitionMap(pm, BrokerMetaMap{}, false)
+		bm.Update([]int{1001, 1002, 1004}, BrokerMetaMap{})
+		bm.WarmNewBrokers(f)
+
+		out, errs := pm.Rebuild(RebuildParams{BM: bm, Strategy: "count"})
+		if errs != nil {
+			t.Errorf("Unexpected error(s): %s", errs)
+		}
+
+		var n int
+		for _, s := range out.UseStats() {
+			if s.ID == 1004 {
+				n = s.Leader + s.Follower
+			}
+		}
+
+		return n
+	}
+
+	cold, warm := placed(0), placed(1.00)
+
+	if cold != 8 {
+		t.Errorf("Expected 8 replicas placed on cold broker 1004, got %d", cold)
+	}
+
+	if warm >= cold {
+		t.Errorf("Expected fewer than %d replicas placed on warm broker 1004, got %d", cold, warm)
+	}
+}
+
 func TestSubStorageAll(t *testing.T) {
 	bm := newMockBrokerMap()
 	pm, _ := PartitionMapFromString(testGetMapString("test_topic"))
@@ -265,33 +771,319 @@ func TestSubStorageReplacements(t *testing.T) {
 	}
 }
 
-func TestFilter(t *testing.T) {
-	bm1 := newMockBrokerMap2()
-	f := func(b *Broker) bool {
-		if b.Locality == "a" {
-			return true
-		}
-		return false
+func TestSubStorageLeadersOnly(t *testing.T) {
+	pm, _ := PartitionMapFromString(testGetMapString("test_topic"))
+	pmm := NewPartitionMetaMap()
+
+	pmm["test_topic"] = map[int]*PartitionMeta{
+		0: &PartitionMeta{Size: 30},
+		1: &PartitionMeta{Size: 35},
+		2: &PartitionMeta{Size: 60},
+		3: &PartitionMeta{Size: 45},
 	}
 
-	bm2 := bm1.Filter(f)
+	allBrokers := func(b *Broker) bool { return true }
 
-	if len(bm2) != 3 {
-		t.Errorf("Expected BrokerMap len of 3, got %d", len(bm2))
+	all, leaders := newMockBrokerMap(), newMockBrokerMap()
+	base := newMockBrokerMap()
+
+	if err := all.SubStorage(pm, pmm, allBrokers); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
 	}
 
-	for _, id := range []int{1001, 1004, 1007} {
-		if _, exist := bm2[id]; !exist {
-			t.Errorf("Expected ID %d in BrokerMap", id)
+	if err := leaders.SubStorageLeadersOnly(pm, pmm, allBrokers); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	expected := map[int]float64{
+		1001: 130,
+		1002: 235,
+		1003: 360,
+		1004: 445,
+	}
+
+	for _, b := range leaders {
+		if b.StorageFree != expected[b.ID] {
+			t.Errorf("Expected '%f' StorageFree for ID %d, got '%f'",
+				expected[b.ID], b.ID, b.StorageFree)
 		}
 	}
+
+	// Compare the totals added back.
+	var allTotal, leadersTotal float64
+	for id := range base {
+		allTotal += all[id].StorageFree - base[id].StorageFree
+		leadersTotal += leaders[id].StorageFree - base[id].StorageFree
+	}
+
+	// Each partition size once per replica.
+	if allTotal != 445 {
+		t.Errorf("Expected all-replica total of 445, got %f", allTotal)
+	}
+
+	// Each partition size once.
+	if leadersTotal != 170 {
+		t.Errorf("Expected leader-only total of 170, got %f", leadersTotal)
+	}
+
+	// Brokers not in the map are an error,
+	// including non-leader replicas.
+	pm.Partitions[0].Replicas = []int{1001, 1010}
+	if err := newMockBrokerMap().SubStorageLeadersOnly(pm, pmm, allBrokers); err == nil {
+		t.Error("Expected error for unknown broker")
+	}
 }
 
-func TestBrokerMapFromPartitionMap(t *testing.T) {
-	zk := &Mock{}
-	bmm, _ := zk.GetAllBrokerMeta(false)
+func TestSubStorageMissingSizes(t *testing.T) {
 	pm, _ := PartitionMapFromString(testGetMapString("test_topic"))
-	forceRebuild := false
+	pmm := NewPartitionMetaMap()
+
+	// p2 and p3 are missing.
+	pmm["test_topic"] = map[int]*PartitionMeta{
+		0: &PartitionMeta{Size: 30},
+		1: &PartitionMeta{Size: 50},
+	}
+
+	allBrokers := func(b *Broker) bool { return true }
+
+	// Strict.
+	bm := newMockBrokerMap()
+	if err := bm.SubStorage(pm, pmm, allBrokers); err == nil {
+		t.Error("Expected error for missing partition size")
+	}
+
+	// Degraded, using the mean size of 40.
+	filled := pmm.FillMissing(pm, pmm.MeanSize())
+
+	if len(filled) != 2 || filled[0].Partition != 2 || filled[1].Partition != 3 {
+		t.Errorf("Expected filled partitions p2, p3, got %v", filled)
+	}
+
+	bm = newMockBrokerMap()
+	if err := bm.SubStorage(pm, pmm, allBrokers); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	expected := map[int]float64{
+		1001: 220,
+		1002: 320,
+		1003: 380,
+		1004: 480,
+	}
+
+	for id, v := range expected {
+		if bm[id].StorageFree != v {
+			t.Errorf("Expected '%f' StorageFree for ID %d, got '%f'", v, id, bm[id].StorageFree)
+		}
+	}
+
+	// Existing sizes are untouched and
+	// nothing remains to be filled.
+	if s, _ := pmm.Size(pm.Partitions[1]); s != 50 {
+		t.Errorf("Expected size 50, got %f", s)
+	}
+
+	if filled := pmm.FillMissing(pm, 1); len(filled) != 0 {
+		t.Errorf("Expected no filled partitions, got %v", filled)
+	}
+}
+
+func TestProjectStorage(t *testing.T) {
+	bm := newMockBrokerMap()
+	orig, _ := PartitionMapFromString(testGetMapString("test_topic"))
+	pmm := NewPartitionMetaMap()
+
+	pmm["test_topic"] = map[int]*PartitionMeta{
+		0: &PartitionMeta{Size: 30},
+		1: &PartitionMeta{Size: 35},
+		2: &PartitionMeta{Size: 60},
+		3: &PartitionMeta{Size: 45},
+	}
+
+	// Move p0 from 1001 to 1003 and p3 from 1002 to 1001.
+	proposed, _ := PartitionMapFromString(`{"version":1,"partitions":[
+    {"topic":"test_topic","partition":0,"replicas":[1003,1002]},
+    {"topic":"test_topic","partition":3,"replicas":[1004,1003,1001]}]}`)
+
+	projected, err := bm.ProjectStorage(orig, proposed, pmm)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	expected := map[int]float64{
+		1001: 85,
+		1002: 245,
+		1003: 270,
+		1004: 400,
+	}
+
+	for id, v := range expected {
+		if projected[id].StorageFree != v {
+			t.Errorf("Expected '%f' StorageFree for ID %d, got '%f'", v, id, projected[id].StorageFree)
+		}
+	}
+
+	// The source BrokerMap is unmodified.
+	if bm[1001].StorageFree != 100 {
+		t.Errorf("Unexpected modification of source BrokerMap")
+	}
+
+	// A broker not in the BrokerMap.
+	proposed.Partitions[0].Replicas = []int{1010, 1002}
+	if _, err := bm.ProjectStorage(orig, proposed, pmm); err == nil {
+		t.Error("Expected error for unknown broker")
+	}
+}
+
+func TestAdjustStorage(t *testing.T) {
+	bm := newMockBrokerMap()
+
+	if err := bm.AdjustStorage(1001, 50); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if err := bm.AdjustStorage(1002, -50); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if bm[1001].StorageFree != 150 || bm[1002].StorageFree != 150 {
+		t.Errorf("Expected StorageFree 150 for 1001 and 1002, got %f and %f",
+			bm[1001].StorageFree, bm[1002].StorageFree)
+	}
+
+	if err := bm.AdjustStorage(1010, 50); err == nil {
+		t.Error("Expected error for unknown broker")
+	}
+}
+
+func TestApplyPartitionMoveSubStorage(t *testing.T) {
+	pm, _ := PartitionMapFromString(testGetMapString("test_topic"))
+	pmm := NewPartitionMetaMap()
+
+	pmm["test_topic"] = map[int]*PartitionMeta{
+		0: &PartitionMeta{Size: 30},
+		1: &PartitionMeta{Size: 35},
+		2: &PartitionMeta{Size: 60},
+		3: &PartitionMeta{Size: 45},
+	}
+
+	// Removing each partition from all brokers
+	// is equivalent to a full SubStorage pass.
+	full, incremental := newMockBrokerMap(), newMockBrokerMap()
+
+	allBrokers := func(b *Broker) bool { return true }
+	if err := full.SubStorage(pm, pmm, allBrokers); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	for _, p := range pm.Partitions {
+		if err := incremental.ApplyPartitionMove(p, p.Replicas, nil, pmm); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+	}
+
+	for id, b := range full {
+		if incremental[id].StorageFree != b.StorageFree {
+			t.Errorf("[all] Expected '%f' StorageFree for ID %d, got '%f'",
+				b.StorageFree, id, incremental[id].StorageFree)
+		}
+	}
+
+	// Likewise for replaced brokers, adjusting
+	// each broker's storage individually.
+	full, incremental = newMockBrokerMap(), newMockBrokerMap()
+	full[1003].Replace, incremental[1003].Replace = true, true
+
+	replacedBrokers := func(b *Broker) bool { return b.Replace }
+	if err := full.SubStorage(pm, pmm, replacedBrokers); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	for _, p := range pm.Partitions {
+		size, _ := pmm.Size(p)
+		for _, id := range p.Replicas {
+			if incremental[id].Replace {
+				incremental.AdjustStorage(id, size)
+			}
+		}
+	}
+
+	for id, b := range full {
+		if incremental[id].StorageFree != b.StorageFree {
+			t.Errorf("[replaced] Expected '%f' StorageFree for ID %d, got '%f'",
+				b.StorageFree, id, incremental[id].StorageFree)
+		}
+	}
+}
+
+func TestApplyPartitionMove(t *testing.T) {
+	bm := newMockBrokerMap()
+	pmm := NewPartitionMetaMap()
+	pmm["test_topic"] = map[int]*PartitionMeta{0: &PartitionMeta{Size: 30}}
+
+	p := Partition{Topic: "test_topic", Partition: 0}
+
+	// Move from [1001 1002] to [1002 1003].
+	if err := bm.ApplyPartitionMove(p, []int{1001, 1002}, []int{1002, 1003}, pmm); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	expected := map[int]float64{
+		1001: 130,
+		1002: 200,
+		1003: 270,
+		1004: 400,
+	}
+
+	for id, v := range expected {
+		if bm[id].StorageFree != v {
+			t.Errorf("Expected '%f' StorageFree for ID %d, got '%f'", v, id, bm[id].StorageFree)
+		}
+	}
+
+	// Errors leave the BrokerMap unmodified.
+	if err := bm.ApplyPartitionMove(p, []int{1002}, []int{1004, 1010}, pmm); err == nil {
+		t.Error("Expected error for unknown broker")
+	}
+
+	if err := bm.ApplyPartitionMove(Partition{Topic: "none"}, []int{1002}, []int{1004}, pmm); err == nil {
+		t.Error("Expected error for missing partition metadata")
+	}
+
+	for id, v := range expected {
+		if bm[id].StorageFree != v {
+			t.Errorf("Expected '%f' StorageFree for ID %d after errors, got '%f'", v, id, bm[id].StorageFree)
+		}
+	}
+}
+
+func TestFilter(t *testing.T) {
+	bm1 := newMockBrokerMap2()
+	f := func(b *Broker) bool {
+		if b.Locality == "a" {
+			return true
+		}
+		return false
+	}
+
+	bm2 := bm1.Filter(f)
+
+	if len(bm2) != 3 {
+		t.Errorf("Expected BrokerMap len of 3, got %d", len(bm2))
+	}
+
+	for _, id := range []int{1001, 1004, 1007} {
+		if _, exist := bm2[id]; !exist {
+			t.Errorf("Expected ID %d in BrokerMap", id)
+		}
+	}
+}
+
+func TestBrokerMapFromPartitionMap(t *testing.T) {
+	zk := &Mock{}
+	bmm, _ := zk.GetAllBrokerMeta(false)
+	pm, _ := PartitionMapFromString(testGetMapString("test_topic"))
+	forceRebuild := false
 
 	brokers := BrokerMapFromPartitionMap(pm, bmm, forceRebuild)
 	expected := newMockBrokerMap()
@@ -314,6 +1106,24 @@ func TestBrokerMapFromPartitionMap(t *testing.T) {
 	}
 }
 
+func TestBrokerMapIDs(t *testing.T) {
+	bm := newMockBrokerMap()
+
+	// The mock includes the stub broker ID 0.
+	if _, exists := bm[0]; !exists {
+		t.Fatal("Expected broker ID 0 in mock BrokerMap")
+	}
+
+	expected := []int{1001, 1002, 1003, 1004}
+	if ids := bm.IDs(); !reflect.DeepEqual(ids, expected) {
+		t.Errorf("Expected %v, got %v", expected, ids)
+	}
+
+	if ids := (BrokerMap{}).IDs(); len(ids) != 0 {
+		t.Errorf("Expected no IDs, got %v", ids)
+	}
+}
+
 func TestBrokerMapCopy(t *testing.T) {
 	bm1 := newMockBrokerMap()
 	bm2 := bm1.Copy()
@@ -338,6 +1148,89 @@ func TestBrokerMapCopy(t *testing.T) {
 	}
 }
 
+func TestBrokerMapWithMetrics(t *testing.T) {
+	bm := newMockBrokerMap()
+	orig := newMockBrokerMap()
+
+	metrics := BrokerMetricsMap{
+		1001: &BrokerMetrics{StorageFree: 1000.00, StorageTotal: 2000.00},
+		1002: &BrokerMetrics{StorageFree: 0.00},
+		1003: &BrokerMetrics{StorageFree: 3000.00},
+		// Not in the BrokerMap.
+		1010: &BrokerMetrics{StorageFree: 5000.00},
+	}
+
+	bm2 := bm.WithMetrics(metrics)
+
+	// The original map is unmodified.
+	if !reflect.DeepEqual(bm, orig) {
+		t.Errorf("Expected BrokerMap %v to be unmodified, got %v", orig, bm)
+	}
+
+	expected := map[int]float64{0: 0.00, 1001: 1000.00, 1002: 0.00, 1003: 3000.00, 1004: 0.00}
+
+	if len(bm2) != len(expected) {
+		t.Fatalf("Expected %d brokers, got %d", len(expected), len(bm2))
+	}
+
+	for id, free := range expected {
+		b := bm2[id]
+		if b.StorageFree != free {
+			t.Errorf("[broker %d] Expected StorageFree %f, got %f", id, free, b.StorageFree)
+		}
+
+		// Other fields are copied and brokers
+		// without metrics aren't marked.
+		if b.Locality != orig[id].Locality || b.Used != orig[id].Used || b.Replace != orig[id].Replace || b.Missing {
+			t.Errorf("[broker %d] Unexpected field change: %+v", id, b)
+		}
+
+		if b == bm[id] {
+			t.Errorf("[broker %d] Expected a copy", id)
+		}
+	}
+}
+
+func TestBrokerMapDiff(t *testing.T) {
+	bm1 := newMockBrokerMap()
+	bm2 := newMockBrokerMap()
+
+	// Addition.
+	bm2[1005] = &Broker{ID: 1005, StorageFree: 500.00}
+	// Removal.
+	delete(bm2, 1002)
+	// Storage deltas.
+	bm2[1001].StorageFree = 250.00
+	bm2[1003].StorageFree = 50.00
+	// The reserved ID is skipped.
+	bm2[0].StorageFree = 1000.00
+
+	added, removed, changed := bm1.Diff(bm2)
+
+	if !reflect.DeepEqual(added, []int{1005}) {
+		t.Errorf("Expected added %v, got %v", []int{1005}, added)
+	}
+
+	if !reflect.DeepEqual(removed, []int{1002}) {
+		t.Errorf("Expected removed %v, got %v", []int{1002}, removed)
+	}
+
+	expected := map[int][2]float64{
+		1001: [2]float64{bm1[1001].StorageFree, 250.00},
+		1003: [2]float64{bm1[1003].StorageFree, 50.00},
+	}
+
+	if !reflect.DeepEqual(changed, expected) {
+		t.Errorf("Expected storage changes %v, got %v", expected, changed)
+	}
+
+	// No changes.
+	added, removed, changed = bm1.Diff(bm1.Copy())
+	if len(added) != 0 || len(removed) != 0 || len(changed) != 0 {
+		t.Errorf("Expected empty diff, got %v, %v, %v", added, removed, changed)
+	}
+}
+
 func TestBrokerCopy(t *testing.T) {
 	bm := newMockBrokerMap()
 	b1 := bm[1001]
@@ -361,6 +1254,155 @@ func TestBrokerCopy(t *testing.T) {
 	}
 }
 
+func TestBrokerMapSnapshotRestore(t *testing.T) {
+	bm := newMockBrokerMap()
+	bm[1001].LogDirs = map[string]float64{"/data/kafka1": 100.00}
+	bl := bm.List()
+
+	snapshot := bm.Snapshot()
+
+	// Speculative changes.
+	bm[1001].Used = 10
+	bm[1001].StorageFree = 0
+	bm[1001].LogDirs["/data/kafka1"] = 0
+	bm[1002].Replace = true
+	bm[1003].Missing = true
+	bm[1004].New = true
+	bm[1005] = &Broker{ID: 1005, New: true}
+	delete(bm, 0)
+
+	for i := 0; i < 2; i++ {
+		bm.Restore(snapshot)
+
+		expected := newMockBrokerMap()
+
+		if len(bm) != len(expected) {
+			t.Errorf("Expected BrokerMap len of %d, got %d", len(expected), len(bm))
+		}
+
+		for id, b := range expected {
+			got, exists := bm[id]
+			if !exists {
+				t.Errorf("Expected ID %d in BrokerMap", id)
+				continue
+			}
+
+			switch {
+			case got.ID != b.ID:
+				t.Errorf("[%d] ID field mismatch", id)
+			case got.Locality != b.Locality:
+				t.Errorf("[%d] Locality field mismatch", id)
+			case got.Used != b.Used:
+				t.Errorf("[%d] Used field mismatch", id)
+			case got.StorageFree != b.StorageFree:
+				t.Errorf("[%d] StorageFree field mismatch", id)
+			case got.Replace != b.Replace:
+				t.Errorf("[%d] Replace field mismatch", id)
+			case got.Missing != b.Missing:
+				t.Errorf("[%d] Missing field mismatch", id)
+			case got.New != b.New:
+				t.Errorf("[%d] New field mismatch", id)
+			}
+		}
+
+		if f := bm[1001].LogDirs["/data/kafka1"]; f != 100.00 {
+			t.Errorf("Expected log dir storage free of 100.00, got %.2f", f)
+		}
+
+		// Modify again for the next restore.
+		bm[1001].Used = 10
+		bm[1001].LogDirs["/data/kafka1"] = 0
+	}
+
+	// References held prior to the snapshot
+	// should reflect the restored state.
+	bm.Restore(snapshot)
+	for _, b := range bl {
+		if b.ID == 1001 && b.Used != 3 {
+			t.Errorf("Expected Used value of 3, got %d", b.Used)
+		}
+	}
+}
+
+func TestBrokerMapMerge(t *testing.T) {
+	newZKMap := func() BrokerMap {
+		return BrokerMap{
+			0:    &Broker{ID: 0, Replace: true},
+			1001: &Broker{ID: 1001, Locality: "a", Used: 2},
+			1002: &Broker{ID: 1002, Locality: "b", Used: 3, StorageFree: 100.00},
+		}
+	}
+
+	metrics := BrokerMap{
+		0:    &Broker{ID: 0, StorageFree: 999.00},
+		1001: &Broker{ID: 1001, StorageFree: 200.00, LogDirs: map[string]float64{"/data": 200.00}},
+		1002: &Broker{ID: 1002, Locality: "c", StorageFree: 300.00, Missing: true},
+		1003: &Broker{ID: 1003, Locality: "a", StorageFree: 400.00},
+	}
+
+	// Fill gaps only.
+	bm := newZKMap()
+	bm.Merge(metrics, false)
+
+	expected := BrokerMap{
+		0:    &Broker{ID: 0, Replace: true},
+		1001: &Broker{ID: 1001, Locality: "a", Used: 2, StorageFree: 200.00, LogDirs: map[string]float64{"/data": 200.00}},
+		1002: &Broker{ID: 1002, Locality: "b", Used: 3, StorageFree: 100.00, Missing: true},
+		1003: &Broker{ID: 1003, Locality: "a", StorageFree: 400.00},
+	}
+
+	if !reflect.DeepEqual(bm, expected) {
+		t.Errorf("[overwrite=false] Expected %v, got %v", expected, bm)
+	}
+
+	// Overwrite.
+	bm = newZKMap()
+	bm.Merge(metrics, true)
+
+	expected[1002].Locality = "c"
+	expected[1002].StorageFree = 300.00
+
+	if !reflect.DeepEqual(bm, expected) {
+		t.Errorf("[overwrite=true] Expected %v, got %v", expected, bm)
+	}
+
+	// Merged values are copies.
+	bm[1001].LogDirs["/data"] = 0
+	if metrics[1001].LogDirs["/data"] != 200.00 {
+		t.Error("Unexpected modification of source BrokerMap log dirs")
+	}
+
+	bm[1003].StorageFree = 0
+	if metrics[1003].StorageFree != 400.00 {
+		t.Error("Unexpected modification of source BrokerMap broker")
+	}
+}
+
+func TestBestLogDir(t *testing.T) {
+	b := &Broker{ID: 1001}
+
+	if d := b.BestLogDir(); d != "" {
+		t.Errorf("Expected empty log dir, got %s", d)
+	}
+
+	b.LogDirs = map[string]float64{
+		"/data/kafka1": 100.00,
+		"/data/kafka2": 300.00,
+		"/data/kafka3": 300.00,
+	}
+
+	// Ties should resolve by path.
+	if d := b.BestLogDir(); d != "/data/kafka2" {
+		t.Errorf("Expected log dir /data/kafka2, got %s", d)
+	}
+
+	b.LogDirs["/data/kafka1"] = 500.00
+
+	if d := b.BestLogDir(); d != "/data/kafka1" {
+		t.Errorf("Expected log dir /data/kafka1, got %s", d)
+	}
+}
+
 func newMockBrokerMap() BrokerMap {
 	return BrokerMap{
 		0:    &Broker{ID: 0, Replace: true},
@@ -383,3 +1425,569 @@ func newMockBrokerMap2() BrokerMap {
 		1007: &Broker{ID: 1007, Locality: "a", Used: 3, Replace: false, StorageFree: 400.00},
 	}
 }
+
+func TestSetAntiAffinityGroups(t *testing.T) {
+	zk := &Mock{}
+	bm, _ := zk.GetAllBrokerMeta(false)
+
+	tags := map[int]map[string]string{
+		1001: map[string]string{"power-domain": "pd1", "switch": "sw1"},
+		1002: map[string]string{"power-domain": "pd1"},
+		1003: map[string]string{"team": "kafka"},
+	}
+
+	bm.SetAntiAffinityGroups(tags, []string{"power-domain", "switch"})
+
+	expected := map[int][]string{
+		1001: []string{"power-domain:pd1", "switch:sw1"},
+		1002: []string{"power-domain:pd1"},
+		1003: nil,
+		1004: nil,
+	}
+
+	for id, groups := range expected {
+		if !stringsEqual(bm[id].AntiAffinityGroups, groups) {
+			t.Errorf("[%d] Expected groups %v, got %v", id, groups, bm[id].AntiAffinityGroups)
+		}
+	}
+
+	// Groups are carried into the BrokerMap.
+	pm, _ := PartitionMapFromString(testGetMapString("test_topic"))
+	brokers := BrokerMapFromPartitionMap(pm, bm, false)
+
+	if !stringsEqual(brokers[1001].AntiAffinityGroups, expected[1001]) {
+		t.Errorf("Expected groups %v, got %v", expected[1001], brokers[1001].AntiAffinityGroups)
+	}
+
+	c := brokers.Copy()
+	c[1001].AntiAffinityGroups[0] = "modified"
+	if brokers[1001].AntiAffinityGroups[0] != "power-domain:pd1" {
+		t.Error("Unexpected modification of source BrokerMap groups")
+	}
+}
+
+func TestFilterByTag(t *testing.T) {
+	zk := &Mock{}
+	bm, _ := zk.GetAllBrokerMeta(false)
+
+	bm.SetTags(map[int]map[string]string{
+		1001: map[string]string{"pool": "a", "team": "kafka"},
+		1002: map[string]string{"pool": "b"},
+		1003: map[string]string{"pool": "a"},
+		1004: map[string]string{"team": "kafka"},
+		// Not in the BrokerMetaMap.
+		1010: map[string]string{"pool": "a"},
+	})
+
+	tests := map[[2]string][]int{
+		// Key only.
+		[2]string{"pool", ""}: []int{1001, 1002, 1003},
+		[2]string{"team", ""}: []int{1001, 1004},
+		// Key and value.
+		[2]string{"pool", "a"}:   []int{1001, 1003},
+		[2]string{"pool", "b"}:   []int{1002},
+		[2]string{"pool", "c"}:   []int{},
+		[2]string{"missing", ""}: []int{},
+	}
+
+	for tag, expected := range tests {
+		filtered := bm.FilterByTag(tag[0], tag[1])
+
+		var ids []int
+		for id := range filtered {
+			ids = append(ids, id)
+		}
+
+		sort.Ints(ids)
+
+		if len(ids) != len(expected) {
+			t.Errorf("[%s:%s] Expected brokers %v, got %v", tag[0], tag[1], expected, ids)
+			continue
+		}
+
+		for i := range ids {
+			if ids[i] != expected[i] {
+				t.Errorf("[%s:%s] Expected brokers %v, got %v", tag[0], tag[1], expected, ids)
+				break
+			}
+		}
+	}
+}
+
+// stubTagResolver is a TagResolver
+// backed by a static tag map.
+type stubTagResolver map[int]map[string]string
+
+func (s stubTagResolver) BrokerTags(id int) (map[string]string, error) {
+	return s[id], nil
+}
+
+func TestBrokerMapFilterByTag(t *testing.T) {
+	bm := newMockBrokerMap2()
+
+	r := stubTagResolver{
+		1001: map[string]string{"pool": "streaming", "team": "kafka"},
+		1002: map[string]string{"pool": "batch"},
+		1003: map[string]string{"pool": "streaming"},
+		1004: map[string]string{"team": "kafka"},
+		1005: map[string]string{"pool": "streaming", "team": "data"},
+	}
+
+	tests := map[int]struct {
+		selectors []string
+		expected  []int
+	}{
+		0: {[]string{"pool:streaming"}, []int{1001, 1003, 1005}},
+		1: {[]string{"pool:streaming", "team:kafka"}, []int{1001}},
+		// Key only.
+		2: {[]string{"team"}, []int{1001, 1004, 1005}},
+		3: {[]string{"pool", "team:data"}, []int{1005}},
+		4: {[]string{"pool:missing"}, []int{}},
+		// No selectors; ID 0 is excluded.
+		5: {nil, []int{1001, 1002, 1003, 1004, 1005, 1006, 1007}},
+		// An empty value matches any value.
+		6: {[]string{"team:"}, []int{1001, 1004, 1005}},
+	}
+
+	for i, test := range tests {
+		filtered, err := bm.FilterByTag(test.selectors, r)
+		if err != nil {
+			t.Errorf("[test %d] Unexpected error: %s", i, err)
+			continue
+		}
+
+		ids := []int{}
+		for id := range filtered {
+			ids = append(ids, id)
+		}
+
+		sort.Ints(ids)
+
+		if !reflect.DeepEqual(ids, test.expected) {
+			t.Errorf("[test %d] Expected brokers %v, got %v", i, test.expected, ids)
+		}
+	}
+}
+
+func TestBrokerMapFilterByTagErrors(t *testing.T) {
+	bm := newMockBrokerMap2()
+
+	// Invalid selector.
+	if _, err := bm.FilterByTag([]string{":streaming"}, stubTagResolver{}); err == nil {
+		t.Error("Expected non-nil error")
+	}
+
+	// Resolver error.
+	r := TagResolverFunc(func(id int) (map[string]string, error) {
+		return nil, fmt.Errorf("lookup failed")
+	})
+
+	if _, err := bm.FilterByTag([]string{"pool"}, r); err == nil {
+		t.Error("Expected non-nil error")
+	}
+}
+
+func TestBrokerMetaUnmarshalJSON(t *testing.T) {
+	// Broker znode samples by Kafka version.
+	tests := map[string]string{
+		// Kafka 0.8.x; schema v1.
+		"0.8": `{"jmx_port":9999,"timestamp":"1441145214516","host":"10.0.1.10","version":1,"port":9092}`,
+		// Kafka 0.9.x; schema v2.
+		"0.9": `{"jmx_port":9999,"timestamp":"1456345435872","endpoints":["PLAINTEXT://10.0.1.10:9092"],"host":"10.0.1.10","version":2,"port":9092}`,
+		// Kafka 0.10.0/0.10.1; schema v3.
+		"0.10": `{"jmx_port":9999,"timestamp":"1475165935045","endpoints":["PLAINTEXT://10.0.1.10:9092"],"rack":"us-east-1a","host":"10.0.1.10","version":3,"port":9092}`,
+		// Kafka 0.10.2 through 2.6; schema v4.
+		"1.1": `{"listener_security_protocol_map":{"PLAINTEXT":"PLAINTEXT"},"endpoints":["PLAINTEXT://10.0.1.10:9092"],"rack":"us-east-1a","jmx_port":9999,"host":"10.0.1.10","timestamp":"1544357419406","port":9092,"version":4}`,
+		// Kafka 2.x without a PLAINTEXT listener;
+		// host and port are null/-1.
+		"2.4-ssl": `{"listener_security_protocol_map":{"SSL":"SSL"},"endpoints":["SSL://10.0.1.10:9093"],"rack":"us-east-1a","jmx_port":9999,"host":null,"timestamp":"1582140364823","port":-1,"version":4}`,
+		// Kafka 2.7+; schema v5.
+		"2.7": `{"features":{},"listener_security_protocol_map":{"INTERNAL":"PLAINTEXT","EXTERNAL":"SSL"},"endpoints":["INTERNAL://10.0.1.10:9092","EXTERNAL://kafka-1.example.com:9093"],"rack":"us-east-1a","jmx_port":9999,"host":"10.0.1.10","timestamp":"1610051023934","port":9092,"version":5}`,
+		// No version field.
+		"unversioned": `{"endpoints":["PLAINTEXT://10.0.1.10:9092"],"rack":"us-east-1a","host":"10.0.1.10","port":9092}`,
+		// IPv6 endpoint, missing host and port.
+		"ipv6": `{"listener_security_protocol_map":{"PLAINTEXT":"PLAINTEXT"},"endpoints":["PLAINTEXT://[fd00::10]:9092"],"version":4}`,
+	}
+
+	type expected struct {
+		host    string
+		port    int
+		rack    string
+		version int
+	}
+
+	expect := map[string]expected{
+		"0.8":         {"10.0.1.10", 9092, "", 1},
+		"0.9":         {"10.0.1.10", 9092, "", 2},
+		"0.10":        {"10.0.1.10", 9092, "us-east-1a", 3},
+		"1.1":         {"10.0.1.10", 9092, "us-east-1a", 4},
+		"2.4-ssl":     {"10.0.1.10", 9093, "us-east-1a", 4},
+		"2.7":         {"10.0.1.10", 9092, "us-east-1a", 5},
+		"unversioned": {"10.0.1.10", 9092, "us-east-1a", 3},
+		"ipv6":        {"fd00::10", 9092, "", 4},
+	}
+
+	for name, data := range tests {
+		bm := &BrokerMeta{}
+		if err := json.Unmarshal([]byte(data), bm); err != nil {
+			t.Errorf("[%s] Unexpected error: %s", name, err)
+			continue
+		}
+
+		e := expect[name]
+		got := expected{bm.Host, bm.Port, bm.Rack, bm.Version}
+
+		if got != e {
+			t.Errorf("[%s] Expected %+v, got %+v", name, e, got)
+		}
+
+		// Round trip.
+		out, err := json.Marshal(bm)
+		if err != nil {
+			t.Errorf("[%s] Unexpected error: %s", name, err)
+			continue
+		}
+
+		bm2 := &BrokerMeta{}
+		if err := json.Unmarshal(out, bm2); err != nil {
+			t.Errorf("[%s] Unexpected error: %s", name, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(bm, bm2) {
+			t.Errorf("[%s] Expected round trip %+v, got %+v", name, bm, bm2)
+		}
+	}
+
+	// Malformed data.
+	if err := json.Unmarshal([]byte(`{"port":"9092"}`), &BrokerMeta{}); err == nil {
+		t.Error("Expected error")
+	}
+}
+
+func TestBrokerMetricsMapAggregate(t *testing.T) {
+	m := BrokerMetricsMap{
+		0:    &BrokerMetrics{StorageFree: 5000.00},
+		1001: &BrokerMetrics{StorageFree: 1000.00},
+		1002: &BrokerMetrics{StorageFree: 2500.00},
+		1003: &BrokerMetrics{StorageFree: 500.00},
+	}
+
+	free, count := m.Aggregate()
+
+	if free != 4000.00 {
+		t.Errorf("Expected total free 4000.00, got %.2f", free)
+	}
+
+	if count != 3 {
+		t.Errorf("Expected count 3, got %d", count)
+	}
+
+	free, count = BrokerMetricsMap{}.Aggregate()
+	if free != 0 || count != 0 {
+		t.Errorf("Expected 0.00 and 0 for an empty map, got %.2f and %d", free, count)
+	}
+}
+
+func TestParseEndpoint(t *testing.T) {
+	tests := map[string]bool{
+		"PLAINTEXT://10.0.1.10:9092": true,
+		"SSL://kafka-1:9093":         true,
+		"PLAINTEXT://[::1]:9092":     true,
+		"10.0.1.10:9092":             false,
+		"PLAINTEXT://10.0.1.10":      false,
+		"PLAINTEXT://10.0.1.10:port": false,
+	}
+
+	for e, valid := range tests {
+		_, _, err := parseEndpoint(e)
+		if valid && err != nil {
+			t.Errorf("[%s] Unexpected error: %s", e, err)
+		}
+		if !valid && err == nil {
+			t.Errorf("[%s] Expected error", e)
+		}
+	}
+}
+
+func TestBrokerMetaEqual(t *testing.T) {
+	base := func() *BrokerMeta {
+		return &BrokerMeta{
+			Rack:      "a",
+			Host:      "10.0.1.1",
+			Port:      9092,
+			JMXPort:   9999,
+			Version:   4,
+			Endpoints: []string{"PLAINTEXT://10.0.1.1:9092", "SSL://10.0.1.1:9093"},
+			ListenerSecurityProtocolMap: map[string]string{
+				"PLAINTEXT": "PLAINTEXT",
+				"SSL":       "SSL",
+			},
+			Timestamp:   "1544357419406",
+			StorageFree: 1000.00,
+		}
+	}
+
+	tests := map[int]struct {
+		mutate   func(*BrokerMeta)
+		expected bool
+	}{
+		// Equal.
+		0: {func(b *BrokerMeta) {}, true},
+		// Transient fields are ignored.
+		1: {func(b *BrokerMeta) {
+			b.StorageFree = 2000.00
+			b.StorageTotal = 4000.00
+			b.MetricsIncomplete = true
+			b.LogDirs = map[string]float64{"/data": 2000.00}
+			b.Tags = map[string]string{"k": "v"}
+			b.Timestamp = "1544357419999"
+		}, true},
+		// Listener maps are compared order-independently.
+		2: {func(b *BrokerMeta) {
+			b.ListenerSecurityProtocolMap = map[string]string{
+				"SSL":       "SSL",
+				"PLAINTEXT": "PLAINTEXT",
+			}
+		}, true},
+		// Field differs.
+		3:  {func(b *BrokerMeta) { b.Rack = "b" }, false},
+		4:  {func(b *BrokerMeta) { b.Host = "10.0.1.2" }, false},
+		5:  {func(b *BrokerMeta) { b.Port = 9093 }, false},
+		6:  {func(b *BrokerMeta) { b.JMXPort = 0 }, false},
+		7:  {func(b *BrokerMeta) { b.Version = 5 }, false},
+		8:  {func(b *BrokerMeta) { b.Endpoints = b.Endpoints[:1] }, false},
+		9:  {func(b *BrokerMeta) { b.ListenerSecurityProtocolMap["SSL"] = "SASL_SSL" }, false},
+		10: {func(b *BrokerMeta) { delete(b.ListenerSecurityProtocolMap, "SSL") }, false},
+		// Endpoint order is significant.
+		11: {func(b *BrokerMeta) { b.Endpoints[0], b.Endpoints[1] = b.Endpoints[1], b.Endpoints[0] }, false},
+	}
+
+	for i, test := range tests {
+		other := base()
+		test.mutate(other)
+
+		if eq := base().Equal(other); eq != test.expected {
+			t.Errorf("[test %d] Expected Equal %t, got %t", i, test.expected, eq)
+		}
+
+		if eq := other.Equal(base()); eq != test.expected {
+			t.Errorf("[test %d] Expected reversed Equal %t, got %t", i, test.expected, eq)
+		}
+	}
+
+	// Nil receivers.
+	var nilMeta *BrokerMeta
+
+	if !nilMeta.Equal(nil) {
+		t.Error("Expected nil BrokerMeta to be equal")
+	}
+
+	if nilMeta.Equal(base()) || base().Equal(nil) {
+		t.Error("Expected nil and non-nil BrokerMeta to be unequal")
+	}
+}
+
+func TestEndpointFor(t *testing.T) {
+	b := &BrokerMeta{
+		ListenerSecurityProtocolMap: map[string]string{
+			"INTERNAL":  "PLAINTEXT",
+			"EXTERNAL":  "SSL",
+			"REPLICAS6": "SASL_SSL",
+		},
+		Endpoints: []string{
+			"INTERNAL://10.0.1.10:9092",
+			"EXTERNAL://kafka-1.example.com:9093",
+			"REPLICAS6://[2001:db8::10]:9094",
+		},
+	}
+
+	type expected struct {
+		host string
+		port int
+	}
+
+	tests := map[string]expected{
+		// Listener names.
+		"INTERNAL":  {"10.0.1.10", 9092},
+		"external":  {"kafka-1.example.com", 9093},
+		"REPLICAS6": {"2001:db8::10", 9094},
+		// Security protocols.
+		"PLAINTEXT": {"10.0.1.10", 9092},
+		"SSL":       {"kafka-1.example.com", 9093},
+		"SASL_SSL":  {"2001:db8::10", 9094},
+	}
+
+	for listener, exp := range tests {
+		host, port, err := b.EndpointFor(listener)
+		if err != nil {
+			t.Errorf("[%s] Unexpected error: %s", listener, err)
+			continue
+		}
+
+		if host != exp.host || port != exp.port {
+			t.Errorf("[%s] Expected %s:%d, got %s:%d", listener, exp.host, exp.port, host, port)
+		}
+	}
+
+	// Missing listeners.
+	for _, listener := range []string{"SASL_PLAINTEXT", "REPLICATION", ""} {
+		if _, _, err := b.EndpointFor(listener); err == nil {
+			t.Errorf("[%s] Expected error", listener)
+		}
+	}
+
+	// Pre-v4 brokers have no security protocol map;
+	// the listener name is the security protocol.
+	b = &BrokerMeta{Endpoints: []string{"PLAINTEXT://[::1]:9092"}}
+
+	host, port, err := b.EndpointFor("PLAINTEXT")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if host != "::1" || port != 9092 {
+		t.Errorf("Expected ::1:9092, got %s:%d", host, port)
+	}
+}
+
+// testPopulatedBroker returns a Broker with every field set to a non-zero
+// value. It fails the test for field kinds it doesn't know how to populate.
+func testPopulatedBroker(t *testing.T) Broker {
+	var b Broker
+	v := reflect.ValueOf(&b).Elem()
+
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Field(i)
+		switch f.Kind() {
+		case reflect.Int:
+			f.SetInt(int64(1000 + i))
+		case reflect.Float64:
+			f.SetFloat(float64(i) + 0.5)
+		case reflect.String:
+			f.SetString(fmt.Sprintf("value-%d", i))
+		case reflect.Bool:
+			f.SetBool(true)
+		case reflect.Map:
+			m := reflect.MakeMap(f.Type())
+			m.SetMapIndex(reflect.Zero(f.Type().Key()), reflect.Zero(f.Type().Elem()))
+			f.Set(m)
+		case reflect.Slice:
+			f.Set(reflect.MakeSlice(f.Type(), 1, 1))
+		default:
+			t.Fatalf("Unhandled Broker field %s (%s); update the Broker copy methods and this test",
+				v.Type().Field(i).Name, f.Kind())
+		}
+	}
+
+	return b
+}
+
+func TestBrokerCopyAllFields(t *testing.T) {
+	src := testPopulatedBroker(t)
+
+	bm := BrokerMap{1001: &src}
+
+	// A stale destination broker, with reference
+	// fields to be reused.
+	dst := BrokerMap{
+		1001: &Broker{LogDirs: map[string]float64{"/stale": 1}, AntiAffinityGroups: []string{"a", "b"}},
+		1002: &Broker{ID: 1002},
+	}
+	bm.CopyInto(dst)
+
+	copies := map[string]Broker{
+		"Broker.Copy":        src.Copy(),
+		"BrokerMap.Copy":     *bm.Copy()[1001],
+		"BrokerMap.CopyInto": *dst[1001],
+		"BrokerMap.Snapshot": *bm.Snapshot()[1001],
+	}
+
+	if _, exists := dst[1002]; exists {
+		t.Error("Expected broker 1002 removed by CopyInto")
+	}
+
+	sv := reflect.ValueOf(src)
+
+	for name, c := range copies {
+		cv := reflect.ValueOf(c)
+
+		for i := 0; i < sv.NumField(); i++ {
+			field := sv.Type().Field(i).Name
+			f1, f2 := sv.Field(i), cv.Field(i)
+
+			if !reflect.DeepEqual(f1.Interface(), f2.Interface()) {
+				t.Errorf("[%s] Field %s not copied: expected %v, got %v", name, field, f1, f2)
+			}
+
+			// Reference types must not be shared.
+			switch f1.Kind() {
+			case reflect.Map, reflect.Slice:
+				if f1.Pointer() == f2.Pointer() {
+					t.Errorf("[%s] Field %s shares a reference with the original", name, field)
+				}
+			}
+		}
+	}
+}
+
+func TestBrokerMapCopyIntoSelf(t *testing.T) {
+	bm := newMockBrokerMap()
+	bm[1001].LogDirs = map[string]float64{"/data": 100}
+
+	bm.CopyInto(bm)
+
+	if bm[1001].LogDirs["/data"] != 100 {
+		t.Errorf("Expected log dirs retained, got %v", bm[1001].LogDirs)
+	}
+}
+
+func TestBrokerMetaRegisteredAt(t *testing.T) {
+	tests := map[string]time.Time{
+		"0":             time.Unix(0, 0),
+		"1544357419406": time.Unix(1544357419, 406*int64(time.Millisecond)),
+		"1700000000000": time.Unix(1700000000, 0),
+	}
+
+	for ts, expected := range tests {
+		b := &BrokerMeta{Timestamp: ts}
+
+		got, err := b.RegisteredAt()
+		if err != nil {
+			t.Errorf("[%s] Unexpected error: %s", ts, err)
+		}
+
+		if !got.Equal(expected) {
+			t.Errorf("[%s] Expected %s, got %s", ts, expected.UTC(), got.UTC())
+		}
+	}
+
+	// Empty and malformed timestamps.
+	for _, ts := range []string{"", "abc", "1544357419.406", "-1"} {
+		b := &BrokerMeta{Timestamp: ts}
+		if _, err := b.RegisteredAt(); err == nil {
+			t.Errorf("[%s] Expected error", ts)
+		}
+	}
+}
+
+func TestBrokerMetaStaleSince(t *testing.T) {
+	ms := func(t time.Time) string {
+		return strconv.FormatInt(t.UnixNano()/int64(time.Millisecond), 10)
+	}
+
+	hourAgo := &BrokerMeta{Timestamp: ms(time.Now().Add(-time.Hour))}
+
+	if !hourAgo.StaleSince(time.Minute) {
+		t.Error("Expected registration stale since 1m")
+	}
+
+	if hourAgo.StaleSince(24 * time.Hour) {
+		t.Error("Expected registration not stale since 24h")
+	}
+
+	// Malformed timestamps are never stale.
+	if (&BrokerMeta{Timestamp: "abc"}).StaleSince(0) {
+		t.Error("Expected malformed timestamp not stale")
+	}
+}
@@ -2,6 +2,9 @@ package kafkazk
 
 import (
 	"errors"
+	"fmt"
+	"sort"
+	"strings"
 )
 
 var (
@@ -9,20 +12,159 @@ var (
 	ErrNoBrokers = errors.New("No additional brokers that meet Constraints")
 	// ErrInvalidSelectionMethod error.
 	ErrInvalidSelectionMethod = errors.New("Invalid selection method")
+	// ErrInvalidControllerPolicy error.
+	ErrInvalidControllerPolicy = errors.New("Invalid controller policy")
+	// ErrInvalidReplication error.
+	ErrInvalidReplication = errors.New("Replication factor must be greater than 0")
+	// ErrInsufficientBrokers error.
+	ErrInsufficientBrokers = errors.New("Insufficient brokers for the replication factor")
+	// ErrRackDiversity error.
+	ErrRackDiversity = errors.New("No brokers available in an unused locality")
+	// ErrStorageFloor error.
+	ErrStorageFloor = errors.New("No brokers with storage free above the floor")
+	// ErrInvalidStrategy error.
+	ErrInvalidStrategy = errors.New("Invalid strategy")
+	// ErrTopicAntiAffinity error.
+	ErrTopicAntiAffinity = errors.New("No brokers without replicas of anti-affinity topics")
 )
 
 // Constraints holds a map of
-// IDs and locality key-values.
+// IDs, locality key-values and
+// anti-affinity groups.
 type Constraints struct {
 	requestSize float64
 	locality    map[string]bool
+	groups      map[string]bool
 	id          map[int]bool
+	// rackWeighted orders candidates by
+	// capacity weighted rack load.
+	rackWeighted bool
+	// minStorageFree is the storage free floor
+	// a candidate must remain at or above after
+	// accepting the requestSize.
+	minStorageFree float64
+	// controller is the controller broker ID and
+	// controllerPolicy determines whether it's
+	// deprioritized or excluded as a candidate.
+	controller       int
+	controllerPolicy string
+	// topicCounts, if non-nil, holds the number of
+	// replicas of the topic being placed held by each
+	// broker; among equally scored candidates, those
+	// holding fewer are preferred.
+	topicCounts map[int]int
+	// maxPartitions, if non-zero, is the number of
+	// replicas at which a broker is no longer eligible,
+	// according to the counts in partitionCounts.
+	maxPartitions   int
+	partitionCounts map[int]int
+	// score, if non-nil, ranks candidates for
+	// the partition being placed.
+	score     ScoreFunc
+	partition *Partition
+	// topics, if non-nil, tracks the topics held by
+	// each broker for applying topic anti-affinity
+	// to placements of the topic.
+	topics *topicPlacements
+	topic  string
+}
+
+// ScoreFunc scores a candidate broker for placement of a partition. Lower
+// scores are preferred; candidates with equal scores keep the placement
+// strategy ordering.
+// Constraints (such as locality and storage floors) are applied regardless
+// of the score, as are rack weighting and controller deprioritization. Topic
+// spread orders candidates with equal scores.
+type ScoreFunc func(*Broker, *Partition) float64
+
+// ScoreByCount is a ScoreFunc preferring brokers holding the fewest
+// replicas. It's the equivalent of the "count" strategy ordering; brokers
+// with equal counts keep their pseudo-random shuffle order.
+func ScoreByCount(b *Broker, _ *Partition) float64 {
+	return float64(b.Used)
+}
+
+// ScoreByStorage is a ScoreFunc preferring brokers with the most storage
+// free. It's the equivalent of the "storage" strategy ordering.
+func ScoreByStorage(b *Broker, _ *Partition) float64 {
+	return -b.StorageFree
+}
+
+// DefaultScoreFunc returns the ScoreFunc equivalent of the ordering used
+// by the placement strategy. It's used where no ScoreFunc is provided.
+func DefaultScoreFunc(strategy string) (ScoreFunc, error) {
+	switch strategy {
+	case "count":
+		return ScoreByCount, nil
+	case "storage":
+		return ScoreByStorage, nil
+	}
+
+	return nil, ErrInvalidSelectionMethod
+}
+
+// ScoreByMostUsed is a ScoreFunc preferring brokers
+// holding the most replicas. It implements Compact.
+func ScoreByMostUsed(b *Broker, _ *Partition) float64 {
+	return -float64(b.Used)
+}
+
+// Strategy determines how replicas are spread among brokers. A Strategy
+// only changes the ranking of candidates; all constraints are applied
+// regardless. This is distinct from the RebuildParams.Strategy placement
+// strategy ("count" or "storage"), which a Strategy is applied on top of.
+type Strategy int
+
+const (
+	// Balanced spreads replicas among as many brokers as
+	// possible, using the placement strategy ordering.
+	Balanced Strategy = iota
+	// Compact packs replicas onto as few brokers as
+	// possible, preferring those already holding the
+	// most replicas. This eases decommissions.
+	Compact
+)
+
+// ParseStrategy returns the Strategy named by s,
+// either "balanced" or "compact".
+func ParseStrategy(s string) (Strategy, error) {
+	switch s {
+	case "balanced":
+		return Balanced, nil
+	case "compact":
+		return Compact, nil
+	}
+
+	return Balanced, ErrInvalidStrategy
+}
+
+func (s Strategy) String() string {
+	switch s {
+	case Balanced:
+		return "balanced"
+	case Compact:
+		return "compact"
+	}
+
+	return "unknown"
+}
+
+// ScoreFunc returns the ScoreFunc implementing the Strategy. A nil
+// ScoreFunc is returned for Balanced, which uses the placement strategy
+// ordering.
+func (s Strategy) ScoreFunc() ScoreFunc {
+	if s == Compact {
+		return ScoreByMostUsed
+	}
+
+	return nil
 }
 
 // NewConstraints returns an empty *Constraints.
 func NewConstraints() *Constraints {
 	return &Constraints{
 		locality: make(map[string]bool),
+		groups:   make(map[string]bool),
 		id:       make(map[int]bool),
 	}
 }
@@ -32,6 +174,12 @@ func NewConstraints() *Constraints {
 // pseudo-random number generation) and returns the
 // most suitable broker.
 func (b BrokerList) BestCandidate(c *Constraints, by string, p int64) (*Broker, error) {
+	return b.bestCandidate(c, by, p, nil)
+}
+
+// bestCandidate implements BestCandidate. If a non-nil *PlacementStats
+// is provided, the selection is recorded.
+func (b BrokerList) bestCandidate(c *Constraints, by string, p int64, s *PlacementStats) (*Broker, error) {
 	// Sort type based on the
 	// desired placement criteria.
 	switch by {
@@ -45,7 +193,45 @@ func (b BrokerList) BestCandidate(c *Constraints, by string, p int64) (*Broker,
 		return nil, ErrInvalidSelectionMethod
 	}
 
+	// Prefer brokers holding the fewest replicas of the
+	// topic where candidates are otherwise ranked equally.
+	// This is a secondary key to the ranking below.
+	if c.topicCounts != nil {
+		b.SortByTopicCount(c.topicCounts)
+	}
+
+	// Rank candidates by the score function, defaulting to
+	// that of the selection method. Ties keep the existing
+	// order.
+	score := c.score
+	if score == nil {
+		score, _ = DefaultScoreFunc(by)
+	}
+	b.SortByScore(score, c.partition)
+
+	// Prefer the least loaded racks relative to
+	// capacity, retaining the order within racks.
+	if c.rackWeighted {
+		b.SortByRackWeight()
+	}
+
+	// Move brokers holding replicas of topics in
+	// a soft anti-affinity group to the end of the
+	// candidates, retaining the order otherwise.
+	if c.topics != nil && c.topics.soft {
+		sort.SliceStable(b, func(i, j int) bool {
+			return !c.topicConflict(b[i]) && c.topicConflict(b[j])
+		})
+	}
+
+	// Move the controller to the end of the
+	// candidates if it's to be deprioritized.
+	if c.controllerPolicy == "deprioritize" {
+		b.deprioritize(c.controller)
+	}
+
 	var candidate *Broker
+	var rejected int
 
 	// Iterate over candidates.
 	for _, candidate = range b {
@@ -57,24 +243,71 @@ func (b BrokerList) BestCandidate(c *Constraints, by string, p int64) (*Broker,
 		if c.passes(candidate) {
 			c.Add(candidate)
 			candidate.Used++
+			s.record(b, rejected, false)
 
 			return candidate, nil
 		}
+
+		rejected++
 	}
 
 	// List exhausted, no brokers passed.
-	return nil, ErrNoBrokers
+	s.record(b, rejected, true)
+
+	return nil, c.exhausted(b)
+}
+
+// exhausted returns the error for a BrokerList where no candidates passed
+// the *Constraints. If topic anti-affinity is the only constraint preventing
+// any candidate from passing, an ErrTopicAntiAffinity naming the conflicting
+// topics is returned. Otherwise, ErrNoBrokers is returned.
+func (c *Constraints) exhausted(bl BrokerList) error {
+	if c.topics == nil || c.topics.soft {
+		return ErrNoBrokers
+	}
+
+	relaxed := *c
+	relaxed.topics = nil
+
+	held := map[string]bool{}
+	for _, br := range bl {
+		if br.ID != 0 && relaxed.passes(br) {
+			for _, t := range c.topics.conflicting(br.ID, c.topic) {
+				held[t] = true
+			}
+		}
+	}
+
+	if len(held) == 0 {
+		return ErrNoBrokers
+	}
+
+	var topics []string
+	for t := range held {
+		topics = append(topics, t)
+	}
+	sort.Strings(topics)
+
+	return fmt.Errorf("%w (held: %s)", ErrTopicAntiAffinity, strings.Join(topics, ", "))
 }
 
 // Add takes a *Broker and adds its attributes to the *Constraints.
 // The requestSize is also subtracted from the *Broker.StorageFree.
 func (c *Constraints) Add(b *Broker) {
 	b.StorageFree -= c.requestSize
+	c.merge(b)
+}
 
+// merge adds the *Broker attributes to the *Constraints.
+func (c *Constraints) merge(b *Broker) {
 	if b.Locality != "" {
 		c.locality[b.Locality] = true
 	}
 
+	for _, g := range b.AntiAffinityGroups {
+		c.groups[g] = true
+	}
+
 	c.id[b.ID] = true
 }
 
@@ -90,9 +323,25 @@ func (c *Constraints) passes(b *Broker) bool {
 		// the existing replica set localities.
 	case c.locality[b.Locality]:
 		return false
-	// Fail if the candidate would run
-	// out of storage.
-	case b.StorageFree-c.requestSize < 0:
+	// Fail if the candidate is the controller
+	// and the controller is excluded.
+	case c.controllerPolicy == "exclude" && b.ID == c.controller:
+		return false
+	// Fail if the candidate shares an anti-affinity
+	// group with any existing replica set broker.
+	case c.inGroup(b):
+		return false
+	// Fail if the candidate would run out of
+	// storage or fall below the storage free floor.
+	case b.StorageFree-c.requestSize < c.minStorageFree:
+		return false
+	// Fail if the candidate holds the
+	// maximum number of replicas.
+	case c.maxPartitions > 0 && c.partitionCounts[b.ID] >= c.maxPartitions:
+		return false
+	// Fail if the candidate holds replicas of a topic in
+	// a strict anti-affinity group with the topic.
+	case c.topics != nil && !c.topics.soft && c.topicConflict(b):
 		return false
 	}
 
@@ -112,12 +361,268 @@ func MergeConstraints(bl BrokerList) *Constraints {
 			continue
 		}
 
-		if b.Locality != "" {
-			c.locality[b.Locality] = true
+		c.merge(b)
+	}
+
+	return c
+}
+
+// PlacementConstraints configures AssignPartition.
+type PlacementConstraints struct {
+	// Replication is the number of replicas to assign.
+	Replication int
+	// RackDiversity requires that each replica
+	// is in a distinct locality.
+	RackDiversity bool
+	// Size is the partition size in bytes. If non-zero, brokers are
+	// selected by storage free rather than by partition count.
+	Size float64
+	// MinStorageFree is the storage free floor in bytes a broker
+	// must remain at or above after accepting the partition.
+	MinStorageFree float64
+}
+
+// AssignPartition returns a replica set for the *Partition satisfying the
+// PlacementConstraints. Existing replicas on brokers that aren't marked
+// for replacement or missing are retained where they satisfy the
+// constraints; remaining replicas are selected from the BrokerMap by
+// partition count or, if a Size is specified, storage free. Selected
+// brokers are accounted for in the BrokerMap (Used is incremented and
+// Size is subtracted from StorageFree). The *Partition isn't modified.
+// If the replica set can't be completed, the BrokerMap is left unchanged
+// and an error naming the constraint that couldn't be met is returned.
+func (b BrokerMap) AssignPartition(p *Partition, pc PlacementConstraints) ([]int, error) {
+	if pc.Replication < 1 {
+		return nil, ErrInvalidReplication
+	}
+
+	c := NewConstraints()
+	c.requestSize = pc.Size
+	c.minStorageFree = pc.MinStorageFree
+
+	// ignoreLocality resets the locality constraints
+	// if rack diversity isn't required.
+	ignoreLocality := func() {
+		if !pc.RackDiversity {
+			c.locality = map[string]bool{}
 		}
+	}
 
-		c.id[b.ID] = true
+	eligible := func(br *Broker) bool {
+		return br.ID != 0 && !br.Replace && !br.Missing
 	}
 
-	return c
+	var replicas []int
+
+	// Retain existing replicas. These already
+	// hold the partition data, so storage isn't
+	// accounted for.
+	for _, id := range p.Replicas {
+		if len(replicas) == pc.Replication {
+			break
+		}
+
+		br, exists := b[id]
+		if !exists || !eligible(br) || c.id[id] || c.locality[br.Locality] || c.inGroup(br) {
+			continue
+		}
+
+		c.merge(br)
+		ignoreLocality()
+		replicas = append(replicas, id)
+	}
+
+	bl := b.Filter(eligible).List()
+
+	// Brokers selected for new replicas. Their accounting is
+	// reverted if the replica set can't be completed.
+	var picked []*Broker
+
+	for len(replicas) < pc.Replication {
+		if pc.Size > 0 {
+			bl.SortByStorage()
+		} else {
+			bl.SortByCount()
+		}
+
+		var selected *Broker
+		for _, br := range bl {
+			if c.passes(br) {
+				selected = br
+				break
+			}
+		}
+
+		if selected == nil {
+			err := c.unmet(bl)
+			for _, br := range picked {
+				br.Used--
+				br.StorageFree += pc.Size
+			}
+			return nil, err
+		}
+
+		c.Add(selected)
+		ignoreLocality()
+		selected.Used++
+		picked = append(picked, selected)
+		replicas = append(replicas, selected.ID)
+	}
+
+	return replicas, nil
+}
+
+// unmet returns an error describing which constraint prevented
+// any broker in the BrokerList from being selected.
+func (c *Constraints) unmet(bl BrokerList) error {
+	var available, inLocality, belowFloor int
+
+	for _, br := range bl {
+		if c.id[br.ID] {
+			continue
+		}
+
+		available++
+
+		switch {
+		case c.locality[br.Locality]:
+			inLocality++
+		case br.StorageFree-c.requestSize < c.minStorageFree:
+			belowFloor++
+		}
+	}
+
+	switch {
+	case available == 0:
+		return ErrInsufficientBrokers
+	case inLocality == available:
+		return ErrRackDiversity
+	case inLocality+belowFloor == available:
+		return ErrStorageFloor
+	}
+
+	return ErrNoBrokers
+}
+
+// inGroup returns whether the *Broker belongs to any
+// anti-affinity group already held by the *Constraints.
+func (c *Constraints) inGroup(b *Broker) bool {
+	for _, g := range b.AntiAffinityGroups {
+		if c.groups[g] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// topicConflict returns whether the *Broker holds replicas of any
+// topic sharing an anti-affinity group with the topic being placed.
+func (c *Constraints) topicConflict(b *Broker) bool {
+	return len(c.topics.conflicting(b.ID, c.topic)) > 0
+}
+
+// deprioritize moves the broker with the
+// specified ID to the end of the BrokerList,
+// retaining the order of all other brokers.
+func (b BrokerList) deprioritize(id int) {
+	for i, br := range b {
+		if br.ID == id {
+			copy(b[i:], b[i+1:])
+			b[len(b)-1] = br
+			return
+		}
+	}
+}
+
+// RackViolation describes a replica set that isn't spread across as many
+// localities as possible. Locality names the over-represented locality
+// and Brokers the replicas in it. A RackViolation with an empty Locality
+// reports replicas on brokers with no locality.
+type RackViolation struct {
+	Topic     string
+	Partition int
+	Locality  string
+	Brokers   []int
+}
+
+// RackViolations takes a *PartitionMap and returns a RackViolation for each
+// over-represented locality in any replica set spanning fewer distinct
+// localities than the lesser of the replication factor and the number of
+// localities available in the BrokerMap. Brokers with no locality, including
+// those not found in the BrokerMap, are each treated as a unique locality;
+// replica sets holding such brokers are additionally reported with an
+// empty Locality.
+func (b BrokerMap) RackViolations(pm *PartitionMap) []RackViolation {
+	// Count available localities.
+	localities := map[string]bool{}
+	var available int
+
+	for id, br := range b {
+		switch {
+		case id == 0:
+		case br.Locality == "":
+			available++
+		case !localities[br.Locality]:
+			localities[br.Locality] = true
+			available++
+		}
+	}
+
+	var violations []RackViolation
+
+	for _, p := range pm.Partitions {
+		byLocality := map[string][]int{}
+		var distinct int
+
+		for _, id := range p.Replicas {
+			var l string
+			if br, exists := b[id]; exists {
+				l = br.Locality
+			}
+
+			if l == "" || len(byLocality[l]) == 0 {
+				distinct++
+			}
+
+			byLocality[l] = append(byLocality[l], id)
+		}
+
+		target := len(p.Replicas)
+		if available < target {
+			target = available
+		}
+
+		// Report over-represented localities.
+		if distinct < target {
+			var over []string
+			for l, ids := range byLocality {
+				if l != "" && len(ids) > 1 {
+					over = append(over, l)
+				}
+			}
+
+			sort.Strings(over)
+
+			for _, l := range over {
+				violations = append(violations, RackViolation{
+					Topic:     p.Topic,
+					Partition: p.Partition,
+					Locality:  l,
+					Brokers:   byLocality[l],
+				})
+			}
+		}
+
+		// Report brokers with no locality.
+		if ids, exists := byLocality[""]; exists {
+			violations = append(violations, RackViolation{
+				Topic:     p.Topic,
+				Partition: p.Partition,
+				Brokers:   ids,
+			})
+		}
+	}
+
+	return violations
 }
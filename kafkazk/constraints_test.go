@@ -1,6 +1,7 @@
 package kafkazk
 
 import (
+	"reflect"
 	"testing"
 )
 
@@ -139,6 +140,93 @@ func TestConstraintsPasses(t *testing.T) {
 	}
 }
 
+func TestConstraintsAntiAffinityGroups(t *testing.T) {
+	b1 := &Broker{ID: 1000, Locality: "a", AntiAffinityGroups: []string{"power-domain:pd1"}}
+	b2 := &Broker{ID: 1001, Locality: "b", AntiAffinityGroups: []string{"power-domain:pd1"}}
+	b3 := &Broker{ID: 1002, Locality: "c", AntiAffinityGroups: []string{"power-domain:pd2"}}
+	b4 := &Broker{ID: 1003, Locality: "d"}
+
+	c := NewConstraints()
+	c.Add(b1)
+
+	if c.passes(b2) {
+		t.Error("Expected broker sharing an anti-affinity group to fail")
+	}
+
+	if !c.passes(b3) {
+		t.Error("Expected broker in a different anti-affinity group to pass")
+	}
+
+	if !c.passes(b4) {
+		t.Error("Expected broker with no anti-affinity groups to pass")
+	}
+
+	// Merged constraints.
+	c = MergeConstraints(BrokerList{b3})
+
+	if c.passes(&Broker{ID: 1004, Locality: "e", AntiAffinityGroups: []string{"power-domain:pd2"}}) {
+		t.Error("Expected broker sharing a merged anti-affinity group to fail")
+	}
+}
+
+func TestConstraintsMinStorageFree(t *testing.T) {
+	c := NewConstraints()
+	c.requestSize = 2500.00
+	c.minStorageFree = 1000.00
+
+	// Broker ID -> StorageFree, expected pass.
+	tests := map[int][2]float64{
+		1000: [2]float64{3000.00, 0},
+		1001: [2]float64{3500.00, 1},
+		1002: [2]float64{10000.00, 1},
+	}
+
+	for id, v := range tests {
+		b := &Broker{ID: id, StorageFree: v[0]}
+		if p := c.passes(b); p != (v[1] == 1) {
+			t.Errorf("Expected broker %d with storage free %.2f to pass: %v", id, v[0], v[1] == 1)
+		}
+	}
+}
+
+func TestBestCandidateController(t *testing.T) {
+	// 1001 is the least used and the controller.
+	bl := BrokerList{
+		&Broker{ID: 1001, Locality: "a", Used: 1},
+		&Broker{ID: 1002, Locality: "b", Used: 2},
+		&Broker{ID: 1003, Locality: "c", Used: 3},
+	}
+
+	// Policy -> expected selections.
+	expected := map[string][]int{
+		"":             []int{1001, 1002, 1003},
+		"deprioritize": []int{1002, 1003, 1001},
+		"exclude":      []int{1002, 1003},
+	}
+
+	for policy, ids := range expected {
+		c := NewConstraints()
+		c.controller = 1001
+		c.controllerPolicy = policy
+
+		for _, id := range ids {
+			b, err := bl.BestCandidate(c, "count", 1)
+			if err != nil {
+				t.Fatalf("[%s] Unexpected error: %s", policy, err)
+			}
+
+			if b.ID != id {
+				t.Errorf("[%s] Expected candidate %d, got %d", policy, id, b.ID)
+			}
+		}
+
+		// Undo Used increments.
+		for _, b := range bl {
+			b.Used = b.ID - 1000
+		}
+	}
+}
+
 func TestMergeConstraints(t *testing.T) {
 	localities := []string{"a", "b", "c"}
 	bl := BrokerList{}
@@ -178,3 +266,297 @@ func TestMergeConstraints(t *testing.T) {
 		t.Error("ID 1004 shouldn't exist in the Constraints")
 	}
 }
+
+func TestRackViolations(t *testing.T) {
+	bm := newMockBrokerMap()
+
+	pm := NewPartitionMap()
+	pm.Partitions = PartitionList{
+		// Clean.
+		Partition{Topic: "test_topic", Partition: 0, Replicas: []int{1001, 1002, 1003}},
+		Partition{Topic: "test_topic", Partition: 1, Replicas: []int{1002, 1001}},
+		// Replication exceeds available localities.
+		Partition{Topic: "test_topic", Partition: 2, Replicas: []int{1001, 1002, 1003, 1004}},
+		// Violations.
+		Partition{Topic: "test_topic", Partition: 3, Replicas: []int{1001, 1004, 1002}},
+		Partition{Topic: "test_topic", Partition: 4, Replicas: []int{1004, 1001}},
+	}
+
+	expected := []RackViolation{
+		RackViolation{Topic: "test_topic", Partition: 3, Locality: "a", Brokers: []int{1001, 1004}},
+		RackViolation{Topic: "test_topic", Partition: 4, Locality: "a", Brokers: []int{1004, 1001}},
+	}
+
+	if v := bm.RackViolations(pm); !reflect.DeepEqual(v, expected) {
+		t.Errorf("Expected violations %v, got %v", expected, v)
+	}
+
+	// Clean map.
+	pm.Partitions = pm.Partitions[:3]
+	if v := bm.RackViolations(pm); len(v) != 0 {
+		t.Errorf("Expected no violations, got %v", v)
+	}
+}
+
+func TestRackViolationsNoLocality(t *testing.T) {
+	bm := newMockBrokerMap()
+	bm[1005] = &Broker{ID: 1005}
+	bm[1006] = &Broker{ID: 1006, Locality: "b"}
+
+	pm := NewPartitionMap()
+	pm.Partitions = PartitionList{
+		// Brokers with no locality are unique localities.
+		Partition{Topic: "test_topic", Partition: 0, Replicas: []int{1001, 1005}},
+		// Brokers not in the BrokerMap have no locality.
+		Partition{Topic: "test_topic", Partition: 1, Replicas: []int{1002, 2000}},
+		// Multiple over-represented localities.
+		Partition{Topic: "test_topic", Partition: 2, Replicas: []int{1006, 1001, 1002, 1004}},
+		// Both kinds.
+		Partition{Topic: "test_topic", Partition: 3, Replicas: []int{1001, 1004, 1005}},
+	}
+
+	expected := []RackViolation{
+		RackViolation{Topic: "test_topic", Partition: 0, Brokers: []int{1005}},
+		RackViolation{Topic: "test_topic", Partition: 1, Brokers: []int{2000}},
+		RackViolation{Topic: "test_topic", Partition: 2, Locality: "a", Brokers: []int{1001, 1004}},
+		RackViolation{Topic: "test_topic", Partition: 2, Locality: "b", Brokers: []int{1006, 1002}},
+		RackViolation{Topic: "test_topic", Partition: 3, Locality: "a", Brokers: []int{1001, 1004}},
+		RackViolation{Topic: "test_topic", Partition: 3, Brokers: []int{1005}},
+	}
+
+	if v := bm.RackViolations(pm); !reflect.DeepEqual(v, expected) {
+		t.Errorf("Expected violations %v, got %v", expected, v)
+	}
+}
+
+func testAssignBrokerMap() BrokerMap {
+	return BrokerMap{
+		1001: &Broker{ID: 1001, Locality: "a", Used: 1, StorageFree: 4000.00},
+		1002: &Broker{ID: 1002, Locality: "a", Used: 2, StorageFree: 5000.00},
+		1003: &Broker{ID: 1003, Locality: "b", Used: 3, StorageFree: 2000.00},
+		1004: &Broker{ID: 1004, Locality: "c", Used: 4, StorageFree: 1000.00},
+	}
+}
+
+func TestAssignPartition(t *testing.T) {
+	type testCase struct {
+		replicas []int
+		pc       PlacementConstraints
+		expected []int
+	}
+
+	tests := map[int]testCase{
+		// By count without rack diversity.
+		0: {nil, PlacementConstraints{Replication: 2}, []int{1001, 1002}},
+		// By count with rack diversity.
+		1: {nil, PlacementConstraints{Replication: 3, RackDiversity: true}, []int{1001, 1003, 1004}},
+		// By storage with a floor; 1003 and 1004 are too small.
+		2: {nil, PlacementConstraints{Replication: 2, Size: 1500.00, MinStorageFree: 1000.00}, []int{1002, 1001}},
+		// Existing replicas are retained.
+		3: {[]int{1004, 1003}, PlacementConstraints{Replication: 3, RackDiversity: true}, []int{1004, 1003, 1001}},
+		// Existing replicas violating rack diversity are replaced.
+		4: {[]int{1001, 1002}, PlacementConstraints{Replication: 2, RackDiversity: true}, []int{1001, 1003}},
+		// Reduced replication.
+		5: {[]int{1004, 1003, 1002}, PlacementConstraints{Replication: 1}, []int{1004}},
+	}
+
+	for i, test := range tests {
+		bm := testAssignBrokerMap()
+		p := &Partition{Topic: "test_topic", Replicas: test.replicas}
+
+		replicas, err := bm.AssignPartition(p, test.pc)
+		if err != nil {
+			t.Errorf("[test %d] Unexpected error: %s", i, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(replicas, test.expected) {
+			t.Errorf("[test %d] Expected replicas %v, got %v", i, test.expected, replicas)
+		}
+
+		if !reflect.DeepEqual(p.Replicas, test.replicas) {
+			t.Errorf("[test %d] Expected partition replicas unchanged", i)
+		}
+	}
+}
+
+func TestAssignPartitionAccounting(t *testing.T) {
+	bm := testAssignBrokerMap()
+	p := &Partition{Topic: "test_topic", Replicas: []int{1003}}
+
+	pc := PlacementConstraints{Replication: 2, Size: 500.00}
+	if _, err := bm.AssignPartition(p, pc); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	// 1002 is selected; the retained 1003 is unchanged.
+	if bm[1002].Used != 3 || bm[1002].StorageFree != 4500.00 {
+		t.Errorf("Expected 1002 Used 3 and StorageFree 4500.00, got %d and %.2f", bm[1002].Used, bm[1002].StorageFree)
+	}
+
+	if bm[1003].Used != 3 || bm[1003].StorageFree != 2000.00 {
+		t.Errorf("Expected 1003 Used 3 and StorageFree 2000.00, got %d and %.2f", bm[1003].Used, bm[1003].StorageFree)
+	}
+}
+
+func TestAssignPartitionErrors(t *testing.T) {
+	type testCase struct {
+		pc       PlacementConstraints
+		expected error
+	}
+
+	tests := map[int]testCase{
+		0: {PlacementConstraints{}, ErrInvalidReplication},
+		// Only 4 brokers.
+		1: {PlacementConstraints{Replication: 5}, ErrInsufficientBrokers},
+		// Only 3 localities.
+		2: {PlacementConstraints{Replication: 4, RackDiversity: true}, ErrRackDiversity},
+		// Only 1002 and 1001 can take 3000 bytes.
+		3: {PlacementConstraints{Replication: 3, Size: 3000.00}, ErrStorageFloor},
+		// Only 1002 stays above a 1500 floor.
+		4: {PlacementConstraints{Replication: 2, Size: 3000.00, MinStorageFree: 1500.00}, ErrStorageFloor},
+	}
+
+	for i, test := range tests {
+		bm := testAssignBrokerMap()
+
+		if _, err := bm.AssignPartition(&Partition{}, test.pc); err != test.expected {
+			t.Errorf("[test %d] Expected error '%v', got '%v'", i, test.expected, err)
+		}
+
+		// Brokers selected ahead of the failure
+		// aren't left accounted for.
+		if !reflect.DeepEqual(bm, testAssignBrokerMap()) {
+			for id, b := range bm {
+				t.Errorf("[test %d] Broker %d: Used %d, StorageFree %.2f", i, id, b.Used, b.StorageFree)
+			}
+		}
+	}
+
+	// Replacement and missing brokers are ineligible.
+	bm := testAssignBrokerMap()
+	bm[1001].Replace = true
+	bm[1002].Missing = true
+
+	pc := PlacementConstraints{Replication: 3}
+	if _, err := bm.AssignPartition(&Partition{Replicas: []int{1001, 1002}}, pc); err != ErrInsufficientBrokers {
+		t.Errorf("Expected error '%v', got '%v'", ErrInsufficientBrokers, err)
+	}
+}
+
+func TestBestCandidateScoreFunc(t *testing.T) {
+	newBrokerList := func() BrokerList {
+		return BrokerList{
+			&Broker{ID: 1001, Locality: "a", Used: 1, StorageFree: 1000.00},
+			&Broker{ID: 1002, Locality: "b", Used: 2, StorageFree: 3000.00},
+			&Broker{ID: 1003, Locality: "c", Used: 3, StorageFree: 2000.00},
+		}
+	}
+
+	// Prefer the most used brokers.
+	mostUsed := func(b *Broker, _ *Partition) float64 { return -float64(b.Used) }
+
+	tests := map[string]struct {
+		f        ScoreFunc
+		expected []int
+	}{
+		"count":     {ScoreByCount, []int{1001, 1002, 1003}},
+		"storage":   {ScoreByStorage, []int{1002, 1003, 1001}},
+		"most used": {mostUsed, []int{1003, 1002, 1001}},
+	}
+
+	for name, test := range tests {
+		bl := newBrokerList()
+		c := NewConstraints()
+		c.score = test.f
+		c.partition = &Partition{Topic: "test_topic"}
+
+		var selected []int
+		for range test.expected {
+			b, err := bl.BestCandidate(c, "count", 1)
+			if err != nil {
+				t.Fatalf("[%s] Unexpected error: %s", name, err)
+			}
+			selected = append(selected, b.ID)
+		}
+
+		if !reflect.DeepEqual(selected, test.expected) {
+			t.Errorf("[%s] Expected selections %v, got %v", name, test.expected, selected)
+		}
+	}
+}
+
+func TestDefaultScoreFunc(t *testing.T) {
+	newBrokerList := func() BrokerList {
+		return BrokerList{
+			&Broker{ID: 1001, Used: 2, StorageFree: 1000.00},
+			&Broker{ID: 1002, Used: 1, StorageFree: 3000.00},
+			&Broker{ID: 1003, Used: 1, StorageFree: 2000.00},
+			&Broker{ID: 1004, Used: 1, StorageFree: 3000.00},
+			&Broker{ID: 1005, Used: 2, StorageFree: 2000.00},
+			&Broker{ID: 1006, Used: 1, StorageFree: 1000.00},
+		}
+	}
+
+	ids := func(bl BrokerList) []int {
+		var ids []int
+		for _, b := range bl {
+			ids = append(ids, b.ID)
+		}
+		return ids
+	}
+
+	// The default ScoreFunc reproduces the selection
+	// method ordering, including the pseudo-random
+	// order of brokers with equal counts.
+	for _, s := range []string{"count", "storage"} {
+		f, err := DefaultScoreFunc(s)
+		if err != nil {
+			t.Fatalf("[%s] Unexpected error: %s", s, err)
+		}
+
+		for seed := int64(0); seed < 8; seed++ {
+			expected, scored := newBrokerList(), newBrokerList()
+
+			switch s {
+			case "count":
+				expected.SortPseudoShuffle(seed)
+				scored.SortPseudoShuffle(seed)
+			case "storage":
+				expected.SortByStorage()
+				scored.SortByStorage()
+			}
+
+			scored.SortByScore(f, &Partition{})
+
+			if !reflect.DeepEqual(ids(scored), ids(expected)) {
+				t.Errorf("[%s seed %d] Expected order %v, got %v", s, seed, ids(expected), ids(scored))
+			}
+		}
+	}
+
+	if _, err := DefaultScoreFunc("invalid"); err != ErrInvalidSelectionMethod {
+		t.Errorf("Expected error '%v', got '%v'", ErrInvalidSelectionMethod, err)
+	}
+}
+
+func TestParseStrategy(t *testing.T) {
+	for _, s := range []Strategy{Balanced, Compact} {
+		parsed, err := ParseStrategy(s.String())
+		if err != nil || parsed != s {
+			t.Errorf("[%s] Expected %s, got %s (error: %v)", s, s, parsed, err)
+		}
+	}
+
+	if _, err := ParseStrategy("invalid"); err != ErrInvalidStrategy {
+		t.Errorf("Expected error '%v', got '%v'", ErrInvalidStrategy, err)
+	}
+
+	if Balanced.ScoreFunc() != nil {
+		t.Error("Expected a nil ScoreFunc for Balanced")
+	}
+
+	if Compact.ScoreFunc() == nil {
+		t.Error("Expected a ScoreFunc for Compact")
+	}
+}
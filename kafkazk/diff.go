@@ -0,0 +1,152 @@
+package kafkazk
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+)
+
+// MapDiff is a structured description of the
+// changes between two PartitionMaps.
+type MapDiff struct {
+	// Partitions holds a PartitionDiff for each
+	// partition that differs between maps.
+	Partitions []PartitionDiff `json:"partitions"`
+	// BrokerDeltas is the net change in the number of
+	// partitions held by each broker referenced in
+	// a replica set change.
+	BrokerDeltas map[int]int `json:"broker_deltas"`
+	// BytesMoved is the sum of partition bytes that
+	// must be replicated to new replicas.
+	BytesMoved float64 `json:"bytes_moved"`
+	// LeaderChanges is the number of partitions
+	// with a changed preferred leader.
+	LeaderChanges int `json:"leader_changes"`
+}
+
+// PartitionDiff describes the changes to a single partition.
+type PartitionDiff struct {
+	Topic         string `json:"topic"`
+	Partition     int    `json:"partition"`
+	Added         []int  `json:"added"`
+	Removed       []int  `json:"removed"`
+	LeaderBefore  int    `json:"leader_before"`
+	LeaderAfter   int    `json:"leader_after"`
+	LeaderChanged bool   `json:"leader_changed"`
+	// BytesMoved is the partition size multiplied
+	// by the number of added replicas.
+	BytesMoved float64 `json:"bytes_moved"`
+}
+
+// Diff takes the original PartitionMap and an optional PartitionMetaMap
+// and returns a *MapDiff describing all changes between the original
+// and the calling PartitionMap. Partitions that are unchanged are omitted.
+// If the PartitionMetaMap is nil, bytes moved are not computed. An error
+// is returned if a partition is missing from the original map or, when
+// provided, the PartitionMetaMap.
+func (pm *PartitionMap) Diff(orig *PartitionMap, pmm PartitionMetaMap) (*MapDiff, error) {
+	// Index the original partitions.
+	prev := map[string]map[int]Partition{}
+	for _, p := range orig.Partitions {
+		if _, exists := prev[p.Topic]; !exists {
+			prev[p.Topic] = map[int]Partition{}
+		}
+		prev[p.Topic][p.Partition] = p
+	}
+
+	d := &MapDiff{
+		Partitions:   []PartitionDiff{},
+		BrokerDeltas: map[int]int{},
+	}
+
+	// Work on a sorted copy for
+	// deterministic output.
+	partitions := pm.Copy().Partitions
+	sort.Sort(partitions)
+
+	for _, p := range partitions {
+		op, exists := prev[p.Topic][p.Partition]
+		if !exists {
+			return nil, fmt.Errorf("%s p%d not found in original map", p.Topic, p.Partition)
+		}
+
+		// Skip no-ops.
+		if p.Equal(op) {
+			continue
+		}
+
+		pd := PartitionDiff{
+			Topic:     p.Topic,
+			Partition: p.Partition,
+			Added:     replicasNotIn(p.Replicas, op.Replicas),
+			Removed:   replicasNotIn(op.Replicas, p.Replicas),
+		}
+
+		if len(op.Replicas) > 0 {
+			pd.LeaderBefore = op.Replicas[0]
+		}
+
+		if len(p.Replicas) > 0 {
+			pd.LeaderAfter = p.Replicas[0]
+		}
+
+		if pd.LeaderBefore != pd.LeaderAfter {
+			pd.LeaderChanged = true
+			d.LeaderChanges++
+		}
+
+		for _, id := range pd.Added {
+			d.BrokerDeltas[id]++
+		}
+
+		for _, id := range pd.Removed {
+			d.BrokerDeltas[id]--
+		}
+
+		if pmm != nil && len(pd.Added) > 0 {
+			size, err := pmm.Size(p)
+			if err != nil {
+				return nil, err
+			}
+
+			pd.BytesMoved = size * float64(len(pd.Added))
+			d.BytesMoved += pd.BytesMoved
+		}
+
+		d.Partitions = append(d.Partitions, pd)
+	}
+
+	return d, nil
+}
+
+// replicasNotIn returns the IDs in s1 that
+// are not present in s2, in s1 order.
+func replicasNotIn(s1, s2 []int) []int {
+	in := map[int]bool{}
+	for _, id := range s2 {
+		in[id] = true
+	}
+
+	ids := []int{}
+	for _, id := range s1 {
+		if !in[id] {
+			ids = append(ids, id)
+		}
+	}
+
+	return ids
+}
+
+// WriteMapDiff takes a *MapDiff and writes
+// a JSON text file to the provided path.
+func WriteMapDiff(d *MapDiff, path string) error {
+	// Marshal.
+	out, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Write file.
+	return ioutil.WriteFile(path+".json", append(out, '\n'), 0644)
+}
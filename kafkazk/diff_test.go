@@ -0,0 +1,121 @@
+package kafkazk
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func testGetMapDiff() *MapDiff {
+	return &MapDiff{
+		Partitions: []PartitionDiff{
+			PartitionDiff{Topic: "test_topic", Partition: 0, Added: []int{1005}, Removed: []int{1002},
+				LeaderBefore: 1001, LeaderAfter: 1001, BytesMoved: 1000},
+			PartitionDiff{Topic: "test_topic", Partition: 1, Added: []int{1005}, Removed: []int{1002},
+				LeaderBefore: 1002, LeaderAfter: 1005, LeaderChanged: true, BytesMoved: 1500},
+			PartitionDiff{Topic: "test_topic", Partition: 3, Added: []int{1005}, Removed: []int{1003},
+				LeaderBefore: 1004, LeaderAfter: 1004, BytesMoved: 2500},
+			PartitionDiff{Topic: "test_topic", Partition: 4, Added: []int{1005}, Removed: []int{1003},
+				LeaderBefore: 1001, LeaderAfter: 1001, BytesMoved: 2200},
+			PartitionDiff{Topic: "test_topic", Partition: 5, Added: []int{1005}, Removed: []int{1002},
+				LeaderBefore: 1002, LeaderAfter: 1005, LeaderChanged: true, BytesMoved: 4000},
+		},
+		BrokerDeltas:  map[int]int{1002: -3, 1003: -2, 1005: 5},
+		BytesMoved:    11200,
+		LeaderChanges: 2,
+	}
+}
+
+func TestDiff(t *testing.T) {
+	zk := &Mock{}
+	pmm, _ := zk.GetAllPartitionMeta()
+	orig, pm := testGetBatchMaps()
+
+	d, err := pm.Diff(orig, pmm)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	expected := testGetMapDiff()
+
+	if !reflect.DeepEqual(d, expected) {
+		t.Errorf("Expected diff %+v, got %+v", expected, d)
+	}
+
+	// Without partition metadata.
+	d, _ = pm.Diff(orig, nil)
+	if d.BytesMoved != 0 {
+		t.Errorf("Expected 0 bytes moved, got %.2f", d.BytesMoved)
+	}
+
+	if len(d.Partitions) != 5 {
+		t.Errorf("Expected 5 partition diffs, got %d", len(d.Partitions))
+	}
+
+	// No changes.
+	d, _ = orig.Diff(orig, pmm)
+	if len(d.Partitions) != 0 || len(d.BrokerDeltas) != 0 {
+		t.Errorf("Expected empty diff, got %+v", d)
+	}
+}
+
+func TestDiffErrors(t *testing.T) {
+	zk := &Mock{}
+	pmm, _ := zk.GetAllPartitionMeta()
+	orig, pm := testGetBatchMaps()
+
+	// Partition not in original map.
+	pm.Partitions = append(pm.Partitions, Partition{Topic: "test_topic", Partition: 6, Replicas: []int{1001}})
+	if _, err := pm.Diff(orig, pmm); err == nil {
+		t.Error("Expected non-nil error")
+	}
+
+	// Partition missing from metadata.
+	orig, pm = testGetBatchMaps()
+	delete(pmm["test_topic"], 0)
+	if _, err := pm.Diff(orig, pmm); err == nil {
+		t.Error("Expected non-nil error")
+	}
+}
+
+func TestWriteMapDiff(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kafkazk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "diff")
+	expected := testGetMapDiff()
+
+	if err := WriteMapDiff(expected, path); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	b, err := ioutil.ReadFile(path + ".json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := &MapDiff{}
+	if err := json.Unmarshal(b, d); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if !reflect.DeepEqual(d, expected) {
+		t.Errorf("Expected diff %+v, got %+v", expected, d)
+	}
+
+	// Check field names.
+	raw := map[string]interface{}{}
+	json.Unmarshal(b, &raw)
+
+	for _, k := range []string{"partitions", "broker_deltas", "bytes_moved", "leader_changes"} {
+		if _, exists := raw[k]; !exists {
+			t.Errorf("Expected key '%s' in diff", k)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package kafkazk
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// replicaMoves describes a partition reassignment by its index in
+// the PartitionMap, the number of replicas it adds to brokers and
+// the fullness of the fullest broker it moves a replica off of.
+type replicaMoves struct {
+	index    int
+	added    int
+	priority float64
+}
+
+// LimitMoves takes the original PartitionMap, a BrokerMap of the brokers
+// prior to reassignment and a maximum number of replica moves, where a move
+// is a replica added to a broker that didn't previously hold it. A copy of
+// the calling PartitionMap is returned in which partitions beyond the limit
+// are reverted to their original assignment, along with the number of moves
+// deferred. A partition's moves are never split.
+//
+// Partitions are prioritized by the fullest broker they move a replica off
+// of. Brokers missing from the BrokerMap or marked as missing are fullest,
+// followed by brokers with the least storage free if every broker has storage
+// free data, otherwise those with the highest Used count. Partitions are
+// included in priority order while their moves fit within the limit. Changes
+// that add no replicas, such as a reordered replica set, are always included.
+// An error is returned if a partition is missing from the original map.
+func (pm *PartitionMap) LimitMoves(orig *PartitionMap, bm BrokerMap, max int) (*PartitionMap, int, error) {
+	// Index the original partitions.
+	prev := map[string]map[int]Partition{}
+	for _, p := range orig.Partitions {
+		if _, exists := prev[p.Topic]; !exists {
+			prev[p.Topic] = map[int]Partition{}
+		}
+		prev[p.Topic][p.Partition] = p
+	}
+
+	// Fullness is by storage free if every
+	// broker has storage free data.
+	byStorage := true
+	for id, b := range bm {
+		if id != 0 && !b.Missing && b.StorageFree <= 0 {
+			byStorage = false
+		}
+	}
+
+	fullness := func(id int) float64 {
+		b, exists := bm[id]
+		switch {
+		case !exists, b.Missing:
+			return math.Inf(1)
+		case byStorage:
+			return -b.StorageFree
+		}
+		return float64(b.Used)
+	}
+
+	out := pm.Copy()
+
+	var moves []replicaMoves
+
+	for i, p := range out.Partitions {
+		op, exists := prev[p.Topic][p.Partition]
+		if !exists {
+			return nil, 0, fmt.Errorf("%s p%d not found in original map", p.Topic, p.Partition)
+		}
+
+		existing := map[int]bool{}
+		for _, id := range op.Replicas {
+			existing[id] = true
+		}
+
+		current := map[int]bool{}
+		m := replicaMoves{index: i, priority: math.Inf(-1)}
+
+		for _, id := range p.Replicas {
+			current[id] = true
+			if !existing[id] {
+				m.added++
+			}
+		}
+
+		if m.added == 0 {
+			continue
+		}
+
+		for _, id := range op.Replicas {
+			if !current[id] && fullness(id) > m.priority {
+				m.priority = fullness(id)
+			}
+		}
+
+		moves = append(moves, m)
+	}
+
+	// Sort by priority descending, then by
+	// topic and partition for determinism.
+	sort.SliceStable(moves, func(i, j int) bool {
+		mi, mj := moves[i], moves[j]
+		if mi.priority != mj.priority {
+			return mi.priority > mj.priority
+		}
+
+		pi, pj := out.Partitions[mi.index], out.Partitions[mj.index]
+		if pi.Topic != pj.Topic {
+			return pi.Topic < pj.Topic
+		}
+		return pi.Partition < pj.Partition
+	})
+
+	var included, deferred int
+
+	for _, m := range moves {
+		if included+m.added <= max {
+			included += m.added
+			continue
+		}
+
+		// Revert the partition.
+		p := out.Partitions[m.index]
+		op := &PartitionMap{Partitions: PartitionList{prev[p.Topic][p.Partition]}}
+		out.Partitions[m.index] = op.Copy().Partitions[0]
+
+		deferred += m.added
+	}
+
+	return out, deferred, nil
+}
@@ -0,0 +1,148 @@
+package kafkazk
+
+import (
+	"reflect"
+	"testing"
+)
+
+func testGetMoveMaps() (*PartitionMap, *PartitionMap) {
+	orig, _ := PartitionMapFromString(`{"version":1,"partitions":[
+    {"topic":"test_topic","partition":0,"replicas":[1001,1002]},
+    {"topic":"test_topic","partition":1,"replicas":[1002,1001]},
+    {"topic":"test_topic","partition":2,"replicas":[1002,1003]},
+    {"topic":"test_topic","partition":3,"replicas":[1003,1004]},
+    {"topic":"test_topic","partition":4,"replicas":[1001,1002]}]}`)
+
+	pm, _ := PartitionMapFromString(`{"version":1,"partitions":[
+    {"topic":"test_topic","partition":0,"replicas":[1003,1002]},
+    {"topic":"test_topic","partition":1,"replicas":[1002,1004]},
+    {"topic":"test_topic","partition":2,"replicas":[1004,1003]},
+    {"topic":"test_topic","partition":3,"replicas":[1004,1003]},
+    {"topic":"test_topic","partition":4,"replicas":[1003,1004]}]}`)
+
+	return orig, pm
+}
+
+func TestLimitMoves(t *testing.T) {
+	orig, pm := testGetMoveMaps()
+	pmCopy := pm.Copy()
+
+	// 1001 is the fullest broker, then 1002.
+	bm := BrokerMap{
+		0:    &Broker{ID: 0, Replace: true},
+		1001: &Broker{ID: 1001, StorageFree: 100},
+		1002: &Broker{ID: 1002, StorageFree: 200},
+		1003: &Broker{ID: 1003, StorageFree: 900},
+		1004: &Broker{ID: 1004, StorageFree: 1000},
+	}
+
+	type testCase struct {
+		max      int
+		deferred int
+		// Partitions expected to retain their original assignment.
+		reverted []int
+	}
+
+	tests := map[int]testCase{
+		// p0 and p1 move a replica off of 1001. p4 also does, but
+		// requires 2 moves and is deferred in favor of p2, which
+		// moves a replica off of 1002. p3 is a reorder and is
+		// always included.
+		0: {max: 3, deferred: 2, reverted: []int{4}},
+		1: {max: 4, deferred: 1, reverted: []int{2}},
+		2: {max: 5, deferred: 0, reverted: nil},
+		3: {max: 1, deferred: 4, reverted: []int{1, 2, 4}},
+		4: {max: 0, deferred: 5, reverted: []int{0, 1, 2, 4}},
+	}
+
+	for i, test := range tests {
+		out, deferred, err := pm.LimitMoves(orig, bm, test.max)
+		if err != nil {
+			t.Fatalf("[test %d] Unexpected error: %s", i, err)
+		}
+
+		if deferred != test.deferred {
+			t.Errorf("[test %d] Expected %d deferred moves, got %d", i, test.deferred, deferred)
+		}
+
+		reverted := map[int]bool{}
+		for _, p := range test.reverted {
+			reverted[p] = true
+		}
+
+		var moves int
+		for j, p := range out.Partitions {
+			expected := pm.Partitions[j]
+			if reverted[p.Partition] {
+				expected = orig.Partitions[j]
+			}
+
+			if !p.Equal(expected) {
+				t.Errorf("[test %d] Expected p%d replicas %v, got %v", i, p.Partition, expected.Replicas, p.Replicas)
+			}
+
+			existing := map[int]bool{}
+			for _, id := range orig.Partitions[j].Replicas {
+				existing[id] = true
+			}
+
+			for _, id := range p.Replicas {
+				if !existing[id] {
+					moves++
+				}
+			}
+		}
+
+		// The cap is honored.
+		if moves > test.max {
+			t.Errorf("[test %d] Expected at most %d moves, got %d", i, test.max, moves)
+		}
+	}
+
+	// The calling map isn't modified.
+	if !reflect.DeepEqual(pm, pmCopy) {
+		t.Errorf("Expected map %v to be unmodified, got %v", pmCopy, pm)
+	}
+}
+
+func TestLimitMovesByCount(t *testing.T) {
+	orig, pm := testGetMoveMaps()
+
+	// Without storage data, brokers are ranked by Used.
+	// 1002 holds the most replicas. 1001 is missing,
+	// which takes precedence.
+	bm := BrokerMap{
+		1001: &Broker{ID: 1001, Used: 1, Missing: true},
+		1002: &Broker{ID: 1002, Used: 4},
+		1003: &Broker{ID: 1003, Used: 2},
+		1004: &Broker{ID: 1004, Used: 1},
+	}
+
+	// p0, p1 and p4 move replicas off of 1001.
+	out, deferred, err := pm.LimitMoves(orig, bm, 4)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if deferred != 1 {
+		t.Errorf("Expected 1 deferred move, got %d", deferred)
+	}
+
+	if !out.Partitions[2].Equal(orig.Partitions[2]) {
+		t.Errorf("Expected p2 deferred, got %v", out.Partitions[2].Replicas)
+	}
+
+	// With 1001 registered, p2 (off of 1002) is first.
+	bm[1001].Missing = false
+
+	out, _, _ = pm.LimitMoves(orig, bm, 1)
+	if !out.Partitions[2].Equal(pm.Partitions[2]) {
+		t.Errorf("Expected p2 included, got %v", out.Partitions[2].Replicas)
+	}
+
+	// A partition missing from the original map.
+	orig.Partitions = orig.Partitions[1:]
+	if _, _, err := pm.LimitMoves(orig, bm, 1); err == nil {
+		t.Error("Expected error")
+	}
+}
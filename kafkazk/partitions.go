@@ -1,20 +1,34 @@
 package kafkazk
 
 import (
+	"encoding/binary"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"hash/fnv"
 	"io/ioutil"
 	"math/rand"
 	"regexp"
 	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+var (
+	// ErrInvalidLeaderPolicy error.
+	ErrInvalidLeaderPolicy = errors.New("Invalid leader policy")
 )
 
-// Partition represents the Kafka partition structure.
+// Partition represents the Kafka partition structure. Observers, if
+// set, holds the trailing replicas designated as observers (asynchronous
+// replicas outside of the ISR).
 type Partition struct {
-	Topic     string `json:"topic"`
-	Partition int    `json:"partition"`
-	Replicas  []int  `json:"replicas"`
+	Topic     string   `json:"topic" yaml:"topic"`
+	Partition int      `json:"partition" yaml:"partition"`
+	Replicas  []int    `json:"replicas" yaml:"replicas"`
+	Observers []int    `json:"observers,omitempty" yaml:"observers,omitempty"`
+	LogDirs   []string `json:"log_dirs,omitempty" yaml:"log_dirs,omitempty"`
 }
 
 // PartitionList is a []Partition.
@@ -22,8 +36,8 @@ type PartitionList []Partition
 
 // PartitionMap represents the Kafka partition map structure.
 type PartitionMap struct {
-	Version    int           `json:"version"`
-	Partitions PartitionList `json:"partitions"`
+	Version    int           `json:"version" yaml:"version"`
+	Partitions PartitionList `json:"partitions" yaml:"partitions"`
 }
 
 // NewPartitionMap returns an empty *PartitionMap.
@@ -31,6 +45,23 @@ func NewPartitionMap() *PartitionMap {
 	return &PartitionMap{Version: 1}
 }
 
+// NewTopicPartitionMap returns a *PartitionMap for topic t with the
+// specified number of partitions and replication factor. All replicas
+// are set to the stub broker ID 0 so that every replica is placed in a
+// subsequent Rebuild.
+func NewTopicPartitionMap(t string, partitions, replication int) *PartitionMap {
+	pm := NewPartitionMap()
+	for i := 0; i < partitions; i++ {
+		pm.Partitions = append(pm.Partitions, Partition{
+			Topic:     t,
+			Partition: i,
+			Replicas:  make([]int, replication),
+		})
+	}
+
+	return pm
+}
+
 // Satisfy the sort interface for PartitionList.
 
 func (p PartitionList) Len() int      { return len(p) }
@@ -106,6 +137,47 @@ func (pmm PartitionMetaMap) Size(p Partition) (float64, error) {
 	return partn.Size, nil
 }
 
+// MeanSize returns the mean size of all
+// partitions in the PartitionMetaMap.
+func (pmm PartitionMetaMap) MeanSize() float64 {
+	var t, c float64
+	for _, partitions := range pmm {
+		for _, p := range partitions {
+			t += p.Size
+			c++
+		}
+	}
+
+	if c == 0 {
+		return 0
+	}
+
+	return t / c
+}
+
+// FillMissing takes a PartitionMap and a default size. Any partitions in the
+// PartitionMap not found in the PartitionMetaMap are added with the default
+// size, allowing size dependent operations (such as SubStorage and storage
+// placements) to proceed rather than fail. The filled partitions are returned.
+func (pmm PartitionMetaMap) FillMissing(pm *PartitionMap, size float64) PartitionList {
+	var filled PartitionList
+
+	for _, p := range pm.Partitions {
+		if _, err := pmm.Size(p); err == nil {
+			continue
+		}
+
+		if _, exists := pmm[p.Topic]; !exists {
+			pmm[p.Topic] = map[int]*PartitionMeta{}
+		}
+
+		pmm[p.Topic][p.Partition] = &PartitionMeta{Size: size}
+		filled = append(filled, p)
+	}
+
+	return filled
+}
+
 // RebuildParams holds required parameters to call the Rebuild
 // method on a *PartitionMap.
 type RebuildParams struct {
@@ -116,6 +188,45 @@ type RebuildParams struct {
 	Optimization  string
 	Affinities    SubstitutionAffinities
 	PartnSzFactor float64
+	// RackWeighted prefers candidates from racks with
+	// the lowest load relative to the rack capacity.
+	RackWeighted bool
+	// MinStorageFree is the storage free floor (in bytes)
+	// that a candidate must retain after accepting a
+	// partition when using the storage strategy.
+	MinStorageFree float64
+	// Controller is the ID of the controller broker.
+	// ControllerPolicy optionally makes the controller the
+	// least preferred candidate for new replicas ("deprioritize")
+	// or ineligible ("exclude"). Existing replicas are retained.
+	Controller       int
+	ControllerPolicy string
+	// TopicSpread prefers, among candidates ranked equally
+	// by the ScoreFunc, those holding the fewest replicas of
+	// the topic being placed, spreading each topic's
+	// partitions evenly among brokers.
+	TopicSpread bool
+	// MaxPartitionsPerBroker, if non-zero, is the maximum
+	// number of replicas a broker may hold. Brokers at the
+	// cap are ineligible candidates for new replicas; existing
+	// replicas are retained regardless.
+	MaxPartitionsPerBroker int
+	// ScoreFunc ranks candidates. If nil, the
+	// DefaultScoreFunc of the Strategy is used.
+	ScoreFunc ScoreFunc
+	// TopicAntiAffinity, if non-nil, keeps new replicas off
+	// of brokers holding replicas of topics that share an
+	// anti-affinity group; see TopicAntiAffinity.
+	TopicAntiAffinity *TopicAntiAffinity
+	// Seed offsets the seeds used to shuffle equally used
+	// candidates with the count strategy. If AutoSeed is set,
+	// the seed for each topic is instead derived from the
+	// eligible broker IDs and topic name; see DeriveSeed.
+	Seed     int64
+	AutoSeed bool
+	// Stats, if non-nil, records candidate
+	// selections performed during the rebuild.
+	Stats *PlacementStats
 }
 
 // NewRebuildParams initializes a RebuildParams.
@@ -125,6 +236,55 @@ func NewRebuildParams() RebuildParams {
 	}
 }
 
+// DeriveSeed returns a seed derived from a set of broker IDs and a topic
+// name. Identical inputs always produce the same seed, regardless of the
+// order of IDs or any duplicates, while different broker sets or topics
+// produce different seeds. This allows reproducible placements for a given
+// cluster state without choosing a seed. The reserved ID 0 is ignored.
+func DeriveSeed(ids []int, topic string) int64 {
+	set := map[int]bool{}
+	var sorted []int
+
+	for _, id := range ids {
+		if id != 0 && !set[id] {
+			set[id] = true
+			sorted = append(sorted, id)
+		}
+	}
+
+	sort.Ints(sorted)
+
+	h := fnv.New64a()
+	buf := make([]byte, 8)
+
+	for _, id := range sorted {
+		binary.BigEndian.PutUint64(buf, uint64(id))
+		h.Write(buf)
+	}
+
+	h.Write([]byte(topic))
+
+	return int64(h.Sum64())
+}
+
+// topicSeeds returns a func returning the seed offset for placements of
+// a topic, derived from the BrokerList IDs if AutoSeed is set.
+func (params RebuildParams) topicSeeds(bl BrokerList) func(string) int64 {
+	if !params.AutoSeed {
+		return func(string) int64 { return params.Seed }
+	}
+
+	ids := bl.IDs()
+	seeds := map[string]int64{}
+
+	return func(topic string) int64 {
+		if _, exists := seeds[topic]; !exists {
+			seeds[topic] = DeriveSeed(ids, topic)
+		}
+		return seeds[topic]
+	}
+}
+
 // SimpleLeaderOptimization is a naive leadership optimization algorithm.
 // It gets leadership counts for all brokers in the partition map and
 // shuffles partition replica sets for those holding brokers with below
@@ -170,6 +330,474 @@ func (pm *PartitionMap) SimpleLeaderOptimization() {
 	}
 }
 
+// SetLeaders takes a BrokerMap and a leader policy and reorders each
+// partition replica set so that the broker selected by the policy is
+// placed in the first (preferred leader) position. The relative order
+// of all other replicas is retained. Valid policies are:
+// - count: the replica with the fewest leaderships assigned so far.
+// - storage: the replica with the most storage free.
+// - rack:<id>: the first replica in the specified rack, if any.
+// Ties are resolved in favor of the current replica order.
+func (pm *PartitionMap) SetLeaders(bm BrokerMap, policy string) error {
+	var pick func(replicas []int) int
+
+	leaders := map[int]int{}
+
+	switch {
+	case policy == "count":
+		pick = func(replicas []int) int {
+			best := 0
+			for i, id := range replicas {
+				if leaders[id] < leaders[replicas[best]] {
+					best = i
+				}
+			}
+			return best
+		}
+	case policy == "storage":
+		pick = func(replicas []int) int {
+			best := 0
+			for i, id := range replicas {
+				b, ok := bm[id]
+				if !ok {
+					continue
+				}
+				if cur, ok := bm[replicas[best]]; !ok || b.StorageFree > cur.StorageFree {
+					best = i
+				}
+			}
+			return best
+		}
+	case strings.HasPrefix(policy, "rack:") && len(policy) > 5:
+		rack := policy[5:]
+		pick = func(replicas []int) int {
+			for i, id := range replicas {
+				if b, ok := bm[id]; ok && b.Locality == rack {
+					return i
+				}
+			}
+			return 0
+		}
+	default:
+		return ErrInvalidLeaderPolicy
+	}
+
+	for _, p := range pm.Partitions {
+		if len(p.Replicas) == 0 {
+			continue
+		}
+
+		// Move the selected replica to the
+		// first position, shifting the others.
+		if i := pick(p.Replicas); i > 0 {
+			id := p.Replicas[i]
+			copy(p.Replicas[1:i+1], p.Replicas[:i])
+			p.Replicas[0] = id
+		}
+
+		leaders[p.Replicas[0]]++
+	}
+
+	return nil
+}
+
+// SetLeadersBySize takes a PartitionMetaMap and reorders each partition
+// replica set so that leadership is balanced by leader bytes rather than
+// leader counts. Partitions are visited in descending size order, with
+// each leadership assigned to the replica holding the fewest leader bytes
+// so far. The relative order of all other replicas is retained. Ties are
+// resolved in favor of the current replica order. An error is returned if
+// any partition is missing from the PartitionMetaMap.
+func (pm *PartitionMap) SetLeadersBySize(pmm PartitionMetaMap) error {
+	sizes := make([]float64, len(pm.Partitions))
+	order := make([]int, len(pm.Partitions))
+
+	for i, p := range pm.Partitions {
+		s, err := pmm.Size(p)
+		if err != nil {
+			return err
+		}
+		sizes[i], order[i] = s, i
+	}
+
+	sort.SliceStable(order, func(i, j int) bool {
+		return sizes[order[i]] > sizes[order[j]]
+	})
+
+	leaderBytes := map[int]float64{}
+
+	for _, n := range order {
+		replicas := pm.Partitions[n].Replicas
+		if len(replicas) == 0 {
+			continue
+		}
+
+		best := 0
+		for i, id := range replicas {
+			if leaderBytes[id] < leaderBytes[replicas[best]] {
+				best = i
+			}
+		}
+
+		if best > 0 {
+			id := replicas[best]
+			copy(replicas[1:best+1], replicas[:best])
+			replicas[0] = id
+		}
+
+		leaderBytes[replicas[0]] += sizes[n]
+	}
+
+	return nil
+}
+
+// BalanceLeaders reorders partition replica sets to even out leader counts
+// across brokers without changing replica set membership. Starting from the
+// current preferred leaders, leadership is shifted along chains of partitions
+// from a broker to another holding at least two fewer leaderships; each
+// intermediate broker in a chain gains and loses one leadership. This is
+// repeated until no such chain exists, at which point the leader counts are
+// as even as the replica set memberships allow. The relative order of all
+// other replicas is retained and only the replica sets required to reach
+// balance are reordered. The number of reordered partitions is returned.
+func (pm *PartitionMap) BalanceLeaders() int {
+	leaders := map[int]int{}
+	orig := make([]int, len(pm.Partitions))
+
+	for n, p := range pm.Partitions {
+		if len(p.Replicas) > 0 && p.Replicas[0] != 0 {
+			leaders[p.Replicas[0]]++
+			orig[n] = p.Replicas[0]
+		}
+	}
+
+	for {
+		// Visit brokers by descending leader count.
+		ids := []int{}
+		for id := range leaders {
+			ids = append(ids, id)
+		}
+
+		sort.Slice(ids, func(i, j int) bool {
+			if leaders[ids[i]] != leaders[ids[j]] {
+				return leaders[ids[i]] > leaders[ids[j]]
+			}
+			return ids[i] < ids[j]
+		})
+
+		var path []leaderMove
+		for _, id := range ids {
+			if path = pm.leaderPath(id, leaders); path != nil {
+				break
+			}
+		}
+
+		if path == nil {
+			break
+		}
+
+		for _, m := range path {
+			replicas := pm.Partitions[m.partition].Replicas
+			leaders[replicas[0]]--
+			leaders[m.broker]++
+
+			for i, id := range replicas {
+				if id == m.broker {
+					copy(replicas[1:i+1], replicas[:i])
+					replicas[0] = id
+					break
+				}
+			}
+		}
+	}
+
+	var changed int
+	for n, p := range pm.Partitions {
+		if len(p.Replicas) > 0 && p.Replicas[0] != orig[n] {
+			changed++
+		}
+	}
+
+	return changed
+}
+
+// leaderMove describes the promotion of
+// a broker to leader of a partition.
+type leaderMove struct {
+	partition int
+	broker    int
+}
+
+// leaderPath performs a breadth-first search for the shortest chain of
+// leader moves that transfers a leadership from the broker with ID id to
+// a broker leading at least two fewer partitions. Each move promotes a
+// replica of a partition led by the previous broker in the chain. A nil
+// slice is returned if no chain exists.
+func (pm *PartitionMap) leaderPath(id int, leaders map[int]int) []leaderMove {
+	// Partition indexes by leader.
+	led := map[int][]int{}
+	for n, p := range pm.Partitions {
+		if len(p.Replicas) > 0 && p.Replicas[0] != 0 {
+			led[p.Replicas[0]] = append(led[p.Replicas[0]], n)
+		}
+	}
+
+	type hop struct {
+		from int
+		move leaderMove
+	}
+
+	prev := map[int]hop{}
+	visited := map[int]bool{id: true}
+	queue := []int{id}
+
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+
+		for _, n := range led[cur] {
+			for _, r := range pm.Partitions[n].Replicas[1:] {
+				if r == 0 || visited[r] {
+					continue
+				}
+
+				visited[r] = true
+				prev[r] = hop{from: cur, move: leaderMove{partition: n, broker: r}}
+
+				if leaders[r] <= leaders[id]-2 {
+					// Walk the chain back to the source.
+					var path []leaderMove
+					for b := r; b != id; b = prev[b].from {
+						path = append([]leaderMove{prev[b].move}, path...)
+					}
+					return path
+				}
+
+				queue = append(queue, r)
+			}
+		}
+	}
+
+	return nil
+}
+
+// SetExplicitLeaders takes a map of topic, partition to a leader broker ID and
+// reorders the referenced replica sets so that the specified broker is placed
+// in the first (preferred leader) position. The relative order of all other
+// replicas is retained. An error is returned if a specified leader isn't in the
+// partition replica set or the partition isn't in the PartitionMap, in which
+// case the PartitionMap is left unmodified.
+func (pm *PartitionMap) SetExplicitLeaders(leaders map[string]map[int]int) error {
+	idx := map[string]map[int]int{}
+	for i, p := range pm.Partitions {
+		if _, exists := idx[p.Topic]; !exists {
+			idx[p.Topic] = map[int]int{}
+		}
+		idx[p.Topic][p.Partition] = i
+	}
+
+	// Validate all references first.
+	positions := map[int]int{}
+	for t, partitions := range leaders {
+		for partn, id := range partitions {
+			i, exists := idx[t][partn]
+			if !exists {
+				return fmt.Errorf("%s p%d not found in partition map", t, partn)
+			}
+
+			pos := -1
+			for n, r := range pm.Partitions[i].Replicas {
+				if r == id {
+					pos = n
+					break
+				}
+			}
+
+			if pos < 0 {
+				return fmt.Errorf("%s p%d: broker %d not in replica set", t, partn, id)
+			}
+
+			positions[i] = pos
+		}
+	}
+
+	for i, pos := range positions {
+		r := pm.Partitions[i].Replicas
+		id := r[pos]
+		copy(r[1:pos+1], r[:pos])
+		r[0] = id
+	}
+
+	return nil
+}
+
+// SetObservers takes a number of observers n and a BrokerMap and designates
+// n replicas of each partition as observers. Observers are selected among the
+// non-leader replicas, preferring those in racks (by BrokerMap locality) not
+// shared by any of the remaining synchronous replicas, since observers are
+// intended to serve reads in remote racks. Remaining ties are resolved in
+// favor of the trailing replicas. Each replica set is reordered so that the
+// observers trail the synchronous replicas; the relative order within each
+// group is retained. A value of 0 clears any observers. An error is returned
+// if any partition holds n or fewer replicas, in which case the PartitionMap
+// is left unmodified.
+func (pm *PartitionMap) SetObservers(n int, bm BrokerMap) error {
+	for _, p := range pm.Partitions {
+		if n > 0 && len(p.Replicas) <= n {
+			return fmt.Errorf("%s p%d: %d observers requires a replication factor of at least %d, got %d",
+				p.Topic, p.Partition, n, n+1, len(p.Replicas))
+		}
+	}
+
+	locality := func(id int) string {
+		if b, exists := bm[id]; exists {
+			return b.Locality
+		}
+		return ""
+	}
+
+	for i, p := range pm.Partitions {
+		if n == 0 {
+			pm.Partitions[i].Observers = nil
+			continue
+		}
+
+		sync := make([]int, len(p.Replicas))
+		copy(sync, p.Replicas)
+		observers := map[int]bool{}
+
+		for k := 0; k < n; k++ {
+			// Default to the trailing replica.
+			pick := len(sync) - 1
+
+			// Scan from the tail for a replica in a rack not
+			// shared with any other synchronous replica. The
+			// leader is never selected.
+		candidates:
+			for j := len(sync) - 1; j > 0; j-- {
+				loc := locality(sync[j])
+				if loc == "" {
+					continue
+				}
+
+				for l, id := range sync {
+					if l != j && locality(id) == loc {
+						continue candidates
+					}
+				}
+
+				pick = j
+				break
+			}
+
+			observers[sync[pick]] = true
+			sync = append(sync[:pick], sync[pick+1:]...)
+		}
+
+		// Reorder with observers trailing.
+		var replicas, obs []int
+		for _, id := range p.Replicas {
+			if observers[id] {
+				obs = append(obs, id)
+			} else {
+				replicas = append(replicas, id)
+			}
+		}
+
+		pm.Partitions[i].Replicas = append(replicas, obs...)
+		pm.Partitions[i].Observers = obs
+	}
+
+	return nil
+}
+
+// ObserverRackConflicts takes a BrokerMap and returns a description of each
+// observer in the PartitionMap sharing a rack (by BrokerMap locality) with
+// any synchronous replica of its partition, in partition order. Brokers
+// without a known locality are not considered.
+func (pm *PartitionMap) ObserverRackConflicts(bm BrokerMap) []string {
+	locality := func(id int) string {
+		if b, exists := bm[id]; exists {
+			return b.Locality
+		}
+		return ""
+	}
+
+	var conflicts []string
+
+	for _, p := range pm.Partitions {
+		observers := map[int]bool{}
+		for _, id := range p.Observers {
+			observers[id] = true
+		}
+
+		racks := map[string]bool{}
+		for _, id := range p.Replicas {
+			if !observers[id] {
+				racks[locality(id)] = true
+			}
+		}
+
+		for _, id := range p.Observers {
+			if loc := locality(id); loc != "" && racks[loc] {
+				conflicts = append(conflicts, fmt.Sprintf("%s p%d: observer %d shares rack %s with a synchronous replica",
+					p.Topic, p.Partition, id, loc))
+			}
+		}
+	}
+
+	return conflicts
+}
+
+// RemapBrokers takes a map of old to new broker IDs and rewrites all
+// replica IDs in the PartitionMap accordingly. IDs referenced in the
+// PartitionMap that aren't present in the mapping are left unchanged and
+// returned as a sorted []int. If the remap would result in any replica set
+// holding a broker more than once, an error is returned and the
+// PartitionMap is left unmodified.
+func (pm *PartitionMap) RemapBrokers(m map[int]int) ([]int, error) {
+	remapped := make([][]int, len(pm.Partitions))
+	unmapped := map[int]bool{}
+
+	for i, p := range pm.Partitions {
+		seen := map[int]bool{}
+		for _, id := range p.Replicas {
+			newID, exists := m[id]
+			if !exists {
+				unmapped[id] = true
+				newID = id
+			}
+
+			if seen[newID] {
+				return nil, fmt.Errorf("%s p%d: broker %d would be duplicated in the replica set",
+					p.Topic, p.Partition, newID)
+			}
+
+			seen[newID] = true
+			remapped[i] = append(remapped[i], newID)
+		}
+	}
+
+	for i := range pm.Partitions {
+		pm.Partitions[i].Replicas = remapped[i]
+
+		for j, id := range pm.Partitions[i].Observers {
+			if newID, exists := m[id]; exists {
+				pm.Partitions[i].Observers[j] = newID
+			}
+		}
+	}
+
+	var ids []int
+	for id := range unmapped {
+		ids = append(ids, id)
+	}
+
+	sort.Ints(ids)
+
+	return ids, nil
+}
+
 // Rebuild takes a BrokerMap and rebuild strategy. It then traverses the
 // partition map, replacing brokers marked removal with the best available
 // candidate based on the selected rebuild strategy. A rebuilt *PartitionMap
@@ -180,6 +808,18 @@ func (pm *PartitionMap) Rebuild(params RebuildParams) (*PartitionMap, []error) {
 
 	params.pm = pm
 
+	switch params.ControllerPolicy {
+	case "", "deprioritize", "exclude":
+	default:
+		return nil, []error{ErrInvalidControllerPolicy}
+	}
+
+	if params.TopicAntiAffinity != nil {
+		if err := params.TopicAntiAffinity.Validate(); err != nil {
+			return nil, []error{err}
+		}
+	}
+
 	switch params.Strategy {
 	case "count":
 		// Standard sort
@@ -242,6 +882,19 @@ func placeByPosition(params RebuildParams) (*PartitionMap, []error) {
 
 	bl := params.BM.Filter(f).List()
 
+	var topicCounts map[string]map[int]int
+	if params.TopicSpread {
+		topicCounts = params.pm.topicCounts(params.BM)
+	}
+
+	var brokerCounts map[int]int
+	if params.MaxPartitionsPerBroker > 0 {
+		brokerCounts = params.pm.brokerCounts(params.BM)
+	}
+
+	topics := params.TopicAntiAffinity.placements(params.pm, params.BM)
+	seed := params.topicSeeds(bl)
+
 	var errs []error
 	var pass int
 
@@ -295,9 +948,29 @@ func placeByPosition(params RebuildParams) (*PartitionMap, []error) {
 
 				// Populate a constraints.
 				constraints := MergeConstraints(replicaSet)
+				constraints.rackWeighted = params.RackWeighted
+				constraints.controller = params.Controller
+				constraints.controllerPolicy = params.ControllerPolicy
+				constraints.score = params.ScoreFunc
+				constraints.partition = &partn
+
+				if topicCounts != nil {
+					constraints.topicCounts = topicCounts[partn.Topic]
+				}
+
+				if brokerCounts != nil {
+					constraints.maxPartitions = params.MaxPartitionsPerBroker
+					constraints.partitionCounts = brokerCounts
+				}
+
+				if topics != nil {
+					constraints.topics = topics
+					constraints.topic = partn.Topic
+				}
 
 				// Add any necessary meta from current partition
 				// to the constraints.
+				var size float64
 				if params.Strategy == "storage" {
 					s, err := params.PMM.Size(partn)
 					if err != nil {
@@ -306,7 +979,9 @@ func placeByPosition(params RebuildParams) (*PartitionMap, []error) {
 						continue
 					}
 
-					constraints.requestSize = s * params.PartnSzFactor
+					size = s * params.PartnSzFactor
+					constraints.requestSize = size
+					constraints.minStorageFree = params.MinStorageFree
 				}
 
 				// Fetch the best candidate and append.
@@ -331,18 +1006,27 @@ func placeByPosition(params RebuildParams) (*PartitionMap, []error) {
 				} else {
 					// Otherwise, use the standard
 					// constraints based selector.
-					replacement, err = bl.BestCandidate(constraints, params.Strategy, int64(pass*n+1))
+					replacement, err = bl.bestCandidate(constraints, params.Strategy, seed(partn.Topic)+int64(pass*n+1), params.Stats)
 				}
 
 				if err != nil {
 					// Append any caught errors.
-					e := fmt.Errorf("%s p%d: %s", partn.Topic, partn.Partition, err.Error())
-					errs = append(errs, e)
+					errs = append(errs, placementError(partn, params.Strategy, size, err))
 					continue
 				}
 
 				// Add the replacement to the map.
 				newMap.Partitions[n].Replicas = append(newMap.Partitions[n].Replicas, replacement.ID)
+
+				if topicCounts != nil {
+					topicCounts[partn.Topic][replacement.ID]++
+				}
+
+				if brokerCounts != nil {
+					brokerCounts[replacement.ID]++
+				}
+
+				topics.add(replacement.ID, partn.Topic)
 			}
 		}
 
@@ -378,6 +1062,19 @@ func placeByPartition(params RebuildParams) (*PartitionMap, []error) {
 
 	bl := params.BM.Filter(f).List()
 
+	var topicCounts map[string]map[int]int
+	if params.TopicSpread {
+		topicCounts = params.pm.topicCounts(params.BM)
+	}
+
+	var brokerCounts map[int]int
+	if params.MaxPartitionsPerBroker > 0 {
+		brokerCounts = params.pm.brokerCounts(params.BM)
+	}
+
+	topics := params.TopicAntiAffinity.placements(params.pm, params.BM)
+	seed := params.topicSeeds(bl)
+
 	var errs []error
 
 	for _, partn := range params.pm.Partitions {
@@ -413,9 +1110,29 @@ func placeByPartition(params RebuildParams) (*PartitionMap, []error) {
 
 				// Populate a constraints.
 				constraints := MergeConstraints(replicaSet)
+				constraints.rackWeighted = params.RackWeighted
+				constraints.controller = params.Controller
+				constraints.controllerPolicy = params.ControllerPolicy
+				constraints.score = params.ScoreFunc
+				constraints.partition = &partn
+
+				if topicCounts != nil {
+					constraints.topicCounts = topicCounts[partn.Topic]
+				}
+
+				if brokerCounts != nil {
+					constraints.maxPartitions = params.MaxPartitionsPerBroker
+					constraints.partitionCounts = brokerCounts
+				}
+
+				if topics != nil {
+					constraints.topics = topics
+					constraints.topic = partn.Topic
+				}
 
 				// Add any necessary meta from current partition
 				// to the constraints.
+				var size float64
 				if params.Strategy == "storage" {
 					s, err := params.PMM.Size(partn)
 					if err != nil {
@@ -424,20 +1141,31 @@ func placeByPartition(params RebuildParams) (*PartitionMap, []error) {
 						continue
 					}
 
-					constraints.requestSize = s * params.PartnSzFactor
+					size = s * params.PartnSzFactor
+					constraints.requestSize = size
+					constraints.minStorageFree = params.MinStorageFree
 				}
 
 				// Fetch the best candidate and append.
-				replacement, err := bl.BestCandidate(constraints, params.Strategy, 1)
+				replacement, err := bl.bestCandidate(constraints, params.Strategy, seed(partn.Topic)+1, params.Stats)
 
 				if err != nil {
 					// Append any caught errors.
-					e := fmt.Errorf("%s p%d: %s", partn.Topic, partn.Partition, err.Error())
-					errs = append(errs, e)
+					errs = append(errs, placementError(partn, params.Strategy, size, err))
 					continue
 				}
 
 				newPartn.Replicas = append(newPartn.Replicas, replacement.ID)
+
+				if topicCounts != nil {
+					topicCounts[partn.Topic][replacement.ID]++
+				}
+
+				if brokerCounts != nil {
+					brokerCounts[replacement.ID]++
+				}
+
+				topics.add(replacement.ID, partn.Topic)
 			}
 		}
 
@@ -459,6 +1187,57 @@ func placeByPartition(params RebuildParams) (*PartitionMap, []error) {
 	return newMap, errs
 }
 
+// placementError returns a placement error for the partition. When using
+// the storage strategy, the partition size in bytes is included since it
+// is typically the reason no broker could satisfy the constraints.
+func placementError(p Partition, strategy string, size float64, err error) error {
+	if strategy == "storage" {
+		return fmt.Errorf("%s p%d (%.2f bytes): %s", p.Topic, p.Partition, size, err)
+	}
+
+	return fmt.Errorf("%s p%d: %s", p.Topic, p.Partition, err)
+}
+
+// topicCounts returns the number of replicas held by each broker
+// for each topic in the PartitionMap, excluding brokers marked
+// for replacement in the BrokerMap.
+func (pm *PartitionMap) topicCounts(bm BrokerMap) map[string]map[int]int {
+	counts := map[string]map[int]int{}
+
+	for _, p := range pm.Partitions {
+		if _, exists := counts[p.Topic]; !exists {
+			counts[p.Topic] = map[int]int{}
+		}
+
+		for _, id := range p.Replicas {
+			if b, exists := bm[id]; exists && b.Replace {
+				continue
+			}
+			counts[p.Topic][id]++
+		}
+	}
+
+	return counts
+}
+
+// brokerCounts returns the number of replicas held by each broker
+// in the PartitionMap, excluding brokers marked for replacement
+// in the BrokerMap.
+func (pm *PartitionMap) brokerCounts(bm BrokerMap) map[int]int {
+	counts := map[int]int{}
+
+	for _, p := range pm.Partitions {
+		for _, id := range p.Replicas {
+			if b, exists := bm[id]; exists && b.Replace {
+				continue
+			}
+			counts[id]++
+		}
+	}
+
+	return counts
+}
+
 // LocalitiesAvailable takes a broker map and broker and returns a []string
 // of localities that are unused by any of the brokers in any replica sets that
 // the reference broker was found in. This is done by building a set of all
@@ -550,6 +1329,21 @@ func PartitionMapFromString(s string) (*PartitionMap, error) {
 	return pm, nil
 }
 
+// PartitionMapFromYAML takes a YAML encoded string
+// literal partition map and returns a *PartitionMap.
+func PartitionMapFromYAML(s string) (*PartitionMap, error) {
+	pm := NewPartitionMap()
+
+	err := yaml.Unmarshal([]byte(s), &pm)
+	if err != nil {
+		return nil, fmt.Errorf("Error parsing partition map: %s", err.Error())
+	}
+
+	sort.Sort(pm.Partitions)
+
+	return pm, nil
+}
+
 // PartitionMapFromZK takes a slice of regexp and finds all matching topics for
 // each. A merged *PartitionMap of all matching topic maps is returned.
 func PartitionMapFromZK(t []*regexp.Regexp, zk Handler) (*PartitionMap, error) {
@@ -586,6 +1380,17 @@ func PartitionMapFromZK(t []*regexp.Regexp, zk Handler) (*PartitionMap, error) {
 // factor r. Sets exceeding r are truncated, sets below r are extended
 // with stub brokers.
 func (pm *PartitionMap) SetReplication(r int) {
+	pm.SetReplicationRackAware(r, nil)
+}
+
+// SetReplicationRackAware is SetReplication, but sets exceeding r drop their
+// least-preferred replicas according to the BrokerMap bm rather than being
+// truncated. In order, replicas dropped are those on brokers that are stubs,
+// missing, or marked for replacement; those sharing a locality with a more
+// preferred replica in the set; and otherwise the last replica in the set.
+// Sets below r are extended with stub brokers that are left for placement.
+// A nil bm results in truncation.
+func (pm *PartitionMap) SetReplicationRackAware(r int, bm BrokerMap) {
 	// 0 is a no-op.
 	if r == 0 {
 		return
@@ -595,9 +1400,17 @@ func (pm *PartitionMap) SetReplication(r int) {
 		l := len(p.Replicas)
 
 		switch {
-		// Truncate replicas beyond r.
+		// Drop replicas beyond r.
 		case l > r:
-			pm.Partitions[n].Replicas = p.Replicas[:r]
+			replicas := make([]int, l)
+			copy(replicas, p.Replicas)
+
+			for len(replicas) > r {
+				i := leastPreferredReplica(replicas, bm)
+				replicas = append(replicas[:i], replicas[i+1:]...)
+			}
+
+			pm.Partitions[n].Replicas = replicas
 		// Add stub brokers to meet r.
 		case l < r:
 			r := make([]int, r-l)
@@ -606,6 +1419,41 @@ func (pm *PartitionMap) SetReplication(r int) {
 	}
 }
 
+// leastPreferredReplica returns the index of the replica
+// that SetReplicationRackAware drops from the replica set.
+func leastPreferredReplica(replicas []int, bm BrokerMap) int {
+	last := len(replicas) - 1
+
+	if bm == nil {
+		return last
+	}
+
+	// Stub, missing, or replaced brokers.
+	for i := last; i >= 0; i-- {
+		b, exists := bm[replicas[i]]
+		if !exists || b.ID == 0 || b.Missing || b.Replace {
+			return i
+		}
+	}
+
+	// Brokers sharing a locality with a
+	// more preferred broker.
+	for i := last; i > 0; i-- {
+		loc := bm[replicas[i]].Locality
+		if loc == "" {
+			continue
+		}
+
+		for _, id := range replicas[:i] {
+			if bm[id].Locality == loc {
+				return i
+			}
+		}
+	}
+
+	return last
+}
+
 // Copy returns a copy of a *PartitionMap.
 func (pm *PartitionMap) Copy() *PartitionMap {
 	cpy := NewPartitionMap()
@@ -618,6 +1466,17 @@ func (pm *PartitionMap) Copy() *PartitionMap {
 		}
 
 		copy(part.Replicas, p.Replicas)
+
+		if p.Observers != nil {
+			part.Observers = make([]int, len(p.Observers))
+			copy(part.Observers, p.Observers)
+		}
+
+		if p.LogDirs != nil {
+			part.LogDirs = make([]string, len(p.LogDirs))
+			copy(part.LogDirs, p.LogDirs)
+		}
+
 		cpy.Partitions = append(cpy.Partitions, part)
 	}
 
@@ -681,6 +1540,90 @@ func (pm *PartitionMap) Strip() *PartitionMap {
 	return Stripped
 }
 
+// SetLogDirs takes the original PartitionMap, a BrokerMap and a
+// PartitionMetaMap and populates the log_dirs field of each partition.
+// Replicas that are newly placed on a broker with log dir metadata are
+// assigned the broker's log dir with the most storage free; the partition
+// size is then subtracted from that log dir's storage free for the remaining
+// placements. The provided BrokerMap is not modified. All other replicas are
+// assigned "any", deferring the log dir choice to the broker. Partitions where
+// no log dirs were chosen are left unset.
+func (pm *PartitionMap) SetLogDirs(orig *PartitionMap, bm BrokerMap, pmm PartitionMetaMap) {
+	// Index the original replica sets.
+	prev := map[string]map[int]map[int]bool{}
+	for _, p := range orig.Partitions {
+		if _, exists := prev[p.Topic]; !exists {
+			prev[p.Topic] = map[int]map[int]bool{}
+		}
+
+		prev[p.Topic][p.Partition] = map[int]bool{}
+		for _, id := range p.Replicas {
+			prev[p.Topic][p.Partition][id] = true
+		}
+	}
+
+	// Storage free is tracked on copies of each
+	// broker's log dirs; bm may be shared.
+	free := map[int]*Broker{}
+
+	for i, p := range pm.Partitions {
+		// Missing sizes are treated as 0; the
+		// emptiest log dir is still selected.
+		size, _ := pmm.Size(p)
+
+		dirs := make([]string, len(p.Replicas))
+		var assigned bool
+
+		for n, id := range p.Replicas {
+			dirs[n] = "any"
+
+			// Existing replicas keep their log dir.
+			if prev[p.Topic][p.Partition][id] {
+				continue
+			}
+
+			broker, exists := free[id]
+			if !exists {
+				b, exists := bm[id]
+				if !exists {
+					continue
+				}
+
+				broker = &Broker{ID: id, LogDirs: copyLogDirs(b.LogDirs)}
+				free[id] = broker
+			}
+
+			if dir := broker.BestLogDir(); dir != "" {
+				dirs[n] = dir
+				broker.LogDirs[dir] -= size
+				assigned = true
+			}
+		}
+
+		if assigned {
+			pm.Partitions[i].LogDirs = dirs
+		}
+	}
+}
+
+// Orphans takes a BrokerMetaMap of registered brokers and returns a
+// PartitionMap of all partitions holding one or more replicas assigned
+// to broker IDs that aren't registered.
+func (pm *PartitionMap) Orphans(bm BrokerMetaMap) *PartitionMap {
+	orphans := NewPartitionMap()
+
+	for _, p := range pm.Partitions {
+		for _, id := range p.Replicas {
+			if _, exists := bm[id]; !exists {
+				orphans.Partitions = append(orphans.Partitions, p)
+				break
+			}
+		}
+	}
+
+	return orphans
+}
+
 // WriteMap takes a *PartitionMap and writes a JSON
 // text file to the provided path.
 func WriteMap(pm *PartitionMap, path string) error {
@@ -701,6 +1644,25 @@ func WriteMap(pm *PartitionMap, path string) error {
 	return nil
 }
 
+// WriteMapYAML takes a *PartitionMap and writes a YAML
+// text file to the provided path. The schema matches
+// the JSON representation written by WriteMap.
+func WriteMapYAML(pm *PartitionMap, path string) error {
+	// Marshal.
+	out, err := yaml.Marshal(pm)
+	if err != nil {
+		return err
+	}
+
+	// Write file.
+	err = ioutil.WriteFile(path+".yaml", out, 0644)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
 // UseStats returns a map of broker IDs to BrokerUseStats; each
 // contains a count of leader and follower partition assignments.
 func (pm *PartitionMap) UseStats() []*BrokerUseStats {
@@ -743,6 +1705,8 @@ func (p Partition) Equal(p2 Partition) bool {
 		return false
 	case len(p.Replicas) != len(p2.Replicas):
 		return false
+	case len(p.Observers) != len(p2.Observers):
+		return false
 	}
 
 	for i := range p.Replicas {
@@ -751,5 +1715,11 @@ func (p Partition) Equal(p2 Partition) bool {
 		}
 	}
 
+	for i := range p.Observers {
+		if p.Observers[i] != p2.Observers[i] {
+			return false
+		}
+	}
+
 	return true
 }
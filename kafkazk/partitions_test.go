@@ -1,9 +1,15 @@
 package kafkazk
 
 import (
+	"encoding/json"
 	"fmt"
+	"reflect"
 	"regexp"
+	"sort"
+	"strings"
 	"testing"
+
+	"gopkg.in/yaml.v2"
 )
 
 func TestPartitionEquality(t *testing.T) {
@@ -192,6 +198,50 @@ func TestPartitionMapFromString(t *testing.T) {
 	}
 }
 
+func TestPartitionMapYAMLRoundTrip(t *testing.T) {
+	pm, _ := PartitionMapFromString(testGetMapString("test_topic"))
+	pm.Partitions[0].LogDirs = []string{"/data/1", "/data/2"}
+
+	j, err := json.Marshal(pm)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	y, err := yaml.Marshal(pm)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fromJSON, err := PartitionMapFromString(string(j))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	fromYAML, err := PartitionMapFromYAML(string(y))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if !reflect.DeepEqual(fromJSON, fromYAML) {
+		t.Errorf("Expected equal maps, got %v and %v", fromJSON, fromYAML)
+	}
+
+	if !reflect.DeepEqual(pm, fromYAML) {
+		t.Errorf("Expected %v, got %v", pm, fromYAML)
+	}
+
+	// The schemas should match. JSON is
+	// valid YAML, so both are decoded
+	// generically with the YAML decoder.
+	var jsonSchema, yamlSchema interface{}
+	yaml.Unmarshal(j, &jsonSchema)
+	yaml.Unmarshal(y, &yamlSchema)
+
+	if !reflect.DeepEqual(jsonSchema, yamlSchema) {
+		t.Errorf("Expected matching schemas, got %v and %v", jsonSchema, yamlSchema)
+	}
+}
+
 func TestPartitionMapFromZK(t *testing.T) {
 	zk := &Mock{}
 
@@ -257,6 +307,74 @@ func TestSetReplication(t *testing.T) {
 	}
 }
 
+func TestSetReplicationRackAware(t *testing.T) {
+	zk := &Mock{}
+	bm, _ := zk.GetAllBrokerMeta(false)
+
+	// Decrease.
+	pm, _ := PartitionMapFromString(`{"version":1,"partitions":[
+    {"topic":"test_topic","partition":0,"replicas":[1001,1004,1002]},
+    {"topic":"test_topic","partition":1,"replicas":[1002,1003,1005]},
+    {"topic":"test_topic","partition":2,"replicas":[1003,1001,1002]},
+    {"topic":"test_topic","partition":3,"replicas":[1001,1010,1002]}]}`)
+
+	brokers := BrokerMapFromPartitionMap(pm, bm, false)
+	brokers.Update([]int{1001, 1002, 1003, 1004, 1005}, bm)
+
+	pm.SetReplicationRackAware(2, brokers)
+
+	expected := map[int][]int{
+		// 1004 shares rack a with 1001.
+		0: []int{1001, 1002},
+		// 1005 shares rack b with 1002.
+		1: []int{1002, 1003},
+		// No shared racks; the last replica is dropped.
+		2: []int{1003, 1001},
+		// 1010 is missing.
+		3: []int{1001, 1002},
+	}
+
+	for _, p := range pm.Partitions {
+		if !reflect.DeepEqual(p.Replicas, expected[p.Partition]) {
+			t.Errorf("[p%d] Expected replicas %v, got %v", p.Partition, expected[p.Partition], p.Replicas)
+		}
+	}
+
+	// Increase.
+	pm, _ = PartitionMapFromString(`{"version":1,"partitions":[
+    {"topic":"test_topic","partition":0,"replicas":[1001,1002]},
+    {"topic":"test_topic","partition":1,"replicas":[1002,1003]}]}`)
+
+	brokers = BrokerMapFromPartitionMap(pm, bm, false)
+	brokers.Update([]int{1001, 1002, 1003, 1004, 1005}, bm)
+
+	pm.SetReplicationRackAware(3, brokers)
+
+	out, errs := pm.Rebuild(RebuildParams{BM: brokers, Strategy: "count"})
+	if errs != nil {
+		t.Fatalf("Unexpected errors: %v", errs)
+	}
+
+	for _, p := range out.Partitions {
+		if len(p.Replicas) != 3 {
+			t.Errorf("[p%d] Expected 3 replicas, got %d", p.Partition, len(p.Replicas))
+		}
+
+		// New replicas must satisfy rack constraints.
+		seen := map[string]bool{}
+		for _, id := range p.Replicas {
+			if id == 0 {
+				t.Errorf("[p%d] Unexpected stub broker in %v", p.Partition, p.Replicas)
+			}
+
+			if seen[bm[id].Rack] {
+				t.Errorf("[p%d] Replicas %v share rack %s", p.Partition, p.Replicas, bm[id].Rack)
+			}
+			seen[bm[id].Rack] = true
+		}
+	}
+}
+
 func TestStrip(t *testing.T) {
 	pm, _ := PartitionMapFromString(testGetMapString("test_topic"))
 
@@ -271,6 +389,266 @@ func TestStrip(t *testing.T) {
 	}
 }
 
+func TestNewTopicPartitionMap(t *testing.T) {
+	pm := NewTopicPartitionMap("new_topic", 12, 3)
+
+	if len(pm.Partitions) != 12 {
+		t.Fatalf("Expected 12 partitions, got %d", len(pm.Partitions))
+	}
+
+	for i, p := range pm.Partitions {
+		if p.Topic != "new_topic" || p.Partition != i {
+			t.Errorf("Unexpected partition %s p%d at index %d", p.Topic, p.Partition, i)
+		}
+
+		if !reflect.DeepEqual(p.Replicas, []int{0, 0, 0}) {
+			t.Errorf("Expected stub replicas [0 0 0], got %v", p.Replicas)
+		}
+	}
+}
+
+func TestRebuildNewTopic(t *testing.T) {
+	bm := BrokerMetaMap{
+		1001: &BrokerMeta{Rack: "a", StorageFree: 6000},
+		1002: &BrokerMeta{Rack: "a", StorageFree: 6000},
+		1003: &BrokerMeta{Rack: "b", StorageFree: 6000},
+		1004: &BrokerMeta{Rack: "b", StorageFree: 6000},
+		1005: &BrokerMeta{Rack: "c", StorageFree: 6000},
+		1006: &BrokerMeta{Rack: "c", StorageFree: 6000},
+	}
+
+	ids := []int{1001, 1002, 1003, 1004, 1005, 1006}
+
+	for _, strategy := range []string{"count", "storage"} {
+		pm := NewTopicPartitionMap("new_topic", 12, 3)
+
+		pmm := NewPartitionMetaMap()
+		pmm.FillMissing(pm, 100)
+
+		brokers := BrokerMapFromPartitionMap(pm, bm, false)
+		brokers.Update(ids, bm)
+
+		out, errs := pm.Rebuild(RebuildParams{
+			PMM:           pmm,
+			BM:            brokers,
+			Strategy:      strategy,
+			Optimization:  "distribution",
+			PartnSzFactor: 1,
+		})
+
+		if errs != nil {
+			t.Fatalf("[%s] Unexpected error(s): %s", strategy, errs)
+		}
+
+		counts := map[int]int{}
+		for _, p := range out.Partitions {
+			racks := map[string]bool{}
+			for _, id := range p.Replicas {
+				b, exists := bm[id]
+				if !exists {
+					t.Fatalf("[%s] p%d: unexpected broker %d in replicas %v", strategy, p.Partition, id, p.Replicas)
+				}
+
+				if racks[b.Rack] {
+					t.Errorf("[%s] p%d: replicas %v share rack %s", strategy, p.Partition, p.Replicas, b.Rack)
+				}
+
+				racks[b.Rack] = true
+				counts[id]++
+			}
+		}
+
+		// 36 replicas spread evenly across 6 brokers.
+		for _, id := range ids {
+			if counts[id] != 6 {
+				t.Errorf("[%s] Expected 6 replicas on broker %d, got %d", strategy, id, counts[id])
+			}
+		}
+	}
+}
+
+func TestRebuildStrategy(t *testing.T) {
+	bm := BrokerMetaMap{
+		1001: &BrokerMeta{Rack: "a", StorageFree: 6000},
+		1002: &BrokerMeta{Rack: "a", StorageFree: 6000},
+		1003: &BrokerMeta{Rack: "b", StorageFree: 6000},
+		1004: &BrokerMeta{Rack: "b", StorageFree: 6000},
+		1005: &BrokerMeta{Rack: "c", StorageFree: 6000},
+		1006: &BrokerMeta{Rack: "c", StorageFree: 6000},
+	}
+
+	ids := []int{1001, 1002, 1003, 1004, 1005, 1006}
+
+	// rebuild places 12 partitions at RF3, returning
+	// replica counts by broker ID.
+	rebuild := func(placement string, s Strategy, maxPartitions int) map[int]int {
+		pm := NewTopicPartitionMap("new_topic", 12, 3)
+
+		pmm := NewPartitionMetaMap()
+		pmm.FillMissing(pm, 100)
+
+		brokers := BrokerMapFromPartitionMap(pm, bm, false)
+		brokers.Update(ids, bm)
+
+		out, errs := pm.Rebuild(RebuildParams{
+			PMM:                    pmm,
+			BM:                     brokers,
+			Strategy:               placement,
+			Optimization:           "distribution",
+			PartnSzFactor:          1,
+			ScoreFunc:              s.ScoreFunc(),
+			MaxPartitionsPerBroker: maxPartitions,
+		})
+
+		if errs != nil {
+			t.Fatalf("[%s/%s] Unexpected error(s): %s", placement, s, errs)
+		}
+
+		counts := map[int]int{}
+		for _, p := range out.Partitions {
+			racks := map[string]bool{}
+			for _, id := range p.Replicas {
+				if racks[bm[id].Rack] {
+					t.Errorf("[%s/%s] p%d: replicas %v share rack %s", placement, s, p.Partition, p.Replicas, bm[id].Rack)
+				}
+				racks[bm[id].Rack] = true
+				counts[id]++
+			}
+		}
+
+		return counts
+	}
+
+	for _, placement := range []string{"count", "storage"} {
+		// Balanced spreads 36 replicas evenly across 6 brokers.
+		balanced := rebuild(placement, Balanced, 0)
+		for _, id := range ids {
+			if balanced[id] != 6 {
+				t.Errorf("[%s/balanced] Expected 6 replicas on broker %d, got %d", placement, id, balanced[id])
+			}
+		}
+
+		// Compact concentrates them on a single broker per rack.
+		compact := rebuild(placement, Compact, 0)
+		if len(compact) != 3 {
+			t.Errorf("[%s/compact] Expected replicas on 3 brokers, got %v", placement, compact)
+		}
+
+		for id, n := range compact {
+			if n != 12 {
+				t.Errorf("[%s/compact] Expected 12 replicas on broker %d, got %d", placement, id, n)
+			}
+		}
+
+		// Constraints are still satisfied.
+		capped := rebuild(placement, Compact, 8)
+		if len(capped) != 6 {
+			t.Errorf("[%s/compact] Expected replicas on 6 brokers, got %v", placement, capped)
+		}
+
+		for id, n := range capped {
+			if n > 8 {
+				t.Errorf("[%s/compact] Expected at most 8 replicas on broker %d, got %d", placement, id, n)
+			}
+		}
+	}
+}
+
+func TestSetLogDirs(t *testing.T) {
+	zk := &Mock{}
+	bmm, _ := zk.GetAllBrokerMeta(true)
+	pmm, _ := zk.GetAllPartitionMeta()
+
+	orig, _ := PartitionMapFromString(testGetMapString("test_topic"))
+	bm := BrokerMapFromPartitionMap(orig, bmm, false)
+
+	// Move 1001 out of p2 and 1002 out of p3,
+	// placing 1001 in p3.
+	pm := orig.Copy()
+	pm.Partitions[2].Replicas = []int{1003, 1004, 1005}
+	pm.Partitions[3].Replicas = []int{1004, 1003, 1001}
+
+	bm[1005] = &Broker{ID: 1005, LogDirs: map[string]float64{"/data/kafka1": 100.00}}
+
+	pm.SetLogDirs(orig, bm, pmm)
+
+	expected := map[int][]string{
+		0: nil,
+		1: nil,
+		2: []string{"any", "any", "/data/kafka1"},
+		3: []string{"any", "any", "/data/kafka2"},
+	}
+
+	for _, p := range pm.Partitions {
+		if !stringsEqual(p.LogDirs, expected[p.Partition]) {
+			t.Errorf("[p%d] Expected log dirs %v, got %v", p.Partition, expected[p.Partition], p.LogDirs)
+		}
+	}
+
+	// The provided BrokerMap shouldn't be modified.
+	if f := bm[1001].LogDirs["/data/kafka2"]; f != 1500.00 {
+		t.Errorf("Expected log dir storage free of 1500.00, got %.2f", f)
+	}
+
+	// Storage free should be tracked across placements;
+	// the p1 size is subtracted from the first choice
+	// and the p3 placement on 1001 chooses the other log dir.
+	base := orig.Copy()
+	base.Partitions[1].Replicas = []int{1002, 1003}
+	pm2 := base.Copy()
+	pm2.Partitions[1].Replicas = []int{1002, 1001}
+	pm2.Partitions[3].Replicas = []int{1004, 1003, 1001}
+	pm2.SetLogDirs(base, bm, pmm)
+
+	if d := pm2.Partitions[1].LogDirs; !stringsEqual(d, []string{"any", "/data/kafka2"}) {
+		t.Errorf("Expected log dirs [any /data/kafka2], got %v", d)
+	}
+
+	if d := pm2.Partitions[3].LogDirs; !stringsEqual(d, []string{"any", "any", "/data/kafka1"}) {
+		t.Errorf("Expected log dirs [any any /data/kafka1], got %v", d)
+	}
+}
+
+func stringsEqual(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func TestOrphans(t *testing.T) {
+	zk := &Mock{}
+	bmm, _ := zk.GetAllBrokerMeta(false)
+	pm, _ := PartitionMapFromString(testGetMapString("test_topic"))
+
+	if o := pm.Orphans(bmm); len(o.Partitions) != 0 {
+		t.Errorf("Expected 0 orphaned partitions, got %d", len(o.Partitions))
+	}
+
+	// Reference a dead broker ID.
+	pm.Partitions[1].Replicas = []int{1002, 1009}
+	pm.Partitions[3].Replicas = []int{1009, 1003, 1002}
+
+	o := pm.Orphans(bmm)
+
+	if len(o.Partitions) != 2 {
+		t.Fatalf("Expected 2 orphaned partitions, got %d", len(o.Partitions))
+	}
+
+	for i, p := range []int{1, 3} {
+		if o.Partitions[i].Partition != p {
+			t.Errorf("Expected orphaned partition %d, got %d", p, o.Partitions[i].Partition)
+		}
+	}
+}
+
 func TestUseStats(t *testing.T) {
 	pm, _ := PartitionMapFromString(testGetMapString("test_topic"))
 
@@ -378,6 +756,73 @@ func TestRebuildByCount(t *testing.T) {
 	}
 }
 
+func TestDeriveSeed(t *testing.T) {
+	seed := DeriveSeed([]int{1001, 1002, 1003}, "test_topic")
+
+	// Identical inputs produce identical seeds, regardless
+	// of ID ordering, duplicates or the reserved ID 0.
+	for _, ids := range [][]int{
+		{1001, 1002, 1003},
+		{1003, 1001, 1002},
+		{1002, 1001, 1003, 1002},
+		{0, 1001, 1002, 1003},
+	} {
+		if s := DeriveSeed(ids, "test_topic"); s != seed {
+			t.Errorf("Expected seed %d for %v, got %d", seed, ids, s)
+		}
+	}
+
+	// Different inputs produce different seeds.
+	tests := map[int]struct {
+		ids   []int
+		topic string
+	}{
+		0: {ids: []int{1001, 1002}, topic: "test_topic"},
+		1: {ids: []int{1001, 1002, 1004}, topic: "test_topic"},
+		2: {ids: []int{1001, 1002, 1003}, topic: "test_topic2"},
+		3: {ids: nil, topic: "test_topic"},
+	}
+
+	for i, test := range tests {
+		if s := DeriveSeed(test.ids, test.topic); s == seed {
+			t.Errorf("[test %d] Expected a seed different from %d", i, seed)
+		}
+	}
+}
+
+func TestRebuildAutoSeed(t *testing.T) {
+	zk := &Mock{}
+	bm, _ := zk.GetAllBrokerMeta(false)
+	pm, _ := PartitionMapFromString(testGetMapString2("test_topic"))
+
+	rebuild := func(params RebuildParams) *PartitionMap {
+		params.PMM = NewPartitionMetaMap()
+		params.BM = BrokerMapFromPartitionMap(pm, bm, true)
+		params.Strategy = "count"
+		params.Optimization = "distribution"
+
+		out, errs := pm.Strip().Rebuild(params)
+		if errs != nil {
+			t.Fatalf("Unexpected error(s): %s", errs)
+		}
+
+		return out
+	}
+
+	// Auto seeded rebuilds are reproducible.
+	out := rebuild(RebuildParams{AutoSeed: true})
+	if same, err := out.equal(rebuild(RebuildParams{AutoSeed: true})); !same {
+		t.Errorf("Expected identical auto seeded rebuilds: %s", err)
+	}
+
+	// And equivalent to explicitly using the seed derived
+	// from the brokers in the partition map.
+	seed := DeriveSeed([]int{1001, 1002, 1003, 1004}, "test_topic")
+	if same, err := out.equal(rebuild(RebuildParams{Seed: seed})); !same {
+		t.Errorf("Expected auto seeded rebuild to equal a rebuild with seed %d: %s", seed, err)
+	}
+}
+
 // Count rebuild with substitution affinities.
 func TestRebuildByCountSA(t *testing.T) {
 	forceRebuild := true
@@ -545,6 +990,724 @@ func TestRebuildByStorageStorage(t *testing.T) {
 	}
 }
 
+func TestSetLeaders(t *testing.T) {
+	bm := newMockBrokerMap()
+
+	tests := map[string][][]int{
+		"count": [][]int{
+			{1001, 1002},
+			{1002, 1001},
+			{1003, 1004, 1001},
+			{1004, 1003, 1002},
+		},
+		// Storage free ascends with ID in the mock map.
+		"storage": [][]int{
+			{1002, 1001},
+			{1002, 1001},
+			{1004, 1003, 1001},
+			{1004, 1003, 1002},
+		},
+		// 1001 and 1004 are in rack a.
+		"rack:a": [][]int{
+			{1001, 1002},
+			{1001, 1002},
+			{1004, 1003, 1001},
+			{1004, 1003, 1002},
+		},
+		"rack:c": [][]int{
+			{1001, 1002},
+			{1001, 1002},
+			{1003, 1004, 1001},
+			{1003, 1004, 1002},
+		},
+	}
+
+	for policy, expected := range tests {
+		pm, _ := PartitionMapFromString(testGetMapString("test_topic"))
+		// Have 1001 lead the first two partitions.
+		pm.Partitions[1].Replicas = []int{1001, 1002}
+
+		if err := pm.SetLeaders(bm, policy); err != nil {
+			t.Errorf("[%s] Unexpected error: %s", policy, err)
+			continue
+		}
+
+		for i, p := range pm.Partitions {
+			for n := range p.Replicas {
+				if p.Replicas[n] != expected[i][n] {
+					t.Errorf("[%s] Expected replicas %v, got %v", policy, expected[i], p.Replicas)
+					break
+				}
+			}
+		}
+	}
+
+	pm, _ := PartitionMapFromString(testGetMapString("test_topic"))
+	for _, policy := range []string{"", "rack:", "random"} {
+		if err := pm.SetLeaders(bm, policy); err != ErrInvalidLeaderPolicy {
+			t.Errorf("Expected error '%s' for policy '%s', got '%v'", ErrInvalidLeaderPolicy, policy, err)
+		}
+	}
+}
+
+func TestSetLeadersBySize(t *testing.T) {
+	mapString := `{"version":1,"partitions":[
+    {"topic":"test_topic","partition":0,"replicas":[1001,1002]},
+    {"topic":"test_topic","partition":1,"replicas":[1001,1002]},
+    {"topic":"test_topic","partition":2,"replicas":[1001,1002]},
+    {"topic":"test_topic","partition":3,"replicas":[1001,1002]}]}`
+
+	pmm := NewPartitionMetaMap()
+	pmm["test_topic"] = map[int]*PartitionMeta{
+		0: &PartitionMeta{Size: 100},
+		1: &PartitionMeta{Size: 10},
+		2: &PartitionMeta{Size: 100},
+		3: &PartitionMeta{Size: 10},
+	}
+
+	// The count policy alternates leaders, placing
+	// both large partitions on 1001.
+	byCount, _ := PartitionMapFromString(mapString)
+	byCount.SetLeaders(newMockBrokerMap(), "count")
+
+	bySize, _ := PartitionMapFromString(mapString)
+	if err := bySize.SetLeadersBySize(pmm); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	expected := [][]int{
+		{1001, 1002},
+		{1001, 1002},
+		{1002, 1001},
+		{1002, 1001},
+	}
+
+	for i, p := range bySize.Partitions {
+		if !sameIDs(p.Replicas, expected[i]) {
+			t.Errorf("Expected replicas %v, got %v", expected[i], p.Replicas)
+		}
+	}
+
+	sdCount, _ := byCount.LeaderBytesStdDev(pmm)
+	sdSize, _ := bySize.LeaderBytesStdDev(pmm)
+
+	if sdCount != 90 {
+		t.Errorf("Expected count policy leader bytes std. deviation 90, got %f", sdCount)
+	}
+
+	if sdSize != 0 {
+		t.Errorf("Expected leader bytes std. deviation 0, got %f", sdSize)
+	}
+
+	// Missing partition metadata.
+	delete(pmm["test_topic"], 3)
+	if err := bySize.SetLeadersBySize(pmm); err == nil {
+		t.Errorf("Expected error for missing partition metadata")
+	}
+}
+
+func TestBalanceLeaders(t *testing.T) {
+	type testCase struct {
+		mapString string
+		// Expected.
+		changed int
+		min     int
+		max     int
+	}
+
+	tests := map[int]testCase{
+		// Balanced only by shifting leadership along a
+		// chain: 1001 -> 1002 -> 1003.
+		0: {
+			mapString: `{"version":1,"partitions":[
+    {"topic":"test_topic","partition":0,"replicas":[1001,1002]},
+    {"topic":"test_topic","partition":1,"replicas":[1001,1002]},
+    {"topic":"test_topic","partition":2,"replicas":[1002,1003]}]}`,
+			changed: 2, min: 1, max: 1,
+		},
+		// Already balanced.
+		1: {
+			mapString: `{"version":1,"partitions":[
+    {"topic":"test_topic","partition":0,"replicas":[1001,1002,1003]},
+    {"topic":"test_topic","partition":1,"replicas":[1002,1003,1001]},
+    {"topic":"test_topic","partition":2,"replicas":[1003,1001,1002]}]}`,
+			changed: 0, min: 1, max: 1,
+		},
+		// Skewed to a single broker.
+		2: {
+			mapString: `{"version":1,"partitions":[
+    {"topic":"test_topic","partition":0,"replicas":[1001,1002,1003]},
+    {"topic":"test_topic","partition":1,"replicas":[1001,1003,1002]},
+    {"topic":"test_topic","partition":2,"replicas":[1001,1002,1003]},
+    {"topic":"test_topic","partition":3,"replicas":[1001,1003,1002]},
+    {"topic":"test_topic","partition":4,"replicas":[1001,1002,1003]},
+    {"topic":"test_topic","partition":5,"replicas":[1001,1003,1002]}]}`,
+			changed: 4, min: 2, max: 2,
+		},
+		// Membership limits balance; 1001 is
+		// the only replica for two partitions.
+		3: {
+			mapString: `{"version":1,"partitions":[
+    {"topic":"test_topic","partition":0,"replicas":[1001]},
+    {"topic":"test_topic","partition":1,"replicas":[1001]},
+    {"topic":"test_topic","partition":2,"replicas":[1001,1002]},
+    {"topic":"test_topic","partition":3,"replicas":[1001,1002]}]}`,
+			changed: 2, min: 2, max: 2,
+		},
+	}
+
+	for i, test := range tests {
+		pm, _ := PartitionMapFromString(test.mapString)
+		orig := pm.Copy()
+
+		if changed := pm.BalanceLeaders(); changed != test.changed {
+			t.Errorf("[test %d] Expected %d changed partitions, got %d", i, test.changed, changed)
+		}
+
+		// Replica set membership must be retained.
+		for n := range pm.Partitions {
+			r1 := append([]int{}, orig.Partitions[n].Replicas...)
+			r2 := append([]int{}, pm.Partitions[n].Replicas...)
+			sort.Ints(r1)
+			sort.Ints(r2)
+
+			if !reflect.DeepEqual(r1, r2) {
+				t.Errorf("[test %d] Expected replicas %v, got %v", i, orig.Partitions[n].Replicas, pm.Partitions[n].Replicas)
+			}
+		}
+
+		min, max, _ := BrokerUseStatsList(pm.UseStats()).LeadershipSkew()
+		if min != test.min || max != test.max {
+			t.Errorf("[test %d] Expected leader counts min %d, max %d, got min %d, max %d", i, test.min, test.max, min, max)
+		}
+	}
+}
+
+func TestSetExplicitLeaders(t *testing.T) {
+	pm, _ := PartitionMapFromString(testGetMapString("test_topic"))
+	orig := pm.Copy()
+
+	leaders := map[string]map[int]int{
+		"test_topic": map[int]int{
+			0: 1002,
+			2: 1001,
+			3: 1004,
+		},
+	}
+
+	if err := pm.SetExplicitLeaders(leaders); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	expected := [][]int{
+		{1002, 1001},
+		{1002, 1001},
+		{1001, 1003, 1004},
+		{1004, 1003, 1002},
+	}
+
+	for i, p := range pm.Partitions {
+		if !sameIDs(p.Replicas, expected[i]) {
+			t.Errorf("Expected replicas %v, got %v", expected[i], p.Replicas)
+		}
+
+		// Membership should be preserved.
+		got, want := make([]int, len(p.Replicas)), make([]int, len(orig.Partitions[i].Replicas))
+		copy(got, p.Replicas)
+		copy(want, orig.Partitions[i].Replicas)
+		sort.Ints(got)
+		sort.Ints(want)
+
+		if !sameIDs(got, want) {
+			t.Errorf("Replica set membership changed: %v -> %v", orig.Partitions[i].Replicas, p.Replicas)
+		}
+	}
+
+	// Invalid references.
+	tests := []map[string]map[int]int{
+		{"test_topic": {0: 1003}},
+		{"test_topic": {10: 1001}},
+		{"test_topic2": {0: 1001}},
+	}
+
+	for _, l := range tests {
+		before := pm.Copy()
+		if err := pm.SetExplicitLeaders(l); err == nil {
+			t.Errorf("Expected error for leaders %v", l)
+		}
+
+		if same, _ := pm.equal(before); !same {
+			t.Error("Unexpected PartitionMap modification on error")
+		}
+	}
+}
+
+func TestRebuildPlacementStats(t *testing.T) {
+	pm, _ := PartitionMapFromString(`{"version":1,"partitions":[
+    {"topic":"test_topic","partition":0,"replicas":[1001,1003]},
+    {"topic":"test_topic","partition":1,"replicas":[1003,1002]},
+    {"topic":"test_topic","partition":2,"replicas":[1003,1001,1002]}]}`)
+
+	// 1003 is replaced. Distinct Used values make
+	// the candidate order 1001, 1004, 1002, 1005.
+	brokers := BrokerMap{
+		1001: &Broker{ID: 1001, Locality: "a", Used: 1},
+		1002: &Broker{ID: 1002, Locality: "b", Used: 5},
+		1003: &Broker{ID: 1003, Locality: "c", Used: 6, Replace: true},
+		1004: &Broker{ID: 1004, Locality: "a", Used: 3},
+		1005: &Broker{ID: 1005, Locality: "b", Used: 9},
+	}
+
+	stats := NewPlacementStats()
+	rebuildParams := RebuildParams{
+		BM:       brokers,
+		Strategy: "count",
+		Stats:    stats,
+	}
+
+	_, errs := pm.Rebuild(rebuildParams)
+
+	// Leaders:
+	// - p1: 1001 is selected (Used 2) with no rejections.
+	// - p2: all 4 candidates are rejected, as racks
+	//   a and b and IDs 1001 and 1002 are taken.
+	// Followers:
+	// - p0: 1001 (ID) and 1004 (rack a) are rejected,
+	//   backtracking to 1002.
+	expected := PlacementStats{
+		Placements: 3,
+		Failures:   1,
+		Rejections: 6,
+		Backtracks: 1,
+		Candidates: 12,
+		CandidateSizes: map[int]int{
+			4: 3,
+		},
+	}
+
+	if !reflect.DeepEqual(*stats, expected) {
+		t.Errorf("Expected stats %+v, got %+v", expected, *stats)
+	}
+
+	if len(errs) != 1 {
+		t.Errorf("Expected 1 error, got %d", len(errs))
+	}
+
+	if avg := stats.AvgCandidates(); avg != 4.00 {
+		t.Errorf("Expected avg. candidate set size of 4.00, got %.2f", avg)
+	}
+}
+
+func TestRebuildAntiAffinityGroups(t *testing.T) {
+	// All brokers are in distinct racks; 1001 and 1002
+	// share power domain pd1, 1003 and 1004 share pd2.
+	bm := BrokerMetaMap{
+		1001: &BrokerMeta{Rack: "a"},
+		1002: &BrokerMeta{Rack: "b"},
+		1003: &BrokerMeta{Rack: "c"},
+		1004: &BrokerMeta{Rack: "d"},
+	}
+
+	tags := map[int]map[string]string{
+		1001: map[string]string{"power-domain": "pd1"},
+		1002: map[string]string{"power-domain": "pd1"},
+		1003: map[string]string{"power-domain": "pd2"},
+		1004: map[string]string{"power-domain": "pd2"},
+	}
+
+	bm.SetAntiAffinityGroups(tags, []string{"power-domain"})
+
+	domain := map[int]string{}
+	for id, t := range tags {
+		domain[id] = t["power-domain"]
+	}
+
+	// Stub partitions to be placed.
+	pm := NewPartitionMap()
+	for i := 0; i < 8; i++ {
+		pm.Partitions = append(pm.Partitions, Partition{
+			Topic:     "test_topic",
+			Partition: i,
+			Replicas:  []int{0, 0},
+		})
+	}
+
+	brokers := BrokerMapFromPartitionMap(NewPartitionMap(), bm, false)
+	brokers.Update([]int{1001, 1002, 1003, 1004}, bm)
+
+	out, errs := pm.Rebuild(RebuildParams{BM: brokers, Strategy: "count"})
+	if errs != nil {
+		t.Fatalf("Unexpected error: %s", errs[0])
+	}
+
+	for _, p := range out.Partitions {
+		seen := map[string]bool{}
+		for _, id := range p.Replicas {
+			if seen[domain[id]] {
+				t.Errorf("p%d: replicas %v share power domain %s", p.Partition, p.Replicas, domain[id])
+			}
+			seen[domain[id]] = true
+		}
+	}
+
+	// A replication factor of 3 can't be
+	// satisfied with two power domains.
+	pm.SetReplication(3)
+	for i := range pm.Partitions {
+		pm.Partitions[i].Replicas = []int{0, 0, 0}
+	}
+
+	brokers = BrokerMapFromPartitionMap(NewPartitionMap(), bm, false)
+	brokers.Update([]int{1001, 1002, 1003, 1004}, bm)
+
+	_, errs = pm.Rebuild(RebuildParams{BM: brokers, Strategy: "count"})
+	if errs == nil {
+		t.Error("Expected placement errors")
+	}
+}
+
+func TestRebuildMinStorageFree(t *testing.T) {
+	bm := BrokerMetaMap{
+		1001: &BrokerMeta{Rack: "a", StorageFree: 8000.00},
+		1002: &BrokerMeta{Rack: "b", StorageFree: 3000.00},
+		1003: &BrokerMeta{Rack: "c", StorageFree: 2800.00},
+	}
+
+	zk := &Mock{}
+	pmm, _ := zk.GetAllPartitionMeta()
+
+	// p0 (1000) and p3 (2500) each
+	// need a second replica.
+	pm, _ := PartitionMapFromString(`{"version":1,"partitions":[
+    {"topic":"test_topic","partition":0,"replicas":[1001,0]},
+    {"topic":"test_topic","partition":3,"replicas":[1001,0]}]}`)
+
+	rebuild := func(floor float64) (*PartitionMap, []error) {
+		brokers := BrokerMapFromPartitionMap(NewPartitionMap(), bm, false)
+		brokers.Update([]int{1001, 1002, 1003}, bm)
+
+		return pm.Copy().Rebuild(RebuildParams{
+			PMM:            pmm,
+			BM:             brokers,
+			Strategy:       "storage",
+			Optimization:   "distribution",
+			PartnSzFactor:  1,
+			MinStorageFree: floor,
+		})
+	}
+
+	// Without a floor, p3 is placed first
+	// (largest) and fits on 1002.
+	out, errs := rebuild(0)
+	if errs != nil {
+		t.Fatalf("Unexpected error(s): %s", errs)
+	}
+
+	expected := map[int][]int{0: []int{1001, 1003}, 3: []int{1001, 1002}}
+	for _, p := range out.Partitions {
+		if !sameIDs(p.Replicas, expected[p.Partition]) {
+			t.Errorf("Expected p%d replicas %v, got %v", p.Partition, expected[p.Partition], p.Replicas)
+		}
+	}
+
+	// With a 1000 floor, p3 would leave either candidate
+	// below the floor while p0 still fits on 1002.
+	out, errs = rebuild(1000.00)
+	if len(errs) != 1 {
+		t.Fatalf("Expected 1 error, got %v", errs)
+	}
+
+	if !strings.Contains(errs[0].Error(), "test_topic p3 (2500.00 bytes)") {
+		t.Errorf("Expected error for test_topic p3 with its size, got %s", errs[0])
+	}
+
+	if !sameIDs(out.Partitions[0].Replicas, []int{1001, 1002}) {
+		t.Errorf("Expected p0 replicas [1001 1002], got %v", out.Partitions[0].Replicas)
+	}
+}
+
+func TestRebuildScoreFunc(t *testing.T) {
+	bm := BrokerMetaMap{
+		1001: &BrokerMeta{Rack: "a", StorageFree: 10000.00},
+		1002: &BrokerMeta{Rack: "b", StorageFree: 10000.00},
+		1003: &BrokerMeta{Rack: "b", StorageFree: 2000.00},
+		1004: &BrokerMeta{Rack: "c", StorageFree: 8000.00},
+	}
+
+	// 1002 is replaced in both partitions.
+	pm, _ := PartitionMapFromString(`{"version":1,"partitions":[
+    {"topic":"test_topic","partition":0,"replicas":[1001,1002]},
+    {"topic":"test_topic","partition":1,"replicas":[1001,1002]}]}`)
+
+	rebuild := func(f ScoreFunc) *PartitionMap {
+		brokers := BrokerMapFromPartitionMap(pm, bm, false)
+		brokers.Update([]int{1001, 1003, 1004}, bm)
+
+		out, errs := pm.Copy().Rebuild(RebuildParams{
+			BM:            brokers,
+			Strategy:      "count",
+			Optimization:  "distribution",
+			PartnSzFactor: 1,
+			ScoreFunc:     f,
+		})
+
+		if errs != nil {
+			t.Fatalf("Unexpected errors: %v", errs)
+		}
+
+		return out
+	}
+
+	// Prefer brokers with an ID parity matching the partition.
+	parity := func(b *Broker, p *Partition) float64 {
+		if b.ID%2 == p.Partition%2 {
+			return 0
+		}
+		return 1
+	}
+
+	tests := map[string]struct {
+		f        ScoreFunc
+		expected [][]int
+	}{
+		// A nil ScoreFunc uses the default for the count strategy.
+		"nil":     {nil, [][]int{{1001, 1003}, {1001, 1004}}},
+		"count":   {ScoreByCount, [][]int{{1001, 1003}, {1001, 1004}}},
+		"storage": {ScoreByStorage, [][]int{{1001, 1004}, {1001, 1004}}},
+		"parity":  {parity, [][]int{{1001, 1004}, {1001, 1003}}},
+	}
+
+	for name, test := range tests {
+		// Repeated rebuilds are deterministic.
+		for i := 0; i < 3; i++ {
+			out := rebuild(test.f)
+
+			for n, p := range out.Partitions {
+				if !reflect.DeepEqual(p.Replicas, test.expected[n]) {
+					t.Errorf("[%s] Expected p%d replicas %v, got %v", name, n, test.expected[n], p.Replicas)
+				}
+			}
+		}
+	}
+}
+
+func TestRebuildMaxPartitionsPerBroker(t *testing.T) {
+	bm := BrokerMetaMap{
+		1001: &BrokerMeta{StorageFree: 10000.00},
+		1002: &BrokerMeta{StorageFree: 10000.00},
+		1003: &BrokerMeta{StorageFree: 10000.00},
+		1004: &BrokerMeta{StorageFree: 10000.00},
+	}
+
+	zk := &Mock{}
+	pmm, _ := zk.GetAllPartitionMeta()
+
+	// 1002 is replaced in all four partitions.
+	pm, _ := PartitionMapFromString(`{"version":1,"partitions":[
+    {"topic":"test_topic","partition":0,"replicas":[1001,1002]},
+    {"topic":"test_topic","partition":1,"replicas":[1001,1002]},
+    {"topic":"test_topic","partition":2,"replicas":[1001,1002]},
+    {"topic":"test_topic","partition":3,"replicas":[1001,1002]}]}`)
+
+	rebuild := func(strategy, optimization string, max int) (*PartitionMap, []error) {
+		brokers := BrokerMapFromPartitionMap(pm, bm, false)
+		brokers.Update([]int{1001, 1003, 1004}, bm)
+
+		return pm.Copy().Rebuild(RebuildParams{
+			PMM:                    pmm,
+			BM:                     brokers,
+			Strategy:               strategy,
+			Optimization:           optimization,
+			PartnSzFactor:          1,
+			MaxPartitionsPerBroker: max,
+		})
+	}
+
+	for _, s := range [][2]string{{"count", ""}, {"storage", "distribution"}, {"storage", "storage"}} {
+		// Unlimited preserves current behavior;
+		// a non-binding cap yields the same map.
+		unlimited, errs := rebuild(s[0], s[1], 0)
+		if errs != nil {
+			t.Fatalf("[%s/%s] Unexpected errors: %v", s[0], s[1], errs)
+		}
+
+		nonBinding, _ := rebuild(s[0], s[1], 100)
+		if !reflect.DeepEqual(unlimited, nonBinding) {
+			t.Errorf("[%s/%s] Expected unlimited map %v, got %v", s[0], s[1], unlimited, nonBinding)
+		}
+
+		// 1003 and 1004 may each take two replicas.
+		out, errs := rebuild(s[0], s[1], 2)
+		if errs != nil {
+			t.Fatalf("[%s/%s] Unexpected errors: %v", s[0], s[1], errs)
+		}
+
+		// Replica sets may be shuffled.
+		counts := map[int]int{}
+		for _, p := range out.Partitions {
+			for _, id := range p.Replicas {
+				counts[id]++
+			}
+		}
+
+		if counts[1003] != 2 || counts[1004] != 2 {
+			t.Errorf("[%s/%s] Expected 2 replicas on 1003 and 1004, got %v", s[0], s[1], counts)
+		}
+
+		// Only two replicas can be placed.
+		out, errs = rebuild(s[0], s[1], 1)
+		if len(errs) != 2 {
+			t.Errorf("[%s/%s] Expected 2 errors, got %d", s[0], s[1], len(errs))
+		}
+
+		var short int
+		for _, p := range out.Partitions {
+			if len(p.Replicas) < 2 {
+				short++
+			}
+		}
+
+		if short != 2 {
+			t.Errorf("[%s/%s] Expected 2 partitions with unplaced replicas, got %d", s[0], s[1], short)
+		}
+	}
+}
+
+func TestRebuildController(t *testing.T) {
+	bm := BrokerMetaMap{
+		1001: &BrokerMeta{Rack: "a"},
+		1002: &BrokerMeta{Rack: "b"},
+		1003: &BrokerMeta{Rack: "c"},
+		1004: &BrokerMeta{Rack: "a"},
+		1005: &BrokerMeta{Rack: "b"},
+		1006: &BrokerMeta{Rack: "c"},
+	}
+
+	// p0 is already held by the controller;
+	// the remaining replicas are to be placed.
+	pm := NewPartitionMap()
+	for i := 0; i < 12; i++ {
+		r := []int{0, 0}
+		if i == 0 {
+			r = []int{1001, 0}
+		}
+		pm.Partitions = append(pm.Partitions, Partition{
+			Topic:     "test_topic",
+			Partition: i,
+			Replicas:  r,
+		})
+	}
+
+	placed := map[string]int{}
+
+	for _, policy := range []string{"", "deprioritize", "exclude"} {
+		brokers := BrokerMapFromPartitionMap(NewPartitionMap(), bm, false)
+		brokers.Update([]int{1001, 1002, 1003, 1004, 1005, 1006}, bm)
+
+		out, errs := pm.Copy().Rebuild(RebuildParams{
+			BM:               brokers,
+			Strategy:         "count",
+			Controller:       1001,
+			ControllerPolicy: policy,
+		})
+
+		if errs != nil {
+			t.Fatalf("[%s] Unexpected error: %s", policy, errs[0])
+		}
+
+		// The existing replica is retained.
+		if out.Partitions[0].Replicas[0] != 1001 {
+			t.Errorf("[%s] Expected p0 to retain broker 1001, got %v", policy, out.Partitions[0].Replicas)
+		}
+
+		for _, p := range out.Partitions[1:] {
+			for _, id := range p.Replicas {
+				if id == 1001 {
+					placed[policy]++
+				}
+			}
+		}
+	}
+
+	if placed["exclude"] != 0 {
+		t.Errorf("Expected no new replicas on the controller, got %d", placed["exclude"])
+	}
+
+	if placed["deprioritize"] >= placed[""] {
+		t.Errorf("Expected fewer new replicas on the controller when deprioritized (%d), got %d",
+			placed[""], placed["deprioritize"])
+	}
+
+	// Invalid policy.
+	brokers := BrokerMapFromPartitionMap(NewPartitionMap(), bm, false)
+	if _, errs := pm.Rebuild(RebuildParams{BM: brokers, Strategy: "count", ControllerPolicy: "avoid"}); errs == nil {
+		t.Error("Expected invalid controller policy error")
+	}
+}
+
+func TestRebuildRackWeighted(t *testing.T) {
+	// Racks of uneven sizes: a holds 5000 (5 brokers),
+	// b holds 4000 (2 brokers), c holds 3000 (1 broker).
+	bm := BrokerMetaMap{
+		1001: &BrokerMeta{Rack: "a", StorageFree: 1000},
+		1002: &BrokerMeta{Rack: "a", StorageFree: 1000},
+		1003: &BrokerMeta{Rack: "a", StorageFree: 1000},
+		1004: &BrokerMeta{Rack: "a", StorageFree: 1000},
+		1005: &BrokerMeta{Rack: "a", StorageFree: 1000},
+		1006: &BrokerMeta{Rack: "b", StorageFree: 2000},
+		1007: &BrokerMeta{Rack: "b", StorageFree: 2000},
+		1008: &BrokerMeta{Rack: "c", StorageFree: 3000},
+	}
+
+	ids := []int{1001, 1002, 1003, 1004, 1005, 1006, 1007, 1008}
+
+	// Stub partitions to be placed.
+	pm := NewPartitionMap()
+	for i := 0; i < 24; i++ {
+		pm.Partitions = append(pm.Partitions, Partition{
+			Topic:     "test_topic",
+			Partition: i,
+			Replicas:  []int{0, 0},
+		})
+	}
+
+	brokers := BrokerMapFromPartitionMap(NewPartitionMap(), bm, false)
+	brokers.Update(ids, bm)
+
+	out, errs := pm.Rebuild(RebuildParams{
+		BM:           brokers,
+		Strategy:     "count",
+		RackWeighted: true,
+	})
+
+	if errs != nil {
+		t.Fatalf("Unexpected error: %s", errs[0])
+	}
+
+	racks := map[string]int{}
+	for _, p := range out.Partitions {
+		seen := map[string]bool{}
+		for _, id := range p.Replicas {
+			r := bm[id].Rack
+			if seen[r] {
+				t.Errorf("p%d: replicas %v share rack %s", p.Partition, p.Replicas, r)
+			}
+			seen[r] = true
+			racks[r]++
+		}
+	}
+
+	// 48 replicas placed proportional
+	// to the capacity of each rack.
+	expected := map[string]int{"a": 20, "b": 16, "c": 12}
+
+	for r, n := range expected {
+		if d := racks[r] - n; d < -1 || d > 1 {
+			t.Errorf("Expected %d (+/-1) replicas in rack %s, got %d", n, r, racks[r])
+		}
+	}
+}
+
 func TestLocalitiesAvailable(t *testing.T) {
 	pm, _ := PartitionMapFromString(testGetMapString("test_topic"))
 	bm := newMockBrokerMap()
@@ -596,3 +1759,276 @@ func TestShuffle(t *testing.T) {
 		t.Errorf("Unexpected shuffle results")
 	}
 }
+
+func TestRebuildTopicSpread(t *testing.T) {
+	// p2 and p3 are held by 1001 and 1002. All brokers
+	// are equally used; p0 and p1 are to be placed.
+	pm := NewPartitionMap()
+	for i, r := range [][]int{{0}, {0}, {1001}, {1002}} {
+		pm.Partitions = append(pm.Partitions, Partition{
+			Topic:     "test_topic",
+			Partition: i,
+			Replicas:  r,
+		})
+	}
+
+	// held returns the number of partitions
+	// held by each broker following a rebuild.
+	held := func(spread bool, seed int64) map[int]int {
+		brokers := BrokerMap{
+			0:    &Broker{ID: 0, Replace: true},
+			1001: &Broker{ID: 1001, Used: 2},
+			1002: &Broker{ID: 1002, Used: 2},
+			1003: &Broker{ID: 1003, Used: 2},
+			1004: &Broker{ID: 1004, Used: 2},
+		}
+
+		out, errs := pm.Copy().Rebuild(RebuildParams{
+			BM:          brokers,
+			Strategy:    "count",
+			TopicSpread: spread,
+			Seed:        seed,
+		})
+
+		if errs != nil {
+			t.Fatalf("[spread: %t] Unexpected error: %s", spread, errs[0])
+		}
+
+		h := map[int]int{}
+		for _, p := range out.Partitions {
+			h[p.Replicas[0]]++
+		}
+
+		return h
+	}
+
+	var uneven int
+
+	for seed := int64(0); seed < 16; seed++ {
+		// By count alone, the choice among equally used
+		// brokers is pseudo-random and may land on a
+		// broker already holding a partition.
+		for _, n := range held(false, seed) {
+			if n > 1 {
+				uneven++
+				break
+			}
+		}
+
+		// With spread, equally used brokers holding
+		// none of the topic are preferred.
+		for id, n := range held(true, seed) {
+			if n != 1 {
+				t.Errorf("[seed %d] Expected broker %d to hold 1 partition with spread, got %d", seed, id, n)
+			}
+		}
+	}
+
+	if uneven == 0 {
+		t.Error("Expected an uneven placement without spread for at least one seed")
+	}
+}
+
+func TestSetObservers(t *testing.T) {
+	// 1001 and 1004 are in rack a.
+	bm := newMockBrokerMap()
+
+	type testCase struct {
+		replicas  []int
+		observers int
+		// Expected.
+		outReplicas  []int
+		outObservers []int
+	}
+
+	tests := map[int]testCase{
+		// All racks distinct; the trailing replica.
+		0: {[]int{1001, 1002, 1003}, 1, []int{1001, 1002, 1003}, []int{1003}},
+		// The trailing replica shares rack a with the
+		// leader; 1002 in rack b is preferred.
+		1: {[]int{1001, 1002, 1004}, 1, []int{1001, 1004, 1002}, []int{1002}},
+		// Two observers.
+		2: {[]int{1001, 1002, 1003, 1004}, 2, []int{1001, 1004, 1002, 1003}, []int{1002, 1003}},
+		// Unknown brokers default to the trailing replica.
+		3: {[]int{1010, 1011}, 1, []int{1010, 1011}, []int{1011}},
+		// Cleared.
+		4: {[]int{1001, 1002}, 0, []int{1001, 1002}, nil},
+	}
+
+	for i, test := range tests {
+		pm := NewPartitionMap()
+		pm.Partitions = PartitionList{
+			Partition{Topic: "test_topic", Partition: 0, Replicas: test.replicas, Observers: []int{1002}},
+		}
+
+		if err := pm.SetObservers(test.observers, bm); err != nil {
+			t.Errorf("[test %d] Unexpected error: %s", i, err)
+			continue
+		}
+
+		p := pm.Partitions[0]
+
+		if !reflect.DeepEqual(p.Replicas, test.outReplicas) {
+			t.Errorf("[test %d] Expected replicas %v, got %v", i, test.outReplicas, p.Replicas)
+		}
+
+		if !reflect.DeepEqual(p.Observers, test.outObservers) {
+			t.Errorf("[test %d] Expected observers %v, got %v", i, test.outObservers, p.Observers)
+		}
+	}
+
+	// A replica set must retain a synchronous replica.
+	pm, _ := PartitionMapFromString(testGetMapString("test_topic"))
+	orig := pm.Copy()
+
+	if err := pm.SetObservers(2, bm); err == nil {
+		t.Error("Expected error")
+	}
+
+	if !reflect.DeepEqual(pm, orig) {
+		t.Error("Expected unmodified PartitionMap")
+	}
+}
+
+func TestRebuildObservers(t *testing.T) {
+	bm := BrokerMetaMap{
+		1001: &BrokerMeta{Rack: "a"},
+		1002: &BrokerMeta{Rack: "b"},
+		1003: &BrokerMeta{Rack: "c"},
+		1004: &BrokerMeta{Rack: "a"},
+		1005: &BrokerMeta{Rack: "b"},
+		1006: &BrokerMeta{Rack: "c"},
+	}
+
+	// Replication is increased to 3 with stub brokers.
+	pm, _ := PartitionMapFromString(`{"version":1,"partitions":[
+    {"topic":"test_topic","partition":0,"replicas":[1001,1002,0]},
+    {"topic":"test_topic","partition":1,"replicas":[1002,1003,0]},
+    {"topic":"test_topic","partition":2,"replicas":[1003,1001,0]},
+    {"topic":"test_topic","partition":3,"replicas":[1004,1005,0]}]}`)
+
+	brokers := BrokerMapFromPartitionMap(pm, bm, false)
+	brokers.Update([]int{1001, 1002, 1003, 1004, 1005, 1006}, bm)
+
+	params := NewRebuildParams()
+	params.BM = brokers
+	params.Strategy = "count"
+
+	out, errs := pm.Rebuild(params)
+	if len(errs) > 0 {
+		t.Fatalf("Unexpected errors: %v", errs)
+	}
+
+	if err := out.SetObservers(1, brokers); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	for _, p := range out.Partitions {
+		if len(p.Observers) != 1 || p.Replicas[2] != p.Observers[0] {
+			t.Errorf("Expected the trailing replica of %v designated as the observer, got %v", p.Replicas, p.Observers)
+			continue
+		}
+
+		racks := map[string]bool{}
+		for _, id := range p.Replicas[:2] {
+			racks[brokers[id].Locality] = true
+		}
+
+		if rack := brokers[p.Observers[0]].Locality; racks[rack] {
+			t.Errorf("Observer %d shares rack %s with the synchronous replicas %v", p.Observers[0], rack, p.Replicas[:2])
+		}
+	}
+
+	// The observers are emitted in the plan.
+	b, _ := json.Marshal(out.Partitions[0])
+	if !strings.Contains(string(b), `"observers":[`) {
+		t.Errorf("Expected observers in the partition JSON, got %s", b)
+	}
+}
+
+func TestObserverRackConflicts(t *testing.T) {
+	// 1001 and 1004 are in rack a.
+	bm := newMockBrokerMap()
+
+	pm, _ := PartitionMapFromString(`{"version":1,"partitions":[
+    {"topic":"test_topic","partition":0,"replicas":[1001,1002,1003]},
+    {"topic":"test_topic","partition":1,"replicas":[1001,1004]},
+    {"topic":"test_topic","partition":2,"replicas":[1004,1001,1002]}]}`)
+
+	if err := pm.SetObservers(1, bm); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	// p0 observer 1003 and p2 observer 1002 are in
+	// racks remote to the synchronous replicas. The
+	// only p1 observer candidate shares rack a with
+	// the leader.
+	expected := []string{"test_topic p1: observer 1004 shares rack a with a synchronous replica"}
+	if c := pm.ObserverRackConflicts(bm); !reflect.DeepEqual(c, expected) {
+		t.Errorf("Expected conflicts %v, got %v", expected, c)
+	}
+}
+
+func TestRemapBrokers(t *testing.T) {
+	// Full remap.
+	pm, _ := PartitionMapFromString(testGetMapString("test_topic"))
+
+	unmapped, err := pm.RemapBrokers(map[int]int{1001: 2001, 1002: 2002, 1003: 2003, 1004: 2004})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if len(unmapped) != 0 {
+		t.Errorf("Expected no unmapped IDs, got %v", unmapped)
+	}
+
+	expected := [][]int{
+		{2001, 2002},
+		{2002, 2001},
+		{2003, 2004, 2001},
+		{2004, 2003, 2002},
+	}
+
+	for i, p := range pm.Partitions {
+		if !sameIDs(p.Replicas, expected[i]) {
+			t.Errorf("Expected replicas %v, got %v", expected[i], p.Replicas)
+		}
+	}
+
+	// Partial remap.
+	pm, _ = PartitionMapFromString(testGetMapString("test_topic"))
+
+	unmapped, err = pm.RemapBrokers(map[int]int{1001: 2001, 1002: 2002})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if !sameIDs(unmapped, []int{1003, 1004}) {
+		t.Errorf("Expected unmapped IDs [1003 1004], got %v", unmapped)
+	}
+
+	expected = [][]int{
+		{2001, 2002},
+		{2002, 2001},
+		{1003, 1004, 2001},
+		{1004, 1003, 2002},
+	}
+
+	for i, p := range pm.Partitions {
+		if !sameIDs(p.Replicas, expected[i]) {
+			t.Errorf("Expected replicas %v, got %v", expected[i], p.Replicas)
+		}
+	}
+
+	// A remap resulting in duplicate replicas.
+	pm, _ = PartitionMapFromString(testGetMapString("test_topic"))
+	orig := pm.Copy()
+
+	if _, err = pm.RemapBrokers(map[int]int{1001: 1002}); err == nil {
+		t.Error("Expected duplicate replica error")
+	}
+
+	if same, _ := pm.equal(orig); !same {
+		t.Error("Expected partition map to be unmodified after error")
+	}
+}
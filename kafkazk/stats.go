@@ -186,6 +186,197 @@ func (b BrokerMap) StorageStdDev() float64 {
 	return math.Sqrt(msq)
 }
 
+// StorageSummary returns the min, max, mean and standard deviation of free
+// storage for all brokers in the BrokerMap, excluding those marked as Missing.
+// This is a superset of StorageRange, which returns only the max-min range.
+// All values are 0 if the BrokerMap holds no such brokers.
+func (b BrokerMap) StorageSummary() (min, max, mean, stddev float64) {
+	var v []float64
+
+	for id, br := range b {
+		if id == 0 || br.Missing {
+			continue
+		}
+		v = append(v, br.StorageFree)
+	}
+
+	if len(v) == 0 {
+		return 0, 0, 0, 0
+	}
+
+	min, max = v[0], v[0]
+
+	var t float64
+	for _, x := range v {
+		if x < min {
+			min = x
+		}
+		if x > max {
+			max = x
+		}
+		t += x
+	}
+
+	mean = t / float64(len(v))
+
+	var s float64
+	for _, x := range v {
+		s += math.Pow(x-mean, 2)
+	}
+
+	stddev = math.Sqrt(s / float64(len(v)))
+
+	return min, max, mean, stddev
+}
+
+// LeadershipSkew returns the minimum and maximum leader counts across all
+// brokers in the BrokerUseStatsList along with the skew, the max-min range
+// normalized by the mean leader count. A skew of 0 indicates leadership is
+// evenly distributed. All values are 0 if the list is empty or no broker
+// leads any partitions.
+func (b BrokerUseStatsList) LeadershipSkew() (min, max int, skew float64) {
+	if len(b) == 0 {
+		return 0, 0, 0
+	}
+
+	min, max = b[0].Leader, b[0].Leader
+
+	var t int
+	for _, s := range b {
+		if s.Leader < min {
+			min = s.Leader
+		}
+		if s.Leader > max {
+			max = s.Leader
+		}
+		t += s.Leader
+	}
+
+	if t == 0 {
+		return min, max, 0
+	}
+
+	mean := float64(t) / float64(len(b))
+	skew = float64(max-min) / mean
+
+	return min, max, skew
+}
+
+// Gini returns the Gini coefficient of the values in v; a measure
+// of inequality from 0 (all values are equal) to 1 (a single value
+// holds the total). For n values, the maximum possible is (n-1)/n.
+func Gini(v []float64) float64 {
+	s := make([]float64, len(v))
+	copy(s, v)
+	sort.Float64s(s)
+
+	var t, w float64
+	for i, x := range s {
+		t += x
+		w += float64(i+1) * x
+	}
+
+	if t == 0 {
+		return 0
+	}
+
+	n := float64(len(s))
+
+	return 2*w/(n*t) - (n+1)/n
+}
+
+// StorageGini returns the Gini coefficient of free
+// storage for all brokers in the BrokerMap.
+func (b BrokerMap) StorageGini() float64 {
+	var v []float64
+	for id, br := range b {
+		if id == 0 {
+			continue
+		}
+		v = append(v, br.StorageFree)
+	}
+
+	return Gini(v)
+}
+
+// CountGini returns the Gini coefficient of partition replica counts
+// for the broker IDs in ids. Brokers holding no replicas in the
+// PartitionMap are counted as 0. If ids is nil, all brokers
+// referenced in the PartitionMap are used.
+func (pm *PartitionMap) CountGini(ids []int) float64 {
+	counts := map[int]float64{}
+	for _, p := range pm.Partitions {
+		for _, id := range p.Replicas {
+			counts[id]++
+		}
+	}
+
+	if ids == nil {
+		for id := range counts {
+			ids = append(ids, id)
+		}
+	}
+
+	var v []float64
+	for _, id := range ids {
+		v = append(v, counts[id])
+	}
+
+	return Gini(v)
+}
+
+// LeaderBytes takes a PartitionMetaMap and returns the sum of partition
+// sizes led by each broker referenced in the PartitionMap. Brokers
+// holding no leaderships are included with a value of 0.
+func (pm *PartitionMap) LeaderBytes(pmm PartitionMetaMap) (map[int]float64, error) {
+	lb := map[int]float64{}
+
+	for _, p := range pm.Partitions {
+		for _, id := range p.Replicas {
+			if _, exists := lb[id]; !exists {
+				lb[id] = 0
+			}
+		}
+
+		if len(p.Replicas) == 0 {
+			continue
+		}
+
+		s, err := pmm.Size(p)
+		if err != nil {
+			return nil, err
+		}
+
+		lb[p.Replicas[0]] += s
+	}
+
+	return lb, nil
+}
+
+// LeaderBytesStdDev returns the standard deviation of
+// leader bytes across all brokers in the PartitionMap.
+func (pm *PartitionMap) LeaderBytesStdDev(pmm PartitionMetaMap) (float64, error) {
+	lb, err := pm.LeaderBytes(pmm)
+	if err != nil || len(lb) == 0 {
+		return 0, err
+	}
+
+	var t float64
+	for _, v := range lb {
+		t += v
+	}
+
+	l := float64(len(lb))
+	m := t / l
+
+	var s float64
+	for _, v := range lb {
+		s += math.Pow(m-v, 2)
+	}
+
+	return math.Sqrt(s / l), nil
+}
+
 // HMean returns the harmonic mean of broker storage free.
 func (b BrokerMap) HMean() float64 {
 	var t float64
@@ -265,3 +456,74 @@ func (b BrokerMap) BelowMean(d float64, f func() float64) []int {
 
 	return ids
 }
+
+// PlacementStats holds counters describing the work performed
+// by placement algorithms in selecting candidate brokers.
+type PlacementStats struct {
+	// Placements is the number of candidate selections performed.
+	Placements int
+	// Failures is the number of selections where
+	// no candidate satisfied the constraints.
+	Failures int
+	// Rejections is the number of candidates
+	// that failed to satisfy the constraints.
+	Rejections int
+	// Backtracks is the number of successful selections
+	// where the most preferred candidates were rejected,
+	// falling back to a lower ranked candidate.
+	Backtracks int
+	// Candidates is the sum of the candidate set
+	// sizes for all selections.
+	Candidates int
+	// CandidateSizes is a histogram of candidate
+	// set sizes to the number of selections.
+	CandidateSizes map[int]int
+}
+
+// NewPlacementStats returns a new *PlacementStats.
+func NewPlacementStats() *PlacementStats {
+	return &PlacementStats{
+		CandidateSizes: make(map[int]int),
+	}
+}
+
+// AvgCandidates returns the average candidate set size per selection.
+func (s *PlacementStats) AvgCandidates() float64 {
+	if s.Placements == 0 {
+		return 0
+	}
+
+	return float64(s.Candidates) / float64(s.Placements)
+}
+
+// record takes a candidate BrokerList, the number of candidates rejected
+// and whether the selection failed, and records the selection. It's a no-op
+// for a nil *PlacementStats.
+func (s *PlacementStats) record(bl BrokerList, rejected int, failed bool) {
+	if s == nil {
+		return
+	}
+
+	var size int
+	for _, b := range bl {
+		if b.ID != 0 {
+			size++
+		}
+	}
+
+	s.Placements++
+	s.Rejections += rejected
+	s.Candidates += size
+
+	if s.CandidateSizes == nil {
+		s.CandidateSizes = make(map[int]int)
+	}
+	s.CandidateSizes[size]++
+
+	switch {
+	case failed:
+		s.Failures++
+	case rejected > 0:
+		s.Backtracks++
+	}
+}
@@ -126,6 +126,131 @@ func TestBrokerMapStorageStdDev(t *testing.T) {
 	}
 }
 
+func TestBrokerMapStorageSummary(t *testing.T) {
+	bm := BrokerMap{
+		0:    &Broker{ID: 0, StorageFree: 0.00},
+		1001: &Broker{ID: 1001, StorageFree: 200.00},
+		1002: &Broker{ID: 1002, StorageFree: 400.00},
+		1003: &Broker{ID: 1003, StorageFree: 400.00},
+		1004: &Broker{ID: 1004, StorageFree: 400.00},
+		1005: &Broker{ID: 1005, StorageFree: 500.00},
+		1006: &Broker{ID: 1006, StorageFree: 500.00},
+		1007: &Broker{ID: 1007, StorageFree: 700.00},
+		1008: &Broker{ID: 1008, StorageFree: 900.00},
+		// Missing brokers are excluded.
+		1009: &Broker{ID: 1009, StorageFree: 10000.00, Missing: true},
+	}
+
+	min, max, mean, sd := bm.StorageSummary()
+
+	expected := [4]float64{200.00, 900.00, 500.00, 200.00}
+	got := [4]float64{min, max, mean, sd}
+
+	if got != expected {
+		t.Errorf("Expected min/max/mean/stddev %v, got %v", expected, got)
+	}
+
+	// Empty BrokerMap.
+	min, max, mean, sd = BrokerMap{}.StorageSummary()
+	if min != 0 || max != 0 || mean != 0 || sd != 0 {
+		t.Errorf("Expected all zero values, got %f/%f/%f/%f", min, max, mean, sd)
+	}
+}
+
+func TestLeadershipSkew(t *testing.T) {
+	// Balanced.
+	balanced := BrokerUseStatsList{
+		&BrokerUseStats{ID: 1001, Leader: 4, Follower: 2},
+		&BrokerUseStats{ID: 1002, Leader: 4, Follower: 8},
+		&BrokerUseStats{ID: 1003, Leader: 4},
+	}
+
+	min, max, skew := balanced.LeadershipSkew()
+	if min != 4 || max != 4 || skew != 0 {
+		t.Errorf("Expected min 4, max 4, skew 0, got %d, %d, %f", min, max, skew)
+	}
+
+	// Skewed; mean of 4.
+	skewed := BrokerUseStatsList{
+		&BrokerUseStats{ID: 1001, Leader: 8},
+		&BrokerUseStats{ID: 1002, Leader: 1},
+		&BrokerUseStats{ID: 1003, Leader: 3},
+	}
+
+	min, max, skew = skewed.LeadershipSkew()
+	if min != 1 || max != 8 || skew != 1.75 {
+		t.Errorf("Expected min 1, max 8, skew 1.75, got %d, %d, %f", min, max, skew)
+	}
+
+	// No leaders.
+	followers := BrokerUseStatsList{
+		&BrokerUseStats{ID: 1001, Follower: 2},
+		&BrokerUseStats{ID: 1002, Follower: 2},
+	}
+
+	min, max, skew = followers.LeadershipSkew()
+	if min != 0 || max != 0 || skew != 0 {
+		t.Errorf("Expected zero values, got %d, %d, %f", min, max, skew)
+	}
+
+	// Empty.
+	min, max, skew = BrokerUseStatsList{}.LeadershipSkew()
+	if min != 0 || max != 0 || skew != 0 {
+		t.Errorf("Expected zero values, got %d, %d, %f", min, max, skew)
+	}
+}
+
+func TestGini(t *testing.T) {
+	// Perfectly even.
+	if g := Gini([]float64{10, 10, 10, 10}); g != 0 {
+		t.Errorf("Expected Gini coefficient 0, got %f", g)
+	}
+
+	// Highly skewed; a single value of 100
+	// holds the total, the max being 0.99.
+	skewed := make([]float64, 100)
+	skewed[42] = 100
+
+	if g := Gini(skewed); g < 0.989 || g > 0.991 {
+		t.Errorf("Expected Gini coefficient 0.99, got %f", g)
+	}
+
+	// Moderate skew should fall within the bounds.
+	if g := Gini([]float64{1, 2, 3, 4}); g != 0.25 {
+		t.Errorf("Expected Gini coefficient 0.25, got %f", g)
+	}
+
+	// No values or all zeros.
+	if Gini(nil) != 0 || Gini([]float64{0, 0}) != 0 {
+		t.Error("Expected Gini coefficient 0 for empty input")
+	}
+}
+
+func TestCountGini(t *testing.T) {
+	pm, _ := PartitionMapFromString(`{"version":1,"partitions":[
+    {"topic":"test_topic","partition":0,"replicas":[1001,1002]},
+    {"topic":"test_topic","partition":1,"replicas":[1002,1001]}]}`)
+
+	// Even among mapped brokers.
+	if g := pm.CountGini(nil); g != 0 {
+		t.Errorf("Expected Gini coefficient 0, got %f", g)
+	}
+
+	// Brokers 1003 and 1004 hold nothing.
+	if g := pm.CountGini([]int{1001, 1002, 1003, 1004}); g != 0.5 {
+		t.Errorf("Expected Gini coefficient 0.5, got %f", g)
+	}
+}
+
+func TestStorageGini(t *testing.T) {
+	bm := newMockBrokerMap()
+
+	// 100, 200, 300, 400; ID 0 is excluded.
+	if g := bm.StorageGini(); g != 0.25 {
+		t.Errorf("Expected Gini coefficient 0.25, got %f", g)
+	}
+}
+
 func TestBrokerListSort(t *testing.T) {
 	b := newMockBrokerMap()
 	bl := b.Filter(func(b *Broker) bool { return true }).List()
@@ -241,3 +366,28 @@ func sameIDs(a, b []int) bool {
 
 	return true
 }
+
+func TestLeaderBytes(t *testing.T) {
+	pm, _ := PartitionMapFromString(testGetMapString("test_topic"))
+
+	zk := &Mock{}
+	pmm, _ := zk.GetAllPartitionMeta()
+
+	lb, err := pm.LeaderBytes(pmm)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	expected := map[int]float64{1001: 1000, 1002: 1500, 1003: 2000, 1004: 2500}
+
+	for id, v := range expected {
+		if lb[id] != v {
+			t.Errorf("Expected %.0f leader bytes for broker %d, got %.0f", v, id, lb[id])
+		}
+	}
+
+	sd, _ := pm.LeaderBytesStdDev(pmm)
+	if e := 559.02; math.Abs(sd-e) > 0.01 {
+		t.Errorf("Expected std. deviation %.2f, got %.2f", e, sd)
+	}
+}
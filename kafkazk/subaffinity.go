@@ -44,6 +44,10 @@ func (b BrokerMap) SubstitutionAffinities(pm *PartitionMap) (SubstitutionAffinit
 			continue
 		case broker.Missing:
 			missing[broker] = struct{}{}
+		case broker.Excluded:
+			// Excluded brokers aren't being replaced; their
+			// replicas are placed by the standard selector.
+			continue
 		case broker.Replace:
 			replace[broker] = struct{}{}
 		case broker.New:
@@ -92,6 +92,19 @@ func TestSubstitutionAffinities(t *testing.T) {
 	if err.Error() != expected {
 		t.Errorf("Expected error '%s', got %s", expected, err)
 	}
+
+	// Excluded brokers aren't
+	// given an affinity.
+	bm[1002].Excluded = true
+
+	sa, err = bm.SubstitutionAffinities(pm)
+	if err != nil {
+		t.Errorf("Unexpected error: %s", err)
+	}
+
+	if _, exists := sa[1002]; exists {
+		t.Error("Unexpected substitution affinity for excluded broker 1002")
+	}
 }
 
 func TestSubstitutionAffinitiesInferred(t *testing.T) {
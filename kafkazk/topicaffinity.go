@@ -0,0 +1,187 @@
+package kafkazk
+
+import (
+	"fmt"
+	"sort"
+)
+
+// TopicAntiAffinity describes groups of topics whose replicas should avoid
+// sharing brokers, such as critical topics that shouldn't share a failure
+// domain. It applies to new replica placements made by Rebuild; existing
+// replicas are retained regardless.
+type TopicAntiAffinity struct {
+	// Groups of topic names. A replica of a topic isn't placed on a
+	// broker holding replicas of any other topic in a shared group.
+	Groups [][]string
+	// Soft makes the groups best-effort: brokers holding replicas
+	// of conflicting topics are the least preferred candidates
+	// rather than ineligible.
+	Soft bool
+	// Existing, if non-nil, holds the assignments of topics that aren't
+	// being rebuilt, such as other topics in the Groups. Partitions of
+	// topics in the PartitionMap being rebuilt are ignored.
+	Existing *PartitionMap
+}
+
+// TopicAntiAffinityViolation describes a broker holding
+// replicas of topics that share an anti-affinity group.
+type TopicAntiAffinityViolation struct {
+	Broker int
+	Topics []string
+}
+
+// Validate returns an error if any group
+// has fewer than two distinct topics.
+func (a *TopicAntiAffinity) Validate() error {
+	for i, g := range a.Groups {
+		topics := map[string]bool{}
+		for _, t := range g {
+			if t == "" {
+				return fmt.Errorf("Topic anti-affinity group %d has an empty topic name", i+1)
+			}
+			topics[t] = true
+		}
+
+		if len(topics) < 2 {
+			return fmt.Errorf("Topic anti-affinity group %d must have at least two distinct topics", i+1)
+		}
+	}
+
+	return nil
+}
+
+// conflicts returns a map of each topic in the
+// groups to the other topics it shares a group with.
+func (a *TopicAntiAffinity) conflicts() map[string]map[string]bool {
+	conflicts := map[string]map[string]bool{}
+
+	for _, g := range a.Groups {
+		for _, t := range g {
+			if _, exists := conflicts[t]; !exists {
+				conflicts[t] = map[string]bool{}
+			}
+
+			for _, other := range g {
+				if other != t {
+					conflicts[t][other] = true
+				}
+			}
+		}
+	}
+
+	return conflicts
+}
+
+// Violations takes a PartitionMap and returns a TopicAntiAffinityViolation
+// for each broker holding replicas of topics that share a group, including
+// the Existing assignments of topics not in the PartitionMap. Violations
+// are ordered by broker ID and list the conflicting topics held.
+func (a *TopicAntiAffinity) Violations(pm *PartitionMap) []TopicAntiAffinityViolation {
+	tp := a.placements(pm, nil)
+
+	var ids []int
+	for id := range tp.held {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	var violations []TopicAntiAffinityViolation
+
+	for _, id := range ids {
+		var topics []string
+		for t := range tp.held[id] {
+			if len(tp.conflicting(id, t)) > 0 {
+				topics = append(topics, t)
+			}
+		}
+
+		if len(topics) > 0 {
+			sort.Strings(topics)
+			violations = append(violations, TopicAntiAffinityViolation{Broker: id, Topics: topics})
+		}
+	}
+
+	return violations
+}
+
+// topicPlacements tracks the topics held by each broker
+// for applying a TopicAntiAffinity to placements.
+type topicPlacements struct {
+	conflicts map[string]map[string]bool
+	// Replica counts by broker ID and topic.
+	held map[int]map[string]int
+	soft bool
+}
+
+// placements returns a *topicPlacements seeded with the replicas in the
+// PartitionMap, excluding those on brokers marked for replacement in the
+// BrokerMap, and the Existing assignments of topics not in the PartitionMap.
+// A nil *TopicAntiAffinity returns nil.
+func (a *TopicAntiAffinity) placements(pm *PartitionMap, bm BrokerMap) *topicPlacements {
+	if a == nil {
+		return nil
+	}
+
+	tp := &topicPlacements{
+		conflicts: a.conflicts(),
+		held:      map[int]map[string]int{},
+		soft:      a.Soft,
+	}
+
+	rebuilt := map[string]bool{}
+	for _, p := range pm.Partitions {
+		rebuilt[p.Topic] = true
+		for _, id := range p.Replicas {
+			if b, exists := bm[id]; exists && b.Replace {
+				continue
+			}
+			tp.add(id, p.Topic)
+		}
+	}
+
+	if a.Existing != nil {
+		for _, p := range a.Existing.Partitions {
+			if rebuilt[p.Topic] {
+				continue
+			}
+			for _, id := range p.Replicas {
+				tp.add(id, p.Topic)
+			}
+		}
+	}
+
+	return tp
+}
+
+// add records a replica of the topic on the broker ID.
+// Stub brokers (ID 0) are ignored.
+func (t *topicPlacements) add(id int, topic string) {
+	if t == nil || id == 0 {
+		return
+	}
+
+	if _, exists := t.held[id]; !exists {
+		t.held[id] = map[string]int{}
+	}
+
+	t.held[id][topic]++
+}
+
+// conflicting returns the topics held by the broker
+// ID that share an anti-affinity group with the topic.
+func (t *topicPlacements) conflicting(id int, topic string) []string {
+	if t == nil {
+		return nil
+	}
+
+	var topics []string
+	for other := range t.conflicts[topic] {
+		if t.held[id][other] > 0 {
+			topics = append(topics, other)
+		}
+	}
+
+	sort.Strings(topics)
+
+	return topics
+}
@@ -0,0 +1,207 @@
+package kafkazk
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func testTopicAffinityMaps() (*PartitionMap, *PartitionMap) {
+	existing, _ := PartitionMapFromString(`{"version":1,"partitions":[
+    {"topic":"topic_a","partition":0,"replicas":[1001,1002]},
+    {"topic":"topic_a","partition":1,"replicas":[1002,1001]}]}`)
+
+	pm, _ := PartitionMapFromString(`{"version":1,"partitions":[
+    {"topic":"topic_b","partition":0,"replicas":[1005,1003]},
+    {"topic":"topic_b","partition":1,"replicas":[1005,1004]}]}`)
+
+	return existing, pm
+}
+
+// testTopicAffinityBrokers returns a BrokerMap where 1001 and
+// 1002 are the least used and 1005 is marked for replacement.
+func testTopicAffinityBrokers() BrokerMap {
+	return BrokerMap{
+		0:    &Broker{ID: 0, Replace: true},
+		1001: &Broker{ID: 1001, Used: 0},
+		1002: &Broker{ID: 1002, Used: 0},
+		1003: &Broker{ID: 1003, Used: 5},
+		1004: &Broker{ID: 1004, Used: 5},
+		1005: &Broker{ID: 1005, Used: 2, Replace: true},
+	}
+}
+
+func TestRebuildTopicAntiAffinity(t *testing.T) {
+	existing, pm := testTopicAffinityMaps()
+
+	// Without anti-affinity, the least used brokers are selected.
+	params := NewRebuildParams()
+	params.BM = testTopicAffinityBrokers()
+	params.Strategy = "count"
+
+	out, errs := pm.Copy().Rebuild(params)
+	if len(errs) > 0 {
+		t.Fatalf("Unexpected error(s): %v", errs)
+	}
+
+	for _, p := range out.Partitions {
+		if id := p.Replicas[0]; id != 1001 && id != 1002 {
+			t.Errorf("Expected %s p%d leader on 1001 or 1002, got %v", p.Topic, p.Partition, p.Replicas)
+		}
+	}
+
+	// With anti-affinity, brokers holding topic_a are ineligible.
+	params.BM = testTopicAffinityBrokers()
+	params.TopicAntiAffinity = &TopicAntiAffinity{
+		Groups:   [][]string{{"topic_a", "topic_b"}},
+		Existing: existing,
+	}
+
+	out, errs = pm.Copy().Rebuild(params)
+	if len(errs) > 0 {
+		t.Fatalf("Unexpected error(s): %v", errs)
+	}
+
+	expected := [][]int{{1004, 1003}, {1003, 1004}}
+	for i, p := range out.Partitions {
+		if !reflect.DeepEqual(p.Replicas, expected[i]) {
+			t.Errorf("Expected %s p%d replicas %v, got %v", p.Topic, p.Partition, expected[i], p.Replicas)
+		}
+	}
+
+	if v := params.TopicAntiAffinity.Violations(out); len(v) != 0 {
+		t.Errorf("Expected no violations, got %v", v)
+	}
+}
+
+func TestRebuildTopicAntiAffinityInfeasible(t *testing.T) {
+	existing, pm := testTopicAffinityMaps()
+
+	// Only brokers holding topic_a are available
+	// for the topic_b p0 replacement.
+	bm := testTopicAffinityBrokers()
+	delete(bm, 1004)
+	pm.Partitions = pm.Partitions[:1]
+
+	params := NewRebuildParams()
+	params.BM = bm.Copy()
+	params.Strategy = "count"
+	params.TopicAntiAffinity = &TopicAntiAffinity{
+		Groups:   [][]string{{"topic_a", "topic_b"}},
+		Existing: existing,
+	}
+
+	_, errs := pm.Copy().Rebuild(params)
+	if len(errs) != 1 {
+		t.Fatalf("Expected 1 error, got %v", errs)
+	}
+
+	if !strings.Contains(errs[0].Error(), ErrTopicAntiAffinity.Error()) || !strings.Contains(errs[0].Error(), "held: topic_a") {
+		t.Errorf("Expected topic anti-affinity error, got '%s'", errs[0])
+	}
+
+	// Soft anti-affinity falls back to the conflicting brokers.
+	params.BM = bm.Copy()
+	params.TopicAntiAffinity.Soft = true
+
+	out, errs := pm.Copy().Rebuild(params)
+	if len(errs) > 0 {
+		t.Fatalf("Unexpected error(s): %v", errs)
+	}
+
+	if id := out.Partitions[0].Replicas[0]; id != 1001 && id != 1002 {
+		t.Errorf("Expected replacement 1001 or 1002, got %v", out.Partitions[0].Replicas)
+	}
+
+	v := params.TopicAntiAffinity.Violations(out)
+	if len(v) != 1 || !reflect.DeepEqual(v[0].Topics, []string{"topic_a", "topic_b"}) {
+		t.Errorf("Expected a violation of topic_a and topic_b, got %v", v)
+	}
+}
+
+func TestRebuildTopicAntiAffinitySoftPreference(t *testing.T) {
+	existing, pm := testTopicAffinityMaps()
+
+	// Soft anti-affinity prefers non-conflicting brokers
+	// regardless of the placement strategy ordering.
+	params := NewRebuildParams()
+	params.BM = testTopicAffinityBrokers()
+	params.Strategy = "count"
+	params.TopicAntiAffinity = &TopicAntiAffinity{
+		Groups:   [][]string{{"topic_a", "topic_b"}},
+		Soft:     true,
+		Existing: existing,
+	}
+
+	out, errs := pm.Copy().Rebuild(params)
+	if len(errs) > 0 {
+		t.Fatalf("Unexpected error(s): %v", errs)
+	}
+
+	if v := params.TopicAntiAffinity.Violations(out); len(v) != 0 {
+		t.Errorf("Expected no violations, got %v", v)
+	}
+}
+
+func TestTopicAntiAffinityViolations(t *testing.T) {
+	existing, pm := testTopicAffinityMaps()
+
+	a := &TopicAntiAffinity{
+		Groups:   [][]string{{"topic_a", "topic_b"}, {"topic_b", "topic_c"}},
+		Existing: existing,
+	}
+
+	pm.Partitions = append(pm.Partitions,
+		Partition{Topic: "topic_b", Partition: 2, Replicas: []int{1001, 1003}},
+		Partition{Topic: "topic_c", Partition: 0, Replicas: []int{1003, 1006}},
+		// Not in a shared group with topic_a.
+		Partition{Topic: "topic_c", Partition: 1, Replicas: []int{1002, 1006}},
+	)
+
+	expected := []TopicAntiAffinityViolation{
+		{Broker: 1001, Topics: []string{"topic_a", "topic_b"}},
+		{Broker: 1003, Topics: []string{"topic_b", "topic_c"}},
+	}
+
+	if v := a.Violations(pm); !reflect.DeepEqual(v, expected) {
+		t.Errorf("Expected violations %v, got %v", expected, v)
+	}
+
+	// Existing assignments of rebuilt topics are ignored.
+	a.Existing.Partitions = append(a.Existing.Partitions,
+		Partition{Topic: "topic_b", Partition: 0, Replicas: []int{1002}})
+
+	if v := a.Violations(pm); !reflect.DeepEqual(v, expected) {
+		t.Errorf("Expected violations %v, got %v", expected, v)
+	}
+}
+
+func TestTopicAntiAffinityValidate(t *testing.T) {
+	tests := map[int]struct {
+		groups [][]string
+		valid  bool
+	}{
+		0: {groups: [][]string{{"a", "b"}, {"b", "c", "d"}}, valid: true},
+		1: {groups: [][]string{{"a"}}, valid: false},
+		2: {groups: [][]string{{"a", "a"}}, valid: false},
+		3: {groups: [][]string{{"a", ""}}, valid: false},
+	}
+
+	for i, test := range tests {
+		a := &TopicAntiAffinity{Groups: test.groups}
+		if err := a.Validate(); (err == nil) != test.valid {
+			t.Errorf("[test %d] Expected valid=%v, got error: %v", i, test.valid, err)
+		}
+	}
+
+	// Rebuild validates the groups.
+	_, pm := testTopicAffinityMaps()
+	params := NewRebuildParams()
+	params.BM = testTopicAffinityBrokers()
+	params.Strategy = "count"
+	params.TopicAntiAffinity = &TopicAntiAffinity{Groups: [][]string{{"topic_b"}}}
+
+	if _, errs := pm.Rebuild(params); len(errs) != 1 {
+		t.Errorf("Expected 1 error, got %v", errs)
+	}
+}
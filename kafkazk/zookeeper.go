@@ -1,6 +1,7 @@
 package kafkazk
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -8,6 +9,7 @@ import (
 	"regexp"
 	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	zkclient "github.com/samuel/go-zookeeper/zk"
@@ -16,6 +18,10 @@ import (
 var (
 	// ErrInvalidKafkaConfigType error.
 	ErrInvalidKafkaConfigType = errors.New("Invalid Kafka config type")
+	// ErrTopicExists error.
+	ErrTopicExists = errors.New("Topic already exists")
+	// ErrTopicNotExist error.
+	ErrTopicNotExist = errors.New("Topic does not exist")
 	// validKafkaConfigTypes is used as a set
 	// to define valid configuration type names.
 	validKafkaConfigTypes = map[string]struct{}{
@@ -35,12 +41,53 @@ func (e ErrNoNode) Error() string {
 	return e.s
 }
 
+// ErrNodeExists error type is specifically for
+// create method calls where the underlying
+// error type is a zkclient.ErrNodeExists.
+type ErrNodeExists struct {
+	s string
+}
+
+func (e ErrNodeExists) Error() string {
+	return e.s
+}
+
+// connectionErrs are ZooKeeper client errors that
+// result from connection loss or session expiry.
+var connectionErrs = []error{
+	zkclient.ErrConnectionClosed,
+	zkclient.ErrSessionExpired,
+	zkclient.ErrSessionMoved,
+	zkclient.ErrNoServer,
+	zkclient.ErrClosing,
+}
+
+// IsConnectionError returns whether an error returned by a Handler was caused
+// by a ZooKeeper connection loss or session expiry. Such errors are typically
+// transient, as opposed to errors such as ErrNoNode.
+func IsConnectionError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	// Handler errors are often annotated
+	// with the path; match on the message.
+	for _, e := range connectionErrs {
+		if err == e || strings.Contains(err.Error(), e.Error()) {
+			return true
+		}
+	}
+
+	return false
+}
+
 // Handler provides basic ZooKeeper operations along with
 // calls that return kafkazk types describing Kafka states.
 type Handler interface {
 	Exists(string) (bool, error)
 	Create(string, string) error
 	CreateSequential(string, string) error
+	CreateEphemeral(string, string) error
 	Set(string, string) error
 	Get(string) ([]byte, error)
 	Delete(string) error
@@ -54,10 +101,15 @@ type Handler interface {
 	GetReassignments() Reassignments
 	GetTopics([]*regexp.Regexp) ([]string, error)
 	GetTopicConfig(string) (*TopicConfig, error)
+	GetController() (int, error)
 	GetAllBrokerMeta(bool) (BrokerMetaMap, []error)
+	GetBrokerMetrics() (BrokerMetricsMap, error)
+	WatchBrokerIDs(context.Context) (<-chan struct{}, error)
 	GetAllPartitionMeta() (PartitionMetaMap, error)
 	MaxMetaAge() (time.Duration, error)
 	GetPartitionMap(string) (*PartitionMap, error)
+	CreateTopic(string, *PartitionMap) error
+	DeleteTopic(string) error
 }
 
 // TopicState is used for unmarshing ZooKeeper json data from a topic:
@@ -66,6 +118,12 @@ type TopicState struct {
 	Partitions map[string][]int `json:"partitions"`
 }
 
+// controller is used for unmarshalling
+// the controller json data at /controller.
+type controller struct {
+	BrokerID int `json:"brokerid"`
+}
+
 // TopicStateISR is a map of partition numbers to PartitionState.
 type TopicStateISR map[string]PartitionState
 
@@ -133,9 +191,25 @@ type ZKHandler struct {
 	MetricsPrefix string
 }
 
+// ZKPath returns the znode path p under the chroot prefix. Leading and
+// trailing slashes in the prefix are ignored; an empty prefix is the root.
+func ZKPath(prefix, p string) string {
+	prefix = strings.Trim(prefix, "/")
+	if prefix == "" {
+		return p
+	}
+
+	return "/" + prefix + p
+}
+
+// path returns the znode path p under the ZKHandler Prefix.
+func (z *ZKHandler) path(p string) string {
+	return ZKPath(z.Prefix, p)
+}
+
 // Config holds initialization paramaters for a Handler. Connect
 // is a ZooKeeper connect string. Prefix should reflect any prefix
-// used for Kafka on the reference ZooKeeper cluster (excluding slashes).
+// used for Kafka on the reference ZooKeeper cluster; see ZKPath.
 // MetricsPrefix is the prefix used for broker metrics metadata persisted
 // in ZooKeeper.
 type Config struct {
@@ -235,13 +309,36 @@ func (z *ZKHandler) CreateSequential(p string, d string) error {
 	return err
 }
 
+// CreateEphemeral takes a path p and data d and creates an
+// ephemeral znode at p with data d. The znode is removed when
+// the Handler's ZooKeeper session ends. An ErrNodeExists is
+// returned if the znode already exists.
+func (z *ZKHandler) CreateEphemeral(p string, d string) error {
+	_, e := z.client.Create(p, []byte(d), zkclient.FlagEphemeral, zkclient.WorldACL(31))
+	if e != nil {
+		switch e {
+		case zkclient.ErrNodeExists:
+			return ErrNodeExists{s: fmt.Sprintf("[%s] %s", p, e.Error())}
+		case zkclient.ErrNoNode:
+			return ErrNoNode{s: fmt.Sprintf("[%s] %s", p, e.Error())}
+		default:
+			return fmt.Errorf("[%s] %s", p, e.Error())
+		}
+	}
+
+	return nil
+}
+
 // Create creates the provided path p with the data
 // from the provided string d and returns an error
-// if encountered.
+// if encountered. An ErrNodeExists is returned if
+// the znode already exists.
 func (z *ZKHandler) Create(p string, d string) error {
 	_, e := z.client.Create(p, []byte(d), 0, zkclient.WorldACL(31))
 	if e != nil {
 		switch e {
+		case zkclient.ErrNodeExists:
+			return ErrNodeExists{s: fmt.Sprintf("[%s] %s", p, e.Error())}
 		case zkclient.ErrNoNode:
 			return ErrNoNode{s: fmt.Sprintf("[%s] %s", p, e.Error())}
 		default:
@@ -286,12 +383,7 @@ func (z *ZKHandler) Children(p string) ([]string, error) {
 func (z *ZKHandler) GetReassignments() Reassignments {
 	reassigns := Reassignments{}
 
-	var path string
-	if z.Prefix != "" {
-		path = fmt.Sprintf("/%s/admin/reassign_partitions", z.Prefix)
-	} else {
-		path = "/admin/reassign_partitions"
-	}
+	path := z.path("/admin/reassign_partitions")
 
 	// Get reassignment config.
 	data, err := z.Get(path)
@@ -319,12 +411,7 @@ func (z *ZKHandler) GetReassignments() Reassignments {
 func (z *ZKHandler) GetTopics(ts []*regexp.Regexp) ([]string, error) {
 	matchingTopics := []string{}
 
-	var path string
-	if z.Prefix != "" {
-		path = fmt.Sprintf("/%s/brokers/topics", z.Prefix)
-	} else {
-		path = "/brokers/topics"
-	}
+	path := z.path("/brokers/topics")
 
 	// Find all topics in zk.
 	entries, err := z.Children(path)
@@ -356,12 +443,7 @@ func (z *ZKHandler) GetTopics(ts []*regexp.Regexp) ([]string, error) {
 func (z *ZKHandler) GetTopicConfig(t string) (*TopicConfig, error) {
 	config := &TopicConfig{}
 
-	var path string
-	if z.Prefix != "" {
-		path = fmt.Sprintf("/%s/config/topics/%s", z.Prefix, t)
-	} else {
-		path = fmt.Sprintf("/config/topics/%s", t)
-	}
+	path := z.path(fmt.Sprintf("/config/topics/%s", t))
 
 	// Get topic config.
 	data, err := z.Get(path)
@@ -374,18 +456,30 @@ func (z *ZKHandler) GetTopicConfig(t string) (*TopicConfig, error) {
 	return config, nil
 }
 
+// GetController returns the broker ID of the active controller.
+func (z *ZKHandler) GetController() (int, error) {
+	path := z.path("/controller")
+
+	data, err := z.Get(path)
+	if err != nil {
+		return 0, err
+	}
+
+	c := &controller{}
+	if err := json.Unmarshal(data, c); err != nil {
+		return 0, err
+	}
+
+	return c.BrokerID, nil
+}
+
 // GetAllBrokerMeta looks up all registered Kafka brokers and returns their
 // metadata as a BrokerMetaMap. A withMetrics bool param determines whether
 // we additionally want to fetch stored broker metrics.
 func (z *ZKHandler) GetAllBrokerMeta(withMetrics bool) (BrokerMetaMap, []error) {
 	var errs []error
 
-	var path string
-	if z.Prefix != "" {
-		path = fmt.Sprintf("/%s/brokers/ids", z.Prefix)
-	} else {
-		path = "/brokers/ids"
-	}
+	path := z.path("/brokers/ids")
 
 	// Get all brokers.
 	entries, err := z.Children(path)
@@ -423,7 +517,7 @@ func (z *ZKHandler) GetAllBrokerMeta(withMetrics bool) (BrokerMetaMap, []error)
 
 	// Fetch and populate in metrics.
 	if withMetrics {
-		bmetrics, err := z.getBrokerMetrics()
+		bmetrics, err := z.GetBrokerMetrics()
 		if err != nil {
 			return nil, []error{err}
 		}
@@ -437,6 +531,8 @@ func (z *ZKHandler) GetAllBrokerMeta(withMetrics bool) (BrokerMetaMap, []error)
 				bmm[bid].MetricsIncomplete = true
 			} else {
 				bmm[bid].StorageFree = m.StorageFree
+				bmm[bid].StorageTotal = m.StorageTotal
+				bmm[bid].LogDirs = m.LogDirs
 			}
 		}
 
@@ -445,9 +541,34 @@ func (z *ZKHandler) GetAllBrokerMeta(withMetrics bool) (BrokerMetaMap, []error)
 	return bmm, errs
 }
 
+// WatchBrokerIDs sets a watch on the registered broker IDs. The returned
+// channel is closed once the set of broker IDs changes or the watch is
+// otherwise triggered, such as by a session event. Watches fire once; a
+// new watch must be set to observe subsequent changes. If the context is
+// done first, the channel is never closed and the watch is abandoned.
+func (z *ZKHandler) WatchBrokerIDs(ctx context.Context) (<-chan struct{}, error) {
+	path := z.path("/brokers/ids")
+
+	_, _, events, err := z.client.ChildrenW(path)
+	if err != nil {
+		return nil, fmt.Errorf("[%s] %s", path, err.Error())
+	}
+
+	c := make(chan struct{})
+	go func() {
+		select {
+		case <-events:
+			close(c)
+		case <-ctx.Done():
+		}
+	}()
+
+	return c, nil
+}
+
 // GetBrokerMetrics fetches broker metrics stored in ZooKeeper and returns
 // a BrokerMetricsMap and an error if encountered.
-func (z *ZKHandler) getBrokerMetrics() (BrokerMetricsMap, error) {
+func (z *ZKHandler) GetBrokerMetrics() (BrokerMetricsMap, error) {
 	var path string
 	if z.MetricsPrefix != "" {
 		path = fmt.Sprintf("/%s/brokermetrics", z.MetricsPrefix)
@@ -552,12 +673,7 @@ func (z *ZKHandler) oldestMetaTs() (int64, error) {
 // GetTopicState takes a topic name. If the topic exists,
 // the topic state is returned as a *TopicState.
 func (z *ZKHandler) GetTopicState(t string) (*TopicState, error) {
-	var path string
-	if z.Prefix != "" {
-		path = fmt.Sprintf("/%s/brokers/topics/%s", z.Prefix, t)
-	} else {
-		path = fmt.Sprintf("/brokers/topics/%s", t)
-	}
+	path := z.path(fmt.Sprintf("/brokers/topics/%s", t))
 
 	// Fetch topic data from z.
 	ts := &TopicState{}
@@ -580,12 +696,7 @@ func (z *ZKHandler) GetTopicState(t string) (*TopicState, error) {
 // returned for each partition. This method is more expensive due to the
 // need for a call per partition to ZK.
 func (z *ZKHandler) GetTopicStateISR(t string) (TopicStateISR, error) {
-	var path string
-	if z.Prefix != "" {
-		path = fmt.Sprintf("/%s/brokers/topics/%s/partitions", z.Prefix, t)
-	} else {
-		path = fmt.Sprintf("/brokers/topics/%s/partitions", t)
-	}
+	path := z.path(fmt.Sprintf("/brokers/topics/%s/partitions", t))
 
 	ts := TopicStateISR{}
 
@@ -662,6 +773,107 @@ func (z *ZKHandler) GetPartitionMap(t string) (*PartitionMap, error) {
 	return pm, nil
 }
 
+// CreateTopic takes a topic name and a PartitionMap describing the replica
+// assignment for all partitions of the topic. An empty topic config and the
+// topic assignment are written to ZooKeeper, which Kafka picks up to create
+// the topic. ErrTopicExists is returned if the topic already exists.
+func (z *ZKHandler) CreateTopic(t string, pm *PartitionMap) error {
+	cpath := z.path(fmt.Sprintf("/config/topics/%s", t))
+	tpath := z.path(fmt.Sprintf("/brokers/topics/%s", t))
+
+	// Checked up front to avoid resetting the config
+	// of an existing topic; the topic state create
+	// below is what guards against concurrent creates.
+	exists, err := z.Exists(tpath)
+	if err != nil {
+		return err
+	}
+
+	if exists {
+		return ErrTopicExists
+	}
+
+	// Populate the topic state.
+	ts := struct {
+		Version    int              `json:"version"`
+		Partitions map[string][]int `json:"partitions"`
+	}{
+		Version:    1,
+		Partitions: map[string][]int{},
+	}
+
+	for _, p := range pm.Partitions {
+		ts.Partitions[strconv.Itoa(p.Partition)] = p.Replicas
+	}
+
+	tsd, err := json.Marshal(ts)
+	if err != nil {
+		return err
+	}
+
+	// The topic config must exist prior
+	// to the topic state. A stale config may be
+	// left over from a previously deleted topic.
+	cfg := KafkaConfigData{Version: 1, Config: map[string]string{}}
+	cfgd, err := json.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+
+	exists, err = z.Exists(cpath)
+	if err != nil {
+		return err
+	}
+
+	if exists {
+		err = z.Set(cpath, string(cfgd))
+	} else {
+		err = z.Create(cpath, string(cfgd))
+	}
+
+	if err != nil {
+		return err
+	}
+
+	switch err := z.Create(tpath, string(tsd)); err.(type) {
+	case nil:
+		return nil
+	case ErrNodeExists:
+		return ErrTopicExists
+	default:
+		return err
+	}
+}
+
+// DeleteTopic marks the topic t for deletion by creating the
+// /admin/delete_topics/<topic> znode; deletion is then carried out
+// by the Kafka controller. If the topic doesn't exist, ErrTopicNotExist
+// is returned. Marking a topic already pending deletion is a no-op.
+func (z *ZKHandler) DeleteTopic(t string) error {
+	dpath := z.path(fmt.Sprintf("/admin/delete_topics/%s", t))
+	tpath := z.path(fmt.Sprintf("/brokers/topics/%s", t))
+
+	exists, err := z.Exists(tpath)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		return ErrTopicNotExist
+	}
+
+	exists, err = z.Exists(dpath)
+	if err != nil {
+		return err
+	}
+
+	if exists {
+		return nil
+	}
+
+	return z.Create(dpath, "")
+}
+
 // UpdateKafkaConfig takes a KafkaConfig with key value pairs of
 // entity config. If the config is changed, a persistent sequential
 // znode is also written to propagate changes (via watches) to all
@@ -679,12 +891,7 @@ func (z *ZKHandler) UpdateKafkaConfig(c KafkaConfig) (bool, error) {
 
 	// Get current config from the
 	// appropriate path.
-	var path string
-	if z.Prefix != "" {
-		path = fmt.Sprintf("/%s/config/%ss/%s", z.Prefix, c.Type, c.Name)
-	} else {
-		path = fmt.Sprintf("/config/%ss/%s", c.Type, c.Name)
-	}
+	path := z.path(fmt.Sprintf("/config/%ss/%s", c.Type, c.Name))
 
 	var config KafkaConfigData
 
@@ -750,10 +957,7 @@ func (z *ZKHandler) UpdateKafkaConfig(c KafkaConfig) (bool, error) {
 
 	// If there were any config changes, write a change
 	// notification at /config/changes/config_change_<seq>.
-	cpath := "/config/changes/config_change_"
-	if z.Prefix != "" {
-		cpath = "/" + z.Prefix + cpath
-	}
+	cpath := z.path("/config/changes/config_change_")
 
 	cdata := fmt.Sprintf(`{"version":2,"entity_path":"%ss/%s"}`, c.Type, c.Name)
 	err = z.CreateSequential(cpath, cdata)
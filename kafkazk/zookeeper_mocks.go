@@ -1,6 +1,8 @@
 package kafkazk
 
 import (
+	"context"
+	"fmt"
 	"regexp"
 	"time"
 )
@@ -34,6 +36,12 @@ func (zk *Mock) CreateSequential(a, b string) error {
 	return nil
 }
 
+// CreateEphemeral mocks CreateEphemeral.
+func (zk *Mock) CreateEphemeral(a, b string) error {
+	_, _ = a, b
+	return nil
+}
+
 // Exists mocks Exists.
 func (zk *Mock) Exists(a string) (bool, error) {
 	_ = a
@@ -65,7 +73,9 @@ func (zk *Mock) Children(a string) ([]string, error) {
 
 // GetTopicState mocks GetTopicState.
 func (zk *Mock) GetTopicState(t string) (*TopicState, error) {
-	_ = t
+	if t != "test_topic" && t != "test_topic2" {
+		return nil, ErrNoNode{s: fmt.Sprintf("[/brokers/topics/%s] zk: node does not exist", t)}
+	}
 
 	ts := &TopicState{
 		Partitions: map[string][]int{
@@ -166,16 +176,30 @@ func (zk *Mock) GetAllBrokerMeta(withMetrics bool) (BrokerMetaMap, []error) {
 
 		for bid := range b {
 			b[bid].StorageFree = m[bid].StorageFree
+			b[bid].StorageTotal = m[bid].StorageTotal
+			b[bid].LogDirs = m[bid].LogDirs
 		}
 	}
 
 	return b, nil
 }
 
+// WatchBrokerIDs mocks WatchBrokerIDs. The
+// returned channel is never closed.
+func (zk *Mock) WatchBrokerIDs(_ context.Context) (<-chan struct{}, error) {
+	return make(chan struct{}), nil
+}
+
 // GetBrokerMetrics mocks GetBrokerMetrics.
 func (zk *Mock) GetBrokerMetrics() (BrokerMetricsMap, error) {
 	bm := BrokerMetricsMap{
-		1001: &BrokerMetrics{StorageFree: 2000.00},
+		1001: &BrokerMetrics{
+			StorageFree: 2000.00,
+			LogDirs: map[string]float64{
+				"/data/kafka1": 500.00,
+				"/data/kafka2": 1500.00,
+			},
+		},
 		1002: &BrokerMetrics{StorageFree: 4000.00},
 		1003: &BrokerMetrics{StorageFree: 6000.00},
 		1004: &BrokerMetrics{StorageFree: 8000.00},
@@ -185,6 +209,11 @@ func (zk *Mock) GetBrokerMetrics() (BrokerMetricsMap, error) {
 	return bm, nil
 }
 
+// GetController mocks GetController.
+func (zk *Mock) GetController() (int, error) {
+	return 1001, nil
+}
+
 // GetAllPartitionMeta mocks GetAllPartitionMeta.
 func (zk *Mock) GetAllPartitionMeta() (PartitionMetaMap, error) {
 	pm := NewPartitionMetaMap()
@@ -219,3 +248,25 @@ func (zk *Mock) GetPartitionMap(t string) (*PartitionMap, error) {
 func (zk *Mock) MaxMetaAge() (time.Duration, error) {
 	return time.Since(time.Now()), nil
 }
+
+// CreateTopic mocks CreateTopic.
+func (zk *Mock) CreateTopic(t string, pm *PartitionMap) error {
+	_ = pm
+
+	switch t {
+	case "test_topic", "test_topic2":
+		return ErrTopicExists
+	}
+
+	return nil
+}
+
+// DeleteTopic mocks DeleteTopic.
+func (zk *Mock) DeleteTopic(t string) error {
+	switch t {
+	case "test_topic", "test_topic2":
+		return nil
+	}
+
+	return ErrTopicNotExist
+}
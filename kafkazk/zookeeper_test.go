@@ -1,6 +1,7 @@
 package kafkazk
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"regexp"
@@ -27,6 +28,7 @@ var (
 		zkprefix + "/brokers/topics",
 		zkprefix + "/admin",
 		zkprefix + "/admin/reassign_partitions",
+		zkprefix + "/admin/delete_topics",
 		zkprefix + "/config",
 		zkprefix + "/config/topics",
 		zkprefix + "/config/brokers",
@@ -240,6 +242,11 @@ func TestCreateSetGetDelete(t *testing.T) {
 		t.Error(err)
 	}
 
+	// Creating an existing znode should fail.
+	if _, ok := zki.Create("/test", "").(ErrNodeExists); !ok {
+		t.Error("Expected ErrNodeExists error")
+	}
+
 	err = zki.Set("/test", "test data")
 	if err != nil {
 		t.Error(err)
@@ -310,6 +317,36 @@ func TestCreateSequential(t *testing.T) {
 	}
 }
 
+func TestCreateEphemeral(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+
+	err := zki.CreateEphemeral("/test/ephemeral", "data")
+	if err != nil {
+		t.Error(err)
+	}
+
+	paths = append(paths, "/test/ephemeral")
+
+	_, s, err := zkc.Get("/test/ephemeral")
+	if err != nil {
+		t.Error(err)
+	}
+
+	if s.EphemeralOwner == 0 {
+		t.Error("Expected an ephemeral znode")
+	}
+
+	err = zki.CreateEphemeral("/test/ephemeral", "data")
+	switch err.(type) {
+	case ErrNodeExists:
+		break
+	default:
+		t.Errorf("Expected ErrNodeExists error, got %v", err)
+	}
+}
+
 func TestExists(t *testing.T) {
 	if testing.Short() {
 		t.Skip()
@@ -770,6 +807,175 @@ func TestUpdateKafkaConfigTopic(t *testing.T) {
 	}
 }
 
+func TestCreateTopic(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+
+	pm, _ := PartitionMapFromString(testGetMapString("topic5"))
+
+	paths = append(paths, zkprefix+"/config/topics/topic5")
+	paths = append(paths, zkprefix+"/brokers/topics/topic5")
+
+	if err := zki.CreateTopic("topic5", pm); err != nil {
+		t.Error(err)
+	}
+
+	d, _, err := zkc.Get(zkprefix + "/brokers/topics/topic5")
+	if err != nil {
+		t.Error(err)
+	}
+
+	expected := `{"version":1,"partitions":{"0":[1001,1002],"1":[1002,1001],"2":[1003,1004,1001],"3":[1004,1003,1002]}}`
+	if string(d) != expected {
+		t.Errorf("Expected topic state '%s', got '%s'", expected, string(d))
+	}
+
+	d, _, err = zkc.Get(zkprefix + "/config/topics/topic5")
+	if err != nil {
+		t.Error(err)
+	}
+
+	expected = `{"version":1,"config":{}}`
+	if string(d) != expected {
+		t.Errorf("Expected config '%s', got '%s'", expected, string(d))
+	}
+
+	// Creating an existing topic should fail.
+	if err := zki.CreateTopic("topic5", pm); err != ErrTopicExists {
+		t.Errorf("Expected error '%s', got '%v'", ErrTopicExists, err)
+	}
+}
+
+func TestDeleteTopic(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+
+	paths = append(paths, zkprefix+"/admin/delete_topics/topic5")
+
+	if err := zki.DeleteTopic("topic5"); err != nil {
+		t.Error(err)
+	}
+
+	if _, _, err := zkc.Get(zkprefix + "/admin/delete_topics/topic5"); err != nil {
+		t.Error(err)
+	}
+
+	// Deleting a topic pending deletion is a no-op.
+	if err := zki.DeleteTopic("topic5"); err != nil {
+		t.Error(err)
+	}
+
+	// Deleting a non-existent topic should fail.
+	if err := zki.DeleteTopic("nonexistent"); err != ErrTopicNotExist {
+		t.Errorf("Expected error '%s', got '%v'", ErrTopicNotExist, err)
+	}
+}
+
+func TestWatchBrokerIDs(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+
+	c, err := zki.WatchBrokerIDs(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Register a broker.
+	p := fmt.Sprintf("%s/brokers/ids/%d", zkprefix, 1099)
+	if _, err := zkc.Create(p, []byte("{}"), 0, zkclient.WorldACL(31)); err != nil {
+		t.Fatal(err)
+	}
+
+	paths = append(paths, p)
+
+	select {
+	case <-c:
+	case <-time.After(5 * time.Second):
+		t.Error("Expected watch to fire")
+	}
+}
+
+func TestGetController(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+
+	p := zkprefix + "/controller"
+	data := []byte(`{"version":1,"brokerid":1002,"timestamp":"1563000000000"}`)
+
+	_, err := zkc.Create(p, data, 0, zkclient.WorldACL(31))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	paths = append(paths, p)
+
+	id, err := zki.GetController()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if id != 1002 {
+		t.Errorf("Expected controller ID 1002, got %d", id)
+	}
+}
+
+func TestIsConnectionError(t *testing.T) {
+	tests := map[error]bool{
+		nil:                            false,
+		zkclient.ErrConnectionClosed:   true,
+		zkclient.ErrSessionExpired:     true,
+		zkclient.ErrNoServer:           true,
+		zkclient.ErrNoNode:             false,
+		ErrTopicNotExist:               false,
+		ErrNoNode{s: "[/brokers/ids]"}: false,
+		// Annotated errors.
+		fmt.Errorf("[/brokers/ids] %s", zkclient.ErrConnectionClosed): true,
+		fmt.Errorf("[/brokers/ids] %s", zkclient.ErrNoNode):           false,
+	}
+
+	for err, expected := range tests {
+		if r := IsConnectionError(err); r != expected {
+			t.Errorf("[%v] Expected %t, got %t", err, expected, r)
+		}
+	}
+}
+
+func TestZKPath(t *testing.T) {
+	type testCase struct {
+		prefix string
+		path   string
+	}
+
+	tests := map[testCase]string{
+		// Root.
+		{"", "/brokers/ids"}:  "/brokers/ids",
+		{"/", "/brokers/ids"}: "/brokers/ids",
+		// Chroots.
+		{"kafka", "/brokers/topics/test"}:     "/kafka/brokers/topics/test",
+		{"/kafka/", "/config/topics/test"}:    "/kafka/config/topics/test",
+		{"clusters/east", "/controller"}:      "/clusters/east/controller",
+		{"/clusters/west", "/admin/reassign"}: "/clusters/west/admin/reassign",
+	}
+
+	for test, expected := range tests {
+		if p := ZKPath(test.prefix, test.path); p != expected {
+			t.Errorf("[%v] Expected path %s, got %s", test, expected, p)
+		}
+	}
+
+	// ZKHandler paths.
+	for _, prefix := range []string{"", "kafka", "/a/b"} {
+		z := &ZKHandler{Prefix: prefix}
+		if p, e := z.path("/brokers/ids"), ZKPath(prefix, "/brokers/ids"); p != e {
+			t.Errorf("[%s] Expected path %s, got %s", prefix, e, p)
+		}
+	}
+}
+
 // TestTearDown does any tear down cleanup.
 func TestTearDown(t *testing.T) {
 	if testing.Short() {
@@ -23,6 +23,173 @@ var _ = math.Inf
 // proto package needs to be updated.
 const _ = proto.ProtoPackageIsVersion3 // please upgrade the proto package
 
+type WatchBrokersRequest_Control int32
+
+const (
+	WatchBrokersRequest_NONE   WatchBrokersRequest_Control = 0
+	WatchBrokersRequest_PAUSE  WatchBrokersRequest_Control = 1
+	WatchBrokersRequest_RESUME WatchBrokersRequest_Control = 2
+)
+
+var WatchBrokersRequest_Control_name = map[int32]string{
+	0: "NONE",
+	1: "PAUSE",
+	2: "RESUME",
+}
+
+var WatchBrokersRequest_Control_value = map[string]int32{
+	"NONE":   0,
+	"PAUSE":  1,
+	"RESUME": 2,
+}
+
+func (x WatchBrokersRequest_Control) String() string {
+	return proto.EnumName(WatchBrokersRequest_Control_name, int32(x))
+}
+
+func (WatchBrokersRequest_Control) EnumDescriptor() ([]byte, []int) {
+	return fileDescriptor_4215e5fe8e6d7e5d, []int{3, 0}
+}
+
+// State values correspond to the broker Missing and Replace
+// flags used in topicmappr. Brokers are evaluated against all
+// topic assignments. MISSING matches brokers holding replicas
+// that aren't registered in ZooKeeper. REPLACE matches brokers
+// that would be marked for replacement given the target_brokers
+// list, as with the topicmappr --brokers flag; this includes
+// MISSING brokers. Unregistered brokers are returned with only
+// the id field populated.
+type BrokerRequest_State int32
+
+const (
+	BrokerRequest_ANY     BrokerRequest_State = 0
+	BrokerRequest_MISSING BrokerRequest_State = 1
+	BrokerRequest_REPLACE BrokerRequest_State = 2
+)
+
+var BrokerRequest_State_name = map[int32]string{
+	0: "ANY",
+	1: "MISSING",
+	2: "REPLACE",
+}
+
+var BrokerRequest_State_value = map[string]int32{
+	"ANY":     0,
+	"MISSING": 1,
+	"REPLACE": 2,
+}
+
+func (x BrokerRequest_State) String() string {
+	return proto.EnumName(BrokerRequest_State_name, int32(x))
+}
+
+func (BrokerRequest_State) EnumDescriptor() ([]byte, []int) {
+	return fileDescriptor_4215e5fe8e6d7e5d, []int{4, 0}
+}
+
+// SortBy values set the order of the response ids. ID is
+// ascending broker ID. STORAGE_FREE is descending storage
+// free; brokers without metrics are ordered last. USED is
+// ascending count of partition replicas held. Ties are
+// broken by ascending broker ID.
+type BrokerRequest_SortBy int32
+
+const (
+	BrokerRequest_ID           BrokerRequest_SortBy = 0
+	BrokerRequest_STORAGE_FREE BrokerRequest_SortBy = 1
+	BrokerRequest_USED         BrokerRequest_SortBy = 2
+)
+
+var BrokerRequest_SortBy_name = map[int32]string{
+	0: "ID",
+	1: "STORAGE_FREE",
+	2: "USED",
+}
+
+var BrokerRequest_SortBy_value = map[string]int32{
+	"ID":           0,
+	"STORAGE_FREE": 1,
+	"USED":         2,
+}
+
+func (x BrokerRequest_SortBy) String() string {
+	return proto.EnumName(BrokerRequest_SortBy_name, int32(x))
+}
+
+func (BrokerRequest_SortBy) EnumDescriptor() ([]byte, []int) {
+	return fileDescriptor_4215e5fe8e6d7e5d, []int{4, 1}
+}
+
+type Event_Type int32
+
+const (
+	Event_UNKNOWN       Event_Type = 0
+	Event_BROKER_JOINED Event_Type = 1
+	Event_BROKER_LEFT   Event_Type = 2
+	Event_TOPIC_CREATED Event_Type = 3
+	Event_TOPIC_DELETED Event_Type = 4
+	// The partition count or replication factor changed;
+	// topic config changes aren't reported.
+	Event_TOPIC_RECONFIGURED Event_Type = 5
+)
+
+var Event_Type_name = map[int32]string{
+	0: "UNKNOWN",
+	1: "BROKER_JOINED",
+	2: "BROKER_LEFT",
+	3: "TOPIC_CREATED",
+	4: "TOPIC_DELETED",
+	5: "TOPIC_RECONFIGURED",
+}
+
+var Event_Type_value = map[string]int32{
+	"UNKNOWN":            0,
+	"BROKER_JOINED":      1,
+	"BROKER_LEFT":        2,
+	"TOPIC_CREATED":      3,
+	"TOPIC_DELETED":      4,
+	"TOPIC_RECONFIGURED": 5,
+}
+
+func (x Event_Type) String() string {
+	return proto.EnumName(Event_Type_name, int32(x))
+}
+
+func (Event_Type) EnumDescriptor() ([]byte, []int) {
+	return fileDescriptor_4215e5fe8e6d7e5d, []int{20, 0}
+}
+
+type Empty struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Empty) Reset()         { *m = Empty{} }
+func (m *Empty) String() string { return proto.CompactTextString(m) }
+func (*Empty) ProtoMessage()    {}
+func (*Empty) Descriptor() ([]byte, []int) {
+	return fileDescriptor_4215e5fe8e6d7e5d, []int{0}
+}
+
+func (m *Empty) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Empty.Unmarshal(m, b)
+}
+func (m *Empty) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Empty.Marshal(b, m, deterministic)
+}
+func (m *Empty) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Empty.Merge(m, src)
+}
+func (m *Empty) XXX_Size() int {
+	return xxx_messageInfo_Empty.Size(m)
+}
+func (m *Empty) XXX_DiscardUnknown() {
+	xxx_messageInfo_Empty.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Empty proto.InternalMessageInfo
+
 type TagResponse struct {
 	Message              string   `protobuf:"bytes,1,opt,name=message,proto3" json:"message,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
@@ -34,7 +201,7 @@ func (m *TagResponse) Reset()         { *m = TagResponse{} }
 func (m *TagResponse) String() string { return proto.CompactTextString(m) }
 func (*TagResponse) ProtoMessage()    {}
 func (*TagResponse) Descriptor() ([]byte, []int) {
-	return fileDescriptor_4215e5fe8e6d7e5d, []int{0}
+	return fileDescriptor_4215e5fe8e6d7e5d, []int{1}
 }
 
 func (m *TagResponse) XXX_Unmarshal(b []byte) error {
@@ -62,9 +229,130 @@ func (m *TagResponse) GetMessage() string {
 	return ""
 }
 
+type BulkTagResponse struct {
+	// Results maps each requested ID to
+	// "success" or the error encountered.
+	Results              map[uint32]string `protobuf:"bytes,1,rep,name=results,proto3" json:"results,omitempty" protobuf_key:"varint,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	XXX_NoUnkeyedLiteral struct{}          `json:"-"`
+	XXX_unrecognized     []byte            `json:"-"`
+	XXX_sizecache        int32             `json:"-"`
+}
+
+func (m *BulkTagResponse) Reset()         { *m = BulkTagResponse{} }
+func (m *BulkTagResponse) String() string { return proto.CompactTextString(m) }
+func (*BulkTagResponse) ProtoMessage()    {}
+func (*BulkTagResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_4215e5fe8e6d7e5d, []int{2}
+}
+
+func (m *BulkTagResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_BulkTagResponse.Unmarshal(m, b)
+}
+func (m *BulkTagResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_BulkTagResponse.Marshal(b, m, deterministic)
+}
+func (m *BulkTagResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_BulkTagResponse.Merge(m, src)
+}
+func (m *BulkTagResponse) XXX_Size() int {
+	return xxx_messageInfo_BulkTagResponse.Size(m)
+}
+func (m *BulkTagResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_BulkTagResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_BulkTagResponse proto.InternalMessageInfo
+
+func (m *BulkTagResponse) GetResults() map[uint32]string {
+	if m != nil {
+		return m.Results
+	}
+	return nil
+}
+
+type WatchBrokersRequest struct {
+	// The brokers to watch, as in WatchBrokers.
+	// Only read from the first message.
+	Request *BrokerRequest `protobuf:"bytes,1,opt,name=request,proto3" json:"request,omitempty"`
+	// Pauses or resumes the stream. Ignored
+	// on the first message.
+	Control              WatchBrokersRequest_Control `protobuf:"varint,2,opt,name=control,proto3,enum=registry.WatchBrokersRequest.Control" json:"control,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}                    `json:"-"`
+	XXX_unrecognized     []byte                      `json:"-"`
+	XXX_sizecache        int32                       `json:"-"`
+}
+
+func (m *WatchBrokersRequest) Reset()         { *m = WatchBrokersRequest{} }
+func (m *WatchBrokersRequest) String() string { return proto.CompactTextString(m) }
+func (*WatchBrokersRequest) ProtoMessage()    {}
+func (*WatchBrokersRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_4215e5fe8e6d7e5d, []int{3}
+}
+
+func (m *WatchBrokersRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_WatchBrokersRequest.Unmarshal(m, b)
+}
+func (m *WatchBrokersRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_WatchBrokersRequest.Marshal(b, m, deterministic)
+}
+func (m *WatchBrokersRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_WatchBrokersRequest.Merge(m, src)
+}
+func (m *WatchBrokersRequest) XXX_Size() int {
+	return xxx_messageInfo_WatchBrokersRequest.Size(m)
+}
+func (m *WatchBrokersRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_WatchBrokersRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_WatchBrokersRequest proto.InternalMessageInfo
+
+func (m *WatchBrokersRequest) GetRequest() *BrokerRequest {
+	if m != nil {
+		return m.Request
+	}
+	return nil
+}
+
+func (m *WatchBrokersRequest) GetControl() WatchBrokersRequest_Control {
+	if m != nil {
+		return m.Control
+	}
+	return WatchBrokersRequest_NONE
+}
+
 type BrokerRequest struct {
-	Tag                  []string `protobuf:"bytes,1,rep,name=tag,proto3" json:"tag,omitempty"`
-	Id                   uint32   `protobuf:"varint,2,opt,name=id,proto3" json:"id,omitempty"`
+	// Tag filters are "key:value" predicates matching
+	// brokers with the key set to the value, or bare "key"
+	// predicates matching brokers with the key set to any
+	// value. Brokers must satisfy all predicates.
+	Tag []string `protobuf:"bytes,1,rep,name=tag,proto3" json:"tag,omitempty"`
+	Id  uint32   `protobuf:"varint,2,opt,name=id,proto3" json:"id,omitempty"`
+	// include_metadata requests that ListBrokers populate
+	// the brokers field along with ids. Broker storage
+	// metrics are included in the metadata.
+	IncludeMetadata bool `protobuf:"varint,3,opt,name=include_metadata,json=includeMetadata,proto3" json:"includeMetadata,omitempty"`
+	// state filters matched brokers by state.
+	State BrokerRequest_State `protobuf:"varint,4,opt,name=state,proto3,enum=registry.BrokerRequest.State" json:"state,omitempty"`
+	// page_size limits the number of brokers returned, in
+	// ascending ID order. A value of 0 returns all brokers.
+	PageSize uint32 `protobuf:"varint,5,opt,name=page_size,json=pageSize,proto3" json:"pageSize,omitempty"`
+	// page_token is the next_page_token returned by a
+	// previous request, used to fetch the following page.
+	PageToken string `protobuf:"bytes,6,opt,name=page_token,json=pageToken,proto3" json:"pageToken,omitempty"`
+	// sort_by orders the response ids. Pagination
+	// is only supported with the default ID order.
+	SortBy BrokerRequest_SortBy `protobuf:"varint,7,opt,name=sort_by,json=sortBy,proto3,enum=registry.BrokerRequest.SortBy" json:"sortBy,omitempty"`
+	// fields limits the Broker fields populated in the
+	// response to those named, using the Broker field
+	// names (e.g. "id", "rack", "storage_free"). All
+	// fields are populated if unset. Unknown field names
+	// are ignored and reported in the response warnings.
+	Fields []string `protobuf:"bytes,8,rep,name=fields,proto3" json:"fields,omitempty"`
+	// target_brokers is the target broker list used to evaluate
+	// the REPLACE state; brokers holding replicas that aren't
+	// listed are matched. Required with the REPLACE state.
+	TargetBrokers        []uint32 `protobuf:"varint,9,rep,packed,name=target_brokers,json=targetBrokers,proto3" json:"targetBrokers,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -74,7 +362,7 @@ func (m *BrokerRequest) Reset()         { *m = BrokerRequest{} }
 func (m *BrokerRequest) String() string { return proto.CompactTextString(m) }
 func (*BrokerRequest) ProtoMessage()    {}
 func (*BrokerRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_4215e5fe8e6d7e5d, []int{1}
+	return fileDescriptor_4215e5fe8e6d7e5d, []int{4}
 }
 
 func (m *BrokerRequest) XXX_Unmarshal(b []byte) error {
@@ -109,19 +397,130 @@ func (m *BrokerRequest) GetId() uint32 {
 	return 0
 }
 
+func (m *BrokerRequest) GetIncludeMetadata() bool {
+	if m != nil {
+		return m.IncludeMetadata
+	}
+	return false
+}
+
+func (m *BrokerRequest) GetState() BrokerRequest_State {
+	if m != nil {
+		return m.State
+	}
+	return BrokerRequest_ANY
+}
+
+func (m *BrokerRequest) GetPageSize() uint32 {
+	if m != nil {
+		return m.PageSize
+	}
+	return 0
+}
+
+func (m *BrokerRequest) GetPageToken() string {
+	if m != nil {
+		return m.PageToken
+	}
+	return ""
+}
+
+func (m *BrokerRequest) GetSortBy() BrokerRequest_SortBy {
+	if m != nil {
+		return m.SortBy
+	}
+	return BrokerRequest_ID
+}
+
+func (m *BrokerRequest) GetFields() []string {
+	if m != nil {
+		return m.Fields
+	}
+	return nil
+}
+
+func (m *BrokerRequest) GetTargetBrokers() []uint32 {
+	if m != nil {
+		return m.TargetBrokers
+	}
+	return nil
+}
+
+type BrokerTagsRequest struct {
+	Tag                  []string `protobuf:"bytes,1,rep,name=tag,proto3" json:"tag,omitempty"`
+	Ids                  []uint32 `protobuf:"varint,2,rep,packed,name=ids,proto3" json:"ids,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *BrokerTagsRequest) Reset()         { *m = BrokerTagsRequest{} }
+func (m *BrokerTagsRequest) String() string { return proto.CompactTextString(m) }
+func (*BrokerTagsRequest) ProtoMessage()    {}
+func (*BrokerTagsRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_4215e5fe8e6d7e5d, []int{5}
+}
+
+func (m *BrokerTagsRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_BrokerTagsRequest.Unmarshal(m, b)
+}
+func (m *BrokerTagsRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_BrokerTagsRequest.Marshal(b, m, deterministic)
+}
+func (m *BrokerTagsRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_BrokerTagsRequest.Merge(m, src)
+}
+func (m *BrokerTagsRequest) XXX_Size() int {
+	return xxx_messageInfo_BrokerTagsRequest.Size(m)
+}
+func (m *BrokerTagsRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_BrokerTagsRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_BrokerTagsRequest proto.InternalMessageInfo
+
+func (m *BrokerTagsRequest) GetTag() []string {
+	if m != nil {
+		return m.Tag
+	}
+	return nil
+}
+
+func (m *BrokerTagsRequest) GetIds() []uint32 {
+	if m != nil {
+		return m.Ids
+	}
+	return nil
+}
+
 type BrokerResponse struct {
-	Brokers              map[uint32]*Broker `protobuf:"bytes,5,rep,name=brokers,proto3" json:"brokers,omitempty" protobuf_key:"varint,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
-	Ids                  []uint32           `protobuf:"varint,6,rep,packed,name=ids,proto3" json:"ids,omitempty"`
-	XXX_NoUnkeyedLiteral struct{}           `json:"-"`
-	XXX_unrecognized     []byte             `json:"-"`
-	XXX_sizecache        int32              `json:"-"`
+	Brokers map[uint32]*Broker `protobuf:"bytes,5,rep,name=brokers,proto3" json:"brokers,omitempty" protobuf_key:"varint,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	Ids     []uint32           `protobuf:"varint,6,rep,packed,name=ids,proto3" json:"ids,omitempty"`
+	// next_page_token is set if a page_size was requested and
+	// more brokers remain. An empty value indicates the last page.
+	NextPageToken string `protobuf:"bytes,7,opt,name=next_page_token,json=nextPageToken,proto3" json:"nextPageToken,omitempty"`
+	// warnings describes request issues that
+	// didn't fail the request, such as unknown
+	// BrokerRequest field names.
+	Warnings []string `protobuf:"bytes,8,rep,name=warnings,proto3" json:"warnings,omitempty"`
+	// Set on WatchBrokers deltas following the initial
+	// snapshot: the IDs of brokers that joined or left.
+	Added   []uint32 `protobuf:"varint,9,rep,packed,name=added,proto3" json:"added,omitempty"`
+	Removed []uint32 `protobuf:"varint,10,rep,packed,name=removed,proto3" json:"removed,omitempty"`
+	// Set on WatchBrokers deltas replayed from recent history
+	// following the snapshot. Replayed changes occurred before
+	// the snapshot and are already reflected in it.
+	Replayed             bool     `protobuf:"varint,11,opt,name=replayed,proto3" json:"replayed,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
 }
 
 func (m *BrokerResponse) Reset()         { *m = BrokerResponse{} }
 func (m *BrokerResponse) String() string { return proto.CompactTextString(m) }
 func (*BrokerResponse) ProtoMessage()    {}
 func (*BrokerResponse) Descriptor() ([]byte, []int) {
-	return fileDescriptor_4215e5fe8e6d7e5d, []int{2}
+	return fileDescriptor_4215e5fe8e6d7e5d, []int{6}
 }
 
 func (m *BrokerResponse) XXX_Unmarshal(b []byte) error {
@@ -156,6 +555,41 @@ func (m *BrokerResponse) GetIds() []uint32 {
 	return nil
 }
 
+func (m *BrokerResponse) GetNextPageToken() string {
+	if m != nil {
+		return m.NextPageToken
+	}
+	return ""
+}
+
+func (m *BrokerResponse) GetWarnings() []string {
+	if m != nil {
+		return m.Warnings
+	}
+	return nil
+}
+
+func (m *BrokerResponse) GetAdded() []uint32 {
+	if m != nil {
+		return m.Added
+	}
+	return nil
+}
+
+func (m *BrokerResponse) GetRemoved() []uint32 {
+	if m != nil {
+		return m.Removed
+	}
+	return nil
+}
+
+func (m *BrokerResponse) GetReplayed() bool {
+	if m != nil {
+		return m.Replayed
+	}
+	return false
+}
+
 type Broker struct {
 	// Registry metadata.
 	Tags map[string]string `protobuf:"bytes,1,rep,name=tags,proto3" json:"tags,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
@@ -169,16 +603,22 @@ type Broker struct {
 	Timestamp                   int64             `protobuf:"varint,11,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
 	Port                        uint32            `protobuf:"varint,12,opt,name=port,proto3" json:"port,omitempty"`
 	Version                     uint32            `protobuf:"varint,13,opt,name=version,proto3" json:"version,omitempty"`
-	XXX_NoUnkeyedLiteral        struct{}          `json:"-"`
-	XXX_unrecognized            []byte            `json:"-"`
-	XXX_sizecache               int32             `json:"-"`
+	// Broker metrics, populated by GetBrokers or if
+	// requested via BrokerRequest.include_metadata.
+	StorageFree float64 `protobuf:"fixed64,14,opt,name=storage_free,json=storageFree,proto3" json:"storageFree,omitempty"`
+	// Set if metrics were unavailable for the broker,
+	// distinguishing an unknown storage_free from zero.
+	MetricsIncomplete    bool     `protobuf:"varint,15,opt,name=metrics_incomplete,json=metricsIncomplete,proto3" json:"metricsIncomplete,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
 }
 
 func (m *Broker) Reset()         { *m = Broker{} }
 func (m *Broker) String() string { return proto.CompactTextString(m) }
 func (*Broker) ProtoMessage()    {}
 func (*Broker) Descriptor() ([]byte, []int) {
-	return fileDescriptor_4215e5fe8e6d7e5d, []int{3}
+	return fileDescriptor_4215e5fe8e6d7e5d, []int{7}
 }
 
 func (m *Broker) XXX_Unmarshal(b []byte) error {
@@ -269,9 +709,31 @@ func (m *Broker) GetVersion() uint32 {
 	return 0
 }
 
+func (m *Broker) GetStorageFree() float64 {
+	if m != nil {
+		return m.StorageFree
+	}
+	return 0
+}
+
+func (m *Broker) GetMetricsIncomplete() bool {
+	if m != nil {
+		return m.MetricsIncomplete
+	}
+	return false
+}
+
 type TopicRequest struct {
-	Tag                  []string `protobuf:"bytes,1,rep,name=tag,proto3" json:"tag,omitempty"`
-	Name                 string   `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	// Tag filters are "key:value" or "key" predicates
+	// as described in BrokerRequest.
+	Tag  []string `protobuf:"bytes,1,rep,name=tag,proto3" json:"tag,omitempty"`
+	Name string   `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	// dry_run previews the topics matched by
+	// DeleteTopic without deleting them.
+	DryRun bool `protobuf:"varint,3,opt,name=dry_run,json=dryRun,proto3" json:"dryRun,omitempty"`
+	// include_assignments populates the replica
+	// assignments of each topic in the response.
+	IncludeAssignments   bool     `protobuf:"varint,4,opt,name=include_assignments,json=includeAssignments,proto3" json:"includeAssignments,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -281,7 +743,7 @@ func (m *TopicRequest) Reset()         { *m = TopicRequest{} }
 func (m *TopicRequest) String() string { return proto.CompactTextString(m) }
 func (*TopicRequest) ProtoMessage()    {}
 func (*TopicRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_4215e5fe8e6d7e5d, []int{4}
+	return fileDescriptor_4215e5fe8e6d7e5d, []int{8}
 }
 
 func (m *TopicRequest) XXX_Unmarshal(b []byte) error {
@@ -316,9 +778,168 @@ func (m *TopicRequest) GetName() string {
 	return ""
 }
 
+func (m *TopicRequest) GetDryRun() bool {
+	if m != nil {
+		return m.DryRun
+	}
+	return false
+}
+
+func (m *TopicRequest) GetIncludeAssignments() bool {
+	if m != nil {
+		return m.IncludeAssignments
+	}
+	return false
+}
+
+type CreateTopicRequest struct {
+	Topic                *Topic                 `protobuf:"bytes,1,opt,name=topic,proto3" json:"topic,omitempty"`
+	Assignment           []*PartitionAssignment `protobuf:"bytes,2,rep,name=assignment,proto3" json:"assignment,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}               `json:"-"`
+	XXX_unrecognized     []byte                 `json:"-"`
+	XXX_sizecache        int32                  `json:"-"`
+}
+
+func (m *CreateTopicRequest) Reset()         { *m = CreateTopicRequest{} }
+func (m *CreateTopicRequest) String() string { return proto.CompactTextString(m) }
+func (*CreateTopicRequest) ProtoMessage()    {}
+func (*CreateTopicRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_4215e5fe8e6d7e5d, []int{9}
+}
+
+func (m *CreateTopicRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_CreateTopicRequest.Unmarshal(m, b)
+}
+func (m *CreateTopicRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_CreateTopicRequest.Marshal(b, m, deterministic)
+}
+func (m *CreateTopicRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_CreateTopicRequest.Merge(m, src)
+}
+func (m *CreateTopicRequest) XXX_Size() int {
+	return xxx_messageInfo_CreateTopicRequest.Size(m)
+}
+func (m *CreateTopicRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_CreateTopicRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_CreateTopicRequest proto.InternalMessageInfo
+
+func (m *CreateTopicRequest) GetTopic() *Topic {
+	if m != nil {
+		return m.Topic
+	}
+	return nil
+}
+
+func (m *CreateTopicRequest) GetAssignment() []*PartitionAssignment {
+	if m != nil {
+		return m.Assignment
+	}
+	return nil
+}
+
+type PartitionAssignment struct {
+	Partition            uint32   `protobuf:"varint,1,opt,name=partition,proto3" json:"partition,omitempty"`
+	Replicas             []uint32 `protobuf:"varint,2,rep,packed,name=replicas,proto3" json:"replicas,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *PartitionAssignment) Reset()         { *m = PartitionAssignment{} }
+func (m *PartitionAssignment) String() string { return proto.CompactTextString(m) }
+func (*PartitionAssignment) ProtoMessage()    {}
+func (*PartitionAssignment) Descriptor() ([]byte, []int) {
+	return fileDescriptor_4215e5fe8e6d7e5d, []int{10}
+}
+
+func (m *PartitionAssignment) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_PartitionAssignment.Unmarshal(m, b)
+}
+func (m *PartitionAssignment) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_PartitionAssignment.Marshal(b, m, deterministic)
+}
+func (m *PartitionAssignment) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_PartitionAssignment.Merge(m, src)
+}
+func (m *PartitionAssignment) XXX_Size() int {
+	return xxx_messageInfo_PartitionAssignment.Size(m)
+}
+func (m *PartitionAssignment) XXX_DiscardUnknown() {
+	xxx_messageInfo_PartitionAssignment.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_PartitionAssignment proto.InternalMessageInfo
+
+func (m *PartitionAssignment) GetPartition() uint32 {
+	if m != nil {
+		return m.Partition
+	}
+	return 0
+}
+
+func (m *PartitionAssignment) GetReplicas() []uint32 {
+	if m != nil {
+		return m.Replicas
+	}
+	return nil
+}
+
+type TopicExistsResponse struct {
+	Exists               bool     `protobuf:"varint,1,opt,name=exists,proto3" json:"exists,omitempty"`
+	Topic                *Topic   `protobuf:"bytes,2,opt,name=topic,proto3" json:"topic,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *TopicExistsResponse) Reset()         { *m = TopicExistsResponse{} }
+func (m *TopicExistsResponse) String() string { return proto.CompactTextString(m) }
+func (*TopicExistsResponse) ProtoMessage()    {}
+func (*TopicExistsResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_4215e5fe8e6d7e5d, []int{11}
+}
+
+func (m *TopicExistsResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_TopicExistsResponse.Unmarshal(m, b)
+}
+func (m *TopicExistsResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_TopicExistsResponse.Marshal(b, m, deterministic)
+}
+func (m *TopicExistsResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_TopicExistsResponse.Merge(m, src)
+}
+func (m *TopicExistsResponse) XXX_Size() int {
+	return xxx_messageInfo_TopicExistsResponse.Size(m)
+}
+func (m *TopicExistsResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_TopicExistsResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_TopicExistsResponse proto.InternalMessageInfo
+
+func (m *TopicExistsResponse) GetExists() bool {
+	if m != nil {
+		return m.Exists
+	}
+	return false
+}
+
+func (m *TopicExistsResponse) GetTopic() *Topic {
+	if m != nil {
+		return m.Topic
+	}
+	return nil
+}
+
 type TopicResponse struct {
-	Topics               map[string]*Topic `protobuf:"bytes,5,rep,name=topics,proto3" json:"topics,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
-	Names                []string          `protobuf:"bytes,6,rep,name=names,proto3" json:"names,omitempty"`
+	Topics map[string]*Topic `protobuf:"bytes,5,rep,name=topics,proto3" json:"topics,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	Names  []string          `protobuf:"bytes,6,rep,name=names,proto3" json:"names,omitempty"`
+	// errors maps the topics that DeleteTopic failed
+	// to delete to the error encountered. Topics that
+	// failed aren't listed in the names field.
+	Errors               map[string]string `protobuf:"bytes,7,rep,name=errors,proto3" json:"errors,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
 	XXX_NoUnkeyedLiteral struct{}          `json:"-"`
 	XXX_unrecognized     []byte            `json:"-"`
 	XXX_sizecache        int32             `json:"-"`
@@ -328,7 +949,7 @@ func (m *TopicResponse) Reset()         { *m = TopicResponse{} }
 func (m *TopicResponse) String() string { return proto.CompactTextString(m) }
 func (*TopicResponse) ProtoMessage()    {}
 func (*TopicResponse) Descriptor() ([]byte, []int) {
-	return fileDescriptor_4215e5fe8e6d7e5d, []int{5}
+	return fileDescriptor_4215e5fe8e6d7e5d, []int{12}
 }
 
 func (m *TopicResponse) XXX_Unmarshal(b []byte) error {
@@ -363,23 +984,111 @@ func (m *TopicResponse) GetNames() []string {
 	return nil
 }
 
+func (m *TopicResponse) GetErrors() map[string]string {
+	if m != nil {
+		return m.Errors
+	}
+	return nil
+}
+
+type TopicConfigResponse struct {
+	Configs              map[string]*TopicConfig `protobuf:"bytes,1,rep,name=configs,proto3" json:"configs,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	XXX_NoUnkeyedLiteral struct{}                `json:"-"`
+	XXX_unrecognized     []byte                  `json:"-"`
+	XXX_sizecache        int32                   `json:"-"`
+}
+
+func (m *TopicConfigResponse) Reset()         { *m = TopicConfigResponse{} }
+func (m *TopicConfigResponse) String() string { return proto.CompactTextString(m) }
+func (*TopicConfigResponse) ProtoMessage()    {}
+func (*TopicConfigResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_4215e5fe8e6d7e5d, []int{13}
+}
+
+func (m *TopicConfigResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_TopicConfigResponse.Unmarshal(m, b)
+}
+func (m *TopicConfigResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_TopicConfigResponse.Marshal(b, m, deterministic)
+}
+func (m *TopicConfigResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_TopicConfigResponse.Merge(m, src)
+}
+func (m *TopicConfigResponse) XXX_Size() int {
+	return xxx_messageInfo_TopicConfigResponse.Size(m)
+}
+func (m *TopicConfigResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_TopicConfigResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_TopicConfigResponse proto.InternalMessageInfo
+
+func (m *TopicConfigResponse) GetConfigs() map[string]*TopicConfig {
+	if m != nil {
+		return m.Configs
+	}
+	return nil
+}
+
+type TopicConfig struct {
+	Config               map[string]string `protobuf:"bytes,1,rep,name=config,proto3" json:"config,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	XXX_NoUnkeyedLiteral struct{}          `json:"-"`
+	XXX_unrecognized     []byte            `json:"-"`
+	XXX_sizecache        int32             `json:"-"`
+}
+
+func (m *TopicConfig) Reset()         { *m = TopicConfig{} }
+func (m *TopicConfig) String() string { return proto.CompactTextString(m) }
+func (*TopicConfig) ProtoMessage()    {}
+func (*TopicConfig) Descriptor() ([]byte, []int) {
+	return fileDescriptor_4215e5fe8e6d7e5d, []int{14}
+}
+
+func (m *TopicConfig) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_TopicConfig.Unmarshal(m, b)
+}
+func (m *TopicConfig) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_TopicConfig.Marshal(b, m, deterministic)
+}
+func (m *TopicConfig) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_TopicConfig.Merge(m, src)
+}
+func (m *TopicConfig) XXX_Size() int {
+	return xxx_messageInfo_TopicConfig.Size(m)
+}
+func (m *TopicConfig) XXX_DiscardUnknown() {
+	xxx_messageInfo_TopicConfig.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_TopicConfig proto.InternalMessageInfo
+
+func (m *TopicConfig) GetConfig() map[string]string {
+	if m != nil {
+		return m.Config
+	}
+	return nil
+}
+
 type Topic struct {
 	// Registry metadata.
 	Tags map[string]string `protobuf:"bytes,1,rep,name=tags,proto3" json:"tags,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
 	// Topic metadata from ZooKeeper.
-	Name                 string   `protobuf:"bytes,5,opt,name=name,proto3" json:"name,omitempty"`
-	Partitions           uint32   `protobuf:"varint,6,opt,name=partitions,proto3" json:"partitions,omitempty"`
-	Replication          uint32   `protobuf:"varint,7,opt,name=replication,proto3" json:"replication,omitempty"`
-	XXX_NoUnkeyedLiteral struct{} `json:"-"`
-	XXX_unrecognized     []byte   `json:"-"`
-	XXX_sizecache        int32    `json:"-"`
+	Name        string `protobuf:"bytes,5,opt,name=name,proto3" json:"name,omitempty"`
+	Partitions  uint32 `protobuf:"varint,6,opt,name=partitions,proto3" json:"partitions,omitempty"`
+	Replication uint32 `protobuf:"varint,7,opt,name=replication,proto3" json:"replication,omitempty"`
+	// Partition to replica assignments; only populated
+	// if requested via include_assignments.
+	Assignments          map[uint32]*Replicas `protobuf:"bytes,8,rep,name=assignments,proto3" json:"assignments,omitempty" protobuf_key:"varint,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	XXX_NoUnkeyedLiteral struct{}             `json:"-"`
+	XXX_unrecognized     []byte               `json:"-"`
+	XXX_sizecache        int32                `json:"-"`
 }
 
 func (m *Topic) Reset()         { *m = Topic{} }
 func (m *Topic) String() string { return proto.CompactTextString(m) }
 func (*Topic) ProtoMessage()    {}
 func (*Topic) Descriptor() ([]byte, []int) {
-	return fileDescriptor_4215e5fe8e6d7e5d, []int{6}
+	return fileDescriptor_4215e5fe8e6d7e5d, []int{15}
 }
 
 func (m *Topic) XXX_Unmarshal(b []byte) error {
@@ -400,104 +1109,546 @@ func (m *Topic) XXX_DiscardUnknown() {
 
 var xxx_messageInfo_Topic proto.InternalMessageInfo
 
-func (m *Topic) GetTags() map[string]string {
+func (m *Topic) GetTags() map[string]string {
+	if m != nil {
+		return m.Tags
+	}
+	return nil
+}
+
+func (m *Topic) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *Topic) GetPartitions() uint32 {
+	if m != nil {
+		return m.Partitions
+	}
+	return 0
+}
+
+func (m *Topic) GetReplication() uint32 {
+	if m != nil {
+		return m.Replication
+	}
+	return 0
+}
+
+func (m *Topic) GetAssignments() map[uint32]*Replicas {
+	if m != nil {
+		return m.Assignments
+	}
+	return nil
+}
+
+type Replicas struct {
+	Ids                  []uint32 `protobuf:"varint,1,rep,packed,name=ids,proto3" json:"ids,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Replicas) Reset()         { *m = Replicas{} }
+func (m *Replicas) String() string { return proto.CompactTextString(m) }
+func (*Replicas) ProtoMessage()    {}
+func (*Replicas) Descriptor() ([]byte, []int) {
+	return fileDescriptor_4215e5fe8e6d7e5d, []int{16}
+}
+
+func (m *Replicas) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Replicas.Unmarshal(m, b)
+}
+func (m *Replicas) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Replicas.Marshal(b, m, deterministic)
+}
+func (m *Replicas) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Replicas.Merge(m, src)
+}
+func (m *Replicas) XXX_Size() int {
+	return xxx_messageInfo_Replicas.Size(m)
+}
+func (m *Replicas) XXX_DiscardUnknown() {
+	xxx_messageInfo_Replicas.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Replicas proto.InternalMessageInfo
+
+func (m *Replicas) GetIds() []uint32 {
+	if m != nil {
+		return m.Ids
+	}
+	return nil
+}
+
+// ReassignRequest fields correspond to the
+// equivalent topicmappr rebuild flags.
+type ReassignRequest struct {
+	// Topic names to rebuild. Names containing regular expression
+	// metacharacters are used as regular expressions.
+	Topics []string `protobuf:"bytes,1,rep,name=topics,proto3" json:"topics,omitempty"`
+	// The target broker list.
+	Brokers []uint32 `protobuf:"varint,2,rep,packed,name=brokers,proto3" json:"brokers,omitempty"`
+	// Placement strategy: [count, storage] (default count).
+	Placement string `protobuf:"bytes,3,opt,name=placement,proto3" json:"placement,omitempty"`
+	// Storage placement optimization: [distribution, storage]
+	// (default distribution).
+	Optimize string `protobuf:"bytes,4,opt,name=optimize,proto3" json:"optimize,omitempty"`
+	// Normalize the replication factor (0 is a no-op).
+	Replication  uint32 `protobuf:"varint,5,opt,name=replication,proto3" json:"replication,omitempty"`
+	ForceRebuild bool   `protobuf:"varint,6,opt,name=force_rebuild,json=forceRebuild,proto3" json:"forceRebuild,omitempty"`
+	// Partition size multiplier for storage placement (default 1).
+	PartitionSizeFactor  float64  `protobuf:"fixed64,7,opt,name=partition_size_factor,json=partitionSizeFactor,proto3" json:"partitionSizeFactor,omitempty"`
+	IgnoreWarns          bool     `protobuf:"varint,8,opt,name=ignore_warns,json=ignoreWarns,proto3" json:"ignoreWarns,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ReassignRequest) Reset()         { *m = ReassignRequest{} }
+func (m *ReassignRequest) String() string { return proto.CompactTextString(m) }
+func (*ReassignRequest) ProtoMessage()    {}
+func (*ReassignRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_4215e5fe8e6d7e5d, []int{17}
+}
+
+func (m *ReassignRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ReassignRequest.Unmarshal(m, b)
+}
+func (m *ReassignRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ReassignRequest.Marshal(b, m, deterministic)
+}
+func (m *ReassignRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ReassignRequest.Merge(m, src)
+}
+func (m *ReassignRequest) XXX_Size() int {
+	return xxx_messageInfo_ReassignRequest.Size(m)
+}
+func (m *ReassignRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ReassignRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ReassignRequest proto.InternalMessageInfo
+
+func (m *ReassignRequest) GetTopics() []string {
+	if m != nil {
+		return m.Topics
+	}
+	return nil
+}
+
+func (m *ReassignRequest) GetBrokers() []uint32 {
+	if m != nil {
+		return m.Brokers
+	}
+	return nil
+}
+
+func (m *ReassignRequest) GetPlacement() string {
+	if m != nil {
+		return m.Placement
+	}
+	return ""
+}
+
+func (m *ReassignRequest) GetOptimize() string {
+	if m != nil {
+		return m.Optimize
+	}
+	return ""
+}
+
+func (m *ReassignRequest) GetReplication() uint32 {
+	if m != nil {
+		return m.Replication
+	}
+	return 0
+}
+
+func (m *ReassignRequest) GetForceRebuild() bool {
+	if m != nil {
+		return m.ForceRebuild
+	}
+	return false
+}
+
+func (m *ReassignRequest) GetPartitionSizeFactor() float64 {
+	if m != nil {
+		return m.PartitionSizeFactor
+	}
+	return 0
+}
+
+func (m *ReassignRequest) GetIgnoreWarns() bool {
+	if m != nil {
+		return m.IgnoreWarns
+	}
+	return false
+}
+
+type ReassignResponse struct {
+	// The reassignment plan as Kafka reassignment JSON.
+	Plan                 string                   `protobuf:"bytes,1,opt,name=plan,proto3" json:"plan,omitempty"`
+	Partitions           []*PartitionReassignment `protobuf:"bytes,2,rep,name=partitions,proto3" json:"partitions,omitempty"`
+	Warnings             []string                 `protobuf:"bytes,3,rep,name=warnings,proto3" json:"warnings,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}                 `json:"-"`
+	XXX_unrecognized     []byte                   `json:"-"`
+	XXX_sizecache        int32                    `json:"-"`
+}
+
+func (m *ReassignResponse) Reset()         { *m = ReassignResponse{} }
+func (m *ReassignResponse) String() string { return proto.CompactTextString(m) }
+func (*ReassignResponse) ProtoMessage()    {}
+func (*ReassignResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_4215e5fe8e6d7e5d, []int{18}
+}
+
+func (m *ReassignResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ReassignResponse.Unmarshal(m, b)
+}
+func (m *ReassignResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ReassignResponse.Marshal(b, m, deterministic)
+}
+func (m *ReassignResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ReassignResponse.Merge(m, src)
+}
+func (m *ReassignResponse) XXX_Size() int {
+	return xxx_messageInfo_ReassignResponse.Size(m)
+}
+func (m *ReassignResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_ReassignResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ReassignResponse proto.InternalMessageInfo
+
+func (m *ReassignResponse) GetPlan() string {
+	if m != nil {
+		return m.Plan
+	}
+	return ""
+}
+
+func (m *ReassignResponse) GetPartitions() []*PartitionReassignment {
+	if m != nil {
+		return m.Partitions
+	}
+	return nil
+}
+
+func (m *ReassignResponse) GetWarnings() []string {
+	if m != nil {
+		return m.Warnings
+	}
+	return nil
+}
+
+type PartitionReassignment struct {
+	Topic                string   `protobuf:"bytes,1,opt,name=topic,proto3" json:"topic,omitempty"`
+	Partition            uint32   `protobuf:"varint,2,opt,name=partition,proto3" json:"partition,omitempty"`
+	Replicas             []uint32 `protobuf:"varint,3,rep,packed,name=replicas,proto3" json:"replicas,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *PartitionReassignment) Reset()         { *m = PartitionReassignment{} }
+func (m *PartitionReassignment) String() string { return proto.CompactTextString(m) }
+func (*PartitionReassignment) ProtoMessage()    {}
+func (*PartitionReassignment) Descriptor() ([]byte, []int) {
+	return fileDescriptor_4215e5fe8e6d7e5d, []int{19}
+}
+
+func (m *PartitionReassignment) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_PartitionReassignment.Unmarshal(m, b)
+}
+func (m *PartitionReassignment) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_PartitionReassignment.Marshal(b, m, deterministic)
+}
+func (m *PartitionReassignment) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_PartitionReassignment.Merge(m, src)
+}
+func (m *PartitionReassignment) XXX_Size() int {
+	return xxx_messageInfo_PartitionReassignment.Size(m)
+}
+func (m *PartitionReassignment) XXX_DiscardUnknown() {
+	xxx_messageInfo_PartitionReassignment.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_PartitionReassignment proto.InternalMessageInfo
+
+func (m *PartitionReassignment) GetTopic() string {
+	if m != nil {
+		return m.Topic
+	}
+	return ""
+}
+
+func (m *PartitionReassignment) GetPartition() uint32 {
+	if m != nil {
+		return m.Partition
+	}
+	return 0
+}
+
+func (m *PartitionReassignment) GetReplicas() []uint32 {
+	if m != nil {
+		return m.Replicas
+	}
+	return nil
+}
+
+type Event struct {
+	Type Event_Type `protobuf:"varint,1,opt,name=type,proto3,enum=registry.Event.Type" json:"type,omitempty"`
+	// Sequence increases by one for each event observed
+	// by the server, such that events in a stream have
+	// consecutive sequence numbers.
+	Sequence  uint64 `protobuf:"varint,2,opt,name=sequence,proto3" json:"sequence,omitempty"`
+	Timestamp int64  `protobuf:"varint,3,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	// The affected object. For departures and deletions,
+	// this is the last state observed.
+	Broker               *Broker  `protobuf:"bytes,4,opt,name=broker,proto3" json:"broker,omitempty"`
+	Topic                *Topic   `protobuf:"bytes,5,opt,name=topic,proto3" json:"topic,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Event) Reset()         { *m = Event{} }
+func (m *Event) String() string { return proto.CompactTextString(m) }
+func (*Event) ProtoMessage()    {}
+func (*Event) Descriptor() ([]byte, []int) {
+	return fileDescriptor_4215e5fe8e6d7e5d, []int{20}
+}
+
+func (m *Event) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Event.Unmarshal(m, b)
+}
+func (m *Event) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Event.Marshal(b, m, deterministic)
+}
+func (m *Event) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Event.Merge(m, src)
+}
+func (m *Event) XXX_Size() int {
+	return xxx_messageInfo_Event.Size(m)
+}
+func (m *Event) XXX_DiscardUnknown() {
+	xxx_messageInfo_Event.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Event proto.InternalMessageInfo
+
+func (m *Event) GetType() Event_Type {
+	if m != nil {
+		return m.Type
+	}
+	return Event_UNKNOWN
+}
+
+func (m *Event) GetSequence() uint64 {
 	if m != nil {
-		return m.Tags
+		return m.Sequence
 	}
-	return nil
+	return 0
 }
 
-func (m *Topic) GetName() string {
+func (m *Event) GetTimestamp() int64 {
 	if m != nil {
-		return m.Name
+		return m.Timestamp
 	}
-	return ""
+	return 0
 }
 
-func (m *Topic) GetPartitions() uint32 {
+func (m *Event) GetBroker() *Broker {
 	if m != nil {
-		return m.Partitions
+		return m.Broker
 	}
-	return 0
+	return nil
 }
 
-func (m *Topic) GetReplication() uint32 {
+func (m *Event) GetTopic() *Topic {
 	if m != nil {
-		return m.Replication
+		return m.Topic
 	}
-	return 0
+	return nil
 }
 
 func init() {
+	proto.RegisterEnum("registry.WatchBrokersRequest_Control", WatchBrokersRequest_Control_name, WatchBrokersRequest_Control_value)
+	proto.RegisterEnum("registry.BrokerRequest_State", BrokerRequest_State_name, BrokerRequest_State_value)
+	proto.RegisterEnum("registry.BrokerRequest_SortBy", BrokerRequest_SortBy_name, BrokerRequest_SortBy_value)
+	proto.RegisterEnum("registry.Event_Type", Event_Type_name, Event_Type_value)
+	proto.RegisterType((*Empty)(nil), "registry.Empty")
 	proto.RegisterType((*TagResponse)(nil), "registry.TagResponse")
+	proto.RegisterType((*BulkTagResponse)(nil), "registry.BulkTagResponse")
+	proto.RegisterMapType((map[uint32]string)(nil), "registry.BulkTagResponse.ResultsEntry")
+	proto.RegisterType((*WatchBrokersRequest)(nil), "registry.WatchBrokersRequest")
 	proto.RegisterType((*BrokerRequest)(nil), "registry.BrokerRequest")
+	proto.RegisterType((*BrokerTagsRequest)(nil), "registry.BrokerTagsRequest")
 	proto.RegisterType((*BrokerResponse)(nil), "registry.BrokerResponse")
 	proto.RegisterMapType((map[uint32]*Broker)(nil), "registry.BrokerResponse.BrokersEntry")
 	proto.RegisterType((*Broker)(nil), "registry.Broker")
 	proto.RegisterMapType((map[string]string)(nil), "registry.Broker.ListenersecurityprotocolmapEntry")
 	proto.RegisterMapType((map[string]string)(nil), "registry.Broker.TagsEntry")
 	proto.RegisterType((*TopicRequest)(nil), "registry.TopicRequest")
+	proto.RegisterType((*CreateTopicRequest)(nil), "registry.CreateTopicRequest")
+	proto.RegisterType((*PartitionAssignment)(nil), "registry.PartitionAssignment")
+	proto.RegisterType((*TopicExistsResponse)(nil), "registry.TopicExistsResponse")
 	proto.RegisterType((*TopicResponse)(nil), "registry.TopicResponse")
+	proto.RegisterMapType((map[string]string)(nil), "registry.TopicResponse.ErrorsEntry")
 	proto.RegisterMapType((map[string]*Topic)(nil), "registry.TopicResponse.TopicsEntry")
+	proto.RegisterType((*TopicConfigResponse)(nil), "registry.TopicConfigResponse")
+	proto.RegisterMapType((map[string]*TopicConfig)(nil), "registry.TopicConfigResponse.ConfigsEntry")
+	proto.RegisterType((*TopicConfig)(nil), "registry.TopicConfig")
+	proto.RegisterMapType((map[string]string)(nil), "registry.TopicConfig.ConfigEntry")
 	proto.RegisterType((*Topic)(nil), "registry.Topic")
+	proto.RegisterMapType((map[uint32]*Replicas)(nil), "registry.Topic.AssignmentsEntry")
 	proto.RegisterMapType((map[string]string)(nil), "registry.Topic.TagsEntry")
+	proto.RegisterType((*Replicas)(nil), "registry.Replicas")
+	proto.RegisterType((*ReassignRequest)(nil), "registry.ReassignRequest")
+	proto.RegisterType((*ReassignResponse)(nil), "registry.ReassignResponse")
+	proto.RegisterType((*PartitionReassignment)(nil), "registry.PartitionReassignment")
+	proto.RegisterType((*Event)(nil), "registry.Event")
 }
 
 func init() { proto.RegisterFile("protos/registry.proto", fileDescriptor_4215e5fe8e6d7e5d) }
 
 var fileDescriptor_4215e5fe8e6d7e5d = []byte{
-	// 806 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xa4, 0x95, 0xdd, 0x6e, 0xe3, 0x44,
-	0x14, 0xc7, 0xe5, 0xa4, 0xf9, 0xf0, 0x71, 0xd2, 0x86, 0xa1, 0x6d, 0xa6, 0x6e, 0x41, 0xc6, 0xa8,
-	0x10, 0x55, 0x22, 0x56, 0x03, 0x12, 0x08, 0x2e, 0x90, 0x10, 0xa8, 0x12, 0x2a, 0x08, 0x59, 0x11,
-	0x02, 0x6e, 0x60, 0x9a, 0x8c, 0xcc, 0xd0, 0xc4, 0x36, 0x9e, 0x69, 0x45, 0x54, 0xf5, 0x86, 0x57,
-	0xd8, 0xb7, 0xd8, 0x8b, 0x95, 0xf6, 0x21, 0xf6, 0x09, 0xf6, 0x15, 0xf6, 0x09, 0xf6, 0x09, 0x56,
-	0x73, 0xc6, 0x6e, 0x9c, 0xa6, 0xde, 0xd5, 0x66, 0xef, 0x66, 0x4e, 0xce, 0xf9, 0x9d, 0xaf, 0x7f,
-	0xc6, 0xb0, 0x97, 0x66, 0x89, 0x4a, 0x64, 0x90, 0xf1, 0x48, 0x48, 0x95, 0x2d, 0x86, 0x78, 0x27,
-	0xed, 0xe2, 0xee, 0x1e, 0x45, 0x49, 0x12, 0xcd, 0x78, 0xc0, 0x52, 0x11, 0xb0, 0x38, 0x4e, 0x14,
-	0x53, 0x22, 0x89, 0xa5, 0xf1, 0xf3, 0x3f, 0x05, 0x67, 0xcc, 0xa2, 0x90, 0xcb, 0x34, 0x89, 0x25,
-	0x27, 0x14, 0x5a, 0x73, 0x2e, 0x25, 0x8b, 0x38, 0xb5, 0x3c, 0x6b, 0x60, 0x87, 0xc5, 0xd5, 0x3f,
-	0x85, 0xee, 0x77, 0x59, 0x72, 0xc9, 0xb3, 0x90, 0xff, 0x7b, 0xc5, 0xa5, 0x22, 0x3d, 0xa8, 0x2b,
-	0x16, 0x51, 0xcb, 0xab, 0x0f, 0xec, 0x50, 0x1f, 0xc9, 0x36, 0xd4, 0xc4, 0x94, 0xd6, 0x3c, 0x6b,
-	0xd0, 0x0d, 0x6b, 0x62, 0xea, 0x3f, 0xb5, 0x60, 0xbb, 0x88, 0xc9, 0xf9, 0xdf, 0x42, 0xeb, 0x02,
-	0x2d, 0x92, 0x36, 0xbc, 0xfa, 0xc0, 0x19, 0x1d, 0x0f, 0xef, 0x0a, 0x5f, 0x75, 0xcd, 0xaf, 0xf2,
-	0x87, 0x58, 0x65, 0x8b, 0xb0, 0x88, 0xd2, 0x59, 0xc5, 0x54, 0xd2, 0xa6, 0x57, 0x1f, 0x74, 0x43,
-	0x7d, 0x74, 0xcf, 0xa1, 0x53, 0x76, 0xd5, 0x1e, 0x97, 0x7c, 0x81, 0xe5, 0x77, 0x43, 0x7d, 0x24,
-	0x9f, 0x40, 0xe3, 0x9a, 0xcd, 0xae, 0x38, 0x96, 0xe6, 0x8c, 0x7a, 0x6b, 0x29, 0xcd, 0xcf, 0x5f,
-	0xd7, 0xbe, 0xb2, 0xfc, 0x97, 0x75, 0x68, 0x1a, 0x2b, 0x19, 0xc2, 0x96, 0x62, 0x91, 0xc4, 0x0e,
-	0x9d, 0x91, 0x7b, 0x3f, 0x6a, 0x38, 0x66, 0x51, 0x5e, 0x1d, 0xfa, 0xe5, 0xed, 0x37, 0x8a, 0xf6,
-	0x89, 0x84, 0xc3, 0x99, 0x90, 0x8a, 0xc7, 0x3c, 0x93, 0x7c, 0x72, 0x95, 0x09, 0xb5, 0xc0, 0x99,
-	0x4f, 0x92, 0xd9, 0x9c, 0xa5, 0xd8, 0x82, 0x33, 0x3a, 0x5d, 0xc3, 0x9e, 0x57, 0xc7, 0x98, 0x6c,
-	0xaf, 0xa3, 0x92, 0x23, 0xb0, 0x79, 0x3c, 0x4d, 0x13, 0x11, 0x2b, 0x49, 0x5b, 0xb8, 0x9b, 0xa5,
-	0x81, 0x10, 0xd8, 0xca, 0xd8, 0xe4, 0x92, 0xb6, 0x71, 0xb7, 0x78, 0xd6, 0x2b, 0xff, 0x67, 0xfe,
-	0x5f, 0x9a, 0x64, 0x8a, 0xda, 0x58, 0x7b, 0x71, 0xd5, 0xde, 0x7f, 0x27, 0x52, 0x51, 0x30, 0xde,
-	0xfa, 0xac, 0xf9, 0x4a, 0xcc, 0xb9, 0x54, 0x6c, 0x9e, 0x52, 0xc7, 0xb3, 0x06, 0xf5, 0x70, 0x69,
-	0xd0, 0x11, 0x08, 0xea, 0x20, 0x08, 0xcf, 0x9a, 0x7f, 0xcd, 0x33, 0x29, 0x92, 0x98, 0x76, 0x0d,
-	0x3f, 0xbf, 0xba, 0x5f, 0x82, 0x7d, 0x37, 0xc3, 0xf2, 0xda, 0x6c, 0xb3, 0xb6, 0xdd, 0xf2, 0xda,
-	0xec, 0xd2, 0x92, 0xdc, 0x9f, 0xc1, 0x7b, 0xd3, 0x94, 0xde, 0x86, 0xe7, 0x7f, 0x01, 0x9d, 0x71,
-	0x92, 0x8a, 0x49, 0xb5, 0xb4, 0x09, 0x6c, 0xc5, 0x6c, 0x5e, 0x84, 0xe2, 0xd9, 0x7f, 0x62, 0x41,
-	0x37, 0x0f, 0xcb, 0xd5, 0xfd, 0x0d, 0x34, 0x95, 0x36, 0x14, 0xe2, 0xfe, 0x78, 0xb9, 0xdc, 0x15,
-	0x47, 0x73, 0xcb, 0xc5, 0x93, 0x87, 0xe8, 0xf2, 0x34, 0xd6, 0x68, 0xdb, 0x0e, 0xcd, 0xc5, 0xfd,
-	0x11, 0x9c, 0x92, 0xf3, 0x03, 0x5d, 0x1d, 0xaf, 0x8a, 0x7b, 0xe7, 0x7e, 0xca, 0x52, 0x9b, 0xcf,
-	0x2c, 0x68, 0xa0, 0x91, 0x7c, 0xb6, 0x22, 0xed, 0x83, 0x7b, 0x31, 0x6b, 0xca, 0x2e, 0xba, 0x6f,
-	0x2c, 0xbb, 0x27, 0x1f, 0x02, 0xa4, 0x2c, 0x53, 0x02, 0x1f, 0x13, 0xda, 0xc4, 0xcd, 0x96, 0x2c,
-	0xc4, 0x03, 0x27, 0xe3, 0xe9, 0x4c, 0x4c, 0xf0, 0xb9, 0xa1, 0x2d, 0x74, 0x28, 0x9b, 0x36, 0x5e,
-	0xff, 0xe8, 0x71, 0x0b, 0xda, 0x61, 0x5e, 0x31, 0x19, 0x03, 0x9c, 0x71, 0x95, 0xbf, 0x00, 0xa4,
-	0xbf, 0xfe, 0x9c, 0xe0, 0x4a, 0x5d, 0x5a, 0xf5, 0xce, 0xf8, 0xef, 0xff, 0xff, 0xfc, 0xc5, 0xa3,
-	0x5a, 0x97, 0x38, 0xc1, 0xf5, 0x69, 0x50, 0x3c, 0x33, 0x7f, 0x80, 0xa3, 0x15, 0xf6, 0x0e, 0x58,
-	0x8a, 0x58, 0x42, 0x7a, 0x25, 0x6c, 0xa0, 0xff, 0xb9, 0xe4, 0x17, 0xb0, 0xcf, 0xb8, 0x32, 0x5b,
-	0x25, 0xfb, 0x6b, 0x12, 0x31, 0xe0, 0x7e, 0x85, 0x74, 0x7c, 0x82, 0xdc, 0x0e, 0x01, 0xcd, 0xcd,
-	0xa5, 0xf3, 0x2b, 0x80, 0xae, 0x76, 0x53, 0x64, 0x1f, 0x91, 0xef, 0x91, 0x9d, 0x25, 0xd2, 0x54,
-	0x3a, 0xcd, 0x05, 0xfe, 0x13, 0x4b, 0x53, 0x11, 0x47, 0xd5, 0xe8, 0xea, 0x31, 0x7c, 0x84, 0xec,
-	0x43, 0x72, 0xa0, 0xd9, 0xf3, 0x9c, 0x63, 0x92, 0x04, 0x37, 0x5a, 0x48, 0xb7, 0x64, 0x5a, 0x7c,
-	0x25, 0xee, 0xd2, 0x54, 0x8e, 0xbb, 0xb2, 0x05, 0x0f, 0xd3, 0xb8, 0x84, 0xae, 0xa4, 0x31, 0x63,
-	0x0f, 0x6e, 0xc4, 0xf4, 0x96, 0xfc, 0x06, 0xed, 0x31, 0x8b, 0x8c, 0xfc, 0xab, 0xda, 0xd8, 0x2b,
-	0xd9, 0x97, 0x1f, 0x45, 0xff, 0x03, 0x84, 0xf7, 0xdd, 0xbd, 0xd2, 0x7c, 0x14, 0x8b, 0x8a, 0xfa,
-	0xff, 0x84, 0x9d, 0xef, 0xf9, 0x8c, 0x2b, 0x8e, 0x2c, 0x2d, 0xe9, 0x0d, 0x13, 0x9c, 0x54, 0x24,
-	0xf8, 0x1d, 0xff, 0x28, 0xf9, 0x57, 0xa9, 0x72, 0x36, 0x15, 0xec, 0x23, 0x64, 0xef, 0xbb, 0xbb,
-	0x65, 0x1d, 0x22, 0x5c, 0x4f, 0xe5, 0x2f, 0xe8, 0x99, 0xda, 0x0d, 0x0b, 0x8b, 0xdf, 0x30, 0xc3,
-	0xc9, 0x83, 0x19, 0x2e, 0x9a, 0xf8, 0x32, 0x7f, 0xfe, 0x2a, 0x00, 0x00, 0xff, 0xff, 0xdf, 0x2e,
-	0x52, 0xc5, 0xa8, 0x08, 0x00, 0x00,
+	// 2100 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xa4, 0x58, 0x4f, 0x73, 0x1b, 0x49,
+	0x15, 0xcf, 0xe8, 0xbf, 0xde, 0x48, 0xd6, 0xb8, 0x9d, 0x38, 0x13, 0xe5, 0x0f, 0xce, 0x6c, 0x25,
+	0x18, 0xc3, 0xda, 0x1b, 0xef, 0x21, 0x6c, 0x28, 0x2a, 0xf8, 0xcf, 0xd8, 0xe5, 0x8d, 0x23, 0x9b,
+	0xb1, 0x4c, 0x08, 0x14, 0x88, 0x89, 0xa6, 0xad, 0x1d, 0x2c, 0xcd, 0x88, 0x99, 0x96, 0x37, 0xca,
+	0xd6, 0x72, 0x80, 0x0f, 0xc0, 0x81, 0x23, 0x07, 0x6e, 0x1c, 0xb9, 0x50, 0x7c, 0x00, 0xaa, 0xf8,
+	0x06, 0x1c, 0x29, 0x6e, 0x7c, 0x10, 0xaa, 0x5f, 0xf7, 0xcc, 0xb4, 0x64, 0xc9, 0x21, 0xde, 0x93,
+	0xe6, 0xbd, 0x7e, 0xfd, 0x7b, 0xaf, 0xdf, 0xeb, 0xf7, 0x47, 0x0d, 0xb7, 0x86, 0x51, 0xc8, 0xc2,
+	0x78, 0x23, 0xa2, 0x3d, 0x3f, 0x66, 0xd1, 0x78, 0x1d, 0x69, 0x52, 0x49, 0xe8, 0xe6, 0xbd, 0x5e,
+	0x18, 0xf6, 0xfa, 0x74, 0xc3, 0x1d, 0xfa, 0x1b, 0x6e, 0x10, 0x84, 0xcc, 0x65, 0x7e, 0x18, 0xc4,
+	0x42, 0xce, 0x2a, 0x43, 0xd1, 0x1e, 0x0c, 0xd9, 0xd8, 0xfa, 0x36, 0xe8, 0x6d, 0xb7, 0xe7, 0xd0,
+	0x78, 0x18, 0x06, 0x31, 0x25, 0x26, 0x94, 0x07, 0x34, 0x8e, 0xdd, 0x1e, 0x35, 0xb5, 0x15, 0x6d,
+	0xb5, 0xea, 0x24, 0xa4, 0xf5, 0x07, 0x0d, 0x1a, 0xdb, 0xa3, 0xfe, 0xb9, 0x2a, 0xfd, 0x23, 0x28,
+	0x47, 0x34, 0x1e, 0xf5, 0x59, 0x6c, 0x6a, 0x2b, 0xf9, 0x55, 0x7d, 0xf3, 0xf1, 0x7a, 0x6a, 0xcf,
+	0x94, 0xec, 0xba, 0x23, 0x04, 0xed, 0x80, 0x45, 0x63, 0x27, 0xd9, 0xd6, 0x7c, 0x06, 0x35, 0x75,
+	0x81, 0x18, 0x90, 0x3f, 0xa7, 0x63, 0xd4, 0x5d, 0x77, 0xf8, 0x27, 0xb9, 0x09, 0xc5, 0x0b, 0xb7,
+	0x3f, 0xa2, 0x66, 0x0e, 0xed, 0x11, 0xc4, 0xb3, 0xdc, 0xf7, 0x35, 0xeb, 0xef, 0x1a, 0x2c, 0xbd,
+	0x72, 0x59, 0xf7, 0x8b, 0xed, 0x28, 0x3c, 0xa7, 0x51, 0xec, 0xd0, 0xdf, 0x8c, 0x68, 0xcc, 0xc8,
+	0x13, 0x6e, 0x15, 0x7e, 0x22, 0x8e, 0xbe, 0x79, 0x5b, 0xb1, 0x0a, 0x45, 0xa5, 0xa4, 0x93, 0xc8,
+	0x91, 0xe7, 0x50, 0xee, 0x86, 0x01, 0x8b, 0xc2, 0x3e, 0xaa, 0x59, 0xd8, 0x7c, 0x94, 0x6d, 0x99,
+	0xa1, 0x62, 0x7d, 0x47, 0x08, 0x3b, 0xc9, 0x2e, 0x6b, 0x0d, 0xca, 0x92, 0x47, 0x2a, 0x50, 0x68,
+	0x1d, 0xb5, 0x6c, 0xe3, 0x06, 0xa9, 0x42, 0xf1, 0x78, 0xeb, 0xf4, 0xc4, 0x36, 0x34, 0x02, 0x50,
+	0x72, 0xec, 0x93, 0xd3, 0x97, 0xb6, 0x91, 0xb3, 0xfe, 0x9c, 0x87, 0xfa, 0x84, 0x1d, 0xfc, 0xd4,
+	0xcc, 0xed, 0xa1, 0x0f, 0xab, 0x0e, 0xff, 0x24, 0x0b, 0x90, 0xf3, 0x3d, 0xb4, 0xa5, 0xee, 0xe4,
+	0x7c, 0x8f, 0x7c, 0x07, 0x0c, 0x3f, 0xe8, 0xf6, 0x47, 0x1e, 0xed, 0x0c, 0x28, 0x73, 0x3d, 0x97,
+	0xb9, 0x66, 0x7e, 0x45, 0x5b, 0xad, 0x38, 0x0d, 0xc9, 0x7f, 0x29, 0xd9, 0xe4, 0x53, 0x28, 0xc6,
+	0xcc, 0x65, 0xd4, 0x2c, 0xe0, 0x49, 0xee, 0xcf, 0x39, 0xfc, 0xfa, 0x09, 0x17, 0x72, 0x84, 0x2c,
+	0xb9, 0x0b, 0xd5, 0xa1, 0xdb, 0xa3, 0x9d, 0xd8, 0x7f, 0x47, 0xcd, 0x22, 0xaa, 0xad, 0x70, 0xc6,
+	0x89, 0xff, 0x8e, 0x92, 0xfb, 0x00, 0xb8, 0xc8, 0xc2, 0x73, 0x1a, 0x98, 0x25, 0x8c, 0x03, 0x8a,
+	0xb7, 0x39, 0x83, 0x3c, 0x85, 0x72, 0x1c, 0x46, 0xac, 0xf3, 0x66, 0x6c, 0x96, 0x51, 0xe5, 0x83,
+	0xb9, 0x2a, 0xc3, 0x88, 0x6d, 0x8f, 0x9d, 0x52, 0x8c, 0xbf, 0x64, 0x19, 0x4a, 0x67, 0x3e, 0xed,
+	0x7b, 0xb1, 0x59, 0xc1, 0x93, 0x4b, 0x8a, 0x3c, 0x82, 0x05, 0xe6, 0x46, 0x3d, 0xca, 0x3a, 0x6f,
+	0x84, 0xdb, 0xcd, 0xea, 0x4a, 0x7e, 0xb5, 0xee, 0xd4, 0x05, 0x57, 0xc6, 0xc2, 0x5a, 0x83, 0x22,
+	0x9e, 0x81, 0x94, 0x21, 0xbf, 0xd5, 0x7a, 0x6d, 0xdc, 0x20, 0x3a, 0x94, 0x5f, 0x1e, 0x9c, 0x9c,
+	0x1c, 0xb4, 0xf6, 0x0d, 0x8d, 0x13, 0x8e, 0x7d, 0x7c, 0xb8, 0xb5, 0xc3, 0x7d, 0xfe, 0x3d, 0x28,
+	0x09, 0xe5, 0xa4, 0x04, 0xb9, 0x83, 0x5d, 0xe3, 0x06, 0x31, 0xa0, 0x76, 0xd2, 0x3e, 0x72, 0xb6,
+	0xf6, 0xed, 0xce, 0x9e, 0x63, 0xf3, 0x18, 0x55, 0xa0, 0x70, 0x7a, 0x62, 0xef, 0x1a, 0x39, 0xeb,
+	0x29, 0x2c, 0x0a, 0x25, 0x6d, 0xb7, 0x17, 0xcf, 0x0f, 0x92, 0x01, 0x79, 0xdf, 0x8b, 0xcd, 0x1c,
+	0x1a, 0xc7, 0x3f, 0xad, 0x7f, 0xe4, 0x60, 0x21, 0x39, 0xb2, 0xcc, 0x91, 0xe7, 0x50, 0x4e, 0x4e,
+	0x51, 0xc4, 0x1c, 0x79, 0x74, 0xd9, 0x3b, 0x32, 0x45, 0x04, 0x99, 0xa4, 0x88, 0xdc, 0x95, 0x68,
+	0x29, 0xa5, 0x5a, 0xc8, 0x63, 0x68, 0x04, 0xf4, 0x2d, 0xeb, 0x28, 0x41, 0x29, 0x63, 0x50, 0xea,
+	0x9c, 0x7d, 0x9c, 0x06, 0xa6, 0x09, 0x95, 0x2f, 0xdd, 0x28, 0xf0, 0x83, 0x5e, 0xe2, 0xe1, 0x94,
+	0xe6, 0x69, 0xe5, 0x7a, 0x1e, 0xf5, 0xa4, 0x6b, 0x05, 0xc1, 0xd3, 0x3f, 0xa2, 0x83, 0xf0, 0x82,
+	0x7a, 0x26, 0x20, 0x3f, 0x21, 0x39, 0x56, 0x44, 0x87, 0x7d, 0x77, 0x4c, 0x3d, 0x53, 0xc7, 0x8b,
+	0x97, 0xd2, 0xcd, 0x43, 0xa8, 0xa9, 0xa6, 0xcf, 0x48, 0xe2, 0xc7, 0x6a, 0x12, 0xeb, 0x9b, 0xc6,
+	0x25, 0x17, 0x28, 0x69, 0xfd, 0xcf, 0x02, 0x94, 0x04, 0x97, 0xac, 0x43, 0x81, 0xb9, 0xbd, 0xa4,
+	0xb8, 0x34, 0xa7, 0x77, 0xad, 0xf3, 0xf0, 0x08, 0x6f, 0xa1, 0x9c, 0xcc, 0x9a, 0x62, 0x9a, 0x35,
+	0x31, 0xdc, 0xed, 0xfb, 0x31, 0xa3, 0x01, 0x8d, 0x62, 0xda, 0x1d, 0x45, 0x3e, 0x1b, 0x63, 0xf9,
+	0xeb, 0x86, 0xfd, 0x81, 0x3b, 0x44, 0x97, 0xea, 0x9b, 0x4f, 0x2e, 0xc1, 0x1e, 0xce, 0xdf, 0x23,
+	0xb4, 0x5d, 0x85, 0x4a, 0xee, 0x41, 0x95, 0x06, 0xde, 0x30, 0xf4, 0x03, 0x16, 0x9b, 0x65, 0x74,
+	0x7b, 0xc6, 0x20, 0x04, 0x0a, 0x91, 0xdb, 0x3d, 0x37, 0x2b, 0x18, 0x30, 0xfc, 0xe6, 0x5e, 0xff,
+	0xf5, 0xe0, 0xed, 0x30, 0x8c, 0x98, 0x59, 0x45, 0xdb, 0x13, 0x92, 0x4b, 0x7f, 0x11, 0xc6, 0xcc,
+	0x04, 0x21, 0xcd, 0xbf, 0x39, 0x3e, 0xf3, 0x07, 0x34, 0x66, 0xee, 0x60, 0x88, 0xa1, 0xc8, 0x3b,
+	0x19, 0x83, 0xef, 0x40, 0xa0, 0x1a, 0x02, 0xe1, 0x37, 0xc7, 0xbf, 0xa0, 0x51, 0xec, 0x87, 0x81,
+	0x59, 0x17, 0xf8, 0x92, 0x24, 0x0f, 0xa1, 0x16, 0xb3, 0x30, 0xe2, 0xf7, 0xe8, 0x2c, 0xa2, 0xd4,
+	0x5c, 0x58, 0xd1, 0x56, 0x35, 0x47, 0x97, 0xbc, 0xbd, 0x88, 0x52, 0xf2, 0x31, 0x90, 0x01, 0x65,
+	0x91, 0xdf, 0x8d, 0x3b, 0x7e, 0xd0, 0x0d, 0x07, 0xc3, 0x3e, 0x65, 0xd4, 0x6c, 0xe0, 0x15, 0x58,
+	0x94, 0x2b, 0x07, 0xe9, 0x42, 0xf3, 0x29, 0x54, 0xd3, 0xa8, 0xa8, 0x17, 0xa1, 0xfa, 0x9e, 0x6a,
+	0xde, 0x6c, 0xc1, 0xca, 0xfb, 0xfc, 0xfe, 0x21, 0x78, 0xd6, 0x6f, 0xa1, 0xd6, 0x0e, 0x87, 0x7e,
+	0x77, 0x7e, 0xfa, 0x12, 0x28, 0x04, 0xee, 0x20, 0xd9, 0x8a, 0xdf, 0xe4, 0x36, 0x94, 0xbd, 0x68,
+	0xdc, 0x89, 0x46, 0x81, 0x2c, 0xaf, 0x25, 0x2f, 0x1a, 0x3b, 0xa3, 0x80, 0x6c, 0xc0, 0x52, 0x52,
+	0x80, 0xdd, 0x38, 0xf6, 0x7b, 0xc1, 0x80, 0xf2, 0xf8, 0x16, 0x50, 0x88, 0xc8, 0xa5, 0xad, 0x6c,
+	0xc5, 0x7a, 0x07, 0x64, 0x27, 0xa2, 0x2e, 0xa3, 0x13, 0x56, 0x3c, 0x82, 0x22, 0xe3, 0xb4, 0xec,
+	0x4c, 0x8d, 0xec, 0xee, 0x09, 0x31, 0xb1, 0x4a, 0x7e, 0x08, 0x90, 0x69, 0xc1, 0x02, 0xa3, 0xab,
+	0x85, 0xfc, 0xd8, 0x8d, 0x98, 0xcf, 0xdb, 0x79, 0xa6, 0xd0, 0x51, 0x36, 0x58, 0x47, 0xb0, 0x34,
+	0x43, 0x84, 0xdf, 0x9c, 0x61, 0xc2, 0x96, 0xd9, 0x99, 0x31, 0x92, 0x0c, 0xf7, 0xbb, 0x6e, 0x52,
+	0xd2, 0x52, 0xda, 0x6a, 0xc3, 0x12, 0xda, 0x67, 0xbf, 0xf5, 0x63, 0x16, 0xa7, 0xb5, 0x6d, 0x19,
+	0x4a, 0x14, 0x39, 0x88, 0x56, 0x71, 0x24, 0x95, 0x9d, 0x32, 0x77, 0xd5, 0x29, 0xad, 0xbf, 0xe4,
+	0xa0, 0x2e, 0x18, 0x09, 0xe0, 0x0f, 0xa0, 0x84, 0x4b, 0x49, 0xad, 0xfc, 0x68, 0x7a, 0xa7, 0x14,
+	0x14, 0x94, 0xcc, 0x7d, 0xb9, 0x85, 0xdf, 0x05, 0x1e, 0x43, 0x51, 0x2a, 0xab, 0x8e, 0x20, 0x38,
+	0x24, 0x8d, 0xa2, 0x30, 0x12, 0xb9, 0x78, 0x05, 0xa4, 0x8d, 0x52, 0x12, 0x52, 0x6c, 0x69, 0x7e,
+	0x0e, 0xba, 0xa2, 0x69, 0xc6, 0xfd, 0x7b, 0x34, 0x59, 0xd8, 0x2e, 0x9f, 0x34, 0xbb, 0xe0, 0x9f,
+	0x81, 0xae, 0xa8, 0xf8, 0xa0, 0xbb, 0xfc, 0x57, 0x4d, 0xfa, 0x7f, 0x27, 0x0c, 0xce, 0xfc, 0x6c,
+	0xfe, 0xda, 0xc5, 0xb1, 0xe5, 0xcc, 0x4f, 0x4b, 0xe4, 0xda, 0x94, 0xfe, 0x49, 0xf9, 0x75, 0x41,
+	0x26, 0x0d, 0x46, 0x6e, 0x6d, 0xfe, 0x18, 0x6a, 0xea, 0xc2, 0x0c, 0xcb, 0xbe, 0x3b, 0x79, 0xca,
+	0x5b, 0xb3, 0xb5, 0x28, 0x06, 0xff, 0x5e, 0x03, 0x5d, 0x59, 0x22, 0x9f, 0x41, 0x49, 0x68, 0x93,
+	0x76, 0x3e, 0x9c, 0x89, 0x20, 0xed, 0x93, 0x21, 0x10, 0x1b, 0xb8, 0xdb, 0x14, 0xf6, 0x07, 0xb9,
+	0xed, 0x3f, 0x39, 0x28, 0x22, 0x3c, 0xf9, 0x78, 0xa2, 0x91, 0xdc, 0x99, 0xd2, 0x7e, 0xa9, 0x8f,
+	0x24, 0x95, 0xa1, 0xa8, 0x54, 0x86, 0x07, 0x00, 0x69, 0xae, 0xc4, 0x38, 0x04, 0xd5, 0x1d, 0x85,
+	0x43, 0x56, 0x40, 0x97, 0xe9, 0x82, 0xe9, 0x55, 0x46, 0x01, 0x95, 0x45, 0xb6, 0x41, 0x57, 0x4b,
+	0x47, 0x05, 0x6d, 0x59, 0x99, 0xb6, 0x45, 0xa9, 0x21, 0xc2, 0x24, 0x75, 0xd3, 0xf5, 0xcb, 0xab,
+	0x03, 0xc6, 0x34, 0xf2, 0x8c, 0x3e, 0xbd, 0x3a, 0x19, 0x68, 0x92, 0x19, 0xe7, 0xc8, 0x52, 0xa0,
+	0xfa, 0xf7, 0x1e, 0x54, 0x12, 0x76, 0x32, 0xa5, 0x68, 0xd9, 0x2c, 0xf4, 0xa7, 0x1c, 0x34, 0x1c,
+	0x2a, 0x8c, 0x4f, 0xca, 0xdf, 0x72, 0x9a, 0xdf, 0xa2, 0x0e, 0x4b, 0x8a, 0x77, 0xa8, 0x64, 0x48,
+	0x12, 0xa5, 0x27, 0x21, 0xb1, 0x66, 0xf5, 0xdd, 0x2e, 0xc5, 0x42, 0x98, 0x97, 0xa3, 0x67, 0xc2,
+	0xe0, 0x35, 0x2b, 0x1c, 0x32, 0x7f, 0xc0, 0xa7, 0xd6, 0x02, 0x2e, 0xa6, 0xf4, 0x74, 0x40, 0x8a,
+	0x97, 0x03, 0xf2, 0x11, 0xd4, 0xcf, 0xc2, 0xa8, 0x4b, 0x3b, 0x11, 0x7d, 0x33, 0xf2, 0xfb, 0x1e,
+	0x46, 0xb5, 0xe2, 0xd4, 0x90, 0xe9, 0x08, 0x1e, 0xd9, 0x84, 0x5b, 0x69, 0x94, 0x71, 0x3c, 0xee,
+	0x9c, 0xb9, 0x5d, 0x16, 0x46, 0x18, 0x61, 0xcd, 0x59, 0x4a, 0x17, 0xf9, 0xa8, 0xbc, 0x87, 0x4b,
+	0xbc, 0xad, 0xfa, 0xbd, 0x20, 0x8c, 0x68, 0x87, 0xcf, 0x5b, 0x31, 0x36, 0xfb, 0x8a, 0xa3, 0x0b,
+	0xde, 0x2b, 0xce, 0xe2, 0x19, 0x62, 0x64, 0xde, 0x91, 0xf9, 0xcc, 0x9b, 0x77, 0xdf, 0x0d, 0x64,
+	0x44, 0xf1, 0x9b, 0x3c, 0x9f, 0xb8, 0x77, 0xa2, 0x15, 0x7c, 0x6b, 0x46, 0x2b, 0x48, 0xc0, 0x44,
+	0x33, 0xc8, 0xb6, 0x4c, 0x4c, 0x81, 0xf9, 0xc9, 0x29, 0xd0, 0xea, 0xc1, 0xad, 0x99, 0x00, 0xfc,
+	0x22, 0x65, 0x7d, 0xaa, 0x9a, 0xb4, 0xa5, 0x89, 0x06, 0x92, 0xbb, 0xaa, 0x81, 0xe4, 0xa7, 0x1a,
+	0xc8, 0xdf, 0x72, 0x50, 0xb4, 0x2f, 0x38, 0xf2, 0x2a, 0x14, 0xd8, 0x78, 0x28, 0xfe, 0x5e, 0x2e,
+	0x6c, 0xde, 0xcc, 0x4e, 0x82, 0xcb, 0xeb, 0xed, 0xf1, 0x90, 0x3a, 0x28, 0xc1, 0xf1, 0x62, 0x7e,
+	0x6f, 0x82, 0xae, 0xb8, 0x8f, 0x05, 0x27, 0xa5, 0x27, 0x87, 0xa0, 0xfc, 0xf4, 0x10, 0xb4, 0x0a,
+	0x25, 0x71, 0x7f, 0xcc, 0xc2, 0x9c, 0x79, 0x53, 0xae, 0x67, 0x9d, 0xaa, 0x78, 0x65, 0xa7, 0x1a,
+	0x41, 0x81, 0x1b, 0xc6, 0xff, 0x53, 0x9c, 0xb6, 0x5e, 0xb4, 0x8e, 0x5e, 0xb5, 0x8c, 0x1b, 0x64,
+	0x11, 0xea, 0xdb, 0xce, 0xd1, 0x0b, 0xdb, 0xe9, 0x7c, 0x7e, 0x74, 0xd0, 0xb2, 0x77, 0x0d, 0x8d,
+	0x34, 0x40, 0x97, 0xac, 0x43, 0x7b, 0xaf, 0x6d, 0xe4, 0xb8, 0x4c, 0xfb, 0xe8, 0xf8, 0x60, 0xa7,
+	0xb3, 0xe3, 0xd8, 0x5b, 0x6d, 0x7b, 0xd7, 0xc8, 0x67, 0xac, 0x5d, 0xfb, 0xd0, 0xe6, 0xac, 0x02,
+	0x59, 0x06, 0x22, 0x58, 0x8e, 0xbd, 0x73, 0xd4, 0xda, 0x3b, 0xd8, 0x3f, 0x75, 0xec, 0x5d, 0xa3,
+	0xb8, 0xf9, 0xef, 0x05, 0x9e, 0x61, 0xc2, 0x20, 0xd2, 0x06, 0xd8, 0x4f, 0xff, 0xfc, 0x90, 0x79,
+	0xff, 0x69, 0x9b, 0xe6, 0xbc, 0xbf, 0x17, 0xd6, 0xd2, 0xef, 0xfe, 0xf5, 0xdf, 0x3f, 0xe6, 0xea,
+	0x44, 0xdf, 0xb8, 0x78, 0xb2, 0x91, 0xe4, 0xd7, 0xcf, 0x40, 0xe7, 0x63, 0xd7, 0x37, 0x80, 0x35,
+	0x11, 0x96, 0x10, 0x43, 0x81, 0xdd, 0xe0, 0x03, 0x32, 0x39, 0x86, 0xea, 0x3e, 0x65, 0xa2, 0x81,
+	0x92, 0xe5, 0x4b, 0x7d, 0x57, 0x00, 0xdf, 0x9e, 0xd3, 0x8f, 0x2d, 0x82, 0xb8, 0x35, 0x02, 0x1c,
+	0x57, 0xd6, 0x89, 0x9f, 0x00, 0x70, 0x6b, 0xaf, 0x0b, 0x79, 0x1b, 0x21, 0x17, 0x49, 0x23, 0x83,
+	0x14, 0x96, 0x7a, 0xd0, 0x48, 0x2c, 0x95, 0xad, 0x70, 0x2e, 0xf8, 0xfd, 0x2b, 0x5b, 0xac, 0xd5,
+	0x44, 0x15, 0x37, 0x09, 0x51, 0x54, 0xc8, 0x46, 0x4b, 0xce, 0x40, 0x57, 0xa6, 0xa8, 0xff, 0x5b,
+	0xc3, 0xe4, 0xd0, 0x65, 0xad, 0xa0, 0x86, 0x26, 0x31, 0x15, 0x0d, 0x62, 0xee, 0xda, 0xf8, 0x8a,
+	0x77, 0xaa, 0xaf, 0x79, 0x4c, 0x95, 0xd1, 0x93, 0xdc, 0xcb, 0xf0, 0x2e, 0x4f, 0xa4, 0x4d, 0xe5,
+	0xca, 0x8b, 0x17, 0xa1, 0x7b, 0x88, 0xbf, 0x6c, 0x2d, 0xaa, 0x27, 0xc0, 0x7d, 0xcf, 0xb4, 0x35,
+	0xf2, 0x1a, 0xf4, 0x5d, 0xda, 0xa7, 0x12, 0xe4, 0xc3, 0x43, 0x70, 0x07, 0xd1, 0x97, 0xd6, 0x54,
+	0x74, 0x0f, 0x01, 0x89, 0x27, 0xa7, 0xc1, 0x97, 0xee, 0x70, 0x88, 0xff, 0x51, 0xe7, 0x81, 0xcf,
+	0xbf, 0x8b, 0x0f, 0x11, 0xfd, 0x2e, 0xb9, 0xc3, 0xd1, 0x07, 0x12, 0x47, 0xa8, 0x49, 0x9c, 0xe3,
+	0x25, 0xff, 0xd0, 0x53, 0x35, 0x73, 0xef, 0xfc, 0xdc, 0x43, 0x4c, 0x84, 0x20, 0x55, 0x23, 0xee,
+	0xfe, 0xc6, 0x57, 0xbe, 0xf7, 0x35, 0xf9, 0x29, 0x54, 0xda, 0x6e, 0xef, 0x6a, 0x1f, 0xa9, 0x63,
+	0x54, 0xf6, 0x50, 0x66, 0xdd, 0x47, 0xf0, 0xdb, 0xcd, 0x5b, 0x8a, 0x87, 0x98, 0xdb, 0x4b, 0xec,
+	0xef, 0x40, 0x43, 0x09, 0x00, 0x1f, 0x06, 0xae, 0xa9, 0x60, 0x6d, 0x8e, 0x82, 0xd7, 0x38, 0x62,
+	0xc8, 0x7f, 0xe0, 0x73, 0x7d, 0x33, 0x07, 0x5b, 0x5e, 0x9e, 0xe6, 0x4d, 0xb5, 0x18, 0x20, 0x38,
+	0xf7, 0xca, 0x2f, 0x00, 0x52, 0xe8, 0x98, 0xdc, 0x9d, 0xc6, 0x56, 0x5e, 0x5b, 0x9a, 0x77, 0xe6,
+	0xbe, 0x24, 0x26, 0x59, 0xdc, 0x6c, 0x4c, 0xe9, 0x20, 0xbf, 0x02, 0x43, 0xb8, 0x26, 0x83, 0xbb,
+	0xee, 0x01, 0xd6, 0x66, 0x1f, 0xe0, 0xe7, 0xfc, 0xb9, 0x92, 0x3f, 0x88, 0xbc, 0xcf, 0x3d, 0xef,
+	0x2d, 0x97, 0x6b, 0x13, 0xe5, 0x12, 0xc1, 0x7f, 0x09, 0x35, 0xf5, 0xad, 0xf1, 0x3a, 0xe0, 0x32,
+	0xbb, 0xc8, 0xa2, 0x0a, 0xfe, 0x25, 0x07, 0xfd, 0x44, 0x23, 0xed, 0xc9, 0xe7, 0xd2, 0xe4, 0xbd,
+	0xf2, 0xfe, 0x95, 0x4f, 0x9d, 0x57, 0x28, 0xbb, 0xb1, 0xaa, 0x7d, 0xa2, 0x91, 0x73, 0x20, 0xc9,
+	0xe4, 0x70, 0x9c, 0x0d, 0x1d, 0x77, 0xd4, 0xc9, 0x71, 0x62, 0x06, 0x6c, 0x36, 0x67, 0x2d, 0x49,
+	0xc8, 0x07, 0x68, 0xbf, 0x69, 0x2d, 0x29, 0x57, 0x33, 0x92, 0x42, 0xbc, 0xfa, 0xbc, 0x00, 0x1d,
+	0x6d, 0xc4, 0x59, 0x21, 0x26, 0xd3, 0xb5, 0x6b, 0xa2, 0x98, 0x71, 0x91, 0xc9, 0xe6, 0x44, 0x71,
+	0x57, 0xe2, 0x8f, 0x37, 0x25, 0x7c, 0x5f, 0xf8, 0xf4, 0x7f, 0x03, 0x00, 0x8e, 0xfd, 0xb9, 0x93,
+	0x4b, 0x17, 0x00, 0x00,
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -523,6 +1674,8 @@ type RegistryClient interface {
 	// a single broker ID is returned matching the ID specified in the
 	// Broker object if the broker exists. Otherwise all brokers are returned,
 	// optionally filtered by any provided BrokerRequest.tags parameters.
+	// If BrokerRequest.include_metadata is set, the brokers field is
+	// additionally populated with full broker metadata.
 	ListBrokers(ctx context.Context, in *BrokerRequest, opts ...grpc.CallOption) (*BrokerResponse, error)
 	// GetTopics returns a TopicResponse with the topics field populated
 	// with full topic metadata. If the input TopicRequest.name field is
@@ -536,6 +1689,32 @@ type RegistryClient interface {
 	// Topic object if the topic exists. Otherwise all topics are returned,
 	// optionally filtered by any provided TopicRequest.tags parameters.
 	ListTopics(ctx context.Context, in *TopicRequest, opts ...grpc.CallOption) (*TopicResponse, error)
+	// GetTopicConfigs returns a TopicConfigResponse with the configs field
+	// populated with the dynamic config overrides stored in ZooKeeper for
+	// each topic. Topics are matched as in ListTopics. Topics with no
+	// overrides are returned with an empty config.
+	GetTopicConfigs(ctx context.Context, in *TopicRequest, opts ...grpc.CallOption) (*TopicConfigResponse, error)
+	// TopicExists returns a TopicExistsResponse with the exists field
+	// set to whether the topic specified in the TopicRequest.name field
+	// exists. If the topic exists, the topic field is populated with the
+	// topic partition count and replication factor. Registry tags are not
+	// fetched.
+	TopicExists(ctx context.Context, in *TopicRequest, opts ...grpc.CallOption) (*TopicExistsResponse, error)
+	// CreateTopic creates the topic specified in the CreateTopicRequest.topic
+	// field. If the CreateTopicRequest.assignment field is populated, the
+	// provided replica assignment is validated and used verbatim. Otherwise,
+	// partitions are placed among all registered brokers according to the
+	// topic partitions and replication fields.
+	CreateTopic(ctx context.Context, in *CreateTopicRequest, opts ...grpc.CallOption) (*Empty, error)
+	// DeleteTopic marks topics for deletion and returns a TopicResponse
+	// with the names field populated with the topics deleted. Topics are
+	// matched as in ListTopics, although at least one of the name or tag
+	// fields must be specified and the name is matched exactly rather than
+	// as a regex. If dry_run is set, the matched topics are returned without
+	// being deleted. If no topics match, a NotFound error is returned. Topics
+	// that fail to be deleted are listed in the errors field; if none were
+	// deleted, an error is returned instead.
+	DeleteTopic(ctx context.Context, in *TopicRequest, opts ...grpc.CallOption) (*TopicResponse, error)
 	// TopicMappings returns a BrokerResponse with the ids field
 	// populated with broker IDs that hold at least one partition
 	// for the requested topic. The topic is specified in the
@@ -548,20 +1727,65 @@ type RegistryClient interface {
 	BrokerMappings(ctx context.Context, in *BrokerRequest, opts ...grpc.CallOption) (*TopicResponse, error)
 	// TagTopic takes a TopicRequest and sets any specified
 	// tags for the named topic. Any existing tags that are
-	// not specified in the request are left unmodified.
+	// not specified in the request are left unmodified. Tags
+	// may be set for topics that don't exist in ZooKeeper;
+	// the TagResponse message indicates this case.
 	TagTopic(ctx context.Context, in *TopicRequest, opts ...grpc.CallOption) (*TagResponse, error)
 	// DeleteTopicTags takes a TopicRequest and deletes any
 	// specified tags for the named topic. Tags must be provided
 	// as key names only; "key:value" will not target the tag "key".
+	// Tags provisioned for nonexistent topics may be deleted.
 	DeleteTopicTags(ctx context.Context, in *TopicRequest, opts ...grpc.CallOption) (*TagResponse, error)
 	// TagBroker takes a BrokerRequest and sets any specified
 	// tags for the named broker. Any existing tags that are
-	// not specified in the request are left unmodified.
+	// not specified in the request are left unmodified. Tags
+	// may be set for broker IDs not registered in ZooKeeper;
+	// the TagResponse message indicates this case.
 	TagBroker(ctx context.Context, in *BrokerRequest, opts ...grpc.CallOption) (*TagResponse, error)
+	// TagBrokers takes a BrokerTagsRequest and sets any specified
+	// tags for each listed broker. As in TagBroker, brokers that
+	// aren't registered may be tagged. A failure for any individual
+	// broker does not abort the request; the outcome for each
+	// broker is returned in the BulkTagResponse.
+	TagBrokers(ctx context.Context, in *BrokerTagsRequest, opts ...grpc.CallOption) (*BulkTagResponse, error)
 	// DeleteBrokerTags takes a BrokerRequest and deletes any
 	// specified tags for the named broker. Tags must be provided
 	// as key names only; "key:value" will not target the tag "key".
+	// Tags provisioned for unregistered broker IDs may be deleted.
 	DeleteBrokerTags(ctx context.Context, in *BrokerRequest, opts ...grpc.CallOption) (*TagResponse, error)
+	// RemoveBroker takes a BrokerRequest and deletes all registry
+	// metadata, such as tags, for the specified broker. Kafka's own
+	// broker registration in ZooKeeper is never modified. Brokers
+	// still holding partitions can't be removed; a FailedPrecondition
+	// error listing the affected topics is returned. The BrokerResponse
+	// ids field contains the removed ID, or is empty if the broker had
+	// no registry metadata.
+	RemoveBroker(ctx context.Context, in *BrokerRequest, opts ...grpc.CallOption) (*BrokerResponse, error)
+	// WatchBrokers streams the registered brokers matching the request. The
+	// first BrokerResponse is a snapshot with the brokers and ids fields
+	// populated with the current membership, as in GetBrokers. Each time the
+	// set of matching brokers changes, a delta follows with the added and
+	// removed fields set and the brokers field holding the added brokers.
+	// Up to the last 16 membership changes observed by the server are
+	// replayed as deltas immediately following the snapshot.
+	WatchBrokers(ctx context.Context, in *BrokerRequest, opts ...grpc.CallOption) (Registry_WatchBrokersClient, error)
+	// WatchBrokersControl is a WatchBrokers stream that can be paused and
+	// resumed by the client. The first WatchBrokersRequest carries the
+	// BrokerRequest. Subsequent requests pause or resume the stream; changes
+	// made while paused are coalesced into a single delta sent on resume.
+	// It's only available over gRPC.
+	WatchBrokersControl(ctx context.Context, opts ...grpc.CallOption) (Registry_WatchBrokersControlClient, error)
+	// ReassignPartitions generates a partition reassignment plan for the
+	// requested topics and target brokers using the topicmappr rebuild
+	// placement engine. The plan is returned in the standard Kafka
+	// reassignment JSON format and in structured form; it's never applied.
+	// As in topicmappr, the plan is omitted if placement warnings are
+	// encountered unless ignore_warns is set. Warnings are always returned.
+	ReassignPartitions(ctx context.Context, in *ReassignRequest, opts ...grpc.CallOption) (*ReassignResponse, error)
+	// WatchEvents streams cluster change events as they're observed.
+	// Broker and topic changes are combined into a single ordered feed;
+	// each Event carries the sequence number and the affected object.
+	WatchEvents(ctx context.Context, in *Empty, opts ...grpc.CallOption) (Registry_WatchEventsClient, error)
 }
 
 type registryClient struct {
@@ -608,6 +1832,42 @@ func (c *registryClient) ListTopics(ctx context.Context, in *TopicRequest, opts
 	return out, nil
 }
 
+func (c *registryClient) GetTopicConfigs(ctx context.Context, in *TopicRequest, opts ...grpc.CallOption) (*TopicConfigResponse, error) {
+	out := new(TopicConfigResponse)
+	err := c.cc.Invoke(ctx, "/registry.Registry/GetTopicConfigs", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *registryClient) TopicExists(ctx context.Context, in *TopicRequest, opts ...grpc.CallOption) (*TopicExistsResponse, error) {
+	out := new(TopicExistsResponse)
+	err := c.cc.Invoke(ctx, "/registry.Registry/TopicExists", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *registryClient) CreateTopic(ctx context.Context, in *CreateTopicRequest, opts ...grpc.CallOption) (*Empty, error) {
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, "/registry.Registry/CreateTopic", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *registryClient) DeleteTopic(ctx context.Context, in *TopicRequest, opts ...grpc.CallOption) (*TopicResponse, error) {
+	out := new(TopicResponse)
+	err := c.cc.Invoke(ctx, "/registry.Registry/DeleteTopic", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *registryClient) TopicMappings(ctx context.Context, in *TopicRequest, opts ...grpc.CallOption) (*BrokerResponse, error) {
 	out := new(BrokerResponse)
 	err := c.cc.Invoke(ctx, "/registry.Registry/TopicMappings", in, out, opts...)
@@ -653,6 +1913,15 @@ func (c *registryClient) TagBroker(ctx context.Context, in *BrokerRequest, opts
 	return out, nil
 }
 
+func (c *registryClient) TagBrokers(ctx context.Context, in *BrokerTagsRequest, opts ...grpc.CallOption) (*BulkTagResponse, error) {
+	out := new(BulkTagResponse)
+	err := c.cc.Invoke(ctx, "/registry.Registry/TagBrokers", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *registryClient) DeleteBrokerTags(ctx context.Context, in *BrokerRequest, opts ...grpc.CallOption) (*TagResponse, error) {
 	out := new(TagResponse)
 	err := c.cc.Invoke(ctx, "/registry.Registry/DeleteBrokerTags", in, out, opts...)
@@ -662,6 +1931,119 @@ func (c *registryClient) DeleteBrokerTags(ctx context.Context, in *BrokerRequest
 	return out, nil
 }
 
+func (c *registryClient) RemoveBroker(ctx context.Context, in *BrokerRequest, opts ...grpc.CallOption) (*BrokerResponse, error) {
+	out := new(BrokerResponse)
+	err := c.cc.Invoke(ctx, "/registry.Registry/RemoveBroker", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *registryClient) WatchBrokers(ctx context.Context, in *BrokerRequest, opts ...grpc.CallOption) (Registry_WatchBrokersClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_Registry_serviceDesc.Streams[0], "/registry.Registry/WatchBrokers", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &registryWatchBrokersClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Registry_WatchBrokersClient interface {
+	Recv() (*BrokerResponse, error)
+	grpc.ClientStream
+}
+
+type registryWatchBrokersClient struct {
+	grpc.ClientStream
+}
+
+func (x *registryWatchBrokersClient) Recv() (*BrokerResponse, error) {
+	m := new(BrokerResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *registryClient) WatchBrokersControl(ctx context.Context, opts ...grpc.CallOption) (Registry_WatchBrokersControlClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_Registry_serviceDesc.Streams[1], "/registry.Registry/WatchBrokersControl", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &registryWatchBrokersControlClient{stream}
+	return x, nil
+}
+
+type Registry_WatchBrokersControlClient interface {
+	Send(*WatchBrokersRequest) error
+	Recv() (*BrokerResponse, error)
+	grpc.ClientStream
+}
+
+type registryWatchBrokersControlClient struct {
+	grpc.ClientStream
+}
+
+func (x *registryWatchBrokersControlClient) Send(m *WatchBrokersRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *registryWatchBrokersControlClient) Recv() (*BrokerResponse, error) {
+	m := new(BrokerResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *registryClient) ReassignPartitions(ctx context.Context, in *ReassignRequest, opts ...grpc.CallOption) (*ReassignResponse, error) {
+	out := new(ReassignResponse)
+	err := c.cc.Invoke(ctx, "/registry.Registry/ReassignPartitions", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *registryClient) WatchEvents(ctx context.Context, in *Empty, opts ...grpc.CallOption) (Registry_WatchEventsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_Registry_serviceDesc.Streams[2], "/registry.Registry/WatchEvents", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &registryWatchEventsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Registry_WatchEventsClient interface {
+	Recv() (*Event, error)
+	grpc.ClientStream
+}
+
+type registryWatchEventsClient struct {
+	grpc.ClientStream
+}
+
+func (x *registryWatchEventsClient) Recv() (*Event, error) {
+	m := new(Event)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 // RegistryServer is the server API for Registry service.
 type RegistryServer interface {
 	// GetBrokers returns a BrokerResponse with the brokers field populated
@@ -675,6 +2057,8 @@ type RegistryServer interface {
 	// a single broker ID is returned matching the ID specified in the
 	// Broker object if the broker exists. Otherwise all brokers are returned,
 	// optionally filtered by any provided BrokerRequest.tags parameters.
+	// If BrokerRequest.include_metadata is set, the brokers field is
+	// additionally populated with full broker metadata.
 	ListBrokers(context.Context, *BrokerRequest) (*BrokerResponse, error)
 	// GetTopics returns a TopicResponse with the topics field populated
 	// with full topic metadata. If the input TopicRequest.name field is
@@ -688,6 +2072,32 @@ type RegistryServer interface {
 	// Topic object if the topic exists. Otherwise all topics are returned,
 	// optionally filtered by any provided TopicRequest.tags parameters.
 	ListTopics(context.Context, *TopicRequest) (*TopicResponse, error)
+	// GetTopicConfigs returns a TopicConfigResponse with the configs field
+	// populated with the dynamic config overrides stored in ZooKeeper for
+	// each topic. Topics are matched as in ListTopics. Topics with no
+	// overrides are returned with an empty config.
+	GetTopicConfigs(context.Context, *TopicRequest) (*TopicConfigResponse, error)
+	// TopicExists returns a TopicExistsResponse with the exists field
+	// set to whether the topic specified in the TopicRequest.name field
+	// exists. If the topic exists, the topic field is populated with the
+	// topic partition count and replication factor. Registry tags are not
+	// fetched.
+	TopicExists(context.Context, *TopicRequest) (*TopicExistsResponse, error)
+	// CreateTopic creates the topic specified in the CreateTopicRequest.topic
+	// field. If the CreateTopicRequest.assignment field is populated, the
+	// provided replica assignment is validated and used verbatim. Otherwise,
+	// partitions are placed among all registered brokers according to the
+	// topic partitions and replication fields.
+	CreateTopic(context.Context, *CreateTopicRequest) (*Empty, error)
+	// DeleteTopic marks topics for deletion and returns a TopicResponse
+	// with the names field populated with the topics deleted. Topics are
+	// matched as in ListTopics, although at least one of the name or tag
+	// fields must be specified and the name is matched exactly rather than
+	// as a regex. If dry_run is set, the matched topics are returned without
+	// being deleted. If no topics match, a NotFound error is returned. Topics
+	// that fail to be deleted are listed in the errors field; if none were
+	// deleted, an error is returned instead.
+	DeleteTopic(context.Context, *TopicRequest) (*TopicResponse, error)
 	// TopicMappings returns a BrokerResponse with the ids field
 	// populated with broker IDs that hold at least one partition
 	// for the requested topic. The topic is specified in the
@@ -700,20 +2110,65 @@ type RegistryServer interface {
 	BrokerMappings(context.Context, *BrokerRequest) (*TopicResponse, error)
 	// TagTopic takes a TopicRequest and sets any specified
 	// tags for the named topic. Any existing tags that are
-	// not specified in the request are left unmodified.
+	// not specified in the request are left unmodified. Tags
+	// may be set for topics that don't exist in ZooKeeper;
+	// the TagResponse message indicates this case.
 	TagTopic(context.Context, *TopicRequest) (*TagResponse, error)
 	// DeleteTopicTags takes a TopicRequest and deletes any
 	// specified tags for the named topic. Tags must be provided
 	// as key names only; "key:value" will not target the tag "key".
+	// Tags provisioned for nonexistent topics may be deleted.
 	DeleteTopicTags(context.Context, *TopicRequest) (*TagResponse, error)
 	// TagBroker takes a BrokerRequest and sets any specified
 	// tags for the named broker. Any existing tags that are
-	// not specified in the request are left unmodified.
+	// not specified in the request are left unmodified. Tags
+	// may be set for broker IDs not registered in ZooKeeper;
+	// the TagResponse message indicates this case.
 	TagBroker(context.Context, *BrokerRequest) (*TagResponse, error)
+	// TagBrokers takes a BrokerTagsRequest and sets any specified
+	// tags for each listed broker. As in TagBroker, brokers that
+	// aren't registered may be tagged. A failure for any individual
+	// broker does not abort the request; the outcome for each
+	// broker is returned in the BulkTagResponse.
+	TagBrokers(context.Context, *BrokerTagsRequest) (*BulkTagResponse, error)
 	// DeleteBrokerTags takes a BrokerRequest and deletes any
 	// specified tags for the named broker. Tags must be provided
 	// as key names only; "key:value" will not target the tag "key".
+	// Tags provisioned for unregistered broker IDs may be deleted.
 	DeleteBrokerTags(context.Context, *BrokerRequest) (*TagResponse, error)
+	// RemoveBroker takes a BrokerRequest and deletes all registry
+	// metadata, such as tags, for the specified broker. Kafka's own
+	// broker registration in ZooKeeper is never modified. Brokers
+	// still holding partitions can't be removed; a FailedPrecondition
+	// error listing the affected topics is returned. The BrokerResponse
+	// ids field contains the removed ID, or is empty if the broker had
+	// no registry metadata.
+	RemoveBroker(context.Context, *BrokerRequest) (*BrokerResponse, error)
+	// WatchBrokers streams the registered brokers matching the request. The
+	// first BrokerResponse is a snapshot with the brokers and ids fields
+	// populated with the current membership, as in GetBrokers. Each time the
+	// set of matching brokers changes, a delta follows with the added and
+	// removed fields set and the brokers field holding the added brokers.
+	// Up to the last 16 membership changes observed by the server are
+	// replayed as deltas immediately following the snapshot.
+	WatchBrokers(*BrokerRequest, Registry_WatchBrokersServer) error
+	// WatchBrokersControl is a WatchBrokers stream that can be paused and
+	// resumed by the client. The first WatchBrokersRequest carries the
+	// BrokerRequest. Subsequent requests pause or resume the stream; changes
+	// made while paused are coalesced into a single delta sent on resume.
+	// It's only available over gRPC.
+	WatchBrokersControl(Registry_WatchBrokersControlServer) error
+	// ReassignPartitions generates a partition reassignment plan for the
+	// requested topics and target brokers using the topicmappr rebuild
+	// placement engine. The plan is returned in the standard Kafka
+	// reassignment JSON format and in structured form; it's never applied.
+	// As in topicmappr, the plan is omitted if placement warnings are
+	// encountered unless ignore_warns is set. Warnings are always returned.
+	ReassignPartitions(context.Context, *ReassignRequest) (*ReassignResponse, error)
+	// WatchEvents streams cluster change events as they're observed.
+	// Broker and topic changes are combined into a single ordered feed;
+	// each Event carries the sequence number and the affected object.
+	WatchEvents(*Empty, Registry_WatchEventsServer) error
 }
 
 func RegisterRegistryServer(s *grpc.Server, srv RegistryServer) {
@@ -792,6 +2247,78 @@ func _Registry_ListTopics_Handler(srv interface{}, ctx context.Context, dec func
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Registry_GetTopicConfigs_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TopicRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RegistryServer).GetTopicConfigs(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/registry.Registry/GetTopicConfigs",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RegistryServer).GetTopicConfigs(ctx, req.(*TopicRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Registry_TopicExists_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TopicRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RegistryServer).TopicExists(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/registry.Registry/TopicExists",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RegistryServer).TopicExists(ctx, req.(*TopicRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Registry_CreateTopic_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateTopicRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RegistryServer).CreateTopic(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/registry.Registry/CreateTopic",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RegistryServer).CreateTopic(ctx, req.(*CreateTopicRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Registry_DeleteTopic_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TopicRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RegistryServer).DeleteTopic(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/registry.Registry/DeleteTopic",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RegistryServer).DeleteTopic(ctx, req.(*TopicRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _Registry_TopicMappings_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(TopicRequest)
 	if err := dec(in); err != nil {
@@ -882,6 +2409,24 @@ func _Registry_TagBroker_Handler(srv interface{}, ctx context.Context, dec func(
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Registry_TagBrokers_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BrokerTagsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RegistryServer).TagBrokers(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/registry.Registry/TagBrokers",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RegistryServer).TagBrokers(ctx, req.(*BrokerTagsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _Registry_DeleteBrokerTags_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(BrokerRequest)
 	if err := dec(in); err != nil {
@@ -900,6 +2445,110 @@ func _Registry_DeleteBrokerTags_Handler(srv interface{}, ctx context.Context, de
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Registry_RemoveBroker_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BrokerRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RegistryServer).RemoveBroker(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/registry.Registry/RemoveBroker",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RegistryServer).RemoveBroker(ctx, req.(*BrokerRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Registry_WatchBrokers_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(BrokerRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(RegistryServer).WatchBrokers(m, &registryWatchBrokersServer{stream})
+}
+
+type Registry_WatchBrokersServer interface {
+	Send(*BrokerResponse) error
+	grpc.ServerStream
+}
+
+type registryWatchBrokersServer struct {
+	grpc.ServerStream
+}
+
+func (x *registryWatchBrokersServer) Send(m *BrokerResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Registry_WatchBrokersControl_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(RegistryServer).WatchBrokersControl(&registryWatchBrokersControlServer{stream})
+}
+
+type Registry_WatchBrokersControlServer interface {
+	Send(*BrokerResponse) error
+	Recv() (*WatchBrokersRequest, error)
+	grpc.ServerStream
+}
+
+type registryWatchBrokersControlServer struct {
+	grpc.ServerStream
+}
+
+func (x *registryWatchBrokersControlServer) Send(m *BrokerResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *registryWatchBrokersControlServer) Recv() (*WatchBrokersRequest, error) {
+	m := new(WatchBrokersRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _Registry_ReassignPartitions_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReassignRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RegistryServer).ReassignPartitions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/registry.Registry/ReassignPartitions",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RegistryServer).ReassignPartitions(ctx, req.(*ReassignRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Registry_WatchEvents_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(Empty)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(RegistryServer).WatchEvents(m, &registryWatchEventsServer{stream})
+}
+
+type Registry_WatchEventsServer interface {
+	Send(*Event) error
+	grpc.ServerStream
+}
+
+type registryWatchEventsServer struct {
+	grpc.ServerStream
+}
+
+func (x *registryWatchEventsServer) Send(m *Event) error {
+	return x.ServerStream.SendMsg(m)
+}
+
 var _Registry_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "registry.Registry",
 	HandlerType: (*RegistryServer)(nil),
@@ -920,6 +2569,22 @@ var _Registry_serviceDesc = grpc.ServiceDesc{
 			MethodName: "ListTopics",
 			Handler:    _Registry_ListTopics_Handler,
 		},
+		{
+			MethodName: "GetTopicConfigs",
+			Handler:    _Registry_GetTopicConfigs_Handler,
+		},
+		{
+			MethodName: "TopicExists",
+			Handler:    _Registry_TopicExists_Handler,
+		},
+		{
+			MethodName: "CreateTopic",
+			Handler:    _Registry_CreateTopic_Handler,
+		},
+		{
+			MethodName: "DeleteTopic",
+			Handler:    _Registry_DeleteTopic_Handler,
+		},
 		{
 			MethodName: "TopicMappings",
 			Handler:    _Registry_TopicMappings_Handler,
@@ -940,11 +2605,40 @@ var _Registry_serviceDesc = grpc.ServiceDesc{
 			MethodName: "TagBroker",
 			Handler:    _Registry_TagBroker_Handler,
 		},
+		{
+			MethodName: "TagBrokers",
+			Handler:    _Registry_TagBrokers_Handler,
+		},
 		{
 			MethodName: "DeleteBrokerTags",
 			Handler:    _Registry_DeleteBrokerTags_Handler,
 		},
+		{
+			MethodName: "RemoveBroker",
+			Handler:    _Registry_RemoveBroker_Handler,
+		},
+		{
+			MethodName: "ReassignPartitions",
+			Handler:    _Registry_ReassignPartitions_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchBrokers",
+			Handler:       _Registry_WatchBrokers_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "WatchBrokersControl",
+			Handler:       _Registry_WatchBrokersControl_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "WatchEvents",
+			Handler:       _Registry_WatchEvents_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "protos/registry.proto",
 }
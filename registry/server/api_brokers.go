@@ -2,14 +2,20 @@ package server
 
 import (
 	"context"
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"regexp"
 	"sort"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/DataDog/kafka-kit/kafkazk"
 	pb "github.com/DataDog/kafka-kit/registry/protos"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 var (
@@ -19,6 +25,20 @@ var (
 	ErrBrokerNotExist = errors.New("broker does not exist")
 	// ErrBrokerIDEmpty error.
 	ErrBrokerIDEmpty = errors.New("broker Id field must be specified")
+	// ErrBrokerIDsEmpty error.
+	ErrBrokerIDsEmpty = errors.New("broker Ids field must be specified")
+	// ErrInvalidPageToken error.
+	ErrInvalidPageToken = status.Error(codes.InvalidArgument, "invalid page token")
+
+	// tagUnregisteredMsg is the TagResponse message for tag requests
+	// targeting a broker ID that isn't registered in ZooKeeper.
+	tagUnregisteredMsg = "success (broker not registered)"
+
+	// tagAttempts is the number of attempts made to set
+	// tags for each broker in a TagBrokers request.
+	tagAttempts = 3
+	// tagRetryBackoff is the wait between attempts.
+	tagRetryBackoff = 100 * time.Millisecond
 )
 
 // BrokerSet is a mapping of broker IDs to *pb.Broker.
@@ -28,19 +48,41 @@ type BrokerSet map[uint32]*pb.Broker
 // non-zero, the specified broker is matched if it exists. Otherwise, all
 // brokers found in ZooKeeper are matched. Matched brokers are then filtered
 // by all tags specified, if specified, in the *pb.BrokerRequest tag field.
+// If the *pb.BrokerRequest State field is set, only brokers in that state are
+// matched; these may include brokers that aren't registered in ZooKeeper.
+// Brokers are populated with storage metrics where available; brokers
+// without metrics have the MetricsIncomplete field set. The response Ids field
+// lists the matched brokers in the *pb.BrokerRequest SortBy order. Results are
+// paginated in ascending broker ID order if the *pb.BrokerRequest PageSize
+// field is set. If the *pb.BrokerRequest Fields field is set, only the named
+// Broker fields are populated and storage metrics are only fetched if
+// requested; unknown field names are reported in the response Warnings.
 func (s *Server) GetBrokers(ctx context.Context, req *pb.BrokerRequest) (*pb.BrokerResponse, error) {
 	if err := s.ValidateRequest(ctx, req, readRequest); err != nil {
 		return nil, err
 	}
 
-	// Get brokers.
-	brokers, err := s.fetchBrokerSet(req)
+	// Get brokers, including metrics unless
+	// excluded by the fields projection.
+	withMetrics := includesMetrics(req.Fields) || req.SortBy == pb.BrokerRequest_STORAGE_FREE
+	brokers, err := s.fetchBrokerSet(req, withMetrics)
+	if err != nil {
+		return nil, err
+	}
+
+	page, next, err := brokers.Page(req.PageSize, req.PageToken)
+	if err != nil {
+		return nil, err
+	}
+
+	ids, err := s.sortedBrokerIDs(page, req.SortBy)
 	if err != nil {
 		return nil, err
 	}
 
-	// Populate response Brokers field.
-	resp := &pb.BrokerResponse{Brokers: brokers}
+	// Populate response Ids and Brokers fields.
+	resp := &pb.BrokerResponse{Ids: ids, Brokers: page, NextPageToken: next}
+	resp.Warnings = page.Project(req.Fields)
 
 	return resp, nil
 }
@@ -49,19 +91,42 @@ func (s *Server) GetBrokers(ctx context.Context, req *pb.BrokerRequest) (*pb.Bro
 // non-zero, the specified broker is matched if it exists. Otherwise, all
 // brokers found in ZooKeeper are matched. Matched brokers are then filtered
 // by all tags specified, if specified, in the *pb.BrokerRequest tag field.
+// If the *pb.BrokerRequest IncludeMetadata field is true, the response Brokers
+// field is populated with full broker metadata, including storage metrics.
+// The response Ids field is ordered as specified by the *pb.BrokerRequest
+// SortBy field. Results are paginated if the *pb.BrokerRequest PageSize field
+// is set. The Brokers field is limited to any *pb.BrokerRequest Fields as
+// described in GetBrokers.
 func (s *Server) ListBrokers(ctx context.Context, req *pb.BrokerRequest) (*pb.BrokerResponse, error) {
 	if err := s.ValidateRequest(ctx, req, readRequest); err != nil {
 		return nil, err
 	}
 
-	// Get brokers.
-	brokers, err := s.fetchBrokerSet(req)
+	// Get brokers. Sorting by storage free requires metrics.
+	withMetrics := (req.IncludeMetadata && includesMetrics(req.Fields)) || req.SortBy == pb.BrokerRequest_STORAGE_FREE
+	brokers, err := s.fetchBrokerSet(req, withMetrics)
+	if err != nil {
+		return nil, err
+	}
+
+	brokers, next, err := brokers.Page(req.PageSize, req.PageToken)
+	if err != nil {
+		return nil, err
+	}
+
+	ids, err := s.sortedBrokerIDs(brokers, req.SortBy)
 	if err != nil {
 		return nil, err
 	}
 
 	// Populate response Ids field.
-	resp := &pb.BrokerResponse{Ids: brokers.IDs()}
+	resp := &pb.BrokerResponse{Ids: ids, NextPageToken: next}
+
+	// Populate response Brokers field if requested.
+	if req.IncludeMetadata {
+		resp.Brokers = brokers
+		resp.Warnings = brokers.Project(req.Fields)
+	}
 
 	return resp, nil
 }
@@ -79,8 +144,8 @@ func (s *Server) BrokerMappings(ctx context.Context, req *pb.BrokerRequest) (*pb
 	}
 
 	// Get a kafkazk.BrokerMetaMap.
-	bm, err := s.ZK.GetAllBrokerMeta(false)
-	if err != nil {
+	bm, errs := s.ZK.GetAllBrokerMeta(false)
+	if errs != nil {
 		return nil, ErrFetchingBrokers
 	}
 
@@ -89,6 +154,17 @@ func (s *Server) BrokerMappings(ctx context.Context, req *pb.BrokerRequest) (*pb
 		return nil, ErrBrokerNotExist
 	}
 
+	names, err := s.brokerTopics(int(req.Id))
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.TopicResponse{Names: names}, nil
+}
+
+// brokerTopics returns the sorted names of all topics that have at
+// least one partition held by the broker ID.
+func (s *Server) brokerTopics(id int) ([]string, error) {
 	// Get all topic names.
 	ts, errs := s.ZK.GetTopics([]*regexp.Regexp{regexp.MustCompile(".*")})
 	if errs != nil {
@@ -116,46 +192,61 @@ func (s *Server) BrokerMappings(ctx context.Context, req *pb.BrokerRequest) (*pb
 		// Add the topic name to each broker's topic set.
 		name := pm.Partitions[0].Topic
 
-		for id := range bm {
-			if bmapping[id] == nil {
-				bmapping[id] = map[string]struct{}{}
+		for bid := range bm {
+			if bmapping[bid] == nil {
+				bmapping[bid] = map[string]struct{}{}
 			}
-			bmapping[id][name] = struct{}{}
+			bmapping[bid][name] = struct{}{}
 		}
 	}
 
 	// Get a []string of topic names where at least one
 	// partition is held by the requested broker.
 	names := []string{}
-	for n := range bmapping[int(req.Id)] {
+	for n := range bmapping[id] {
 		names = append(names, n)
 	}
 
 	sort.Strings(names)
 
-	return &pb.TopicResponse{Names: names}, nil
+	return names, nil
 }
 
-// fetchBrokerSet fetches metadata for all brokers.
-func (s *Server) fetchBrokerSet(req *pb.BrokerRequest) (BrokerSet, error) {
+// fetchBrokerSet fetches metadata for all brokers, including
+// metrics if withMetrics is true.
+func (s *Server) fetchBrokerSet(req *pb.BrokerRequest, withMetrics bool) (BrokerSet, error) {
 	// Get brokers from ZK.
-	brokers, errs := s.ZK.GetAllBrokerMeta(false)
-	if errs != nil {
-		return nil, ErrFetchingBrokers
+	brokers, err := s.fetchBrokerMeta(withMetrics)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get the candidate broker IDs; either all
+	// brokers or those in the requested state.
+	var ids []int
+	if req.State != pb.BrokerRequest_ANY {
+		if ids, err = s.brokersInState(req, brokers); err != nil {
+			return nil, err
+		}
+	} else {
+		for id := range brokers {
+			ids = append(ids, id)
+		}
 	}
 
 	matched := BrokerSet{}
 
-	// Check if a specific broker is being fetched.
-	if req.Id != 0 {
-		// Lookup the broker.
-		if b, ok := brokers[int(req.Id)]; ok {
-			matched[req.Id] = pbBrokerFromMeta(req.Id, b)
+	for _, id := range ids {
+		// Check if a specific broker is being fetched.
+		if req.Id != 0 && uint32(id) != req.Id {
+			continue
 		}
-	} else {
-		// Otherwise, populate all brokers.
-		for b, m := range brokers {
-			matched[uint32(b)] = pbBrokerFromMeta(uint32(b), m)
+
+		// Unregistered brokers have no metadata.
+		if m, ok := brokers[id]; ok {
+			matched[uint32(id)] = pbBrokerFromMeta(uint32(id), m)
+		} else {
+			matched[uint32(id)] = &pb.Broker{Id: uint32(id)}
 		}
 	}
 
@@ -168,8 +259,152 @@ func (s *Server) fetchBrokerSet(req *pb.BrokerRequest) (BrokerSet, error) {
 	return filtered, nil
 }
 
+// brokersInState returns the IDs of brokers in the requested state. A
+// kafkazk.BrokerMap is built from all topic assignments and updated with
+// the target broker list, as in a topicmappr rebuild; the broker Missing
+// and Replace flags are then evaluated against the requested state. The
+// MISSING state uses all registered brokers as the target broker list.
+func (s *Server) brokersInState(req *pb.BrokerRequest, brokers kafkazk.BrokerMetaMap) ([]int, error) {
+	pm, err := s.allPartitionMap()
+	if err != nil {
+		return nil, err
+	}
+
+	var targets []int
+	if req.State == pb.BrokerRequest_REPLACE {
+		for _, id := range req.TargetBrokers {
+			targets = append(targets, int(id))
+		}
+	} else {
+		for id := range brokers {
+			targets = append(targets, id)
+		}
+	}
+
+	bm := kafkazk.BrokerMapFromPartitionMap(pm, brokers, false)
+	bm.Update(targets, brokers)
+
+	var ids []int
+	for id, b := range bm {
+		// Skip the reserved stub ID.
+		if id == 0 {
+			continue
+		}
+
+		switch {
+		case req.State == pb.BrokerRequest_MISSING && b.Missing,
+			req.State == pb.BrokerRequest_REPLACE && b.Replace:
+			ids = append(ids, id)
+		}
+	}
+
+	return ids, nil
+}
+
+// allPartitionMap returns a merged kafkazk.PartitionMap of all topics.
+func (s *Server) allPartitionMap() (*kafkazk.PartitionMap, error) {
+	// Get all topic names.
+	ts, errs := s.ZK.GetTopics([]*regexp.Regexp{tregex})
+	if errs != nil {
+		return nil, ErrFetchingTopics
+	}
+
+	// Merge the kafkazk.PartitionMap for each topic.
+	pm := kafkazk.NewPartitionMap()
+	for _, t := range ts {
+		tpm, err := s.ZK.GetPartitionMap(t)
+		if err != nil {
+			return nil, err
+		}
+		pm.Partitions = append(pm.Partitions, tpm.Partitions...)
+	}
+
+	return pm, nil
+}
+
+// sortedBrokerIDs returns the BrokerSet IDs in the requested order using the
+// kafkazk.BrokerList sort methods. Ordering by USED requires the replica
+// counts of all topic assignments.
+func (s *Server) sortedBrokerIDs(brokers BrokerSet, by pb.BrokerRequest_SortBy) ([]uint32, error) {
+	if by == pb.BrokerRequest_ID {
+		return brokers.IDs(), nil
+	}
+
+	var used kafkazk.BrokerMap
+	if by == pb.BrokerRequest_USED {
+		pm, err := s.allPartitionMap()
+		if err != nil {
+			return nil, err
+		}
+		used = kafkazk.BrokerMapFromPartitionMap(pm, nil, false)
+	}
+
+	bl := kafkazk.BrokerList{}
+	for id, b := range brokers {
+		br := &kafkazk.Broker{ID: int(id), StorageFree: b.StorageFree}
+		if u, exists := used[int(id)]; exists {
+			br.Used = u.Used
+		}
+		bl = append(bl, br)
+	}
+
+	switch by {
+	case pb.BrokerRequest_STORAGE_FREE:
+		bl.SortByStorage()
+	case pb.BrokerRequest_USED:
+		bl.SortByCount()
+	default:
+		bl.SortByID()
+	}
+
+	ids := make([]uint32, len(bl))
+	for i, b := range bl {
+		ids[i] = uint32(b.ID)
+	}
+
+	return ids, nil
+}
+
+// fetchBrokerMeta fetches a kafkazk.BrokerMetaMap, including metrics if
+// withMetrics is true. Brokers missing metrics are returned with the
+// MetricsIncomplete field set. If metrics are unavailable altogether,
+// all brokers are returned with the MetricsIncomplete field set.
+func (s *Server) fetchBrokerMeta(withMetrics bool) (kafkazk.BrokerMetaMap, error) {
+	brokers, errs := s.ZK.GetAllBrokerMeta(false)
+	if errs != nil {
+		return nil, ErrFetchingBrokers
+	}
+
+	if !withMetrics {
+		return brokers, nil
+	}
+
+	// Metrics are fetched separately so that the
+	// broker metadata is still usable without them.
+	metrics, err := s.ZK.GetBrokerMetrics()
+	if err != nil {
+		s.logger.Warnf("Error fetching broker metrics: %s", err)
+	}
+
+	for id, b := range brokers {
+		m, exists := metrics[id]
+		if !exists {
+			b.MetricsIncomplete = true
+			continue
+		}
+
+		b.StorageFree = m.StorageFree
+		b.StorageTotal = m.StorageTotal
+		b.LogDirs = m.LogDirs
+	}
+
+	return brokers, nil
+}
+
 // TagBroker sets custom tags for the specified broker. Any previously existing
-// tags that were not specified in the request remain unmodified.
+// tags that were not specified in the request remain unmodified. Tags may be
+// set for broker IDs that aren't registered in ZooKeeper, allowing tags to be
+// provisioned ahead of a broker; the response message indicates this case.
 func (s *Server) TagBroker(ctx context.Context, req *pb.BrokerRequest) (*pb.TagResponse, error) {
 	if err := s.ValidateRequest(ctx, req, writeRequest); err != nil {
 		return nil, err
@@ -189,16 +424,10 @@ func (s *Server) TagBroker(ctx context.Context, req *pb.BrokerRequest) (*pb.TagR
 		return nil, err
 	}
 
-	// Ensure the broker exists.
-
-	// Get brokers from ZK.
-	brokers, errs := s.ZK.GetAllBrokerMeta(false)
-	if errs != nil {
-		return nil, ErrFetchingBrokers
-	}
-
-	if _, exist := brokers[int(req.Id)]; !exist {
-		return nil, ErrBrokerNotExist
+	// Check if the broker is registered.
+	registered, err := s.brokerRegistered(req.Id)
+	if err != nil {
+		return nil, err
 	}
 
 	// Set the tags.
@@ -208,24 +437,36 @@ func (s *Server) TagBroker(ctx context.Context, req *pb.BrokerRequest) (*pb.TagR
 		return nil, err
 	}
 
+	if !registered {
+		return &pb.TagResponse{Message: tagUnregisteredMsg}, nil
+	}
+
 	return &pb.TagResponse{Message: "success"}, nil
 }
 
-//DeleteBrokerTags deletes custom tags for the specified broker.
-func (s *Server) DeleteBrokerTags(ctx context.Context, req *pb.BrokerRequest) (*pb.TagResponse, error) {
+// TagBrokers sets custom tags for each of the specified brokers. Setting tags
+// for each broker is retried on storage errors; a broker that can't be tagged
+// doesn't abort the request. As in TagBroker, brokers that aren't registered
+// may be tagged. The outcome for each broker is returned as either "success",
+// the TagBroker message for unregistered brokers or the error encountered.
+func (s *Server) TagBrokers(ctx context.Context, req *pb.BrokerTagsRequest) (*pb.BulkTagResponse, error) {
 	if err := s.ValidateRequest(ctx, req, writeRequest); err != nil {
 		return nil, err
 	}
 
-	if req.Id == 0 {
-		return nil, ErrBrokerIDEmpty
+	if len(req.Ids) == 0 {
+		return nil, ErrBrokerIDsEmpty
 	}
 
 	if len(req.Tag) == 0 {
 		return nil, ErrNilTags
 	}
 
-	// Ensure the broker exists.
+	// Get a TagSet from the supplied tags.
+	ts, err := Tags(req.Tag).TagSet()
+	if err != nil {
+		return nil, err
+	}
 
 	// Get brokers from ZK.
 	brokers, errs := s.ZK.GetAllBrokerMeta(false)
@@ -233,20 +474,159 @@ func (s *Server) DeleteBrokerTags(ctx context.Context, req *pb.BrokerRequest) (*
 		return nil, ErrFetchingBrokers
 	}
 
-	if _, exist := brokers[int(req.Id)]; !exist {
-		return nil, ErrBrokerNotExist
+	resp := &pb.BulkTagResponse{Results: map[uint32]string{}}
+
+	for _, id := range req.Ids {
+		if id == 0 {
+			resp.Results[id] = ErrBrokerIDEmpty.Error()
+			continue
+		}
+
+		o := KafkaObject{Type: "broker", ID: fmt.Sprintf("%d", id)}
+		if err := s.setTagsWithRetry(ctx, o, ts); err != nil {
+			resp.Results[id] = err.Error()
+			continue
+		}
+
+		// As in TagBroker, brokers that aren't
+		// registered may be tagged.
+		if _, registered := brokers[int(id)]; !registered {
+			resp.Results[id] = tagUnregisteredMsg
+			continue
+		}
+
+		resp.Results[id] = "success"
+	}
+
+	return resp, nil
+}
+
+// setTagsWithRetry sets the TagSet for the KafkaObject, making up to
+// tagAttempts attempts. Errors that can't succeed on a retry, such as
+// the use of reserved tags, are returned immediately. If the context is
+// cancelled while waiting to retry, the context error is returned.
+func (s *Server) setTagsWithRetry(ctx context.Context, o KafkaObject, ts TagSet) error {
+	var err error
+
+	for i := 0; i < tagAttempts; i++ {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(tagRetryBackoff):
+			}
+		}
+
+		err = s.Tags.Store.SetTags(o, ts)
+		switch err.(type) {
+		case nil, ErrReservedTag:
+			return err
+		}
+
+		if err == ErrInvalidKafkaObjectType || err == ErrNilTagSet {
+			return err
+		}
+	}
+
+	return err
+}
+
+//DeleteBrokerTags deletes custom tags for the specified broker.
+func (s *Server) DeleteBrokerTags(ctx context.Context, req *pb.BrokerRequest) (*pb.TagResponse, error) {
+	if err := s.ValidateRequest(ctx, req, writeRequest); err != nil {
+		return nil, err
+	}
+
+	if req.Id == 0 {
+		return nil, ErrBrokerIDEmpty
+	}
+
+	if len(req.Tag) == 0 {
+		return nil, ErrNilTags
+	}
+
+	// Check if the broker is registered.
+	registered, err := s.brokerRegistered(req.Id)
+	if err != nil {
+		return nil, err
 	}
 
 	// Delete the tags.
 	id := fmt.Sprintf("%d", req.Id)
-	err := s.Tags.Store.DeleteTags(KafkaObject{Type: "broker", ID: id}, req.Tag)
-	if err != nil {
+	err = s.Tags.Store.DeleteTags(KafkaObject{Type: "broker", ID: id}, req.Tag)
+	switch {
+	// Unregistered brokers are only valid
+	// targets if tags were provisioned.
+	case err == ErrKafkaObjectDoesNotExist && !registered:
+		return nil, ErrBrokerNotExist
+	case err != nil:
 		return nil, err
 	}
 
+	if !registered {
+		return &pb.TagResponse{Message: tagUnregisteredMsg}, nil
+	}
+
 	return &pb.TagResponse{Message: "success"}, nil
 }
 
+// RemoveBroker deletes all registry-managed metadata for the specified broker,
+// such as tags and the membership changes replayed to WatchBrokers clients. This is intended for brokers that have been permanently
+// removed from the cluster; the broker's registration in ZooKeeper, which is
+// managed by Kafka, is never modified. Brokers that still hold partitions
+// can't be removed. The response ids field contains the removed broker ID,
+// or is empty if the broker had no registry metadata.
+func (s *Server) RemoveBroker(ctx context.Context, req *pb.BrokerRequest) (*pb.BrokerResponse, error) {
+	if err := s.ValidateRequest(ctx, req, writeRequest); err != nil {
+		return nil, err
+	}
+
+	if req.Id == 0 {
+		return nil, ErrBrokerIDEmpty
+	}
+
+	// Ensure the broker isn't holding any partitions.
+	topics, err := s.brokerTopics(int(req.Id))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(topics) > 0 {
+		return nil, status.Errorf(codes.FailedPrecondition,
+			"broker %d still holds partitions for topics: %s", req.Id, strings.Join(topics, ", "))
+	}
+
+	// Delete the tags.
+	id := fmt.Sprintf("%d", req.Id)
+	err = s.Tags.Store.DeleteObject(KafkaObject{Type: "broker", ID: id})
+	if err != nil && err != ErrKafkaObjectDoesNotExist {
+		return nil, err
+	}
+
+	// Stop replaying the broker's
+	// changes to WatchBrokers clients.
+	s.brokerHistory.forget(req.Id)
+
+	if err == ErrKafkaObjectDoesNotExist {
+		return &pb.BrokerResponse{Ids: []uint32{}}, nil
+	}
+
+	return &pb.BrokerResponse{Ids: []uint32{req.Id}}, nil
+}
+
+// brokerRegistered returns whether the broker ID
+// is registered in ZooKeeper.
+func (s *Server) brokerRegistered(id uint32) (bool, error) {
+	brokers, errs := s.ZK.GetAllBrokerMeta(false)
+	if errs != nil {
+		return false, ErrFetchingBrokers
+	}
+
+	_, exist := brokers[int(id)]
+
+	return exist, nil
+}
+
 // IDs returns a []uint32 of IDs from a BrokerSet.
 func (b BrokerSet) IDs() []uint32 {
 	var ids = []uint32{}
@@ -259,6 +639,127 @@ func (b BrokerSet) IDs() []uint32 {
 	return ids
 }
 
+// Page takes a page size and page token and returns the page of the
+// BrokerSet, in ascending ID order, following the position encoded in the
+// token along with the token for the next page. The token encodes the last
+// ID returned, so pages remain stable as brokers are added or removed. An
+// empty token returns the first page and an empty next page token indicates
+// the last page. A size of 0 returns all remaining brokers.
+func (b BrokerSet) Page(size uint32, token string) (BrokerSet, string, error) {
+	if size == 0 && token == "" {
+		return b, "", nil
+	}
+
+	var after uint32
+	if token != "" {
+		var err error
+		if after, err = decodePageToken(token); err != nil {
+			return nil, "", err
+		}
+	}
+
+	ids := b.IDs()
+	start := sort.Search(len(ids), func(i int) bool { return ids[i] > after })
+	ids = ids[start:]
+
+	var next string
+	if size > 0 && uint32(len(ids)) > size {
+		ids = ids[:size]
+		next = encodePageToken(ids[len(ids)-1])
+	}
+
+	page := BrokerSet{}
+	for _, id := range ids {
+		page[id] = b[id]
+	}
+
+	return page, next, nil
+}
+
+// brokerFields maps Broker field names
+// to funcs that clear the field.
+var brokerFields = map[string]func(*pb.Broker){
+	"tags":                        func(b *pb.Broker) { b.Tags = nil },
+	"id":                          func(b *pb.Broker) { b.Id = 0 },
+	"listenersecurityprotocolmap": func(b *pb.Broker) { b.Listenersecurityprotocolmap = nil },
+	"endpoints":                   func(b *pb.Broker) { b.Endpoints = nil },
+	"rack":                        func(b *pb.Broker) { b.Rack = "" },
+	"jmxport":                     func(b *pb.Broker) { b.Jmxport = 0 },
+	"host":                        func(b *pb.Broker) { b.Host = "" },
+	"timestamp":                   func(b *pb.Broker) { b.Timestamp = 0 },
+	"port":                        func(b *pb.Broker) { b.Port = 0 },
+	"version":                     func(b *pb.Broker) { b.Version = 0 },
+	"storage_free":                func(b *pb.Broker) { b.StorageFree = 0 },
+	"metrics_incomplete":          func(b *pb.Broker) { b.MetricsIncomplete = false },
+}
+
+// Project clears all fields not named in fields from each Broker in the
+// BrokerSet. Brokers are unmodified if no fields are named. A warning is
+// returned for each unknown field name.
+func (b BrokerSet) Project(fields []string) []string {
+	if len(fields) == 0 {
+		return nil
+	}
+
+	keep := map[string]bool{}
+	var warnings []string
+
+	for _, f := range fields {
+		if _, exists := brokerFields[f]; !exists {
+			warnings = append(warnings, fmt.Sprintf("unknown field '%s' ignored", f))
+			continue
+		}
+		keep[f] = true
+	}
+
+	for _, broker := range b {
+		for f, clear := range brokerFields {
+			if !keep[f] {
+				clear(broker)
+			}
+		}
+	}
+
+	return warnings
+}
+
+// includesMetrics returns whether a fields projection
+// includes the Broker storage metrics fields.
+func includesMetrics(fields []string) bool {
+	if len(fields) == 0 {
+		return true
+	}
+
+	for _, f := range fields {
+		if f == "storage_free" || f == "metrics_incomplete" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// encodePageToken returns an opaque page
+// token for the last broker ID in a page.
+func encodePageToken(id uint32) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatUint(uint64(id), 10)))
+}
+
+// decodePageToken returns the broker ID encoded in a page token.
+func decodePageToken(token string) (uint32, error) {
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return 0, ErrInvalidPageToken
+	}
+
+	id, err := strconv.ParseUint(string(b), 10, 32)
+	if err != nil {
+		return 0, ErrInvalidPageToken
+	}
+
+	return uint32(id), nil
+}
+
 type idList []uint32
 
 func (s idList) Len() int           { return len(s) }
@@ -271,11 +772,14 @@ func pbBrokerFromMeta(id uint32, b *kafkazk.BrokerMeta) *pb.Broker {
 	return &pb.Broker{
 		Id:                          id,
 		Listenersecurityprotocolmap: b.ListenerSecurityProtocolMap,
+		Endpoints:                   b.Endpoints,
 		Rack:                        b.Rack,
 		Jmxport:                     uint32(b.JMXPort),
 		Host:                        b.Host,
 		Timestamp:                   ts,
 		Port:                        uint32(b.Port),
 		Version:                     uint32(b.Version),
+		StorageFree:                 b.StorageFree,
+		MetricsIncomplete:           b.MetricsIncomplete,
 	}
 }
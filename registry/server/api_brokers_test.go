@@ -2,9 +2,16 @@ package server
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"testing"
+	"time"
 
+	"github.com/DataDog/kafka-kit/kafkazk"
 	pb "github.com/DataDog/kafka-kit/registry/protos"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 func TestGetBrokers(t *testing.T) {
@@ -40,6 +47,233 @@ func TestGetBrokers(t *testing.T) {
 	}
 }
 
+func TestGetBrokersTagPredicates(t *testing.T) {
+	s := testServer()
+
+	stored := map[string]TagSet{
+		"1001": TagSet{"zone": "us-east-1a", "instance": "i3.xlarge"},
+		"1002": TagSet{"zone": "us-east-1a", "instance": "i3.2xlarge"},
+		"1003": TagSet{"zone": "us-east-1b", "instance": "i3.xlarge"},
+		"1004": TagSet{"instance": "i3.xlarge"},
+	}
+
+	for id, ts := range stored {
+		s.Tags.Store.SetTags(KafkaObject{Type: "broker", ID: id}, ts)
+	}
+
+	tests := map[int]*pb.BrokerRequest{
+		0: &pb.BrokerRequest{Tag: []string{"instance:i3.xlarge"}},
+		// Multiple predicates.
+		1: &pb.BrokerRequest{Tag: []string{"instance:i3.xlarge", "zone:us-east-1a"}},
+		2: &pb.BrokerRequest{Tag: []string{"instance:i3.xlarge", "rack:a"}},
+		// Key only.
+		3: &pb.BrokerRequest{Tag: []string{"zone"}},
+		4: &pb.BrokerRequest{Tag: []string{"zone", "instance:i3.xlarge"}},
+		// Missing keys.
+		5: &pb.BrokerRequest{Tag: []string{"missing"}},
+		6: &pb.BrokerRequest{Tag: []string{"instance:i3.xlarge", "missing:value"}},
+		// Value mismatches.
+		7: &pb.BrokerRequest{Tag: []string{"zone:us-east-1c"}},
+		8: &pb.BrokerRequest{Tag: []string{"zone:us-east-1b", "instance:i3.2xlarge"}},
+	}
+
+	expected := map[int]idList{
+		0: idList{1001, 1003, 1004},
+		1: idList{1001},
+		2: idList{1001, 1004},
+		3: idList{1001, 1002, 1003},
+		4: idList{1001, 1003},
+		5: idList{},
+		6: idList{},
+		7: idList{},
+		8: idList{},
+	}
+
+	for i, req := range tests {
+		resp, err := s.GetBrokers(context.Background(), req)
+		if err != nil {
+			t.Errorf("[test %d] Unexpected error: %s", i, err)
+			continue
+		}
+
+		brokers := BrokerSet(resp.Brokers).IDs()
+
+		if !intsEqual(expected[i], brokers) {
+			t.Errorf("[test %d] Expected broker list %v, got %v", i, expected[i], brokers)
+		}
+	}
+
+	// Malformed predicates.
+	if _, err := s.GetBrokers(context.Background(), &pb.BrokerRequest{Tag: []string{"a:b:c"}}); err == nil {
+		t.Error("Expected non-nil error")
+	}
+}
+
+// testMetricsZK is a kafkazk.Mock that omits
+// metrics for brokers in the missing set, or
+// for all brokers if unavailable is true. Broker
+// metadata and metrics fetches are counted.
+type testMetricsZK struct {
+	kafkazk.Mock
+	missing       map[int]bool
+	unavailable   bool
+	metaFetches   int
+	metricFetches int
+}
+
+func (zk *testMetricsZK) GetAllBrokerMeta(withMetrics bool) (kafkazk.BrokerMetaMap, []error) {
+	zk.metaFetches++
+	return zk.Mock.GetAllBrokerMeta(withMetrics)
+}
+
+func (zk *testMetricsZK) GetBrokerMetrics() (kafkazk.BrokerMetricsMap, error) {
+	zk.metricFetches++
+	if zk.unavailable {
+		return nil, errors.New("no metrics")
+	}
+
+	m, _ := zk.Mock.GetBrokerMetrics()
+	for id := range zk.missing {
+		delete(m, id)
+	}
+
+	return m, nil
+}
+
+func TestGetBrokersMetrics(t *testing.T) {
+	s := testServer()
+	s.ZK = &testMetricsZK{missing: map[int]bool{1002: true}}
+
+	resp, err := s.GetBrokers(context.Background(), &pb.BrokerRequest{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	expected := map[uint32]*pb.Broker{
+		1001: &pb.Broker{StorageFree: 2000.00},
+		1002: &pb.Broker{MetricsIncomplete: true},
+		1003: &pb.Broker{StorageFree: 6000.00},
+		1004: &pb.Broker{StorageFree: 8000.00},
+		1005: &pb.Broker{StorageFree: 10000.00},
+	}
+
+	for id, b := range expected {
+		got, exists := resp.Brokers[id]
+		if !exists {
+			t.Errorf("Expected broker %d in BrokerResponse.Brokers", id)
+			continue
+		}
+
+		if got.StorageFree != b.StorageFree {
+			t.Errorf("[broker %d] Expected storage free %.2f, got %.2f", id, b.StorageFree, got.StorageFree)
+		}
+
+		if got.MetricsIncomplete != b.MetricsIncomplete {
+			t.Errorf("[broker %d] Expected metrics incomplete %v, got %v", id, b.MetricsIncomplete, got.MetricsIncomplete)
+		}
+	}
+
+	// Metrics unavailable altogether.
+	zk := &testMetricsZK{unavailable: true}
+	s.ZK = zk
+
+	resp, err = s.GetBrokers(context.Background(), &pb.BrokerRequest{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if len(resp.Brokers) != 5 {
+		t.Errorf("Expected 5 brokers, got %d", len(resp.Brokers))
+	}
+
+	for id, b := range resp.Brokers {
+		if !b.MetricsIncomplete {
+			t.Errorf("[broker %d] Expected metrics incomplete", id)
+		}
+	}
+
+	// Broker metadata is fetched once, not again on fallback.
+	if zk.metaFetches != 1 || zk.metricFetches != 1 {
+		t.Errorf("Expected 1 metadata and 1 metrics fetch, got %d and %d", zk.metaFetches, zk.metricFetches)
+	}
+
+	// Metrics aren't fetched if not requested.
+	zk = &testMetricsZK{}
+	s.ZK = zk
+
+	if _, err = s.GetBrokers(context.Background(), &pb.BrokerRequest{Fields: []string{"rack"}}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if zk.metricFetches != 0 {
+		t.Errorf("Expected 0 metrics fetches, got %d", zk.metricFetches)
+	}
+}
+
+// testStateZK is a kafkazk.Mock where brokers in
+// the unregistered set are absent from ZooKeeper.
+type testStateZK struct {
+	kafkazk.Mock
+	unregistered map[int]bool
+}
+
+func (zk *testStateZK) GetAllBrokerMeta(withMetrics bool) (kafkazk.BrokerMetaMap, []error) {
+	b, _ := zk.Mock.GetAllBrokerMeta(withMetrics)
+	for id := range zk.unregistered {
+		delete(b, id)
+	}
+
+	return b, nil
+}
+
+func TestGetBrokersState(t *testing.T) {
+	s := testServer()
+	// Mock topics are assigned to brokers 1001-1004;
+	// 1005 is registered but holds no replicas.
+	zk := &testStateZK{unregistered: map[int]bool{1003: true, 1004: true}}
+	s.ZK = zk
+
+	tests := map[int]*pb.BrokerRequest{
+		0: &pb.BrokerRequest{},
+		1: &pb.BrokerRequest{State: pb.BrokerRequest_MISSING},
+		2: &pb.BrokerRequest{State: pb.BrokerRequest_REPLACE, TargetBrokers: []uint32{1001, 1002}},
+		3: &pb.BrokerRequest{State: pb.BrokerRequest_MISSING, Id: 1003},
+		4: &pb.BrokerRequest{State: pb.BrokerRequest_MISSING, Id: 1001},
+		5: &pb.BrokerRequest{State: pb.BrokerRequest_REPLACE, TargetBrokers: []uint32{1001, 1005}},
+		6: &pb.BrokerRequest{State: pb.BrokerRequest_REPLACE, TargetBrokers: []uint32{1001, 1005}, Id: 1002},
+	}
+
+	expected := map[int][]uint32{
+		0: []uint32{1001, 1002, 1005},
+		1: []uint32{1003, 1004},
+		2: []uint32{1003, 1004},
+		3: []uint32{1003},
+		4: []uint32{},
+		5: []uint32{1002, 1003, 1004},
+		6: []uint32{1002},
+	}
+
+	for i, req := range tests {
+		resp, err := s.GetBrokers(context.Background(), req)
+		if err != nil {
+			t.Errorf("[test %d] Unexpected error: %s", i, err)
+			continue
+		}
+
+		ids := BrokerSet(resp.Brokers).IDs()
+		if !intsEqual(ids, expected[i]) {
+			t.Errorf("[test %d] Expected brokers %v, got %v", i, expected[i], ids)
+		}
+
+		// Unregistered brokers have no metadata.
+		for _, b := range resp.Brokers {
+			if zk.unregistered[int(b.Id)] && b.Rack != "" {
+				t.Errorf("[test %d] Expected no metadata for broker %d", i, b.Id)
+			}
+		}
+	}
+}
+
 func TestListBrokers(t *testing.T) {
 	s := testServer()
 
@@ -73,6 +307,274 @@ func TestListBrokers(t *testing.T) {
 	}
 }
 
+func TestBrokersSortBy(t *testing.T) {
+	s := testServer()
+
+	tests := map[pb.BrokerRequest_SortBy]idList{
+		pb.BrokerRequest_ID: idList{1001, 1002, 1003, 1004, 1005},
+		// Most storage free first.
+		pb.BrokerRequest_STORAGE_FREE: idList{1005, 1004, 1003, 1002, 1001},
+		// Fewest replicas first; 1005 holds none, 1003 and
+		// 1004 hold 4 and 1001 and 1002 hold 6.
+		pb.BrokerRequest_USED: idList{1005, 1003, 1004, 1001, 1002},
+	}
+
+	for sortBy, expected := range tests {
+		req := &pb.BrokerRequest{SortBy: sortBy}
+
+		resp, err := s.ListBrokers(context.Background(), req)
+		if err != nil {
+			t.Fatalf("[%s] Unexpected error: %s", sortBy, err)
+		}
+
+		if !intsEqual(expected, resp.Ids) {
+			t.Errorf("[%s] Expected ListBrokers IDs %v, got %v", sortBy, expected, resp.Ids)
+		}
+
+		resp, err = s.GetBrokers(context.Background(), req)
+		if err != nil {
+			t.Fatalf("[%s] Unexpected error: %s", sortBy, err)
+		}
+
+		if !intsEqual(expected, resp.Ids) {
+			t.Errorf("[%s] Expected GetBrokers IDs %v, got %v", sortBy, expected, resp.Ids)
+		}
+
+		if len(resp.Brokers) != len(expected) {
+			t.Errorf("[%s] Expected %d brokers, got %d", sortBy, len(expected), len(resp.Brokers))
+		}
+	}
+}
+
+// testFullMetaZK is a kafkazk.Mock where brokers
+// have all ZooKeeper metadata fields populated.
+type testFullMetaZK struct {
+	kafkazk.Mock
+}
+
+func (zk *testFullMetaZK) GetAllBrokerMeta(withMetrics bool) (kafkazk.BrokerMetaMap, []error) {
+	bm, errs := zk.Mock.GetAllBrokerMeta(withMetrics)
+
+	for id, meta := range bm {
+		meta.Host = fmt.Sprintf("kafka-%d", id)
+		meta.Port = 9092
+		meta.JMXPort = 9999
+		meta.Endpoints = []string{fmt.Sprintf("PLAINTEXT://kafka-%d:9092", id)}
+		meta.ListenerSecurityProtocolMap = map[string]string{"PLAINTEXT": "PLAINTEXT"}
+		meta.Timestamp = "1544357419406"
+		meta.Version = 4
+	}
+
+	return bm, errs
+}
+
+func TestBrokersFields(t *testing.T) {
+	s := testServer()
+	s.ZK = &testFullMetaZK{}
+
+	req := &pb.BrokerRequest{
+		Fields: []string{"id", "rack", "storage_free"},
+		SortBy: pb.BrokerRequest_STORAGE_FREE,
+	}
+
+	resp, err := s.GetBrokers(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	// Sorting by an excluded field is unaffected.
+	if expected := (idList{1005, 1004, 1003, 1002, 1001}); !intsEqual(expected, resp.Ids) {
+		t.Errorf("Expected IDs %v, got %v", expected, resp.Ids)
+	}
+
+	if len(resp.Warnings) != 0 {
+		t.Errorf("Unexpected warnings: %v", resp.Warnings)
+	}
+
+	// All other fields are zero.
+	b := resp.Brokers[1001]
+	expected := &pb.Broker{Id: 1001, Rack: "a", StorageFree: 2000.00}
+
+	if b.String() != expected.String() {
+		t.Errorf("Expected broker %v, got %v", expected, b)
+	}
+
+	// Unknown fields are ignored and reported.
+	req = &pb.BrokerRequest{Id: 1002, IncludeMetadata: true, Fields: []string{"host", "nope"}}
+
+	resp, err = s.ListBrokers(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if len(resp.Warnings) != 1 || resp.Warnings[0] != "unknown field 'nope' ignored" {
+		t.Errorf("Expected an unknown field warning, got %v", resp.Warnings)
+	}
+
+	b = resp.Brokers[1002]
+	expected = &pb.Broker{Host: "kafka-1002"}
+
+	if b.String() != expected.String() {
+		t.Errorf("Expected broker %v, got %v", expected, b)
+	}
+
+	// All fields are populated without a projection.
+	resp, err = s.GetBrokers(context.Background(), &pb.BrokerRequest{Id: 1002})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	b = resp.Brokers[1002]
+	if b.Id != 1002 || b.Rack != "b" || b.StorageFree != 4000.00 || b.Host != "kafka-1002" || len(b.Endpoints) != 1 || b.Timestamp == 0 {
+		t.Errorf("Expected a fully populated broker, got %v", b)
+	}
+}
+
+func TestBrokersPagination(t *testing.T) {
+	// Paging requires a higher request rate.
+	s, _ := NewServer(Config{
+		ReadReqRate:  1000,
+		WriteReqRate: 1,
+		ZKTagsPrefix: testConfig.Prefix,
+		test:         true,
+	})
+	s.DialZK(nil, nil, nil)
+
+	ctx := context.Background()
+
+	all, err := s.ListBrokers(ctx, &pb.BrokerRequest{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	for _, size := range []uint32{1, 2, 4, 5, 10} {
+		var ids []uint32
+		var pages int
+		seen := map[uint32]bool{}
+		req := &pb.BrokerRequest{PageSize: size}
+
+		for {
+			listResp, err := s.ListBrokers(ctx, req)
+			if err != nil {
+				t.Fatalf("[size %d] Unexpected error: %s", size, err)
+			}
+
+			getResp, err := s.GetBrokers(ctx, req)
+			if err != nil {
+				t.Fatalf("[size %d] Unexpected error: %s", size, err)
+			}
+
+			if uint32(len(listResp.Ids)) > size {
+				t.Errorf("[size %d] Expected at most %d brokers, got %d", size, size, len(listResp.Ids))
+			}
+
+			// Both calls return the same page.
+			if !intsEqual(listResp.Ids, BrokerSet(getResp.Brokers).IDs()) || listResp.NextPageToken != getResp.NextPageToken {
+				t.Errorf("[size %d] Expected GetBrokers page %v, got %v", size, listResp.Ids, BrokerSet(getResp.Brokers).IDs())
+			}
+
+			for _, id := range listResp.Ids {
+				if seen[id] {
+					t.Errorf("[size %d] Duplicate broker %d", size, id)
+				}
+				seen[id] = true
+				ids = append(ids, id)
+			}
+
+			pages++
+
+			if listResp.NextPageToken == "" {
+				break
+			}
+
+			req.PageToken = listResp.NextPageToken
+		}
+
+		if !intsEqual(ids, all.Ids) {
+			t.Errorf("[size %d] Expected brokers %v, got %v", size, all.Ids, ids)
+		}
+
+		if expected := (len(all.Ids) + int(size) - 1) / int(size); pages != expected {
+			t.Errorf("[size %d] Expected %d pages, got %d", size, expected, pages)
+		}
+	}
+}
+
+func TestBrokerSetPage(t *testing.T) {
+	bs := BrokerSet{1001: nil, 1003: nil, 1005: nil, 1007: nil}
+
+	// Tokens refer to the last ID returned; removing
+	// that broker doesn't affect the following page.
+	_, next, _ := bs.Page(2, "")
+	delete(bs, 1003)
+
+	page, next, err := bs.Page(2, next)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if ids := page.IDs(); !intsEqual(ids, []uint32{1005, 1007}) || next != "" {
+		t.Errorf("Expected page [1005 1007] and no next token, got %v, '%s'", ids, next)
+	}
+
+	for _, token := range []string{"not a token!", encodePageToken(1001)[:1]} {
+		if _, _, err := bs.Page(2, token); err != ErrInvalidPageToken {
+			t.Errorf("Expected error '%s' for token '%s', got '%v'", ErrInvalidPageToken, token, err)
+		}
+	}
+}
+
+func TestListBrokersIncludeMetadata(t *testing.T) {
+	s := testServer()
+
+	// IDs only by default.
+	resp, err := s.ListBrokers(context.Background(), &pb.BrokerRequest{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if resp.Brokers != nil {
+		t.Errorf("Expected a nil BrokerResponse.Brokers field")
+	}
+
+	if !intsEqual(idList{1001, 1002, 1003, 1004, 1005}, resp.Ids) {
+		t.Errorf("Expected broker list %v, got %v", idList{1001, 1002, 1003, 1004, 1005}, resp.Ids)
+	}
+
+	// With metadata.
+	req := &pb.BrokerRequest{Tag: []string{"rack:a"}, IncludeMetadata: true}
+
+	resp, err = s.ListBrokers(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	expected := map[uint32]*pb.Broker{
+		1001: &pb.Broker{Id: 1001, Rack: "a", StorageFree: 2000.00},
+		1004: &pb.Broker{Id: 1004, Rack: "a", StorageFree: 8000.00},
+	}
+
+	if !intsEqual(idList{1001, 1004}, resp.Ids) {
+		t.Errorf("Expected broker list %v, got %v", idList{1001, 1004}, resp.Ids)
+	}
+
+	if len(resp.Brokers) != len(expected) {
+		t.Fatalf("Expected %d brokers, got %d", len(expected), len(resp.Brokers))
+	}
+
+	for id, b := range expected {
+		got, exists := resp.Brokers[id]
+		if !exists {
+			t.Errorf("Expected broker %d in BrokerResponse.Brokers", id)
+			continue
+		}
+
+		if got.Id != b.Id || got.Rack != b.Rack || got.StorageFree != b.StorageFree {
+			t.Errorf("Expected broker %+v, got %+v", b, got)
+		}
+	}
+}
+
 func TestCustomTagBrokerFilter(t *testing.T) {
 	s := testServer()
 
@@ -135,15 +637,187 @@ func TestTagBroker(t *testing.T) {
 		1: ErrBrokerIDEmpty,
 		2: ErrNilTags,
 		3: ErrNilTags,
-		4: ErrBrokerNotExist,
+		// Unregistered brokers may be tagged.
+		4: nil,
+	}
+
+	expectedMsg := map[int]string{
+		0: "success",
+		4: tagUnregisteredMsg,
+	}
+
+	for i, req := range tests {
+		resp, err := s.TagBroker(context.Background(), req)
+		if err != expected[i] {
+			t.Errorf("[test %d] Expected err '%v', got '%v'", i, expected[i], err)
+		}
+
+		if err == nil && resp.Message != expectedMsg[i] {
+			t.Errorf("[test %d] Expected message '%s', got '%s'", i, expectedMsg[i], resp.Message)
+		}
+	}
+}
+
+func TestTagBrokerMerge(t *testing.T) {
+	s := testServer()
+
+	for _, id := range []uint32{1001, 1020} {
+		o := KafkaObject{Type: "broker", ID: fmt.Sprintf("%d", id)}
+
+		reqs := []*pb.BrokerRequest{
+			&pb.BrokerRequest{Id: id, Tag: []string{"k:v", "k2:v2"}},
+			// Overwrites k2, adds k3 and leaves k unmodified.
+			&pb.BrokerRequest{Id: id, Tag: []string{"k2:new", "k3:v3"}},
+		}
+
+		for _, req := range reqs {
+			if _, err := s.TagBroker(context.Background(), req); err != nil {
+				t.Fatalf("[broker %d] Unexpected error: %s", id, err)
+			}
+		}
+
+		expected := TagSet{"k": "v", "k2": "new", "k3": "v3"}
+		got, _ := s.Tags.Store.GetTags(o)
+		if !expected.Equal(got) {
+			t.Errorf("[broker %d] Expected TagSet %v, got %v", id, expected, got)
+		}
+
+		// Delete a tag.
+		req := &pb.BrokerRequest{Id: id, Tag: []string{"k"}}
+		resp, err := s.DeleteBrokerTags(context.Background(), req)
+		if err != nil {
+			t.Fatalf("[broker %d] Unexpected error: %s", id, err)
+		}
+
+		expectedMsg := "success"
+		if id == 1020 {
+			expectedMsg = tagUnregisteredMsg
+		}
+
+		if resp.Message != expectedMsg {
+			t.Errorf("[broker %d] Expected message '%s', got '%s'", id, expectedMsg, resp.Message)
+		}
+
+		expected = TagSet{"k2": "new", "k3": "v3"}
+		got, _ = s.Tags.Store.GetTags(o)
+		if !expected.Equal(got) {
+			t.Errorf("[broker %d] Expected TagSet %v, got %v", id, expected, got)
+		}
+	}
+}
+
+// flakyTagStorage wraps zkTagStorageMock, failing
+// SetTags calls for an ID while failures[ID] > 0.
+type flakyTagStorage struct {
+	*zkTagStorageMock
+	failures map[string]int
+}
+
+func (f *flakyTagStorage) SetTags(o KafkaObject, ts TagSet) error {
+	if f.failures[o.ID] > 0 {
+		f.failures[o.ID]--
+		return errors.New("transient error")
+	}
+
+	return f.zkTagStorageMock.SetTags(o, ts)
+}
+
+func TestTagBrokers(t *testing.T) {
+	s := testServer()
+
+	backoff := tagRetryBackoff
+	tagRetryBackoff = 0
+	defer func() { tagRetryBackoff = backoff }()
+
+	// 1002 recovers within the allowed attempts, 1003 doesn't.
+	store := &flakyTagStorage{
+		zkTagStorageMock: newzkTagStorageMock(),
+		failures:         map[string]int{"1002": tagAttempts - 1, "1003": tagAttempts},
+	}
+	s.Tags.Store = store
+
+	req := &pb.BrokerTagsRequest{
+		Ids: []uint32{1001, 1002, 1003, 1020, 0},
+		Tag: []string{"k:v"},
+	}
+
+	resp, err := s.TagBrokers(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	expected := map[uint32]string{
+		1001: "success",
+		1002: "success",
+		1003: "transient error",
+		1020: tagUnregisteredMsg,
+		0:    ErrBrokerIDEmpty.Error(),
+	}
+
+	if len(resp.Results) != len(expected) {
+		t.Errorf("Expected %d results, got %d", len(expected), len(resp.Results))
+	}
+
+	for id, r := range expected {
+		if resp.Results[id] != r {
+			t.Errorf("[broker %d] Expected result '%s', got '%s'", id, r, resp.Results[id])
+		}
+	}
+
+	// Check that only the successful brokers were tagged.
+	for _, id := range []string{"1001", "1002", "1020"} {
+		ts, _ := store.GetTags(KafkaObject{Type: "broker", ID: id})
+		if ts["k"] != "v" {
+			t.Errorf("[broker %s] Expected tag k:v, got %v", id, ts)
+		}
+	}
+
+	if _, err := store.GetTags(KafkaObject{Type: "broker", ID: "1003"}); err != ErrKafkaObjectDoesNotExist {
+		t.Errorf("Expected broker 1003 to be untagged")
+	}
+
+	// Retries stop once the request context is cancelled.
+	tagRetryBackoff = time.Minute
+	store.failures["1003"] = tagAttempts
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	resp, err = s.TagBrokers(ctx, &pb.BrokerTagsRequest{Ids: []uint32{1003}, Tag: []string{"k:v"}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if r := resp.Results[1003]; r != context.DeadlineExceeded.Error() {
+		t.Errorf("Expected result '%s', got '%s'", context.DeadlineExceeded, r)
+	}
+}
+
+func TestTagBrokersFailures(t *testing.T) {
+	s := testServer()
+
+	tests := map[int]*pb.BrokerTagsRequest{
+		0: &pb.BrokerTagsRequest{Tag: []string{"k:v"}},
+		1: &pb.BrokerTagsRequest{Ids: []uint32{1001}},
+	}
+
+	expected := map[int]error{
+		0: ErrBrokerIDsEmpty,
+		1: ErrNilTags,
 	}
 
 	for i, req := range tests {
-		_, err := s.TagBroker(context.Background(), req)
+		_, err := s.TagBrokers(context.Background(), req)
 		if err != expected[i] {
 			t.Errorf("[test %d] Expected err '%v', got '%v'", i, expected[i], err)
 		}
 	}
+
+	// Malformed tags fail the entire request.
+	req := &pb.BrokerTagsRequest{Ids: []uint32{1001}, Tag: []string{"k"}}
+	if _, err := s.TagBrokers(context.Background(), req); err == nil {
+		t.Errorf("Expected error for malformed tags")
+	}
 }
 
 func TestDeleteBrokerTags(t *testing.T) {
@@ -210,6 +884,94 @@ func TestDeleteBrokerTagsFailures(t *testing.T) {
 	}
 }
 
+func TestRemoveBroker(t *testing.T) {
+	s := testServer()
+
+	// 1005 is registered but holds no partitions;
+	// 1020 is unregistered with provisioned tags.
+	for _, id := range []uint32{1005, 1020} {
+		req := &pb.BrokerRequest{Id: id, Tag: []string{"k:v"}}
+		if _, err := s.TagBroker(context.Background(), req); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+	}
+
+	// 1005 has a recorded membership change.
+	s.brokerHistory.changes = []brokerChange{
+		{added: BrokerSet{1005: &pb.Broker{Id: 1005}}, removed: BrokerSet{}},
+	}
+
+	tests := map[int]*pb.BrokerRequest{
+		0: &pb.BrokerRequest{Id: 1005},
+		1: &pb.BrokerRequest{Id: 1020},
+		// Already removed.
+		2: &pb.BrokerRequest{Id: 1020},
+	}
+
+	expected := map[int][]uint32{
+		0: []uint32{1005},
+		1: []uint32{1020},
+		2: []uint32{},
+	}
+
+	for i := 0; i < len(tests); i++ {
+		req := tests[i]
+		resp, err := s.RemoveBroker(context.Background(), req)
+		if err != nil {
+			t.Fatalf("[test %d] Unexpected error: %s", i, err)
+		}
+
+		if !intsEqual(expected[i], resp.Ids) {
+			t.Errorf("[test %d] Expected IDs %v, got %v", i, expected[i], resp.Ids)
+		}
+
+		o := KafkaObject{Type: "broker", ID: fmt.Sprintf("%d", req.Id)}
+		if _, err := s.Tags.Store.GetTags(o); err != ErrKafkaObjectDoesNotExist {
+			t.Errorf("[test %d] Expected tags to be removed, got error: %v", i, err)
+		}
+	}
+
+	if changes := s.brokerHistory.recent(); len(changes) != 0 {
+		t.Errorf("Expected no recorded changes, got %v", changes)
+	}
+
+	// Test no ID.
+	_, err := s.RemoveBroker(context.Background(), &pb.BrokerRequest{})
+	if err != ErrBrokerIDEmpty {
+		t.Errorf("Unexpected error: %s", err)
+	}
+}
+
+func TestRemoveBrokerAssigned(t *testing.T) {
+	s := testServer()
+
+	req := &pb.BrokerRequest{Id: 1002, Tag: []string{"k:v"}}
+	if _, err := s.TagBroker(context.Background(), req); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	_, err := s.RemoveBroker(context.Background(), &pb.BrokerRequest{Id: 1002})
+
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("Expected FailedPrecondition error, got %v", err)
+	}
+
+	expected := "broker 1002 still holds partitions for topics: test_topic, test_topic2"
+	if msg := status.Convert(err).Message(); msg != expected {
+		t.Errorf("Expected error message '%s', got '%s'", expected, msg)
+	}
+
+	// The tags should be untouched.
+	tags, err := s.Tags.Store.GetTags(KafkaObject{Type: "broker", ID: "1002"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if !tags.Equal(TagSet{"k": "v"}) {
+		t.Errorf("Expected TagSet %v, got %v", TagSet{"k": "v"}, tags)
+	}
+}
+
 func TestBrokerMappings(t *testing.T) {
 	s := testServer()
 
@@ -1 +1,162 @@
 package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+
+	"github.com/DataDog/kafka-kit/kafkazk"
+	pb "github.com/DataDog/kafka-kit/registry/protos"
+)
+
+// ReassignPartitions generates a partition reassignment plan for the topics
+// and target brokers specified in the *pb.ReassignRequest. Placement follows
+// the topicmappr rebuild command: brokers not in the target list are replaced,
+// with new placements made by the kafkazk rebuild according to the requested
+// placement strategy. Placement errors are returned as warnings; as with the
+// topicmappr --ignore-warns flag, the plan is only populated if there are no
+// warnings or the request IgnoreWarns field is set. The plan is never applied.
+func (s *Server) ReassignPartitions(ctx context.Context, req *pb.ReassignRequest) (*pb.ReassignResponse, error) {
+	if err := s.ValidateRequest(ctx, req, readRequest); err != nil {
+		return nil, err
+	}
+
+	params := rebuildParamsFromRequest(req)
+
+	topics, err := topicRegexes(req.Topics)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get the current partition map.
+	pm, err := kafkazk.PartitionMapFromZK(topics, s.ZK)
+	if err != nil {
+		return nil, err
+	}
+
+	storage := params.Strategy == "storage"
+
+	// Get broker metadata, including
+	// metrics for storage placement.
+	bm, errs := s.ZK.GetAllBrokerMeta(storage)
+	if errs != nil {
+		return nil, ErrFetchingBrokers
+	}
+
+	if storage {
+		if params.PMM, err = s.ZK.GetAllPartitionMeta(); err != nil {
+			return nil, err
+		}
+	}
+
+	var ids []int
+	for _, id := range req.Brokers {
+		ids = append(ids, int(id))
+	}
+
+	params.BM = kafkazk.BrokerMapFromPartitionMap(pm, bm, req.ForceRebuild)
+	bs, _ := params.BM.UpdateSorted(ids, bm)
+
+	if req.Replication > 0 {
+		pm.SetReplicationRackAware(int(req.Replication), params.BM)
+	}
+
+	// Storage freed by brokers being replaced, or all
+	// brokers in a force rebuild, is available for placements.
+	freed := func(b *kafkazk.Broker) bool { return req.ForceRebuild || b.Replace }
+	if storage {
+		if err := params.BM.SubStorage(pm, params.PMM, freed); err != nil {
+			return nil, err
+		}
+	}
+
+	// A force rebuild places all partitions
+	// from a stripped map.
+	if req.ForceRebuild {
+		pm = pm.Strip()
+	}
+
+	out, errs := pm.Rebuild(params)
+
+	resp := &pb.ReassignResponse{}
+
+	for _, e := range errs {
+		resp.Warnings = append(resp.Warnings, e.Error())
+	}
+
+	if bs.Missing > 0 {
+		resp.Warnings = append(resp.Warnings, fmt.Sprintf("%d provided brokers not found in ZooKeeper", bs.Missing))
+	}
+
+	sort.Strings(resp.Warnings)
+
+	if len(resp.Warnings) > 0 && !req.IgnoreWarns {
+		return resp, nil
+	}
+
+	sort.Sort(out.Partitions)
+
+	plan, err := json.Marshal(out)
+	if err != nil {
+		return nil, err
+	}
+
+	resp.Plan = string(plan)
+
+	for _, p := range out.Partitions {
+		pr := &pb.PartitionReassignment{Topic: p.Topic, Partition: uint32(p.Partition)}
+		for _, id := range p.Replicas {
+			pr.Replicas = append(pr.Replicas, uint32(id))
+		}
+		resp.Partitions = append(resp.Partitions, pr)
+	}
+
+	return resp, nil
+}
+
+// rebuildParamsFromRequest returns a kafkazk.RebuildParams populated with
+// the placement settings of a *pb.ReassignRequest, applying defaults for
+// unset fields. The request is validated by validateReassignRequest.
+func rebuildParamsFromRequest(req *pb.ReassignRequest) kafkazk.RebuildParams {
+	params := kafkazk.NewRebuildParams()
+
+	params.Strategy = req.Placement
+	if params.Strategy == "" {
+		params.Strategy = "count"
+	}
+
+	params.Optimization = req.Optimize
+	if params.Optimization == "" {
+		params.Optimization = "distribution"
+	}
+
+	if req.PartitionSizeFactor != 0 {
+		params.PartnSzFactor = req.PartitionSizeFactor
+	}
+
+	return params
+}
+
+// topicRegexes takes a []string of topic names and returns a
+// []*regexp.Regexp. Names without regular expression metacharacters
+// are matched literally, as with the topicmappr --topics flag.
+func topicRegexes(topics []string) ([]*regexp.Regexp, error) {
+	var res []*regexp.Regexp
+
+	for _, t := range topics {
+		if regexp.QuoteMeta(t) == t {
+			t = fmt.Sprintf("^%s$", t)
+		}
+
+		r, err := regexp.Compile(t)
+		if err != nil {
+			return nil, fmt.Errorf("invalid topic regex '%s'", t)
+		}
+
+		res = append(res, r)
+	}
+
+	return res, nil
+}
@@ -0,0 +1,167 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	pb "github.com/DataDog/kafka-kit/registry/protos"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// testReassignPlan is the expected plan for a no-op
+// reassignment of the kafkazk.Mock test_topic.
+const testReassignPlan = `{"version":1,"partitions":[` +
+	`{"topic":"test_topic","partition":0,"replicas":[1001,1002]},` +
+	`{"topic":"test_topic","partition":1,"replicas":[1002,1001]},` +
+	`{"topic":"test_topic","partition":2,"replicas":[1003,1004,1001]},` +
+	`{"topic":"test_topic","partition":3,"replicas":[1004,1003,1002]}]}`
+
+func TestReassignPartitions(t *testing.T) {
+	s := testServer()
+
+	req := &pb.ReassignRequest{
+		Topics:  []string{"test_topic"},
+		Brokers: []uint32{1001, 1002, 1003, 1004},
+	}
+
+	resp, err := s.ReassignPartitions(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if len(resp.Warnings) != 0 {
+		t.Errorf("Unexpected warnings: %v", resp.Warnings)
+	}
+
+	if resp.Plan != testReassignPlan {
+		t.Errorf("Expected plan:\n%s\ngot:\n%s", testReassignPlan, resp.Plan)
+	}
+
+	checkReassignPartitions(t, resp)
+}
+
+func TestReassignPartitionsReplace(t *testing.T) {
+	s := testServer()
+
+	// 1004 is replaced.
+	req := &pb.ReassignRequest{
+		Topics:  []string{"test_topic"},
+		Brokers: []uint32{1001, 1002, 1003, 1005},
+	}
+
+	resp, err := s.ReassignPartitions(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if len(resp.Warnings) != 0 {
+		t.Errorf("Unexpected warnings: %v", resp.Warnings)
+	}
+
+	for _, p := range resp.Partitions {
+		for _, id := range p.Replicas {
+			if id == 1004 {
+				t.Errorf("p%d: Unexpected replaced broker 1004 in %v", p.Partition, p.Replicas)
+			}
+		}
+	}
+
+	checkReassignPartitions(t, resp)
+}
+
+func TestReassignPartitionsWarnings(t *testing.T) {
+	s := testServer()
+
+	// Three replicas can't be placed
+	// among two brokers.
+	req := &pb.ReassignRequest{
+		Topics:  []string{"test_topic"},
+		Brokers: []uint32{1001, 1002},
+	}
+
+	resp, err := s.ReassignPartitions(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if len(resp.Warnings) == 0 {
+		t.Error("Expected warnings")
+	}
+
+	if resp.Plan != "" || len(resp.Partitions) != 0 {
+		t.Error("Expected no plan")
+	}
+
+	// The plan is returned if warnings are ignored.
+	req.IgnoreWarns = true
+
+	resp, err = s.ReassignPartitions(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if len(resp.Warnings) == 0 || resp.Plan == "" {
+		t.Error("Expected warnings and a plan")
+	}
+
+	checkReassignPartitions(t, resp)
+}
+
+func TestReassignPartitionsInvalid(t *testing.T) {
+	s := testServer()
+
+	// Requests are validated by the gRPC interceptors.
+	conn, stop := testGRPCConn(t, s)
+	defer stop()
+
+	client := pb.NewRegistryClient(conn)
+
+	tests := map[int]*pb.ReassignRequest{
+		0: &pb.ReassignRequest{Brokers: []uint32{1001}},
+		1: &pb.ReassignRequest{Topics: []string{"test_topic"}},
+		2: &pb.ReassignRequest{Topics: []string{"test_topic"}, Brokers: []uint32{1001}, Placement: "invalid"},
+		3: &pb.ReassignRequest{Topics: []string{"test_topic"}, Brokers: []uint32{1001}, Optimize: "invalid"},
+		4: &pb.ReassignRequest{Topics: []string{"test_topic["}, Brokers: []uint32{1001}},
+	}
+
+	for i, req := range tests {
+		if _, err := client.ReassignPartitions(context.Background(), req); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("[test %d] Expected InvalidArgument error, got %v", i, err)
+		}
+	}
+}
+
+// checkReassignPartitions checks that the structured
+// partitions of a response match the plan.
+func checkReassignPartitions(t *testing.T, resp *pb.ReassignResponse) {
+	var plan struct {
+		Version    int `json:"version"`
+		Partitions []struct {
+			Topic     string   `json:"topic"`
+			Partition uint32   `json:"partition"`
+			Replicas  []uint32 `json:"replicas"`
+		} `json:"partitions"`
+	}
+
+	if err := json.Unmarshal([]byte(resp.Plan), &plan); err != nil {
+		t.Fatalf("Invalid plan: %s", err)
+	}
+
+	if plan.Version != 1 {
+		t.Errorf("Expected plan version 1, got %d", plan.Version)
+	}
+
+	if len(plan.Partitions) != len(resp.Partitions) {
+		t.Fatalf("Expected %d partitions, got %d", len(plan.Partitions), len(resp.Partitions))
+	}
+
+	for i, p := range plan.Partitions {
+		r := resp.Partitions[i]
+		if r.Topic != p.Topic || r.Partition != p.Partition || !intsEqual(r.Replicas, p.Replicas) {
+			t.Errorf("Expected partition %v, got %v", p, r)
+		}
+	}
+}
@@ -0,0 +1,741 @@
+package server
+
+import (
+	"context"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	pb "github.com/DataDog/kafka-kit/registry/protos"
+
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	// eventPollInterval is the interval at which
+	// cluster state is polled for WatchEvents.
+	eventPollInterval = 5 * time.Second
+	// eventBufferSize is the number of events buffered for
+	// each WatchEvents stream. Streams that fall further
+	// behind the event feed are ended.
+	eventBufferSize = 256
+
+	// ErrEventsDropped error.
+	ErrEventsDropped = status.Error(codes.ResourceExhausted, "stream fell behind the event feed")
+)
+
+// WatchEvents streams broker and topic change events. Cluster state is
+// polled from ZooKeeper and compared against the previous observation;
+// any differences are sent as events in a single ordered feed. A single
+// poll is shared by all WatchEvents streams. The stream runs until the
+// client disconnects, an event fails to send or the stream falls too far
+// behind the feed.
+func (s *Server) WatchEvents(req *pb.Empty, stream pb.Registry_WatchEventsServer) error {
+	if err := s.ValidateRequest(stream.Context(), req, readRequest); err != nil {
+		return err
+	}
+
+	events, err := s.events.subscribe(eventPollInterval)
+	if err != nil {
+		return err
+	}
+	defer s.events.unsubscribe(events)
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case e, ok := <-events:
+			if !ok {
+				return ErrEventsDropped
+			}
+
+			if err := stream.Send(e); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// clusterSnapshot holds the brokers
+// and topics observed at a point in time.
+type clusterSnapshot struct {
+	brokers BrokerSet
+	topics  TopicSet
+}
+
+// clusterSnapshot fetches all brokers and topics.
+func (s *Server) clusterSnapshot() (*clusterSnapshot, error) {
+	brokers, err := s.fetchBrokerSet(&pb.BrokerRequest{}, false)
+	if err != nil {
+		return nil, err
+	}
+
+	topics, err := s.fetchTopicSet(&pb.TopicRequest{})
+	if err != nil {
+		return nil, err
+	}
+
+	return &clusterSnapshot{brokers: brokers, topics: topics}, nil
+}
+
+// eventFeed polls cluster state on behalf of all WatchEvents streams. A
+// single poll loop runs while any stream is subscribed, with each event
+// delivered to every subscriber.
+type eventFeed struct {
+	sync.Mutex
+	snapshot func() (*clusterSnapshot, error)
+	logger   Logger
+	subs     map[chan *pb.Event]struct{}
+	// stop, if non-nil, stops the running poll loop.
+	stop context.CancelFunc
+}
+
+func newEventFeed(snapshot func() (*clusterSnapshot, error), logger Logger) *eventFeed {
+	return &eventFeed{
+		snapshot: snapshot,
+		logger:   logger,
+		subs:     map[chan *pb.Event]struct{}{},
+	}
+}
+
+// subscribe returns a channel receiving all subsequent events. If no poll
+// loop is running, a baseline snapshot is taken and a loop polling at the
+// provided interval is started; an error is returned if the snapshot fails.
+// The channel is closed if the subscriber falls behind the feed.
+func (f *eventFeed) subscribe(interval time.Duration) (chan *pb.Event, error) {
+	f.Lock()
+	defer f.Unlock()
+
+	if f.stop == nil {
+		w := &eventWatcher{snapshot: f.snapshot}
+		if _, err := w.poll(); err != nil {
+			return nil, err
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		f.stop = cancel
+		go f.run(ctx, w, interval)
+	}
+
+	c := make(chan *pb.Event, eventBufferSize)
+	f.subs[c] = struct{}{}
+
+	return c, nil
+}
+
+// unsubscribe removes a subscription. The poll
+// loop is stopped once no subscribers remain.
+func (f *eventFeed) unsubscribe(c chan *pb.Event) {
+	f.Lock()
+	defer f.Unlock()
+
+	delete(f.subs, c)
+	f.stopIfIdle()
+}
+
+// stopIfIdle stops the poll loop if there are no
+// subscribers. The caller must hold the lock.
+func (f *eventFeed) stopIfIdle() {
+	if len(f.subs) == 0 && f.stop != nil {
+		f.stop()
+		f.stop = nil
+	}
+}
+
+// run polls at the provided interval until the context is done, publishing
+// all events. Snapshot errors are logged and retried on the next poll.
+func (f *eventFeed) run(ctx context.Context, w *eventWatcher, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		events, err := w.poll()
+		if err != nil {
+			loggerOrNop(f.logger).Errorf("error polling cluster state: %s", err)
+			continue
+		}
+
+		f.publish(ctx, events)
+	}
+}
+
+// publish delivers events to all subscribers. Subscribers without buffer
+// space for all events are removed and their channels closed. Events from
+// a poll loop that has been stopped are discarded.
+func (f *eventFeed) publish(ctx context.Context, events []*pb.Event) {
+	f.Lock()
+	defer f.Unlock()
+
+	if ctx.Err() != nil {
+		return
+	}
+
+subscribers:
+	for c := range f.subs {
+		for _, e := range events {
+			select {
+			case c <- e:
+			default:
+				delete(f.subs, c)
+				close(c)
+				continue subscribers
+			}
+		}
+	}
+
+	f.stopIfIdle()
+}
+
+// eventWatcher produces events from
+// successive cluster snapshots.
+type eventWatcher struct {
+	snapshot func() (*clusterSnapshot, error)
+	last     *clusterSnapshot
+	sequence uint64
+}
+
+// poll takes a snapshot and returns the events describing the changes since
+// the previous snapshot. The first poll establishes a baseline and returns no
+// events. Within a poll, broker events are ordered by ID and precede topic
+// events, which are ordered by name.
+func (w *eventWatcher) poll() ([]*pb.Event, error) {
+	current, err := w.snapshot()
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() { w.last = current }()
+
+	if w.last == nil {
+		return nil, nil
+	}
+
+	var events []*pb.Event
+	now := time.Now().Unix()
+
+	add := func(t pb.Event_Type, b *pb.Broker, tp *pb.Topic) {
+		w.sequence++
+		events = append(events, &pb.Event{
+			Type:      t,
+			Sequence:  w.sequence,
+			Timestamp: now,
+			Broker:    b,
+			Topic:     tp,
+		})
+	}
+
+	// Broker changes.
+	ids := map[uint32]struct{}{}
+	for id := range w.last.brokers {
+		ids[id] = struct{}{}
+	}
+	for id := range current.brokers {
+		ids[id] = struct{}{}
+	}
+
+	var sortedIDs []uint32
+	for id := range ids {
+		sortedIDs = append(sortedIDs, id)
+	}
+
+	sort.Slice(sortedIDs, func(i, j int) bool { return sortedIDs[i] < sortedIDs[j] })
+
+	for _, id := range sortedIDs {
+		old, existed := w.last.brokers[id]
+		b, exists := current.brokers[id]
+
+		switch {
+		case !existed && exists:
+			add(pb.Event_BROKER_JOINED, b, nil)
+		case existed && !exists:
+			add(pb.Event_BROKER_LEFT, old, nil)
+		}
+	}
+
+	// Topic changes.
+	names := map[string]struct{}{}
+	for n := range w.last.topics {
+		names[n] = struct{}{}
+	}
+	for n := range current.topics {
+		names[n] = struct{}{}
+	}
+
+	var sortedNames []string
+	for n := range names {
+		sortedNames = append(sortedNames, n)
+	}
+
+	sort.Strings(sortedNames)
+
+	for _, n := range sortedNames {
+		old, existed := w.last.topics[n]
+		t, exists := current.topics[n]
+
+		switch {
+		case !existed && exists:
+			add(pb.Event_TOPIC_CREATED, nil, t)
+		case existed && !exists:
+			add(pb.Event_TOPIC_DELETED, nil, old)
+		case old.Partitions != t.Partitions || old.Replication != t.Replication:
+			add(pb.Event_TOPIC_RECONFIGURED, nil, t)
+		}
+	}
+
+	return events, nil
+}
+
+var (
+	// brokerWatchRetryInterval is the interval at which WatchBrokers
+	// retries setting a watch or fetching brokers following an error.
+	brokerWatchRetryInterval = 5 * time.Second
+)
+
+// WatchBrokers streams the brokers matching the request. A snapshot of the
+// current membership is sent first, followed by a delta listing the added
+// and removed broker IDs each time the set of matching brokers changes.
+// Recent membership changes observed by the server are replayed as deltas
+// immediately following the snapshot, filtered by the request ID and tag
+// selectors; changes aren't replayed for requests with a broker state.
+// Changes are observed through a ZooKeeper watch on the registered broker
+// IDs. The stream runs until the client disconnects or a response fails to
+// send.
+func (s *Server) WatchBrokers(req *pb.BrokerRequest, stream pb.Registry_WatchBrokersServer) error {
+	if err := s.ValidateRequest(stream.Context(), req, readRequest); err != nil {
+		return err
+	}
+
+	w := s.newBrokerWatcher(req)
+
+	return w.run(stream.Context(), brokerWatchRetryInterval, stream.Send)
+}
+
+// WatchBrokersControl is WatchBrokers with client-driven pause and resume.
+// The first request on the stream carries the BrokerRequest; subsequent
+// requests pause or resume the stream. While paused, no deltas are sent;
+// on resume, any changes made while paused are coalesced into a single
+// delta. The stream runs until the client disconnects, a response fails to
+// send or a request fails to be received. A client closing its side of the
+// stream doesn't end it.
+func (s *Server) WatchBrokersControl(stream pb.Registry_WatchBrokersControlServer) error {
+	first, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+
+	req := first.Request
+	if req == nil {
+		req = &pb.BrokerRequest{}
+	}
+
+	if err := s.ValidateRequest(stream.Context(), req, readRequest); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(stream.Context())
+	defer cancel()
+
+	control := make(chan bool)
+	errc := make(chan error, 1)
+
+	// Receive control requests.
+	go func() {
+		for {
+			r, err := stream.Recv()
+			switch {
+			case err == io.EOF:
+				return
+			case err != nil:
+				errc <- err
+				cancel()
+				return
+			}
+
+			var paused bool
+			switch r.Control {
+			case pb.WatchBrokersRequest_PAUSE:
+				paused = true
+			case pb.WatchBrokersRequest_RESUME:
+			default:
+				continue
+			}
+
+			select {
+			case control <- paused:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	w := s.newBrokerWatcher(req)
+	w.control = control
+
+	if err := w.run(ctx, brokerWatchRetryInterval, stream.Send); err != nil {
+		return err
+	}
+
+	select {
+	case err := <-errc:
+		return err
+	default:
+		return nil
+	}
+}
+
+// newBrokerWatcher returns a *brokerWatcher for the request.
+func (s *Server) newBrokerWatcher(req *pb.BrokerRequest) *brokerWatcher {
+	w := &brokerWatcher{
+		fetch:  func() (BrokerSet, error) { return s.fetchBrokerSet(req, false) },
+		watch:  s.ZK.WatchBrokerIDs,
+		logger: s.logger,
+	}
+
+	if req.State == pb.BrokerRequest_ANY {
+		w.replay = func() ([]*pb.BrokerResponse, error) { return s.replayBrokerChanges(req) }
+	}
+
+	return w
+}
+
+// brokerWatcher produces BrokerResponses from
+// successive broker membership observations.
+type brokerWatcher struct {
+	fetch func() (BrokerSet, error)
+	watch func(context.Context) (<-chan struct{}, error)
+	// replay, if non-nil, returns the deltas
+	// to send following the initial snapshot.
+	replay func() ([]*pb.BrokerResponse, error)
+	// control, if non-nil, receives whether
+	// the watcher should be paused.
+	control <-chan bool
+	paused  bool
+	last    BrokerSet
+	sent    bool
+	logger  Logger
+}
+
+// run sends a snapshot of the current membership, along with any replayed
+// deltas, then waits on the watch, sending a delta whenever the set of broker
+// IDs differs from what was last sent. The watch is set ahead of each fetch
+// so that no changes are missed in between. Deltas aren't sent while paused;
+// resuming sends a delta of all changes since the last one sent. Errors
+// following the initial fetch are logged and retried at the provided
+// interval. run returns when the context is done or if send returns an error.
+func (w *brokerWatcher) run(ctx context.Context, retry time.Duration, send func(*pb.BrokerResponse) error) error {
+	var next <-chan struct{}
+
+	for {
+		var retryC <-chan time.Time
+		var err error
+
+		// A watch may still be pending following a resume.
+		if next == nil {
+			next, err = w.watch(ctx)
+		}
+
+		if err == nil {
+			err = w.update(send)
+		}
+
+		if err != nil {
+			if _, ok := err.(sendError); ok || !w.sent {
+				return err
+			}
+
+			loggerOrNop(w.logger).Errorf("error watching brokers: %s", err)
+			retryC = time.After(retry)
+		}
+
+		for waiting := true; waiting; {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-next:
+				next, waiting = nil, false
+			case <-retryC:
+				waiting = false
+			case paused := <-w.control:
+				// Resuming sends any changes made while paused.
+				resumed := w.paused && !paused
+				w.paused, waiting = paused, !resumed
+			}
+		}
+	}
+}
+
+// sendError wraps errors returned by a send function.
+type sendError struct{ error }
+
+// update fetches the current membership and passes it to send, either as
+// the initial snapshot or as a delta if the set of broker IDs has changed.
+// Deltas are skipped while paused.
+func (w *brokerWatcher) update(send func(*pb.BrokerResponse) error) error {
+	if w.sent && w.paused {
+		return nil
+	}
+
+	brokers, err := w.fetch()
+	if err != nil {
+		return err
+	}
+
+	var resp *pb.BrokerResponse
+
+	if !w.sent {
+		resp = &pb.BrokerResponse{Brokers: brokers, Ids: brokers.IDs()}
+	} else if resp = brokerDelta(w.last, brokers); resp == nil {
+		return nil
+	}
+
+	if err := send(resp); err != nil {
+		return sendError{err}
+	}
+
+	snapshot := !w.sent
+	w.last, w.sent = brokers, true
+
+	if !snapshot || w.replay == nil {
+		return nil
+	}
+
+	replayed, err := w.replay()
+	if err != nil {
+		return err
+	}
+
+	for _, r := range replayed {
+		if err := send(r); err != nil {
+			return sendError{err}
+		}
+	}
+
+	return nil
+}
+
+// brokerDelta returns a BrokerResponse describing the brokers added
+// and removed from BrokerSet a to b, or nil if the IDs are unchanged.
+func brokerDelta(a, b BrokerSet) *pb.BrokerResponse {
+	resp := &pb.BrokerResponse{Brokers: BrokerSet{}}
+
+	for _, id := range b.IDs() {
+		if _, ok := a[id]; !ok {
+			resp.Added = append(resp.Added, id)
+			resp.Brokers[id] = b[id]
+		}
+	}
+
+	for _, id := range a.IDs() {
+		if _, ok := b[id]; !ok {
+			resp.Removed = append(resp.Removed, id)
+		}
+	}
+
+	if len(resp.Added) == 0 && len(resp.Removed) == 0 {
+		return nil
+	}
+
+	return resp
+}
+
+var (
+	// brokerReplaySize is the number of recent broker
+	// membership changes replayed to WatchBrokers clients.
+	brokerReplaySize = 16
+)
+
+// brokerHistory records recent changes
+// to the registered broker membership.
+type brokerHistory struct {
+	sync.Mutex
+	size    int
+	last    BrokerSet
+	changes []brokerChange
+}
+
+// brokerChange describes the brokers that
+// joined or left between two observations.
+type brokerChange struct {
+	added, removed BrokerSet
+}
+
+func newBrokerHistory(size int) *brokerHistory {
+	return &brokerHistory{size: size}
+}
+
+// observe fetches the current membership and records the change from the
+// previous observation, if any. The first observation establishes a baseline.
+// Observations are serialized so that concurrent callers record each change
+// once and in order. Only the most recent size changes are kept.
+func (h *brokerHistory) observe(fetch func() (BrokerSet, error)) error {
+	h.Lock()
+	defer h.Unlock()
+
+	current, err := fetch()
+	if err != nil {
+		return err
+	}
+
+	defer func() { h.last = current }()
+
+	if h.last == nil {
+		return nil
+	}
+
+	c := brokerChange{added: BrokerSet{}, removed: BrokerSet{}}
+
+	for id, b := range current {
+		if _, ok := h.last[id]; !ok {
+			c.added[id] = b
+		}
+	}
+
+	for id, b := range h.last {
+		if _, ok := current[id]; !ok {
+			c.removed[id] = b
+		}
+	}
+
+	if len(c.added) == 0 && len(c.removed) == 0 {
+		return nil
+	}
+
+	h.changes = append(h.changes, c)
+	if len(h.changes) > h.size {
+		h.changes = h.changes[len(h.changes)-h.size:]
+	}
+
+	return nil
+}
+
+// run observes the membership each time the watch fires until the context
+// is done. The watch is set ahead of each observation so that no changes are
+// missed in between. Errors are logged and retried at the provided interval.
+func (h *brokerHistory) run(ctx context.Context, retry time.Duration, watch func(context.Context) (<-chan struct{}, error), fetch func() (BrokerSet, error), logger Logger) {
+	var next <-chan struct{}
+
+	for {
+		var retryC <-chan time.Time
+		var err error
+
+		// A watch may still be pending following an error.
+		if next == nil {
+			next, err = watch(ctx)
+		}
+
+		if err == nil {
+			err = h.observe(fetch)
+		}
+
+		if err != nil {
+			loggerOrNop(logger).Errorf("error recording broker changes: %s", err)
+			retryC = time.After(retry)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-next:
+			next = nil
+		case <-retryC:
+		}
+	}
+}
+
+// forget removes the broker ID from the recorded changes. Changes left
+// without any brokers are dropped. The baseline is left as is so that a
+// broker still registered isn't recorded as joining again.
+func (h *brokerHistory) forget(id uint32) {
+	h.Lock()
+	defer h.Unlock()
+
+	changes := h.changes[:0]
+	for _, c := range h.changes {
+		delete(c.added, id)
+		delete(c.removed, id)
+
+		if len(c.added) > 0 || len(c.removed) > 0 {
+			changes = append(changes, c)
+		}
+	}
+
+	h.changes = changes
+}
+
+// recent returns the recorded changes, oldest first.
+func (h *brokerHistory) recent() []brokerChange {
+	h.Lock()
+	defer h.Unlock()
+
+	return append([]brokerChange(nil), h.changes...)
+}
+
+// runBrokerHistory records broker membership changes for replay to
+// WatchBrokers clients until the context is done. Changes are recorded
+// regardless of whether any clients are subscribed.
+func (s *Server) runBrokerHistory(ctx context.Context) {
+	all := func() (BrokerSet, error) { return s.fetchBrokerSet(&pb.BrokerRequest{}, false) }
+	s.brokerHistory.run(ctx, brokerWatchRetryInterval, s.ZK.WatchBrokerIDs, all, s.logger)
+}
+
+// replayBrokerChanges returns the recent broker membership changes matching
+// the request ID and tag selectors as replayed deltas, oldest first. Changes
+// without any matching brokers are omitted.
+func (s *Server) replayBrokerChanges(req *pb.BrokerRequest) ([]*pb.BrokerResponse, error) {
+	var resps []*pb.BrokerResponse
+
+	for _, c := range s.brokerHistory.recent() {
+		added, err := s.matchBrokers(req, c.added)
+		if err != nil {
+			return nil, err
+		}
+
+		removed, err := s.matchBrokers(req, c.removed)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(added) == 0 && len(removed) == 0 {
+			continue
+		}
+
+		resp := &pb.BrokerResponse{Brokers: added, Replayed: true}
+		if len(added) > 0 {
+			resp.Added = added.IDs()
+		}
+		if len(removed) > 0 {
+			resp.Removed = removed.IDs()
+		}
+
+		resps = append(resps, resp)
+	}
+
+	return resps, nil
+}
+
+// matchBrokers returns copies of the brokers in the
+// BrokerSet matching the request ID and tag selectors.
+func (s *Server) matchBrokers(req *pb.BrokerRequest, in BrokerSet) (BrokerSet, error) {
+	matched := BrokerSet{}
+
+	for id, b := range in {
+		if req.Id != 0 && id != req.Id {
+			continue
+		}
+
+		// Copied since filtering populates tags.
+		matched[id] = proto.Clone(b).(*pb.Broker)
+	}
+
+	return s.Tags.FilterBrokers(matched, req.Tag)
+}
@@ -0,0 +1,761 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DataDog/kafka-kit/kafkazk"
+	pb "github.com/DataDog/kafka-kit/registry/protos"
+
+	"google.golang.org/grpc"
+)
+
+// testSnapshots returns a series of cluster snapshots with
+// interleaved broker and topic changes along with the
+// events expected from polling them in order.
+func testSnapshots() ([]*clusterSnapshot, []pb.Event_Type, []string) {
+	broker := func(id uint32) *pb.Broker { return &pb.Broker{Id: id} }
+	topic := func(n string, p uint32) *pb.Topic { return &pb.Topic{Name: n, Partitions: p, Replication: 2} }
+
+	snapshots := []*clusterSnapshot{
+		// Baseline.
+		&clusterSnapshot{
+			brokers: BrokerSet{1001: broker(1001), 1002: broker(1002)},
+			topics:  TopicSet{"a": topic("a", 2)},
+		},
+		// 1003 joins.
+		&clusterSnapshot{
+			brokers: BrokerSet{1001: broker(1001), 1002: broker(1002), 1003: broker(1003)},
+			topics:  TopicSet{"a": topic("a", 2)},
+		},
+		// b is created.
+		&clusterSnapshot{
+			brokers: BrokerSet{1001: broker(1001), 1002: broker(1002), 1003: broker(1003)},
+			topics:  TopicSet{"a": topic("a", 2), "b": topic("b", 1)},
+		},
+		// 1002 leaves, a is expanded.
+		&clusterSnapshot{
+			brokers: BrokerSet{1001: broker(1001), 1003: broker(1003)},
+			topics:  TopicSet{"a": topic("a", 4), "b": topic("b", 1)},
+		},
+		// b is deleted.
+		&clusterSnapshot{
+			brokers: BrokerSet{1001: broker(1001), 1003: broker(1003)},
+			topics:  TopicSet{"a": topic("a", 4)},
+		},
+	}
+
+	types := []pb.Event_Type{
+		pb.Event_BROKER_JOINED,
+		pb.Event_TOPIC_CREATED,
+		pb.Event_BROKER_LEFT,
+		pb.Event_TOPIC_RECONFIGURED,
+		pb.Event_TOPIC_DELETED,
+	}
+
+	objects := []string{"1003", "b", "1002", "a", "b"}
+
+	return snapshots, types, objects
+}
+
+// eventObject returns the broker ID or
+// topic name referenced by an event.
+func eventObject(e *pb.Event) string {
+	if e.Broker != nil {
+		return fmt.Sprintf("%d", e.Broker.Id)
+	}
+
+	return e.Topic.Name
+}
+
+func checkEvents(t *testing.T, events []*pb.Event, types []pb.Event_Type, objects []string) {
+	if len(events) != len(types) {
+		t.Fatalf("Expected %d events, got %d", len(types), len(events))
+	}
+
+	for i, e := range events {
+		if e.Sequence != uint64(i+1) {
+			t.Errorf("Expected sequence %d, got %d", i+1, e.Sequence)
+		}
+
+		if e.Type != types[i] {
+			t.Errorf("[event %d] Expected type %s, got %s", i+1, types[i], e.Type)
+		}
+
+		if o := eventObject(e); o != objects[i] {
+			t.Errorf("[event %d] Expected object %s, got %s", i+1, objects[i], o)
+		}
+	}
+}
+
+func TestEventWatcherPoll(t *testing.T) {
+	snapshots, types, objects := testSnapshots()
+
+	var n int
+	w := &eventWatcher{
+		snapshot: func() (*clusterSnapshot, error) {
+			s := snapshots[n]
+			n++
+			return s, nil
+		},
+	}
+
+	var events []*pb.Event
+	for range snapshots {
+		e, err := w.poll()
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		events = append(events, e...)
+	}
+
+	checkEvents(t, events, types, objects)
+}
+
+func TestEventFeed(t *testing.T) {
+	snapshots, types, objects := testSnapshots()
+
+	// Snapshots are released one at a time so that
+	// both subscribers are in place before any
+	// changes are observed.
+	var mu sync.Mutex
+	var n, polls int
+	release := make(chan struct{}, len(snapshots))
+
+	f := newEventFeed(func() (*clusterSnapshot, error) {
+		mu.Lock()
+		baseline := polls == 0
+		mu.Unlock()
+
+		if !baseline {
+			<-release
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		polls++
+		s := snapshots[n]
+		if n < len(snapshots)-1 {
+			n++
+		}
+
+		return s, nil
+	}, nil)
+
+	sub1, err := f.subscribe(time.Millisecond)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	sub2, err := f.subscribe(time.Millisecond)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	for range snapshots[1:] {
+		release <- struct{}{}
+	}
+
+	// Both subscribers receive all events.
+	for i, sub := range []chan *pb.Event{sub1, sub2} {
+		var events []*pb.Event
+		for range types {
+			select {
+			case e := <-sub:
+				events = append(events, e)
+			case <-time.After(time.Second):
+				t.Fatalf("[subscriber %d] Timed out waiting for events", i+1)
+			}
+		}
+
+		checkEvents(t, events, types, objects)
+	}
+
+	f.unsubscribe(sub1)
+	f.unsubscribe(sub2)
+
+	// A single baseline and poll per snapshot
+	// are shared by both subscribers.
+	mu.Lock()
+	if polls != len(snapshots) {
+		t.Errorf("Expected %d polls, got %d", len(snapshots), polls)
+	}
+	mu.Unlock()
+
+	// Unblock any pending poll.
+	close(release)
+
+	// The poll loop stops without subscribers.
+	f.Lock()
+	if f.stop != nil {
+		t.Error("Expected the poll loop to be stopped")
+	}
+	f.Unlock()
+}
+
+func TestEventFeedSlowSubscriber(t *testing.T) {
+	size := eventBufferSize
+	eventBufferSize = 1
+	defer func() { eventBufferSize = size }()
+
+	f := newEventFeed(nil, nil)
+	f.stop = func() {}
+
+	sub := make(chan *pb.Event, eventBufferSize)
+	f.subs[sub] = struct{}{}
+
+	// Two events exceed the buffer.
+	f.publish(context.Background(), []*pb.Event{{Sequence: 1}, {Sequence: 2}})
+
+	if e := <-sub; e.Sequence != 1 {
+		t.Errorf("Expected sequence 1, got %d", e.Sequence)
+	}
+
+	if _, ok := <-sub; ok {
+		t.Error("Expected the subscriber channel to be closed")
+	}
+
+	if len(f.subs) != 0 || f.stop != nil {
+		t.Error("Expected the subscriber removed and the poll loop stopped")
+	}
+}
+
+// testWatchEventsStream is a pb.Registry_WatchEventsServer.
+type testWatchEventsStream struct {
+	grpc.ServerStream
+	ctx    context.Context
+	events []*pb.Event
+}
+
+func (s *testWatchEventsStream) Context() context.Context { return s.ctx }
+
+func (s *testWatchEventsStream) Send(e *pb.Event) error {
+	s.events = append(s.events, e)
+	return nil
+}
+
+func TestWatchEvents(t *testing.T) {
+	s := testServer()
+
+	interval := eventPollInterval
+	eventPollInterval = time.Millisecond
+	defer func() { eventPollInterval = interval }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	stream := &testWatchEventsStream{ctx: ctx}
+
+	if err := s.WatchEvents(&pb.Empty{}, stream); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	// Mock cluster state doesn't change.
+	if len(stream.events) != 0 {
+		t.Errorf("Expected no events, got %d", len(stream.events))
+	}
+}
+
+func TestBrokerWatcherRun(t *testing.T) {
+	broker := func(id uint32) *pb.Broker { return &pb.Broker{Id: id} }
+
+	observations := []BrokerSet{
+		// Initial membership.
+		BrokerSet{1001: broker(1001), 1002: broker(1002)},
+		// 1003 joins.
+		BrokerSet{1001: broker(1001), 1002: broker(1002), 1003: broker(1003)},
+		// Unchanged; the watch fired for a non-matching broker.
+		BrokerSet{1001: broker(1001), 1002: broker(1002), 1003: broker(1003)},
+		// 1002 leaves.
+		BrokerSet{1001: broker(1001), 1003: broker(1003)},
+	}
+
+	// A snapshot of the full membership
+	// is followed by add/remove deltas.
+	type delta struct{ ids, added, removed []uint32 }
+	expected := []delta{
+		{ids: []uint32{1001, 1002}},
+		{added: []uint32{1003}},
+		{removed: []uint32{1002}},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// Each watch fires immediately until
+	// all observations are exhausted.
+	var n int
+	var watchCtx context.Context
+	w := &brokerWatcher{
+		fetch: func() (BrokerSet, error) {
+			b := observations[n]
+			n++
+			return b, nil
+		},
+		watch: func(ctx context.Context) (<-chan struct{}, error) {
+			watchCtx = ctx
+			c := make(chan struct{})
+			if n < len(observations)-1 {
+				close(c)
+			} else {
+				cancel()
+			}
+			return c, nil
+		},
+	}
+
+	var resps []*pb.BrokerResponse
+	send := func(r *pb.BrokerResponse) error {
+		resps = append(resps, r)
+		return nil
+	}
+
+	if err := w.run(ctx, time.Millisecond, send); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if len(resps) != len(expected) {
+		t.Fatalf("Expected %d responses, got %d", len(expected), len(resps))
+	}
+
+	for i, r := range resps {
+		e := expected[i]
+		if !intsEqual(r.Ids, e.ids) || !intsEqual(r.Added, e.added) || !intsEqual(r.Removed, e.removed) {
+			t.Errorf("[response %d] Expected ids/added/removed %v/%v/%v, got %v/%v/%v",
+				i, e.ids, e.added, e.removed, r.Ids, r.Added, r.Removed)
+		}
+
+		// Snapshots hold all brokers, deltas the added brokers.
+		if c := len(e.ids) + len(e.added); len(r.Brokers) != c {
+			t.Errorf("[response %d] Expected %d brokers, got %d", i, c, len(r.Brokers))
+		}
+	}
+
+	// Watches are abandoned once the stream ends.
+	if watchCtx.Err() == nil {
+		t.Error("Expected the watch context to be done")
+	}
+}
+
+func TestBrokerWatcherRunError(t *testing.T) {
+	w := &brokerWatcher{
+		fetch: func() (BrokerSet, error) { return nil, fmt.Errorf("fetch error") },
+		watch: func(context.Context) (<-chan struct{}, error) { return make(chan struct{}), nil },
+	}
+
+	send := func(r *pb.BrokerResponse) error { return nil }
+
+	// Errors on the initial fetch are returned.
+	if err := w.run(context.Background(), time.Millisecond, send); err == nil {
+		t.Error("Expected non-nil error")
+	}
+}
+
+// testWatchBrokersStream is a pb.Registry_WatchBrokersServer.
+type testWatchBrokersStream struct {
+	grpc.ServerStream
+	ctx   context.Context
+	resps []*pb.BrokerResponse
+}
+
+func (s *testWatchBrokersStream) Context() context.Context { return s.ctx }
+
+func (s *testWatchBrokersStream) Send(r *pb.BrokerResponse) error {
+	s.resps = append(s.resps, r)
+	return nil
+}
+
+func TestWatchBrokers(t *testing.T) {
+	s := testServer()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	stream := &testWatchBrokersStream{ctx: ctx}
+	req := &pb.BrokerRequest{Tag: []string{"rack:a"}}
+
+	if err := s.WatchBrokers(req, stream); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	// Mock broker membership doesn't
+	// change; only the initial membership
+	// is expected.
+	if len(stream.resps) != 1 {
+		t.Fatalf("Expected 1 response, got %d", len(stream.resps))
+	}
+
+	expected := []uint32{1001, 1004}
+	if ids := stream.resps[0].Ids; !intsEqual(ids, expected) {
+		t.Errorf("Expected ids %v, got %v", expected, ids)
+	}
+}
+
+// testMembershipZK is a kafkazk.Handler
+// with a configurable set of registered
+// brokers drawn from the kafkazk.Mock.
+type testMembershipZK struct {
+	kafkazk.Mock
+	ids []int
+}
+
+func (zk *testMembershipZK) GetAllBrokerMeta(withMetrics bool) (kafkazk.BrokerMetaMap, []error) {
+	all, _ := zk.Mock.GetAllBrokerMeta(withMetrics)
+
+	bm := kafkazk.BrokerMetaMap{}
+	for _, id := range zk.ids {
+		bm[id] = all[id]
+	}
+
+	return bm, nil
+}
+
+func TestWatchBrokersReplay(t *testing.T) {
+	s := testServer()
+	zk := &testMembershipZK{ids: []int{1001, 1002, 1003, 1004}}
+	s.ZK = zk
+
+	watch := func(req *pb.BrokerRequest) []*pb.BrokerResponse {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+
+		stream := &testWatchBrokersStream{ctx: ctx}
+		if err := s.WatchBrokers(req, stream); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		return stream.resps
+	}
+
+	all := func() (BrokerSet, error) { return s.fetchBrokerSet(&pb.BrokerRequest{}, false) }
+
+	// Changes are recorded without any subscribers;
+	// the first observation establishes a baseline.
+	s.brokerHistory.observe(all)
+
+	// 1004 leaves and 1005 joins.
+	zk.ids = []int{1001, 1002, 1003, 1005}
+	s.brokerHistory.observe(all)
+
+	// A late subscriber sees the change.
+	resps := watch(&pb.BrokerRequest{})
+	if len(resps) != 2 {
+		t.Fatalf("Expected 2 responses, got %d", len(resps))
+	}
+
+	snapshot, replayed := resps[0], resps[1]
+
+	if expected := []uint32{1001, 1002, 1003, 1005}; !intsEqual(snapshot.Ids, expected) || snapshot.Replayed {
+		t.Errorf("Expected snapshot ids %v, got %v (replayed: %v)", expected, snapshot.Ids, snapshot.Replayed)
+	}
+
+	if !replayed.Replayed || !intsEqual(replayed.Added, []uint32{1005}) || !intsEqual(replayed.Removed, []uint32{1004}) {
+		t.Errorf("Expected replayed delta added [1005] removed [1004], got %v", replayed)
+	}
+
+	if _, ok := replayed.Brokers[1005]; !ok || len(replayed.Brokers) != 1 {
+		t.Errorf("Expected replayed brokers [1005], got %v", replayed.Brokers)
+	}
+
+	// Later subscribers see the same change,
+	// filtered by the request tag selectors.
+	resps = watch(&pb.BrokerRequest{Tag: []string{"rack:a"}})
+	if len(resps) != 2 {
+		t.Fatalf("Expected 2 responses, got %d", len(resps))
+	}
+
+	if r := resps[1]; len(r.Added) != 0 || !intsEqual(r.Removed, []uint32{1004}) || len(r.Brokers) != 0 {
+		t.Errorf("Expected replayed delta removed [1004], got %v", r)
+	}
+
+	// Changes are not replayed for broker states.
+	if resps := watch(&pb.BrokerRequest{State: pb.BrokerRequest_MISSING}); len(resps) != 1 {
+		t.Errorf("Expected 1 response, got %d", len(resps))
+	}
+}
+
+func TestBrokerHistory(t *testing.T) {
+	h := newBrokerHistory(2)
+
+	broker := func(id uint32) *pb.Broker { return &pb.Broker{Id: id} }
+	observe := func(ids ...uint32) {
+		bs := BrokerSet{}
+		for _, id := range ids {
+			bs[id] = broker(id)
+		}
+		h.observe(func() (BrokerSet, error) { return bs, nil })
+	}
+
+	// Baseline, then three changes and
+	// an unchanged observation.
+	observe(1001)
+	observe(1001, 1002)
+	observe(1002)
+	observe(1002)
+	observe(1002, 1003)
+
+	changes := h.recent()
+	if len(changes) != 2 {
+		t.Fatalf("Expected 2 changes, got %d", len(changes))
+	}
+
+	// The oldest change is discarded.
+	if !intsEqual(changes[0].removed.IDs(), []uint32{1001}) || len(changes[0].added) != 0 {
+		t.Errorf("Expected 1001 removed, got %v", changes[0])
+	}
+
+	if !intsEqual(changes[1].added.IDs(), []uint32{1003}) || len(changes[1].removed) != 0 {
+		t.Errorf("Expected 1003 added, got %v", changes[1])
+	}
+}
+
+func TestBrokerHistoryRun(t *testing.T) {
+	h := newBrokerHistory(brokerReplaySize)
+
+	broker := func(id uint32) *pb.Broker { return &pb.Broker{Id: id} }
+	observations := []BrokerSet{
+		BrokerSet{1001: broker(1001)},
+		BrokerSet{1001: broker(1001), 1002: broker(1002)},
+		BrokerSet{1002: broker(1002)},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// Each watch fires immediately until
+	// all observations are exhausted.
+	var n int
+	fetch := func() (BrokerSet, error) {
+		b := observations[n]
+		n++
+		return b, nil
+	}
+
+	watch := func(context.Context) (<-chan struct{}, error) {
+		c := make(chan struct{})
+		if n < len(observations)-1 {
+			close(c)
+		} else {
+			cancel()
+		}
+		return c, nil
+	}
+
+	h.run(ctx, time.Millisecond, watch, fetch, nil)
+
+	changes := h.recent()
+	if len(changes) != 2 {
+		t.Fatalf("Expected 2 changes, got %d", len(changes))
+	}
+
+	if !intsEqual(changes[0].added.IDs(), []uint32{1002}) || len(changes[0].removed) != 0 {
+		t.Errorf("Expected 1002 added, got %v", changes[0])
+	}
+
+	if !intsEqual(changes[1].removed.IDs(), []uint32{1001}) || len(changes[1].added) != 0 {
+		t.Errorf("Expected 1001 removed, got %v", changes[1])
+	}
+}
+
+func TestBrokerHistoryForget(t *testing.T) {
+	h := newBrokerHistory(brokerReplaySize)
+
+	broker := func(id uint32) *pb.Broker { return &pb.Broker{Id: id} }
+	for _, bs := range []BrokerSet{
+		BrokerSet{1001: broker(1001)},
+		BrokerSet{1001: broker(1001), 1002: broker(1002)},
+		BrokerSet{1002: broker(1002), 1003: broker(1003)},
+	} {
+		h.observe(func() (BrokerSet, error) { return bs, nil })
+	}
+
+	// 1001 is dropped from the second change.
+	h.forget(1001)
+
+	changes := h.recent()
+	if len(changes) != 2 {
+		t.Fatalf("Expected 2 changes, got %d", len(changes))
+	}
+
+	if !intsEqual(changes[1].added.IDs(), []uint32{1003}) || len(changes[1].removed) != 0 {
+		t.Errorf("Expected change added [1003] removed [], got added %v removed %v",
+			changes[1].added.IDs(), changes[1].removed.IDs())
+	}
+
+	// Changes left empty are dropped.
+	h.forget(1002)
+
+	changes = h.recent()
+	if len(changes) != 1 || !intsEqual(changes[0].added.IDs(), []uint32{1003}) {
+		t.Errorf("Expected a single change adding [1003], got %v", changes)
+	}
+
+	// The baseline is kept.
+	if _, ok := h.last[1002]; !ok {
+		t.Error("Expected 1002 to remain in the baseline")
+	}
+}
+
+func TestBrokerWatcherPause(t *testing.T) {
+	broker := func(id uint32) *pb.Broker { return &pb.Broker{Id: id} }
+
+	var mu sync.Mutex
+	current := BrokerSet{1001: broker(1001)}
+	set := func(ids ...uint32) {
+		mu.Lock()
+		defer mu.Unlock()
+		current = BrokerSet{}
+		for _, id := range ids {
+			current[id] = broker(id)
+		}
+	}
+
+	watches := make(chan chan struct{}, 1)
+	control := make(chan bool)
+	resps := make(chan *pb.BrokerResponse, 10)
+
+	w := &brokerWatcher{
+		fetch: func() (BrokerSet, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			return current, nil
+		},
+		watch: func(context.Context) (<-chan struct{}, error) {
+			c := make(chan struct{})
+			watches <- c
+			return c, nil
+		},
+		control: control,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	done := make(chan error)
+	go func() {
+		done <- w.run(ctx, time.Millisecond, func(r *pb.BrokerResponse) error {
+			resps <- r
+			return nil
+		})
+	}()
+
+	watch := <-watches
+	if r := <-resps; !intsEqual(r.Ids, []uint32{1001}) {
+		t.Fatalf("Expected snapshot ids [1001], got %v", r.Ids)
+	}
+
+	control <- true
+
+	// Changes while paused aren't sent.
+	set(1001, 1002)
+	close(watch)
+	watch = <-watches
+
+	set(1002, 1003)
+	close(watch)
+	watch = <-watches
+
+	// Resuming sends a single delta coalescing all
+	// changes since the last response. The control
+	// is only received once the prior update is done.
+	control <- false
+
+	r := <-resps
+	if !intsEqual(r.Added, []uint32{1002, 1003}) || !intsEqual(r.Removed, []uint32{1001}) {
+		t.Errorf("Expected added [1002 1003] removed [1001], got added %v removed %v", r.Added, r.Removed)
+	}
+
+	// The pending watch is kept across a resume.
+	set(1003)
+	close(watch)
+	<-watches
+
+	r = <-resps
+	if len(r.Added) != 0 || !intsEqual(r.Removed, []uint32{1002}) {
+		t.Errorf("Expected removed [1002], got added %v removed %v", r.Added, r.Removed)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("Unexpected error: %s", err)
+	}
+
+	if len(resps) != 0 {
+		t.Errorf("Expected no further responses, got %d", len(resps))
+	}
+}
+
+// testWatchBrokersControlStream is a
+// pb.Registry_WatchBrokersControlServer.
+type testWatchBrokersControlStream struct {
+	grpc.ServerStream
+	ctx   context.Context
+	reqs  chan *pb.WatchBrokersRequest
+	resps chan *pb.BrokerResponse
+}
+
+func (s *testWatchBrokersControlStream) Context() context.Context { return s.ctx }
+
+func (s *testWatchBrokersControlStream) Send(r *pb.BrokerResponse) error {
+	s.resps <- r
+	return nil
+}
+
+func (s *testWatchBrokersControlStream) Recv() (*pb.WatchBrokersRequest, error) {
+	select {
+	case r, ok := <-s.reqs:
+		if !ok {
+			return nil, io.EOF
+		}
+		return r, nil
+	case <-s.ctx.Done():
+		return nil, s.ctx.Err()
+	}
+}
+
+func TestWatchBrokersControl(t *testing.T) {
+	s := testServer()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	stream := &testWatchBrokersControlStream{
+		ctx:   ctx,
+		reqs:  make(chan *pb.WatchBrokersRequest, 2),
+		resps: make(chan *pb.BrokerResponse, 10),
+	}
+
+	stream.reqs <- &pb.WatchBrokersRequest{Request: &pb.BrokerRequest{Tag: []string{"rack:a"}}}
+	stream.reqs <- &pb.WatchBrokersRequest{Control: pb.WatchBrokersRequest_PAUSE}
+	// Closing the client side doesn't end the stream.
+	close(stream.reqs)
+
+	if err := s.WatchBrokersControl(stream); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if len(stream.resps) != 1 {
+		t.Fatalf("Expected 1 response, got %d", len(stream.resps))
+	}
+
+	expected := []uint32{1001, 1004}
+	if ids := (<-stream.resps).Ids; !intsEqual(ids, expected) {
+		t.Errorf("Expected ids %v, got %v", expected, ids)
+	}
+
+	// Streams closed ahead of the first request fail.
+	stream = &testWatchBrokersControlStream{
+		ctx:   context.Background(),
+		reqs:  make(chan *pb.WatchBrokersRequest),
+		resps: make(chan *pb.BrokerResponse, 10),
+	}
+
+	close(stream.reqs)
+
+	if err := s.WatchBrokersControl(stream); err != io.EOF {
+		t.Errorf("Expected io.EOF, got %v", err)
+	}
+}
@@ -6,9 +6,14 @@ import (
 	"fmt"
 	"regexp"
 	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/DataDog/kafka-kit/kafkazk"
 	pb "github.com/DataDog/kafka-kit/registry/protos"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 var (
@@ -18,8 +23,20 @@ var (
 	ErrTopicNotExist = errors.New("topic does not exist")
 	// ErrTopicNameEmpty error.
 	ErrTopicNameEmpty = errors.New("topic Name field must be specified")
+	// ErrTopicAlreadyExists error.
+	ErrTopicAlreadyExists = status.Error(codes.AlreadyExists, "topic already exists")
+	// ErrInvalidTopicParams error.
+	ErrInvalidTopicParams = errors.New("topic Partitions and Replication fields must be greater than 0")
+	// ErrTopicSelectorEmpty error.
+	ErrTopicSelectorEmpty = errors.New("topic Name or Tag field must be specified")
+	// ErrTopicNotFound error.
+	ErrTopicNotFound = status.Error(codes.NotFound, ErrTopicNotExist.Error())
 	// Misc.
 	tregex = regexp.MustCompile(".*")
+
+	// tagNonexistentTopicMsg is the TagResponse message for tag
+	// requests targeting a topic that doesn't exist in ZooKeeper.
+	tagNonexistentTopicMsg = "success (topic does not exist)"
 )
 
 // TopicSet is a mapping of topic name to *pb.Topic.
@@ -67,6 +84,295 @@ func (s *Server) ListTopics(ctx context.Context, req *pb.TopicRequest) (*pb.Topi
 	return resp, nil
 }
 
+// GetTopicConfigs gets the dynamic config overrides for topics, matched as
+// in ListTopics. Topics with no overrides are returned with an empty config.
+func (s *Server) GetTopicConfigs(ctx context.Context, req *pb.TopicRequest) (*pb.TopicConfigResponse, error) {
+	if err := s.ValidateRequest(ctx, req, readRequest); err != nil {
+		return nil, err
+	}
+
+	// Get topics.
+	topics, err := s.fetchTopicSet(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &pb.TopicConfigResponse{Configs: map[string]*pb.TopicConfig{}}
+
+	// Get the config for each topic.
+	for _, name := range topics.Names() {
+		config := map[string]string{}
+
+		tc, err := s.ZK.GetTopicConfig(name)
+		if err != nil {
+			switch err.(type) {
+			// The topic has no config node.
+			case kafkazk.ErrNoNode:
+			default:
+				return nil, err
+			}
+		}
+
+		if tc != nil {
+			for k, v := range tc.Config {
+				config[k] = v
+			}
+		}
+
+		resp.Configs[name] = &pb.TopicConfig{Config: config}
+	}
+
+	return resp, nil
+}
+
+// TopicExists returns whether the topic specified in the TopicRequest.Name
+// field exists. If the topic exists, the response Topic field is populated
+// with the partition count and replication factor. This requires a single
+// ZooKeeper read and does not fetch registry tags.
+func (s *Server) TopicExists(ctx context.Context, req *pb.TopicRequest) (*pb.TopicExistsResponse, error) {
+	if err := s.ValidateRequest(ctx, req, readRequest); err != nil {
+		return nil, err
+	}
+
+	if req.Name == "" {
+		return nil, ErrTopicNameEmpty
+	}
+
+	ts, err := s.ZK.GetTopicState(req.Name)
+	if err != nil {
+		switch err.(type) {
+		case kafkazk.ErrNoNode:
+			return &pb.TopicExistsResponse{Exists: false}, nil
+		default:
+			return nil, err
+		}
+	}
+
+	resp := &pb.TopicExistsResponse{
+		Exists: true,
+		Topic: &pb.Topic{
+			Name:        req.Name,
+			Partitions:  uint32(len(ts.Partitions)),
+			Replication: uint32(len(ts.Partitions["0"])),
+		},
+	}
+
+	return resp, nil
+}
+
+// CreateTopic creates a topic. If the *pb.CreateTopicRequest Assignment field
+// is non-nil, the replica assignment is validated and used verbatim. Otherwise,
+// partitions are placed among all registered brokers according to the requested
+// partition count and replication factor.
+func (s *Server) CreateTopic(ctx context.Context, req *pb.CreateTopicRequest) (*pb.Empty, error) {
+	if err := s.ValidateRequest(ctx, req, writeRequest); err != nil {
+		return nil, err
+	}
+
+	if req.Topic == nil || req.Topic.Name == "" {
+		return nil, ErrTopicNameEmpty
+	}
+
+	// Get broker metadata.
+	bm, errs := s.ZK.GetAllBrokerMeta(false)
+	if errs != nil {
+		return nil, ErrFetchingBrokers
+	}
+
+	var pm *kafkazk.PartitionMap
+	var err error
+
+	// Use the explicit assignment if provided,
+	// otherwise place partitions automatically.
+	if len(req.Assignment) > 0 {
+		pm, err = assignmentToPartitionMap(req.Topic, req.Assignment, bm)
+	} else {
+		pm, err = placeTopic(req.Topic, bm)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.ZK.CreateTopic(req.Topic.Name, pm); err != nil {
+		switch err {
+		case kafkazk.ErrTopicExists:
+			return nil, ErrTopicAlreadyExists
+		default:
+			return nil, err
+		}
+	}
+
+	return &pb.Empty{}, nil
+}
+
+// assignmentToPartitionMap takes a *pb.Topic, an explicit replica assignment
+// and a kafkazk.BrokerMetaMap. The assignment is validated and returned as a
+// *kafkazk.PartitionMap. A valid assignment references every partition exactly
+// once, has replica sets of equal length composed of distinct and registered
+// brokers, and matches the topic Partitions and Replication fields if specified.
+func assignmentToPartitionMap(t *pb.Topic, a []*pb.PartitionAssignment, bm kafkazk.BrokerMetaMap) (*kafkazk.PartitionMap, error) {
+	if t.Partitions != 0 && int(t.Partitions) != len(a) {
+		return nil, fmt.Errorf("invalid assignment: %d partitions specified, expected %d", len(a), t.Partitions)
+	}
+
+	replication := int(t.Replication)
+	if replication == 0 {
+		replication = len(a[0].Replicas)
+	}
+
+	pm := kafkazk.NewPartitionMap()
+	seen := map[uint32]struct{}{}
+
+	for _, p := range a {
+		switch _, dupe := seen[p.Partition]; {
+		case int(p.Partition) >= len(a):
+			return nil, fmt.Errorf("invalid assignment: partition %d out of range", p.Partition)
+		case dupe:
+			return nil, fmt.Errorf("invalid assignment: partition %d specified more than once", p.Partition)
+		case len(p.Replicas) == 0 || len(p.Replicas) != replication:
+			return nil, fmt.Errorf("invalid assignment: partition %d has %d replicas, expected %d", p.Partition, len(p.Replicas), replication)
+		}
+
+		seen[p.Partition] = struct{}{}
+
+		partn := kafkazk.Partition{Topic: t.Name, Partition: int(p.Partition)}
+		ids := map[uint32]struct{}{}
+
+		for _, id := range p.Replicas {
+			if _, dupe := ids[id]; dupe {
+				return nil, fmt.Errorf("invalid assignment: partition %d has duplicate broker %d", p.Partition, id)
+			}
+
+			if _, exists := bm[int(id)]; !exists {
+				return nil, fmt.Errorf("invalid assignment: partition %d broker %d does not exist", p.Partition, id)
+			}
+
+			ids[id] = struct{}{}
+			partn.Replicas = append(partn.Replicas, int(id))
+		}
+
+		pm.Partitions = append(pm.Partitions, partn)
+	}
+
+	sort.Sort(pm.Partitions)
+
+	return pm, nil
+}
+
+// placeTopic takes a *pb.Topic and a kafkazk.BrokerMetaMap and returns
+// a *kafkazk.PartitionMap with the topic partitions placed among all
+// brokers in the BrokerMetaMap.
+func placeTopic(t *pb.Topic, bm kafkazk.BrokerMetaMap) (*kafkazk.PartitionMap, error) {
+	if t.Partitions == 0 || t.Replication == 0 {
+		return nil, ErrInvalidTopicParams
+	}
+
+	if int(t.Replication) > len(bm) {
+		return nil, invalidArgument(fieldViolation{
+			"topic.replication",
+			fmt.Sprintf("replication factor %d exceeds the %d registered brokers", t.Replication, len(bm)),
+		})
+	}
+
+	// Populate a map of stub brokers
+	// to be replaced in the rebuild.
+	pm := kafkazk.NewTopicPartitionMap(t.Name, int(t.Partitions), int(t.Replication))
+
+	var ids []int
+	for id := range bm {
+		ids = append(ids, id)
+	}
+
+	sort.Ints(ids)
+
+	brokers := kafkazk.BrokerMapFromPartitionMap(kafkazk.NewPartitionMap(), bm, false)
+	brokers.Update(ids, bm)
+
+	out, errs := pm.Rebuild(kafkazk.RebuildParams{
+		BM:       brokers,
+		Strategy: "count",
+	})
+
+	if errs != nil {
+		return nil, errs[0]
+	}
+
+	return out, nil
+}
+
+// DeleteTopic marks topics for deletion. Topics are matched as in ListTopics,
+// although a Name or Tag must be specified in the *pb.TopicRequest and the Name
+// is matched exactly rather than as a regex. The response Names field is
+// populated with the topics deleted. If the request DryRun field is true, the
+// matched topics are returned without being deleted. ErrTopicNotFound is
+// returned if no topics match. Topics that fail to be deleted are returned in
+// the response Errors field; if no topics were deleted, an error is returned.
+func (s *Server) DeleteTopic(ctx context.Context, req *pb.TopicRequest) (*pb.TopicResponse, error) {
+	reqType := writeRequest
+	if req.DryRun {
+		reqType = readRequest
+	}
+
+	if err := s.ValidateRequest(ctx, req, reqType); err != nil {
+		return nil, err
+	}
+
+	if req.Name == "" && len(req.Tag) == 0 {
+		return nil, ErrTopicSelectorEmpty
+	}
+
+	// Match the name literally.
+	sel := &pb.TopicRequest{Name: regexp.QuoteMeta(req.Name), Tag: req.Tag}
+
+	topics, err := s.fetchTopicSet(sel)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(topics) == 0 {
+		return nil, ErrTopicNotFound
+	}
+
+	names := topics.Names()
+
+	if req.DryRun {
+		return &pb.TopicResponse{Names: names}, nil
+	}
+
+	resp := &pb.TopicResponse{}
+	failed := map[string]string{}
+	var notExist int
+
+	for _, n := range names {
+		if err := s.ZK.DeleteTopic(n); err != nil {
+			if err == kafkazk.ErrTopicNotExist {
+				notExist++
+			}
+			failed[n] = err.Error()
+			continue
+		}
+
+		resp.Names = append(resp.Names, n)
+	}
+
+	switch {
+	case len(failed) == 0:
+	case len(resp.Names) > 0:
+		resp.Errors = failed
+	case notExist == len(failed):
+		return nil, ErrTopicNotFound
+	default:
+		var errs []string
+		for _, n := range names {
+			errs = append(errs, fmt.Sprintf("%s: %s", n, failed[n]))
+		}
+		return nil, status.Errorf(codes.Internal, "failed to delete topics: %s", strings.Join(errs, "; "))
+	}
+
+	return resp, nil
+}
+
 // TopicMappings returns all broker IDs that hold at least one partition for
 // the requested topic. The topic is specified in the TopicRequest.Name
 // field.
@@ -111,7 +417,9 @@ func (s *Server) TopicMappings(ctx context.Context, req *pb.TopicRequest) (*pb.B
 }
 
 // TagTopic sets custom tags for the specified topic. Any previously existing
-// tags that were not specified in the request remain unmodified.
+// tags that were not specified in the request remain unmodified. Tags may be
+// set for topics that don't exist in ZooKeeper, allowing tags to be
+// provisioned ahead of a topic; the response message indicates this case.
 func (s *Server) TagTopic(ctx context.Context, req *pb.TopicRequest) (*pb.TagResponse, error) {
 	if err := s.ValidateRequest(ctx, req, writeRequest); err != nil {
 		return nil, err
@@ -131,19 +439,10 @@ func (s *Server) TagTopic(ctx context.Context, req *pb.TopicRequest) (*pb.TagRes
 		return nil, err
 	}
 
-	// Ensure the topic exists.
-
-	// Get topics from ZK.
-	r := regexp.MustCompile(fmt.Sprintf("^%s$", req.Name))
-	tr := []*regexp.Regexp{r}
-
-	topics, errs := s.ZK.GetTopics(tr)
-	if errs != nil {
-		return nil, ErrFetchingTopics
-	}
-
-	if len(topics) == 0 {
-		return nil, ErrTopicNotExist
+	// Check if the topic exists.
+	exists, err := s.topicExists(req.Name)
+	if err != nil {
+		return nil, err
 	}
 
 	// Set the tags.
@@ -152,10 +451,15 @@ func (s *Server) TagTopic(ctx context.Context, req *pb.TopicRequest) (*pb.TagRes
 		return nil, err
 	}
 
+	if !exists {
+		return &pb.TagResponse{Message: tagNonexistentTopicMsg}, nil
+	}
+
 	return &pb.TagResponse{Message: "success"}, nil
 }
 
-// DeleteTopicTag deletes custom tags for the specified topic.
+// DeleteTopicTags deletes custom tags for the specified topic. Tags
+// provisioned for a topic that doesn't exist may be deleted.
 func (s *Server) DeleteTopicTags(ctx context.Context, req *pb.TopicRequest) (*pb.TagResponse, error) {
 	if err := s.ValidateRequest(ctx, req, writeRequest); err != nil {
 		return nil, err
@@ -169,37 +473,52 @@ func (s *Server) DeleteTopicTags(ctx context.Context, req *pb.TopicRequest) (*pb
 		return nil, ErrNilTags
 	}
 
-	// Ensure the topic exists.
-
-	// Get topics from ZK.
-	r := regexp.MustCompile(fmt.Sprintf("^%s$", req.Name))
-	tr := []*regexp.Regexp{r}
-
-	topics, errs := s.ZK.GetTopics(tr)
-	if errs != nil {
-		return nil, ErrFetchingTopics
+	// Check if the topic exists.
+	exists, err := s.topicExists(req.Name)
+	if err != nil {
+		return nil, err
 	}
 
-	if len(topics) == 0 {
+	// Delete the tags.
+	err = s.Tags.Store.DeleteTags(KafkaObject{Type: "topic", ID: req.Name}, req.Tag)
+	switch {
+	// Nonexistent topics are only valid
+	// targets if tags were provisioned.
+	case err == ErrKafkaObjectDoesNotExist && !exists:
 		return nil, ErrTopicNotExist
+	case err != nil:
+		return nil, err
 	}
 
-	// Delete the tags.
-	err := s.Tags.Store.DeleteTags(KafkaObject{Type: "topic", ID: req.Name}, req.Tag)
-	if err != nil {
-		return nil, err
+	if !exists {
+		return &pb.TagResponse{Message: tagNonexistentTopicMsg}, nil
 	}
 
 	return &pb.TagResponse{Message: "success"}, nil
 }
 
+// topicExists returns whether the named topic exists in ZooKeeper.
+func (s *Server) topicExists(name string) (bool, error) {
+	r := regexp.MustCompile(fmt.Sprintf("^%s$", regexp.QuoteMeta(name)))
+
+	topics, errs := s.ZK.GetTopics([]*regexp.Regexp{r})
+	if errs != nil {
+		return false, ErrFetchingTopics
+	}
+
+	return len(topics) > 0, nil
+}
+
 // fetchBrokerSet fetches metadata for all topics.
 func (s *Server) fetchTopicSet(req *pb.TopicRequest) (TopicSet, error) {
 	topicRegex := []*regexp.Regexp{}
 
 	// Check if a specific topic is being fetched.
 	if req.Name != "" {
-		r := regexp.MustCompile(fmt.Sprintf("^%s$", req.Name))
+		r, err := regexp.Compile(fmt.Sprintf("^%s$", req.Name))
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid topic name regex: %s", err)
+		}
 		topicRegex = append(topicRegex, r)
 	} else {
 		topicRegex = append(topicRegex, tregex)
@@ -223,6 +542,10 @@ func (s *Server) fetchTopicSet(req *pb.TopicRequest) (TopicSet, error) {
 			// first partition len.
 			Replication: uint32(len(s.Partitions["0"])),
 		}
+
+		if req.IncludeAssignments {
+			matched[t].Assignments = topicAssignments(s)
+		}
 	}
 
 	filtered, err := s.Tags.FilterTopics(matched, req.Tag)
@@ -233,6 +556,28 @@ func (s *Server) fetchTopicSet(req *pb.TopicRequest) (TopicSet, error) {
 	return filtered, nil
 }
 
+// topicAssignments returns the partition to replica
+// assignments held in a *kafkazk.TopicState.
+func topicAssignments(ts *kafkazk.TopicState) map[uint32]*pb.Replicas {
+	assignments := map[uint32]*pb.Replicas{}
+
+	for p, replicas := range ts.Partitions {
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			continue
+		}
+
+		ids := make([]uint32, len(replicas))
+		for i, id := range replicas {
+			ids[i] = uint32(id)
+		}
+
+		assignments[uint32(n)] = &pb.Replicas{Ids: ids}
+	}
+
+	return assignments
+}
+
 // Names returns a []string of topic names from a TopicSet.
 func (t TopicSet) Names() []string {
 	var names = []string{}
@@ -2,9 +2,16 @@ package server
 
 import (
 	"context"
+	"errors"
+	"reflect"
+	"strings"
 	"testing"
 
+	"github.com/DataDog/kafka-kit/kafkazk"
 	pb "github.com/DataDog/kafka-kit/registry/protos"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 func TestGetTopics(t *testing.T) {
@@ -40,6 +47,45 @@ func TestGetTopics(t *testing.T) {
 	}
 }
 
+func TestGetTopicsAssignments(t *testing.T) {
+	s := testServer()
+
+	// Not included by default.
+	resp, err := s.GetTopics(context.Background(), &pb.TopicRequest{Name: "test_topic"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if a := resp.Topics["test_topic"].Assignments; a != nil {
+		t.Errorf("Expected nil assignments, got %v", a)
+	}
+
+	req := &pb.TopicRequest{Name: "test_topic", IncludeAssignments: true}
+	resp, err = s.GetTopics(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	expected := map[uint32][]uint32{
+		0: []uint32{1000, 1001},
+		1: []uint32{1002, 1003},
+		2: []uint32{1004, 1005},
+		3: []uint32{1006, 1007},
+		4: []uint32{1008, 1009},
+	}
+
+	assignments := resp.Topics["test_topic"].Assignments
+	if len(assignments) != len(expected) {
+		t.Fatalf("Expected %d partition assignments, got %d", len(expected), len(assignments))
+	}
+
+	for p, replicas := range expected {
+		if a, exists := assignments[p]; !exists || !intsEqual(a.Ids, replicas) {
+			t.Errorf("Expected p%d replicas %v, got %v", p, replicas, a.GetIds())
+		}
+	}
+}
+
 func TestListTopics(t *testing.T) {
 	s := testServer()
 
@@ -73,6 +119,88 @@ func TestListTopics(t *testing.T) {
 	}
 }
 
+// testConfigZK is a kafkazk.Mock that returns topic
+// configs from the configs map. Topics not present
+// in the map have no config node.
+type testConfigZK struct {
+	kafkazk.Mock
+	configs map[string]map[string]string
+}
+
+func (zk *testConfigZK) GetTopicConfig(t string) (*kafkazk.TopicConfig, error) {
+	c, exists := zk.configs[t]
+	if !exists {
+		return nil, kafkazk.ErrNoNode{}
+	}
+
+	return &kafkazk.TopicConfig{Version: 1, Config: c}, nil
+}
+
+func TestGetTopicConfigs(t *testing.T) {
+	s := testServer()
+
+	s.Tags.Store.SetTags(
+		KafkaObject{Type: "topic", ID: "test_topic"},
+		TagSet{"team": "a"},
+	)
+
+	tests := map[int]*pb.TopicRequest{
+		0: &pb.TopicRequest{},
+		1: &pb.TopicRequest{Name: "test_topic"},
+		2: &pb.TopicRequest{Tag: []string{"team:a"}},
+		3: &pb.TopicRequest{Name: "nonexistent"},
+	}
+
+	override := map[string]string{"retention.ms": "86400000", "cleanup.policy": "compact"}
+
+	expected := map[int]map[string]map[string]string{
+		0: {"test_topic": override, "test_topic2": {}},
+		1: {"test_topic": override},
+		2: {"test_topic": override},
+		3: {},
+	}
+
+	// A topic that has no overrides may either have an
+	// empty config node or no config node at all.
+	zks := []map[string]map[string]string{
+		{"test_topic": override, "test_topic2": {}},
+		{"test_topic": override},
+	}
+
+	for _, configs := range zks {
+		s.ZK = &testConfigZK{configs: configs}
+
+		for i, req := range tests {
+			resp, err := s.GetTopicConfigs(context.Background(), req)
+			if err != nil {
+				t.Errorf("[test %d] Unexpected error: %s", i, err)
+				continue
+			}
+
+			if len(resp.Configs) != len(expected[i]) {
+				t.Errorf("[test %d] Expected %d topic configs, got %d", i, len(expected[i]), len(resp.Configs))
+				continue
+			}
+
+			for name, config := range expected[i] {
+				c, exists := resp.Configs[name]
+				if !exists {
+					t.Errorf("[test %d] Expected config for topic %s", i, name)
+					continue
+				}
+
+				if c.Config == nil {
+					t.Errorf("[test %d] Expected a non-nil config for topic %s", i, name)
+				}
+
+				if !reflect.DeepEqual(c.Config, config) {
+					t.Errorf("[test %d] Expected config %v for topic %s, got %v", i, config, name, c.Config)
+				}
+			}
+		}
+	}
+}
+
 func TestCustomTagTopicFilter(t *testing.T) {
 	s := testServer()
 
@@ -135,14 +263,125 @@ func TestTagTopic(t *testing.T) {
 		1: ErrTopicNameEmpty,
 		2: ErrNilTags,
 		3: ErrNilTags,
-		4: ErrTopicNotExist,
+		// Nonexistent topics may be tagged.
+		4: nil,
+	}
+
+	expectedMsg := map[int]string{
+		0: "success",
+		4: tagNonexistentTopicMsg,
 	}
 
 	for i, req := range tests {
-		_, err := s.TagTopic(context.Background(), req)
+		resp, err := s.TagTopic(context.Background(), req)
 		if err != expected[i] {
 			t.Errorf("[test %d] Expected err '%v', got '%v'", i, expected[i], err)
 		}
+
+		if err == nil && resp.Message != expectedMsg[i] {
+			t.Errorf("[test %d] Expected message '%s', got '%s'", i, expectedMsg[i], resp.Message)
+		}
+	}
+}
+
+func TestTagTopicMerge(t *testing.T) {
+	s := testServer()
+
+	for _, name := range []string{"test_topic", "test_topic20"} {
+		o := KafkaObject{Type: "topic", ID: name}
+
+		reqs := []*pb.TopicRequest{
+			&pb.TopicRequest{Name: name, Tag: []string{"k:v", "k2:v2"}},
+			// Overwrites k2, adds k3 and leaves k unmodified.
+			&pb.TopicRequest{Name: name, Tag: []string{"k2:new", "k3:v3"}},
+		}
+
+		for _, req := range reqs {
+			if _, err := s.TagTopic(context.Background(), req); err != nil {
+				t.Fatalf("[topic %s] Unexpected error: %s", name, err)
+			}
+		}
+
+		expected := TagSet{"k": "v", "k2": "new", "k3": "v3"}
+		got, _ := s.Tags.Store.GetTags(o)
+		if !expected.Equal(got) {
+			t.Errorf("[topic %s] Expected TagSet %v, got %v", name, expected, got)
+		}
+
+		// Delete a tag.
+		req := &pb.TopicRequest{Name: name, Tag: []string{"k"}}
+		resp, err := s.DeleteTopicTags(context.Background(), req)
+		if err != nil {
+			t.Fatalf("[topic %s] Unexpected error: %s", name, err)
+		}
+
+		expectedMsg := "success"
+		if name == "test_topic20" {
+			expectedMsg = tagNonexistentTopicMsg
+		}
+
+		if resp.Message != expectedMsg {
+			t.Errorf("[topic %s] Expected message '%s', got '%s'", name, expectedMsg, resp.Message)
+		}
+
+		expected = TagSet{"k2": "new", "k3": "v3"}
+		got, _ = s.Tags.Store.GetTags(o)
+		if !expected.Equal(got) {
+			t.Errorf("[topic %s] Expected TagSet %v, got %v", name, expected, got)
+		}
+	}
+}
+
+func TestTagTopicSelectors(t *testing.T) {
+	s := testServer()
+
+	reqs := []*pb.TopicRequest{
+		&pb.TopicRequest{Name: "test_topic", Tag: []string{"team:a"}},
+		&pb.TopicRequest{Name: "test_topic2", Tag: []string{"team:b", "tier:1"}},
+		// Tags for nonexistent topics are never matched.
+		&pb.TopicRequest{Name: "test_topic20", Tag: []string{"team:a"}},
+	}
+
+	for _, req := range reqs {
+		if _, err := s.TagTopic(context.Background(), req); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+	}
+
+	tests := map[int]*pb.TopicRequest{
+		0: &pb.TopicRequest{Tag: []string{"team:a"}},
+		1: &pb.TopicRequest{Tag: []string{"team"}},
+		2: &pb.TopicRequest{Tag: []string{"team:b", "tier:1"}},
+		3: &pb.TopicRequest{Tag: []string{"team:a", "tier:1"}},
+		4: &pb.TopicRequest{Name: "test_topic2", Tag: []string{"team:a"}},
+	}
+
+	expected := map[int][]string{
+		0: []string{"test_topic"},
+		1: []string{"test_topic", "test_topic2"},
+		2: []string{"test_topic2"},
+		3: []string{},
+		4: []string{},
+	}
+
+	for i, req := range tests {
+		list, err := s.ListTopics(context.Background(), req)
+		if err != nil {
+			t.Fatalf("[test %d] Unexpected error: %s", i, err)
+		}
+
+		if !stringsEqual(expected[i], list.Names) {
+			t.Errorf("[test %d] Expected ListTopics names %s, got %s", i, expected[i], list.Names)
+		}
+
+		get, err := s.GetTopics(context.Background(), req)
+		if err != nil {
+			t.Fatalf("[test %d] Unexpected error: %s", i, err)
+		}
+
+		if names := TopicSet(get.Topics).Names(); !stringsEqual(expected[i], names) {
+			t.Errorf("[test %d] Expected GetTopics names %s, got %s", i, expected[i], names)
+		}
 	}
 }
 
@@ -244,3 +483,349 @@ func TestTopicMappings(t *testing.T) {
 		t.Errorf("Unexpected error: %s", err)
 	}
 }
+
+func TestCreateTopic(t *testing.T) {
+	s := testServer()
+
+	tests := map[int]*pb.CreateTopicRequest{
+		0: &pb.CreateTopicRequest{Topic: &pb.Topic{Name: "new_topic", Partitions: 6, Replication: 2}},
+		1: &pb.CreateTopicRequest{
+			Topic: &pb.Topic{Name: "new_topic"},
+			Assignment: []*pb.PartitionAssignment{
+				&pb.PartitionAssignment{Partition: 0, Replicas: []uint32{1001, 1002}},
+				&pb.PartitionAssignment{Partition: 1, Replicas: []uint32{1002, 1003}},
+			},
+		},
+		2: &pb.CreateTopicRequest{},
+		3: &pb.CreateTopicRequest{Topic: &pb.Topic{Partitions: 6, Replication: 2}},
+		4: &pb.CreateTopicRequest{Topic: &pb.Topic{Name: "new_topic", Replication: 2}},
+		5: &pb.CreateTopicRequest{Topic: &pb.Topic{Name: "test_topic", Partitions: 6, Replication: 2}},
+	}
+
+	expected := map[int]error{
+		0: nil,
+		1: nil,
+		2: ErrTopicNameEmpty,
+		3: ErrTopicNameEmpty,
+		4: ErrInvalidTopicParams,
+		5: ErrTopicAlreadyExists,
+	}
+
+	for i, req := range tests {
+		_, err := s.CreateTopic(context.Background(), req)
+		if err != expected[i] {
+			t.Errorf("[test %d] Expected err '%v', got '%v'", i, expected[i], err)
+		}
+	}
+
+	if status.Code(ErrTopicAlreadyExists) != codes.AlreadyExists {
+		t.Errorf("Expected code %s, got %s", codes.AlreadyExists, status.Code(ErrTopicAlreadyExists))
+	}
+}
+
+func TestAssignmentToPartitionMap(t *testing.T) {
+	s := testServer()
+	bm, _ := s.ZK.GetAllBrokerMeta(false)
+
+	topic := &pb.Topic{Name: "new_topic", Partitions: 2, Replication: 2}
+
+	// A valid assignment.
+	a := []*pb.PartitionAssignment{
+		&pb.PartitionAssignment{Partition: 1, Replicas: []uint32{1003, 1001}},
+		&pb.PartitionAssignment{Partition: 0, Replicas: []uint32{1001, 1002}},
+	}
+
+	pm, err := assignmentToPartitionMap(topic, a, bm)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	expected := map[int][]int{
+		0: []int{1001, 1002},
+		1: []int{1003, 1001},
+	}
+
+	for _, p := range pm.Partitions {
+		if p.Topic != "new_topic" {
+			t.Errorf("Expected topic new_topic, got %s", p.Topic)
+		}
+
+		got := p.Replicas
+		if len(got) != len(expected[p.Partition]) {
+			t.Errorf("Expected replicas %v, got %v", expected[p.Partition], got)
+			continue
+		}
+
+		for i := range got {
+			if got[i] != expected[p.Partition][i] {
+				t.Errorf("Expected replicas %v, got %v", expected[p.Partition], got)
+				break
+			}
+		}
+	}
+
+	// Validation failures.
+	tests := map[int][]*pb.PartitionAssignment{
+		// Wrong partition count.
+		0: []*pb.PartitionAssignment{
+			&pb.PartitionAssignment{Partition: 0, Replicas: []uint32{1001, 1002}},
+		},
+		// Wrong replica count.
+		1: []*pb.PartitionAssignment{
+			&pb.PartitionAssignment{Partition: 0, Replicas: []uint32{1001, 1002}},
+			&pb.PartitionAssignment{Partition: 1, Replicas: []uint32{1003}},
+		},
+		// Duplicate brokers.
+		2: []*pb.PartitionAssignment{
+			&pb.PartitionAssignment{Partition: 0, Replicas: []uint32{1001, 1001}},
+			&pb.PartitionAssignment{Partition: 1, Replicas: []uint32{1002, 1003}},
+		},
+		// Non-existent broker.
+		3: []*pb.PartitionAssignment{
+			&pb.PartitionAssignment{Partition: 0, Replicas: []uint32{1001, 1002}},
+			&pb.PartitionAssignment{Partition: 1, Replicas: []uint32{1002, 1009}},
+		},
+		// Duplicate partition.
+		4: []*pb.PartitionAssignment{
+			&pb.PartitionAssignment{Partition: 0, Replicas: []uint32{1001, 1002}},
+			&pb.PartitionAssignment{Partition: 0, Replicas: []uint32{1002, 1003}},
+		},
+		// Partition out of range.
+		5: []*pb.PartitionAssignment{
+			&pb.PartitionAssignment{Partition: 0, Replicas: []uint32{1001, 1002}},
+			&pb.PartitionAssignment{Partition: 2, Replicas: []uint32{1002, 1003}},
+		},
+	}
+
+	expectedErrs := map[int]string{
+		0: "invalid assignment: 1 partitions specified, expected 2",
+		1: "invalid assignment: partition 1 has 1 replicas, expected 2",
+		2: "invalid assignment: partition 0 has duplicate broker 1001",
+		3: "invalid assignment: partition 1 broker 1009 does not exist",
+		4: "invalid assignment: partition 0 specified more than once",
+		5: "invalid assignment: partition 2 out of range",
+	}
+
+	for i, a := range tests {
+		_, err := assignmentToPartitionMap(topic, a, bm)
+		if err == nil || err.Error() != expectedErrs[i] {
+			t.Errorf("[test %d] Expected err '%s', got '%v'", i, expectedErrs[i], err)
+		}
+	}
+}
+
+func TestTopicExists(t *testing.T) {
+	s := testServer()
+
+	tests := map[int]*pb.TopicRequest{
+		0: &pb.TopicRequest{Name: "test_topic"},
+		1: &pb.TopicRequest{Name: "test_topic2"},
+		2: &pb.TopicRequest{Name: "nonexistent_topic"},
+	}
+
+	expected := map[int]*pb.TopicExistsResponse{
+		0: &pb.TopicExistsResponse{
+			Exists: true,
+			Topic:  &pb.Topic{Name: "test_topic", Partitions: 5, Replication: 2},
+		},
+		1: &pb.TopicExistsResponse{
+			Exists: true,
+			Topic:  &pb.Topic{Name: "test_topic2", Partitions: 5, Replication: 2},
+		},
+		2: &pb.TopicExistsResponse{Exists: false},
+	}
+
+	for i, req := range tests {
+		resp, err := s.TopicExists(context.Background(), req)
+		if err != nil {
+			t.Errorf("[test %d] Unexpected error: %s", i, err)
+			continue
+		}
+
+		if resp.Exists != expected[i].Exists {
+			t.Errorf("[test %d] Expected exists %v, got %v", i, expected[i].Exists, resp.Exists)
+		}
+
+		if expected[i].Topic == nil {
+			if resp.Topic != nil {
+				t.Errorf("[test %d] Expected nil topic, got %v", i, resp.Topic)
+			}
+			continue
+		}
+
+		if resp.Topic == nil {
+			t.Errorf("[test %d] Expected non-nil topic", i)
+			continue
+		}
+
+		if resp.Topic.Partitions != expected[i].Topic.Partitions {
+			t.Errorf("[test %d] Expected %d partitions, got %d", i, expected[i].Topic.Partitions, resp.Topic.Partitions)
+		}
+
+		if resp.Topic.Replication != expected[i].Topic.Replication {
+			t.Errorf("[test %d] Expected replication %d, got %d", i, expected[i].Topic.Replication, resp.Topic.Replication)
+		}
+	}
+
+	// Test no topic name.
+	_, err := s.TopicExists(context.Background(), &pb.TopicRequest{})
+	if err != ErrTopicNameEmpty {
+		t.Errorf("Unexpected error: %s", err)
+	}
+}
+
+// testDeleteZK is a kafkazk.Mock
+// that records topic deletions.
+type testDeleteZK struct {
+	kafkazk.Mock
+	deleted []string
+	// Errors returned for topics.
+	fail map[string]error
+}
+
+func (zk *testDeleteZK) DeleteTopic(t string) error {
+	if err := zk.fail[t]; err != nil {
+		return err
+	}
+
+	if err := zk.Mock.DeleteTopic(t); err != nil {
+		return err
+	}
+
+	zk.deleted = append(zk.deleted, t)
+	return nil
+}
+
+func TestDeleteTopic(t *testing.T) {
+	tests := map[int]*pb.TopicRequest{
+		0: &pb.TopicRequest{Name: "test_topic", DryRun: true},
+		1: &pb.TopicRequest{Name: "test_topic"},
+		2: &pb.TopicRequest{Tag: []string{"customtag:customvalue"}, DryRun: true},
+		3: &pb.TopicRequest{Tag: []string{"customtag:customvalue"}},
+		4: &pb.TopicRequest{Tag: []string{"customtag2:customvalue2"}},
+	}
+
+	expected := map[int][]string{
+		0: []string{"test_topic"},
+		1: []string{"test_topic"},
+		2: []string{"test_topic", "test_topic2"},
+		3: []string{"test_topic", "test_topic2"},
+		4: []string{"test_topic2"},
+	}
+
+	expectedDeleted := map[int][]string{
+		0: []string{},
+		1: []string{"test_topic"},
+		2: []string{},
+		3: []string{"test_topic", "test_topic2"},
+		4: []string{"test_topic2"},
+	}
+
+	for i, req := range tests {
+		s := testServer()
+		zk := &testDeleteZK{deleted: []string{}}
+		s.ZK = zk
+
+		s.Tags.Store.SetTags(
+			KafkaObject{Type: "topic", ID: "test_topic"},
+			TagSet{"customtag": "customvalue"},
+		)
+
+		s.Tags.Store.SetTags(
+			KafkaObject{Type: "topic", ID: "test_topic2"},
+			TagSet{
+				"customtag":  "customvalue",
+				"customtag2": "customvalue2",
+			},
+		)
+
+		resp, err := s.DeleteTopic(context.Background(), req)
+		if err != nil {
+			t.Errorf("[test %d] Unexpected error: %s", i, err)
+			continue
+		}
+
+		if !stringsEqual(expected[i], resp.Names) {
+			t.Errorf("[test %d] Expected topics %s, got %s", i, expected[i], resp.Names)
+		}
+
+		if !stringsEqual(expectedDeleted[i], zk.deleted) {
+			t.Errorf("[test %d] Expected deleted topics %s, got %s", i, expectedDeleted[i], zk.deleted)
+		}
+	}
+}
+
+func TestDeleteTopicFailures(t *testing.T) {
+	s := testServer()
+
+	tests := map[int]*pb.TopicRequest{
+		0: &pb.TopicRequest{},
+		1: &pb.TopicRequest{Name: "nonexistent"},
+		2: &pb.TopicRequest{Name: "nonexistent", DryRun: true},
+		3: &pb.TopicRequest{Tag: []string{"nomatches:forthistag"}},
+		// Names aren't interpreted as regex.
+		4: &pb.TopicRequest{Name: ".*"},
+		5: &pb.TopicRequest{Name: "test_topic.*", DryRun: true},
+		6: &pb.TopicRequest{Name: "("},
+	}
+
+	expected := map[int]error{
+		0: ErrTopicSelectorEmpty,
+		1: ErrTopicNotFound,
+		2: ErrTopicNotFound,
+		3: ErrTopicNotFound,
+		4: ErrTopicNotFound,
+		5: ErrTopicNotFound,
+		6: ErrTopicNotFound,
+	}
+
+	for i, req := range tests {
+		_, err := s.DeleteTopic(context.Background(), req)
+		if err != expected[i] {
+			t.Errorf("[test %d] Expected err '%v', got '%v'", i, expected[i], err)
+		}
+	}
+
+	// NotFound errors carry the gRPC code.
+	if status.Code(ErrTopicNotFound) != codes.NotFound {
+		t.Errorf("Expected code %s, got %s", codes.NotFound, status.Code(ErrTopicNotFound))
+	}
+}
+
+func TestDeleteTopicPartialFailure(t *testing.T) {
+	s := testServer()
+	zk := &testDeleteZK{fail: map[string]error{"test_topic2": errors.New("zk error")}}
+	s.ZK = zk
+
+	s.Tags.Store.SetTags(KafkaObject{Type: "topic", ID: "test_topic"}, TagSet{"k": "v"})
+	s.Tags.Store.SetTags(KafkaObject{Type: "topic", ID: "test_topic2"}, TagSet{"k": "v"})
+
+	// Failed deletions are reported
+	// alongside those that succeeded.
+	resp, err := s.DeleteTopic(context.Background(), &pb.TopicRequest{Tag: []string{"k:v"}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if !stringsEqual(resp.Names, []string{"test_topic"}) || !stringsEqual(zk.deleted, []string{"test_topic"}) {
+		t.Errorf("Expected test_topic deleted, got %v (deleted: %v)", resp.Names, zk.deleted)
+	}
+
+	if e := resp.Errors; len(e) != 1 || e["test_topic2"] != "zk error" {
+		t.Errorf("Expected test_topic2 error, got %v", e)
+	}
+
+	// An error is returned if no topics were deleted.
+	_, err = s.DeleteTopic(context.Background(), &pb.TopicRequest{Name: "test_topic2"})
+	if status.Code(err) != codes.Internal || !strings.Contains(err.Error(), "test_topic2: zk error") {
+		t.Errorf("Expected an Internal error naming test_topic2, got %v", err)
+	}
+}
+
+func TestFetchTopicSetInvalidRegex(t *testing.T) {
+	s := testServer()
+
+	if _, err := s.fetchTopicSet(&pb.TopicRequest{Name: "("}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Expected code %s, got %v", codes.InvalidArgument, err)
+	}
+}
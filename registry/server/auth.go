@@ -0,0 +1,149 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	// ErrUnauthenticated error.
+	ErrUnauthenticated = errors.New("invalid bearer token")
+	// ErrPermissionDenied error.
+	ErrPermissionDenied = errors.New("permission denied")
+)
+
+// Authorizer authorizes Registry RPCs.
+type Authorizer interface {
+	// Authorize is called with the bearer token provided in the request
+	// and the RPC method name, such as "TagTopic". It returns nil if the
+	// call is permitted, ErrUnauthenticated if the token isn't valid, or
+	// ErrPermissionDenied if the token isn't permitted to call the method.
+	// Any other error denies the call.
+	Authorize(ctx context.Context, token, method string) error
+}
+
+// AuthPolicy maps Registry RPC method names, such as "TagTopic", to
+// whether calls to the method must be authorized. Methods without an
+// entry are open.
+type AuthPolicy map[string]bool
+
+// DefaultAuthPolicy returns an AuthPolicy where all
+// methods that modify state must be authorized.
+func DefaultAuthPolicy() AuthPolicy {
+	return AuthPolicy{
+		"CreateTopic":      true,
+		"DeleteTopic":      true,
+		"TagTopic":         true,
+		"DeleteTopicTags":  true,
+		"TagBroker":        true,
+		"TagBrokers":       true,
+		"DeleteBrokerTags": true,
+		"RemoveBroker":     true,
+	}
+}
+
+// validate returns an error if any method isn't a Registry RPC.
+func (p AuthPolicy) validate() error {
+	for method := range p {
+		if !registryMethod(method) {
+			return fmt.Errorf("invalid auth policy: unknown method '%s'", method)
+		}
+	}
+
+	return nil
+}
+
+// authenticator authorizes RPCs to methods that require authorization
+// under the policy. Tokens are read from the "authorization" request
+// metadata in the form "Bearer <token>"; the HTTP gateway forwards the
+// Authorization header as this metadata.
+type authenticator struct {
+	authorizer Authorizer
+	// Methods that require authorization,
+	// by RPC full method name.
+	restricted map[string]bool
+}
+
+// newAuthenticator returns an *authenticator that applies the policy
+// using the Authorizer. All calls are permitted if the Authorizer is nil.
+func newAuthenticator(a Authorizer, p AuthPolicy) *authenticator {
+	auth := &authenticator{authorizer: a, restricted: map[string]bool{}}
+
+	for method, restricted := range p {
+		if restricted {
+			auth.restricted["/registry.Registry/"+method] = true
+		}
+	}
+
+	return auth
+}
+
+// authorize returns an Unauthenticated error if the RPC method requires
+// authorization and ctx doesn't carry a valid bearer token, or a
+// PermissionDenied error if the token isn't permitted to call the method.
+func (a *authenticator) authorize(ctx context.Context, fullMethod string) error {
+	if a.authorizer == nil || !a.restricted[fullMethod] {
+		return nil
+	}
+
+	token, ok := bearerToken(ctx)
+	if !ok {
+		return status.Error(codes.Unauthenticated, "missing bearer token")
+	}
+
+	method := fullMethod[strings.LastIndex(fullMethod, "/")+1:]
+
+	switch err := a.authorizer.Authorize(ctx, token, method); {
+	case err == nil:
+		return nil
+	case errors.Is(err, ErrUnauthenticated):
+		return status.Error(codes.Unauthenticated, err.Error())
+	default:
+		return status.Error(codes.PermissionDenied, err.Error())
+	}
+}
+
+// bearerToken returns the bearer token from the
+// "authorization" metadata of ctx, if any.
+func bearerToken(ctx context.Context) (string, bool) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return "", false
+	}
+
+	for _, v := range md.Get("authorization") {
+		parts := strings.SplitN(v, " ", 2)
+		if len(parts) == 2 && strings.EqualFold(parts[0], "bearer") && parts[1] != "" {
+			return parts[1], true
+		}
+	}
+
+	return "", false
+}
+
+// unaryInterceptor is a grpc.UnaryServerInterceptor that
+// authorizes unary RPCs.
+func (a *authenticator) unaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if err := a.authorize(ctx, info.FullMethod); err != nil {
+		return nil, err
+	}
+
+	return handler(ctx, req)
+}
+
+// streamInterceptor is a grpc.StreamServerInterceptor that
+// authorizes streaming RPCs when the stream is opened.
+func (a *authenticator) streamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := a.authorize(ss.Context(), info.FullMethod); err != nil {
+		return err
+	}
+
+	return handler(srv, ss)
+}
@@ -0,0 +1,209 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	pb "github.com/DataDog/kafka-kit/registry/protos"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// stubAuthorizer permits tokens to call the methods they're mapped to.
+type stubAuthorizer struct {
+	tokens map[string][]string
+	calls  []string
+}
+
+func (a *stubAuthorizer) Authorize(_ context.Context, token, method string) error {
+	a.calls = append(a.calls, method)
+
+	methods, exists := a.tokens[token]
+	if !exists {
+		return ErrUnauthenticated
+	}
+
+	for _, m := range methods {
+		if m == method {
+			return nil
+		}
+	}
+
+	return ErrPermissionDenied
+}
+
+func testAuthClient(t *testing.T, c Config) (pb.RegistryClient, func()) {
+	c.ReadReqRate, c.WriteReqRate = 1, 1
+	c.ZKTagsPrefix = testConfig.Prefix
+	c.test = true
+
+	s, err := NewServer(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.DialZK(nil, nil, nil)
+
+	conn, stop := testGRPCConn(t, s)
+
+	return pb.NewRegistryClient(conn), stop
+}
+
+func withToken(token string) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+token)
+}
+
+func TestAuthorization(t *testing.T) {
+	auth := &stubAuthorizer{tokens: map[string][]string{
+		"admin":  {"TagBroker", "DeleteBrokerTags"},
+		"reader": {},
+	}}
+
+	client, done := testAuthClient(t, Config{Authorizer: auth})
+	defer done()
+
+	listBrokers := func(ctx context.Context) error {
+		_, err := client.ListBrokers(ctx, &pb.BrokerRequest{})
+		return err
+	}
+
+	tagBroker := func(ctx context.Context) error {
+		_, err := client.TagBroker(ctx, &pb.BrokerRequest{Id: 1001, Tag: []string{"k:v"}})
+		return err
+	}
+
+	removeBroker := func(ctx context.Context) error {
+		_, err := client.RemoveBroker(ctx, &pb.BrokerRequest{Id: 1001})
+		return err
+	}
+
+	tests := map[int]struct {
+		ctx      context.Context
+		call     func(context.Context) error
+		expected codes.Code
+	}{
+		// Reads are open.
+		0: {
+			ctx:      context.Background(),
+			call:     listBrokers,
+			expected: codes.OK,
+		},
+		1: {
+			ctx:      withToken("unknown"),
+			call:     listBrokers,
+			expected: codes.OK,
+		},
+		// Writes require a token.
+		2: {
+			ctx:      context.Background(),
+			call:     tagBroker,
+			expected: codes.Unauthenticated,
+		},
+		3: {
+			ctx:      metadata.AppendToOutgoingContext(context.Background(), "authorization", "Basic admin"),
+			call:     tagBroker,
+			expected: codes.Unauthenticated,
+		},
+		4: {
+			ctx:      withToken("unknown"),
+			call:     tagBroker,
+			expected: codes.Unauthenticated,
+		},
+		5: {
+			ctx:      withToken("reader"),
+			call:     tagBroker,
+			expected: codes.PermissionDenied,
+		},
+		6: {
+			ctx:      withToken("admin"),
+			call:     tagBroker,
+			expected: codes.OK,
+		},
+		7: {
+			ctx:      withToken("admin"),
+			call:     removeBroker,
+			expected: codes.PermissionDenied,
+		},
+	}
+
+	for i := 0; i < len(tests); i++ {
+		test := tests[i]
+		if code := status.Code(test.call(test.ctx)); code != test.expected {
+			t.Errorf("[test %d] Expected code %s, got %s", i, test.expected, code)
+		}
+	}
+
+	// The authorizer is only consulted for
+	// restricted methods with a bearer token.
+	expected := []string{"TagBroker", "TagBroker", "TagBroker", "RemoveBroker"}
+	if !stringsEqual(auth.calls, expected) {
+		t.Errorf("Expected authorizer calls %v, got %v", expected, auth.calls)
+	}
+}
+
+func TestAuthorizationStream(t *testing.T) {
+	auth := &stubAuthorizer{tokens: map[string][]string{"admin": {"WatchBrokers"}}}
+
+	client, done := testAuthClient(t, Config{
+		Authorizer: auth,
+		AuthPolicy: AuthPolicy{"WatchBrokers": true},
+	})
+	defer done()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stream, err := client.WatchBrokers(ctx, &pb.BrokerRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := stream.Recv(); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("Expected Unauthenticated error, got %v", err)
+	}
+
+	stream, err = client.WatchBrokers(metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer admin"), &pb.BrokerRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The initial snapshot is sent once authorized.
+	if _, err := stream.Recv(); err != nil {
+		t.Errorf("Unexpected error: %s", err)
+	}
+
+	// Writes are open under a policy that doesn't restrict them.
+	if _, err := client.TagBroker(context.Background(), &pb.BrokerRequest{Id: 1001, Tag: []string{"k:v"}}); err != nil {
+		t.Errorf("Unexpected error: %s", err)
+	}
+}
+
+func TestAuthorizationDisabled(t *testing.T) {
+	client, done := testAuthClient(t, Config{})
+	defer done()
+
+	// All calls are permitted without an Authorizer.
+	if _, err := client.TagBroker(context.Background(), &pb.BrokerRequest{Id: 1001, Tag: []string{"k:v"}}); err != nil {
+		t.Errorf("Unexpected error: %s", err)
+	}
+}
+
+func TestNewServerAuthPolicy(t *testing.T) {
+	c := Config{
+		ReadReqRate:  1,
+		WriteReqRate: 1,
+		ZKTagsPrefix: "test",
+		AuthPolicy:   AuthPolicy{"TagBroker": true, "Nope": true},
+	}
+
+	if _, err := NewServer(c); err == nil {
+		t.Error("Expected error")
+	}
+
+	c.AuthPolicy = DefaultAuthPolicy()
+
+	if _, err := NewServer(c); err != nil {
+		t.Errorf("Unexpected error: %s", err)
+	}
+}
@@ -0,0 +1,129 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	pb "github.com/DataDog/kafka-kit/registry/protos"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ConcurrencyLimits maps Registry RPC method names, such as "GetTopics",
+// to the maximum number of concurrent in-flight requests for the method.
+// Methods without a limit are unbounded.
+type ConcurrencyLimits map[string]int
+
+// ParseConcurrencyLimits parses a comma delimited list of method=limit
+// pairs, such as "GetTopics=4,ListTopics=8", into a ConcurrencyLimits.
+func ParseConcurrencyLimits(s string) (ConcurrencyLimits, error) {
+	limits := ConcurrencyLimits{}
+
+	if s == "" {
+		return limits, nil
+	}
+
+	for _, pair := range strings.Split(s, ",") {
+		kv := strings.Split(pair, "=")
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid concurrency limit '%s': must be formatted as method=limit", pair)
+		}
+
+		n, err := strconv.Atoi(kv[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid concurrency limit '%s': %s", pair, err)
+		}
+
+		limits[kv[0]] = n
+	}
+
+	return limits, limits.validate()
+}
+
+// validate returns an error if any method isn't a Registry
+// RPC or any limit is less than 1.
+func (c ConcurrencyLimits) validate() error {
+	for method, n := range c {
+		if !registryMethod(method) {
+			return fmt.Errorf("invalid concurrency limit: unknown method '%s'", method)
+		}
+
+		if n < 1 {
+			return fmt.Errorf("invalid concurrency limit for %s: must be >= 1", method)
+		}
+	}
+
+	return nil
+}
+
+// registryMethod returns whether name, such as "GetTopics",
+// is a Registry RPC method.
+func registryMethod(name string) bool {
+	_, exists := reflect.TypeOf((*pb.RegistryServer)(nil)).Elem().MethodByName(name)
+	return exists
+}
+
+// concurrencyLimiter limits the number of in-flight requests for each
+// RPC method with a configured limit. Requests exceeding the limit are
+// rejected rather than queued, so that bursts of requests can't pile up
+// ZooKeeper reads.
+type concurrencyLimiter struct {
+	// Semaphores by RPC full method name.
+	sems map[string]chan struct{}
+}
+
+func newConcurrencyLimiter(limits ConcurrencyLimits) *concurrencyLimiter {
+	l := &concurrencyLimiter{sems: map[string]chan struct{}{}}
+
+	for method, n := range limits {
+		l.sems["/registry.Registry/"+method] = make(chan struct{}, n)
+	}
+
+	return l
+}
+
+// acquire takes a slot for the RPC method, returning a func that releases
+// it. A ResourceExhausted error is returned if the limit has been reached.
+func (l *concurrencyLimiter) acquire(method string) (func(), error) {
+	sem, exists := l.sems[method]
+	if !exists {
+		return func() {}, nil
+	}
+
+	select {
+	case sem <- struct{}{}:
+		return func() { <-sem }, nil
+	default:
+		return nil, status.Errorf(codes.ResourceExhausted, "concurrency limit of %d reached for %s", cap(sem), method)
+	}
+}
+
+// unaryInterceptor is a grpc.UnaryServerInterceptor that
+// applies concurrency limits to unary RPCs.
+func (l *concurrencyLimiter) unaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	release, err := l.acquire(info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	return handler(ctx, req)
+}
+
+// streamInterceptor is a grpc.StreamServerInterceptor that applies
+// concurrency limits to streaming RPCs. A slot is held for the lifetime
+// of the stream.
+func (l *concurrencyLimiter) streamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	release, err := l.acquire(info.FullMethod)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	return handler(srv, ss)
+}
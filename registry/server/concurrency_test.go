@@ -0,0 +1,157 @@
+package server
+
+import (
+	"context"
+	"regexp"
+	"sync"
+	"testing"
+
+	"github.com/DataDog/kafka-kit/kafkazk"
+	pb "github.com/DataDog/kafka-kit/registry/protos"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// blockingZK is a kafkazk.Mock where GetTopics
+// blocks until the release channel is closed.
+type blockingZK struct {
+	kafkazk.Mock
+	entered chan struct{}
+	release chan struct{}
+}
+
+func (zk *blockingZK) GetTopics(ts []*regexp.Regexp) ([]string, error) {
+	zk.entered <- struct{}{}
+	<-zk.release
+	return zk.Mock.GetTopics(ts)
+}
+
+func TestConcurrencyLimits(t *testing.T) {
+	s, _ := NewServer(Config{
+		ReadReqRate:       1,
+		WriteReqRate:      1,
+		ZKTagsPrefix:      testConfig.Prefix,
+		ConcurrencyLimits: ConcurrencyLimits{"GetTopics": 2},
+		test:              true,
+	})
+
+	zk := &blockingZK{entered: make(chan struct{}, 10), release: make(chan struct{})}
+	s.ZK = zk
+
+	conn, stop := testGRPCConn(t, s)
+	defer stop()
+
+	ctx := context.Background()
+	client := pb.NewRegistryClient(conn)
+
+	// Fill the limit with requests blocked in ZooKeeper.
+	var wg sync.WaitGroup
+	inflight := make(chan error, 2)
+
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := client.GetTopics(ctx, &pb.TopicRequest{IncludeAssignments: true})
+			inflight <- err
+		}()
+	}
+
+	for i := 0; i < 2; i++ {
+		<-zk.entered
+	}
+
+	// All requests in excess of the limit are rejected.
+	excess := make(chan error, 3)
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := client.GetTopics(ctx, &pb.TopicRequest{})
+			excess <- err
+		}()
+	}
+
+	for i := 0; i < 3; i++ {
+		if err := <-excess; status.Code(err) != codes.ResourceExhausted {
+			t.Errorf("Expected ResourceExhausted error, got %v", err)
+		}
+	}
+
+	// Unlimited methods are unaffected.
+	if _, err := client.ListBrokers(ctx, &pb.BrokerRequest{}); err != nil {
+		t.Errorf("Unexpected error: %s", err)
+	}
+
+	close(zk.release)
+	wg.Wait()
+
+	for i := 0; i < 2; i++ {
+		if err := <-inflight; err != nil {
+			t.Errorf("Unexpected error: %s", err)
+		}
+	}
+
+	// Slots are released once requests complete.
+	if _, err := client.GetTopics(ctx, &pb.TopicRequest{}); err != nil {
+		t.Errorf("Unexpected error: %s", err)
+	}
+}
+
+func TestParseConcurrencyLimits(t *testing.T) {
+	tests := map[int]string{
+		0: "",
+		1: "GetTopics=4,ListTopics=8",
+		2: "GetTopics",
+		3: "GetTopics=x",
+		4: "GetTopics=0",
+		5: "NotAMethod=1",
+	}
+
+	expected := map[int]ConcurrencyLimits{
+		0: {},
+		1: {"GetTopics": 4, "ListTopics": 8},
+	}
+
+	for i := 0; i < len(tests); i++ {
+		limits, err := ParseConcurrencyLimits(tests[i])
+
+		exp, valid := expected[i]
+		if !valid {
+			if err == nil {
+				t.Errorf("[test %d] Expected error", i)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("[test %d] Unexpected error: %s", i, err)
+			continue
+		}
+
+		if len(limits) != len(exp) {
+			t.Errorf("[test %d] Expected %v, got %v", i, exp, limits)
+		}
+
+		for method, n := range exp {
+			if limits[method] != n {
+				t.Errorf("[test %d] Expected %s limit %d, got %d", i, method, n, limits[method])
+			}
+		}
+	}
+}
+
+func TestNewServerConcurrencyLimits(t *testing.T) {
+	tests := map[int]ConcurrencyLimits{
+		0: {"GetTopics": 0},
+		1: {"NotAMethod": 1},
+	}
+
+	for i, limits := range tests {
+		c := Config{ReadReqRate: 1, WriteReqRate: 1, ZKTagsPrefix: "test", ConcurrencyLimits: limits}
+		if _, err := NewServer(c); err == nil {
+			t.Errorf("[test %d] Expected error", i)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package server
+
+import (
+	"context"
+	"regexp"
+	"time"
+
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+const (
+	// RegistryService is the health service name of the Registry
+	// service. Along with the overall ("") service, it reports
+	// SERVING while the ZooKeeper session is alive.
+	RegistryService = "registry.Registry"
+	// ReadinessService is the health service name reporting whether
+	// the server is ready for requests. It reports SERVING once
+	// broker and topic metadata has been successfully fetched
+	// from ZooKeeper and while the ZooKeeper session is alive.
+	ReadinessService = "readiness"
+)
+
+var (
+	// healthCheckInterval is the interval at
+	// which health statuses are updated.
+	healthCheckInterval = 5 * time.Second
+)
+
+// newHealthServer returns a *health.Server with
+// all services initialized as NOT_SERVING.
+func newHealthServer() *health.Server {
+	hs := health.NewServer()
+	for _, svc := range []string{"", RegistryService, ReadinessService} {
+		hs.SetServingStatus(svc, healthpb.HealthCheckResponse_NOT_SERVING)
+	}
+
+	return hs
+}
+
+// runHealthChecks updates the health statuses every
+// interval until the context is cancelled, at which point
+// all services are set to NOT_SERVING.
+func (s *Server) runHealthChecks(ctx context.Context, interval time.Duration) {
+	s.updateHealth()
+
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			s.health.Shutdown()
+			return
+		case <-t.C:
+			s.updateHealth()
+		}
+	}
+}
+
+// updateHealth sets the health statuses according to the ZooKeeper
+// session state. Readiness additionally requires that metadata has
+// been successfully fetched once; this is attempted on each update
+// until it succeeds.
+func (s *Server) updateHealth() {
+	connected := s.ZK != nil && s.ZK.Ready()
+
+	if connected && !s.warm {
+		s.warm = s.warmUp() == nil
+	}
+
+	status := healthpb.HealthCheckResponse_NOT_SERVING
+	if connected {
+		status = healthpb.HealthCheckResponse_SERVING
+	}
+
+	s.health.SetServingStatus("", status)
+	s.health.SetServingStatus(RegistryService, status)
+
+	if !s.warm {
+		status = healthpb.HealthCheckResponse_NOT_SERVING
+	}
+
+	s.health.SetServingStatus(ReadinessService, status)
+}
+
+// warmUp fetches broker metadata and topics from ZooKeeper,
+// returning an error if either can't be fetched.
+func (s *Server) warmUp() error {
+	if _, err := s.fetchBrokerMeta(false); err != nil {
+		return err
+	}
+
+	if _, err := s.ZK.GetTopics([]*regexp.Regexp{tregex}); err != nil {
+		return ErrFetchingTopics
+	}
+
+	return nil
+}
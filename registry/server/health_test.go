@@ -0,0 +1,113 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/DataDog/kafka-kit/kafkazk"
+
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// toggleZK is a kafkazk.Mock with a settable
+// connection state and GetTopics failure.
+type toggleZK struct {
+	kafkazk.Mock
+	connected bool
+	topicsErr error
+}
+
+func (zk *toggleZK) Ready() bool {
+	return zk.connected
+}
+
+func (zk *toggleZK) GetTopics(ts []*regexp.Regexp) ([]string, error) {
+	if zk.topicsErr != nil {
+		return nil, zk.topicsErr
+	}
+	return zk.Mock.GetTopics(ts)
+}
+
+func healthStatus(t *testing.T, s *Server, svc string) healthpb.HealthCheckResponse_ServingStatus {
+	resp, err := s.health.Check(context.Background(), &healthpb.HealthCheckRequest{Service: svc})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	return resp.Status
+}
+
+func TestUpdateHealth(t *testing.T) {
+	serving := healthpb.HealthCheckResponse_SERVING
+	notServing := healthpb.HealthCheckResponse_NOT_SERVING
+
+	s := testServer()
+	zk := &toggleZK{topicsErr: errors.New("error")}
+	s.ZK = zk
+
+	type expected struct {
+		registry  healthpb.HealthCheckResponse_ServingStatus
+		readiness healthpb.HealthCheckResponse_ServingStatus
+	}
+
+	steps := []struct {
+		connected bool
+		topicsErr error
+		expected
+	}{
+		// Disconnected.
+		{false, zk.topicsErr, expected{notServing, notServing}},
+		// Connected, but the warm up fails.
+		{true, zk.topicsErr, expected{serving, notServing}},
+		// Connected and warm.
+		{true, nil, expected{serving, serving}},
+		// Disconnected after warm up.
+		{false, nil, expected{notServing, notServing}},
+		// Reconnected; already warm.
+		{true, errors.New("error"), expected{serving, serving}},
+	}
+
+	// Initial state.
+	for _, svc := range []string{"", RegistryService, ReadinessService} {
+		if st := healthStatus(t, s, svc); st != notServing {
+			t.Errorf("Expected initial status %s for '%s', got %s", notServing, svc, st)
+		}
+	}
+
+	for i, step := range steps {
+		zk.connected, zk.topicsErr = step.connected, step.topicsErr
+		s.updateHealth()
+
+		for _, svc := range []string{"", RegistryService} {
+			if st := healthStatus(t, s, svc); st != step.registry {
+				t.Errorf("[step %d] Expected status %s for '%s', got %s", i, step.registry, svc, st)
+			}
+		}
+
+		if st := healthStatus(t, s, ReadinessService); st != step.readiness {
+			t.Errorf("[step %d] Expected readiness status %s, got %s", i, step.readiness, st)
+		}
+	}
+}
+
+func TestRunHealthChecksShutdown(t *testing.T) {
+	s := testServer()
+	s.ZK = &toggleZK{connected: true}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	go func() {
+		s.runHealthChecks(ctx, healthCheckInterval)
+		close(done)
+	}()
+
+	cancel()
+	<-done
+
+	if st := healthStatus(t, s, RegistryService); st != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Errorf("Expected status NOT_SERVING after shutdown, got %s", st)
+	}
+}
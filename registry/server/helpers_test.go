@@ -1,7 +1,15 @@
 package server
 
 import (
+	"context"
+	"net"
+	"testing"
+
 	"github.com/DataDog/kafka-kit/kafkazk"
+	pb "github.com/DataDog/kafka-kit/registry/protos"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/test/bufconn"
 )
 
 var (
@@ -23,6 +31,43 @@ func testServer() *Server {
 	return s
 }
 
+// testGRPCConn serves a gRPC server for s over an in-memory listener and
+// returns a client connection to it, along with a func that closes the
+// connection and stops the server.
+func testGRPCConn(t *testing.T, s *Server) (*grpc.ClientConn, func()) {
+	l := bufconn.Listen(1 << 20)
+	srvr := s.newGRPCServer()
+	go srvr.Serve(l)
+
+	dialer := func(context.Context, string) (net.Conn, error) { return l.Dial() }
+	conn, err := grpc.Dial("bufnet", grpc.WithContextDialer(dialer), grpc.WithInsecure())
+	if err != nil {
+		srvr.Stop()
+		t.Fatal(err)
+	}
+
+	return conn, func() {
+		conn.Close()
+		srvr.Stop()
+	}
+}
+
+// testBrokerRequests makes two successful ListBrokers
+// requests and one failed GetBrokers request.
+func testBrokerRequests(t *testing.T, client pb.RegistryClient) {
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if _, err := client.ListBrokers(ctx, &pb.BrokerRequest{}); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+	}
+
+	if _, err := client.GetBrokers(ctx, &pb.BrokerRequest{PageToken: "!"}); err == nil {
+		t.Fatal("Expected error")
+	}
+}
+
 func testTagHandler() *TagHandler {
 	th, _ := NewTagHandler(testConfig)
 	th.Store.(*ZKTagStorage).ZK = &kafkazk.Mock{}
@@ -0,0 +1,172 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	// ErrInvalidLogLevel error.
+	ErrInvalidLogLevel = errors.New("invalid log level")
+)
+
+// Logger is a leveled logger. Arguments are handled
+// in the manner of fmt.Printf.
+type Logger interface {
+	Debugf(format string, v ...interface{})
+	Infof(format string, v ...interface{})
+	Warnf(format string, v ...interface{})
+	Errorf(format string, v ...interface{})
+}
+
+// LogLevel is a Logger severity level.
+type LogLevel int
+
+// Log levels in order of increasing severity.
+const (
+	LogDebug LogLevel = iota
+	LogInfo
+	LogWarn
+	LogError
+)
+
+// ParseLogLevel returns the LogLevel for the
+// level name: [debug, info, warn, error].
+func ParseLogLevel(s string) (LogLevel, error) {
+	switch strings.ToLower(s) {
+	case "debug":
+		return LogDebug, nil
+	case "info":
+		return LogInfo, nil
+	case "warn":
+		return LogWarn, nil
+	case "error":
+		return LogError, nil
+	}
+
+	return 0, ErrInvalidLogLevel
+}
+
+func (l LogLevel) String() string {
+	switch l {
+	case LogDebug:
+		return "DEBUG"
+	case LogInfo:
+		return "INFO"
+	case LogWarn:
+		return "WARN"
+	case LogError:
+		return "ERROR"
+	}
+
+	return fmt.Sprintf("LogLevel(%d)", int(l))
+}
+
+// StdLogger is a Logger that writes messages at or
+// above a minimum level to a *log.Logger, prefixed
+// with the level (e.g. "[INFO] gRPC up").
+type StdLogger struct {
+	logger *log.Logger
+	level  LogLevel
+}
+
+// NewStdLogger returns a *StdLogger writing to l. Messages
+// below the provided level are discarded.
+func NewStdLogger(l *log.Logger, level LogLevel) *StdLogger {
+	return &StdLogger{logger: l, level: level}
+}
+
+func (l *StdLogger) logf(level LogLevel, format string, v ...interface{}) {
+	if level < l.level {
+		return
+	}
+
+	l.logger.Printf("[%s] %s", level, fmt.Sprintf(format, v...))
+}
+
+// Debugf logs at LogDebug.
+func (l *StdLogger) Debugf(format string, v ...interface{}) { l.logf(LogDebug, format, v...) }
+
+// Infof logs at LogInfo.
+func (l *StdLogger) Infof(format string, v ...interface{}) { l.logf(LogInfo, format, v...) }
+
+// Warnf logs at LogWarn.
+func (l *StdLogger) Warnf(format string, v ...interface{}) { l.logf(LogWarn, format, v...) }
+
+// Errorf logs at LogError.
+func (l *StdLogger) Errorf(format string, v ...interface{}) { l.logf(LogError, format, v...) }
+
+// nopLogger is a Logger that discards all messages.
+type nopLogger struct{}
+
+func (nopLogger) Debugf(string, ...interface{}) {}
+func (nopLogger) Infof(string, ...interface{})  {}
+func (nopLogger) Warnf(string, ...interface{})  {}
+func (nopLogger) Errorf(string, ...interface{}) {}
+
+// loggerOrNop returns l, or a nopLogger if l is nil.
+func loggerOrNop(l Logger) Logger {
+	if l == nil {
+		return nopLogger{}
+	}
+	return l
+}
+
+// accessLog logs a completed RPC along with the
+// requestor, request type, duration and gRPC status.
+func (s *Server) accessLog(ctx context.Context, method string, err error, d time.Duration) {
+	requestor, reqType := requestSource(ctx)
+
+	s.logger.Infof("requestor:%s type:%s method:%s duration:%s code:%s",
+		requestor, reqType, method, d, status.Code(err))
+}
+
+// accessLogUnaryInterceptor is a grpc.UnaryServerInterceptor
+// that writes an access log for each unary RPC.
+func (s *Server) accessLogUnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	s.accessLog(ctx, info.FullMethod, err, time.Since(start))
+
+	return resp, err
+}
+
+// accessLogStreamInterceptor is a grpc.StreamServerInterceptor that writes
+// an access log for each streaming RPC. The duration is the lifetime of the
+// stream.
+func (s *Server) accessLogStreamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	start := time.Now()
+	err := handler(srv, ss)
+	s.accessLog(ss.Context(), info.FullMethod, err, time.Since(start))
+
+	return err
+}
+
+// requestSource returns the requestor address and request
+// type (grpc or http, if proxied by the gateway) for ctx.
+func requestSource(ctx context.Context) (requestor, reqType string) {
+	// Get Peer info.
+	if p, ok := peer.FromContext(ctx); ok {
+		requestor = p.Addr.String()
+	}
+
+	// Get Metadata.
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if _, ok := md["grpcgateway-user-agent"]; ok {
+			reqType = "http"
+		} else {
+			reqType = "grpc"
+		}
+	}
+
+	return requestor, reqType
+}
@@ -0,0 +1,122 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"testing"
+
+	pb "github.com/DataDog/kafka-kit/registry/protos"
+)
+
+// testLogger is a Logger that captures messages.
+type testLogger struct {
+	sync.Mutex
+	lines []string
+}
+
+func (l *testLogger) logf(level LogLevel, format string, v ...interface{}) {
+	l.Lock()
+	defer l.Unlock()
+	l.lines = append(l.lines, fmt.Sprintf("[%s] %s", level, fmt.Sprintf(format, v...)))
+}
+
+func (l *testLogger) Debugf(format string, v ...interface{}) { l.logf(LogDebug, format, v...) }
+func (l *testLogger) Infof(format string, v ...interface{})  { l.logf(LogInfo, format, v...) }
+func (l *testLogger) Warnf(format string, v ...interface{})  { l.logf(LogWarn, format, v...) }
+func (l *testLogger) Errorf(format string, v ...interface{}) { l.logf(LogError, format, v...) }
+
+// matching returns the captured lines containing s.
+func (l *testLogger) matching(s string) []string {
+	l.Lock()
+	defer l.Unlock()
+
+	var out []string
+	for _, line := range l.lines {
+		if strings.Contains(line, s) {
+			out = append(out, line)
+		}
+	}
+
+	return out
+}
+
+func TestAccessLog(t *testing.T) {
+	s := testServer()
+	logger := &testLogger{}
+	s.logger = logger
+
+	conn, stop := testGRPCConn(t, s)
+	defer stop()
+
+	testBrokerRequests(t, pb.NewRegistryClient(conn))
+
+	tests := map[string]int{
+		"method:/registry.Registry/ListBrokers": 2,
+		"method:/registry.Registry/GetBrokers":  1,
+	}
+
+	for method, expected := range tests {
+		lines := logger.matching(method)
+		if len(lines) != expected {
+			t.Errorf("Expected %d access log line(s) for %s, got %v", expected, method, lines)
+		}
+
+		for _, line := range lines {
+			for _, field := range []string{"[INFO]", "type:grpc", "duration:"} {
+				if !strings.Contains(line, field) {
+					t.Errorf("Expected '%s' in access log line '%s'", field, line)
+				}
+			}
+		}
+	}
+
+	if lines := logger.matching("GetBrokers"); len(lines) == 1 && !strings.Contains(lines[0], "code:InvalidArgument") {
+		t.Errorf("Expected code:InvalidArgument in access log line '%s'", lines[0])
+	}
+
+	if lines := logger.matching("ListBrokers"); len(lines) == 2 && !strings.Contains(lines[0], "code:OK") {
+		t.Errorf("Expected code:OK in access log line '%s'", lines[0])
+	}
+}
+
+func TestStdLogger(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewStdLogger(log.New(&buf, "", 0), LogWarn)
+
+	l.Debugf("debug %d", 1)
+	l.Infof("info %d", 2)
+	l.Warnf("warn %d", 3)
+	l.Errorf("error %d", 4)
+
+	expected := "[WARN] warn 3\n[ERROR] error 4\n"
+	if buf.String() != expected {
+		t.Errorf("Expected output '%s', got '%s'", expected, buf.String())
+	}
+}
+
+func TestParseLogLevel(t *testing.T) {
+	tests := map[string]LogLevel{
+		"debug": LogDebug,
+		"info":  LogInfo,
+		"WARN":  LogWarn,
+		"error": LogError,
+	}
+
+	for s, expected := range tests {
+		level, err := ParseLogLevel(s)
+		if err != nil {
+			t.Errorf("[%s] Unexpected error: %s", s, err)
+		}
+
+		if level != expected {
+			t.Errorf("[%s] Expected level %s, got %s", s, expected, level)
+		}
+	}
+
+	if _, err := ParseLogLevel("verbose"); err != ErrInvalidLogLevel {
+		t.Errorf("Expected error '%s', got '%v'", ErrInvalidLogLevel, err)
+	}
+}
@@ -0,0 +1,140 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	// latencyBuckets are the RPC latency histogram
+	// bucket upper bounds in seconds.
+	latencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+	// metricsRefreshInterval is the interval at which
+	// the broker and topic count gauges are refreshed.
+	metricsRefreshInterval = 30 * time.Second
+)
+
+// rpcMetrics holds per method RPC request counts, error counts by gRPC
+// code and latency histograms, along with broker and topic count gauges.
+type rpcMetrics struct {
+	registry *prometheus.Registry
+	requests *prometheus.CounterVec
+	errors   *prometheus.CounterVec
+	latency  *prometheus.HistogramVec
+	brokers  prometheus.Gauge
+	topics   prometheus.Gauge
+}
+
+func newRPCMetrics() *rpcMetrics {
+	m := &rpcMetrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "registry_grpc_requests_total",
+			Help: "Total gRPC requests handled.",
+		}, []string{"method"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "registry_grpc_errors_total",
+			Help: "Total gRPC requests that returned an error, by code.",
+		}, []string{"method", "code"}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "registry_grpc_request_duration_seconds",
+			Help:    "gRPC request latency.",
+			Buckets: latencyBuckets,
+		}, []string{"method"}),
+		brokers: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "registry_brokers",
+			Help: "Number of brokers registered in ZooKeeper, as of the last refresh.",
+		}),
+		topics: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "registry_topics",
+			Help: "Number of topics in ZooKeeper, as of the last refresh.",
+		}),
+	}
+
+	m.registry.MustRegister(m.requests, m.errors, m.latency, m.brokers, m.topics)
+
+	return m
+}
+
+// observe records a completed RPC.
+func (m *rpcMetrics) observe(method string, err error, d time.Duration) {
+	m.requests.WithLabelValues(method).Inc()
+
+	if err != nil {
+		m.errors.WithLabelValues(method, status.Code(err).String()).Inc()
+	}
+
+	m.latency.WithLabelValues(method).Observe(d.Seconds())
+}
+
+// unaryInterceptor is a grpc.UnaryServerInterceptor that records metrics
+// for each unary RPC.
+func (m *rpcMetrics) unaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	m.observe(info.FullMethod, err, time.Since(start))
+
+	return resp, err
+}
+
+// streamInterceptor is a grpc.StreamServerInterceptor that records metrics
+// for each streaming RPC. Latency is the lifetime of the stream.
+func (m *rpcMetrics) streamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	start := time.Now()
+	err := handler(srv, ss)
+	m.observe(info.FullMethod, err, time.Since(start))
+
+	return err
+}
+
+// metricsHandler returns an http.Handler that serves the RPC metrics
+// and the broker and topic count gauges in the Prometheus exposition
+// format. Scrapes don't query ZooKeeper; see refreshMetrics.
+func (s *Server) metricsHandler() http.Handler {
+	return promhttp.HandlerFor(s.metrics.registry, promhttp.HandlerOpts{})
+}
+
+// runMetricsRefresh refreshes the broker and topic count
+// gauges every interval until the context is cancelled.
+func (s *Server) runMetricsRefresh(ctx context.Context, interval time.Duration) {
+	s.refreshMetrics()
+
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			s.refreshMetrics()
+		}
+	}
+}
+
+// refreshMetrics sets the broker and topic count gauges from
+// ZooKeeper. Gauges that can't be fetched retain their last value.
+func (s *Server) refreshMetrics() {
+	if s.ZK == nil {
+		return
+	}
+
+	if bm, errs := s.ZK.GetAllBrokerMeta(false); errs == nil {
+		s.metrics.brokers.Set(float64(len(bm)))
+	} else {
+		s.logger.Warnf("Error refreshing broker count metric: %s", errs[0])
+	}
+
+	if ts, err := s.ZK.GetTopics([]*regexp.Regexp{tregex}); err == nil {
+		s.metrics.topics.Set(float64(len(ts)))
+	} else {
+		s.logger.Warnf("Error refreshing topic count metric: %s", err)
+	}
+}
@@ -0,0 +1,88 @@
+package server
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	pb "github.com/DataDog/kafka-kit/registry/protos"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestMetrics(t *testing.T) {
+	s := testServer()
+
+	conn, stop := testGRPCConn(t, s)
+	defer stop()
+
+	testBrokerRequests(t, pb.NewRegistryClient(conn))
+
+	// Gauges are set by refreshes
+	// rather than at scrape time.
+	s.refreshMetrics()
+
+	rec := httptest.NewRecorder()
+	s.metricsHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	out := rec.Body.String()
+
+	expected := []string{
+		`registry_grpc_requests_total{method="/registry.Registry/ListBrokers"} 2`,
+		`registry_grpc_requests_total{method="/registry.Registry/GetBrokers"} 1`,
+		`registry_grpc_errors_total{code="InvalidArgument",method="/registry.Registry/GetBrokers"} 1`,
+		`registry_grpc_request_duration_seconds_count{method="/registry.Registry/ListBrokers"} 2`,
+		`registry_grpc_request_duration_seconds_bucket{method="/registry.Registry/ListBrokers",le="+Inf"} 2`,
+		"registry_brokers 5",
+		"registry_topics 2",
+	}
+
+	for _, line := range expected {
+		if !strings.Contains(out, line+"\n") {
+			t.Errorf("Expected line '%s' in output:\n%s", line, out)
+		}
+	}
+
+	for _, line := range strings.Split(out, "\n") {
+		if strings.HasPrefix(line, "registry_grpc_errors_total{") && strings.Contains(line, "ListBrokers") {
+			t.Errorf("Unexpected ListBrokers errors in output:\n%s", out)
+		}
+	}
+}
+
+func TestRPCMetricsObserve(t *testing.T) {
+	m := newRPCMetrics()
+
+	m.observe("m", nil, 20*time.Millisecond)
+	m.observe("m", status.Error(codes.NotFound, "not found"), 3*time.Second)
+	m.observe("m", errors.New("error"), time.Millisecond)
+
+	metric := &dto.Metric{}
+
+	m.requests.WithLabelValues("m").Write(metric)
+	if n := metric.GetCounter().GetValue(); n != 3 {
+		t.Errorf("Expected 3 requests, got %g", n)
+	}
+
+	errs := map[codes.Code]float64{codes.NotFound: 1, codes.Unknown: 1}
+	for c, n := range errs {
+		m.errors.WithLabelValues("m", c.String()).Write(metric)
+		if v := metric.GetCounter().GetValue(); v != n {
+			t.Errorf("Expected %g %s errors, got %g", n, c, v)
+		}
+	}
+
+	// Cumulative counts for each bucket upper bound.
+	m.latency.WithLabelValues("m").(prometheus.Histogram).Write(metric)
+
+	expected := []uint64{1, 1, 2, 2, 2, 2, 2, 2, 2, 3, 3}
+	for i, b := range metric.GetHistogram().GetBucket() {
+		if n := b.GetCumulativeCount(); n != expected[i] {
+			t.Errorf("Expected bucket le=%g count %d, got %d", latencyBuckets[i], expected[i], n)
+		}
+	}
+}
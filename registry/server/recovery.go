@@ -0,0 +1,41 @@
+package server
+
+import (
+	"context"
+	"runtime/debug"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	// ErrInternal error.
+	ErrInternal = status.Error(codes.Internal, "internal error")
+)
+
+// recoverPanic is deferred by the recovery interceptors. A panic in the
+// handler is logged along with the stack trace and err is set to
+// ErrInternal so that a single request can't crash the server.
+func (s *Server) recoverPanic(method string, err *error) {
+	if r := recover(); r != nil {
+		loggerOrNop(s.logger).Errorf("panic handling %s: %v\n%s", method, r, debug.Stack())
+		*err = ErrInternal
+	}
+}
+
+// recoveryUnaryInterceptor is a grpc.UnaryServerInterceptor
+// that returns ErrInternal for handlers that panic.
+func (s *Server) recoveryUnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+	defer s.recoverPanic(info.FullMethod, &err)
+
+	return handler(ctx, req)
+}
+
+// recoveryStreamInterceptor is a grpc.StreamServerInterceptor
+// that returns ErrInternal for handlers that panic.
+func (s *Server) recoveryStreamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+	defer s.recoverPanic(info.FullMethod, &err)
+
+	return handler(srv, ss)
+}
@@ -0,0 +1,36 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	pb "github.com/DataDog/kafka-kit/registry/protos"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestRecoveryInterceptors(t *testing.T) {
+	s := testServer()
+
+	panics := func(context.Context, interface{}) (interface{}, error) { panic("handler panic") }
+	info := &grpc.UnaryServerInfo{FullMethod: "/registry.Registry/GetTopics"}
+
+	if _, err := s.recoveryUnaryInterceptor(context.Background(), nil, info, panics); status.Code(err) != codes.Internal {
+		t.Errorf("Expected code %s, got %v", codes.Internal, err)
+	}
+
+	streamPanics := func(interface{}, grpc.ServerStream) error { panic("handler panic") }
+	sinfo := &grpc.StreamServerInfo{FullMethod: "/registry.Registry/WatchBrokers"}
+
+	if err := s.recoveryStreamInterceptor(nil, nil, sinfo, streamPanics); status.Code(err) != codes.Internal {
+		t.Errorf("Expected code %s, got %v", codes.Internal, err)
+	}
+
+	// Handler results are passed through.
+	ok := func(context.Context, interface{}) (interface{}, error) { return &pb.Empty{}, nil }
+	if resp, err := s.recoveryUnaryInterceptor(context.Background(), nil, info, ok); err != nil || resp == nil {
+		t.Errorf("Expected a response, got %v, %v", resp, err)
+	}
+}
@@ -0,0 +1,113 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	rpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// testReflectionClient returns a reflection stream to
+// a gRPC server for s and a func that stops the server.
+func testReflectionClient(t *testing.T, s *Server) (rpb.ServerReflection_ServerReflectionInfoClient, func()) {
+	conn, stop := testGRPCConn(t, s)
+
+	stream, err := rpb.NewServerReflectionClient(conn).ServerReflectionInfo(context.Background())
+	if err != nil {
+		stop()
+		t.Fatal(err)
+	}
+
+	return stream, stop
+}
+
+func TestReflection(t *testing.T) {
+	s := testServer()
+	s.reflection = true
+
+	stream, stop := testReflectionClient(t, s)
+	defer stop()
+
+	// List services.
+	err := stream.Send(&rpb.ServerReflectionRequest{
+		MessageRequest: &rpb.ServerReflectionRequest_ListServices{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var found bool
+	for _, svc := range resp.GetListServicesResponse().GetService() {
+		if svc.Name == "registry.Registry" {
+			found = true
+		}
+	}
+
+	if !found {
+		t.Fatalf("Expected service registry.Registry, got %v", resp.GetListServicesResponse().GetService())
+	}
+
+	// List the registry.Registry methods.
+	err = stream.Send(&rpb.ServerReflectionRequest{
+		MessageRequest: &rpb.ServerReflectionRequest_FileContainingSymbol{
+			FileContainingSymbol: "registry.Registry",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err = stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	methods := map[string]bool{}
+	for _, b := range resp.GetFileDescriptorResponse().GetFileDescriptorProto() {
+		fd := &descriptorpb.FileDescriptorProto{}
+		if err := proto.Unmarshal(b, fd); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, svc := range fd.GetService() {
+			if svc.GetName() != "Registry" {
+				continue
+			}
+			for _, m := range svc.GetMethod() {
+				methods[m.GetName()] = true
+			}
+		}
+	}
+
+	for _, m := range []string{"GetBrokers", "ListBrokers", "GetTopics", "ListTopics", "TagTopic"} {
+		if !methods[m] {
+			t.Errorf("Expected method %s, got %v", m, methods)
+		}
+	}
+}
+
+func TestReflectionDisabled(t *testing.T) {
+	s := testServer()
+
+	stream, stop := testReflectionClient(t, s)
+	defer stop()
+
+	err := stream.Send(&rpb.ServerReflectionRequest{
+		MessageRequest: &rpb.ServerReflectionRequest_ListServices{},
+	})
+
+	if err == nil {
+		_, err = stream.Recv()
+	}
+
+	if err == nil {
+		t.Error("Expected error")
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"log"
 	"net"
 	"net/http"
+	"os"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -16,8 +17,9 @@ import (
 
 	"github.com/grpc-ecosystem/grpc-gateway/runtime"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/metadata"
-	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
 )
 
 const (
@@ -29,22 +31,55 @@ const (
 type Server struct {
 	HTTPListen       string
 	GRPCListen       string
+	MetricsListen    string
 	ZK               kafkazk.Handler
 	Tags             *TagHandler
 	readReqThrottle  RequestThrottle
 	writeReqThrottle RequestThrottle
-	reqID            uint64
+	zkRetryAttempts  int
+	zkRetryBackoff   time.Duration
+	health           *health.Server
+	metrics          *rpcMetrics
+	limiter          *concurrencyLimiter
+	auth             *authenticator
+	brokerHistory    *brokerHistory
+	events           *eventFeed
+	logger           Logger
+	reflection       bool
+	// warm is true once metadata has been
+	// fetched for readiness health checks.
+	warm  bool
+	reqID uint64
 	// For tests.
 	test bool
 }
 
 // Config holds Server configurations.
 type Config struct {
-	HTTPListen   string
-	GRPCListen   string
-	ReadReqRate  int
-	WriteReqRate int
-	ZKTagsPrefix string
+	HTTPListen    string
+	GRPCListen    string
+	MetricsListen string
+	ReadReqRate   int
+	WriteReqRate  int
+	ZKTagsPrefix  string
+	// ZKRetryAttempts is the maximum number of attempts for ZooKeeper
+	// reads that fail with a connection error. ZKRetryBackoff is the
+	// wait before the first retry and is doubled on each subsequent retry.
+	ZKRetryAttempts int
+	ZKRetryBackoff  time.Duration
+	// ConcurrencyLimits caps in-flight requests per RPC method.
+	ConcurrencyLimits ConcurrencyLimits
+	// Logger receives all server logs, including an access log line for
+	// each RPC. If nil, logs are written to stderr at LogInfo and above.
+	Logger Logger
+	// Reflection registers the gRPC server reflection service, allowing
+	// clients such as grpcurl to discover the Registry service.
+	Reflection bool
+	// Authorizer authorizes calls to the methods restricted by AuthPolicy,
+	// using a bearer token from the request metadata. If nil, all calls are
+	// permitted. AuthPolicy defaults to DefaultAuthPolicy if unset.
+	Authorizer Authorizer
+	AuthPolicy AuthPolicy
 
 	test bool
 }
@@ -57,9 +92,23 @@ func NewServer(c Config) (*Server, error) {
 	case c.ReadReqRate < 1:
 		fallthrough
 	case c.WriteReqRate < 1:
+		fallthrough
+	case c.ZKRetryAttempts < 0, c.ZKRetryBackoff < 0:
 		return nil, errors.New("invalid configuration parameter(s)")
 	}
 
+	if err := c.ConcurrencyLimits.validate(); err != nil {
+		return nil, err
+	}
+
+	if c.AuthPolicy == nil {
+		c.AuthPolicy = DefaultAuthPolicy()
+	}
+
+	if err := c.AuthPolicy.validate(); err != nil {
+		return nil, err
+	}
+
 	rrt, _ := NewRequestThrottle(RequestThrottleConfig{
 		Capacity: 10,
 		Rate:     c.ReadReqRate,
@@ -79,14 +128,37 @@ func NewServer(c Config) (*Server, error) {
 		th.Store = newzkTagStorageMock()
 	}
 
-	return &Server{
+	logger := c.Logger
+	switch {
+	case logger != nil:
+	case c.test:
+		logger = nopLogger{}
+	default:
+		logger = NewStdLogger(log.New(os.Stderr, "", log.LstdFlags), LogInfo)
+	}
+
+	s := &Server{
 		HTTPListen:       c.HTTPListen,
 		GRPCListen:       c.GRPCListen,
+		MetricsListen:    c.MetricsListen,
 		Tags:             th,
 		readReqThrottle:  rrt,
 		writeReqThrottle: wrt,
+		zkRetryAttempts:  c.ZKRetryAttempts,
+		zkRetryBackoff:   c.ZKRetryBackoff,
+		health:           newHealthServer(),
+		metrics:          newRPCMetrics(),
+		limiter:          newConcurrencyLimiter(c.ConcurrencyLimits),
+		auth:             newAuthenticator(c.Authorizer, c.AuthPolicy),
+		brokerHistory:    newBrokerHistory(brokerReplaySize),
+		logger:           logger,
+		reflection:       c.Reflection,
 		test:             c.test,
-	}, nil
+	}
+
+	s.events = newEventFeed(s.clusterSnapshot, logger)
+
+	return s, nil
 }
 
 // Run* methods take a Context for cancellation and WaitGroup
@@ -105,18 +177,17 @@ func (s *Server) RunRPC(ctx context.Context, wg *sync.WaitGroup) error {
 		return err
 	}
 
-	srvr := grpc.NewServer()
-	pb.RegisterRegistryServer(srvr, s)
+	srvr := s.newGRPCServer()
 
 	// Shutdown procedure.
 	go func() {
 		<-ctx.Done()
-		log.Println("Shutting down gRPC listener")
+		s.logger.Infof("Shutting down gRPC listener")
 
 		srvr.GracefulStop()
 
 		if err := l.Close(); err != nil {
-			log.Println(err)
+			s.logger.Errorf("%s", err)
 		}
 
 		wg.Done()
@@ -124,15 +195,49 @@ func (s *Server) RunRPC(ctx context.Context, wg *sync.WaitGroup) error {
 
 	// Background the listener.
 	go func() {
-		log.Printf("gRPC up: %s\n", s.GRPCListen)
+		s.logger.Infof("gRPC up: %s", s.GRPCListen)
 		if err := srvr.Serve(l); err != nil {
-			log.Println(err)
+			s.logger.Errorf("%s", err)
 		}
 	}()
 
 	return nil
 }
 
+// newGRPCServer returns a *grpc.Server with the Registry and Health
+// services registered and all RPCs access logged, instrumented, recovered from
+// panics, authorized, validated and subject to any concurrency limits. The
+// reflection service is registered if enabled in the Config.
+func (s *Server) newGRPCServer() *grpc.Server {
+	srvr := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(
+			s.accessLogUnaryInterceptor,
+			s.metrics.unaryInterceptor,
+			s.recoveryUnaryInterceptor,
+			s.auth.unaryInterceptor,
+			validationUnaryInterceptor,
+			s.limiter.unaryInterceptor,
+		),
+		grpc.ChainStreamInterceptor(
+			s.accessLogStreamInterceptor,
+			s.metrics.streamInterceptor,
+			s.recoveryStreamInterceptor,
+			s.auth.streamInterceptor,
+			validationStreamInterceptor,
+			s.limiter.streamInterceptor,
+		),
+	)
+
+	pb.RegisterRegistryServer(srvr, s)
+	healthpb.RegisterHealthServer(srvr, s.health)
+
+	if s.reflection {
+		reflection.Register(srvr)
+	}
+
+	return srvr
+}
+
 // RunHTTP runs the HTTP endpoint.
 func (s *Server) RunHTTP(ctx context.Context, wg *sync.WaitGroup) error {
 	wg.Add(1)
@@ -153,10 +258,53 @@ func (s *Server) RunHTTP(ctx context.Context, wg *sync.WaitGroup) error {
 	// Shutdown procedure.
 	go func() {
 		<-ctx.Done()
-		log.Println("Shutting down HTTP listener")
+		s.logger.Infof("Shutting down HTTP listener")
+
+		if err := srvr.Shutdown(ctx); err != nil {
+			s.logger.Errorf("%s", err)
+		}
+
+		wg.Done()
+	}()
+
+	// Background the listener.
+	go func() {
+		s.logger.Infof("HTTP up: %s", s.HTTPListen)
+		if err := srvr.ListenAndServe(); err != http.ErrServerClosed {
+			s.logger.Errorf("%s", err)
+		}
+	}()
+
+	return nil
+}
+
+// RunMetrics runs the Prometheus metrics endpoint at /metrics, along with
+// a background refresh of the broker and topic count gauges. It's a no-op
+// if MetricsListen is unset. DialZK should be called first.
+func (s *Server) RunMetrics(ctx context.Context, wg *sync.WaitGroup) error {
+	if s.MetricsListen == "" {
+		return nil
+	}
+
+	wg.Add(1)
+
+	go s.runMetricsRefresh(ctx, metricsRefreshInterval)
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", s.metricsHandler())
+
+	srvr := &http.Server{
+		Addr:    s.MetricsListen,
+		Handler: mux,
+	}
+
+	// Shutdown procedure.
+	go func() {
+		<-ctx.Done()
+		s.logger.Infof("Shutting down metrics listener")
 
 		if err := srvr.Shutdown(ctx); err != nil {
-			log.Println(err)
+			s.logger.Errorf("%s", err)
 		}
 
 		wg.Done()
@@ -164,9 +312,9 @@ func (s *Server) RunHTTP(ctx context.Context, wg *sync.WaitGroup) error {
 
 	// Background the listener.
 	go func() {
-		log.Printf("HTTP up: %s\n", s.HTTPListen)
+		s.logger.Infof("Metrics up: %s", s.MetricsListen)
 		if err := srvr.ListenAndServe(); err != http.ErrServerClosed {
-			log.Println(err)
+			s.logger.Errorf("%s", err)
 		}
 	}()
 
@@ -185,11 +333,13 @@ func (s *Server) DialZK(ctx context.Context, wg *sync.WaitGroup, c *kafkazk.Conf
 	wg.Add(1)
 
 	// Init.
-	zk, err := kafkazk.NewHandler(c)
+	h, err := kafkazk.NewHandler(c)
 	if err != nil {
 		return err
 	}
 
+	// Reads are retried on transient connection errors.
+	zk := newZKRetryHandler(ctx, h, s.zkRetryAttempts, s.zkRetryBackoff)
 	s.ZK = zk
 
 	// Test readiness.
@@ -200,7 +350,7 @@ func (s *Server) DialZK(ctx context.Context, wg *sync.WaitGroup, c *kafkazk.Conf
 		return fmt.Errorf("failed to dial ZooKeeper in %s", zkReadyWait)
 	}
 
-	log.Printf("Connected to ZooKeeper: %s\n", c.Connect)
+	s.logger.Infof("Connected to ZooKeeper: %s", c.Connect)
 
 	// Pass the Handler to the underlying TagHandler Store
 	// and call the Init procedure.
@@ -210,6 +360,14 @@ func (s *Server) DialZK(ctx context.Context, wg *sync.WaitGroup, c *kafkazk.Conf
 		return fmt.Errorf("failed to initialize ZooKeeper TagStorage backend")
 	}
 
+	// Report health according to
+	// the ZooKeeper session state.
+	go s.runHealthChecks(ctx, healthCheckInterval)
+
+	// Record broker membership
+	// changes for replay.
+	go s.runBrokerHistory(ctx)
+
 	// Shutdown procedure.
 	go func() {
 		<-ctx.Done()
@@ -242,7 +400,7 @@ func (s *Server) ValidateRequest(ctx context.Context, req interface{}, kind int)
 	var err error
 	defer func() {
 		if err != nil {
-			log.Printf("[request %d] timed out", reqID)
+			s.logger.Warnf("[request %d] timed out", reqID)
 		}
 	}()
 
@@ -262,37 +420,21 @@ func (s *Server) ValidateRequest(ctx context.Context, req interface{}, kind int)
 }
 
 // LogRequest takes a request context and input parameters as a string
-// and logs the request data.
+// and logs the request data at LogDebug.
 func (s *Server) LogRequest(ctx context.Context, params string, reqID uint64) {
 	if s.test {
 		return
 	}
 
-	var requestor string
-	var reqType string
-	var method string
-
 	if params == "" {
 		params = "none"
 	}
 
-	// Get Peer info.
-	if p, ok := peer.FromContext(ctx); ok {
-		requestor = p.Addr.String()
-	}
-
-	// Get Metadata.
-	if md, ok := metadata.FromIncomingContext(ctx); ok {
-		if _, ok := md["grpcgateway-user-agent"]; ok {
-			reqType = "http"
-		} else {
-			reqType = "grpc"
-		}
-	}
+	requestor, reqType := requestSource(ctx)
 
 	// Get the gRPC method.
-	method, _ = grpc.Method(ctx)
+	method, _ := grpc.Method(ctx)
 
-	log.Printf("[request %d] requestor:%s type:%s method:%s params:%s",
+	s.logger.Debugf("[request %d] requestor:%s type:%s method:%s params:%s",
 		reqID, requestor, reqType, method, params)
 }
@@ -42,6 +42,7 @@ type TagStorage interface {
 	SetTags(KafkaObject, TagSet) error
 	GetTags(KafkaObject) (TagSet, error)
 	DeleteTags(KafkaObject, Tags) error
+	DeleteObject(KafkaObject) error
 }
 
 // NewTagHandler initializes a TagHandler.
@@ -152,16 +153,16 @@ func (t *TagHandler) TagSetFromObject(o interface{}) (TagSet, error) {
 	return ts, nil
 }
 
-// FilterTopics takes a map of topic names to *pb.Topic and tags KV list.
-// A filtered map is returned that includes topics where all tags
-// values match the provided input tag KVs. Additionally, any custom
+// FilterTopics takes a map of topic names to *pb.Topic and a list of tag
+// predicates (see Tags.predicates). A filtered map is returned that includes
+// topics satisfying all predicates. Additionally, any custom
 // tags persisted in the TagStorage backend are populated into the
 // Tags field for each matched object.
 func (t *TagHandler) FilterTopics(in TopicSet, tags Tags) (TopicSet, error) {
 	var out = make(TopicSet)
 
-	// Get tag key/values.
-	tagKV, err := tags.TagSet()
+	// Get tag predicates.
+	preds, err := tags.predicates()
 	if err != nil {
 		return nil, err
 	}
@@ -173,7 +174,7 @@ func (t *TagHandler) FilterTopics(in TopicSet, tags Tags) (TopicSet, error) {
 			return nil, err
 		}
 
-		if ts.matchAll(tagKV) {
+		if ts.matchPredicates(preds) {
 			out[name] = topic
 
 			// Ensure that custom tags fetched from storage are
@@ -194,16 +195,16 @@ func (t *TagHandler) FilterTopics(in TopicSet, tags Tags) (TopicSet, error) {
 	return out, nil
 }
 
-// FilterBrokers takes a map of broker IDs to *pb.Broker and tags KV list.
-// A filtered map is returned that includes brokers where all tags
-// values match the provided input tag KVs. Additionally, any custom
+// FilterBrokers takes a map of broker IDs to *pb.Broker and a list of tag
+// predicates (see Tags.predicates). A filtered map is returned that includes
+// brokers satisfying all predicates. Additionally, any custom
 // tags persisted in the TagStorage backend are populated into the
 // Tags field for each matched object.
 func (t *TagHandler) FilterBrokers(in BrokerSet, tags Tags) (BrokerSet, error) {
 	var out = make(BrokerSet)
 
-	// Get tag key/values.
-	tagKV, err := tags.TagSet()
+	// Get tag predicates.
+	preds, err := tags.predicates()
 	if err != nil {
 		return nil, err
 	}
@@ -215,7 +216,7 @@ func (t *TagHandler) FilterBrokers(in BrokerSet, tags Tags) (BrokerSet, error) {
 			return nil, err
 		}
 
-		if ts.matchAll(tagKV) {
+		if ts.matchPredicates(preds) {
 			out[id] = broker
 
 			// Ensure that custom tags fetched from storage are
@@ -236,12 +237,11 @@ func (t *TagHandler) FilterBrokers(in BrokerSet, tags Tags) (BrokerSet, error) {
 	return out, nil
 }
 
-// matchAll takes a TagSet and returns true
-// if all key/values are present and equal
-// to those in the input TagSet.
-func (t TagSet) matchAll(kv TagSet) bool {
-	for k, v := range kv {
-		if t[k] != v {
+// matchPredicates takes a []tagPredicate and
+// returns true if the TagSet satisfies all.
+func (t TagSet) matchPredicates(ps []tagPredicate) bool {
+	for _, p := range ps {
+		if !p.match(t) {
 			return false
 		}
 	}
@@ -284,6 +284,48 @@ func (t Tags) TagSet() (TagSet, error) {
 	return ts, nil
 }
 
+// tagPredicate is a tag filter. A predicate with a value
+// matches a TagSet where the key is set to the value; a
+// key only predicate matches where the key has any value.
+type tagPredicate struct {
+	key     string
+	value   string
+	keyOnly bool
+}
+
+// match returns whether the TagSet satisfies the tagPredicate.
+func (p tagPredicate) match(ts TagSet) bool {
+	v, exists := ts[p.key]
+	if p.keyOnly {
+		return exists && v != ""
+	}
+
+	return exists && v == p.value
+}
+
+// predicates takes tags and returns a []tagPredicate and error for any
+// malformed tags. Each tag is either a "key:value" pair, matching objects
+// where the key is set to the value, or a bare "key", matching objects
+// where the key is set to any value.
+func (t Tags) predicates() ([]tagPredicate, error) {
+	var ps []tagPredicate
+
+	for _, tag := range t {
+		kv := strings.Split(tag, ":")
+
+		switch {
+		case kv[0] == "", len(kv) > 2:
+			return nil, fmt.Errorf("invalid tag '%s': must be formatted as key or key:value", tag)
+		case len(kv) == 1:
+			ps = append(ps, tagPredicate{key: kv[0], keyOnly: true})
+		default:
+			ps = append(ps, tagPredicate{key: kv[0], value: kv[1]})
+		}
+	}
+
+	return ps, nil
+}
+
 // ReservedFields is a mapping of object types (topic, broker)
 // to a set of fields reserved for internal use; these are
 // default fields that become searchable through the tags interface.
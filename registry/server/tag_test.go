@@ -33,32 +33,6 @@ func TestTagSetFromObject(t *testing.T) {
 	}
 }
 
-func TestMatchAll(t *testing.T) {
-	ts := TagSet{
-		"k1": "v1",
-		"k2": "v2",
-	}
-
-	tSets := map[int]TagSet{
-		1: TagSet{"k1": "v1"},
-		2: TagSet{"k1": "v1", "k2": "v2"},
-		3: TagSet{"k1": "v1", "k2": "v2", "unrelated": "v3"},
-	}
-
-	tests := map[int]bool{
-		1: false,
-		2: true,
-		3: true,
-	}
-
-	for i, expected := range tests {
-		ts2 := tSets[i]
-		if ok := ts2.matchAll(ts); ok != expected {
-			t.Errorf("Expected TagSet %v matchAll=%v with %v", ts2, expected, ts)
-		}
-	}
-}
-
 func TestEqual(t *testing.T) {
 	tests := map[int][2]TagSet{
 		0: [2]TagSet{
@@ -114,6 +88,84 @@ func TestTagSet(t *testing.T) {
 	}
 }
 
+func TestTagPredicates(t *testing.T) {
+	tags := Tags{"k1:v1", "k2", "k3:"}
+
+	ps, err := tags.predicates()
+	if err != nil {
+		t.Errorf("Unexpected error: %s", err)
+	}
+
+	expected := []tagPredicate{
+		tagPredicate{key: "k1", value: "v1"},
+		tagPredicate{key: "k2", keyOnly: true},
+		tagPredicate{key: "k3", value: ""},
+	}
+
+	if len(ps) != len(expected) {
+		t.Fatalf("Expected %d predicates, got %d", len(expected), len(ps))
+	}
+
+	for i := range expected {
+		if ps[i] != expected[i] {
+			t.Errorf("Expected predicate %+v, got %+v", expected[i], ps[i])
+		}
+	}
+
+	// Malformed tags.
+	for _, tag := range []string{"", ":v1", "k1:v1:v2"} {
+		if _, err := (Tags{tag}).predicates(); err == nil {
+			t.Errorf("Expected error for tag '%s'", tag)
+		}
+	}
+}
+
+func TestMatchPredicates(t *testing.T) {
+	ts := TagSet{
+		"k1": "v1",
+		"k2": "v2",
+		"k3": "",
+	}
+
+	tests := map[int]Tags{
+		0: Tags{},
+		1: Tags{"k1:v1"},
+		2: Tags{"k1:v1", "k2:v2"},
+		3: Tags{"k1"},
+		4: Tags{"k1", "k2:v2"},
+		// Value mismatch.
+		5: Tags{"k1:v2"},
+		6: Tags{"k1:v1", "k2:v1"},
+		// Missing key.
+		7: Tags{"k4"},
+		8: Tags{"k1:v1", "k4:v4"},
+		// Key set to an empty value.
+		9:  Tags{"k3"},
+		10: Tags{"k3:"},
+	}
+
+	expected := map[int]bool{
+		0:  true,
+		1:  true,
+		2:  true,
+		3:  true,
+		4:  true,
+		5:  false,
+		6:  false,
+		7:  false,
+		8:  false,
+		9:  false,
+		10: true,
+	}
+
+	for i, tags := range tests {
+		ps, _ := tags.predicates()
+		if ok := ts.matchPredicates(ps); ok != expected[i] {
+			t.Errorf("[test %d] Expected TagSet %v matchPredicates=%v with %v", i, ts, expected[i], tags)
+		}
+	}
+}
+
 func TestValid(t *testing.T) {
 	tests := map[int]string{
 		0: "broker",
@@ -261,6 +313,7 @@ func TestReservedFields(t *testing.T) {
 		"name":        struct{}{},
 		"partitions":  struct{}{},
 		"replication": struct{}{},
+		"assignments": struct{}{},
 	}
 
 	brokerExpected := map[string]struct{}{
@@ -274,6 +327,8 @@ func TestReservedFields(t *testing.T) {
 		"host":                        struct{}{},
 		"port":                        struct{}{},
 		"version":                     struct{}{},
+		"storagefree":                 struct{}{},
+		"metricsincomplete":           struct{}{},
 	}
 
 	for i, expected := range []map[string]struct{}{topicExpected, brokerExpected} {
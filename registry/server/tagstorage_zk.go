@@ -214,6 +214,27 @@ func (t *ZKTagStorage) DeleteTags(o KafkaObject, ts Tags) error {
 	return t.ZK.Set(znode, string(out))
 }
 
+// DeleteObject deletes all tags for the requested KafkaObject.
+func (t *ZKTagStorage) DeleteObject(o KafkaObject) error {
+	// Sanity checks.
+	if !o.Complete() {
+		return ErrInvalidKafkaObjectType
+	}
+
+	znode := fmt.Sprintf("/%s/%s/%s", t.Prefix, o.Type, o.ID)
+
+	exist, err := t.ZK.Exists(znode)
+	if err != nil {
+		return err
+	}
+
+	if !exist {
+		return ErrKafkaObjectDoesNotExist
+	}
+
+	return t.ZK.Delete(znode)
+}
+
 // FieldReserved takes a KafkaObject and field name. A bool
 // is returned that indicates whether the field is reserved
 // for the respective KafkaObject type.
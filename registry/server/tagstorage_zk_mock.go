@@ -85,6 +85,21 @@ func (t *zkTagStorageMock) DeleteTags(o KafkaObject, tl Tags) error {
 	return nil
 }
 
+// DeleteObject mocks DeleteObject.
+func (t *zkTagStorageMock) DeleteObject(o KafkaObject) error {
+	if !o.Complete() {
+		return ErrInvalidKafkaObjectType
+	}
+
+	if _, exist := t.tags[o.Type][o.ID]; !exist {
+		return ErrKafkaObjectDoesNotExist
+	}
+
+	delete(t.tags[o.Type], o.ID)
+
+	return nil
+}
+
 // FieldReserved mocks FieldReserved.
 func (t *zkTagStorageMock) FieldReserved(o KafkaObject, f string) bool {
 	if !o.Valid() {
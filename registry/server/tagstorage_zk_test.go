@@ -279,6 +279,36 @@ func TestDeleteTagsFailures(t *testing.T) {
 	}
 }
 
+func TestDeleteObject(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+
+	o := KafkaObject{Type: "broker", ID: "1099"}
+
+	if err := store.SetTags(o, TagSet{"key": "value"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.DeleteObject(o); err != nil {
+		t.Error(err)
+	}
+
+	// The object should no longer exist.
+	if _, err := store.GetTags(o); err != ErrKafkaObjectDoesNotExist {
+		t.Error("Expected ErrKafkaObjectDoesNotExist error")
+	}
+
+	if err := store.DeleteObject(o); err != ErrKafkaObjectDoesNotExist {
+		t.Error("Expected ErrKafkaObjectDoesNotExist error")
+	}
+
+	// Test invalid object.
+	if err := store.DeleteObject(KafkaObject{Type: "fail"}); err != ErrInvalidKafkaObjectType {
+		t.Error("Expected ErrInvalidKafkaObjectType error")
+	}
+}
+
 // Sort by string length.
 
 type byLen []string
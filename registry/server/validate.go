@@ -0,0 +1,417 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	pb "github.com/DataDog/kafka-kit/registry/protos"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	// topicNameRegex matches legal Kafka topic names.
+	topicNameRegex = regexp.MustCompile(`^[a-zA-Z0-9._-]+$`)
+	// topicNameMaxLen is the maximum Kafka topic name length.
+	topicNameMaxLen = 249
+)
+
+// fieldViolation describes an invalid request field.
+type fieldViolation struct {
+	field       string
+	description string
+}
+
+// requestValidator returns any field violations for a request.
+type requestValidator func(req interface{}) []fieldViolation
+
+// validators maps RPC full method names to request validators. Methods
+// without an entry aren't validated.
+var validators = map[string]requestValidator{
+	// Brokers.
+	"/registry.Registry/GetBrokers":          brokerRules(brokerFilter, brokerSort),
+	"/registry.Registry/ListBrokers":         brokerRules(brokerFilter, brokerSort),
+	"/registry.Registry/WatchBrokers":        brokerRules(brokerFilter),
+	"/registry.Registry/WatchBrokersControl": validateWatchBrokersRequest,
+	"/registry.Registry/BrokerMappings":      brokerRules(brokerID),
+	"/registry.Registry/TagBroker":           brokerRules(brokerID, brokerTagSet),
+	"/registry.Registry/DeleteBrokerTags":    brokerRules(brokerID, brokerTagKeys),
+	"/registry.Registry/RemoveBroker":        brokerRules(brokerID),
+	"/registry.Registry/TagBrokers":          validateBrokerTagsRequest,
+	// Topics.
+	"/registry.Registry/GetTopics":       topicRules(topicPattern, topicFilter),
+	"/registry.Registry/ListTopics":      topicRules(topicPattern, topicFilter),
+	"/registry.Registry/GetTopicConfigs": topicRules(topicPattern, topicFilter),
+	"/registry.Registry/TopicExists":     topicRules(topicName),
+	"/registry.Registry/TopicMappings":   topicRules(topicName),
+	"/registry.Registry/TagTopic":        topicRules(topicName, topicTagSet),
+	"/registry.Registry/DeleteTopicTags": topicRules(topicName, topicTagKeys),
+	"/registry.Registry/DeleteTopic":     topicRules(topicSelector, topicFilter),
+	"/registry.Registry/CreateTopic":     validateCreateTopicRequest,
+	// Cluster.
+	"/registry.Registry/ReassignPartitions": validateReassignRequest,
+}
+
+// validate validates the request for the RPC method, returning an
+// InvalidArgument error describing all field violations, if any.
+func validate(method string, req interface{}) error {
+	v, exists := validators[method]
+	if !exists {
+		return nil
+	}
+
+	return invalidArgument(v(req)...)
+}
+
+// invalidArgument returns an InvalidArgument status error naming each
+// field violation. The violations are attached as BadRequest details.
+// A nil error is returned if no violations are provided.
+func invalidArgument(vs ...fieldViolation) error {
+	if len(vs) == 0 {
+		return nil
+	}
+
+	var msgs []string
+	br := &errdetails.BadRequest{}
+
+	for _, v := range vs {
+		msgs = append(msgs, fmt.Sprintf("%s: %s", v.field, v.description))
+		br.FieldViolations = append(br.FieldViolations, &errdetails.BadRequest_FieldViolation{
+			Field:       v.field,
+			Description: v.description,
+		})
+	}
+
+	st := status.New(codes.InvalidArgument, "invalid request: "+strings.Join(msgs, "; "))
+	if withDetails, err := st.WithDetails(br); err == nil {
+		st = withDetails
+	}
+
+	return st.Err()
+}
+
+// validationUnaryInterceptor is a grpc.UnaryServerInterceptor that
+// validates requests before they're handled.
+func validationUnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if err := validate(info.FullMethod, req); err != nil {
+		return nil, err
+	}
+
+	return handler(ctx, req)
+}
+
+// validationStreamInterceptor is a grpc.StreamServerInterceptor that
+// validates each request received on a stream.
+func validationStreamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return handler(srv, &validatedStream{ServerStream: ss, method: info.FullMethod})
+}
+
+// validatedStream is a grpc.ServerStream that
+// validates received messages.
+type validatedStream struct {
+	grpc.ServerStream
+	method string
+}
+
+// RecvMsg receives and validates a message.
+func (s *validatedStream) RecvMsg(m interface{}) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+
+	return validate(s.method, m)
+}
+
+// brokerRules returns a requestValidator applying
+// each rule to a *pb.BrokerRequest.
+func brokerRules(rules ...func(*pb.BrokerRequest) []fieldViolation) requestValidator {
+	return func(req interface{}) []fieldViolation {
+		var vs []fieldViolation
+		for _, r := range rules {
+			vs = append(vs, r(req.(*pb.BrokerRequest))...)
+		}
+		return vs
+	}
+}
+
+// topicRules returns a requestValidator applying
+// each rule to a *pb.TopicRequest.
+func topicRules(rules ...func(*pb.TopicRequest) []fieldViolation) requestValidator {
+	return func(req interface{}) []fieldViolation {
+		var vs []fieldViolation
+		for _, r := range rules {
+			vs = append(vs, r(req.(*pb.TopicRequest))...)
+		}
+		return vs
+	}
+}
+
+// brokerFilter requires well formed tag predicates and a known state.
+// The REPLACE state requires a target broker list.
+func brokerFilter(req *pb.BrokerRequest) []fieldViolation {
+	vs := tagPredicateViolations(req.Tag)
+
+	switch _, known := pb.BrokerRequest_State_name[int32(req.State)]; {
+	case !known:
+		vs = append(vs, fieldViolation{"state", fmt.Sprintf("unknown state %d", req.State)})
+	case req.State == pb.BrokerRequest_REPLACE && len(req.TargetBrokers) == 0:
+		vs = append(vs, fieldViolation{"target_brokers", "required with the REPLACE state"})
+	}
+
+	return vs
+}
+
+// brokerSort requires a known sort order. Pagination
+// is only supported in the default ID order.
+func brokerSort(req *pb.BrokerRequest) []fieldViolation {
+	if _, known := pb.BrokerRequest_SortBy_name[int32(req.SortBy)]; !known {
+		return []fieldViolation{{"sort_by", fmt.Sprintf("unknown sort order %d", req.SortBy)}}
+	}
+
+	if req.SortBy != pb.BrokerRequest_ID && (req.PageSize > 0 || req.PageToken != "") {
+		return []fieldViolation{{"sort_by", "pagination requires the ID sort order"}}
+	}
+
+	return nil
+}
+
+// brokerID requires a broker ID.
+func brokerID(req *pb.BrokerRequest) []fieldViolation {
+	if req.Id == 0 {
+		return []fieldViolation{{"id", "must be specified"}}
+	}
+
+	return nil
+}
+
+// brokerTagSet requires tags formatted as key:value.
+func brokerTagSet(req *pb.BrokerRequest) []fieldViolation {
+	return tagSetViolations(req.Tag)
+}
+
+// brokerTagKeys requires tags formatted as key names.
+func brokerTagKeys(req *pb.BrokerRequest) []fieldViolation {
+	return tagKeyViolations(req.Tag)
+}
+
+// validateBrokerTagsRequest requires non-zero broker
+// IDs and tags formatted as key:value.
+func validateBrokerTagsRequest(r interface{}) []fieldViolation {
+	req := r.(*pb.BrokerTagsRequest)
+
+	var vs []fieldViolation
+
+	if len(req.Ids) == 0 {
+		vs = append(vs, fieldViolation{"ids", "must be specified"})
+	}
+
+	for _, id := range req.Ids {
+		if id == 0 {
+			vs = append(vs, fieldViolation{"ids", "broker ID 0 is invalid"})
+			break
+		}
+	}
+
+	return append(vs, tagSetViolations(req.Tag)...)
+}
+
+// validateWatchBrokersRequest requires a known control and, if
+// set, a BrokerRequest with well formed tag predicates and state.
+func validateWatchBrokersRequest(r interface{}) []fieldViolation {
+	req := r.(*pb.WatchBrokersRequest)
+
+	var vs []fieldViolation
+
+	if _, known := pb.WatchBrokersRequest_Control_name[int32(req.Control)]; !known {
+		vs = append(vs, fieldViolation{"control", fmt.Sprintf("unknown control %d", req.Control)})
+	}
+
+	if req.Request != nil {
+		for _, v := range brokerFilter(req.Request) {
+			vs = append(vs, fieldViolation{"request." + v.field, v.description})
+		}
+	}
+
+	return vs
+}
+
+// topicFilter requires well formed tag predicates. A "name" tag
+// predicate that can never match the name selector is rejected.
+func topicFilter(req *pb.TopicRequest) []fieldViolation {
+	vs := tagPredicateViolations(req.Tag)
+	if len(vs) > 0 || req.Name == "" {
+		return vs
+	}
+
+	ps, _ := Tags(req.Tag).predicates()
+	for _, p := range ps {
+		if p.key == "name" && !p.keyOnly && p.value != req.Name {
+			vs = append(vs, fieldViolation{"tag", fmt.Sprintf("'name:%s' conflicts with the name selector '%s'", p.value, req.Name)})
+		}
+	}
+
+	return vs
+}
+
+// topicPattern requires a name selector that compiles as a regex.
+func topicPattern(req *pb.TopicRequest) []fieldViolation {
+	if req.Name == "" {
+		return nil
+	}
+
+	if _, err := regexp.Compile(fmt.Sprintf("^%s$", req.Name)); err != nil {
+		return []fieldViolation{{"name", fmt.Sprintf("invalid regex: %s", err)}}
+	}
+
+	return nil
+}
+
+// topicName requires a topic name.
+func topicName(req *pb.TopicRequest) []fieldViolation {
+	if req.Name == "" {
+		return []fieldViolation{{"name", "must be specified"}}
+	}
+
+	return nil
+}
+
+// topicSelector requires a topic name or tags.
+func topicSelector(req *pb.TopicRequest) []fieldViolation {
+	if req.Name == "" && len(req.Tag) == 0 {
+		return []fieldViolation{{"name", "name or tag must be specified"}}
+	}
+
+	return nil
+}
+
+// topicTagSet requires tags formatted as key:value.
+func topicTagSet(req *pb.TopicRequest) []fieldViolation {
+	return tagSetViolations(req.Tag)
+}
+
+// topicTagKeys requires tags formatted as key names.
+func topicTagKeys(req *pb.TopicRequest) []fieldViolation {
+	return tagKeyViolations(req.Tag)
+}
+
+// validateCreateTopicRequest requires a legal topic name. Partitions
+// and replication are required unless an explicit assignment is given.
+func validateCreateTopicRequest(r interface{}) []fieldViolation {
+	req := r.(*pb.CreateTopicRequest)
+
+	if req.Topic == nil {
+		return []fieldViolation{{"topic", "must be specified"}}
+	}
+
+	var vs []fieldViolation
+
+	switch name := req.Topic.Name; {
+	case name == "":
+		vs = append(vs, fieldViolation{"topic.name", "must be specified"})
+	case name == "." || name == "..":
+		vs = append(vs, fieldViolation{"topic.name", fmt.Sprintf("'%s' is not a legal topic name", name)})
+	case len(name) > topicNameMaxLen:
+		vs = append(vs, fieldViolation{"topic.name", fmt.Sprintf("must be at most %d characters", topicNameMaxLen)})
+	case !topicNameRegex.MatchString(name):
+		vs = append(vs, fieldViolation{"topic.name", "may only contain ASCII alphanumerics, '.', '_' and '-'"})
+	}
+
+	if len(req.Assignment) > 0 {
+		return vs
+	}
+
+	if req.Topic.Partitions == 0 {
+		vs = append(vs, fieldViolation{"topic.partitions", "must be greater than 0"})
+	}
+
+	if req.Topic.Replication == 0 {
+		vs = append(vs, fieldViolation{"topic.replication", "must be greater than 0"})
+	}
+
+	return vs
+}
+
+// validateReassignRequest requires topics, brokers and
+// known placement and optimization settings.
+func validateReassignRequest(r interface{}) []fieldViolation {
+	req := r.(*pb.ReassignRequest)
+
+	var vs []fieldViolation
+
+	if len(req.Topics) == 0 {
+		vs = append(vs, fieldViolation{"topics", "must be specified"})
+	}
+
+	if _, err := topicRegexes(req.Topics); err != nil {
+		vs = append(vs, fieldViolation{"topics", err.Error()})
+	}
+
+	if len(req.Brokers) == 0 {
+		vs = append(vs, fieldViolation{"brokers", "must be specified"})
+	}
+
+	switch req.Placement {
+	case "", "count", "storage":
+	default:
+		vs = append(vs, fieldViolation{"placement", "must be either 'count' or 'storage'"})
+	}
+
+	switch req.Optimize {
+	case "", "distribution", "storage":
+	default:
+		vs = append(vs, fieldViolation{"optimize", "must be either 'distribution' or 'storage'"})
+	}
+
+	if req.PartitionSizeFactor < 0 {
+		vs = append(vs, fieldViolation{"partition_size_factor", "must be 0 or greater"})
+	}
+
+	return vs
+}
+
+// tagPredicateViolations requires tags
+// formatted as key or key:value.
+func tagPredicateViolations(t []string) []fieldViolation {
+	if _, err := Tags(t).predicates(); err != nil {
+		return []fieldViolation{{"tag", err.Error()}}
+	}
+
+	return nil
+}
+
+// tagSetViolations requires one or more
+// tags formatted as key:value.
+func tagSetViolations(t []string) []fieldViolation {
+	if len(t) == 0 {
+		return []fieldViolation{{"tag", "must be specified"}}
+	}
+
+	for _, tag := range t {
+		if kv := strings.Split(tag, ":"); len(kv) != 2 || kv[0] == "" {
+			return []fieldViolation{{"tag", fmt.Sprintf("invalid tag '%s': must be formatted as key:value", tag)}}
+		}
+	}
+
+	return nil
+}
+
+// tagKeyViolations requires one or
+// more tags formatted as key names.
+func tagKeyViolations(t []string) []fieldViolation {
+	if len(t) == 0 {
+		return []fieldViolation{{"tag", "must be specified"}}
+	}
+
+	for _, tag := range t {
+		if tag == "" || strings.Contains(tag, ":") {
+			return []fieldViolation{{"tag", fmt.Sprintf("invalid tag '%s': must be a key name", tag)}}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,174 @@
+package server
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	pb "github.com/DataDog/kafka-kit/registry/protos"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestValidate(t *testing.T) {
+	type testCase struct {
+		method string
+		req    interface{}
+		// The expected violated fields; none if the request is valid.
+		fields []string
+	}
+
+	tests := map[int]testCase{
+		// Broker filters.
+		0: {"GetBrokers", &pb.BrokerRequest{Tag: []string{"rack:a", "k"}}, nil},
+		1: {"ListBrokers", &pb.BrokerRequest{Tag: []string{"a:b:c"}}, []string{"tag"}},
+		2: {"WatchBrokers", &pb.BrokerRequest{Tag: []string{":v"}}, []string{"tag"}},
+		3: {"GetBrokers", &pb.BrokerRequest{State: 9}, []string{"state"}},
+		// Broker IDs.
+		4: {"BrokerMappings", &pb.BrokerRequest{}, []string{"id"}},
+		5: {"RemoveBroker", &pb.BrokerRequest{Id: 1001}, nil},
+		// Broker tags.
+		6:  {"TagBroker", &pb.BrokerRequest{Id: 1001, Tag: []string{"k:v"}}, nil},
+		7:  {"TagBroker", &pb.BrokerRequest{Tag: []string{"k"}}, []string{"id", "tag"}},
+		8:  {"DeleteBrokerTags", &pb.BrokerRequest{Id: 1001, Tag: []string{"k:v"}}, []string{"tag"}},
+		9:  {"DeleteBrokerTags", &pb.BrokerRequest{Id: 1001}, []string{"tag"}},
+		10: {"TagBrokers", &pb.BrokerTagsRequest{Ids: []uint32{1001}, Tag: []string{"k:v"}}, nil},
+		11: {"TagBrokers", &pb.BrokerTagsRequest{Ids: []uint32{1001, 0}}, []string{"ids", "tag"}},
+		// Topic filters.
+		12: {"GetTopics", &pb.TopicRequest{Name: "a", Tag: []string{"name:a", "k"}}, nil},
+		13: {"ListTopics", &pb.TopicRequest{Name: "a", Tag: []string{"name:b"}}, []string{"tag"}},
+		14: {"GetTopicConfigs", &pb.TopicRequest{Tag: []string{"a:b:c"}}, []string{"tag"}},
+		15: {"DeleteTopic", &pb.TopicRequest{}, []string{"name"}},
+		// Topic names and tags.
+		16: {"TopicExists", &pb.TopicRequest{}, []string{"name"}},
+		17: {"TopicMappings", &pb.TopicRequest{Name: "a"}, nil},
+		18: {"TagTopic", &pb.TopicRequest{Name: "a", Tag: []string{"k"}}, []string{"tag"}},
+		19: {"DeleteTopicTags", &pb.TopicRequest{Tag: []string{"k"}}, []string{"name"}},
+		// Topic creation.
+		20: {"CreateTopic", &pb.CreateTopicRequest{Topic: &pb.Topic{Name: "a", Partitions: 1, Replication: 1}}, nil},
+		21: {"CreateTopic", &pb.CreateTopicRequest{}, []string{"topic"}},
+		22: {"CreateTopic", &pb.CreateTopicRequest{Topic: &pb.Topic{}}, []string{"topic.name", "topic.partitions", "topic.replication"}},
+		23: {"CreateTopic", &pb.CreateTopicRequest{Topic: &pb.Topic{Name: "a/b", Partitions: 1, Replication: 1}}, []string{"topic.name"}},
+		24: {"CreateTopic", &pb.CreateTopicRequest{Topic: &pb.Topic{Name: "..", Partitions: 1, Replication: 1}}, []string{"topic.name"}},
+		25: {"CreateTopic", &pb.CreateTopicRequest{Topic: &pb.Topic{Name: strings.Repeat("a", 250), Partitions: 1, Replication: 1}}, []string{"topic.name"}},
+		26: {"CreateTopic", &pb.CreateTopicRequest{
+			Topic:      &pb.Topic{Name: "a"},
+			Assignment: []*pb.PartitionAssignment{{Partition: 0, Replicas: []uint32{1001}}},
+		}, nil},
+		// Reassignment.
+		27: {"ReassignPartitions", &pb.ReassignRequest{Topics: []string{"a"}, Brokers: []uint32{1001}}, nil},
+		28: {"ReassignPartitions", &pb.ReassignRequest{Topics: []string{"a["}}, []string{"topics", "brokers"}},
+		29: {"ReassignPartitions", &pb.ReassignRequest{
+			Topics:              []string{"a"},
+			Brokers:             []uint32{1001},
+			Placement:           "random",
+			Optimize:            "random",
+			PartitionSizeFactor: -1,
+		}, []string{"placement", "optimize", "partition_size_factor"}},
+		// Unvalidated methods.
+		30: {"WatchEvents", &pb.Empty{}, nil},
+		// Watch controls.
+		31: {"WatchBrokersControl", &pb.WatchBrokersRequest{Control: pb.WatchBrokersRequest_PAUSE}, nil},
+		32: {"WatchBrokersControl", &pb.WatchBrokersRequest{Request: &pb.BrokerRequest{Tag: []string{":v"}}, Control: 9}, []string{"control", "request.tag"}},
+		// Broker sort orders.
+		33: {"ListBrokers", &pb.BrokerRequest{SortBy: pb.BrokerRequest_USED}, nil},
+		34: {"ListBrokers", &pb.BrokerRequest{SortBy: 9}, []string{"sort_by"}},
+		35: {"GetBrokers", &pb.BrokerRequest{SortBy: pb.BrokerRequest_STORAGE_FREE, PageSize: 2}, []string{"sort_by"}},
+		36: {"ListBrokers", &pb.BrokerRequest{SortBy: pb.BrokerRequest_ID, PageSize: 2}, nil},
+		// Topic name regex.
+		37: {"GetTopics", &pb.TopicRequest{Name: "test_.*"}, nil},
+		38: {"GetTopics", &pb.TopicRequest{Name: "("}, []string{"name"}},
+		39: {"ListTopics", &pb.TopicRequest{Name: "a[", Tag: []string{"k"}}, []string{"name"}},
+		// DeleteTopic names are literal.
+		40: {"DeleteTopic", &pb.TopicRequest{Name: "("}, nil},
+		// The REPLACE state requires a target broker list.
+		41: {"GetBrokers", &pb.BrokerRequest{State: pb.BrokerRequest_REPLACE}, []string{"target_brokers"}},
+		42: {"ListBrokers", &pb.BrokerRequest{State: pb.BrokerRequest_REPLACE, TargetBrokers: []uint32{1001}}, nil},
+	}
+
+	for i := 0; i < len(tests); i++ {
+		test := tests[i]
+		err := validate("/registry.Registry/"+test.method, test.req)
+
+		if len(test.fields) == 0 {
+			if err != nil {
+				t.Errorf("[test %d] Unexpected error: %s", i, err)
+			}
+			continue
+		}
+
+		if status.Code(err) != codes.InvalidArgument {
+			t.Errorf("[test %d] Expected InvalidArgument error, got %v", i, err)
+			continue
+		}
+
+		if fields := violatedFields(err); !stringsEqual(fields, test.fields) {
+			t.Errorf("[test %d] Expected violated fields %v, got %v", i, test.fields, fields)
+		}
+	}
+}
+
+func TestValidationInterceptors(t *testing.T) {
+	s := testServer()
+
+	conn, stop := testGRPCConn(t, s)
+	defer stop()
+
+	ctx := context.Background()
+	client := pb.NewRegistryClient(conn)
+
+	// Unary.
+	_, err := client.TagBroker(ctx, &pb.BrokerRequest{Tag: []string{"k:v"}})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("Expected InvalidArgument error, got %v", err)
+	}
+
+	expected := "invalid request: id: must be specified"
+	if msg := status.Convert(err).Message(); msg != expected {
+		t.Errorf("Expected error message '%s', got '%s'", expected, msg)
+	}
+
+	// Streaming.
+	stream, err := client.WatchBrokers(ctx, &pb.BrokerRequest{Tag: []string{"a:b:c"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := stream.Recv(); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Expected InvalidArgument error, got %v", err)
+	}
+}
+
+func TestCreateTopicReplicationExceedsBrokers(t *testing.T) {
+	s := testServer()
+
+	// The mock registers 5 brokers.
+	req := &pb.CreateTopicRequest{Topic: &pb.Topic{Name: "new_topic", Partitions: 6, Replication: 6}}
+	_, err := s.CreateTopic(context.Background(), req)
+
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("Expected InvalidArgument error, got %v", err)
+	}
+
+	if fields := violatedFields(err); !stringsEqual(fields, []string{"topic.replication"}) {
+		t.Errorf("Expected violated fields [topic.replication], got %v", fields)
+	}
+}
+
+// violatedFields returns the fields of all
+// BadRequest field violations in the error.
+func violatedFields(err error) []string {
+	var fields []string
+
+	for _, d := range status.Convert(err).Details() {
+		if br, ok := d.(*errdetails.BadRequest); ok {
+			for _, v := range br.FieldViolations {
+				fields = append(fields, v.Field)
+			}
+		}
+	}
+
+	return fields
+}
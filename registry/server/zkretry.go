@@ -0,0 +1,188 @@
+package server
+
+import (
+	"context"
+	"regexp"
+	"time"
+
+	"github.com/DataDog/kafka-kit/kafkazk"
+)
+
+// zkRetryHandler wraps a kafkazk.Handler and retries read calls that fail
+// with a ZooKeeper connection error (see kafkazk.IsConnectionError), waiting
+// an exponentially increasing backoff between attempts. Writes are passed
+// through as-is since they may have been applied before the connection loss.
+// Retries stop once the context is done.
+type zkRetryHandler struct {
+	kafkazk.Handler
+	ctx      context.Context
+	attempts int
+	backoff  time.Duration
+}
+
+// newZKRetryHandler returns a kafkazk.Handler that retries reads up to
+// attempts times, or until the context is done. The input Handler is
+// returned if attempts is less than 2.
+func newZKRetryHandler(ctx context.Context, zk kafkazk.Handler, attempts int, backoff time.Duration) kafkazk.Handler {
+	if attempts < 2 {
+		return zk
+	}
+
+	return &zkRetryHandler{
+		Handler:  zk,
+		ctx:      ctx,
+		attempts: attempts,
+		backoff:  backoff,
+	}
+}
+
+// retry calls f until it returns a non-connection error or the attempts
+// are exhausted. The last error returned by f is returned. If the context
+// is done while waiting to retry, the context error is returned.
+func (zk *zkRetryHandler) retry(ctx context.Context, f func() error) error {
+	var err error
+	wait := zk.backoff
+
+	for i := 0; i < zk.attempts; i++ {
+		if err = f(); !kafkazk.IsConnectionError(err) {
+			return err
+		}
+
+		if i < zk.attempts-1 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(wait):
+			}
+			wait *= 2
+		}
+	}
+
+	return err
+}
+
+// Exists calls Exists with retries.
+func (zk *zkRetryHandler) Exists(p string) (bool, error) {
+	var ok bool
+	err := zk.retry(zk.ctx, func() error {
+		var err error
+		ok, err = zk.Handler.Exists(p)
+		return err
+	})
+
+	return ok, err
+}
+
+// Get calls Get with retries.
+func (zk *zkRetryHandler) Get(p string) ([]byte, error) {
+	var b []byte
+	err := zk.retry(zk.ctx, func() error {
+		var err error
+		b, err = zk.Handler.Get(p)
+		return err
+	})
+
+	return b, err
+}
+
+// Children calls Children with retries.
+func (zk *zkRetryHandler) Children(p string) ([]string, error) {
+	var c []string
+	err := zk.retry(zk.ctx, func() error {
+		var err error
+		c, err = zk.Handler.Children(p)
+		return err
+	})
+
+	return c, err
+}
+
+// GetTopics calls GetTopics with retries.
+func (zk *zkRetryHandler) GetTopics(ts []*regexp.Regexp) ([]string, error) {
+	var t []string
+	err := zk.retry(zk.ctx, func() error {
+		var err error
+		t, err = zk.Handler.GetTopics(ts)
+		return err
+	})
+
+	return t, err
+}
+
+// GetTopicState calls GetTopicState with retries.
+func (zk *zkRetryHandler) GetTopicState(t string) (*kafkazk.TopicState, error) {
+	var ts *kafkazk.TopicState
+	err := zk.retry(zk.ctx, func() error {
+		var err error
+		ts, err = zk.Handler.GetTopicState(t)
+		return err
+	})
+
+	return ts, err
+}
+
+// GetTopicConfig calls GetTopicConfig with retries.
+func (zk *zkRetryHandler) GetTopicConfig(t string) (*kafkazk.TopicConfig, error) {
+	var tc *kafkazk.TopicConfig
+	err := zk.retry(zk.ctx, func() error {
+		var err error
+		tc, err = zk.Handler.GetTopicConfig(t)
+		return err
+	})
+
+	return tc, err
+}
+
+// GetPartitionMap calls GetPartitionMap with retries.
+func (zk *zkRetryHandler) GetPartitionMap(t string) (*kafkazk.PartitionMap, error) {
+	var pm *kafkazk.PartitionMap
+	err := zk.retry(zk.ctx, func() error {
+		var err error
+		pm, err = zk.Handler.GetPartitionMap(t)
+		return err
+	})
+
+	return pm, err
+}
+
+// GetAllPartitionMeta calls GetAllPartitionMeta with retries.
+func (zk *zkRetryHandler) GetAllPartitionMeta() (kafkazk.PartitionMetaMap, error) {
+	var pm kafkazk.PartitionMetaMap
+	err := zk.retry(zk.ctx, func() error {
+		var err error
+		pm, err = zk.Handler.GetAllPartitionMeta()
+		return err
+	})
+
+	return pm, err
+}
+
+// GetBrokerMetrics calls GetBrokerMetrics with retries.
+func (zk *zkRetryHandler) GetBrokerMetrics() (kafkazk.BrokerMetricsMap, error) {
+	var bm kafkazk.BrokerMetricsMap
+	err := zk.retry(zk.ctx, func() error {
+		var err error
+		bm, err = zk.Handler.GetBrokerMetrics()
+		return err
+	})
+
+	return bm, err
+}
+
+// GetAllBrokerMeta calls GetAllBrokerMeta with retries. The call is
+// retried if any of the returned errors is a connection error.
+func (zk *zkRetryHandler) GetAllBrokerMeta(withMetrics bool) (kafkazk.BrokerMetaMap, []error) {
+	var bm kafkazk.BrokerMetaMap
+	var errs []error
+	zk.retry(zk.ctx, func() error {
+		bm, errs = zk.Handler.GetAllBrokerMeta(withMetrics)
+		for _, err := range errs {
+			if kafkazk.IsConnectionError(err) {
+				return err
+			}
+		}
+		return nil
+	})
+
+	return bm, errs
+}
@@ -0,0 +1,176 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DataDog/kafka-kit/kafkazk"
+	pb "github.com/DataDog/kafka-kit/registry/protos"
+
+	zkclient "github.com/samuel/go-zookeeper/zk"
+)
+
+// flakyZK is a kafkazk.Mock that fails the first
+// failures calls to each read with err.
+type flakyZK struct {
+	kafkazk.Mock
+	failures int
+	err      error
+	calls    int
+}
+
+func (zk *flakyZK) fail() error {
+	zk.calls++
+	if zk.calls <= zk.failures {
+		return zk.err
+	}
+	return nil
+}
+
+func (zk *flakyZK) GetTopics(ts []*regexp.Regexp) ([]string, error) {
+	if err := zk.fail(); err != nil {
+		return nil, err
+	}
+	return zk.Mock.GetTopics(ts)
+}
+
+func (zk *flakyZK) GetAllBrokerMeta(withMetrics bool) (kafkazk.BrokerMetaMap, []error) {
+	if err := zk.fail(); err != nil {
+		return nil, []error{err}
+	}
+	return zk.Mock.GetAllBrokerMeta(withMetrics)
+}
+
+var errTestConnClosed = fmt.Errorf("[/brokers/topics] %s", zkclient.ErrConnectionClosed)
+
+func TestZKRetryHandler(t *testing.T) {
+	type testCase struct {
+		failures int
+		err      error
+		// Expected.
+		calls  int
+		failed bool
+	}
+
+	tests := map[int]testCase{
+		// Succeeds after retries.
+		0: {failures: 2, err: errTestConnClosed, calls: 3},
+		1: {failures: 1, err: zkclient.ErrSessionExpired, calls: 2},
+		// Retries exhausted.
+		2: {failures: 5, err: errTestConnClosed, calls: 3, failed: true},
+		// Not a connection error; not retried.
+		3: {failures: 2, err: zkclient.ErrNoNode, calls: 1, failed: true},
+	}
+
+	re := []*regexp.Regexp{regexp.MustCompile(".*")}
+
+	for i, test := range tests {
+		f := &flakyZK{failures: test.failures, err: test.err}
+		zk := newZKRetryHandler(context.Background(), f, 3, time.Millisecond)
+
+		topics, err := zk.GetTopics(re)
+
+		if f.calls != test.calls {
+			t.Errorf("[test %d] Expected %d calls, got %d", i, test.calls, f.calls)
+		}
+
+		switch {
+		case test.failed && err != test.err:
+			t.Errorf("[test %d] Expected error '%v', got '%v'", i, test.err, err)
+		case !test.failed && err != nil:
+			t.Errorf("[test %d] Unexpected error: %s", i, err)
+		case !test.failed && len(topics) != 2:
+			t.Errorf("[test %d] Expected 2 topics, got %d", i, len(topics))
+		}
+	}
+}
+
+func TestZKRetryHandlerBrokerMeta(t *testing.T) {
+	f := &flakyZK{failures: 2, err: errTestConnClosed}
+	zk := newZKRetryHandler(context.Background(), f, 3, time.Millisecond)
+
+	bm, errs := zk.GetAllBrokerMeta(false)
+	if errs != nil {
+		t.Fatalf("Unexpected errors: %v", errs)
+	}
+
+	if f.calls != 3 {
+		t.Errorf("Expected 3 calls, got %d", f.calls)
+	}
+
+	if len(bm) != 5 {
+		t.Errorf("Expected 5 brokers, got %d", len(bm))
+	}
+}
+
+func TestZKRetryHandlerBackoff(t *testing.T) {
+	f := &flakyZK{failures: 3, err: errTestConnClosed}
+	zk := newZKRetryHandler(context.Background(), f, 3, 10*time.Millisecond)
+
+	start := time.Now()
+	zk.GetTopics(nil)
+
+	// Two waits: 10ms then 20ms.
+	if elapsed := time.Since(start); elapsed < 30*time.Millisecond {
+		t.Errorf("Expected at least 30ms of backoff, got %s", elapsed)
+	}
+}
+
+func TestZKRetryHandlerCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	f := &flakyZK{failures: 3, err: errTestConnClosed}
+	zk := newZKRetryHandler(ctx, f, 3, time.Minute)
+
+	// The backoff is abandoned once the context is done.
+	if _, err := zk.GetTopics(nil); err != context.Canceled {
+		t.Errorf("Expected error '%v', got '%v'", context.Canceled, err)
+	}
+
+	if f.calls != 1 {
+		t.Errorf("Expected 1 call, got %d", f.calls)
+	}
+}
+
+func TestZKRetryHandlerDisabled(t *testing.T) {
+	f := &flakyZK{failures: 1, err: errTestConnClosed}
+
+	for _, attempts := range []int{0, 1} {
+		if zk := newZKRetryHandler(context.Background(), f, attempts, time.Millisecond); zk != f {
+			t.Errorf("Expected the unwrapped Handler with %d attempts", attempts)
+		}
+	}
+}
+
+func TestZKRetryGetTopics(t *testing.T) {
+	s := testServer()
+	f := &flakyZK{failures: 2, err: errTestConnClosed}
+	s.ZK = newZKRetryHandler(context.Background(), f, 3, time.Millisecond)
+
+	resp, err := s.GetTopics(context.Background(), &pb.TopicRequest{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if len(resp.Topics) != 2 {
+		t.Errorf("Expected 2 topics, got %d", len(resp.Topics))
+	}
+}
+
+func TestNewServerZKRetryConfig(t *testing.T) {
+	tests := map[int]Config{
+		0: {ZKRetryAttempts: -1},
+		1: {ZKRetryBackoff: -time.Second},
+	}
+
+	for i, c := range tests {
+		c.ReadReqRate, c.WriteReqRate, c.ZKTagsPrefix = 1, 1, "test"
+		if _, err := NewServer(c); err == nil {
+			t.Errorf("[test %d] Expected error", i)
+		}
+	}
+}